@@ -38,9 +38,18 @@ func main() {
 	if err != nil {
 		panic(fmt.Sprintf("failed to load config: %v", err))
 	}
+	if err := cfg.ValidateManager(); err != nil {
+		panic(err.Error())
+	}
 
-	// Initialize logger
-	logger, err := zap.NewProduction()
+	// Initialize logger; its level follows configuration reloads
+	logLevel, err := zap.ParseAtomicLevel(cfg.Observability.LogLevel)
+	if err != nil {
+		panic(fmt.Sprintf("invalid log level: %v", err))
+	}
+	zapConfig := zap.NewProductionConfig()
+	zapConfig.Level = logLevel
+	logger, err := zapConfig.Build()
 	if err != nil {
 		panic(fmt.Sprintf("failed to initialize logger: %v", err))
 	}
@@ -52,11 +61,21 @@ func main() {
 		logger.Fatal("failed to initialize catalog", zap.Error(err))
 	}
 
+	// Upgrade catalog records written by older versions before anything reads them
+	migrateCtx, migrateCancel := context.WithTimeout(context.Background(), 30*time.Second)
+	if _, err := cat.MigrateSchema(migrateCtx); err != nil {
+		logger.Fatal("failed to migrate catalog schema", zap.Error(err))
+	}
+	migrateCancel()
+
 	// Initialize resharder
 	resharderInstance := resharder.NewResharder(cat, logger)
 
 	// Initialize manager
 	shardManager := manager.NewManager(cat, logger, resharderInstance, cfg.Pricing)
+	shardManager.SetShardCopier(resharderInstance)
+	// Keep shard timelines in etcd alongside the shards
+	shardManager.SetShardTimeline(cat.Timeline())
 
 	// Initialize client apps (discover from existing shards)
 	if err := shardManager.InitializeClientApps(); err != nil {
@@ -82,8 +101,28 @@ func main() {
 		logger.Fatal("failed to create server", zap.Error(err))
 	}
 
+	// Apply reload-safe settings on SIGHUP or through the admin API
+	reloader, err := config.NewHotReloader(logger, config.HotReloaderConfig{ConfigPath: configPath, Initial: cfg})
+	if err != nil {
+		logger.Fatal("failed to set up configuration reloading", zap.Error(err))
+	}
+	reloader.OnReload(func(old, new *config.Config) error {
+		return logLevel.UnmarshalText([]byte(new.Observability.LogLevel))
+	})
+	srv.WatchConfig(reloader)
+
 	srv.StartAsync()
 
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			if _, err := reloader.Reload(); err != nil {
+				logger.Error("failed to reload configuration", zap.Error(err))
+			}
+		}
+	}()
+
 	// Wait for interrupt signal
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
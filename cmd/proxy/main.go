@@ -1,6 +1,7 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
 	"os/signal"
@@ -11,6 +12,12 @@ import (
 )
 
 func main() {
+	validateRules := flag.String("validate-rules", "", "check a rule file, print a report and exit without starting the proxy")
+	flag.Parse()
+	if *validateRules != "" {
+		os.Exit(runValidateRules(*validateRules))
+	}
+
 	// Initialize logger
 	logger, err := zap.NewProduction()
 	if err != nil {
@@ -32,12 +39,18 @@ func main() {
 	if addr := os.Getenv("PROXY_LISTEN_ADDR"); addr != "" {
 		config.ListenAddr = addr
 	}
+	if path := os.Getenv("PROXY_LISTEN_SOCKET"); path != "" {
+		config.ListenSocket = path
+	}
 	if addr := os.Getenv("PROXY_ADMIN_ADDR"); addr != "" {
 		config.AdminAddr = addr
 	}
 	if url := os.Getenv("SHARDING_MANAGER_URL"); url != "" {
 		config.ManagerURL = url
 	}
+	if err := config.Validate(); err != nil {
+		logger.Fatal("invalid proxy configuration", zap.Error(err))
+	}
 
 	// Create and start proxy
 	proxyServer := proxy.NewShardingProxy(config, logger)
@@ -52,6 +65,9 @@ func main() {
 	fmt.Println("║           SHARDING PROXY - ZERO CODE SHARDING                     ║")
 	fmt.Println("╠═══════════════════════════════════════════════════════════════════╣")
 	fmt.Printf("║  Database Proxy:  %s                                       ║\n", config.ListenAddr)
+	if config.ListenSocket != "" {
+		fmt.Printf("║  Unix Socket:     %s\n", config.ListenSocket)
+	}
 	fmt.Printf("║  Admin API:       %s                                       ║\n", config.AdminAddr)
 	fmt.Printf("║  Manager URL:     %s                            ║\n", config.ManagerURL)
 	fmt.Println("╠═══════════════════════════════════════════════════════════════════╣")
@@ -78,3 +94,29 @@ func main() {
 	}
 }
 
+// runValidateRules reports the problems in a rule file and returns the exit
+// code: 0 when the file is valid, 1 when it has problems or cannot be parsed
+func runValidateRules(path string) int {
+	config, problems, err := proxy.ValidateRuleFile(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+		return 1
+	}
+
+	rules := 0
+	for _, app := range config.ClientApps {
+		if app != nil {
+			rules += len(app.ShardingRules)
+		}
+	}
+
+	if len(problems) > 0 {
+		fmt.Printf("%s: %d problem(s) in %d client app(s) with %d rule(s)\n", path, len(problems), len(config.ClientApps), rules)
+		for _, problem := range problems {
+			fmt.Printf("  %s\n", problem)
+		}
+		return 1
+	}
+	fmt.Printf("%s: OK, %d client app(s) with %d rule(s)\n", path, len(config.ClientApps), rules)
+	return 0
+}
@@ -2,18 +2,32 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/sharding-system/internal/server"
 	"github.com/sharding-system/pkg/catalog"
 	"github.com/sharding-system/pkg/config"
+	"github.com/sharding-system/pkg/health"
+	"github.com/sharding-system/pkg/models"
+	"github.com/sharding-system/pkg/monitoring"
+	"github.com/sharding-system/pkg/pricing"
 	"github.com/sharding-system/pkg/router"
+	clientv3 "go.etcd.io/etcd/client/v3"
 	"go.uber.org/zap"
 )
 
+// replicaLagInterval is how often replica lag is measured for staleness-bounded reads
+const replicaLagInterval = 10 * time.Second
+
+// replicaHealthInterval is how often replicas are health checked; replicas
+// failing health.DefaultDownAfter checks in a row get no reads
+const replicaHealthInterval = 10 * time.Second
+
 // @title Sharding System Router API
 // @version 1.0
 // @description API for routing requests to shards based on shard keys
@@ -35,6 +49,9 @@ func main() {
 	if err != nil {
 		panic(fmt.Sprintf("failed to load config: %v", err))
 	}
+	if err := cfg.ValidateRouter(); err != nil {
+		panic(err.Error())
+	}
 
 	// Initialize logger
 	logger, err := zap.NewProduction()
@@ -59,6 +76,37 @@ func main() {
 		cfg.Pricing,
 	)
 	defer shardRouter.Close()
+	shardRouter.SetStatementTimeout(cfg.Sharding.StatementTimeout)
+	shardRouter.SetShadowReads(cfg.Sharding.ShadowReadPercent)
+	loadClientQoS(cat, shardRouter, logger)
+
+	// Retire pools whose endpoints left the catalog, e.g. DSNs with rotated credentials
+	watchCtx, watchCancel := context.WithCancel(context.Background())
+	defer watchCancel()
+	go shardRouter.WatchCatalog(watchCtx)
+
+	if cfg.Sharding.QueryGuard.Enabled() {
+		shardRouter.SetQueryGuard(cfg.Sharding.QueryGuard, nil)
+	}
+
+	// Measure replica lag so reads with a max_staleness budget can use replicas,
+	// and spread reads across replicas by health
+	if cfg.Sharding.ReplicaPolicy == "replica_ok" {
+		lagCollector := monitoring.NewPostgresStatsCollector(logger, replicaLagInterval)
+		trackReplicaLag(cat, lagCollector, logger)
+		go watchReplicaLag(watchCtx, cat, lagCollector, logger)
+		go lagCollector.Start(context.Background())
+		defer lagCollector.Stop()
+		shardRouter.SetLagSource(lagCollector)
+		shardRouter.SetReplicaBalancer(router.NewReplicaBalancer(cfg.Sharding.ReplicaBalancing))
+
+		// Stop reading from replicas that stay down, until they recover
+		healthController := health.NewController(cat, logger, replicaHealthInterval, cfg.Sharding.ReplicaBalancing.MaxLag)
+		healthCtx, healthCancel := context.WithCancel(context.Background())
+		defer healthCancel()
+		go healthController.Start(healthCtx)
+		shardRouter.SetHealthSource(healthController)
+	}
 
 	// Create and start server
 	srv, err := server.NewRouterServer(cfg, shardRouter, logger)
@@ -81,3 +129,82 @@ func main() {
 		logger.Error("server shutdown error", zap.Error(err))
 	}
 }
+
+// loadClientQoS schedules each client app's queries with the QoS class of its
+// pricing tier. Apps without a tier of their own keep the router's.
+func loadClientQoS(cat *catalog.EtcdCatalog, shardRouter *router.Router, logger *zap.Logger) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	resp, err := cat.GetEtcdClient().Get(ctx, "/client_apps/", clientv3.WithPrefix())
+	if err != nil {
+		logger.Warn("failed to load client apps, their queries get the router tier's QoS class", zap.Error(err))
+		return
+	}
+	for _, kv := range resp.Kvs {
+		var app struct {
+			ID   string `json:"id"`
+			Tier string `json:"tier"`
+		}
+		if err := json.Unmarshal(kv.Value, &app); err != nil {
+			logger.Warn("failed to decode client app", zap.String("key", string(kv.Key)), zap.Error(err))
+			continue
+		}
+		if app.Tier == "" {
+			continue
+		}
+		tier, err := pricing.ParseTier(app.Tier)
+		if err != nil {
+			logger.Warn("client app has an unknown tier, its queries get the router tier's QoS class",
+				zap.String("client_app_id", app.ID),
+				zap.Error(err))
+			continue
+		}
+		shardRouter.SetClientQoS(app.ID, pricing.GetLimits(string(tier)).QoSClass)
+	}
+}
+
+// trackReplicaLag registers every shard replica with the collector
+func trackReplicaLag(cat catalog.Catalog, collector *monitoring.PostgresStatsCollector, logger *zap.Logger) {
+	shards, err := cat.ListShards("")
+	if err != nil {
+		logger.Warn("failed to list shards for replica lag tracking", zap.Error(err))
+		return
+	}
+	syncReplicaLag(shards, collector, logger)
+}
+
+// watchReplicaLag keeps the collector's replicas in step with the catalog, so
+// replicas added after startup are measured and removed ones are dropped
+func watchReplicaLag(ctx context.Context, cat catalog.Catalog, collector *monitoring.PostgresStatsCollector, logger *zap.Logger) {
+	updates, err := cat.Watch(ctx)
+	if err != nil {
+		logger.Warn("failed to watch catalog, replicas added later get no lag tracking", zap.Error(err))
+		return
+	}
+	for update := range updates {
+		syncReplicaLag(update.Shards, collector, logger)
+	}
+}
+
+// syncReplicaLag registers the replicas of shards with the collector and
+// unregisters the ones no longer among them. Replicas already registered with
+// the same endpoint keep their connection.
+func syncReplicaLag(shards []models.Shard, collector *monitoring.PostgresStatsCollector, logger *zap.Logger) {
+	live := make(map[string]bool)
+	for _, shard := range shards {
+		for i, replica := range shard.Replicas {
+			id := fmt.Sprintf("%s-replica-%d", shard.ID, i)
+			live[id] = true
+			if err := collector.RegisterDatabase(id, replica); err != nil {
+				logger.Warn("failed to register replica for lag tracking, reads with max_staleness will skip it",
+					zap.String("replica", id),
+					zap.Error(err))
+			}
+		}
+	}
+	for _, id := range collector.RegisteredDatabases() {
+		if !live[id] {
+			collector.UnregisterDatabase(id)
+		}
+	}
+}
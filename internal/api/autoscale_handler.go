@@ -1,18 +1,29 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
 
 	"github.com/gorilla/mux"
 	"github.com/sharding-system/pkg/autoscale"
+	"github.com/sharding-system/pkg/operator"
 	"go.uber.org/zap"
 )
 
+// ShardResizer changes the CPU and memory of running shards; implemented by
+// the Kubernetes operator
+type ShardResizer interface {
+	UpdateShardResources(ctx context.Context, shardID string, resources operator.ShardResources) (*operator.ResourceUpdate, error)
+}
+
 // AutoscaleHandler handles auto-scaling API endpoints
 type AutoscaleHandler struct {
 	detector *autoscale.HotShardDetector
 	splitter *autoscale.AutoSplitter
+	resizer  ShardResizer
 	logger   *zap.Logger
 }
 
@@ -29,6 +40,11 @@ func NewAutoscaleHandler(
 	}
 }
 
+// SetShardResizer sets what changes the CPU and memory of running shards
+func (h *AutoscaleHandler) SetShardResizer(resizer ShardResizer) {
+	h.resizer = resizer
+}
+
 // RegisterRoutes registers autoscale API routes
 func (h *AutoscaleHandler) RegisterRoutes(r *mux.Router) {
 	r.HandleFunc("/api/v1/autoscale/status", h.GetStatus).Methods("GET", "OPTIONS")
@@ -38,6 +54,8 @@ func (h *AutoscaleHandler) RegisterRoutes(r *mux.Router) {
 	r.HandleFunc("/api/v1/autoscale/cold-shards", h.GetColdShards).Methods("GET", "OPTIONS")
 	r.HandleFunc("/api/v1/autoscale/thresholds", h.GetThresholds).Methods("GET", "OPTIONS")
 	r.HandleFunc("/api/v1/autoscale/thresholds", h.UpdateThresholds).Methods("PUT", "OPTIONS")
+	r.HandleFunc("/api/v1/autoscale/shards/{id}/expand-storage", h.ExpandStorage).Methods("POST", "OPTIONS")
+	r.HandleFunc("/api/v1/autoscale/shards/{id}/resources", h.UpdateResources).Methods("PUT", "OPTIONS")
 }
 
 // GetStatus returns the current status of auto-scaling
@@ -137,3 +155,93 @@ func (h *AutoscaleHandler) UpdateThresholds(w http.ResponseWriter, r *http.Reque
 	json.NewEncoder(w).Encode(thresholds)
 }
 
+// expandStorageRequest is the body of a storage expansion
+type expandStorageRequest struct {
+	Size string `json:"size"` // Empty grows by the configured percentage
+}
+
+// ExpandStorage grows the volumes of a shard
+// @Summary Expand shard storage
+// @Description Grows the PVCs of a shard's primary and replicas to a size, or by the configured percentage when none is given. The StorageClass must allow volume expansion.
+// @Tags autoscale
+// @Accept json
+// @Produce json
+// @Param id path string true "Shard ID"
+// @Param request body expandStorageRequest false "Target size, e.g. 20Gi"
+// @Success 200 {object} operator.StorageExpansion
+// @Failure 400 {string} string "Invalid size"
+// @Failure 404 {string} string "Shard not found"
+// @Failure 409 {string} string "Storage cannot grow"
+// @Failure 501 {string} string "Storage expansion not supported"
+// @Router /autoscale/shards/{id}/expand-storage [post]
+func (h *AutoscaleHandler) ExpandStorage(w http.ResponseWriter, r *http.Request) {
+	var req expandStorageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	expansion, err := h.splitter.ExpandStorage(r.Context(), mux.Vars(r)["id"], req.Size)
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, autoscale.ErrStorageExpansionUnsupported):
+			status = http.StatusNotImplemented
+		case errors.Is(err, operator.ErrInvalidStorageSize):
+			status = http.StatusBadRequest
+		case errors.Is(err, operator.ErrShardNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, operator.ErrVolumeExpansionNotAllowed), errors.Is(err, operator.ErrStorageShrink),
+			errors.Is(err, operator.ErrStorageAtLimit):
+			status = http.StatusConflict
+		}
+		h.logger.Warn("failed to expand shard storage", zap.String("shard_id", mux.Vars(r)["id"]), zap.Error(err))
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(expansion)
+}
+
+// UpdateResources changes the CPU and memory of a running shard
+// @Summary Update shard resources
+// @Description Sets the CPU and memory of a shard's primary and replicas in place; their pods restart one by one, replicas first, and the request returns once every rollout is ready. An omitted value is kept.
+// @Tags autoscale
+// @Accept json
+// @Produce json
+// @Param id path string true "Shard ID"
+// @Param resources body operator.ShardResources true "CPU and memory, e.g. 2 and 4Gi"
+// @Success 200 {object} operator.ResourceUpdate
+// @Failure 400 {string} string "Invalid resources"
+// @Failure 404 {string} string "Shard not found"
+// @Failure 501 {string} string "Resource updates not supported"
+// @Router /autoscale/shards/{id}/resources [put]
+func (h *AutoscaleHandler) UpdateResources(w http.ResponseWriter, r *http.Request) {
+	if h.resizer == nil {
+		http.Error(w, "updating shard resources is not supported", http.StatusNotImplemented)
+		return
+	}
+	var resources operator.ShardResources
+	if err := json.NewDecoder(r.Body).Decode(&resources); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	update, err := h.resizer.UpdateShardResources(r.Context(), mux.Vars(r)["id"], resources)
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, operator.ErrInvalidResources):
+			status = http.StatusBadRequest
+		case errors.Is(err, operator.ErrShardNotFound):
+			status = http.StatusNotFound
+		}
+		h.logger.Warn("failed to update shard resources", zap.String("shard_id", mux.Vars(r)["id"]), zap.Error(err))
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(update)
+}
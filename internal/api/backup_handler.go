@@ -2,10 +2,13 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 
 	"github.com/gorilla/mux"
+	"github.com/sharding-system/internal/middleware"
 	"github.com/sharding-system/pkg/backup"
+	"github.com/sharding-system/pkg/security"
 	"go.uber.org/zap"
 )
 
@@ -13,6 +16,7 @@ import (
 type BackupHandler struct {
 	backupService *backup.BackupService
 	logger        *zap.Logger
+	rbac          *security.RBAC
 }
 
 // NewBackupHandler creates a new backup handler
@@ -20,6 +24,7 @@ func NewBackupHandler(backupService *backup.BackupService, logger *zap.Logger) *
 	return &BackupHandler{
 		backupService: backupService,
 		logger:        logger,
+		rbac:          security.NewRBAC(),
 	}
 }
 
@@ -115,16 +120,18 @@ func (h *BackupHandler) GetBackup(w http.ResponseWriter, r *http.Request) {
 
 // RestoreBackup handles backup restore requests
 // @Summary Restore database from backup
-// @Description Restores a database from a backup
+// @Description Restores a database from a backup. Listing tables restores only those tables, optionally with the tables they reference and into a staging schema.
 // @Tags backups
 // @Accept json
 // @Produce json
 // @Param id path string true "Database ID"
 // @Param backup_id path string true "Backup ID"
-// @Param request body map[string]string true "Restore request (optional target_database_id)"
+// @Param request body map[string]interface{} true "Restore request (optional target_database_id, tables, include_referenced, staging_schema)"
+// @Success 200 {object} backup.TableRestoreResult "Tables restored"
 // @Success 202 {object} map[string]string "Restore started"
 // @Failure 400 {object} map[string]interface{} "Bad request"
 // @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Failure 501 {string} string "Whole-database restores are not supported; list tables"
 // @Router /api/v1/databases/{id}/backups/{backup_id}/restore [post]
 func (h *BackupHandler) RestoreBackup(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
@@ -133,6 +140,7 @@ func (h *BackupHandler) RestoreBackup(w http.ResponseWriter, r *http.Request) {
 
 	var req struct {
 		TargetDatabaseID string `json:"target_database_id"`
+		backup.TableRestoreRequest
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		req.TargetDatabaseID = databaseID // Default to same database
@@ -142,7 +150,23 @@ func (h *BackupHandler) RestoreBackup(w http.ResponseWriter, r *http.Request) {
 		req.TargetDatabaseID = databaseID
 	}
 
+	if len(req.Tables) > 0 {
+		result, err := h.backupService.RestoreTables(r.Context(), backupID, req.TargetDatabaseID, req.TableRestoreRequest)
+		if err != nil {
+			h.logger.Error("failed to restore tables", zap.Error(err))
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+		return
+	}
+
 	if err := h.backupService.RestoreBackup(r.Context(), backupID, req.TargetDatabaseID); err != nil {
+		if errors.Is(err, backup.ErrFullRestoreUnsupported) {
+			http.Error(w, err.Error(), http.StatusNotImplemented)
+			return
+		}
 		h.logger.Error("failed to restore backup", zap.Error(err))
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -202,7 +226,13 @@ func SetupBackupRoutes(router *mux.Router, handler *BackupHandler) {
 	router.HandleFunc("/api/v1/databases/{id}/backups", handler.CreateBackup).Methods("POST", "OPTIONS")
 	router.HandleFunc("/api/v1/databases/{id}/backups", handler.ListBackups).Methods("GET", "OPTIONS")
 	router.HandleFunc("/api/v1/databases/{id}/backups/{backup_id}", handler.GetBackup).Methods("GET", "OPTIONS")
-	router.HandleFunc("/api/v1/databases/{id}/backups/{backup_id}/restore", handler.RestoreBackup).Methods("POST", "OPTIONS")
 	router.HandleFunc("/api/v1/databases/{id}/backups/schedule", handler.ScheduleBackup).Methods("POST", "OPTIONS")
 }
 
+// SetupProtectedBackupRoutes sets up backup routes that need authentication.
+// Restores drop and recreate live tables, so they need the restore permission.
+func SetupProtectedBackupRoutes(router *mux.Router, handler *BackupHandler) {
+	router.Handle("/api/v1/databases/{id}/backups/{backup_id}/restore",
+		middleware.RequirePermission(handler.rbac, "backups", "restore")(http.HandlerFunc(handler.RestoreBackup))).Methods("POST", "OPTIONS")
+}
+
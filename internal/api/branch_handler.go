@@ -2,6 +2,7 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 
 	"github.com/gorilla/mux"
@@ -27,7 +28,9 @@ func NewBranchHandler(service *branch.BranchService, logger *zap.Logger) *Branch
 func (h *BranchHandler) RegisterRoutes(r *mux.Router) {
 	r.HandleFunc("/api/v1/databases/{dbName}/branches", h.ListBranches).Methods("GET", "OPTIONS")
 	r.HandleFunc("/api/v1/databases/{dbName}/branches", h.CreateBranch).Methods("POST", "OPTIONS")
+	r.HandleFunc("/api/v1/branches", h.CreateBranchFromParent).Methods("POST", "OPTIONS")
 	r.HandleFunc("/api/v1/branches/{branchID}", h.GetBranch).Methods("GET", "OPTIONS")
+	r.HandleFunc("/api/v1/branches/{branchID}/lineage", h.GetLineage).Methods("GET", "OPTIONS")
 	r.HandleFunc("/api/v1/branches/{branchID}", h.DeleteBranch).Methods("DELETE", "OPTIONS")
 	r.HandleFunc("/api/v1/branches/{branchID}/merge", h.MergeBranch).Methods("POST", "OPTIONS")
 }
@@ -61,9 +64,42 @@ func (h *BranchHandler) CreateBranch(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	branch, err := h.service.CreateBranch(r.Context(), dbName, req.Name)
+	h.createBranch(w, r, dbName, req.Name)
+}
+
+// CreateBranchFromParent creates a copy-on-write branch of a database or of another branch
+// @Summary Create branch
+// @Description Creates an isolated copy of a database or branch, from a storage snapshot where available and otherwise from its latest backup
+// @Tags branches
+// @Accept json
+// @Produce json
+// @Param request body object true "Branch details" example({"parent": "orders", "name": "feature-x"})
+// @Success 201 {object} branch.Branch
+// @Failure 400 {string} string "Bad request"
+// @Failure 500 {string} string "Internal server error"
+// @Router /branches [post]
+func (h *BranchHandler) CreateBranchFromParent(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Parent string `json:"parent"`
+		Name   string `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if req.Parent == "" || req.Name == "" {
+		http.Error(w, "parent and branch name are required", http.StatusBadRequest)
+		return
+	}
+
+	h.createBranch(w, r, req.Parent, req.Name)
+}
+
+func (h *BranchHandler) createBranch(w http.ResponseWriter, r *http.Request, parent, name string) {
+	branch, err := h.service.CreateBranch(r.Context(), parent, name)
 	if err != nil {
-		h.logger.Error("failed to create branch", zap.String("db_name", dbName), zap.Error(err))
+		h.logger.Error("failed to create branch", zap.String("db_name", parent), zap.Error(err))
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -113,13 +149,37 @@ func (h *BranchHandler) GetBranch(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(branch)
 }
 
+// GetLineage returns a branch's ancestry
+// @Summary Get branch lineage
+// @Description Returns the chain of branches from the root database down to the branch, oldest first
+// @Tags branches
+// @Produce json
+// @Param branchID path string true "Branch ID"
+// @Success 200 {array} branch.Branch
+// @Failure 404 {string} string "Branch not found"
+// @Router /branches/{branchID}/lineage [get]
+func (h *BranchHandler) GetLineage(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	branchID := vars["branchID"]
+
+	lineage, err := h.service.Lineage(branchID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(lineage)
+}
+
 // DeleteBranch deletes a branch
 // @Summary Delete branch
-// @Description Deletes a database branch
+// @Description Deletes a database branch and its database
 // @Tags branches
 // @Param branchID path string true "Branch ID"
 // @Success 204 "Branch deleted successfully"
 // @Failure 404 {string} string "Branch not found"
+// @Failure 409 {string} string "Branch has child branches"
 // @Failure 500 {string} string "Internal server error"
 // @Router /branches/{branchID} [delete]
 func (h *BranchHandler) DeleteBranch(w http.ResponseWriter, r *http.Request) {
@@ -127,7 +187,14 @@ func (h *BranchHandler) DeleteBranch(w http.ResponseWriter, r *http.Request) {
 	branchID := vars["branchID"]
 
 	err := h.service.DeleteBranch(r.Context(), branchID)
-	if err != nil {
+	switch {
+	case errors.Is(err, branch.ErrBranchNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case errors.Is(err, branch.ErrBranchHasChildren):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
 		h.logger.Error("failed to delete branch", zap.String("branch_id", branchID), zap.Error(err))
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -0,0 +1,225 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/sharding-system/pkg/catalog"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultEventPageSize = 100
+	maxEventPageSize     = 1000
+)
+
+// CatalogHandler handles catalog event log and snapshot API endpoints
+type CatalogHandler struct {
+	events    catalog.EventLog
+	catalog   catalog.Catalog
+	snapshots catalog.SnapshotStore
+	logger    *zap.Logger
+}
+
+// NewCatalogHandler creates a new catalog handler
+func NewCatalogHandler(events catalog.EventLog, logger *zap.Logger) *CatalogHandler {
+	return &CatalogHandler{
+		events: events,
+		logger: logger,
+	}
+}
+
+// SetSnapshots enables the snapshot endpoints, capturing snapshots of cat into store
+func (h *CatalogHandler) SetSnapshots(cat catalog.Catalog, store catalog.SnapshotStore) {
+	h.catalog = cat
+	h.snapshots = store
+}
+
+// RegisterRoutes registers catalog API routes
+func (h *CatalogHandler) RegisterRoutes(r *mux.Router) {
+	r.HandleFunc("/api/v1/catalog/events", h.ListEvents).Methods("GET", "OPTIONS")
+	if h.snapshots != nil {
+		r.HandleFunc("/api/v1/catalog/snapshots", h.CaptureSnapshot).Methods("POST", "OPTIONS")
+		r.HandleFunc("/api/v1/catalog/snapshots", h.ListSnapshots).Methods("GET")
+		r.HandleFunc("/api/v1/catalog/diff", h.DiffSnapshots).Methods("GET", "OPTIONS")
+	}
+}
+
+// ListEvents returns catalog mutation events after a sequence number
+// @Summary List catalog events
+// @Description Returns catalog mutation events with a sequence number greater than since, oldest first. Pass next_since back as since to continue reading.
+// @Tags catalog
+// @Produce json
+// @Param since query int false "Return events after this sequence number" default(0)
+// @Param limit query int false "Maximum number of events to return" default(100)
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {string} string "Bad request"
+// @Failure 500 {string} string "Internal server error"
+// @Router /catalog/events [get]
+func (h *CatalogHandler) ListEvents(w http.ResponseWriter, r *http.Request) {
+	var since int64
+	if v := r.URL.Query().Get("since"); v != "" {
+		parsed, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || parsed < 0 {
+			http.Error(w, "since must be a non-negative integer", http.StatusBadRequest)
+			return
+		}
+		since = parsed
+	}
+
+	limit := defaultEventPageSize
+	if v := r.URL.Query().Get("limit"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil || parsed <= 0 {
+			http.Error(w, "limit must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		if parsed > maxEventPageSize {
+			parsed = maxEventPageSize
+		}
+		limit = parsed
+	}
+
+	events, err := h.events.Since(r.Context(), since, limit)
+	if err != nil {
+		h.logger.Error("failed to read catalog events", zap.Int64("since", since), zap.Error(err))
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	nextSince := since
+	if len(events) > 0 {
+		nextSince = events[len(events)-1].Seq
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"events":     events,
+		"next_since": nextSince,
+	})
+}
+
+// CaptureSnapshotRequest names a catalog snapshot to capture
+type CaptureSnapshotRequest struct {
+	Name string `json:"name"`
+}
+
+// CaptureSnapshot records the catalog's current shards under a name
+// @Summary Capture a catalog snapshot
+// @Description Records every shard in the catalog under a name, so the catalog can later be diffed against it. A name can be captured once.
+// @Tags catalog
+// @Accept json
+// @Produce json
+// @Param request body CaptureSnapshotRequest true "Snapshot name"
+// @Success 201 {object} catalog.SnapshotInfo
+// @Failure 400 {string} string "Bad request"
+// @Failure 409 {string} string "Snapshot name already taken"
+// @Failure 500 {string} string "Internal server error"
+// @Router /catalog/snapshots [post]
+func (h *CatalogHandler) CaptureSnapshot(w http.ResponseWriter, r *http.Request) {
+	var req CaptureSnapshotRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Name == "" {
+		http.Error(w, "name is required", http.StatusBadRequest)
+		return
+	}
+
+	snapshot, err := catalog.CaptureSnapshot(r.Context(), h.catalog, h.snapshots, req.Name)
+	if err != nil {
+		switch {
+		case errors.Is(err, catalog.ErrInvalidSnapshotName):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		case errors.Is(err, catalog.ErrSnapshotExists):
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		h.logger.Error("failed to capture catalog snapshot", zap.String("name", req.Name), zap.Error(err))
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.logger.Info("captured catalog snapshot",
+		zap.String("name", snapshot.Name),
+		zap.Int64("version", snapshot.Version),
+		zap.Int("shards", len(snapshot.Shards)))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(snapshot.Info())
+}
+
+// ListSnapshots describes the captured catalog snapshots
+// @Summary List catalog snapshots
+// @Description Returns the name, catalog version, capture time and shard count of every catalog snapshot, oldest first
+// @Tags catalog
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Failure 500 {string} string "Internal server error"
+// @Router /catalog/snapshots [get]
+func (h *CatalogHandler) ListSnapshots(w http.ResponseWriter, r *http.Request) {
+	snapshots, err := h.snapshots.List(r.Context())
+	if err != nil {
+		h.logger.Error("failed to list catalog snapshots", zap.Error(err))
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"snapshots": snapshots,
+	})
+}
+
+// DiffSnapshots compares two catalog snapshots
+// @Summary Diff catalog snapshots
+// @Description Returns the shards and routes added, removed or changed between two catalog snapshots. Changed shards list each changed field. Snapshots hold no credentials, so usernames, passwords and the credentials in endpoints are never shown or compared.
+// @Tags catalog
+// @Produce json
+// @Param from query string true "Snapshot to compare from"
+// @Param to query string true "Snapshot to compare to"
+// @Success 200 {object} catalog.SnapshotDiff
+// @Failure 400 {string} string "Bad request"
+// @Failure 404 {string} string "Snapshot not found"
+// @Failure 500 {string} string "Internal server error"
+// @Router /catalog/diff [get]
+func (h *CatalogHandler) DiffSnapshots(w http.ResponseWriter, r *http.Request) {
+	fromName, toName := r.URL.Query().Get("from"), r.URL.Query().Get("to")
+	if fromName == "" || toName == "" {
+		http.Error(w, "from and to snapshot names are required", http.StatusBadRequest)
+		return
+	}
+
+	snapshots := make([]*catalog.Snapshot, 0, 2)
+	for _, name := range []string{fromName, toName} {
+		snapshot, err := h.snapshots.Get(r.Context(), name)
+		if err != nil {
+			if errors.Is(err, catalog.ErrSnapshotNotFound) {
+				http.Error(w, err.Error(), http.StatusNotFound)
+				return
+			}
+			h.logger.Error("failed to read catalog snapshot", zap.String("name", name), zap.Error(err))
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+
+	diff, err := catalog.DiffSnapshots(snapshots[0], snapshots[1])
+	if err != nil {
+		h.logger.Error("failed to diff catalog snapshots",
+			zap.String("from", fromName),
+			zap.String("to", toName),
+			zap.Error(err))
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(diff)
+}
@@ -23,9 +23,16 @@ type ClusterScannerHandler struct {
 	multiClusterScanner    *scanner.MultiClusterScanner
 	prometheusCollector    *monitoring.PrometheusCollector
 	postgresStatsCollector *monitoring.PostgresStatsCollector
+	scanResults            ScanResultsUpdater
 	logger                 *zap.Logger
 }
 
+// ScanResultsUpdater receives the results of scans; DatabaseHandler lists
+// them and keeps their history
+type ScanResultsUpdater interface {
+	UpdateScanResults(results []models.ScannedDatabase)
+}
+
 // NewClusterScannerHandler creates a new cluster scanner handler
 func NewClusterScannerHandler(
 	clusterManager *scanner.ClusterManager,
@@ -43,6 +50,11 @@ func NewClusterScannerHandler(
 	}
 }
 
+// SetScanResultsUpdater sets what receives the results of requested scans
+func (h *ClusterScannerHandler) SetScanResultsUpdater(updater ScanResultsUpdater) {
+	h.scanResults = updater
+}
+
 // RegisterCluster handles cluster registration requests
 // @Summary Register a new Kubernetes cluster for scanning
 // @Description Registers a Kubernetes cluster (cloud or on-prem) for database scanning
@@ -181,8 +193,10 @@ func (h *ClusterScannerHandler) ScanClusters(w http.ResponseWriter, r *http.Requ
 	// Register discovered databases for metrics collection
 	h.registerDatabasesForMetrics(result.Results)
 
-	// Store scan results (if database handler is available, update it)
-	// This will be done through a shared storage mechanism in production
+	// Keep the results listed with the databases and in their history
+	if h.scanResults != nil {
+		h.scanResults.UpdateScanResults(result.Results)
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(result)
@@ -229,6 +243,15 @@ func (h *ClusterScannerHandler) registerDatabasesForMetrics(databases []models.S
 					zap.String("database", db.DatabaseName),
 					zap.String("shard_id", shardID))
 			}
+
+			// Export the row counts of a deep scan's tables, capped per database
+			if db.ScanResults != nil && len(db.ScanResults.TableStats) > 0 {
+				tableRows := make(map[string]int64, len(db.ScanResults.TableStats))
+				for _, table := range db.ScanResults.TableStats {
+					tableRows[table.Name] = table.RowCount
+				}
+				h.prometheusCollector.RecordPostgresTables(db.ClusterID, db.ClusterName, db.Namespace, db.DatabaseName, db.Host, tableRows)
+			}
 		}
 
 		// Register with PostgreSQL stats collector
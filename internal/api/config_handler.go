@@ -0,0 +1,55 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/sharding-system/internal/middleware"
+	"github.com/sharding-system/pkg/config"
+	"github.com/sharding-system/pkg/security"
+	"go.uber.org/zap"
+)
+
+// ConfigHandler handles configuration API endpoints
+type ConfigHandler struct {
+	reloader *config.HotReloader
+	logger   *zap.Logger
+	rbac     *security.RBAC
+}
+
+// NewConfigHandler creates a new configuration handler
+func NewConfigHandler(reloader *config.HotReloader, logger *zap.Logger) *ConfigHandler {
+	return &ConfigHandler{
+		reloader: reloader,
+		logger:   logger,
+		rbac:     security.NewRBAC(),
+	}
+}
+
+// RegisterRoutes registers configuration API routes. A reload changes
+// settings for the whole manager, so only admins may run it by default.
+func (h *ConfigHandler) RegisterRoutes(r *mux.Router) {
+	r.Handle("/api/v1/admin/config/reload",
+		middleware.RequirePermission(h.rbac, "config", "reload")(http.HandlerFunc(h.ReloadConfig))).Methods("POST", "OPTIONS")
+}
+
+// ReloadConfig reloads the configuration file
+// @Summary Reload configuration
+// @Description Reads the configuration file again and applies its reload-safe settings (log level, collection interval, slow query threshold, table series cap and CORS origins) without dropping connections. An invalid file is rejected and the running configuration is kept. Changed settings that need a restart are listed in restart_required.
+// @Tags admin
+// @Produce json
+// @Success 200 {object} config.ReloadResult
+// @Failure 422 {string} string "Invalid configuration"
+// @Router /admin/config/reload [post]
+func (h *ConfigHandler) ReloadConfig(w http.ResponseWriter, r *http.Request) {
+	result, err := h.reloader.Reload()
+	if err != nil {
+		h.logger.Warn("configuration reload rejected", zap.Error(err))
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
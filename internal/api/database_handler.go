@@ -1,17 +1,23 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
 	"sync"
+	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/sharding-system/internal/middleware"
+	"github.com/sharding-system/pkg/backup"
 	"github.com/sharding-system/pkg/database"
 	"github.com/sharding-system/pkg/manager"
 	"github.com/sharding-system/pkg/models"
 	"github.com/sharding-system/pkg/scanner"
+	"github.com/sharding-system/pkg/security"
 	"go.uber.org/zap"
 )
 
@@ -21,10 +27,19 @@ type DatabaseHandler struct {
 	manager             *manager.Manager
 	logger              *zap.Logger
 	databases           map[string]*database.SimpleDatabase
+	databasesMu         sync.RWMutex
 	clusterManager      *scanner.ClusterManager
 	multiClusterScanner *scanner.MultiClusterScanner
 	scanResults         map[string]models.ScannedDatabase // Store scan results by database ID
 	scanResultsMu       sync.RWMutex
+	scanHistory         *scanner.ScanHistory // Keeps every scan's results for size history
+	backupStatuses      ShardBackupStatusSource
+	rbac                *security.RBAC
+}
+
+// ShardBackupStatusSource reports how each shard's backups are going
+type ShardBackupStatusSource interface {
+	ShardBackupStatus(shardID string) (backup.ShardBackupStatus, bool)
 }
 
 // NewDatabaseHandler creates a new database handler
@@ -42,6 +57,7 @@ func NewDatabaseHandler(
 		clusterManager:      clusterManager,
 		multiClusterScanner: multiClusterScanner,
 		scanResults:         make(map[string]models.ScannedDatabase),
+		rbac:                security.NewRBAC(),
 	}
 }
 
@@ -50,6 +66,45 @@ func (h *DatabaseHandler) SetManager(mgr *manager.Manager) {
 	h.manager = mgr
 }
 
+// SetScanHistory sets where the results of every scan are kept
+func (h *DatabaseHandler) SetScanHistory(history *scanner.ScanHistory) {
+	h.scanHistory = history
+}
+
+// SetBackupStatuses sets where the status endpoint reads shard backup status from
+func (h *DatabaseHandler) SetBackupStatuses(source ShardBackupStatusSource) {
+	h.backupStatuses = source
+}
+
+// BackupShards returns the shards of a database created through the handler,
+// for tracking the backups of each
+func (h *DatabaseHandler) BackupShards(databaseID string) []string {
+	db, ok := h.createdDatabase(databaseID)
+	if !ok {
+		return nil
+	}
+	return append([]string(nil), db.ShardIDs...)
+}
+
+// createdDatabase returns a database created through the handler
+func (h *DatabaseHandler) createdDatabase(id string) (*database.SimpleDatabase, bool) {
+	h.databasesMu.RLock()
+	defer h.databasesMu.RUnlock()
+	db, ok := h.databases[id]
+	return db, ok
+}
+
+// createdDatabases returns the databases created through the handler
+func (h *DatabaseHandler) createdDatabases() []*database.SimpleDatabase {
+	h.databasesMu.RLock()
+	defer h.databasesMu.RUnlock()
+	databases := make([]*database.SimpleDatabase, 0, len(h.databases))
+	for _, db := range h.databases {
+		databases = append(databases, db)
+	}
+	return databases
+}
+
 // CreateDatabase handles simplified database creation
 // @Summary Create a new sharded database
 // @Description Creates a new sharded database with minimal configuration. Uses templates for quick setup.
@@ -74,13 +129,12 @@ func (h *DatabaseHandler) CreateDatabase(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	// Set defaults
-	if req.Template == "" {
-		req.Template = "starter"
-	}
-
-	// Create database
+	// Create database; the service applies the default template when none is given
 	db, err := h.dbService.CreateDatabase(r.Context(), req)
+	if errors.Is(err, database.ErrTemplateNotFound) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	if err != nil {
 		h.logger.Error("failed to create database", zap.Error(err))
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -88,7 +142,9 @@ func (h *DatabaseHandler) CreateDatabase(w http.ResponseWriter, r *http.Request)
 	}
 
 	// Store database (in production, use persistent storage)
+	h.databasesMu.Lock()
 	h.databases[db.ID] = db
+	h.databasesMu.Unlock()
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
@@ -137,7 +193,7 @@ func (h *DatabaseHandler) GetDatabase(w http.ResponseWriter, r *http.Request) {
 	dbID := vars["id"]
 
 	// First check manually created databases
-	db, ok := h.databases[dbID]
+	db, ok := h.createdDatabase(dbID)
 	if !ok {
 		// Check discovered databases from scan results
 		h.scanResultsMu.RLock()
@@ -217,7 +273,7 @@ func (h *DatabaseHandler) ListDatabases(w http.ResponseWriter, r *http.Request)
 	databases := make([]*database.SimpleDatabase, 0)
 
 	// Start with manually created databases
-	for _, db := range h.databases {
+	for _, db := range h.createdDatabases() {
 		databases = append(databases, db)
 		seenDBs[db.ID] = true
 	}
@@ -335,23 +391,152 @@ func (h *DatabaseHandler) UpdateScanResults(results []models.ScannedDatabase) {
 		h.scanResults[db.ID] = db
 	}
 	h.logger.Info("updated scan results", zap.Int("count", len(results)))
+
+	if h.scanHistory != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := h.scanHistory.Record(ctx, results, time.Now()); err != nil {
+			h.logger.Error("failed to record scan history", zap.Error(err))
+		}
+	}
+}
+
+// defaultSizeHistoryRange is how far back size history goes when no start is given
+const defaultSizeHistoryRange = 30 * 24 * time.Hour
+
+// sizeHistory is a database's size over time
+type sizeHistory struct {
+	DatabaseID string               `json:"database_id"`
+	From       time.Time            `json:"from"`
+	To         time.Time            `json:"to"`
+	Samples    []scanner.ScanSample `json:"samples"`
+}
+
+// GetSizeHistory handles database size history retrieval
+// @Summary Get database size history
+// @Description Returns what each scan of a discovered database found (size, table and index counts) in a time range, oldest first, for trend charts
+// @Tags databases
+// @Produce json
+// @Param id path string true "Database ID"
+// @Param from query string false "Start of the range, RFC 3339; defaults to 30 days before the end"
+// @Param to query string false "End of the range, RFC 3339; defaults to now"
+// @Success 200 {object} sizeHistory "Size history"
+// @Failure 400 {object} map[string]interface{} "Invalid time range"
+// @Failure 501 {object} map[string]interface{} "Scan history not available"
+// @Router /api/v1/databases/{id}/size-history [get]
+func (h *DatabaseHandler) GetSizeHistory(w http.ResponseWriter, r *http.Request) {
+	if h.scanHistory == nil {
+		http.Error(w, "scan history is not available", http.StatusNotImplemented)
+		return
+	}
+
+	to := time.Now()
+	if value := r.URL.Query().Get("to"); value != "" {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			http.Error(w, "to must be an RFC 3339 time", http.StatusBadRequest)
+			return
+		}
+		to = parsed
+	}
+	from := to.Add(-defaultSizeHistoryRange)
+	if value := r.URL.Query().Get("from"); value != "" {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			http.Error(w, "from must be an RFC 3339 time", http.StatusBadRequest)
+			return
+		}
+		from = parsed
+	}
+	if from.After(to) {
+		http.Error(w, "from must not be after to", http.StatusBadRequest)
+		return
+	}
+
+	dbID := mux.Vars(r)["id"]
+	samples, err := h.scanHistory.Query(r.Context(), dbID, from, to)
+	if err != nil {
+		h.logger.Error("failed to query scan history", zap.String("database_id", dbID), zap.Error(err))
+		http.Error(w, "failed to query scan history", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sizeHistory{DatabaseID: dbID, From: from, To: to, Samples: samples})
+}
+
+// templateList is the v2 shape of the template listing
+type templateList struct {
+	Templates []database.DatabaseTemplate `json:"templates"`
+	Default   string                      `json:"default"`
 }
 
 // ListTemplates handles template listing
 // @Summary List available database templates
-// @Description Returns all available database templates (starter, production, enterprise)
+// @Description Returns all available database templates: the built-in starter, production and enterprise templates and registered custom templates. API version v1 returns the templates as an array; v2 wraps them in an object naming the default template.
 // @Tags databases
 // @Accept json
 // @Produce json
+// @Param X-API-Version header string false "Payload version: v1 (default) or v2"
 // @Success 200 {array} database.DatabaseTemplate "List of templates"
 // @Router /api/v1/databases/templates [get]
 func (h *DatabaseHandler) ListTemplates(w http.ResponseWriter, r *http.Request) {
 	templates := database.ListTemplates()
+	defaultTemplate := database.DefaultTemplate
+	if registry := h.dbService.Templates(); registry != nil {
+		templates = registry.List()
+		defaultTemplate = registry.Default()
+	}
 
 	w.Header().Set("Content-Type", "application/json")
+	if middleware.APIVersionFromContext(r.Context()) == middleware.APIVersion2 {
+		json.NewEncoder(w).Encode(templateList{Templates: templates, Default: defaultTemplate})
+		return
+	}
 	json.NewEncoder(w).Encode(templates)
 }
 
+// RegisterTemplate handles custom template registration
+// @Summary Register a database template
+// @Description Registers a custom database template with resource, storage, image and backup defaults, persisted in the catalog. A template named like a built-in one overrides it. Databases select it by name in their template field.
+// @Tags databases
+// @Accept json
+// @Produce json
+// @Param request body database.DatabaseTemplate true "Template"
+// @Success 201 {object} database.DatabaseTemplate "Registered template"
+// @Failure 400 {object} map[string]interface{} "Invalid template"
+// @Failure 501 {object} map[string]interface{} "Custom templates not supported"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /api/v1/databases/templates [post]
+func (h *DatabaseHandler) RegisterTemplate(w http.ResponseWriter, r *http.Request) {
+	registry := h.dbService.Templates()
+	if registry == nil {
+		http.Error(w, "custom templates are not supported", http.StatusNotImplemented)
+		return
+	}
+
+	var req database.DatabaseTemplate
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	template, err := registry.Register(r.Context(), req)
+	if err != nil {
+		if errors.Is(err, database.ErrInvalidTemplate) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		h.logger.Error("failed to register database template", zap.String("name", req.Name), zap.Error(err))
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(template)
+}
+
 // GetDatabaseStatus handles database status retrieval
 // @Summary Get database status
 // @Description Returns the current status of a database including shard information (checks both manually created and discovered databases)
@@ -367,7 +552,7 @@ func (h *DatabaseHandler) GetDatabaseStatus(w http.ResponseWriter, r *http.Reque
 	dbID := vars["id"]
 
 	// First check manually created databases
-	db, ok := h.databases[dbID]
+	db, ok := h.createdDatabase(dbID)
 	if !ok {
 		// Check discovered databases from scan results
 		h.scanResultsMu.RLock()
@@ -392,6 +577,18 @@ func (h *DatabaseHandler) GetDatabaseStatus(w http.ResponseWriter, r *http.Reque
 		"created_at":        db.CreatedAt,
 		"updated_at":        db.UpdatedAt,
 	}
+	if h.backupStatuses != nil {
+		// Shards never backed up are listed without a last backup
+		backups := make([]backup.ShardBackupStatus, 0, len(db.ShardIDs))
+		for _, shardID := range db.ShardIDs {
+			shardBackup, ok := h.backupStatuses.ShardBackupStatus(shardID)
+			if !ok {
+				shardBackup = backup.ShardBackupStatus{ShardID: shardID, DatabaseID: db.ID}
+			}
+			backups = append(backups, shardBackup)
+		}
+		status["shard_backups"] = backups
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(status)
@@ -419,7 +616,9 @@ func (h *DatabaseHandler) GetDatabaseStats(w http.ResponseWriter, r *http.Reques
 	}
 
 	// Count manually created databases
-	for _, db := range h.databases {
+	created := make(map[string]bool)
+	for _, db := range h.createdDatabases() {
+		created[db.ID] = true
 		stats.TotalDatabases++
 		stats.ByStatus[db.Status]++
 		// Manually created databases are usually postgresql by default in this system
@@ -438,7 +637,7 @@ func (h *DatabaseHandler) GetDatabaseStats(w http.ResponseWriter, r *http.Reques
 	h.scanResultsMu.RLock()
 	for _, db := range h.scanResults {
 		// Avoid double counting if ID exists in both (though they shouldn't usually)
-		if _, exists := h.databases[db.ID]; !exists {
+		if !created[db.ID] {
 			stats.TotalDatabases++
 			stats.ByStatus[db.Status]++
 			stats.ByType[db.DatabaseType]++
@@ -458,5 +657,14 @@ func SetupDatabaseRoutes(router *mux.Router, handler *DatabaseHandler) {
 	router.HandleFunc("/api/v1/databases/templates", handler.ListTemplates).Methods("GET", "OPTIONS")
 	router.HandleFunc("/api/v1/databases/stats", handler.GetDatabaseStats).Methods("GET", "OPTIONS")
 	router.HandleFunc("/api/v1/databases/{id}/status", handler.GetDatabaseStatus).Methods("GET", "OPTIONS")
+	router.HandleFunc("/api/v1/databases/{id}/size-history", handler.GetSizeHistory).Methods("GET", "OPTIONS")
 	router.HandleFunc("/api/v1/databases/{id}", handler.GetDatabase).Methods("GET", "OPTIONS")
 }
+
+// SetupProtectedDatabaseRoutes sets up database routes that need
+// authentication. A registered template applies to every database created
+// from it, so registering one needs the templates create permission.
+func SetupProtectedDatabaseRoutes(router *mux.Router, handler *DatabaseHandler) {
+	router.Handle("/api/v1/databases/templates",
+		middleware.RequirePermission(handler.rbac, "templates", "create")(http.HandlerFunc(handler.RegisterTemplate))).Methods("POST", "OPTIONS")
+}
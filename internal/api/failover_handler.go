@@ -1,11 +1,15 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 
 	"github.com/gorilla/mux"
+	"github.com/sharding-system/internal/middleware"
 	"github.com/sharding-system/pkg/failover"
+	"github.com/sharding-system/pkg/security"
 	"go.uber.org/zap"
 )
 
@@ -13,6 +17,7 @@ import (
 type FailoverHandler struct {
 	failoverCtrl *failover.FailoverController
 	logger       *zap.Logger
+	rbac         *security.RBAC
 }
 
 // NewFailoverHandler creates a new failover handler
@@ -20,6 +25,7 @@ func NewFailoverHandler(failoverCtrl *failover.FailoverController, logger *zap.L
 	return &FailoverHandler{
 		failoverCtrl: failoverCtrl,
 		logger:       logger,
+		rbac:         security.NewRBAC(),
 	}
 }
 
@@ -100,6 +106,55 @@ func (h *FailoverHandler) GetFailoverHistory(w http.ResponseWriter, r *http.Requ
 	json.NewEncoder(w).Encode(history)
 }
 
+// PlannedFailover handles planned failover requests
+// @Summary Perform a planned failover
+// @Description Gracefully switches a shard's primary to one of its replicas, e.g. ahead of maintenance: writes are drained from the primary, which is demoted before the replica is promoted and replication reconfigured. Without a replica, one is picked as an emergency failover would. The failover is recorded in the history with type planned.
+// @Tags failover
+// @Accept json
+// @Produce json
+// @Param request body failover.PlannedFailoverRequest true "Planned failover"
+// @Success 200 {object} failover.FailoverEvent "Failover completed, possibly without reconfiguring every replica"
+// @Failure 400 {object} map[string]interface{} "Invalid request or replica"
+// @Failure 404 {object} map[string]interface{} "Shard not found"
+// @Failure 409 {object} map[string]interface{} "Failover already in progress"
+// @Failure 500 {object} failover.FailoverEvent "Failover failed"
+// @Failure 501 {object} map[string]interface{} "Planned failover not supported"
+// @Router /api/v1/failover/planned [post]
+func (h *FailoverHandler) PlannedFailover(w http.ResponseWriter, r *http.Request) {
+	var req failover.PlannedFailoverRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.ShardID == "" {
+		http.Error(w, "shard_id is required", http.StatusBadRequest)
+		return
+	}
+
+	// The switchover must not stop halfway because the client went away
+	event, err := h.failoverCtrl.PlannedFailover(context.WithoutCancel(r.Context()), req)
+	if event == nil && err != nil {
+		status := http.StatusNotFound
+		switch {
+		case errors.Is(err, failover.ErrSwitchoverUnsupported):
+			status = http.StatusNotImplemented
+		case errors.Is(err, failover.ErrFailoverInProgress):
+			status = http.StatusConflict
+		case errors.Is(err, failover.ErrInvalidReplica):
+			status = http.StatusBadRequest
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err != nil {
+		h.logger.Error("planned failover failed", zap.String("shard_id", req.ShardID), zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+	json.NewEncoder(w).Encode(event)
+}
+
 // SetupFailoverRoutes sets up failover management routes
 func SetupFailoverRoutes(router *mux.Router, handler *FailoverHandler) {
 	router.HandleFunc("/api/v1/failover/status", handler.GetFailoverStatus).Methods("GET", "OPTIONS")
@@ -108,3 +163,9 @@ func SetupFailoverRoutes(router *mux.Router, handler *FailoverHandler) {
 	router.HandleFunc("/api/v1/failover/history", handler.GetFailoverHistory).Methods("GET", "OPTIONS")
 }
 
+// SetupProtectedFailoverRoutes sets up the failover routes that require authentication
+func SetupProtectedFailoverRoutes(router *mux.Router, handler *FailoverHandler) {
+	router.Handle("/api/v1/failover/planned",
+		middleware.RequirePermission(handler.rbac, "failover", "switchover")(http.HandlerFunc(handler.PlannedFailover))).Methods("POST", "OPTIONS")
+}
+
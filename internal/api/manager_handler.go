@@ -1,16 +1,24 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/sharding-system/internal/middleware"
 	"github.com/sharding-system/pkg/discovery"
 	"github.com/sharding-system/pkg/manager"
 	"github.com/sharding-system/pkg/models"
 	"github.com/sharding-system/pkg/monitoring"
 	"github.com/sharding-system/pkg/pricing"
+	"github.com/sharding-system/pkg/scanner"
+	"github.com/sharding-system/pkg/security"
 	"go.uber.org/zap"
 )
 
@@ -25,20 +33,42 @@ import (
 // @host localhost:8081
 // @BasePath /api/v1
 
+const (
+	// targetReadyInterval is how often the targets of a resharding job are
+	// checked for cutover, to register them for metrics collection
+	targetReadyInterval = 5 * time.Second
+	// targetReadyTimeout bounds the wait, for jobs that never finish
+	targetReadyTimeout = 24 * time.Hour
+)
+
 // ManagerHandler handles HTTP requests for the manager
 type ManagerHandler struct {
 	manager              *manager.Manager
 	logger               *zap.Logger
 	prometheusCollector  *monitoring.PrometheusCollector
 	postgresStatsCollector *monitoring.PostgresStatsCollector
+	schemaScanner          *scanner.LegacyDatabaseScanner
+	discoveryCache         *discovery.Cache
+	discoveryFilter        *discovery.Filter
+	rbac                   *security.RBAC
 }
 
 // NewManagerHandler creates a new manager handler
 func NewManagerHandler(m *manager.Manager, logger *zap.Logger) *ManagerHandler {
-	return &ManagerHandler{
-		manager: m,
-		logger:  logger,
+	h := &ManagerHandler{
+		manager:       m,
+		logger:        logger,
+		schemaScanner: scanner.NewLegacyDatabaseScanner(logger),
+		rbac:          security.NewRBAC(),
 	}
+	h.discoveryCache = discovery.NewCache(h.scanApplications, discovery.DefaultCacheTTL)
+	h.discoveryFilter, _ = discovery.NewFilter(discovery.FilterStrict, logger)
+	return h
+}
+
+// SetDiscoveryFilter sets which discovered apps are surfaced by discovery
+func (h *ManagerHandler) SetDiscoveryFilter(filter *discovery.Filter) {
+	h.discoveryFilter = filter
 }
 
 // SetPrometheusCollector sets the Prometheus collector for metrics registration
@@ -60,6 +90,7 @@ func (h *ManagerHandler) SetPostgresStatsCollector(psc *monitoring.PostgresStats
 // @Param request body models.CreateShardRequest true "Shard Configuration (must include client_app_id)"
 // @Success 201 {object} models.Shard "Shard created successfully"
 // @Failure 400 {object} map[string]interface{} "Bad request"
+// @Failure 409 {object} map[string]interface{} "Client application is over its storage quota"
 // @Failure 500 {object} map[string]interface{} "Internal server error"
 // @Router /shards [post]
 func (h *ManagerHandler) CreateShard(w http.ResponseWriter, r *http.Request) {
@@ -76,6 +107,10 @@ func (h *ManagerHandler) CreateShard(w http.ResponseWriter, r *http.Request) {
 
 	shard, err := h.manager.CreateShard(r.Context(), &req)
 	if err != nil {
+		if errors.Is(err, manager.ErrStorageQuotaExceeded) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
 		h.logger.Error("failed to create shard", zap.Error(err))
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -85,7 +120,7 @@ func (h *ManagerHandler) CreateShard(w http.ResponseWriter, r *http.Request) {
 	if h.prometheusCollector != nil && shard.Status == "active" {
 		dsn := buildDSNFromShard(shard)
 		if dsn != "" {
-			if err := h.prometheusCollector.RegisterShard(shard.ID, dsn); err != nil {
+			if err := h.prometheusCollector.RegisterShardWithEngine(shard.ID, shard.Engine, dsn); err != nil {
 				h.logger.Warn("failed to register shard for metrics collection",
 					zap.String("shard_id", shard.ID),
 					zap.Error(err))
@@ -100,7 +135,7 @@ func (h *ManagerHandler) CreateShard(w http.ResponseWriter, r *http.Request) {
 	if h.postgresStatsCollector != nil && shard.Status == "active" {
 		dsn := buildDSNFromShard(shard)
 		if dsn != "" {
-			if err := h.postgresStatsCollector.RegisterDatabase(shard.ID, dsn); err != nil {
+			if err := h.postgresStatsCollector.RegisterDatabaseWithEngine(shard.ID, shard.Engine, dsn); err != nil {
 				h.logger.Warn("failed to register shard with PostgreSQL stats collector",
 					zap.String("shard_id", shard.ID),
 					zap.Error(err))
@@ -229,31 +264,41 @@ func (h *ManagerHandler) SplitShard(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Register target shards for metrics collection
-	if h.prometheusCollector != nil {
-		for _, targetShardID := range job.TargetShards {
-			shard, err := h.manager.GetShard(targetShardID)
-			if err == nil {
-				dsn := buildDSNFromShard(shard)
-				if dsn != "" {
-					if err := h.prometheusCollector.RegisterShard(targetShardID, dsn); err != nil {
-						h.logger.Warn("failed to register target shard for metrics after split",
-							zap.String("shard_id", targetShardID),
-							zap.Error(err))
-					} else {
-						h.logger.Info("registered target shard for metrics after split",
-							zap.String("shard_id", targetShardID))
-					}
-				}
-			}
-		}
-	}
+	// Register target shards for metrics collection once the split cuts over to them
+	h.registerTargetsWhenReady(job)
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusAccepted)
 	json.NewEncoder(w).Encode(job)
 }
 
+// AnalyzeSplit handles split dry-run requests
+// @Summary Analyze the impact of a split
+// @Description Reports what a split would do without creating shards or starting a job: the rows and bytes to copy, each target's key range and expected share, and the estimated copy time from copy_rows_per_second or the last completed resharding job. Warns if the split would start outside, or run past the end of, the given maintenance window.
+// @Tags resharding
+// @Accept json
+// @Produce json
+// @Param request body models.SplitImpactRequest true "Split Impact Request"
+// @Success 200 {object} models.SplitImpact "Estimated impact of the split"
+// @Failure 400 {object} map[string]interface{} "Bad request"
+// @Router /reshard/split/dry-run [post]
+func (h *ManagerHandler) AnalyzeSplit(w http.ResponseWriter, r *http.Request) {
+	var req models.SplitImpactRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	impact, err := h.manager.AnalyzeSplit(&req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(impact)
+}
+
 // MergeShards handles merge operation requests
 // @Summary Merge shards
 // @Description Merges multiple source shards into a target shard
@@ -279,25 +324,42 @@ func (h *ManagerHandler) MergeShards(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Register target shard for metrics collection
-	if h.prometheusCollector != nil && len(job.TargetShards) > 0 {
-		targetShardID := job.TargetShards[0]
-		shard, err := h.manager.GetShard(targetShardID)
-		if err == nil {
-			dsn := buildDSNFromShard(shard)
-			if dsn != "" {
-				if err := h.prometheusCollector.RegisterShard(targetShardID, dsn); err != nil {
-					h.logger.Warn("failed to register target shard for metrics after merge",
-						zap.String("shard_id", targetShardID),
-						zap.Error(err))
-				} else {
-					h.logger.Info("registered target shard for metrics after merge",
-						zap.String("shard_id", targetShardID))
-				}
-			}
-		}
+	// Register target shard for metrics collection once the merge cuts over to it
+	h.registerTargetsWhenReady(job)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(job)
+}
+
+// RekeyClientApp handles shard key change requests
+// @Summary Change a client app's shard key
+// @Description Creates the target shards as a new key space placed by shard_key, copies every row of the app's active shards onto them, verifies the copy and cuts over, taking the old shards out of routing. Track progress with the returned job.
+// @Tags resharding
+// @Accept json
+// @Produce json
+// @Param request body models.RekeyRequest true "Rekey Request"
+// @Success 202 {object} models.ReshardJob "Rekey job started"
+// @Failure 400 {object} map[string]interface{} "Bad request"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /reshard/rekey [post]
+func (h *ManagerHandler) RekeyClientApp(w http.ResponseWriter, r *http.Request) {
+	var req models.RekeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
 
+	job, err := h.manager.RekeyClientApp(r.Context(), &req)
+	if err != nil {
+		h.logger.Error("failed to start rekey", zap.Error(err))
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Register target shards for metrics collection once the rekey cuts over to them
+	h.registerTargetsWhenReady(job)
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusAccepted)
 	json.NewEncoder(w).Encode(job)
@@ -359,9 +421,126 @@ func (h *ManagerHandler) PromoteReplica(w http.ResponseWriter, r *http.Request)
 	json.NewEncoder(w).Encode(map[string]string{"status": "promoted"})
 }
 
+// MoveShard handles shard move requests
+// @Summary Move a shard to another host
+// @Description Copies a shard to a new host, switches routing to it and decommissions the source. The shard keeps its ID and key range.
+// @Tags shards
+// @Accept json
+// @Produce json
+// @Param id path string true "Shard ID"
+// @Param request body models.MoveShardRequest true "Move Request"
+// @Success 202 {object} models.ReshardJob "Move job started"
+// @Failure 400 {object} map[string]interface{} "Bad request"
+// @Router /shards/{id}/move [post]
+func (h *ManagerHandler) MoveShard(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	shardID := vars["id"]
+
+	var req models.MoveShardRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	job, err := h.manager.MoveShard(r.Context(), shardID, &req)
+	if err != nil {
+		h.logger.Error("failed to start shard move", zap.String("shard_id", shardID), zap.Error(err))
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(job)
+}
+
+// SchemaDiff handles schema comparison between two shards
+// @Summary Compare the schemas of two shards
+// @Description Scans both shards and reports tables, columns, indexes and constraints added, removed or changed in the second shard relative to the first
+// @Tags shards
+// @Produce json
+// @Param id path string true "Source shard ID"
+// @Param other path string true "Target shard ID"
+// @Success 200 {object} scanner.SchemaDiff "Schema differences"
+// @Failure 404 {object} map[string]interface{} "Shard not found"
+// @Failure 502 {object} map[string]interface{} "Failed to scan shard"
+// @Router /shards/{id}/schema-diff/{other} [get]
+func (h *ManagerHandler) SchemaDiff(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	source, ok := h.scanShardSchema(w, r, vars["id"])
+	if !ok {
+		return
+	}
+	target, ok := h.scanShardSchema(w, r, vars["other"])
+	if !ok {
+		return
+	}
+
+	diff := scanner.DiffSchemas(source, target)
+	diff.Source = vars["id"]
+	diff.Target = vars["other"]
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(diff)
+}
+
+// DesiredSchemaDiff handles schema comparison between a shard and a desired schema
+// @Summary Compare a shard's schema with a desired schema
+// @Description Scans the shard and reports what differs in the desired table definitions relative to it
+// @Tags shards
+// @Accept json
+// @Produce json
+// @Param id path string true "Shard ID"
+// @Param request body object true "Desired schema" example({"tables": []})
+// @Success 200 {object} scanner.SchemaDiff "Schema differences"
+// @Failure 400 {object} map[string]interface{} "Bad request"
+// @Failure 404 {object} map[string]interface{} "Shard not found"
+// @Router /shards/{id}/schema-diff [post]
+func (h *ManagerHandler) DesiredSchemaDiff(w http.ResponseWriter, r *http.Request) {
+	shardID := mux.Vars(r)["id"]
+
+	var req struct {
+		Tables []scanner.TableInfo `json:"tables"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	source, ok := h.scanShardSchema(w, r, shardID)
+	if !ok {
+		return
+	}
+
+	diff := scanner.DiffSchemas(source, req.Tables)
+	diff.Source = shardID
+	diff.Target = "desired"
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(diff)
+}
+
+// scanShardSchema scans a shard's primary, writing an error response on failure
+func (h *ManagerHandler) scanShardSchema(w http.ResponseWriter, r *http.Request, shardID string) ([]scanner.TableInfo, bool) {
+	shard, err := h.manager.GetShard(shardID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return nil, false
+	}
+
+	tables, err := h.schemaScanner.ScanSchema(r.Context(), shard.PrimaryEndpoint)
+	if err != nil {
+		h.logger.Error("failed to scan shard schema", zap.String("shard_id", shardID), zap.Error(err))
+		http.Error(w, fmt.Sprintf("failed to scan shard %s: %v", shardID, err), http.StatusBadGateway)
+		return nil, false
+	}
+	return tables, true
+}
+
 // UpdateShardStatus handles shard status update requests
 // @Summary Update shard status
-// @Description Updates the status of a shard (e.g., to inactive)
+// @Description Moves a shard to another lifecycle status (active, inactive, readonly, draining or failed). Transitions the lifecycle does not allow are rejected.
 // @Tags shards
 // @Accept json
 // @Produce json
@@ -369,6 +548,7 @@ func (h *ManagerHandler) PromoteReplica(w http.ResponseWriter, r *http.Request)
 // @Param request body object true "Status Update Request" example({"status": "inactive"})
 // @Success 200 {object} map[string]string "Status updated successfully"
 // @Failure 400 {object} map[string]interface{} "Bad request"
+// @Failure 409 {object} map[string]interface{} "Transition not allowed"
 // @Router /shards/{id}/status [put]
 func (h *ManagerHandler) UpdateShardStatus(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
@@ -382,8 +562,11 @@ func (h *ManagerHandler) UpdateShardStatus(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	if req.Status != "active" && req.Status != "inactive" {
-		http.Error(w, "invalid status: must be 'active' or 'inactive'", http.StatusBadRequest)
+	// Provisioning, migrating and deleting are entered by the operations that own them
+	switch req.Status {
+	case models.ShardStatusActive, models.ShardStatusInactive, models.ShardStatusReadOnly, models.ShardStatusDraining, models.ShardStatusFailed:
+	default:
+		http.Error(w, "invalid status: must be 'active', 'inactive', 'readonly', 'draining' or 'failed'", http.StatusBadRequest)
 		return
 	}
 
@@ -394,7 +577,11 @@ func (h *ManagerHandler) UpdateShardStatus(w http.ResponseWriter, r *http.Reques
 	}
 
 	if err := h.manager.UpdateShardStatus(shardID, req.Status); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		status := http.StatusInternalServerError
+		if models.IsInvalidShardTransition(err) {
+			status = http.StatusConflict
+		}
+		http.Error(w, err.Error(), status)
 		return
 	}
 
@@ -402,18 +589,18 @@ func (h *ManagerHandler) UpdateShardStatus(w http.ResponseWriter, r *http.Reques
 	if h.prometheusCollector != nil {
 		shard, err := h.manager.GetShard(shardID)
 		if err == nil {
-			if req.Status == "active" {
-				// Register for metrics if becoming active
+			if shardServesTraffic(req.Status) {
+				// Register for metrics while the shard still serves traffic
 				dsn := buildDSNFromShard(shard)
 				if dsn != "" {
-					if err := h.prometheusCollector.RegisterShard(shardID, dsn); err != nil {
+					if err := h.prometheusCollector.RegisterShardWithEngine(shardID, shard.Engine, dsn); err != nil {
 						h.logger.Warn("failed to register shard for metrics after status update",
 							zap.String("shard_id", shardID),
 							zap.Error(err))
 					}
 				}
 			} else {
-				// Unregister if becoming inactive
+				// Unregister once the shard is out of service
 				h.prometheusCollector.UnregisterShard(shardID)
 				h.logger.Info("unregistered shard from metrics collection",
 					zap.String("shard_id", shardID))
@@ -425,11 +612,11 @@ func (h *ManagerHandler) UpdateShardStatus(w http.ResponseWriter, r *http.Reques
 	if h.postgresStatsCollector != nil {
 		shard, err := h.manager.GetShard(shardID)
 		if err == nil {
-			if req.Status == "active" {
-				// Register for stats if becoming active
+			if shardServesTraffic(req.Status) {
+				// Register for stats while the shard still serves traffic
 				dsn := buildDSNFromShard(shard)
 				if dsn != "" {
-					if err := h.postgresStatsCollector.RegisterDatabase(shardID, dsn); err != nil {
+					if err := h.postgresStatsCollector.RegisterDatabaseWithEngine(shardID, shard.Engine, dsn); err != nil {
 						h.logger.Warn("failed to register shard with PostgreSQL stats collector after status update",
 							zap.String("shard_id", shardID),
 							zap.Error(err))
@@ -439,7 +626,7 @@ func (h *ManagerHandler) UpdateShardStatus(w http.ResponseWriter, r *http.Reques
 					}
 				}
 			} else {
-				// Unregister if becoming inactive
+				// Unregister once the shard is out of service
 				h.postgresStatsCollector.UnregisterDatabase(shardID)
 				h.logger.Info("unregistered shard from PostgreSQL stats collection",
 					zap.String("shard_id", shardID))
@@ -451,6 +638,76 @@ func (h *ManagerHandler) UpdateShardStatus(w http.ResponseWriter, r *http.Reques
 	json.NewEncoder(w).Encode(map[string]string{"status": "updated"})
 }
 
+// GetShardEvents returns a shard's timeline
+// @Summary Get shard events
+// @Description Returns what happened to a shard, such as its creation, status changes, splits, merges, moves, promotions and failovers, oldest first. Pass next_since back as since to continue reading. Deleted shards keep their timeline.
+// @Tags shards
+// @Produce json
+// @Param id path string true "Shard ID"
+// @Param since query int false "Return events after this sequence number" default(0)
+// @Param limit query int false "Maximum number of events to return" default(100)
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {string} string "Bad request"
+// @Failure 404 {string} string "Shard not found"
+// @Failure 500 {string} string "Internal server error"
+// @Router /shards/{id}/events [get]
+func (h *ManagerHandler) GetShardEvents(w http.ResponseWriter, r *http.Request) {
+	shardID := mux.Vars(r)["id"]
+
+	var since int64
+	if v := r.URL.Query().Get("since"); v != "" {
+		parsed, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || parsed < 0 {
+			http.Error(w, "since must be a non-negative integer", http.StatusBadRequest)
+			return
+		}
+		since = parsed
+	}
+
+	limit := defaultEventPageSize
+	if v := r.URL.Query().Get("limit"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil || parsed <= 0 {
+			http.Error(w, "limit must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		if parsed > maxEventPageSize {
+			parsed = maxEventPageSize
+		}
+		limit = parsed
+	}
+
+	events, err := h.manager.ShardEvents(r.Context(), shardID, since, limit)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, manager.ErrShardNotFound) {
+			status = http.StatusNotFound
+		} else {
+			h.logger.Error("failed to read shard events", zap.String("shard_id", shardID), zap.Error(err))
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	nextSince := since
+	if len(events) > 0 {
+		nextSince = events[len(events)-1].Seq
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"shard_id":   shardID,
+		"events":     events,
+		"next_since": nextSince,
+	})
+}
+
+// shardServesTraffic reports whether a shard in status still takes queries,
+// and so should keep being monitored
+func shardServesTraffic(status string) bool {
+	return status != models.ShardStatusInactive && status != models.ShardStatusFailed
+}
+
 // GetPricing handles pricing info requests
 // @Summary Get pricing plan
 // @Description Retrieves the current pricing plan and limits
@@ -524,6 +781,64 @@ func (h *ManagerHandler) GetClientApp(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(app)
 }
 
+// GetShardMap returns a client application's shard map, optionally long-polling for changes
+// @Summary Get client application shard map
+// @Description Returns the shard map for smart clients to cache. Pass the last seen version (via the version query parameter or If-None-Match) together with wait to block until the map changes or the wait elapses. Returns 304 if the map is unchanged.
+// @Tags client-apps
+// @Produce json
+// @Param id path string true "Client Application ID"
+// @Param version query string false "Last shard map version seen by the client"
+// @Param wait query string false "Maximum time to wait for a change, e.g. 30s (capped at 25s)"
+// @Success 200 {object} manager.ShardMap "Current shard map"
+// @Success 304 "Shard map unchanged"
+// @Failure 400 {object} map[string]interface{} "Invalid wait duration"
+// @Failure 404 {object} map[string]interface{} "Client application not found"
+// @Router /client-apps/{id}/shard-map [get]
+func (h *ManagerHandler) GetShardMap(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	appID := vars["id"]
+
+	if _, err := h.manager.GetClientAppManager().GetClientApp(appID); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	knownVersion := r.URL.Query().Get("version")
+	if knownVersion == "" {
+		knownVersion = strings.Trim(r.Header.Get("If-None-Match"), `"`)
+	}
+
+	var wait time.Duration
+	if waitStr := r.URL.Query().Get("wait"); waitStr != "" {
+		var err error
+		wait, err = time.ParseDuration(waitStr)
+		if err != nil || wait < 0 {
+			http.Error(w, fmt.Sprintf("invalid wait duration: %s", waitStr), http.StatusBadRequest)
+			return
+		}
+	}
+
+	shardMap, changed, err := h.manager.GetShardMap(r.Context(), appID, knownVersion, wait)
+	if err != nil {
+		if r.Context().Err() != nil {
+			// Client went away while waiting
+			return
+		}
+		h.logger.Error("failed to get shard map", zap.String("client_app_id", appID), zap.Error(err))
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("ETag", fmt.Sprintf("%q", shardMap.Version))
+	if !changed {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(shardMap)
+}
+
 // CreateClientAppRequest represents a request to create a client application
 type CreateClientAppRequest struct {
 	Name             string `json:"name"`
@@ -536,6 +851,8 @@ type CreateClientAppRequest struct {
 	KeyPrefix        string `json:"key_prefix,omitempty"`
 	Namespace        string `json:"namespace,omitempty"`    // Kubernetes namespace
 	ClusterName      string `json:"cluster_name,omitempty"` // Kubernetes cluster name
+	// Defaults for shards created for this app; each shard may override them
+	ShardingDefaults *manager.ShardingDefaults `json:"sharding_defaults,omitempty"`
 }
 
 // CreateClientApp handles client application creation requests
@@ -561,14 +878,37 @@ func (h *ManagerHandler) CreateClientApp(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	if req.ShardingDefaults != nil {
+		if err := req.ShardingDefaults.Validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
 	clientAppMgr := h.manager.GetClientAppManager()
 	app, err := clientAppMgr.RegisterClientApp(r.Context(), req.Name, req.Description, req.DatabaseName, req.DatabaseHost, req.DatabasePort, req.DatabaseUser, req.DatabasePassword, req.KeyPrefix, req.Namespace, req.ClusterName)
+	if errors.Is(err, manager.ErrNamespaceNotFound) || errors.Is(err, manager.ErrAppNotInNamespace) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	if err != nil {
 		h.logger.Error("failed to create client app", zap.Error(err))
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	if req.ShardingDefaults != nil {
+		if err := clientAppMgr.SetShardingDefaults(app.ID, *req.ShardingDefaults); err != nil {
+			h.logger.Error("failed to set sharding defaults", zap.String("id", app.ID), zap.Error(err))
+			// Don't leave the app registered without the defaults it asked for
+			if delErr := clientAppMgr.DeleteClientApp(app.ID); delErr != nil {
+				h.logger.Error("failed to roll back client app registration", zap.String("id", app.ID), zap.Error(delErr))
+			}
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(app)
@@ -576,21 +916,46 @@ func (h *ManagerHandler) CreateClientApp(w http.ResponseWriter, r *http.Request)
 
 // DeleteClientApp handles client application deletion requests
 // @Summary Delete a client application
-// @Description De-registers a client application from the sharding system
+// @Description De-registers a client application from the sharding system. The deletion is refused while the application owns shards unless cascade is set, in which case its shards are drained, deleted and removed from metrics collection first.
 // @Tags client-apps
 // @Accept json
 // @Produce json
 // @Param id path string true "Client Application ID"
+// @Param cascade query bool false "Delete the application's shards as well"
 // @Success 204 "Client application deleted successfully"
 // @Failure 400 {object} map[string]interface{} "Bad request"
+// @Failure 403 {object} map[string]interface{} "Insufficient permissions"
 // @Failure 404 {object} map[string]interface{} "Client application not found"
+// @Failure 409 {object} map[string]interface{} "Client application still owns shards"
 // @Router /client-apps/{id} [delete]
 func (h *ManagerHandler) DeleteClientApp(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	appID := vars["id"]
 
-	clientAppMgr := h.manager.GetClientAppManager()
-	if err := clientAppMgr.DeleteClientApp(appID); err != nil {
+	cascade := false
+	if raw := r.URL.Query().Get("cascade"); raw != "" {
+		var err error
+		if cascade, err = strconv.ParseBool(raw); err != nil {
+			http.Error(w, fmt.Sprintf("invalid cascade value: %s", raw), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if _, err := h.manager.GetClientAppManager().GetClientApp(appID); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	deleted, err := h.manager.DeleteClientApp(appID, cascade)
+	// Shards deleted before a failure are gone from the catalog either way
+	for _, shardID := range deleted {
+		h.unregisterShard(shardID)
+	}
+	if err != nil {
+		if errors.Is(err, manager.ErrClientAppHasShards) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
@@ -598,17 +963,167 @@ func (h *ManagerHandler) DeleteClientApp(w http.ResponseWriter, r *http.Request)
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// registerTargetsWhenReady registers the target shards of a resharding job for
+// metrics and stats collection in the background, each once the job has cut
+// over to it. Targets are not registered straight away: until cutover they
+// take no traffic and may not be reachable.
+func (h *ManagerHandler) registerTargetsWhenReady(job *models.ReshardJob) {
+	if h.prometheusCollector == nil && h.postgresStatsCollector == nil {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), targetReadyTimeout)
+		defer cancel()
+		err := h.manager.WhenTargetsReady(ctx, job.ID, targetReadyInterval, func(shard *models.Shard) error {
+			if err := h.registerShard(shard); err != nil {
+				return err
+			}
+			h.logger.Info("registered target shard for metrics after "+job.Type,
+				zap.String("job_id", job.ID),
+				zap.String("shard_id", shard.ID))
+			return nil
+		})
+		if err != nil {
+			h.logger.Warn("stopped waiting to register target shards",
+				zap.String("job_id", job.ID),
+				zap.Error(err))
+		}
+	}()
+}
+
+// registerShard starts metrics and stats collection for a shard. Shards
+// without connection details are skipped.
+func (h *ManagerHandler) registerShard(shard *models.Shard) error {
+	dsn := buildDSNFromShard(shard)
+	if dsn == "" {
+		return nil
+	}
+	if h.prometheusCollector != nil {
+		if err := h.prometheusCollector.RegisterShardWithEngine(shard.ID, shard.Engine, dsn); err != nil {
+			return fmt.Errorf("failed to register shard for metrics collection: %w", err)
+		}
+	}
+	if h.postgresStatsCollector != nil {
+		if err := h.postgresStatsCollector.RegisterDatabaseWithEngine(shard.ID, shard.Engine, dsn); err != nil {
+			return fmt.Errorf("failed to register shard with PostgreSQL stats collector: %w", err)
+		}
+	}
+	return nil
+}
+
+// unregisterShard stops metrics and stats collection for a deleted shard
+func (h *ManagerHandler) unregisterShard(shardID string) {
+	if h.prometheusCollector != nil {
+		h.prometheusCollector.UnregisterShard(shardID)
+	}
+	if h.postgresStatsCollector != nil {
+		h.postgresStatsCollector.UnregisterDatabase(shardID)
+	}
+	h.logger.Info("unregistered shard from metrics collection",
+		zap.String("shard_id", shardID))
+}
+
+// GetClientAppLimits handles client application usage reports
+// @Summary Get client application usage against its limits
+// @Description Reports a client application's shards, connections and storage alongside its tier's limits and the percentage of each limit used. Connections and storage come from the latest collected stats of each shard.
+// @Tags client-apps
+// @Produce json
+// @Param id path string true "Client Application ID"
+// @Success 200 {object} manager.UsageReport "Usage against limits"
+// @Failure 404 {object} map[string]interface{} "Client application not found"
+// @Router /client-apps/{id}/limits [get]
+func (h *ManagerHandler) GetClientAppLimits(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	appID := vars["id"]
+
+	if _, err := h.manager.GetClientAppManager().GetClientApp(appID); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	report, err := h.manager.ClientAppUsage(appID)
+	if err != nil {
+		h.logger.Error("failed to report client app usage", zap.String("client_app_id", appID), zap.Error(err))
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// SetClientAppTier handles client application tier change requests
+// @Summary Change a client application's pricing tier
+// @Description Moves a client application to another pricing tier. A downgrade that would leave the app with more shards than the new tier allows is refused unless force is set; a forced downgrade keeps the existing shards but refuses new ones until the app is back within the limit.
+// @Tags client-apps
+// @Accept json
+// @Produce json
+// @Param id path string true "Client Application ID"
+// @Param request body object true "Tier Change Request" example({"tier": "free", "force": false})
+// @Success 200 {object} manager.TierChange "Tier changed"
+// @Failure 400 {object} map[string]interface{} "Bad request"
+// @Failure 404 {object} map[string]interface{} "Client application not found"
+// @Failure 409 {object} map[string]interface{} "Current usage exceeds the new tier's limits"
+// @Router /client-apps/{id}/tier [put]
+func (h *ManagerHandler) SetClientAppTier(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	appID := vars["id"]
+
+	var req struct {
+		Tier  string `json:"tier"`
+		Force bool   `json:"force"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if _, err := pricing.ParseTier(req.Tier); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if _, err := h.manager.GetClientAppManager().GetClientApp(appID); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	change, err := h.manager.SetClientAppTier(appID, req.Tier, req.Force)
+	if err != nil {
+		if errors.Is(err, manager.ErrTierLimitExceeded) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		h.logger.Error("failed to change client app tier", zap.String("client_app_id", appID), zap.Error(err))
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(change)
+}
+
 // DiscoverClientApps handles client application discovery requests
 // @Summary Discover applications from Kubernetes
-// @Description Discovers applications running in Kubernetes clusters that can be registered as client applications
+// @Description Discovers applications running in Kubernetes clusters that can be registered as client applications. Results are cached for a short time and concurrent requests share one scan; pass refresh=true to scan again. Under the lenient discovery filter, apps with incomplete database configuration are included with needs_database_info set.
 // @Tags client-apps
 // @Accept json
 // @Produce json
+// @Param refresh query bool false "Skip the cached result and scan the cluster again"
 // @Success 200 {array} discovery.DiscoveredApp "List of discovered applications"
+// @Failure 400 {string} string "Invalid refresh parameter"
 // @Failure 503 {object} map[string]interface{} "Kubernetes discovery not available"
 // @Failure 500 {object} map[string]interface{} "Internal server error"
 // @Router /client-apps/discover [get]
 func (h *ManagerHandler) DiscoverClientApps(w http.ResponseWriter, r *http.Request) {
+	refresh := false
+	if value := r.URL.Query().Get("refresh"); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			http.Error(w, "refresh must be true or false", http.StatusBadRequest)
+			return
+		}
+		refresh = parsed
+	}
+
 	// Get list of registered client apps to check which ones are already registered
 	clientAppMgr := h.manager.GetClientAppManager()
 	registeredApps, err := clientAppMgr.ListClientApps()
@@ -616,25 +1131,13 @@ func (h *ManagerHandler) DiscoverClientApps(w http.ResponseWriter, r *http.Reque
 		h.logger.Warn("failed to list registered apps for discovery", zap.Error(err))
 		registeredApps = []*manager.ClientAppInfo{}
 	}
-
-	// Build list of registered app names for discovery service
-	registeredNames := make([]string, 0, len(registeredApps))
+	registered := make(map[string]bool, len(registeredApps))
 	for _, app := range registeredApps {
-		registeredNames = append(registeredNames, app.Name)
-	}
-
-	// Try to create Kubernetes discovery service
-	var discoveryService discovery.DiscoveryService
-	discoveryService, err = discovery.NewKubernetesDiscovery(h.logger, registeredNames)
-	if err != nil {
-		// Kubernetes not available - use mock discovery (returns empty list)
-		h.logger.Info("Kubernetes discovery not available, using mock discovery", zap.Error(err))
-		discoveryService = discovery.NewMockDiscovery(h.logger)
-		discoveryService.UpdateRegisteredApps(registeredNames)
+		registered[app.Name] = true
 	}
 
 	// Discover applications
-	discoveredApps, err := discoveryService.DiscoverApplications(r.Context())
+	discoveredApps, err := h.discoveryCache.Get(r.Context(), refresh)
 	if err != nil {
 		h.logger.Error("failed to discover applications", zap.Error(err))
 		// Return 503 Service Unavailable if discovery fails
@@ -647,40 +1150,33 @@ func (h *ManagerHandler) DiscoverClientApps(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	// Filter out applications without database information
-	// Only applications with valid database connections should be discoverable
-	filteredApps := make([]discovery.DiscoveredApp, 0)
-	for _, app := range discoveredApps {
-		// Skip apps without database info - they should not be discoverable for sharding
-		if app.DatabaseHost == "" && app.DatabaseURL == "" {
-			h.logger.Debug("filtering out discovered app without database information",
-				zap.String("app_name", app.Name),
-				zap.String("namespace", app.Namespace))
-			continue
-		}
-
-		// Validate that we have sufficient database information
-		hasDBInfo := app.DatabaseHost != "" && app.DatabaseName != ""
-		if !hasDBInfo && app.DatabaseURL == "" {
-			h.logger.Debug("filtering out discovered app with insufficient database information",
-				zap.String("app_name", app.Name),
-				zap.String("namespace", app.Namespace))
-			continue
-		}
-
-		filteredApps = append(filteredApps, app)
+	// Registrations change between scans, so they are applied to each response
+	for i := range discoveredApps {
+		discoveredApps[i].IsRegistered = registered[discoveredApps[i].Name]
 	}
 
-	// Ensure we always return an array (not null)
-	if filteredApps == nil {
-		filteredApps = []discovery.DiscoveredApp{}
-	}
+	// Only applications with database connections can be sharded. Depending on
+	// the filter, partially-configured ones are surfaced for manual completion.
+	filteredApps := h.discoveryFilter.Apply(discoveredApps)
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(filteredApps)
 }
 
+// scanApplications runs one discovery scan for the discovery cache
+func (h *ManagerHandler) scanApplications(ctx context.Context) ([]discovery.DiscoveredApp, error) {
+	// Try to create Kubernetes discovery service
+	var discoveryService discovery.DiscoveryService
+	discoveryService, err := discovery.NewKubernetesDiscovery(h.logger, nil)
+	if err != nil {
+		// Kubernetes not available - use mock discovery (returns empty list)
+		h.logger.Info("Kubernetes discovery not available, using mock discovery", zap.Error(err))
+		discoveryService = discovery.NewMockDiscovery(h.logger)
+	}
+	return discoveryService.DiscoverApplications(ctx)
+}
+
 // SetupPublicRoutes sets up public manager HTTP routes
 func SetupPublicRoutes(router *mux.Router, handler *ManagerHandler) {
 	// Root route
@@ -695,6 +1191,7 @@ func SetupPublicRoutes(router *mux.Router, handler *ManagerHandler) {
 				"POST /api/v1/shards",
 				"GET /api/v1/shards/{id}",
 				"POST /api/v1/reshard/split",
+				"POST /api/v1/reshard/split/dry-run",
 				"POST /api/v1/reshard/merge",
 				"GET /api/v1/reshard/jobs/{id}",
 				"GET /api/v1/health",
@@ -702,6 +1199,8 @@ func SetupPublicRoutes(router *mux.Router, handler *ManagerHandler) {
 				"GET /api/v1/pricing",
 				"GET /api/v1/client-apps",
 				"GET /api/v1/client-apps/discover",
+				"GET /api/v1/client-apps/{id}/shard-map",
+				"GET /api/v1/client-apps/{id}/limits",
 			},
 		})
 	}).Methods("GET", "OPTIONS")
@@ -712,7 +1211,6 @@ func SetupPublicRoutes(router *mux.Router, handler *ManagerHandler) {
 	router.HandleFunc("/api/v1/client-apps", handler.CreateClientApp).Methods("POST", "OPTIONS")
 	router.HandleFunc("/api/v1/client-apps/discover", handler.DiscoverClientApps).Methods("GET", "OPTIONS")
 	router.HandleFunc("/api/v1/client-apps/{id}", handler.GetClientApp).Methods("GET", "OPTIONS")
-	router.HandleFunc("/api/v1/client-apps/{id}", handler.DeleteClientApp).Methods("DELETE", "OPTIONS")
 
 	// Health endpoint under /api/v1
 	router.HandleFunc("/api/v1/health", func(w http.ResponseWriter, r *http.Request) {
@@ -738,44 +1236,27 @@ func SetupProtectedRoutes(router *mux.Router, handler *ManagerHandler) {
 	router.HandleFunc("/api/v1/shards/{id}", handler.GetShard).Methods("GET", "OPTIONS")
 	router.HandleFunc("/api/v1/shards/{id}", handler.DeleteShard).Methods("DELETE", "OPTIONS")
 	router.HandleFunc("/api/v1/shards/{id}/promote", handler.PromoteReplica).Methods("POST", "OPTIONS")
+	router.HandleFunc("/api/v1/shards/{id}/move", handler.MoveShard).Methods("POST", "OPTIONS")
+	router.HandleFunc("/api/v1/shards/{id}/schema-diff", handler.DesiredSchemaDiff).Methods("POST", "OPTIONS")
+	router.HandleFunc("/api/v1/shards/{id}/schema-diff/{other}", handler.SchemaDiff).Methods("GET", "OPTIONS")
 	router.HandleFunc("/api/v1/shards/{id}/status", handler.UpdateShardStatus).Methods("PUT", "OPTIONS")
+	router.HandleFunc("/api/v1/shards/{id}/events", handler.GetShardEvents).Methods("GET", "OPTIONS")
+	// Deleting an app can cascade to its shards and their data
+	router.Handle("/api/v1/client-apps/{id}",
+		middleware.RequirePermission(handler.rbac, "client_apps", "delete")(http.HandlerFunc(handler.DeleteClientApp))).Methods("DELETE", "OPTIONS")
+	router.HandleFunc("/api/v1/client-apps/{id}/tier", handler.SetClientAppTier).Methods("PUT", "OPTIONS")
+	router.HandleFunc("/api/v1/client-apps/{id}/limits", handler.GetClientAppLimits).Methods("GET", "OPTIONS")
+	router.Handle("/api/v1/client-apps/{id}/shard-map",
+		middleware.RequirePermission(handler.rbac, "shards", "read")(http.HandlerFunc(handler.GetShardMap))).Methods("GET", "OPTIONS")
 
 	router.HandleFunc("/api/v1/reshard/split", handler.SplitShard).Methods("POST", "OPTIONS")
+	router.HandleFunc("/api/v1/reshard/split/dry-run", handler.AnalyzeSplit).Methods("POST", "OPTIONS")
 	router.HandleFunc("/api/v1/reshard/merge", handler.MergeShards).Methods("POST", "OPTIONS")
+	router.HandleFunc("/api/v1/reshard/rekey", handler.RekeyClientApp).Methods("POST", "OPTIONS")
 	router.HandleFunc("/api/v1/reshard/jobs/{id}", handler.GetReshardJob).Methods("GET", "OPTIONS")
 }
 
-// buildDSNFromShard builds a PostgreSQL DSN from shard connection details
+// buildDSNFromShard builds the DSN the collectors connect to a shard with
 func buildDSNFromShard(shard *models.Shard) string {
-	// If PrimaryEndpoint is provided and is a full connection string, use it
-	if shard.PrimaryEndpoint != "" {
-		// Check if it's already a DSN format (starts with postgres:// or postgresql://)
-		if len(shard.PrimaryEndpoint) > 10 && (shard.PrimaryEndpoint[:10] == "postgres://" || shard.PrimaryEndpoint[:14] == "postgresql://") {
-			return shard.PrimaryEndpoint
-		}
-	}
-
-	// Build DSN from individual connection details
-	if shard.Host == "" || shard.Database == "" {
-		return ""
-	}
-
-	port := shard.Port
-	if port == 0 {
-		port = 5432 // Default PostgreSQL port
-	}
-
-	dsn := fmt.Sprintf("host=%s port=%d dbname=%s", shard.Host, port, shard.Database)
-	
-	if shard.Username != "" {
-		dsn += fmt.Sprintf(" user=%s", shard.Username)
-	}
-	
-	if shard.Password != "" {
-		dsn += fmt.Sprintf(" password=%s", shard.Password)
-	}
-	
-	dsn += " sslmode=prefer connect_timeout=10"
-	
-	return dsn
+	return shard.CollectorConnectionString(monitoring.CollectorApplicationName)
 }
@@ -1,14 +1,21 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/sharding-system/internal/middleware"
 	"github.com/sharding-system/pkg/manager"
 	"github.com/sharding-system/pkg/monitoring"
+	"github.com/sharding-system/pkg/security"
 	"go.uber.org/zap"
 )
 
@@ -16,6 +23,8 @@ import (
 type PostgresStatsHandler struct {
 	statsCollector *monitoring.PostgresStatsCollector
 	manager        *manager.Manager
+	rbac           *security.RBAC
+	audit          *security.AuditLogger
 	logger         *zap.Logger
 }
 
@@ -28,10 +37,16 @@ func NewPostgresStatsHandler(
 	return &PostgresStatsHandler{
 		statsCollector: statsCollector,
 		manager:        manager,
+		rbac:           security.NewRBAC(),
 		logger:         logger,
 	}
 }
 
+// SetAuditLogger records cancelled and terminated backends in the audit log
+func (h *PostgresStatsHandler) SetAuditLogger(audit *security.AuditLogger) {
+	h.audit = audit
+}
+
 // GetDatabaseStats returns PostgreSQL stats for a specific database
 // @Summary Get PostgreSQL stats for a database
 // @Description Returns detailed PostgreSQL statistics for a specific database
@@ -109,11 +124,529 @@ func (h *PostgresStatsHandler) GetShardStats(w http.ResponseWriter, r *http.Requ
 	json.NewEncoder(w).Encode(stats)
 }
 
+// GetShardSlowQueries lists the queries currently running longer than the slow query threshold on a shard
+// @Summary List slow queries on a shard
+// @Description Returns the queries currently running on a shard for longer than the slow query threshold, longest running first. Query text has its literals masked.
+// @Tags postgres-stats
+// @Produce json
+// @Param id path string true "Shard ID"
+// @Param threshold query string false "Override the configured threshold, e.g. 500ms or 5s"
+// @Success 200 {object} map[string]interface{} "Slow queries"
+// @Failure 400 {object} map[string]interface{} "Invalid threshold"
+// @Failure 404 {object} map[string]interface{} "Shard not found or not monitored"
+// @Router /api/v1/shards/{id}/slow-queries [get]
+func (h *PostgresStatsHandler) GetShardSlowQueries(w http.ResponseWriter, r *http.Request) {
+	shardID := mux.Vars(r)["id"]
+
+	if _, err := h.manager.GetShard(shardID); err != nil {
+		http.Error(w, "shard not found", http.StatusNotFound)
+		return
+	}
+
+	threshold := h.statsCollector.SlowQueryThreshold()
+	if raw := r.URL.Query().Get("threshold"); raw != "" {
+		parsed, err := time.ParseDuration(raw)
+		if err != nil || parsed <= 0 {
+			http.Error(w, "threshold must be a positive duration such as 500ms or 5s", http.StatusBadRequest)
+			return
+		}
+		threshold = parsed
+	}
+
+	slowQueries, err := h.statsCollector.GetSlowQueries(r.Context(), shardID, threshold)
+	if err != nil {
+		h.logger.Warn("failed to list slow queries",
+			zap.String("shard_id", shardID),
+			zap.Error(err))
+		http.Error(w, "slow queries not available for shard", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"shard_id":     shardID,
+		"threshold_ms": threshold.Milliseconds(),
+		"count":        len(slowQueries),
+		"slow_queries": slowQueries,
+	})
+}
+
+// GetShardConnections lists the sessions currently open on a shard's server
+// @Summary Describe connections on a shard
+// @Description Returns the live client sessions from pg_stat_activity on a shard's server, oldest first, with their state, transaction and query start, wait event, blocking PIDs and client address, to spot idle in transaction or blocking sessions. Query text has its literals masked.
+// @Tags postgres-stats
+// @Produce json
+// @Param id path string true "Shard ID"
+// @Success 200 {object} map[string]interface{} "Connections"
+// @Failure 404 {object} map[string]interface{} "Shard not found or not monitored"
+// @Failure 501 {object} map[string]interface{} "Not supported for the shard's engine"
+// @Failure 500 {object} map[string]interface{} "Listing connections failed"
+// @Router /api/v1/shards/{id}/connections [get]
+func (h *PostgresStatsHandler) GetShardConnections(w http.ResponseWriter, r *http.Request) {
+	shardID := mux.Vars(r)["id"]
+
+	if _, err := h.manager.GetShard(shardID); err != nil {
+		http.Error(w, "shard not found", http.StatusNotFound)
+		return
+	}
+
+	connections, err := h.statsCollector.GetConnections(r.Context(), shardID)
+	if err != nil {
+		switch {
+		case errors.Is(err, monitoring.ErrDatabaseNotRegistered):
+			http.Error(w, "shard is not monitored", http.StatusNotFound)
+		case errors.Is(err, monitoring.ErrUnsupportedEngine):
+			http.Error(w, err.Error(), http.StatusNotImplemented)
+		default:
+			h.logger.Error("failed to list shard connections",
+				zap.String("shard_id", shardID),
+				zap.Error(err))
+			http.Error(w, "failed to list connections", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	byState := make(map[string]int)
+	for _, c := range connections {
+		byState[c.State]++
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"shard_id":    shardID,
+		"count":       len(connections),
+		"by_state":    byState,
+		"connections": connections,
+	})
+}
+
+// GetShardLocks returns the lock-wait graph of a shard's server
+// @Summary Describe lock waits on a shard
+// @Description Returns which sessions are blocked by which on a shard's server, from pg_locks joined with pg_stat_activity. root_blockers are the blocking sessions not waiting themselves, and cycles lists sessions waiting on each other in a loop (a deadlock PostgreSQL has not broken yet). Query text has its literals masked.
+// @Tags postgres-stats
+// @Produce json
+// @Param id path string true "Shard ID"
+// @Success 200 {object} map[string]interface{} "Lock-wait graph"
+// @Failure 404 {object} map[string]interface{} "Shard not found or not monitored"
+// @Failure 501 {object} map[string]interface{} "Not supported for the shard's engine"
+// @Failure 500 {object} map[string]interface{} "Reading locks failed"
+// @Router /api/v1/shards/{id}/locks [get]
+func (h *PostgresStatsHandler) GetShardLocks(w http.ResponseWriter, r *http.Request) {
+	shardID := mux.Vars(r)["id"]
+
+	if _, err := h.manager.GetShard(shardID); err != nil {
+		http.Error(w, "shard not found", http.StatusNotFound)
+		return
+	}
+
+	graph, err := h.statsCollector.GetLockGraph(r.Context(), shardID)
+	if err != nil {
+		switch {
+		case errors.Is(err, monitoring.ErrDatabaseNotRegistered):
+			http.Error(w, "shard is not monitored", http.StatusNotFound)
+		case errors.Is(err, monitoring.ErrUnsupportedEngine):
+			http.Error(w, err.Error(), http.StatusNotImplemented)
+		default:
+			h.logger.Error("failed to read shard locks",
+				zap.String("shard_id", shardID),
+				zap.Error(err))
+			http.Error(w, "failed to read locks", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"shard_id":      shardID,
+		"blocked":       graph.Blocked,
+		"root_blockers": graph.RootBlockers,
+		"cycles":        graph.Cycles,
+		"deadlocks":     graph.Deadlocks,
+		"waits":         graph.Waits,
+	})
+}
+
+// GetShardIndexRecommendations suggests indexes for tables on a shard that are mostly read with sequential scans
+// @Summary Recommend indexes for a shard
+// @Description Identifies large tables with a high sequential scan ratio and suggests indexes on their most frequent filter columns, taken from pg_stat_statements when available. Advisory only; no index is created.
+// @Tags postgres-stats
+// @Produce json
+// @Param id path string true "Shard ID"
+// @Param min_rows query int false "Minimum live rows for a table to be considered (default 10000)"
+// @Param min_seq_scan_ratio query number false "Minimum share of sequential scans, 0.0 to 1.0 (default 0.5)"
+// @Success 200 {object} map[string]interface{} "Index recommendations"
+// @Failure 400 {object} map[string]interface{} "Invalid parameters"
+// @Failure 404 {object} map[string]interface{} "Shard not found or not monitored"
+// @Router /api/v1/shards/{id}/index-recommendations [get]
+func (h *PostgresStatsHandler) GetShardIndexRecommendations(w http.ResponseWriter, r *http.Request) {
+	shardID := mux.Vars(r)["id"]
+
+	if _, err := h.manager.GetShard(shardID); err != nil {
+		http.Error(w, "shard not found", http.StatusNotFound)
+		return
+	}
+
+	var opts monitoring.IndexAdvisorOptions
+	if raw := r.URL.Query().Get("min_rows"); raw != "" {
+		minRows, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || minRows < 0 {
+			http.Error(w, "min_rows must be a non-negative integer", http.StatusBadRequest)
+			return
+		}
+		opts.MinRows = minRows
+	}
+	if raw := r.URL.Query().Get("min_seq_scan_ratio"); raw != "" {
+		ratio, err := strconv.ParseFloat(raw, 64)
+		if err != nil || ratio < 0 || ratio > 1 {
+			http.Error(w, "min_seq_scan_ratio must be between 0 and 1", http.StatusBadRequest)
+			return
+		}
+		opts.MinSeqScanRatio = ratio
+	}
+
+	recommendations, err := h.statsCollector.GetIndexRecommendations(r.Context(), shardID, opts)
+	if err != nil {
+		h.logger.Warn("failed to compute index recommendations",
+			zap.String("shard_id", shardID),
+			zap.Error(err))
+		http.Error(w, "index recommendations not available for shard", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"shard_id":        shardID,
+		"count":           len(recommendations),
+		"recommendations": recommendations,
+	})
+}
+
+// GetShardUnusedIndexes reports the indexes on a shard that have never been scanned
+// @Summary List unused indexes on a shard
+// @Description Lists indexes with zero scans since statistics were last reset, largest first, with their size and last scan time. Indexes backing primary keys, unique indexes or constraints are marked as not droppable.
+// @Tags postgres-stats
+// @Produce json
+// @Param id path string true "Shard ID"
+// @Success 200 {object} monitoring.UnusedIndexReport "Unused indexes"
+// @Failure 404 {object} map[string]interface{} "Shard not found or not monitored"
+// @Router /api/v1/shards/{id}/unused-indexes [get]
+func (h *PostgresStatsHandler) GetShardUnusedIndexes(w http.ResponseWriter, r *http.Request) {
+	shardID := mux.Vars(r)["id"]
+
+	if _, err := h.manager.GetShard(shardID); err != nil {
+		http.Error(w, "shard not found", http.StatusNotFound)
+		return
+	}
+
+	report, err := h.statsCollector.GetUnusedIndexes(r.Context(), shardID)
+	if err != nil {
+		h.logger.Warn("failed to list unused indexes",
+			zap.String("shard_id", shardID),
+			zap.Error(err))
+		http.Error(w, "unused indexes not available for shard", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// GetShardCapacity returns the size history and growth rates of a shard
+// @Summary Get size growth of a shard
+// @Description Returns the recent size samples of a shard, its growth rate in bytes per day and the size and growth rate of its largest tables, fastest growing first. Samples are kept for the configured size history retention; growth is 0 until two samples are kept.
+// @Tags postgres-stats
+// @Produce json
+// @Param id path string true "Shard ID"
+// @Success 200 {object} monitoring.CapacityReport "Size history and growth rates"
+// @Failure 404 {object} map[string]interface{} "Shard not found or not monitored"
+// @Router /api/v1/shards/{id}/capacity [get]
+func (h *PostgresStatsHandler) GetShardCapacity(w http.ResponseWriter, r *http.Request) {
+	shardID := mux.Vars(r)["id"]
+
+	if _, err := h.manager.GetShard(shardID); err != nil {
+		http.Error(w, "shard not found", http.StatusNotFound)
+		return
+	}
+
+	report, err := h.statsCollector.GetCapacity(shardID)
+	if err != nil {
+		h.logger.Warn("failed to get shard capacity",
+			zap.String("shard_id", shardID),
+			zap.Error(err))
+		http.Error(w, "capacity not available for shard", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// DropShardUnusedIndex drops an unused index on a shard
+// @Summary Drop an unused index
+// @Description Drops an index that has never been scanned. Requires the admin role and a confirm parameter repeating the index name. Indexes backing primary keys, unique indexes or constraints are refused.
+// @Tags postgres-stats
+// @Produce json
+// @Param id path string true "Shard ID"
+// @Param index path string true "Index name"
+// @Param schema query string false "Index schema (default public)"
+// @Param confirm query string true "Must equal the index name"
+// @Success 200 {object} map[string]interface{} "Index dropped"
+// @Failure 400 {object} map[string]interface{} "Missing or mismatched confirmation"
+// @Failure 403 {object} map[string]interface{} "Insufficient permissions"
+// @Failure 404 {object} map[string]interface{} "Shard or unused index not found"
+// @Failure 409 {object} map[string]interface{} "Index backs a primary key or constraint"
+// @Failure 500 {object} map[string]interface{} "Drop failed"
+// @Router /api/v1/shards/{id}/unused-indexes/{index} [delete]
+func (h *PostgresStatsHandler) DropShardUnusedIndex(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	shardID, indexName := vars["id"], vars["index"]
+
+	if r.URL.Query().Get("confirm") != indexName {
+		http.Error(w, "confirm must repeat the index name to drop it", http.StatusBadRequest)
+		return
+	}
+	schemaName := r.URL.Query().Get("schema")
+	if schemaName == "" {
+		schemaName = "public"
+	}
+
+	if _, err := h.manager.GetShard(shardID); err != nil {
+		http.Error(w, "shard not found", http.StatusNotFound)
+		return
+	}
+
+	dropped, err := h.statsCollector.DropUnusedIndex(r.Context(), shardID, schemaName, indexName)
+	if err != nil {
+		switch {
+		case errors.Is(err, monitoring.ErrIndexNotFound):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case errors.Is(err, monitoring.ErrIndexNotDroppable):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			h.logger.Error("failed to drop unused index",
+				zap.String("shard_id", shardID),
+				zap.String("index", indexName),
+				zap.Error(err))
+			http.Error(w, "failed to drop index", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"shard_id": shardID,
+		"dropped":  dropped,
+	})
+}
+
+// ResetShardStats resets the PostgreSQL statistics counters of a shard
+// @Summary Reset shard statistics
+// @Description Calls pg_stat_reset() on the shard's database and re-baselines the collector, so the next collection starts counting afresh instead of reporting the reset as a spike.
+// @Tags postgres-stats
+// @Produce json
+// @Param id path string true "Shard ID"
+// @Success 200 {object} map[string]interface{} "Statistics reset"
+// @Failure 403 {object} map[string]interface{} "Insufficient permissions"
+// @Failure 404 {object} map[string]interface{} "Shard not found or not monitored"
+// @Failure 500 {object} map[string]interface{} "Reset failed"
+// @Router /api/v1/shards/{id}/reset-stats [post]
+func (h *PostgresStatsHandler) ResetShardStats(w http.ResponseWriter, r *http.Request) {
+	shardID := mux.Vars(r)["id"]
+
+	if _, err := h.manager.GetShard(shardID); err != nil {
+		http.Error(w, "shard not found", http.StatusNotFound)
+		return
+	}
+
+	if err := h.statsCollector.ResetStats(r.Context(), shardID); err != nil {
+		if errors.Is(err, monitoring.ErrDatabaseNotRegistered) {
+			http.Error(w, "shard is not monitored", http.StatusNotFound)
+			return
+		}
+		h.logger.Error("failed to reset shard stats",
+			zap.String("shard_id", shardID),
+			zap.Error(err))
+		http.Error(w, "failed to reset stats", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"shard_id": shardID,
+		"reset":    true,
+	})
+}
+
+// backendRequest identifies a backend to cancel or terminate
+type backendRequest struct {
+	PID int `json:"pid"`
+}
+
+// CancelShardBackend cancels the query a backend of a shard is running
+// @Summary Cancel a backend's query on a shard
+// @Description Calls pg_cancel_backend for a backend PID from the slow query listing. The query is cancelled but the session stays open.
+// @Tags postgres-stats
+// @Accept json
+// @Produce json
+// @Param id path string true "Shard ID"
+// @Param request body backendRequest true "Backend PID"
+// @Success 200 {object} map[string]interface{} "Query cancelled"
+// @Failure 400 {object} map[string]interface{} "Invalid request body"
+// @Failure 403 {object} map[string]interface{} "Insufficient permissions"
+// @Failure 404 {object} map[string]interface{} "Shard or backend not found"
+// @Failure 500 {object} map[string]interface{} "Cancel failed"
+// @Router /api/v1/shards/{id}/cancel [post]
+func (h *PostgresStatsHandler) CancelShardBackend(w http.ResponseWriter, r *http.Request) {
+	h.signalShardBackend(w, r, "cancel", h.statsCollector.CancelBackend)
+}
+
+// TerminateShardBackend ends the session of a backend of a shard
+// @Summary Terminate a backend on a shard
+// @Description Calls pg_terminate_backend for a backend PID from the slow query listing. The session is closed and its open transaction rolled back.
+// @Tags postgres-stats
+// @Accept json
+// @Produce json
+// @Param id path string true "Shard ID"
+// @Param request body backendRequest true "Backend PID"
+// @Success 200 {object} map[string]interface{} "Backend terminated"
+// @Failure 400 {object} map[string]interface{} "Invalid request body"
+// @Failure 403 {object} map[string]interface{} "Insufficient permissions"
+// @Failure 404 {object} map[string]interface{} "Shard or backend not found"
+// @Failure 500 {object} map[string]interface{} "Terminate failed"
+// @Router /api/v1/shards/{id}/terminate [post]
+func (h *PostgresStatsHandler) TerminateShardBackend(w http.ResponseWriter, r *http.Request) {
+	h.signalShardBackend(w, r, "terminate", h.statsCollector.TerminateBackend)
+}
+
+// signalShardBackend applies signal, a cancel or terminate, to the backend PID
+// in the request body and records the outcome in the audit log
+func (h *PostgresStatsHandler) signalShardBackend(w http.ResponseWriter, r *http.Request, action string,
+	signal func(ctx context.Context, databaseID string, pid int) error) {
+	shardID := mux.Vars(r)["id"]
+
+	var req backendRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.PID <= 0 {
+		http.Error(w, "request body must contain a positive pid", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := h.manager.GetShard(shardID); err != nil {
+		http.Error(w, "shard not found", http.StatusNotFound)
+		return
+	}
+
+	err := signal(r.Context(), shardID, req.PID)
+	h.auditBackendSignal(r, action, shardID, req.PID, err)
+	if err != nil {
+		switch {
+		case errors.Is(err, monitoring.ErrBackendNotFound), errors.Is(err, monitoring.ErrDatabaseNotRegistered):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		default:
+			h.logger.Error("failed to signal backend",
+				zap.String("action", action),
+				zap.String("shard_id", shardID),
+				zap.Int("pid", req.PID),
+				zap.Error(err))
+			http.Error(w, fmt.Sprintf("failed to %s backend", action), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"shard_id": shardID,
+		"pid":      req.PID,
+		"action":   action,
+	})
+}
+
+// auditBackendSignal records who cancelled or terminated a backend
+func (h *PostgresStatsHandler) auditBackendSignal(r *http.Request, action, shardID string, pid int, err error) {
+	user, _ := r.Context().Value("username").(string)
+	h.logger.Info("backend signal requested",
+		zap.String("action", action),
+		zap.String("shard_id", shardID),
+		zap.Int("pid", pid),
+		zap.String("user", user),
+		zap.Bool("success", err == nil))
+
+	if h.audit == nil {
+		return
+	}
+	event := security.AuditEvent{
+		User:       user,
+		Action:     action,
+		Resource:   "backends",
+		ResourceID: fmt.Sprintf("%s/%d", shardID, pid),
+		Success:    err == nil,
+		IP:         r.RemoteAddr,
+	}
+	if err != nil {
+		event.Error = err.Error()
+	}
+	h.audit.LogContext(r.Context(), event)
+}
+
+// GetStatsSnapshot exports the latest stats of every registered database
+// @Summary Export a stats snapshot
+// @Description Returns a point-in-time dump of the latest PostgreSQL statistics for every registered database, including collection timestamps and per-database errors. Use format=ndjson for one database per line.
+// @Tags postgres-stats
+// @Produce json
+// @Produce application/x-ndjson
+// @Param format query string false "Output format: json (default) or ndjson"
+// @Success 200 {object} monitoring.StatsSnapshot "Stats snapshot"
+// @Failure 400 {object} map[string]interface{} "Unsupported format"
+// @Router /api/v1/stats/snapshot [get]
+func (h *PostgresStatsHandler) GetStatsSnapshot(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "json"
+	}
+	if format != "json" && format != "ndjson" {
+		http.Error(w, "format must be json or ndjson", http.StatusBadRequest)
+		return
+	}
+
+	snapshot := h.statsCollector.Snapshot()
+	filename := fmt.Sprintf("stats-snapshot-%s.%s", snapshot.GeneratedAt.UTC().Format("20060102T150405Z"), format)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+
+	if format == "ndjson" {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		encoder := json.NewEncoder(w)
+		for _, entry := range snapshot.Databases {
+			if err := encoder.Encode(entry); err != nil {
+				h.logger.Warn("failed to write stats snapshot", zap.Error(err))
+				return
+			}
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(snapshot)
+}
+
 // RegisterRoutes registers PostgreSQL stats API routes
 func (h *PostgresStatsHandler) RegisterRoutes(router *mux.Router) {
 	router.HandleFunc("/api/v1/databases/{id}/stats", h.GetDatabaseStats).Methods("GET", "OPTIONS")
 	router.HandleFunc("/api/v1/databases/stats", h.GetAllDatabaseStats).Methods("GET", "OPTIONS")
 	router.HandleFunc("/api/v1/shards/{id}/stats", h.GetShardStats).Methods("GET", "OPTIONS")
+	router.HandleFunc("/api/v1/shards/{id}/slow-queries", h.GetShardSlowQueries).Methods("GET", "OPTIONS")
+	router.HandleFunc("/api/v1/shards/{id}/connections", h.GetShardConnections).Methods("GET", "OPTIONS")
+	router.HandleFunc("/api/v1/shards/{id}/locks", h.GetShardLocks).Methods("GET", "OPTIONS")
+	router.HandleFunc("/api/v1/shards/{id}/index-recommendations", h.GetShardIndexRecommendations).Methods("GET", "OPTIONS")
+	router.HandleFunc("/api/v1/shards/{id}/unused-indexes", h.GetShardUnusedIndexes).Methods("GET", "OPTIONS")
+	router.HandleFunc("/api/v1/shards/{id}/capacity", h.GetShardCapacity).Methods("GET", "OPTIONS")
+	router.Handle("/api/v1/shards/{id}/unused-indexes/{index}",
+		middleware.RequirePermission(h.rbac, "indexes", "delete")(http.HandlerFunc(h.DropShardUnusedIndex))).Methods("DELETE", "OPTIONS")
+	router.Handle("/api/v1/shards/{id}/reset-stats",
+		middleware.RequirePermission(h.rbac, "stats", "reset")(http.HandlerFunc(h.ResetShardStats))).Methods("POST", "OPTIONS")
+	router.Handle("/api/v1/shards/{id}/cancel",
+		middleware.RequirePermission(h.rbac, "backends", "cancel")(http.HandlerFunc(h.CancelShardBackend))).Methods("POST", "OPTIONS")
+	router.Handle("/api/v1/shards/{id}/terminate",
+		middleware.RequirePermission(h.rbac, "backends", "terminate")(http.HandlerFunc(h.TerminateShardBackend))).Methods("POST", "OPTIONS")
+	router.HandleFunc("/api/v1/stats/snapshot", h.GetStatsSnapshot).Methods("GET", "OPTIONS")
 }
 
 // endpointToDSN converts a PostgreSQL endpoint URL to DSN format
@@ -161,4 +694,3 @@ func endpointToDSN(endpoint string) (string, error) {
 
 	return strings.Join(parts, " "), nil
 }
-
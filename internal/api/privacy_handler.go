@@ -0,0 +1,130 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/sharding-system/internal/middleware"
+	"github.com/sharding-system/pkg/privacy"
+	"github.com/sharding-system/pkg/security"
+	"go.uber.org/zap"
+)
+
+// PrivacyHandler handles tenant data export and erasure API endpoints
+type PrivacyHandler struct {
+	service *privacy.Service
+	logger  *zap.Logger
+	rbac    *security.RBAC
+}
+
+// NewPrivacyHandler creates a new privacy handler
+func NewPrivacyHandler(service *privacy.Service, logger *zap.Logger) *PrivacyHandler {
+	return &PrivacyHandler{
+		service: service,
+		logger:  logger,
+		rbac:    security.NewRBAC(),
+	}
+}
+
+// RegisterRoutes registers privacy API routes. Exports and erasures reach
+// every shard of an app, so only admins may run them by default.
+func (h *PrivacyHandler) RegisterRoutes(r *mux.Router) {
+	r.Handle("/api/v1/client-apps/{id}/data-export",
+		middleware.RequirePermission(h.rbac, "tenant_data", "export")(http.HandlerFunc(h.ExportData))).Methods("POST", "OPTIONS")
+	r.Handle("/api/v1/client-apps/{id}/data-erasure",
+		middleware.RequirePermission(h.rbac, "tenant_data", "erase")(http.HandlerFunc(h.EraseData))).Methods("POST", "OPTIONS")
+}
+
+// dataSubjectRequest is the body of export and erasure requests
+type dataSubjectRequest struct {
+	Key string `json:"key"`
+}
+
+// ExportData exports every row of a data subject as a zip archive
+// @Summary Export tenant data
+// @Description Collects every row whose shard key column equals key from all shards of the client app into a zip archive
+// @Tags privacy
+// @Accept json
+// @Produce application/zip
+// @Param id path string true "Client App ID"
+// @Param request body object true "Data subject" example({"key": "user-42"})
+// @Success 200 {file} file "Export archive"
+// @Failure 400 {string} string "Bad request"
+// @Failure 404 {string} string "Client app has no shards"
+// @Failure 500 {string} string "Internal server error"
+// @Router /api/v1/client-apps/{id}/data-export [post]
+func (h *PrivacyHandler) ExportData(w http.ResponseWriter, r *http.Request) {
+	req, ok := h.decodeRequest(w, r)
+	if !ok {
+		return
+	}
+
+	export, err := h.service.Export(r.Context(), req)
+	if err != nil {
+		h.writeError(w, "failed to export tenant data", req, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fmt.Sprintf("%s-export-%s.zip", req.ClientAppID, export.ExportedAt.UTC().Format("20060102T150405Z"))))
+	if err := export.WriteArchive(w); err != nil {
+		h.logger.Error("failed to write export archive", zap.String("client_app_id", req.ClientAppID), zap.Error(err))
+	}
+}
+
+// EraseData deletes every row of a data subject
+// @Summary Erase tenant data
+// @Description Deletes every row whose shard key column equals key from all shards of the client app, one transaction per shard. PostgreSQL and MySQL shards are supported.
+// @Tags privacy
+// @Accept json
+// @Produce json
+// @Param id path string true "Client App ID"
+// @Param request body object true "Data subject" example({"key": "user-42"})
+// @Success 200 {object} privacy.Erasure
+// @Failure 400 {string} string "Bad request"
+// @Failure 404 {string} string "Client app has no shards"
+// @Failure 500 {object} privacy.Erasure "Some shards could not be erased"
+// @Router /api/v1/client-apps/{id}/data-erasure [post]
+func (h *PrivacyHandler) EraseData(w http.ResponseWriter, r *http.Request) {
+	req, ok := h.decodeRequest(w, r)
+	if !ok {
+		return
+	}
+
+	erasure, err := h.service.Erase(r.Context(), req)
+	if err != nil && erasure == nil {
+		h.writeError(w, "failed to erase tenant data", req, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err != nil {
+		// Report which shards kept their rows so the erasure can be retried
+		h.logger.Error("failed to erase tenant data", zap.String("client_app_id", req.ClientAppID), zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+	json.NewEncoder(w).Encode(erasure)
+}
+
+// decodeRequest reads the data subject of a request
+func (h *PrivacyHandler) decodeRequest(w http.ResponseWriter, r *http.Request) (privacy.Request, bool) {
+	var body dataSubjectRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Key == "" {
+		http.Error(w, "request body must contain a key", http.StatusBadRequest)
+		return privacy.Request{}, false
+	}
+	user, _ := r.Context().Value("username").(string)
+	return privacy.Request{ClientAppID: mux.Vars(r)["id"], Key: body.Key, RequestedBy: user}, true
+}
+
+func (h *PrivacyHandler) writeError(w http.ResponseWriter, msg string, req privacy.Request, err error) {
+	if errors.Is(err, privacy.ErrNoShards) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	h.logger.Error(msg, zap.String("client_app_id", req.ClientAppID), zap.Error(err))
+	http.Error(w, msg, http.StatusInternalServerError)
+}
@@ -2,12 +2,16 @@ package api
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 
 	"github.com/gorilla/mux"
 	"github.com/sharding-system/internal/errors"
+	"github.com/sharding-system/internal/middleware"
+	"github.com/sharding-system/pkg/logging"
 	"github.com/sharding-system/pkg/models"
 	"github.com/sharding-system/pkg/router"
+	"github.com/sharding-system/pkg/security"
 	"go.uber.org/zap"
 )
 
@@ -26,6 +30,7 @@ import (
 type RouterHandler struct {
 	router       *router.Router
 	logger       *zap.Logger
+	rbac         *security.RBAC
 	clientAppMgr interface {
 		TrackRequest(shardKey string, shardID string)
 	}
@@ -38,6 +43,7 @@ func NewRouterHandler(r *router.Router, logger *zap.Logger, clientAppMgr interfa
 	return &RouterHandler{
 		router:       r,
 		logger:       logger,
+		rbac:         security.NewRBAC(),
 		clientAppMgr: clientAppMgr,
 	}
 }
@@ -49,10 +55,16 @@ func NewRouterHandler(r *router.Router, logger *zap.Logger, clientAppMgr interfa
 // @Accept json
 // @Produce json
 // @Param X-Client-App-ID header string true "Client Application ID"
+// @Param X-Read-Preference header string false "Read preference overriding the replica policy: primary, prefer-replica, replica-only or nearest"
+// @Param read_preference query string false "Read preference, if the X-Read-Preference header is not set"
 // @Param request body models.QueryRequest true "Query Request"
 // @Success 200 {object} models.QueryResponse "Query executed successfully"
 // @Failure 400 {object} map[string]interface{} "Bad request"
+// @Failure 422 {object} map[string]interface{} "Query exceeds cost budget"
+// @Failure 429 {object} map[string]interface{} "Throttled by QoS admission control"
 // @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Failure 503 {object} map[string]interface{} "No replica available for a replica-only read"
+// @Failure 504 {object} map[string]interface{} "Query exceeded the statement timeout"
 // @Router /execute [post]
 func (h *RouterHandler) ExecuteQuery(w http.ResponseWriter, r *http.Request) {
 	// Extract client application ID from header
@@ -82,9 +94,35 @@ func (h *RouterHandler) ExecuteQuery(w http.ResponseWriter, r *http.Request) {
 		req.Consistency = "strong"
 	}
 
+	// A read preference in the header or query string overrides the body's
+	if preference := r.Header.Get("X-Read-Preference"); preference != "" {
+		req.ReadPreference = preference
+	} else if preference := r.URL.Query().Get("read_preference"); preference != "" {
+		req.ReadPreference = preference
+	}
+
+	logger := logging.WithRequestID(r.Context(), h.logger)
 	resp, err := h.router.ExecuteQuery(r.Context(), &req, clientAppID)
 	if err != nil {
-		h.logger.Error("query execution failed", zap.Error(err))
+		if router.IsThrottled(err) {
+			logger.Debug("query throttled", zap.String("client_app_id", clientAppID), zap.Error(err))
+			h.writeError(w, errors.Wrap(err, http.StatusTooManyRequests, "query throttled"))
+			return
+		}
+		if router.IsTooExpensive(err) {
+			h.writeError(w, errors.Wrap(err, http.StatusUnprocessableEntity, "query rejected by cost guardrail"))
+			return
+		}
+		if router.IsNoReplicaAvailable(err) {
+			h.writeError(w, errors.Wrap(err, http.StatusServiceUnavailable, "no replica available"))
+			return
+		}
+		if router.IsTimeout(err) {
+			logger.Warn("query timed out", zap.String("client_app_id", clientAppID), zap.Error(err))
+			h.writeError(w, errors.Wrap(err, http.StatusGatewayTimeout, "query timed out"))
+			return
+		}
+		logger.Error("query execution failed", zap.Error(err))
 		h.writeError(w, errors.Wrap(err, http.StatusInternalServerError, "query execution failed"))
 		return
 	}
@@ -96,7 +134,7 @@ func (h *RouterHandler) ExecuteQuery(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(resp); err != nil {
-		h.logger.Error("failed to encode response", zap.Error(err))
+		logger.Error("failed to encode response", zap.Error(err))
 	}
 }
 
@@ -144,6 +182,199 @@ func (h *RouterHandler) GetShardForKey(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// RouteRequest asks where a key is placed
+type RouteRequest struct {
+	ClientAppID string `json:"client_app_id"`
+	Key         string `json:"key"`
+}
+
+// ResolveRoute handles route resolution requests
+// @Summary Resolve the shard for a key
+// @Description Returns the shard a key is placed on for a client application, with its primary endpoint and the key's placement hash, so SDKs can verify their local routing matches the server
+// @Tags router
+// @Accept json
+// @Produce json
+// @Param request body RouteRequest true "Client application and key"
+// @Success 200 {object} router.Route "Route for the key"
+// @Failure 400 {object} map[string]interface{} "Bad request"
+// @Failure 404 {object} map[string]interface{} "No shard for the key"
+// @Router /api/v1/route [post]
+func (h *RouterHandler) ResolveRoute(w http.ResponseWriter, r *http.Request) {
+	var req RouteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.writeError(w, errors.Wrap(err, http.StatusBadRequest, "invalid request body"))
+		return
+	}
+	if req.ClientAppID == "" {
+		h.writeError(w, errors.New(http.StatusBadRequest, "client_app_id is required - sharding is scoped to client applications"))
+		return
+	}
+	if req.Key == "" {
+		h.writeError(w, errors.New(http.StatusBadRequest, "key is required"))
+		return
+	}
+
+	route, err := h.router.ResolveRoute(req.Key, req.ClientAppID)
+	if err != nil {
+		h.writeError(w, errors.Wrap(err, http.StatusNotFound, "no shard found for key"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(route); err != nil {
+		h.logger.Error("failed to encode response", zap.Error(err))
+	}
+}
+
+// maxBatchRouteKeys caps the keys resolved in one batch route request
+const maxBatchRouteKeys = 10000
+
+// BatchRouteRequest asks where each of a set of keys is placed
+type BatchRouteRequest struct {
+	ClientAppID string   `json:"client_app_id"`
+	Keys        []string `json:"keys"`
+}
+
+// BatchRouteResponse lists the keys placed on each shard
+type BatchRouteResponse struct {
+	Shards map[string][]string `json:"shards"`
+}
+
+// ResolveRoutes handles batch route resolution requests
+// @Summary Resolve the shards for a batch of keys
+// @Description Buckets keys by the shard they are placed on for a client application, using the same placement as single route resolution
+// @Tags router
+// @Accept json
+// @Produce json
+// @Param request body BatchRouteRequest true "Client application and keys"
+// @Success 200 {object} BatchRouteResponse "Keys by shard ID"
+// @Failure 400 {object} map[string]interface{} "Bad request"
+// @Failure 404 {object} map[string]interface{} "No shard for a key"
+// @Router /api/v1/route/batch [post]
+func (h *RouterHandler) ResolveRoutes(w http.ResponseWriter, r *http.Request) {
+	var req BatchRouteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.writeError(w, errors.Wrap(err, http.StatusBadRequest, "invalid request body"))
+		return
+	}
+	if req.ClientAppID == "" {
+		h.writeError(w, errors.New(http.StatusBadRequest, "client_app_id is required - sharding is scoped to client applications"))
+		return
+	}
+	if len(req.Keys) == 0 {
+		h.writeError(w, errors.New(http.StatusBadRequest, "keys is required"))
+		return
+	}
+	if len(req.Keys) > maxBatchRouteKeys {
+		h.writeError(w, errors.New(http.StatusBadRequest, fmt.Sprintf("at most %d keys may be resolved per request", maxBatchRouteKeys)))
+		return
+	}
+	for _, key := range req.Keys {
+		if key == "" {
+			h.writeError(w, errors.New(http.StatusBadRequest, "keys must not be empty"))
+			return
+		}
+	}
+
+	buckets, err := h.router.ResolveRoutes(req.Keys, req.ClientAppID)
+	if err != nil {
+		h.writeError(w, errors.Wrap(err, http.StatusNotFound, "no shard found for key"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(BatchRouteResponse{Shards: buckets}); err != nil {
+		h.logger.Error("failed to encode response", zap.Error(err))
+	}
+}
+
+// RefreshCatalog handles forced catalog refresh requests
+// @Summary Force a catalog refresh
+// @Description Reloads the shard catalog immediately and drops connection pools for endpoints no longer in the catalog
+// @Tags router
+// @Produce json
+// @Success 200 {object} map[string]interface{} "Refresh result with shard count"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /api/v1/router/refresh [post]
+func (h *RouterHandler) RefreshCatalog(w http.ResponseWriter, r *http.Request) {
+	shardCount, err := h.router.Refresh(r.Context())
+	if err != nil {
+		h.logger.Error("catalog refresh failed", zap.Error(err))
+		h.writeError(w, errors.Wrap(err, http.StatusInternalServerError, "catalog refresh failed"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":      "refreshed",
+		"shard_count": shardCount,
+	}); err != nil {
+		h.logger.Error("failed to encode response", zap.Error(err))
+	}
+}
+
+// GetThrottle handles throttle lookup requests
+// @Summary Get the emergency throttle
+// @Description Returns the router's global and per-client-app rate limits and whether the kill switch is pausing non-admin traffic
+// @Tags admin
+// @Produce json
+// @Success 200 {object} router.ThrottleSettings "Current throttle settings"
+// @Router /api/v1/router/throttle [get]
+func (h *RouterHandler) GetThrottle(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(h.router.Throttle().Settings()); err != nil {
+		h.logger.Error("failed to encode response", zap.Error(err))
+	}
+}
+
+// UpdateThrottle handles throttle update requests
+// @Summary Set the emergency throttle
+// @Description Replaces the router's rate limits, taking effect on the next request. global_rps caps all traffic and client_rps caps individual client apps, by the username of the token they authenticate with (0 or absent is unlimited); paused is the kill switch that sheds all non-admin traffic with 503 until it is turned off. Admin and health endpoints are never throttled.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param request body router.ThrottleSettings true "Throttle settings"
+// @Success 200 {object} router.ThrottleSettings "Applied throttle settings"
+// @Failure 400 {object} map[string]interface{} "Invalid settings"
+// @Router /api/v1/router/throttle [put]
+func (h *RouterHandler) UpdateThrottle(w http.ResponseWriter, r *http.Request) {
+	var settings router.ThrottleSettings
+	if err := json.NewDecoder(r.Body).Decode(&settings); err != nil {
+		h.writeError(w, errors.Wrap(err, http.StatusBadRequest, "invalid request body"))
+		return
+	}
+
+	throttle := h.router.Throttle()
+	if err := throttle.Update(settings); err != nil {
+		h.writeError(w, errors.Wrap(err, http.StatusBadRequest, err.Error()))
+		return
+	}
+	h.logger.Warn("router throttle updated",
+		zap.Float64("global_rps", settings.GlobalRPS),
+		zap.Int("client_limits", len(settings.ClientRPS)),
+		zap.Bool("paused", settings.Paused),
+		zap.String("reason", settings.Reason))
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(throttle.Settings()); err != nil {
+		h.logger.Error("failed to encode response", zap.Error(err))
+	}
+}
+
+// GetShadowReads handles shadow read lookup requests
+// @Summary Get shadow read results
+// @Description Returns how reads mirrored to the target shards of splits in progress compared with the results served from the source shards, with the most recent mismatches, whose queries and shard keys are client data, so reading them needs the router read permission. percent is 0 while shadow reads are off.
+// @Tags admin
+// @Produce json
+// @Success 200 {object} router.ShadowReadStats "Shadow read results"
+// @Router /api/v1/router/shadow-reads [get]
+func (h *RouterHandler) GetShadowReads(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(h.router.ShadowReadStats()); err != nil {
+		h.logger.Error("failed to encode response", zap.Error(err))
+	}
+}
+
 // writeError writes an error response in a standardized format
 func (h *RouterHandler) writeError(w http.ResponseWriter, err *errors.Error) {
 	w.Header().Set("Content-Type", "application/json")
@@ -170,12 +401,29 @@ func SetupRouterRoutes(router *mux.Router, handler *RouterHandler) {
 				"GET /v1/shard-for-key?key=<key>",
 				"GET /v1/health",
 				"GET /health",
+				"POST /api/v1/router/refresh",
+				"GET /api/v1/router/throttle",
+				"PUT /api/v1/router/throttle",
+				"GET /api/v1/router/shadow-reads",
+				"POST /api/v1/route",
+				"POST /api/v1/route/batch",
 			},
 		})
 	}).Methods("GET", "OPTIONS")
 
 	router.HandleFunc("/v1/execute", handler.ExecuteQuery).Methods("POST", "OPTIONS")
 	router.HandleFunc("/v1/shard-for-key", handler.GetShardForKey).Methods("GET", "OPTIONS")
+	router.HandleFunc("/api/v1/route", handler.ResolveRoute).Methods("POST", "OPTIONS")
+	router.HandleFunc("/api/v1/route/batch", handler.ResolveRoutes).Methods("POST", "OPTIONS")
+
+	// Admin endpoints
+	router.Handle("/api/v1/router/refresh",
+		middleware.RequirePermission(handler.rbac, "router", "refresh")(http.HandlerFunc(handler.RefreshCatalog))).Methods("POST", "OPTIONS")
+	router.HandleFunc("/api/v1/router/throttle", handler.GetThrottle).Methods("GET", "OPTIONS")
+	router.Handle("/api/v1/router/throttle",
+		middleware.RequirePermission(handler.rbac, "router", "throttle")(http.HandlerFunc(handler.UpdateThrottle))).Methods("PUT", "OPTIONS")
+	router.Handle("/api/v1/router/shadow-reads",
+		middleware.RequirePermission(handler.rbac, "router", "read")(http.HandlerFunc(handler.GetShadowReads))).Methods("GET", "OPTIONS")
 
 	// Health endpoint under /v1
 	router.HandleFunc("/v1/health", func(w http.ResponseWriter, r *http.Request) {
@@ -75,3 +75,25 @@ func AuthMiddleware(authManager *security.AuthManager) func(http.Handler) http.H
 		})
 	}
 }
+
+// RequirePermission rejects authenticated requests whose roles lack permission
+// for action on resource. Requests that carry no roles were not authenticated
+// because RBAC is disabled, and pass through like the rest of the API does then.
+func RequirePermission(rbac *security.RBAC, resource, action string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == "OPTIONS" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			roles, authenticated := r.Context().Value("roles").([]string)
+			if authenticated && !rbac.IsAllowed(roles, resource, action) {
+				http.Error(w, `{"error":{"code":"FORBIDDEN","message":"Insufficient permissions"}}`, http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -22,31 +22,47 @@ type corsConfigCache struct {
 // getCORSConfig returns cached CORS configuration
 func getCORSConfig() *corsConfigCache {
 	corsConfigOnce.Do(func() {
-		allowedOriginsEnv := os.Getenv("CORS_ALLOWED_ORIGINS")
-		if allowedOriginsEnv == "" {
-			// Default: allow all in development
-			allowedOriginsEnv = "*"
-		}
-
 		config := &corsConfigCache{}
-		if allowedOriginsEnv == "*" {
-			config.wildcard = true
-		} else {
-			// Parse comma-separated origins
-			origins := strings.Split(allowedOriginsEnv, ",")
-			config.allowedOrigins = make([]string, 0, len(origins))
-			for _, orig := range origins {
-				trimmed := strings.TrimSpace(orig)
-				if trimmed != "" {
-					config.allowedOrigins = append(config.allowedOrigins, trimmed)
-				}
-			}
-		}
+		config.wildcard, config.allowedOrigins = parseCORSOrigins(os.Getenv("CORS_ALLOWED_ORIGINS"))
 		corsConfig = config
 	})
 	return corsConfig
 }
 
+// parseCORSOrigins parses a comma-separated origin list; empty or "*" allows all
+func parseCORSOrigins(value string) (bool, []string) {
+	if value == "" || value == "*" {
+		// Default: allow all in development
+		return true, nil
+	}
+
+	origins := strings.Split(value, ",")
+	allowedOrigins := make([]string, 0, len(origins))
+	for _, orig := range origins {
+		trimmed := strings.TrimSpace(orig)
+		if trimmed != "" {
+			allowedOrigins = append(allowedOrigins, trimmed)
+		}
+	}
+	return false, allowedOrigins
+}
+
+// SetCORSAllowedOrigins replaces the origins allowed to make cross-origin
+// requests, taking effect from the next request. An empty list restores the
+// CORS_ALLOWED_ORIGINS default.
+func SetCORSAllowedOrigins(origins []string) {
+	value := strings.Join(origins, ",")
+	if len(origins) == 0 {
+		value = os.Getenv("CORS_ALLOWED_ORIGINS")
+	}
+	wildcard, allowedOrigins := parseCORSOrigins(value)
+
+	config := getCORSConfig()
+	config.mu.Lock()
+	defer config.mu.Unlock()
+	config.wildcard, config.allowedOrigins = wildcard, allowedOrigins
+}
+
 // isOriginAllowed checks if an origin is allowed (MAANG standard: strict validation)
 func (c *corsConfigCache) isOriginAllowed(origin string) (bool, string) {
 	c.mu.RLock()
@@ -112,7 +128,7 @@ func CORS(next http.Handler) http.Handler {
 				}
 				
 				w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
-				w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Requested-With, Accept, X-CSRF-Token")
+				w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Requested-With, Accept, X-CSRF-Token, X-Request-ID, Accept-Version, X-API-Version")
 				w.Header().Set("Access-Control-Max-Age", "86400") // 24 hours (MAANG standard)
 			}
 			w.WriteHeader(http.StatusNoContent)
@@ -131,8 +147,8 @@ func CORS(next http.Handler) http.Handler {
 
 			// Set CORS headers for cross-origin requests
 			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
-			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Requested-With, Accept, X-CSRF-Token")
-			w.Header().Set("Access-Control-Expose-Headers", "Content-Length, Content-Type, X-Request-ID")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Requested-With, Accept, X-CSRF-Token, X-Request-ID, Accept-Version, X-API-Version")
+			w.Header().Set("Access-Control-Expose-Headers", "Content-Length, Content-Type, X-Request-ID, X-API-Version")
 			w.Header().Set("Access-Control-Max-Age", "86400") // 24 hours (MAANG standard)
 		}
 
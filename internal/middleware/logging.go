@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/sharding-system/pkg/logging"
 	"go.uber.org/zap"
 )
 
@@ -22,7 +23,7 @@ func Logging(logger *zap.Logger) func(http.Handler) http.Handler {
 			next.ServeHTTP(wrapped, r)
 
 			duration := time.Since(start)
-			logger.Info("HTTP request",
+			logging.WithRequestID(r.Context(), logger).Info("HTTP request",
 				zap.String("method", r.Method),
 				zap.String("path", r.URL.Path),
 				zap.String("remote_addr", r.RemoteAddr),
@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/sharding-system/pkg/config"
+)
+
+// MetricsAuth requires the scrape credential of cfg on the metrics endpoint:
+// a bearer token, basic auth, or either when both are set. Without any
+// credential configured every request passes, so Prometheus can scrape
+// without setup.
+func MetricsAuth(cfg config.MetricsAuthConfig) func(http.Handler) http.Handler {
+	bearer := cfg.BearerToken != ""
+	basic := cfg.Username != "" && cfg.Password != ""
+
+	return func(next http.Handler) http.Handler {
+		if !bearer && !basic {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodOptions {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if bearer {
+				if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok && secretEqual(token, cfg.BearerToken) {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+			if basic {
+				if username, password, ok := r.BasicAuth(); ok && secretEqual(username, cfg.Username) && secretEqual(password, cfg.Password) {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+
+			// Tell the scraper which credential to send
+			if basic {
+				w.Header().Add("WWW-Authenticate", `Basic realm="metrics"`)
+			}
+			if bearer {
+				w.Header().Add("WWW-Authenticate", `Bearer realm="metrics"`)
+			}
+			http.Error(w, `{"error":{"code":"UNAUTHORIZED","message":"Missing or invalid metrics credentials"}}`, http.StatusUnauthorized)
+		})
+	}
+}
+
+// secretEqual compares a credential in constant time
+func secretEqual(got, want string) bool {
+	return subtle.ConstantTimeCompare([]byte(got), []byte(want)) == 1
+}
@@ -0,0 +1,85 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sharding-system/pkg/config"
+)
+
+func serveMetrics(cfg config.MetricsAuthConfig, prepare func(*http.Request)) *httptest.ResponseRecorder {
+	metrics := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("shard_queries_total 1\n"))
+	})
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	if prepare != nil {
+		prepare(req)
+	}
+	rec := httptest.NewRecorder()
+	MetricsAuth(cfg)(metrics).ServeHTTP(rec, req)
+	return rec
+}
+
+func TestMetricsAuth_OpenByDefault(t *testing.T) {
+	if rec := serveMetrics(config.MetricsAuthConfig{}, nil); rec.Code != http.StatusOK {
+		t.Fatalf("expected metrics to be open without credentials configured, got %d", rec.Code)
+	}
+}
+
+func TestMetricsAuth_BearerToken(t *testing.T) {
+	cfg := config.MetricsAuthConfig{BearerToken: "scrape-token"}
+
+	rejected := map[string]func(*http.Request){
+		"no credential":   nil,
+		"wrong token":     func(r *http.Request) { r.Header.Set("Authorization", "Bearer other-token") },
+		"wrong scheme":    func(r *http.Request) { r.Header.Set("Authorization", "Token scrape-token") },
+		"basic not setup": func(r *http.Request) { r.SetBasicAuth("prometheus", "scrape-token") },
+	}
+	for name, prepare := range rejected {
+		rec := serveMetrics(cfg, prepare)
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("%s: expected 401, got %d", name, rec.Code)
+		}
+		if got := rec.Header().Get("WWW-Authenticate"); got != `Bearer realm="metrics"` {
+			t.Errorf("%s: expected a bearer challenge, got %q", name, got)
+		}
+	}
+
+	rec := serveMetrics(cfg, func(r *http.Request) { r.Header.Set("Authorization", "Bearer scrape-token") })
+	if rec.Code != http.StatusOK || rec.Body.String() != "shard_queries_total 1\n" {
+		t.Errorf("expected the token to be accepted, got %d %q", rec.Code, rec.Body.String())
+	}
+}
+
+func TestMetricsAuth_BasicAuth(t *testing.T) {
+	cfg := config.MetricsAuthConfig{Username: "prometheus", Password: "s3cret"}
+
+	if rec := serveMetrics(cfg, nil); rec.Code != http.StatusUnauthorized || rec.Header().Get("WWW-Authenticate") != `Basic realm="metrics"` {
+		t.Errorf("expected 401 with a basic challenge, got %d %v", rec.Code, rec.Header())
+	}
+	if rec := serveMetrics(cfg, func(r *http.Request) { r.SetBasicAuth("prometheus", "wrong") }); rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected a wrong password to be rejected, got %d", rec.Code)
+	}
+	if rec := serveMetrics(cfg, func(r *http.Request) { r.SetBasicAuth("grafana", "s3cret") }); rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected a wrong username to be rejected, got %d", rec.Code)
+	}
+	if rec := serveMetrics(cfg, func(r *http.Request) { r.SetBasicAuth("prometheus", "s3cret") }); rec.Code != http.StatusOK {
+		t.Errorf("expected the credentials to be accepted, got %d", rec.Code)
+	}
+}
+
+func TestMetricsAuth_EitherCredential(t *testing.T) {
+	cfg := config.MetricsAuthConfig{BearerToken: "scrape-token", Username: "prometheus", Password: "s3cret"}
+
+	if rec := serveMetrics(cfg, func(r *http.Request) { r.Header.Set("Authorization", "Bearer scrape-token") }); rec.Code != http.StatusOK {
+		t.Errorf("expected the token to be accepted, got %d", rec.Code)
+	}
+	if rec := serveMetrics(cfg, func(r *http.Request) { r.SetBasicAuth("prometheus", "s3cret") }); rec.Code != http.StatusOK {
+		t.Errorf("expected basic auth to be accepted, got %d", rec.Code)
+	}
+	rec := serveMetrics(cfg, nil)
+	if rec.Code != http.StatusUnauthorized || len(rec.Header().Values("WWW-Authenticate")) != 2 {
+		t.Errorf("expected 401 offering both schemes, got %d %v", rec.Code, rec.Header())
+	}
+}
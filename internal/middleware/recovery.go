@@ -3,6 +3,7 @@ package middleware
 import (
 	"net/http"
 
+	"github.com/sharding-system/pkg/logging"
 	"go.uber.org/zap"
 )
 
@@ -12,7 +13,7 @@ func Recovery(logger *zap.Logger) func(http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			defer func() {
 				if err := recover(); err != nil {
-					logger.Error("panic recovered",
+					logging.WithRequestID(r.Context(), logger).Error("panic recovered",
 						zap.Any("error", err),
 						zap.String("method", r.Method),
 						zap.String("path", r.URL.Path),
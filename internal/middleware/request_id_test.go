@@ -0,0 +1,99 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/sharding-system/pkg/logging"
+	"github.com/sharding-system/pkg/security"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestRequestID_PropagatesToContextAndLogs(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	logger := zap.New(core)
+
+	var seen string
+	handler := RequestID(Logging(logger)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = logging.RequestIDFromContext(r.Context())
+		// Downstream components log through the request context
+		logging.WithRequestID(r.Context(), logger).Info("query executed")
+	})))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/shards", nil)
+	req.Header.Set(RequestIDHeader, "client-req-42")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if seen != "client-req-42" {
+		t.Errorf("expected handler to see the client request ID, got %q", seen)
+	}
+	if got := rec.Header().Get(RequestIDHeader); got != "client-req-42" {
+		t.Errorf("expected request ID to be echoed, got %q", got)
+	}
+
+	entries := logs.All()
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 log entries, got %d", len(entries))
+	}
+	for _, entry := range entries {
+		if got := entry.ContextMap()["request_id"]; got != "client-req-42" {
+			t.Errorf("expected %q to carry request_id, got %v", entry.Message, got)
+		}
+	}
+}
+
+func TestRequestID_GeneratesMissingOrInvalidIDs(t *testing.T) {
+	var seen []string
+	handler := RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = append(seen, logging.RequestIDFromContext(r.Context()))
+	}))
+
+	for _, incoming := range []string{"", "bad id\nwith newline"} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if incoming != "" {
+			req.Header.Set(RequestIDHeader, incoming)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		generated := rec.Header().Get(RequestIDHeader)
+		if generated == "" || generated == incoming {
+			t.Errorf("expected a generated request ID for %q, got %q", incoming, generated)
+		}
+		if seen[len(seen)-1] != generated {
+			t.Errorf("expected context and response IDs to match, got %q and %q", seen[len(seen)-1], generated)
+		}
+	}
+	if seen[0] == seen[1] {
+		t.Error("expected each request to get its own ID")
+	}
+}
+
+func TestAuditLogger_LogContextIncludesRequestID(t *testing.T) {
+	path := t.TempDir() + "/audit.log"
+	audit, err := security.NewAuditLogger(path)
+	if err != nil {
+		t.Fatalf("failed to create audit logger: %v", err)
+	}
+	defer audit.Close()
+
+	handler := RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		audit.LogContext(r.Context(), security.AuditEvent{User: "admin", Action: "delete", Resource: "shards", Success: true})
+	}))
+	req := httptest.NewRequest(http.MethodDelete, "/api/v1/shards/s1", nil)
+	req.Header.Set(RequestIDHeader, "audit-req-7")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read audit log: %v", err)
+	}
+	if want := `"request_id":"audit-req-7"`; !strings.Contains(string(data), want) {
+		t.Errorf("expected audit entry to contain %s, got %s", want, data)
+	}
+}
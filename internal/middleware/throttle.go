@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/sharding-system/pkg/router"
+)
+
+// pausedRetryAfter is the Retry-After, in seconds, sent while traffic is paused
+const pausedRetryAfter = "30"
+
+// Throttle sheds requests the router's throttle does not admit: 503 while the
+// kill switch pauses traffic and 429 over a rate limit, both with a
+// Retry-After header. Requests are counted against the global limit and,
+// once AuthMiddleware has authenticated them, the client app named by the
+// token's username; the X-Client-App-ID header is chosen by the caller, so it
+// is not trusted for limits. Requests whose path starts with one of exempt,
+// such as the admin and health endpoints, always pass so operators can still
+// lift the throttle.
+func Throttle(throttle *router.Throttle, exempt ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodOptions || hasAnyPrefix(r.URL.Path, exempt) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			clientAppID, _ := r.Context().Value("username").(string)
+			err := throttle.Allow(clientAppID)
+			if err == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			status, code, retryAfter := http.StatusTooManyRequests, "THROTTLED", "1"
+			if router.IsPaused(err) {
+				status, code, retryAfter = http.StatusServiceUnavailable, "TRAFFIC_PAUSED", pausedRetryAfter
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Retry-After", retryAfter)
+			w.WriteHeader(status)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"error": map[string]interface{}{
+					"code":    code,
+					"message": err.Error(),
+				},
+			})
+		})
+	}
+}
+
+// hasAnyPrefix reports whether path starts with one of prefixes
+func hasAnyPrefix(path string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
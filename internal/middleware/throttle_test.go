@@ -0,0 +1,85 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sharding-system/pkg/router"
+)
+
+func serveThrottled(throttle *router.Throttle, path, clientAppID string) *httptest.ResponseRecorder {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	req := httptest.NewRequest(http.MethodPost, path, nil)
+	if clientAppID != "" {
+		req = req.WithContext(context.WithValue(req.Context(), "username", clientAppID))
+	}
+	rec := httptest.NewRecorder()
+	Throttle(throttle, "/api/v1/router/", "/health")(ok).ServeHTTP(rec, req)
+	return rec
+}
+
+func TestThrottle_ShedsOverLimit(t *testing.T) {
+	throttle := router.NewThrottle()
+	if rec := serveThrottled(throttle, "/v1/execute", "app-a"); rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 before the throttle is set, got %d", rec.Code)
+	}
+
+	throttle.Update(router.ThrottleSettings{ClientRPS: map[string]float64{"app-a": 1}})
+	if rec := serveThrottled(throttle, "/v1/execute", "app-a"); rec.Code != http.StatusOK {
+		t.Fatalf("expected the first request within the limit to pass, got %d", rec.Code)
+	}
+	rec := serveThrottled(throttle, "/v1/execute", "app-a")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429 over the limit, got %d", rec.Code)
+	}
+	if rec.Header().Get("Retry-After") == "" || !strings.Contains(rec.Body.String(), "THROTTLED") {
+		t.Errorf("expected a Retry-After header and THROTTLED error, got %v %q", rec.Header(), rec.Body.String())
+	}
+	if rec := serveThrottled(throttle, "/v1/execute", "app-b"); rec.Code != http.StatusOK {
+		t.Errorf("expected other client apps to pass, got %d", rec.Code)
+	}
+}
+
+func TestThrottle_KillSwitchSparesAdminEndpoints(t *testing.T) {
+	throttle := router.NewThrottle()
+	throttle.Update(router.ThrottleSettings{Paused: true})
+
+	rec := serveThrottled(throttle, "/v1/execute", "app-a")
+	if rec.Code != http.StatusServiceUnavailable || !strings.Contains(rec.Body.String(), "TRAFFIC_PAUSED") {
+		t.Fatalf("expected 503 TRAFFIC_PAUSED while paused, got %d %q", rec.Code, rec.Body.String())
+	}
+	for _, path := range []string{"/api/v1/router/throttle", "/health"} {
+		if rec := serveThrottled(throttle, path, ""); rec.Code != http.StatusOK {
+			t.Errorf("expected %s to stay reachable while paused, got %d", path, rec.Code)
+		}
+	}
+
+	throttle.Update(router.ThrottleSettings{})
+	if rec := serveThrottled(throttle, "/v1/execute", "app-a"); rec.Code != http.StatusOK {
+		t.Errorf("expected traffic to resume, got %d", rec.Code)
+	}
+}
+
+func TestThrottle_IgnoresClaimedClientApp(t *testing.T) {
+	throttle := router.NewThrottle()
+	throttle.Update(router.ThrottleSettings{ClientRPS: map[string]float64{"app-a": 1}})
+	if rec := serveThrottled(throttle, "/v1/execute", "app-a"); rec.Code != http.StatusOK {
+		t.Fatalf("expected the first request within the limit to pass, got %d", rec.Code)
+	}
+
+	// A client naming another app in the header still counts against its own
+	// limit
+	req := httptest.NewRequest(http.MethodPost, "/v1/execute", nil)
+	req.Header.Set("X-Client-App-ID", "app-b")
+	req = req.WithContext(context.WithValue(req.Context(), "username", "app-a"))
+	rec := httptest.NewRecorder()
+	Throttle(throttle)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).ServeHTTP(rec, req)
+	if rec.Code != http.StatusTooManyRequests {
+		t.Errorf("expected app-a to stay limited whatever header it sends, got %d", rec.Code)
+	}
+}
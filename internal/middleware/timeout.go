@@ -0,0 +1,133 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Timeout middleware bounds how long a handler may run. The handler's context
+// is cancelled at the deadline and the client gets a 504 with a JSON error, so
+// a slow handler cannot hold a connection indefinitely. Streaming requests
+// (server-sent events and WebSocket upgrades) are long-lived by design and are
+// not subject to the timeout, and neither are requests matched to a route
+// whose path template is in exempt, such as long-polls and downloads; their
+// responses go straight to the client instead of being buffered. A
+// non-positive timeout disables the middleware.
+func Timeout(timeout time.Duration, exempt ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if timeout <= 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if isStreamingRequest(r) || isExemptRoute(r, exempt) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+
+			tw := &timeoutWriter{header: make(http.Header), statusCode: http.StatusOK}
+			done := make(chan struct{})
+			panicCh := make(chan interface{}, 1)
+			go func() {
+				defer func() {
+					if p := recover(); p != nil {
+						panicCh <- p
+					}
+				}()
+				next.ServeHTTP(tw, r.WithContext(ctx))
+				close(done)
+			}()
+
+			select {
+			case p := <-panicCh:
+				// Re-panic on the serving goroutine so Recovery handles it
+				panic(p)
+			case <-done:
+				tw.mu.Lock()
+				defer tw.mu.Unlock()
+				for key, values := range tw.header {
+					w.Header()[key] = values
+				}
+				w.WriteHeader(tw.statusCode)
+				w.Write(tw.buf.Bytes())
+			case <-ctx.Done():
+				tw.mu.Lock()
+				defer tw.mu.Unlock()
+				tw.timedOut = true
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusGatewayTimeout)
+				w.Write([]byte(`{"error":{"code":"REQUEST_TIMEOUT","message":"Request exceeded the ` + timeout.String() + ` timeout"}}` + "\n"))
+			}
+		})
+	}
+}
+
+// isStreamingRequest reports whether r opens a long-lived stream
+func isStreamingRequest(r *http.Request) bool {
+	if strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		return true
+	}
+	return strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+}
+
+// isExemptRoute reports whether r was matched to a route whose path template
+// is one of exempt
+func isExemptRoute(r *http.Request, exempt []string) bool {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return false
+	}
+	template, err := route.GetPathTemplate()
+	if err != nil {
+		return false
+	}
+	for _, path := range exempt {
+		if template == path {
+			return true
+		}
+	}
+	return false
+}
+
+// timeoutWriter buffers a handler's response until it completes, and discards
+// anything written after the request timed out
+type timeoutWriter struct {
+	mu         sync.Mutex
+	header     http.Header
+	buf        bytes.Buffer
+	statusCode int
+	wroteHead  bool
+	timedOut   bool
+}
+
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.header
+}
+
+func (tw *timeoutWriter) WriteHeader(code int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut || tw.wroteHead {
+		return
+	}
+	tw.wroteHead = true
+	tw.statusCode = code
+}
+
+func (tw *timeoutWriter) Write(p []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	tw.wroteHead = true
+	return tw.buf.Write(p)
+}
@@ -0,0 +1,115 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func TestTimeout_CutsOffSlowHandler(t *testing.T) {
+	handlerErr := make(chan error, 1)
+	slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+			handlerErr <- r.Context().Err()
+		case <-time.After(5 * time.Second):
+			handlerErr <- nil
+		}
+		w.Write([]byte("too late"))
+	})
+
+	start := time.Now()
+	rec := httptest.NewRecorder()
+	Timeout(50*time.Millisecond)(slow).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v1/execute", nil))
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected the request to be cut off at the timeout, took %v", elapsed)
+	}
+	if rec.Code != http.StatusGatewayTimeout {
+		t.Errorf("expected 504, got %d", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("expected a JSON error, got content type %q", ct)
+	}
+	if !strings.Contains(rec.Body.String(), "REQUEST_TIMEOUT") || strings.Contains(rec.Body.String(), "too late") {
+		t.Errorf("unexpected body %q", rec.Body.String())
+	}
+
+	select {
+	case err := <-handlerErr:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected the handler context to be cancelled, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("handler context was not cancelled")
+	}
+}
+
+func TestTimeout_PassesFastHandlerThrough(t *testing.T) {
+	fast := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Shard-ID", "shard1")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"ok":true}`))
+	})
+
+	rec := httptest.NewRecorder()
+	Timeout(time.Second)(fast).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if rec.Code != http.StatusCreated || rec.Body.String() != `{"ok":true}` || rec.Header().Get("X-Shard-ID") != "shard1" {
+		t.Errorf("expected the handler response unchanged, got %d %q %v", rec.Code, rec.Body.String(), rec.Header())
+	}
+}
+
+func TestTimeout_ExemptsStreamingRequests(t *testing.T) {
+	stream := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.Context().Deadline(); ok {
+			t.Error("expected streaming requests to have no deadline")
+		}
+		time.Sleep(100 * time.Millisecond)
+		w.Write([]byte("data: ok\n\n"))
+	})
+
+	for _, header := range [][2]string{{"Accept", "text/event-stream"}, {"Upgrade", "websocket"}} {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/events", nil)
+		req.Header.Set(header[0], header[1])
+		rec := httptest.NewRecorder()
+		Timeout(20*time.Millisecond)(stream).ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusOK {
+			t.Errorf("expected %s stream to run past the timeout, got %d", header[1], rec.Code)
+		}
+	}
+}
+
+func TestTimeout_ExemptsListedRoutes(t *testing.T) {
+	var deadlines []bool
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, ok := r.Context().Deadline()
+		deadlines = append(deadlines, ok)
+		w.Write([]byte("ok"))
+	})
+
+	router := mux.NewRouter()
+	router.Use(Timeout(time.Second, "/api/v1/client-apps/{id}/shard-map"))
+	protected := router.PathPrefix("/").Subrouter()
+	protected.Handle("/api/v1/client-apps/{id}/shard-map", handler)
+	protected.Handle("/api/v1/client-apps/{id}", handler)
+
+	for _, path := range []string{"/api/v1/client-apps/app1/shard-map", "/api/v1/client-apps/app1"} {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d", path, rec.Code)
+		}
+	}
+
+	if len(deadlines) != 2 || deadlines[0] || !deadlines[1] {
+		t.Errorf("expected only the listed route to run without a deadline, got %v", deadlines)
+	}
+}
@@ -0,0 +1,109 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+const (
+	// APIVersionHeader selects the payload shape of a request and response,
+	// and carries the version served back to the client
+	APIVersionHeader = "X-API-Version"
+	// AcceptVersionHeader selects the payload shape like X-API-Version and
+	// takes precedence over it
+	AcceptVersionHeader = "Accept-Version"
+
+	// APIVersion1 is the original payload shape
+	APIVersion1 = "v1"
+	// APIVersion2 changes the payload shape of the endpoints that document a
+	// v2 shape; so far only GET /api/v1/databases/templates, which wraps its
+	// list in an object. Every other endpoint serves its v1 shape under v2.
+	APIVersion2 = "v2"
+
+	// DefaultAPIVersion is served when the client names no version and none
+	// is configured
+	DefaultAPIVersion = APIVersion1
+)
+
+// SupportedAPIVersions lists the payload shapes the API can serve
+var SupportedAPIVersions = []string{APIVersion1, APIVersion2}
+
+type apiVersionKey struct{}
+
+// ParseAPIVersion normalizes a requested version such as "2", "v2" or "V2.0"
+// and reports whether it is supported
+func ParseAPIVersion(version string) (string, bool) {
+	version = strings.ToLower(strings.TrimSpace(version))
+	version = strings.TrimSuffix(strings.TrimPrefix(version, "v"), ".0")
+	if version == "" {
+		return "", false
+	}
+	version = "v" + version
+	for _, supported := range SupportedAPIVersions {
+		if version == supported {
+			return version, true
+		}
+	}
+	return "", false
+}
+
+// APIVersion middleware negotiates the payload shape handlers serve from the
+// Accept-Version or X-API-Version header, falling back to defaultVersion, or
+// to v1 when that is empty or unsupported. The negotiated version is stored in
+// the request context and echoed in X-API-Version; an unsupported requested
+// version is refused with a 406 listing the supported ones.
+func APIVersion(defaultVersion string) func(http.Handler) http.Handler {
+	fallback, ok := ParseAPIVersion(defaultVersion)
+	if !ok {
+		fallback = DefaultAPIVersion
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requested := r.Header.Get(AcceptVersionHeader)
+			if requested == "" {
+				requested = r.Header.Get(APIVersionHeader)
+			}
+
+			version := fallback
+			if requested != "" {
+				var ok bool
+				if version, ok = ParseAPIVersion(requested); !ok {
+					w.Header().Set("Content-Type", "application/json")
+					w.WriteHeader(http.StatusNotAcceptable)
+					w.Write([]byte(`{"error":{"code":"UNSUPPORTED_API_VERSION","message":"API version ` + quoteVersion(requested) +
+						` is not supported; supported versions are ` + strings.Join(SupportedAPIVersions, ", ") + `"}}` + "\n"))
+					return
+				}
+			}
+
+			w.Header().Set(APIVersionHeader, version)
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), apiVersionKey{}, version)))
+		})
+	}
+}
+
+// APIVersionFromContext returns the version negotiated for a request, or v1
+// when the request did not pass through the APIVersion middleware
+func APIVersionFromContext(ctx context.Context) string {
+	if version, ok := ctx.Value(apiVersionKey{}).(string); ok {
+		return version
+	}
+	return DefaultAPIVersion
+}
+
+// quoteVersion quotes a client-supplied version for a JSON message, keeping
+// only characters that are safe inside it
+func quoteVersion(version string) string {
+	if len(version) > 16 {
+		version = version[:16]
+	}
+	safe := strings.Map(func(c rune) rune {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '.', c == '-', c == '_':
+			return c
+		}
+		return -1
+	}, version)
+	return `'` + safe + `'`
+}
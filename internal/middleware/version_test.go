@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAPIVersion_Negotiation(t *testing.T) {
+	var seen string
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = APIVersionFromContext(r.Context())
+	})
+
+	cases := []struct {
+		name           string
+		defaultVersion string
+		headers        map[string]string
+		wantStatus     int
+		wantVersion    string
+	}{
+		{name: "default", wantStatus: http.StatusOK, wantVersion: "v1"},
+		{name: "configured default", defaultVersion: "v2", wantStatus: http.StatusOK, wantVersion: "v2"},
+		{name: "unsupported default falls back to v1", defaultVersion: "v7", wantStatus: http.StatusOK, wantVersion: "v1"},
+		{name: "x-api-version", headers: map[string]string{APIVersionHeader: "V2"}, wantStatus: http.StatusOK, wantVersion: "v2"},
+		{name: "accept-version", headers: map[string]string{AcceptVersionHeader: "2.0"}, wantStatus: http.StatusOK, wantVersion: "v2"},
+		{name: "accept-version wins", defaultVersion: "v2", headers: map[string]string{AcceptVersionHeader: "1", APIVersionHeader: "2"}, wantStatus: http.StatusOK, wantVersion: "v1"},
+		{name: "unsupported", headers: map[string]string{APIVersionHeader: "v3"}, wantStatus: http.StatusNotAcceptable},
+		{name: "garbage", headers: map[string]string{AcceptVersionHeader: "latest\""}, wantStatus: http.StatusNotAcceptable},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			seen = ""
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/shards", nil)
+			for key, value := range c.headers {
+				req.Header.Set(key, value)
+			}
+			rec := httptest.NewRecorder()
+			APIVersion(c.defaultVersion)(next).ServeHTTP(rec, req)
+
+			if rec.Code != c.wantStatus {
+				t.Fatalf("expected status %d, got %d: %s", c.wantStatus, rec.Code, rec.Body.String())
+			}
+			if seen != c.wantVersion {
+				t.Errorf("expected handler to see %q, got %q", c.wantVersion, seen)
+			}
+			if c.wantVersion != "" && rec.Header().Get(APIVersionHeader) != c.wantVersion {
+				t.Errorf("expected %q to be echoed, got %q", c.wantVersion, rec.Header().Get(APIVersionHeader))
+			}
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/sharding-system/pkg/config"
+	"github.com/sharding-system/pkg/listener"
+)
+
+// newHTTPServer creates an API server with the timeouts and limits in cfg
+func newHTTPServer(cfg config.ServerConfig, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:           fmt.Sprintf("%s:%d", cfg.Host, cfg.Port),
+		Handler:        handler,
+		ReadTimeout:    cfg.ReadTimeout,
+		WriteTimeout:   cfg.WriteTimeout,
+		IdleTimeout:    cfg.IdleTimeout,
+		MaxHeaderBytes: cfg.MaxHeaderBytes,
+	}
+}
+
+// tcpOptions returns the listener tuning in cfg
+func tcpOptions(cfg config.ServerConfig) listener.TCPOptions {
+	return listener.TCPOptions{
+		KeepAlive: cfg.TCPKeepAlive,
+	}
+}
+
+// serveTCP serves server on its address using a listener tuned by opts.
+// listening, if set, is called once the address accepts connections.
+func serveTCP(server *http.Server, opts listener.TCPOptions, listening func()) error {
+	l, err := listener.TCP(server.Addr, opts)
+	if err != nil {
+		return err
+	}
+	if listening != nil {
+		listening()
+	}
+	return server.Serve(l)
+}
@@ -0,0 +1,37 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/sharding-system/pkg/config"
+)
+
+func TestNewHTTPServer_UsesConfiguredValues(t *testing.T) {
+	cfg := config.ServerConfig{
+		Host:           "127.0.0.1",
+		Port:           8081,
+		ReadTimeout:    5 * time.Second,
+		WriteTimeout:   10 * time.Second,
+		IdleTimeout:    90 * time.Second,
+		TCPKeepAlive:   45 * time.Second,
+		MaxHeaderBytes: 64 << 10,
+	}
+
+	server := newHTTPServer(cfg, http.NotFoundHandler())
+	if server.Addr != "127.0.0.1:8081" {
+		t.Errorf("expected address 127.0.0.1:8081, got %s", server.Addr)
+	}
+	if server.ReadTimeout != 5*time.Second || server.WriteTimeout != 10*time.Second || server.IdleTimeout != 90*time.Second {
+		t.Errorf("unexpected timeouts: read %v, write %v, idle %v", server.ReadTimeout, server.WriteTimeout, server.IdleTimeout)
+	}
+	if server.MaxHeaderBytes != 64<<10 {
+		t.Errorf("expected max header bytes %d, got %d", 64<<10, server.MaxHeaderBytes)
+	}
+
+	opts := tcpOptions(cfg)
+	if opts.KeepAlive != 45*time.Second {
+		t.Errorf("expected keepalive 45s, got %+v", opts)
+	}
+}
@@ -2,10 +2,13 @@ package server
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"time"
 
 	"github.com/gorilla/mux"
@@ -18,12 +21,16 @@ import (
 	"github.com/sharding-system/pkg/catalog"
 	"github.com/sharding-system/pkg/config"
 	"github.com/sharding-system/pkg/database"
+	"github.com/sharding-system/pkg/discovery"
 	"github.com/sharding-system/pkg/failover"
 	"github.com/sharding-system/pkg/health"
+	"github.com/sharding-system/pkg/listener"
 	"github.com/sharding-system/pkg/manager"
 	"github.com/sharding-system/pkg/models"
 	"github.com/sharding-system/pkg/monitoring"
+	"github.com/sharding-system/pkg/notify"
 	"github.com/sharding-system/pkg/operator"
+	"github.com/sharding-system/pkg/privacy"
 	"github.com/sharding-system/pkg/scanner"
 	"github.com/sharding-system/pkg/schema"
 	"github.com/sharding-system/pkg/security"
@@ -38,6 +45,8 @@ import (
 // ManagerServer represents the manager HTTP server
 type ManagerServer struct {
 	server           *http.Server
+	tcp              listener.TCPOptions
+	socketPath       string
 	logger           *zap.Logger
 	healthController *health.Controller
 	backupService    *backup.BackupService
@@ -45,89 +54,77 @@ type ManagerServer struct {
 	loadMonitor      *monitoring.LoadMonitor
 	autoSplitter     *autoscale.AutoSplitter
 	branchService    *branch.BranchService
+	auditLogger      *security.AuditLogger
 	monitorCtx       context.Context
 	monitorCancel    context.CancelFunc
 	splitterCtx      context.Context
 	splitterCancel   context.CancelFunc
-}
+	ready            *readinessGate // Gates registration of existing shards
 
-// buildDSNFromShard builds a PostgreSQL DSN from shard connection details
-func buildDSNFromShard(shard *models.Shard) string {
-	// If PrimaryEndpoint is provided and is a full connection string, use it
-	if shard.PrimaryEndpoint != "" {
-		// Check if it's already a DSN format (starts with postgres:// or postgresql://)
-		if len(shard.PrimaryEndpoint) > 10 && (shard.PrimaryEndpoint[:10] == "postgres://" || shard.PrimaryEndpoint[:14] == "postgresql://") {
-			return shard.PrimaryEndpoint
-		}
-	}
+	registrationCancel context.CancelFunc // Stops registering existing shards
+	reaperCancel       context.CancelFunc // Stops failing databases stuck creating
 
-	// Build DSN from individual connection details
-	if shard.Host == "" || shard.Database == "" {
-		return ""
-	}
-
-	port := shard.Port
-	if port == 0 {
-		port = 5432 // Default PostgreSQL port
-	}
+	// Applied on configuration reloads
+	protectedRouter        *mux.Router
+	prometheusCollector    *monitoring.PrometheusCollector
+	postgresStatsCollector *monitoring.PostgresStatsCollector
+}
 
-	dsn := fmt.Sprintf("host=%s port=%d dbname=%s", shard.Host, port, shard.Database)
+// buildDSNFromShard builds the DSN the collectors connect to a shard with
+func buildDSNFromShard(shard *models.Shard) string {
+	return shard.CollectorConnectionString(monitoring.CollectorApplicationName)
+}
 
-	if shard.Username != "" {
-		dsn += fmt.Sprintf(" user=%s", shard.Username)
+// setupCatalogEventRoutes registers the catalog event log endpoint when the
+// catalog implementation records mutation events, and the snapshot endpoints
+// when it stores snapshots
+func setupCatalogEventRoutes(router *mux.Router, cat catalog.Catalog, logger *zap.Logger) {
+	eventSource, ok := cat.(interface{ EventLog() catalog.EventLog })
+	if !ok {
+		logger.Info("catalog does not record events, skipping catalog event routes")
+		return
 	}
-
-	if shard.Password != "" {
-		dsn += fmt.Sprintf(" password=%s", shard.Password)
+	catalogHandler := api.NewCatalogHandler(eventSource.EventLog(), logger)
+	if snapshotSource, ok := cat.(interface{ Snapshots() catalog.SnapshotStore }); ok {
+		catalogHandler.SetSnapshots(cat, snapshotSource.Snapshots())
 	}
-
-	dsn += " sslmode=prefer connect_timeout=10"
-
-	return dsn
+	catalogHandler.RegisterRoutes(router)
 }
 
-// registerExistingShardsForMetrics registers all existing active shards with the Prometheus collector
-func registerExistingShardsForMetrics(
-	shardManager *manager.Manager,
-	prometheusCollector *monitoring.PrometheusCollector,
-	logger *zap.Logger,
-) {
-	shards, err := shardManager.ListShards()
-	if err != nil {
-		logger.Warn("failed to list shards for metrics registration", zap.Error(err))
-		return
+// databaseTemplates builds the registry of database templates, persisting
+// custom templates in the catalog's etcd when it has one. An unknown default
+// template is logged and the built-in default kept.
+func databaseTemplates(cat catalog.Catalog, defaultTemplate string, logger *zap.Logger) *database.TemplateRegistry {
+	var store database.TemplateStore = database.NewMemoryTemplateStore()
+	if etcdCat, ok := cat.(*catalog.EtcdCatalog); ok {
+		store = database.NewEtcdTemplateStore(etcdCat.GetEtcdClient())
 	}
+	registry := database.NewTemplateRegistry(store)
 
-	registeredCount := 0
-	for _, shard := range shards {
-		if shard.Status != "active" {
-			continue
-		}
-
-		dsn := buildDSNFromShard(&shard)
-		if dsn == "" {
-			logger.Debug("skipping shard - no connection details available",
-				zap.String("shard_id", shard.ID),
-				zap.String("shard_name", shard.Name))
-			continue
-		}
-
-		if err := prometheusCollector.RegisterShard(shard.ID, dsn); err != nil {
-			logger.Warn("failed to register existing shard for metrics",
-				zap.String("shard_id", shard.ID),
-				zap.String("shard_name", shard.Name),
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := registry.Load(ctx); err != nil {
+		logger.Error("failed to load custom database templates", zap.Error(err))
+	}
+	if defaultTemplate != "" {
+		if err := registry.SetDefault(defaultTemplate); err != nil {
+			logger.Warn("configured default database template is unknown, keeping the built-in default",
+				zap.String("template", defaultTemplate),
+				zap.String("default", registry.Default()),
 				zap.Error(err))
-		} else {
-			registeredCount++
-			logger.Info("registered existing shard for metrics collection",
-				zap.String("shard_id", shard.ID),
-				zap.String("shard_name", shard.Name))
 		}
 	}
+	return registry
+}
 
-	logger.Info("completed registration of existing shards for metrics",
-		zap.Int("total_shards", len(shards)),
-		zap.Int("registered", registeredCount))
+// scanHistory keeps scan results in the catalog's etcd, or in memory when the
+// catalog is not etcd
+func scanHistory(cat catalog.Catalog) *scanner.ScanHistory {
+	var store scanner.HistoryStore = scanner.NewMemoryHistoryStore()
+	if etcdCat, ok := cat.(*catalog.EtcdCatalog); ok {
+		store = scanner.NewEtcdHistoryStore(etcdCat.GetEtcdClient())
+	}
+	return scanner.NewScanHistory(store, scanner.DefaultHistoryRetention)
 }
 
 // NewManagerServer creates a new manager server instance
@@ -143,10 +140,25 @@ func NewManagerServer(
 
 	// Note: Stats collector will be set later after initialization
 
+	// Existing shards are registered with the collectors once the server is
+	// listening and both collectors are running
+	ready := newReadinessGate(readyListening, readyMetricsCollector, readyStatsCollector)
+
 	// Initialize Prometheus collector for metrics (needed before setting up handlers)
-	prometheusCollector := monitoring.NewPrometheusCollector(logger, 30*time.Second)
+	prometheusCollector, err := monitoring.NewPrometheusCollectorWithBuckets(logger, cfg.Observability.CollectionInterval, monitoring.HistogramBuckets{
+		QueryDuration: cfg.Observability.QueryDurationBuckets,
+		RouterLatency: cfg.Observability.RouterLatencyBuckets,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Prometheus collector: %w", err)
+	}
+	prometheusCollector.SetSlowQueryThreshold(cfg.Observability.SlowQueryThreshold)
+	prometheusCollector.SetMaxTableSeries(cfg.Observability.MaxTableSeries)
+	prometheusCollector.SetPoolSize(collectorPoolSize(cfg.Observability.MetricsCollectorPool))
+	prometheusCollector.SetEndpointResolver(monitoring.NewCatalogResolver(catalog), monitoring.DefaultReconnectAfter)
 	prometheusCtx, prometheusCancel := context.WithCancel(context.Background())
 	go prometheusCollector.Start(prometheusCtx)
+	ready.follow(readyMetricsCollector, prometheusCollector.Started())
 	logger.Info("Prometheus collector started")
 	_ = prometheusCancel // Will be used in shutdown
 
@@ -154,8 +166,11 @@ func NewManagerServer(
 	managerHandler.SetPrometheusCollector(prometheusCollector)
 
 	// Initialize auth manager
-	// JWT_SECRET is required if RBAC is enabled, optional for development
-	jwtSecret := os.Getenv("JWT_SECRET")
+	// A JWT secret is required if RBAC is enabled, optional for development
+	jwtSecret := cfg.Security.JWTSecret
+	if jwtSecret == "" {
+		jwtSecret = os.Getenv("JWT_SECRET")
+	}
 	if jwtSecret == "" {
 		if cfg.Security.EnableRBAC {
 			logger.Fatal("JWT_SECRET environment variable is required when RBAC is enabled")
@@ -164,7 +179,7 @@ func NewManagerServer(
 		jwtSecret = "development-secret-not-for-production-use-min-32-chars"
 		logger.Warn("JWT_SECRET not set - using development secret. Set JWT_SECRET in production!")
 	}
-	if len(jwtSecret) < 32 {
+	if len(jwtSecret) < config.MinJWTSecretLength {
 		logger.Fatal("JWT_SECRET must be at least 32 characters for security")
 	}
 	authManager := security.NewAuthManager(jwtSecret)
@@ -210,16 +225,30 @@ func NewManagerServer(
 	api.SetupAuthRoutes(muxRouter, authHandler)
 
 	// Apply middleware - CORS must be first to ensure headers are set
+	if len(cfg.Security.CORSAllowedOrigins) > 0 {
+		middleware.SetCORSAllowedOrigins(cfg.Security.CORSAllowedOrigins)
+	}
 	muxRouter.Use(middleware.CORS)
+	muxRouter.Use(middleware.RequestID)
 	muxRouter.Use(middleware.Recovery(logger))
 	muxRouter.Use(middleware.Logging(logger))
 
 	// Request size limit (10MB default)
 	muxRouter.Use(middleware.RequestSizeLimit(middleware.DefaultMaxRequestSize))
 
+	// Bound handler run time; streaming endpoints, the shard-map long-poll and
+	// downloads are exempt
+	muxRouter.Use(middleware.Timeout(cfg.Server.RequestTimeout,
+		"/api/v1/client-apps/{id}/shard-map",
+		"/api/v1/client-apps/{id}/data-export",
+		"/api/v1/stats/snapshot"))
+
 	// Content-Type validation for POST/PUT/PATCH requests
 	muxRouter.Use(middleware.ContentTypeValidation([]string{"application/json"}))
 
+	// Negotiate the payload shape from Accept-Version / X-API-Version
+	muxRouter.Use(middleware.APIVersion(cfg.Server.DefaultAPIVersion))
+
 	// Enable auth middleware if RBAC is enabled in config
 	var protectedRouter *mux.Router
 	if cfg.Security.EnableRBAC {
@@ -236,10 +265,28 @@ func NewManagerServer(
 	dbScanner := scanner.NewDatabaseScanner(logger)
 	multiClusterScanner := scanner.NewMultiClusterScanner(clusterManager, dbScanner, logger)
 
+	// Check the namespace and cluster client apps are registered with
+	namespaceValidator, err := manager.NewNamespaceValidator(clusterClients(clusterManager), cfg.ClientApps.NamespaceValidation, logger)
+	if err != nil {
+		return nil, err
+	}
+	shardManager.GetClientAppManager().SetNamespaceValidator(namespaceValidator)
+	discoveryFilter, err := discovery.NewFilter(cfg.ClientApps.DiscoveryFilter, logger)
+	if err != nil {
+		return nil, err
+	}
+	managerHandler.SetDiscoveryFilter(discoveryFilter)
+
 	// Initialize database service (simplified database creation)
 	dbService := database.NewDatabaseService(shardManager, logger, cfg.Server.Host, cfg.Server.Port)
+	templates := databaseTemplates(catalog, cfg.Sharding.DefaultDatabaseTemplate, logger)
+	dbService.SetTemplates(templates)
 	databaseHandler := api.NewDatabaseHandler(dbService, clusterManager, multiClusterScanner, logger)
 	databaseHandler.SetManager(shardManager) // Set manager to access client apps
+	databaseHandler.SetScanHistory(scanHistory(catalog))
+
+	// Announce backup, branch and auto-split outcomes to the configured notifiers
+	notifier := notifierFromEnv()
 
 	// Initialize backup service
 	backupStoragePath := os.Getenv("BACKUP_STORAGE_PATH")
@@ -247,6 +294,15 @@ func NewManagerServer(
 		backupStoragePath = filepath.Join(os.TempDir(), "sharding-backups")
 	}
 	backupService := backup.NewBackupService(backupStoragePath, logger)
+	if value := os.Getenv("BACKUP_MAX_CONCURRENT"); value != "" {
+		// Overlapping schedules queue instead of dumping and uploading all at once
+		if n, err := strconv.Atoi(value); err == nil && n > 0 {
+			backupService.SetMaxConcurrent(n)
+		} else {
+			logger.Warn("ignoring invalid BACKUP_MAX_CONCURRENT", zap.String("value", value))
+		}
+	}
+	backupService.SetNotifier(notifier)
 	backupService.Start()
 	backupHandler := api.NewBackupHandler(backupService, logger)
 
@@ -257,6 +313,7 @@ func NewManagerServer(
 		logger,
 		10*time.Second, // Check every 10 seconds
 	)
+	failoverCtrl.SetSwitchover(failover.NewPostgresSwitchover(logger, failover.DefaultDrainTimeout))
 	failoverCtrl.Start()
 	failoverHandler := api.NewFailoverHandler(failoverCtrl, logger)
 
@@ -266,6 +323,12 @@ func NewManagerServer(
 	go loadMonitor.Start(monitorCtx)
 	logger.Info("load monitor started")
 
+	// Place new shards by host capacity when placement hosts are configured
+	if len(cfg.Sharding.PlacementHosts) > 0 {
+		shardManager.SetPlacementStrategy(manager.NewWeightedPlacement(cfg.Sharding.PlacementHosts, loadMonitor))
+		logger.Info("weighted shard placement enabled", zap.Int("hosts", len(cfg.Sharding.PlacementHosts)))
+	}
+
 	// Initialize Phase 2 services: Hot Shard Detector
 	thresholds := autoscale.DefaultThresholds()
 	hotShardDetector := autoscale.NewHotShardDetector(loadMonitor, thresholds, logger)
@@ -273,6 +336,7 @@ func NewManagerServer(
 
 	// Initialize Phase 2 services: Auto-Splitter
 	autoSplitter := autoscale.NewAutoSplitter(hotShardDetector, shardManager, catalog, logger)
+	autoSplitter.SetNotifier(notifier)
 	splitterCtx, splitterCancel := context.WithCancel(context.Background())
 	go autoSplitter.Start(splitterCtx)
 	logger.Info("auto-splitter started")
@@ -287,37 +351,106 @@ func NewManagerServer(
 	if err != nil {
 		logger.Warn("failed to initialize kubernetes operator, branch service will be limited", zap.Error(err))
 		op = nil // Will need to handle nil operator
+	} else {
+		// Promote replicas outside the failed primary's zone
+		failoverCtrl.SetZoneResolver(op.ZoneResolver())
+		if cfg.Sharding.FailoverOnDrain {
+			// Move primaries off nodes being drained before they are evicted
+			failoverCtrl.SetDrainDetector(op.ZoneResolver())
+		}
+		// Shards running out of storage may grow their volumes instead of splitting
+		expansion := cfg.Sharding.StorageExpansion
+		op.SetRequireVolumeExpansion(expansion.Enabled)
+		autoSplitter.SetStorageExpansion(op, autoscale.StorageExpansionPolicy{
+			Automatic:     expansion.Enabled,
+			GrowthPercent: expansion.GrowthPercent,
+			MaxSize:       expansion.MaxSize,
+		})
 	}
 	schemaManager := schema.NewManager(logger)
-	dbController := database.NewController(logger, op, schemaManager, namespace)
+	var provisioner operator.Provisioner
+	if os.Getenv("SHARDING_DEV_MODE") == "true" {
+		// Run shards as local PostgreSQL containers instead of in Kubernetes
+		provisioner = operator.NewLocalOperator(operator.NewDockerRuntime(os.Getenv("SHARDING_DEV_POSTGRES_IMAGE"), logger), logger)
+		logger.Info("dev mode enabled, databases are provisioned as local containers")
+	} else if op != nil {
+		provisioner = op
+	}
+	dbController := database.NewController(logger, provisioner, schemaManager, namespace)
+	dbController.SetTemplates(templates)
+	dbController.SetProvisionTimeout(cfg.Sharding.ProvisioningTimeout)
+	reaperCtx, reaperCancel := context.WithCancel(context.Background())
+	if provisioner != nil {
+		go dbController.StartReaper(reaperCtx)
+	}
+	// Databases may keep their backups in their own object storage
+	backupService.SetStorageResolver(dbController)
+	backupService.SetConnectionResolver(dbController)
+	// Track each shard's last backup, for the status endpoints and for
+	// alerting on shards whose backups stopped succeeding
+	backupService.SetShardResolver(backup.ShardResolvers{dbController, databaseHandler})
+	backupService.SetOnBackupFinished(dbController.RecordBackup)
+	if err := backupService.LoadBackups(); err != nil {
+		logger.Warn("failed to load recorded backups, shard backup status starts empty", zap.Error(err))
+	}
+	databaseHandler.SetBackupStatuses(backupService)
+	prometheusCollector.MustRegister(backupService.Metrics())
 	branchService := branch.NewBranchService(backupService, dbController, op, logger)
+	branchService.SetNotifier(notifier)
 	logger.Info("branch service initialized")
 
 	// Create API handlers for Phase 2
 	autoscaleHandler := api.NewAutoscaleHandler(hotShardDetector, autoSplitter, logger)
+	if op != nil {
+		autoscaleHandler.SetShardResizer(op)
+	}
 	metricsHandler := api.NewMetricsHandler(loadMonitor, logger)
 	branchHandler := api.NewBranchHandler(branchService, logger)
 
 	// Initialize PostgreSQL stats collector
-	postgresStatsCollector := monitoring.NewPostgresStatsCollector(logger, 30*time.Second)
+	postgresStatsCollector := monitoring.NewPostgresStatsCollector(logger, cfg.Observability.CollectionInterval)
+	postgresStatsCollector.SetSlowQueryThreshold(cfg.Observability.SlowQueryThreshold)
+	postgresStatsCollector.SetSizeHistoryRetention(cfg.Observability.SizeHistoryRetention)
+	postgresStatsCollector.SetPoolSize(collectorPoolSize(cfg.Observability.StatsCollectorPool))
+	postgresStatsCollector.SetEndpointResolver(monitoring.NewCatalogResolver(catalog), monitoring.DefaultReconnectAfter)
 	postgresStatsCtx, postgresStatsCancel := context.WithCancel(context.Background())
 	go postgresStatsCollector.Start(postgresStatsCtx)
+	ready.follow(readyStatsCollector, postgresStatsCollector.Started())
 	logger.Info("PostgreSQL stats collector started")
 	_ = postgresStatsCancel // Will be used in shutdown
 
-	// Set stats collector on manager handler
+	// Set stats collector on manager handler and shard manager
 	managerHandler.SetPostgresStatsCollector(postgresStatsCollector)
+	shardManager.SetShardStatsSource(postgresStatsCollector)
+
+	// Flag client apps that outgrow their storage quota from the collected sizes
+	go shardManager.WatchStorageQuotas(postgresStatsCtx, time.Minute, prometheusCollector)
 
-	// Register existing active shards with stats collector
-	registerExistingShards(shardManager, postgresStatsCollector, logger)
+	// Register existing active shards with stats collector. Both collectors
+	// share one throttle so startup does not connect to every shard at once.
+	registration := cfg.Observability.ShardRegistration
+	registrationThrottle := newRegistrationThrottle(registration.Concurrency, registration.BatchSize, registration.BatchDelay)
+	registrationCtx, registrationCancel := context.WithCancel(context.Background())
+	go registerShardsWhenReady(registrationCtx, ready.Ready(), shardManager.ListShards,
+		func(shard *models.Shard, dsn string) error {
+			return postgresStatsCollector.RegisterDatabaseWithEngine(shard.ID, shard.Engine, dsn)
+		}, "stats", registrationThrottle, registrationRetryInterval, logger)
+
+	// Reconnect collectors as soon as a shard's connection details change in
+	// the catalog, e.g. when its password is rotated
+	go monitoring.WatchCatalogDSNs(postgresStatsCtx, catalog, logger, prometheusCollector, postgresStatsCollector)
 
 	// Cluster scanner already initialized above, create handler
 	clusterScannerHandler := api.NewClusterScannerHandler(clusterManager, multiClusterScanner, prometheusCollector, postgresStatsCollector, logger)
+	clusterScannerHandler.SetScanResultsUpdater(databaseHandler)
 
 	// Auto-register current Kubernetes cluster and scan for databases
 	go func() {
-		// Wait a bit for the server to be ready
-		time.Sleep(5 * time.Second)
+		select {
+		case <-ready.Ready():
+		case <-registrationCtx.Done():
+			return
+		}
 		if err := autoRegisterAndScanCurrentCluster(clusterManager, multiClusterScanner, databaseHandler, logger); err != nil {
 			logger.Warn("failed to auto-register current cluster", zap.Error(err))
 		}
@@ -329,8 +462,11 @@ func NewManagerServer(
 
 	// Setup Phase 1 routes (database, backup, failover)
 	api.SetupDatabaseRoutes(muxRouter, databaseHandler)
+	api.SetupProtectedDatabaseRoutes(protectedRouter, databaseHandler)
 	api.SetupBackupRoutes(muxRouter, backupHandler)
+	api.SetupProtectedBackupRoutes(protectedRouter, backupHandler)
 	api.SetupFailoverRoutes(muxRouter, failoverHandler)
+	api.SetupProtectedFailoverRoutes(protectedRouter, failoverHandler)
 
 	// Setup Phase 2 routes (autoscale, metrics, branches)
 	autoscaleHandler.RegisterRoutes(protectedRouter)
@@ -342,8 +478,27 @@ func NewManagerServer(
 
 	// Setup PostgreSQL stats routes
 	postgresStatsHandler := api.NewPostgresStatsHandler(postgresStatsCollector, shardManager, logger)
+	var auditLogger *security.AuditLogger
+	if cfg.Security.AuditLogPath != "" {
+		auditLogger, err = security.NewAuditLogger(cfg.Security.AuditLogPath)
+		if err != nil {
+			logger.Warn("failed to open audit log, backend signals will only be logged", zap.Error(err))
+		} else {
+			postgresStatsHandler.SetAuditLogger(auditLogger)
+		}
+	}
 	postgresStatsHandler.RegisterRoutes(protectedRouter)
 
+	// Setup tenant data export and erasure routes
+	privacyService := privacy.NewService(shardManager, logger)
+	if auditLogger != nil {
+		privacyService.SetAuditLogger(auditLogger)
+	}
+	api.NewPrivacyHandler(privacyService, logger).RegisterRoutes(protectedRouter)
+
+	// Setup catalog event log routes
+	setupCatalogEventRoutes(protectedRouter, catalog, logger)
+
 	// Setup Swagger documentation
 	muxRouter.HandleFunc("/swagger/doc.json", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
@@ -360,28 +515,44 @@ func NewManagerServer(
 	)).Methods("GET", "OPTIONS")
 
 	// Setup metrics endpoint with CORS support
-	// Prometheus metrics handler wrapped to ensure CORS headers are set
-	muxRouter.Handle("/metrics", prometheusCollector.Handler()).Methods("GET", "OPTIONS")
+	// Prometheus metrics handler wrapped to ensure CORS headers are set, and to
+	// require a scrape credential when observability.metrics_auth sets one
+	muxRouter.Handle("/metrics", middleware.MetricsAuth(cfg.Observability.MetricsAuth)(prometheusCollector.Handler())).Methods("GET", "OPTIONS")
 
 	// Register existing active shards for metrics collection on startup
-	go func() {
-		// Wait a bit for the server to be ready
-		time.Sleep(2 * time.Second)
-		registerExistingShardsForMetrics(shardManager, prometheusCollector, logger)
-	}()
+	go registerShardsWhenReady(registrationCtx, ready.Ready(), shardManager.ListShards,
+		func(shard *models.Shard, dsn string) error {
+			return prometheusCollector.RegisterShardWithEngine(shard.ID, shard.Engine, dsn)
+		}, "metrics", registrationThrottle, registrationRetryInterval, logger)
+
+	// Periodically heal drift between the active shards and the collectors'
+	// registrations, such as missed shard events or failed registrations
+	reconciler := newRegistrationReconciler(shardManager.ListShards, logger,
+		collectorRegistration{
+			collector:  "metrics",
+			registered: prometheusCollector.RegisteredShards,
+			register: func(shard *models.Shard, dsn string) error {
+				return prometheusCollector.RegisterShardWithEngine(shard.ID, shard.Engine, dsn)
+			},
+			unregister: prometheusCollector.UnregisterShard,
+		},
+		collectorRegistration{
+			collector:  "stats",
+			registered: postgresStatsCollector.RegisteredDatabases,
+			register: func(shard *models.Shard, dsn string) error {
+				return postgresStatsCollector.RegisterDatabaseWithEngine(shard.ID, shard.Engine, dsn)
+			},
+			unregister: postgresStatsCollector.UnregisterDatabase,
+		})
+	go reconciler.run(registrationCtx, ready.Ready(), registration.ReconcileInterval)
 
 	// Create HTTP server
-	addr := fmt.Sprintf("%s:%d", cfg.Server.Host, cfg.Server.Port)
-	server := &http.Server{
-		Addr:         addr,
-		Handler:      muxRouter,
-		ReadTimeout:  cfg.Server.ReadTimeout,
-		WriteTimeout: cfg.Server.WriteTimeout,
-		IdleTimeout:  cfg.Server.IdleTimeout,
-	}
+	server := newHTTPServer(cfg.Server, muxRouter)
 
 	return &ManagerServer{
 		server:           server,
+		tcp:              tcpOptions(cfg.Server),
+		socketPath:       cfg.Server.SocketPath,
 		logger:           logger,
 		healthController: healthController,
 		backupService:    backupService,
@@ -389,17 +560,110 @@ func NewManagerServer(
 		loadMonitor:      loadMonitor,
 		autoSplitter:     autoSplitter,
 		branchService:    branchService,
+		auditLogger:      auditLogger,
 		monitorCtx:       monitorCtx,
 		monitorCancel:    monitorCancel,
 		splitterCtx:      splitterCtx,
 		splitterCancel:   splitterCancel,
+		ready:            ready,
+
+		registrationCancel: registrationCancel,
+		reaperCancel:       reaperCancel,
+
+		protectedRouter:        protectedRouter,
+		prometheusCollector:    prometheusCollector,
+		postgresStatsCollector: postgresStatsCollector,
 	}, nil
 }
 
-// Start starts the HTTP server
+// WatchConfig applies the reload-safe settings of configuration reloads to
+// the running server and serves the reload endpoint. It must be called before
+// the server starts.
+func (s *ManagerServer) WatchConfig(reloader *config.HotReloader) {
+	reloader.OnReload(func(old, new *config.Config) error {
+		s.prometheusCollector.SetCollectionInterval(new.Observability.CollectionInterval)
+		s.postgresStatsCollector.SetCollectionInterval(new.Observability.CollectionInterval)
+		s.prometheusCollector.SetSlowQueryThreshold(new.Observability.SlowQueryThreshold)
+		s.postgresStatsCollector.SetSlowQueryThreshold(new.Observability.SlowQueryThreshold)
+		s.postgresStatsCollector.SetSizeHistoryRetention(new.Observability.SizeHistoryRetention)
+		s.prometheusCollector.SetMaxTableSeries(new.Observability.MaxTableSeries)
+		s.prometheusCollector.SetPoolSize(collectorPoolSize(new.Observability.MetricsCollectorPool))
+		s.postgresStatsCollector.SetPoolSize(collectorPoolSize(new.Observability.StatsCollectorPool))
+		middleware.SetCORSAllowedOrigins(new.Security.CORSAllowedOrigins)
+		return nil
+	})
+
+	configHandler := api.NewConfigHandler(reloader, s.logger)
+	configHandler.RegisterRoutes(s.protectedRouter)
+}
+
+// collectorPoolSize converts a collector's configured pool size and per-shard
+// overrides
+func collectorPoolSize(cfg config.CollectorPoolConfig) (monitoring.PoolSize, map[string]monitoring.PoolSize) {
+	shards := make(map[string]monitoring.PoolSize, len(cfg.Shards))
+	for shardID, size := range cfg.Shards {
+		shards[shardID] = monitoring.PoolSize{MaxOpenConns: size.MaxOpenConns, MaxIdleConns: idleConns(size.MaxIdleConns)}
+	}
+	return monitoring.PoolSize{MaxOpenConns: cfg.MaxOpenConns, MaxIdleConns: idleConns(cfg.MaxIdleConns)}, shards
+}
+
+// idleConns converts a configured idle connection count, where nil is unset
+// and zero keeps no idle connections
+func idleConns(n *int) int {
+	switch {
+	case n == nil:
+		return 0
+	case *n == 0:
+		return monitoring.NoIdleConns
+	default:
+		return *n
+	}
+}
+
+// notifierFromEnv builds the notifiers configured through NOTIFY_WEBHOOK_URL,
+// NOTIFY_SLACK_WEBHOOK_URL and NOTIFY_PAGERDUTY_ROUTING_KEY, or nil when none are
+func notifierFromEnv() notify.Notifier {
+	var notifiers notify.Multi
+	if url := os.Getenv("NOTIFY_WEBHOOK_URL"); url != "" {
+		notifiers = append(notifiers, notify.NewWebhookNotifier(url))
+	}
+	if url := os.Getenv("NOTIFY_SLACK_WEBHOOK_URL"); url != "" {
+		notifiers = append(notifiers, notify.NewSlackNotifier(url))
+	}
+	if routingKey := os.Getenv("NOTIFY_PAGERDUTY_ROUTING_KEY"); routingKey != "" {
+		notifiers = append(notifiers, notify.NewPagerDutyNotifier(routingKey))
+	}
+	if len(notifiers) == 0 {
+		return nil
+	}
+	return notifiers
+}
+
+// Start starts the HTTP server, also serving on the Unix socket if one is configured
 func (s *ManagerServer) Start() error {
+	var unixListener net.Listener
+	if s.socketPath != "" {
+		l, err := listener.Unix(s.socketPath)
+		if err != nil {
+			return err
+		}
+		unixListener = l
+		s.logger.Info("serving manager API on unix socket", zap.String("path", s.socketPath))
+		// Shutdown closes the listener, which removes the socket file
+		go func() {
+			if err := s.server.Serve(l); err != nil && err != http.ErrServerClosed && !errors.Is(err, net.ErrClosed) {
+				s.logger.Error("unix socket server failed", zap.Error(err))
+			}
+		}()
+	}
+
 	s.logger.Info("starting manager server", zap.String("address", s.server.Addr))
-	if err := s.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+	listening := func() { s.ready.markReady(readyListening) }
+	if err := serveTCP(s.server, s.tcp, listening); err != nil && err != http.ErrServerClosed {
+		// Stop serving on the socket too, so a failed start leaves nothing behind
+		if unixListener != nil {
+			unixListener.Close()
+		}
 		return fmt.Errorf("server failed: %w", err)
 	}
 	return nil
@@ -409,6 +673,13 @@ func (s *ManagerServer) Start() error {
 func (s *ManagerServer) Shutdown(ctx context.Context) error {
 	s.logger.Info("shutting down manager server")
 
+	if s.registrationCancel != nil {
+		s.registrationCancel()
+	}
+	if s.reaperCancel != nil {
+		s.reaperCancel()
+	}
+
 	// Stop Phase 2 services
 	if s.monitorCancel != nil {
 		s.monitorCancel()
@@ -430,7 +701,11 @@ func (s *ManagerServer) Shutdown(ctx context.Context) error {
 		s.failoverCtrl.Stop()
 	}
 
-	return s.server.Shutdown(ctx)
+	err := s.server.Shutdown(ctx)
+	if s.auditLogger != nil {
+		s.auditLogger.Close()
+	}
+	return err
 }
 
 // StartAsync starts the server in a goroutine
@@ -447,6 +722,32 @@ func (s *ManagerServer) Handler() http.Handler {
 	return s.server.Handler
 }
 
+// currentClusterName returns the name the cluster the manager runs in is registered under
+func currentClusterName() string {
+	// Get cluster name from environment or use default
+	if name := os.Getenv("KUBERNETES_CLUSTER_NAME"); name != "" {
+		return name
+	}
+	return "local-cluster"
+}
+
+// clusterClients resolves registered clusters to Kubernetes clients by name
+func clusterClients(clusterManager *scanner.ClusterManager) manager.ClusterClientFunc {
+	return func(clusterName string) (kubernetes.Interface, error) {
+		if clusterName == "" {
+			clusterName = currentClusterName()
+		}
+		conn, err := clusterManager.GetClusterByName(clusterName)
+		if err != nil {
+			return nil, err
+		}
+		if conn.Client == nil {
+			return nil, fmt.Errorf("cluster %s has no client", clusterName)
+		}
+		return conn.Client, nil
+	}
+}
+
 // autoRegisterAndScanCurrentCluster automatically registers the current Kubernetes cluster
 // and scans it for databases
 func autoRegisterAndScanCurrentCluster(
@@ -489,11 +790,7 @@ func autoRegisterAndScanCurrentCluster(
 		return nil // Don't fail startup if we can't connect
 	}
 
-	// Get cluster name from environment or use default
-	clusterName := os.Getenv("KUBERNETES_CLUSTER_NAME")
-	if clusterName == "" {
-		clusterName = "local-cluster"
-	}
+	clusterName := currentClusterName()
 
 	// Check if cluster already registered
 	clusters := clusterManager.ListClusters()
@@ -565,35 +862,3 @@ func scanCluster(
 
 	return nil
 }
-
-// registerExistingShards registers all existing active shards with the PostgreSQL stats collector
-func registerExistingShards(shardManager *manager.Manager, statsCollector *monitoring.PostgresStatsCollector, logger *zap.Logger) {
-	shards, err := shardManager.ListShards()
-	if err != nil {
-		logger.Warn("failed to list shards for stats registration", zap.Error(err))
-		return
-	}
-
-	registered := 0
-	for _, shard := range shards {
-		if shard.Status == "active" {
-			dsn := buildDSNFromShard(&shard)
-			if dsn != "" {
-				if err := statsCollector.RegisterDatabase(shard.ID, dsn); err != nil {
-					logger.Warn("failed to register existing shard with PostgreSQL stats collector",
-						zap.String("shard_id", shard.ID),
-						zap.Error(err))
-				} else {
-					registered++
-					logger.Debug("registered existing shard for PostgreSQL stats collection",
-						zap.String("shard_id", shard.ID))
-				}
-			}
-		}
-	}
-
-	if registered > 0 {
-		logger.Info("registered existing shards for PostgreSQL stats collection",
-			zap.Int("count", registered))
-	}
-}
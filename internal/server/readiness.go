@@ -0,0 +1,261 @@
+package server
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/sharding-system/pkg/models"
+	"go.uber.org/zap"
+)
+
+// Components the manager waits for before registering existing shards
+const (
+	readyListening        = "listening"
+	readyMetricsCollector = "metrics collector"
+	readyStatsCollector   = "stats collector"
+)
+
+const (
+	// registrationRetryInterval is how long shards that failed to register
+	// wait before the first retry; it doubles up to maxRegistrationRetryInterval
+	registrationRetryInterval    = 2 * time.Second
+	maxRegistrationRetryInterval = time.Minute
+
+	// Defaults for pacing registration when the configuration leaves it unset
+	defaultRegistrationConcurrency = 8
+	defaultRegistrationBatchSize   = 50
+)
+
+// registrationThrottle paces registering existing shards so a large cluster
+// is not connected to all at once on startup. It bounds how many shards
+// register at the same time, shared by every collector using it, and pauses
+// after each batch of shards.
+type registrationThrottle struct {
+	slots      chan struct{}
+	batchSize  int
+	batchDelay time.Duration
+}
+
+// newRegistrationThrottle creates a throttle. A non-positive concurrency or
+// batch size uses the default; a non-positive batch delay does not pause.
+func newRegistrationThrottle(concurrency, batchSize int, batchDelay time.Duration) *registrationThrottle {
+	if concurrency <= 0 {
+		concurrency = defaultRegistrationConcurrency
+	}
+	if batchSize <= 0 {
+		batchSize = defaultRegistrationBatchSize
+	}
+	return &registrationThrottle{
+		slots:      make(chan struct{}, concurrency),
+		batchSize:  batchSize,
+		batchDelay: batchDelay,
+	}
+}
+
+// acquire waits for a free registration slot, or returns false once ctx is done
+func (t *registrationThrottle) acquire(ctx context.Context) bool {
+	select {
+	case t.slots <- struct{}{}:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (t *registrationThrottle) release() {
+	<-t.slots
+}
+
+// pause waits out the delay between batches, or returns false once ctx is done
+func (t *registrationThrottle) pause(ctx context.Context) bool {
+	if t.batchDelay <= 0 {
+		return ctx.Err() == nil
+	}
+	select {
+	case <-time.After(t.batchDelay):
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// readinessGate opens once every component it waits for has reported ready
+type readinessGate struct {
+	mu      sync.Mutex
+	pending map[string]bool
+	ready   chan struct{}
+}
+
+func newReadinessGate(components ...string) *readinessGate {
+	g := &readinessGate{
+		pending: make(map[string]bool, len(components)),
+		ready:   make(chan struct{}),
+	}
+	for _, component := range components {
+		g.pending[component] = true
+	}
+	if len(g.pending) == 0 {
+		close(g.ready)
+	}
+	return g
+}
+
+// markReady reports a component ready. The gate opens with the last one;
+// reporting a component twice, or one the gate does not wait for, is harmless.
+func (g *readinessGate) markReady(component string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if !g.pending[component] {
+		return
+	}
+	delete(g.pending, component)
+	if len(g.pending) == 0 {
+		close(g.ready)
+	}
+}
+
+// follow reports a component ready once started is closed
+func (g *readinessGate) follow(component string, started <-chan struct{}) {
+	go func() {
+		<-started
+		g.markReady(component)
+	}()
+}
+
+// Ready is closed once every component is ready
+func (g *readinessGate) Ready() <-chan struct{} {
+	return g.ready
+}
+
+// registerShardsWhenReady waits for ready, then registers every active shard
+// with connection details using register, paced by throttle. Shards that fail
+// to register, e.g. because they are briefly unreachable, are retried with a
+// growing delay until they register, leave the catalog or ctx is done.
+func registerShardsWhenReady(
+	ctx context.Context,
+	ready <-chan struct{},
+	listShards func() ([]models.Shard, error),
+	register func(shard *models.Shard, dsn string) error,
+	collector string,
+	throttle *registrationThrottle,
+	retryInterval time.Duration,
+	logger *zap.Logger,
+) {
+	select {
+	case <-ready:
+	case <-ctx.Done():
+		return
+	}
+	logger = logger.With(zap.String("collector", collector))
+
+	var pending map[string]bool // Shards left to register; nil until the first pass
+	delay := retryInterval
+	for {
+		failed, err := registerShards(ctx, listShards, register, pending, throttle, logger)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			logger.Warn("failed to list shards for registration", zap.Error(err))
+		} else if len(failed) == 0 {
+			return
+		} else {
+			pending = failed
+			logger.Info("retrying shards that failed to register",
+				zap.Int("shards", len(failed)),
+				zap.Duration("retry_in", delay))
+		}
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return
+		}
+		delay *= 2
+		if delay > maxRegistrationRetryInterval {
+			delay = maxRegistrationRetryInterval
+		}
+	}
+}
+
+// registerShards registers the active shards with connection details, all of
+// them when pending is nil and otherwise only those in pending. Shards are
+// registered concurrently in batches, as throttle allows. It returns the
+// shards that failed.
+func registerShards(
+	ctx context.Context,
+	listShards func() ([]models.Shard, error),
+	register func(shard *models.Shard, dsn string) error,
+	pending map[string]bool,
+	throttle *registrationThrottle,
+	logger *zap.Logger,
+) (map[string]bool, error) {
+	shards, err := listShards()
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		mu         sync.Mutex
+		failed     = make(map[string]bool)
+		registered int
+		wg         sync.WaitGroup
+	)
+	inBatch := 0
+	for i := range shards {
+		shard := &shards[i]
+		if shard.Status != models.ShardStatusActive || (pending != nil && !pending[shard.ID]) {
+			continue
+		}
+
+		dsn := buildDSNFromShard(shard)
+		if dsn == "" {
+			logger.Debug("skipping shard - no connection details available",
+				zap.String("shard_id", shard.ID),
+				zap.String("shard_name", shard.Name))
+			continue
+		}
+
+		// Let a full batch finish before pausing and starting the next one
+		if inBatch == throttle.batchSize {
+			wg.Wait()
+			inBatch = 0
+			if !throttle.pause(ctx) {
+				break
+			}
+		}
+		if !throttle.acquire(ctx) {
+			break
+		}
+		inBatch++
+		wg.Add(1)
+		go func(shard *models.Shard, dsn string) {
+			defer wg.Done()
+			defer throttle.release()
+
+			err := register(shard, dsn)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				failed[shard.ID] = true
+				logger.Warn("failed to register existing shard",
+					zap.String("shard_id", shard.ID),
+					zap.String("shard_name", shard.Name),
+					zap.Error(err))
+				return
+			}
+			registered++
+			logger.Debug("registered existing shard",
+				zap.String("shard_id", shard.ID),
+				zap.String("shard_name", shard.Name))
+		}(shard, dsn)
+	}
+	wg.Wait()
+
+	logger.Info("registered existing shards",
+		zap.Int("total_shards", len(shards)),
+		zap.Int("registered", registered),
+		zap.Int("failed", len(failed)))
+	return failed, nil
+}
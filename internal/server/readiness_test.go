@@ -0,0 +1,255 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sharding-system/pkg/models"
+	"go.uber.org/zap/zaptest"
+)
+
+// shardRegistry records registrations and fails the first attempts of
+// shards that are not reachable yet
+type shardRegistry struct {
+	mu          sync.Mutex
+	unreachable map[string]int // Attempts left to fail per shard
+	attempts    map[string]int
+	registered  chan string
+}
+
+func newShardRegistry(unreachable map[string]int) *shardRegistry {
+	return &shardRegistry{
+		unreachable: unreachable,
+		attempts:    make(map[string]int),
+		registered:  make(chan string, 10),
+	}
+}
+
+func (r *shardRegistry) register(shard *models.Shard, dsn string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.attempts[shard.ID]++
+	if r.unreachable[shard.ID] > 0 {
+		r.unreachable[shard.ID]--
+		return errors.New("connection refused")
+	}
+	r.registered <- shard.ID
+	return nil
+}
+
+func (r *shardRegistry) attemptsFor(shardID string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.attempts[shardID]
+}
+
+func listShards(shards ...models.Shard) func() ([]models.Shard, error) {
+	return func() ([]models.Shard, error) {
+		return shards, nil
+	}
+}
+
+func TestReadinessGate_OpensOnceAllComponentsAreReady(t *testing.T) {
+	gate := newReadinessGate(readyListening, readyMetricsCollector)
+	started := make(chan struct{})
+	gate.follow(readyMetricsCollector, started)
+
+	gate.markReady(readyListening)
+	gate.markReady(readyListening)
+	gate.markReady("unknown")
+	select {
+	case <-gate.Ready():
+		t.Fatal("expected the gate to wait for the metrics collector")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(started)
+	select {
+	case <-gate.Ready():
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the gate to open once the metrics collector started")
+	}
+	gate.markReady(readyMetricsCollector)
+}
+
+func TestRegisterShardsWhenReady_WaitsForReadiness(t *testing.T) {
+	registry := newShardRegistry(nil)
+	ready := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		registerShardsWhenReady(context.Background(), ready,
+			listShards(models.Shard{ID: "shard1", Status: models.ShardStatusActive, Host: "db-1", Database: "orders"}),
+			registry.register, "metrics", newRegistrationThrottle(0, 0, 0), time.Millisecond, zaptest.NewLogger(t))
+		close(done)
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	if attempts := registry.attemptsFor("shard1"); attempts != 0 {
+		t.Fatalf("expected no registration before readiness, got %d attempts", attempts)
+	}
+
+	close(ready)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected registration to finish once ready")
+	}
+	if id := <-registry.registered; id != "shard1" {
+		t.Errorf("expected shard1 to be registered, got %s", id)
+	}
+}
+
+func TestRegisterShardsWhenReady_RetriesUnreachableShards(t *testing.T) {
+	registry := newShardRegistry(map[string]int{"shard2": 2})
+	ready := make(chan struct{})
+	close(ready)
+
+	shards := listShards(
+		models.Shard{ID: "shard1", Status: models.ShardStatusActive, Host: "db-1", Database: "orders"},
+		models.Shard{ID: "shard2", Status: models.ShardStatusActive, Host: "db-2", Database: "orders"},
+		models.Shard{ID: "shard3", Status: "inactive", Host: "db-3", Database: "orders"},
+		models.Shard{ID: "shard4", Status: models.ShardStatusActive},
+	)
+	done := make(chan struct{})
+	go func() {
+		registerShardsWhenReady(context.Background(), ready, shards, registry.register, "stats", newRegistrationThrottle(0, 0, 0), time.Millisecond, zaptest.NewLogger(t))
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the unreachable shard to register once it came up")
+	}
+	if attempts := registry.attemptsFor("shard2"); attempts != 3 {
+		t.Errorf("expected shard2 to register on its third attempt, got %d attempts", attempts)
+	}
+	if attempts := registry.attemptsFor("shard1"); attempts != 1 {
+		t.Errorf("expected shard1 to register once, got %d attempts", attempts)
+	}
+	if registry.attemptsFor("shard3") != 0 || registry.attemptsFor("shard4") != 0 {
+		t.Error("expected inactive shards and shards without connection details to be skipped")
+	}
+}
+
+func TestRegisterShardsWhenReady_StopsWithContext(t *testing.T) {
+	registry := newShardRegistry(map[string]int{"shard1": 1 << 20})
+	ready := make(chan struct{})
+	close(ready)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		registerShardsWhenReady(ctx, ready,
+			listShards(models.Shard{ID: "shard1", Status: models.ShardStatusActive, Host: "db-1", Database: "orders"}),
+			registry.register, "metrics", newRegistrationThrottle(0, 0, 0), time.Millisecond, zaptest.NewLogger(t))
+		close(done)
+	}()
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected retries to stop with the context")
+	}
+}
+
+func TestRegisterShardsWhenReady_BoundsConcurrency(t *testing.T) {
+	const shardCount = 300
+	shards := make([]models.Shard, shardCount)
+	for i := range shards {
+		shards[i] = models.Shard{ID: fmt.Sprintf("shard%d", i), Status: models.ShardStatusActive, Host: fmt.Sprintf("db-%d", i), Database: "orders"}
+	}
+
+	// Each registration holds its connection briefly, as opening one would
+	var mu sync.Mutex
+	inFlight, maxInFlight := 0, 0
+	registered := make(map[string]int)
+	register := func(shard *models.Shard, dsn string) error {
+		mu.Lock()
+		inFlight++
+		if inFlight > maxInFlight {
+			maxInFlight = inFlight
+		}
+		registered[shard.ID]++
+		mu.Unlock()
+
+		time.Sleep(time.Millisecond)
+
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+		return nil
+	}
+
+	ready := make(chan struct{})
+	close(ready)
+	// Both collectors share the throttle, so the bound holds across them
+	throttle := newRegistrationThrottle(5, 40, time.Millisecond)
+	var wg sync.WaitGroup
+	for _, collector := range []string{"metrics", "stats"} {
+		wg.Add(1)
+		go func(collector string) {
+			defer wg.Done()
+			registerShardsWhenReady(context.Background(), ready, listShards(shards...), register, collector, throttle, time.Millisecond, zaptest.NewLogger(t))
+		}(collector)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("expected every shard to register")
+	}
+
+	if maxInFlight > 5 {
+		t.Errorf("expected at most 5 registrations at once, got %d", maxInFlight)
+	}
+	if maxInFlight < 2 {
+		t.Errorf("expected registrations to run concurrently, got at most %d at once", maxInFlight)
+	}
+	if len(registered) != shardCount {
+		t.Fatalf("expected %d shards registered, got %d", shardCount, len(registered))
+	}
+	for id, count := range registered {
+		if count != 2 {
+			t.Errorf("expected %s to register once per collector, got %d", id, count)
+		}
+	}
+}
+
+func TestRegisterShards_PausesBetweenBatches(t *testing.T) {
+	shards := make([]models.Shard, 6)
+	for i := range shards {
+		shards[i] = models.Shard{ID: fmt.Sprintf("shard%d", i), Status: models.ShardStatusActive, Host: "db", Database: "orders"}
+	}
+	registry := newShardRegistry(nil)
+
+	start := time.Now()
+	failed, err := registerShards(context.Background(), listShards(shards...), registry.register, nil,
+		newRegistrationThrottle(2, 2, 30*time.Millisecond), zaptest.NewLogger(t))
+	if err != nil || len(failed) != 0 {
+		t.Fatalf("expected every shard to register, got %v failed and %v", failed, err)
+	}
+	// Three batches of two shards pause twice
+	if elapsed := time.Since(start); elapsed < 60*time.Millisecond {
+		t.Errorf("expected a pause between batches, finished in %s", elapsed)
+	}
+
+	// Cancelling stops registration at the next pause
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	registry = newShardRegistry(nil)
+	registerShards(ctx, listShards(shards...), registry.register, nil, newRegistrationThrottle(2, 2, time.Hour), zaptest.NewLogger(t))
+	if len(registry.registered) > 2 {
+		t.Errorf("expected registration to stop once cancelled, got %d shards registered", len(registry.registered))
+	}
+}
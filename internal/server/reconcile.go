@@ -0,0 +1,139 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	"github.com/sharding-system/pkg/models"
+	"go.uber.org/zap"
+)
+
+// collectorRegistration is how the reconciler reads and changes the shards
+// registered with one collector
+type collectorRegistration struct {
+	collector  string
+	registered func() []string
+	register   func(shard *models.Shard, dsn string) error
+	unregister func(shardID string)
+}
+
+// registrationReconciler heals drift between the shards in the catalog and
+// the shards registered with the collectors: active shards whose registration
+// failed or was missed are registered, and shards still registered after they
+// went out of service or were deleted are unregistered. Shards on their way in
+// or out of service, such as read-only or draining ones, keep their
+// registration either way. Only shards it has seen in the catalog are
+// unregistered, so databases the cluster scanner registered are left alone.
+type registrationReconciler struct {
+	listShards    func() ([]models.Shard, error)
+	registrations []collectorRegistration
+	logger        *zap.Logger
+
+	known map[string]bool // Shards seen in the catalog and not yet deleted from it
+}
+
+func newRegistrationReconciler(listShards func() ([]models.Shard, error), logger *zap.Logger, registrations ...collectorRegistration) *registrationReconciler {
+	return &registrationReconciler{
+		listShards:    listShards,
+		registrations: registrations,
+		logger:        logger,
+		known:         make(map[string]bool),
+	}
+}
+
+// run reconciles registrations every interval once ready is closed, until
+// ctx is done. A non-positive interval disables reconciling.
+func (r *registrationReconciler) run(ctx context.Context, ready <-chan struct{}, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	select {
+	case <-ready:
+	case <-ctx.Done():
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if _, _, err := r.reconcile(); err != nil {
+				r.logger.Warn("failed to list shards for registration reconciling", zap.Error(err))
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// reconcile brings each collector's registrations in line with the catalog,
+// returning how many shards were registered and unregistered across the
+// collectors
+func (r *registrationReconciler) reconcile() (registered, unregistered int, err error) {
+	shards, err := r.listShards()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	active := make(map[string]*models.Shard, len(shards))
+	inService := make(map[string]bool, len(shards))
+	inCatalog := make(map[string]bool, len(shards))
+	for i := range shards {
+		shard := &shards[i]
+		r.known[shard.ID] = true
+		inCatalog[shard.ID] = true
+		if shardInService(shard.Status) {
+			inService[shard.ID] = true
+		}
+		if shard.Status == models.ShardStatusActive && buildDSNFromShard(shard) != "" {
+			active[shard.ID] = shard
+		}
+	}
+
+	for _, registration := range r.registrations {
+		logger := r.logger.With(zap.String("collector", registration.collector))
+		current := make(map[string]bool)
+		for _, shardID := range registration.registered() {
+			current[shardID] = true
+			if r.known[shardID] && !inService[shardID] {
+				registration.unregister(shardID)
+				unregistered++
+				logger.Info("unregistered shard that is out of service", zap.String("shard_id", shardID))
+			}
+		}
+
+		for shardID, shard := range active {
+			if current[shardID] {
+				continue
+			}
+			if err := registration.register(shard, buildDSNFromShard(shard)); err != nil {
+				logger.Warn("failed to register active shard missing from collector",
+					zap.String("shard_id", shardID),
+					zap.Error(err))
+				continue
+			}
+			registered++
+			logger.Info("registered active shard missing from collector", zap.String("shard_id", shardID))
+		}
+	}
+
+	// Shards deleted from the catalog were unregistered above, so they need
+	// not be remembered
+	for shardID := range r.known {
+		if !inCatalog[shardID] {
+			delete(r.known, shardID)
+		}
+	}
+	return registered, unregistered, nil
+}
+
+// shardInService reports whether a shard in status keeps its collector
+// registrations
+func shardInService(status string) bool {
+	switch status {
+	case models.ShardStatusInactive, models.ShardStatusFailed, models.ShardStatusDeleting:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,226 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sharding-system/pkg/models"
+	"go.uber.org/zap/zaptest"
+)
+
+// fakeCollector holds the shards registered with it and fails to register
+// the shards in failing
+type fakeCollector struct {
+	mu         sync.Mutex
+	registered map[string]string // DSN by shard ID
+	failing    map[string]bool
+}
+
+func newFakeCollector(shardIDs ...string) *fakeCollector {
+	c := &fakeCollector{registered: make(map[string]string), failing: make(map[string]bool)}
+	for _, shardID := range shardIDs {
+		c.registered[shardID] = "seeded"
+	}
+	return c
+}
+
+func (c *fakeCollector) registration(name string) collectorRegistration {
+	return collectorRegistration{
+		collector: name,
+		registered: func() []string {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			shardIDs := make([]string, 0, len(c.registered))
+			for shardID := range c.registered {
+				shardIDs = append(shardIDs, shardID)
+			}
+			return shardIDs
+		},
+		register: func(shard *models.Shard, dsn string) error {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			if c.failing[shard.ID] {
+				return errors.New("connection refused")
+			}
+			c.registered[shard.ID] = dsn
+			return nil
+		},
+		unregister: func(shardID string) {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			delete(c.registered, shardID)
+		},
+	}
+}
+
+func (c *fakeCollector) shards() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	shardIDs := make([]string, 0, len(c.registered))
+	for shardID := range c.registered {
+		shardIDs = append(shardIDs, shardID)
+	}
+	sort.Strings(shardIDs)
+	return shardIDs
+}
+
+func reconcileShard(id, status string) models.Shard {
+	return models.Shard{ID: id, Status: status, Host: id + ".db", Port: 5432, Database: "app"}
+}
+
+func expectShards(t *testing.T, collector string, got []string, want ...string) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Errorf("%s: expected shards %v, got %v", collector, want, got)
+		return
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("%s: expected shards %v, got %v", collector, want, got)
+			return
+		}
+	}
+}
+
+func TestRegistrationReconciler_RegistersDriftedActiveShards(t *testing.T) {
+	// The metrics collector missed shard-2, and neither collector has shard-3
+	metrics := newFakeCollector("shard-1")
+	stats := newFakeCollector("shard-1", "shard-2")
+	shards := []models.Shard{
+		reconcileShard("shard-1", models.ShardStatusActive),
+		reconcileShard("shard-2", models.ShardStatusActive),
+		reconcileShard("shard-3", models.ShardStatusActive),
+		{ID: "no-dsn", Status: models.ShardStatusActive},
+	}
+	reconciler := newRegistrationReconciler(listShards(shards...), zaptest.NewLogger(t),
+		metrics.registration("metrics"), stats.registration("stats"))
+
+	registered, unregistered, err := reconciler.reconcile()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if registered != 3 || unregistered != 0 {
+		t.Errorf("expected 3 registrations and no unregistrations, got %d and %d", registered, unregistered)
+	}
+	expectShards(t, "metrics", metrics.shards(), "shard-1", "shard-2", "shard-3")
+	expectShards(t, "stats", stats.shards(), "shard-1", "shard-2", "shard-3")
+	if dsn := metrics.registered["shard-3"]; dsn != buildDSNFromShard(&shards[2]) {
+		t.Errorf("expected the shard's collector DSN, got %q", dsn)
+	}
+	if stats.registered["shard-1"] != "seeded" {
+		t.Error("expected shards already registered to keep their registration")
+	}
+
+	// A pass without drift changes nothing
+	if registered, unregistered, _ := reconciler.reconcile(); registered != 0 || unregistered != 0 {
+		t.Errorf("expected no changes without drift, got %d registrations and %d unregistrations", registered, unregistered)
+	}
+}
+
+func TestRegistrationReconciler_UnregistersShardsOutOfService(t *testing.T) {
+	metrics := newFakeCollector("shard-1", "shard-2", "shard-3", "scanned-db")
+	shards := []models.Shard{
+		reconcileShard("shard-1", models.ShardStatusActive),
+		reconcileShard("shard-2", models.ShardStatusInactive),
+		reconcileShard("shard-3", models.ShardStatusDraining),
+	}
+	list := func() ([]models.Shard, error) { return shards, nil }
+	reconciler := newRegistrationReconciler(list, zaptest.NewLogger(t), metrics.registration("metrics"))
+
+	if _, unregistered, _ := reconciler.reconcile(); unregistered != 1 {
+		t.Errorf("expected the inactive shard to be unregistered, got %d unregistrations", unregistered)
+	}
+	// Draining shards and databases the catalog does not know keep their registration
+	expectShards(t, "metrics", metrics.shards(), "scanned-db", "shard-1", "shard-3")
+
+	// shard-1 is deleted from the catalog
+	shards = shards[1:]
+	if _, unregistered, _ := reconciler.reconcile(); unregistered != 1 {
+		t.Errorf("expected the deleted shard to be unregistered, got %d unregistrations", unregistered)
+	}
+	expectShards(t, "metrics", metrics.shards(), "scanned-db", "shard-3")
+	if reconciler.known["shard-1"] {
+		t.Error("expected the deleted shard to be forgotten once unregistered")
+	}
+	if !reconciler.known["shard-2"] || !reconciler.known["shard-3"] {
+		t.Errorf("expected shards still in the catalog to be remembered, got %v", reconciler.known)
+	}
+}
+
+func TestRegistrationReconciler_RetriesFailedRegistrations(t *testing.T) {
+	metrics := newFakeCollector()
+	metrics.failing["shard-1"] = true
+	reconciler := newRegistrationReconciler(listShards(reconcileShard("shard-1", models.ShardStatusActive)),
+		zaptest.NewLogger(t), metrics.registration("metrics"))
+
+	if registered, _, _ := reconciler.reconcile(); registered != 0 {
+		t.Errorf("expected the unreachable shard not to register, got %d registrations", registered)
+	}
+
+	metrics.mu.Lock()
+	metrics.failing["shard-1"] = false
+	metrics.mu.Unlock()
+	if registered, _, _ := reconciler.reconcile(); registered != 1 {
+		t.Errorf("expected the shard to register once reachable, got %d registrations", registered)
+	}
+	expectShards(t, "metrics", metrics.shards(), "shard-1")
+}
+
+func TestRegistrationReconciler_RunsPeriodicallyOnceReady(t *testing.T) {
+	metrics := newFakeCollector()
+	reconciler := newRegistrationReconciler(listShards(reconcileShard("shard-1", models.ShardStatusActive)),
+		zaptest.NewLogger(t), metrics.registration("metrics"))
+	ready := make(chan struct{})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		reconciler.run(ctx, ready, 10*time.Millisecond)
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	if len(metrics.shards()) != 0 {
+		t.Fatal("expected no reconciling before the server is ready")
+	}
+
+	close(ready)
+	deadline := time.After(5 * time.Second)
+	for len(metrics.shards()) == 0 {
+		select {
+		case <-deadline:
+			t.Fatal("expected the drifted shard to be registered")
+		case <-time.After(5 * time.Millisecond):
+		}
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the reconciler to stop with its context")
+	}
+}
+
+func TestRegistrationReconciler_DisabledWithoutInterval(t *testing.T) {
+	reconciler := newRegistrationReconciler(func() ([]models.Shard, error) {
+		t.Error("expected no shards to be listed while disabled")
+		return nil, nil
+	}, zaptest.NewLogger(t))
+	ready := make(chan struct{})
+	close(ready)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		reconciler.run(context.Background(), ready, -1)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected run to return straight away when disabled")
+	}
+}
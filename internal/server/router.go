@@ -11,7 +11,9 @@ import (
 	"github.com/sharding-system/internal/api"
 	"github.com/sharding-system/internal/middleware"
 	"github.com/sharding-system/pkg/config"
+	"github.com/sharding-system/pkg/listener"
 	"github.com/sharding-system/pkg/router"
+	"github.com/sharding-system/pkg/security"
 	httpSwagger "github.com/swaggo/http-swagger"
 	"go.uber.org/zap"
 )
@@ -19,6 +21,7 @@ import (
 // RouterServer represents the router HTTP server
 type RouterServer struct {
 	server *http.Server
+	tcp    listener.TCPOptions
 	logger *zap.Logger
 }
 
@@ -33,13 +36,34 @@ func NewRouterServer(
 	muxRouter := mux.NewRouter()
 
 	// Apply middleware - CORS must be first to ensure headers are set
+	if len(cfg.Security.CORSAllowedOrigins) > 0 {
+		middleware.SetCORSAllowedOrigins(cfg.Security.CORSAllowedOrigins)
+	}
 	muxRouter.Use(middleware.CORS)
+	muxRouter.Use(middleware.RequestID)
 	muxRouter.Use(middleware.Recovery(logger))
 	muxRouter.Use(middleware.Logging(logger))
 
+	// With RBAC on, clients authenticate with the manager's JWTs; the throttle
+	// counts their requests against the token's username
+	if cfg.Security.EnableRBAC {
+		if cfg.Security.JWTSecret == "" {
+			return nil, fmt.Errorf("security.jwt_secret is required when enable_rbac is on; set it or JWT_SECRET")
+		}
+		muxRouter.Use(middleware.AuthMiddleware(security.NewAuthManager(cfg.Security.JWTSecret)))
+	} else {
+		logger.Warn("RBAC disabled - router endpoints are not protected and client rate limits do not apply. Enable in production!")
+	}
+
+	// Emergency throttle and kill switch; admin and health endpoints stay reachable
+	muxRouter.Use(middleware.Throttle(shardRouter.Throttle(), "/api/v1/router/", "/health", "/v1/health", "/metrics", "/swagger/"))
+
 	// Request size limit (10MB default)
 	muxRouter.Use(middleware.RequestSizeLimit(middleware.DefaultMaxRequestSize))
 
+	// Bound handler run time; streaming endpoints are exempt
+	muxRouter.Use(middleware.Timeout(cfg.Server.RequestTimeout))
+
 	// Content-Type validation for POST/PUT/PATCH requests
 	muxRouter.Use(middleware.ContentTypeValidation([]string{"application/json"}))
 
@@ -62,21 +86,16 @@ func NewRouterServer(
 	)).Methods("GET", "OPTIONS")
 
 	// Setup metrics endpoint with CORS support
-	// Prometheus metrics handler wrapped to ensure CORS headers are set
-	muxRouter.Handle("/metrics", promhttp.Handler()).Methods("GET", "OPTIONS")
+	// Prometheus metrics handler wrapped to ensure CORS headers are set, and to
+	// require a scrape credential when observability.metrics_auth sets one
+	muxRouter.Handle("/metrics", middleware.MetricsAuth(cfg.Observability.MetricsAuth)(promhttp.Handler())).Methods("GET", "OPTIONS")
 
 	// Create HTTP server
-	addr := fmt.Sprintf("%s:%d", cfg.Server.Host, cfg.Server.Port)
-	server := &http.Server{
-		Addr:         addr,
-		Handler:      muxRouter,
-		ReadTimeout:  cfg.Server.ReadTimeout,
-		WriteTimeout: cfg.Server.WriteTimeout,
-		IdleTimeout:  cfg.Server.IdleTimeout,
-	}
+	server := newHTTPServer(cfg.Server, muxRouter)
 
 	return &RouterServer{
 		server: server,
+		tcp:    tcpOptions(cfg.Server),
 		logger: logger,
 	}, nil
 }
@@ -84,7 +103,7 @@ func NewRouterServer(
 // Start starts the HTTP server
 func (s *RouterServer) Start() error {
 	s.logger.Info("starting router server", zap.String("address", s.server.Addr))
-	if err := s.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+	if err := serveTCP(s.server, s.tcp, nil); err != nil && err != http.ErrServerClosed {
 		return fmt.Errorf("server failed: %w", err)
 	}
 	return nil
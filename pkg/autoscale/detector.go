@@ -95,6 +95,24 @@ func (d *HotShardDetector) IsHotShard(shardID string) bool {
 	return isHot
 }
 
+// IsStorageBound reports whether storage is the only threshold a shard
+// exceeds, so growing its volume relieves it without a split
+func (d *HotShardDetector) IsStorageBound(shardID string) bool {
+	metrics, ok := d.monitor.GetMetrics(shardID)
+	if !ok {
+		return false
+	}
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return metrics.StorageUsage > d.thresholds.MaxStorageUsage &&
+		metrics.QueryRate <= d.thresholds.MaxQueryRate &&
+		metrics.CPUUsage <= d.thresholds.MaxCPUUsage &&
+		metrics.MemoryUsage <= d.thresholds.MaxMemoryUsage &&
+		metrics.ConnectionCount <= d.thresholds.MaxConnections &&
+		metrics.AvgLatencyMs <= d.thresholds.MaxLatencyMs
+}
+
 // IsColdShard determines if a shard is "cold" and can be merged
 func (d *HotShardDetector) IsColdShard(shardID string) bool {
 	metrics, ok := d.monitor.GetMetrics(shardID)
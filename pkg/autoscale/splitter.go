@@ -2,6 +2,7 @@ package autoscale
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
@@ -9,6 +10,8 @@ import (
 	"github.com/sharding-system/pkg/catalog"
 	"github.com/sharding-system/pkg/manager"
 	"github.com/sharding-system/pkg/models"
+	"github.com/sharding-system/pkg/notify"
+	"github.com/sharding-system/pkg/operator"
 	"go.uber.org/zap"
 )
 
@@ -17,11 +20,33 @@ type AutoSplitter struct {
 	detector     *HotShardDetector
 	manager      *manager.Manager
 	catalog      catalog.Catalog
+	notifier     notify.Notifier
 	logger       *zap.Logger
 	enabled      bool
 	mu           sync.RWMutex
 	splitHistory map[string]time.Time // Track when shards were last split
 	cooldown     time.Duration        // Minimum time between splits for same shard
+
+	// expander grows the volumes of shards that only run out of storage
+	expander        StorageExpander
+	expansionPolicy StorageExpansionPolicy
+}
+
+// ErrStorageExpansionUnsupported is returned for a storage expansion when no
+// StorageExpander is set
+var ErrStorageExpansionUnsupported = errors.New("storage expansion is not supported")
+
+// StorageExpander grows the volumes of shards; implemented by the Kubernetes operator
+type StorageExpander interface {
+	ExpandShardStorage(ctx context.Context, shardID, size string) (*operator.StorageExpansion, error)
+	GrowShardStorage(ctx context.Context, shardID string, percent int, maxSize string) (*operator.StorageExpansion, error)
+}
+
+// StorageExpansionPolicy sets how far shards grow in place before they are split
+type StorageExpansionPolicy struct {
+	Automatic     bool   // Grow hot shards that exceed only their storage threshold instead of splitting them
+	GrowthPercent int    // Growth of each expansion, in percent of the current size
+	MaxSize       string // Size past which shards are split instead, e.g. "500Gi"; empty for no limit
 }
 
 // NewAutoSplitter creates a new auto-splitter
@@ -42,6 +67,64 @@ func NewAutoSplitter(
 	}
 }
 
+// SetNotifier sets where automatic splits are announced
+func (s *AutoSplitter) SetNotifier(notifier notify.Notifier) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.notifier = notifier
+}
+
+// SetStorageExpansion sets what grows the volumes of shards. With an
+// automatic policy, shards that exceed only their storage threshold grow
+// instead of being split, until they reach the policy's maximum size.
+func (s *AutoSplitter) SetStorageExpansion(expander StorageExpander, policy StorageExpansionPolicy) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expander = expander
+	s.expansionPolicy = policy
+}
+
+// ExpandStorage grows a shard's volumes to size, or by the policy's growth
+// when size is empty
+func (s *AutoSplitter) ExpandStorage(ctx context.Context, shardID, size string) (*operator.StorageExpansion, error) {
+	s.mu.RLock()
+	expander, policy := s.expander, s.expansionPolicy
+	s.mu.RUnlock()
+	if expander == nil {
+		return nil, ErrStorageExpansionUnsupported
+	}
+	if size != "" {
+		return expander.ExpandShardStorage(ctx, shardID, size)
+	}
+	return expander.GrowShardStorage(ctx, shardID, policy.GrowthPercent, policy.MaxSize)
+}
+
+// emit announces a split of a shard through the notifier, if one is set
+func (s *AutoSplitter) emit(ctx context.Context, shardID string, status string, detail string) {
+	s.mu.RLock()
+	notifier := s.notifier
+	s.mu.RUnlock()
+	notify.Send(ctx, notifier, notify.Event{
+		Type:     notify.EventAutoSplit,
+		Resource: shardID,
+		Status:   status,
+		Detail:   detail,
+	}, s.logger)
+}
+
+// emitExpand announces an automatic storage expansion through the notifier, if one is set
+func (s *AutoSplitter) emitExpand(ctx context.Context, shardID string, status string, detail string) {
+	s.mu.RLock()
+	notifier := s.notifier
+	s.mu.RUnlock()
+	notify.Send(ctx, notifier, notify.Event{
+		Type:     notify.EventAutoExpand,
+		Resource: shardID,
+		Status:   status,
+		Detail:   detail,
+	}, s.logger)
+}
+
 // Start begins automatic splitting monitoring
 func (s *AutoSplitter) Start(ctx context.Context) {
 	ticker := time.NewTicker(1 * time.Minute) // Check every minute
@@ -81,6 +164,11 @@ func (s *AutoSplitter) checkAndSplit(ctx context.Context) {
 			continue
 		}
 
+		// Grow shards that only run out of storage in place while they may
+		if s.expandStorage(ctx, shardID) {
+			continue
+		}
+
 		// Perform automatic split
 		if err := s.splitShard(ctx, shardID); err != nil {
 			s.logger.Error("failed to auto-split shard",
@@ -90,6 +178,45 @@ func (s *AutoSplitter) checkAndSplit(ctx context.Context) {
 	}
 }
 
+// expandStorage grows the volumes of a hot shard that exceeds only its
+// storage threshold, and reports whether it did. Shards at the maximum size,
+// or whose volumes cannot grow, are left to be split.
+func (s *AutoSplitter) expandStorage(ctx context.Context, shardID string) bool {
+	s.mu.RLock()
+	enabled := s.expander != nil && s.expansionPolicy.Automatic
+	s.mu.RUnlock()
+	if !enabled || !s.detector.IsStorageBound(shardID) {
+		return false
+	}
+
+	expansion, err := s.ExpandStorage(ctx, shardID, "")
+	if err != nil {
+		if errors.Is(err, operator.ErrStorageAtLimit) || errors.Is(err, operator.ErrVolumeExpansionNotAllowed) {
+			s.logger.Info("shard storage cannot grow, splitting instead",
+				zap.String("shard_id", shardID),
+				zap.Error(err))
+		} else {
+			s.logger.Error("failed to expand shard storage, splitting instead",
+				zap.String("shard_id", shardID),
+				zap.Error(err))
+			s.emitExpand(ctx, shardID, notify.StatusFailed, err.Error())
+		}
+		return false
+	}
+
+	// Expansions share the split cooldown so resized volumes can settle
+	s.mu.Lock()
+	s.splitHistory[shardID] = time.Now()
+	s.mu.Unlock()
+
+	s.logger.Info("auto-expanded shard storage",
+		zap.String("shard_id", shardID),
+		zap.String("from", expansion.PreviousSize),
+		zap.String("to", expansion.Size))
+	s.emitExpand(ctx, shardID, notify.StatusSucceeded, fmt.Sprintf("storage grown from %s to %s", expansion.PreviousSize, expansion.Size))
+	return true
+}
+
 // splitShard automatically splits a hot shard
 func (s *AutoSplitter) splitShard(ctx context.Context, shardID string) error {
 	s.logger.Info("auto-splitting hot shard", zap.String("shard_id", shardID))
@@ -97,7 +224,9 @@ func (s *AutoSplitter) splitShard(ctx context.Context, shardID string) error {
 	// Get source shard
 	sourceShard, err := s.catalog.GetShardByID(shardID)
 	if err != nil {
-		return fmt.Errorf("failed to get source shard: %w", err)
+		err = fmt.Errorf("failed to get source shard: %w", err)
+		s.emit(ctx, shardID, notify.StatusFailed, err.Error())
+		return err
 	}
 
 	// Determine split strategy (split into 2 shards by default)
@@ -112,7 +241,9 @@ func (s *AutoSplitter) splitShard(ctx context.Context, shardID string) error {
 	// Execute split
 	job, err := s.manager.SplitShard(ctx, splitReq)
 	if err != nil {
-		return fmt.Errorf("failed to execute split: %w", err)
+		err = fmt.Errorf("failed to execute split: %w", err)
+		s.emit(ctx, shardID, notify.StatusFailed, err.Error())
+		return err
 	}
 
 	// Record split time
@@ -124,6 +255,7 @@ func (s *AutoSplitter) splitShard(ctx context.Context, shardID string) error {
 		zap.String("shard_id", shardID),
 		zap.String("job_id", job.ID),
 		zap.Int("target_shards", len(targetShards)))
+	s.emit(ctx, shardID, notify.StatusStarted, fmt.Sprintf("hot shard split into %d shards by job %s", len(targetShards), job.ID))
 
 	return nil
 }
@@ -143,6 +275,14 @@ func (s *AutoSplitter) createTargetShards(sourceShard *models.Shard, count int)
 			Replicas:        sourceShard.Replicas, // Copy replica configuration
 			VNodeCount:      vnodeCount,           // Distribute vnodes
 		}
+		if s.manager.HasPlacementStrategy() {
+			// Let the manager pick the least-loaded host for each target
+			targetShard.PrimaryEndpoint = ""
+			targetShard.Port = sourceShard.Port
+			targetShard.Database = sourceShard.Database
+			targetShard.Username = sourceShard.Username
+			targetShard.Password = sourceShard.Password
+		}
 		targetShards = append(targetShards, targetShard)
 	}
 
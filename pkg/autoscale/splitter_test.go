@@ -0,0 +1,181 @@
+package autoscale
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/sharding-system/pkg/catalog"
+	"github.com/sharding-system/pkg/models"
+	"github.com/sharding-system/pkg/monitoring"
+	"github.com/sharding-system/pkg/notify"
+	"github.com/sharding-system/pkg/operator"
+	"go.uber.org/zap/zaptest"
+)
+
+// missingShards is a catalog that has lost every shard
+type missingShards struct {
+	catalog.Catalog
+}
+
+func (missingShards) GetShardByID(shardID string) (*models.Shard, error) {
+	return nil, errors.New("shard not found")
+}
+
+// recordingNotifier passes events on to a channel
+type recordingNotifier chan notify.Event
+
+func (n recordingNotifier) Notify(ctx context.Context, event notify.Event) error {
+	n <- event
+	return nil
+}
+
+func TestAutoSplitter_NotifiesFailedSplit(t *testing.T) {
+	s := NewAutoSplitter(nil, nil, missingShards{}, zaptest.NewLogger(t))
+	notifier := make(recordingNotifier, 1)
+	s.SetNotifier(notifier)
+
+	if err := s.splitShard(context.Background(), "shard-1"); err == nil {
+		t.Fatal("expected the split to fail")
+	}
+	select {
+	case event := <-notifier:
+		if event.Type != notify.EventAutoSplit || event.Resource != "shard-1" || event.Status != notify.StatusFailed || event.Detail == "" {
+			t.Errorf("expected a failed split notification, got %+v", event)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the failed split to be announced")
+	}
+}
+
+// monitoredShards is a catalog listing active shards for the load monitor
+type monitoredShards struct {
+	catalog.Catalog
+	ids []string
+}
+
+func (c monitoredShards) ListShards(clientAppID string) ([]models.Shard, error) {
+	shards := make([]models.Shard, 0, len(c.ids))
+	for _, id := range c.ids {
+		shards = append(shards, models.Shard{ID: id, Status: "active"})
+	}
+	return shards, nil
+}
+
+// staticMetrics reports fixed metrics for each shard
+type staticMetrics map[string]monitoring.ShardMetrics
+
+func (m staticMetrics) CollectMetrics(ctx context.Context, shardID string) (*monitoring.ShardMetrics, error) {
+	metrics := m[shardID]
+	return &metrics, nil
+}
+
+// recordingExpander records the shards it grows and fails with err
+type recordingExpander struct {
+	grown []string
+	err   error
+}
+
+func (e *recordingExpander) ExpandShardStorage(ctx context.Context, shardID, size string) (*operator.StorageExpansion, error) {
+	e.grown = append(e.grown, shardID+"="+size)
+	return &operator.StorageExpansion{ShardID: shardID, PreviousSize: "10Gi", Size: size}, e.err
+}
+
+func (e *recordingExpander) GrowShardStorage(ctx context.Context, shardID string, percent int, maxSize string) (*operator.StorageExpansion, error) {
+	if e.err != nil {
+		return nil, e.err
+	}
+	e.grown = append(e.grown, fmt.Sprintf("%s+%d%%<=%s", shardID, percent, maxSize))
+	return &operator.StorageExpansion{ShardID: shardID, PreviousSize: "10Gi", Size: "15Gi"}, nil
+}
+
+// newMonitoredDetector returns a detector once the load monitor collected metrics
+func newMonitoredDetector(t *testing.T, metrics staticMetrics) *HotShardDetector {
+	t.Helper()
+	ids := make([]string, 0, len(metrics))
+	for id := range metrics {
+		ids = append(ids, id)
+	}
+	monitor := monitoring.NewLoadMonitor(monitoredShards{ids: ids}, zaptest.NewLogger(t), 5*time.Millisecond)
+	for _, id := range ids {
+		monitor.RegisterCollector(id, metrics)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go monitor.Start(ctx)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for len(monitor.GetAllMetrics()) < len(ids) {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for metrics")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	return NewHotShardDetector(monitor, DefaultThresholds(), zaptest.NewLogger(t))
+}
+
+func TestAutoSplitter_ExpandsStorageBoundShards(t *testing.T) {
+	detector := newMonitoredDetector(t, staticMetrics{
+		"full":     {StorageUsage: 90},
+		"busy":     {StorageUsage: 90, CPUUsage: 95},
+		"unloaded": {StorageUsage: 10},
+	})
+	s := NewAutoSplitter(detector, nil, missingShards{}, zaptest.NewLogger(t))
+	notifier := make(recordingNotifier, 4)
+	s.SetNotifier(notifier)
+
+	// Without an expander storage-bound shards are split
+	if s.expandStorage(context.Background(), "full") {
+		t.Error("expected no expansion without an expander")
+	}
+
+	expander := &recordingExpander{}
+	s.SetStorageExpansion(expander, StorageExpansionPolicy{GrowthPercent: 50, MaxSize: "500Gi"})
+	if s.expandStorage(context.Background(), "full") {
+		t.Error("expected no automatic expansion unless the policy asks for it")
+	}
+	s.SetStorageExpansion(expander, StorageExpansionPolicy{Automatic: true, GrowthPercent: 50, MaxSize: "500Gi"})
+	if !s.expandStorage(context.Background(), "full") {
+		t.Error("expected a shard that only ran out of storage to grow")
+	}
+	if s.expandStorage(context.Background(), "busy") || s.expandStorage(context.Background(), "unloaded") {
+		t.Error("expected only storage-bound shards to grow")
+	}
+	if len(expander.grown) != 1 || expander.grown[0] != "full+50%<=500Gi" {
+		t.Errorf("expected full to grow by the policy, got %v", expander.grown)
+	}
+	if !s.isInCooldown("full") {
+		t.Error("expected the expanded shard to cool down")
+	}
+	if event := <-notifier; event.Type != notify.EventAutoExpand || event.Resource != "full" || event.Status != notify.StatusSucceeded {
+		t.Errorf("expected a storage expansion notification, got %+v", event)
+	}
+
+	// Shards at the maximum size are split instead
+	s.SetStorageExpansion(&recordingExpander{err: operator.ErrStorageAtLimit}, StorageExpansionPolicy{Automatic: true, GrowthPercent: 50, MaxSize: "500Gi"})
+	if s.expandStorage(context.Background(), "full") {
+		t.Error("expected a shard at the maximum size not to grow")
+	}
+}
+
+func TestAutoSplitter_ExpandStorage(t *testing.T) {
+	s := NewAutoSplitter(nil, nil, missingShards{}, zaptest.NewLogger(t))
+	if _, err := s.ExpandStorage(context.Background(), "shard-1", "20Gi"); !errors.Is(err, ErrStorageExpansionUnsupported) {
+		t.Errorf("expected expansion without an expander to be unsupported, got %v", err)
+	}
+
+	expander := &recordingExpander{}
+	s.SetStorageExpansion(expander, StorageExpansionPolicy{GrowthPercent: 25})
+	if _, err := s.ExpandStorage(context.Background(), "shard-1", "20Gi"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := s.ExpandStorage(context.Background(), "shard-1", ""); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{"shard-1=20Gi", "shard-1+25%<="}
+	if fmt.Sprint(expander.grown) != fmt.Sprint(want) {
+		t.Errorf("expected %v, got %v", want, expander.grown)
+	}
+}
@@ -0,0 +1,141 @@
+package backup
+
+import (
+	"context"
+	"sync"
+)
+
+// DefaultMaxConcurrentBackups is how many backups run at once unless set
+// with SetMaxConcurrent
+const DefaultMaxConcurrentBackups = 2
+
+// backupLimiter caps how many backups run at once. Backups over the limit
+// queue per database, and freed slots go to the databases in turn, so one
+// tenant with many backups due cannot hold up everyone else's.
+type backupLimiter struct {
+	mu      sync.Mutex
+	limit   int
+	running int
+	waiting map[string][]chan struct{} // Queued backups by database, oldest first
+	turns   []string                   // Databases with queued backups, next to run first
+}
+
+// newBackupLimiter creates a limiter allowing limit backups at once
+func newBackupLimiter(limit int) *backupLimiter {
+	if limit < 1 {
+		limit = 1
+	}
+	return &backupLimiter{
+		limit:   limit,
+		waiting: make(map[string][]chan struct{}),
+	}
+}
+
+// acquire waits for a slot to back up a database. The returned function
+// must be called when the backup finishes.
+func (l *backupLimiter) acquire(ctx context.Context, databaseID string) (func(), error) {
+	l.mu.Lock()
+	if l.running < l.limit && len(l.turns) == 0 {
+		l.running++
+		l.mu.Unlock()
+		return l.release, nil
+	}
+
+	ready := make(chan struct{})
+	if len(l.waiting[databaseID]) == 0 {
+		l.turns = append(l.turns, databaseID)
+	}
+	l.waiting[databaseID] = append(l.waiting[databaseID], ready)
+	l.mu.Unlock()
+
+	select {
+	case <-ready:
+		return l.release, nil
+	case <-ctx.Done():
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if !l.removeWaiter(databaseID, ready) {
+			// A slot was handed over just as we gave up; pass it on
+			l.running--
+			l.dispatch()
+		}
+		return nil, ctx.Err()
+	}
+}
+
+// release frees a slot and hands it to the next database in turn
+func (l *backupLimiter) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.running--
+	l.dispatch()
+}
+
+// setLimit changes how many backups run at once. Raising it starts queued
+// backups straight away; lowering it lets running backups finish.
+func (l *backupLimiter) setLimit(limit int) {
+	if limit < 1 {
+		limit = 1
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.limit = limit
+	l.dispatch()
+}
+
+// full reports whether a new backup would have to queue, with how many
+// backups are running and queued
+func (l *backupLimiter) full() (full bool, running, queued int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, queue := range l.waiting {
+		queued += len(queue)
+	}
+	return l.running >= l.limit || queued > 0, l.running, queued
+}
+
+// dispatch starts queued backups while slots are free, taking the oldest
+// backup of each database in turn. Must be called with l.mu held.
+func (l *backupLimiter) dispatch() {
+	for l.running < l.limit && len(l.turns) > 0 {
+		databaseID := l.turns[0]
+		l.turns = l.turns[1:]
+
+		queue := l.waiting[databaseID]
+		ready := queue[0]
+		if len(queue) > 1 {
+			l.waiting[databaseID] = queue[1:]
+			l.turns = append(l.turns, databaseID)
+		} else {
+			delete(l.waiting, databaseID)
+		}
+
+		l.running++
+		close(ready)
+	}
+}
+
+// removeWaiter drops a queued backup, reporting whether it was still queued.
+// Must be called with l.mu held.
+func (l *backupLimiter) removeWaiter(databaseID string, ready chan struct{}) bool {
+	queue := l.waiting[databaseID]
+	for i, ch := range queue {
+		if ch != ready {
+			continue
+		}
+		queue = append(queue[:i], queue[i+1:]...)
+		if len(queue) > 0 {
+			l.waiting[databaseID] = queue
+			return true
+		}
+		delete(l.waiting, databaseID)
+		for j, turn := range l.turns {
+			if turn == databaseID {
+				l.turns = append(l.turns[:j], l.turns[j+1:]...)
+				break
+			}
+		}
+		return true
+	}
+	return false
+}
@@ -0,0 +1,192 @@
+package backup
+
+import (
+	"context"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sharding-system/pkg/storage"
+	"go.uber.org/zap/zaptest"
+)
+
+// blockingStore is object storage whose uploads wait to be released, and
+// which records how many uploads ran at once
+type blockingStore struct {
+	storage.ObjectStorage
+	release chan struct{}
+
+	mu      sync.Mutex
+	running int
+	peak    int
+	order   []string // Database of each upload, in start order
+}
+
+func (s *blockingStore) Upload(ctx context.Context, bucket, key string, data io.Reader, metadata map[string]string) error {
+	s.mu.Lock()
+	s.running++
+	if s.running > s.peak {
+		s.peak = s.running
+	}
+	s.order = append(s.order, metadata["database_id"])
+	s.mu.Unlock()
+
+	<-s.release
+
+	s.mu.Lock()
+	s.running--
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *blockingStore) started() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.order)
+}
+
+// waitForUploads waits until n uploads have started
+func waitForUploads(t *testing.T, store *blockingStore, n int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for store.started() < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d uploads to start, got %d", n, store.started())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// newLimitedService creates a backup service whose databases all back up to store
+func newLimitedService(t *testing.T, store *blockingStore, databases ...string) *BackupService {
+	s := NewBackupService(t.TempDir(), zaptest.NewLogger(t))
+	resolver := staticResolver{}
+	for _, databaseID := range databases {
+		cfg := storage.StorageConfig{Type: "local", Endpoint: "/backups/" + databaseID}
+		resolver[databaseID] = struct {
+			cfg    storage.StorageConfig
+			bucket string
+		}{cfg: cfg, bucket: "backups"}
+		s.backends[databaseID] = &backupBackend{cfg: cfg, bucket: "backups", store: store}
+	}
+	s.SetStorageResolver(resolver)
+	return s
+}
+
+func TestBackupService_LimitsConcurrentBackups(t *testing.T) {
+	store := &blockingStore{release: make(chan struct{})}
+	databases := []string{"tenant-a", "tenant-b", "tenant-c"}
+	s := newLimitedService(t, store, databases...)
+	s.SetMaxConcurrent(2)
+
+	ids := make([]string, 0)
+	for i := 0; i < 4; i++ {
+		for _, databaseID := range databases {
+			created, err := s.CreateBackup(context.Background(), databaseID, "full")
+			if err != nil {
+				t.Fatal(err)
+			}
+			ids = append(ids, created.ID)
+		}
+	}
+
+	waitForUploads(t, store, 2)
+	time.Sleep(20 * time.Millisecond)
+	if got := store.started(); got != 2 {
+		t.Fatalf("expected 2 backups running with the rest queued, got %d started", got)
+	}
+	pending := 0
+	for _, id := range ids {
+		if backup, _ := s.GetBackup(id); backup.Status == "pending" {
+			pending++
+		}
+	}
+	if pending != len(ids)-2 {
+		t.Errorf("expected %d queued backups to stay pending, got %d", len(ids)-2, pending)
+	}
+
+	close(store.release)
+	for _, id := range ids {
+		if backup := waitForBackup(t, s, id); backup.Status != "completed" {
+			t.Fatalf("expected backup %s to complete, got %s: %s", id, backup.Status, backup.Error)
+		}
+	}
+	if store.peak != 2 {
+		t.Errorf("expected at most 2 backups at once, peak was %d", store.peak)
+	}
+}
+
+func TestBackupLimiter_TakesTurnsAcrossDatabases(t *testing.T) {
+	l := newBackupLimiter(1)
+	ctx := context.Background()
+	hold, _ := l.acquire(ctx, "busy")
+
+	// One tenant queues many backups before two others queue one each
+	order := make(chan string, 8)
+	var wg sync.WaitGroup
+	enqueue := func(databaseID string) {
+		wg.Add(1)
+		queuedBefore := queuedCount(l)
+		go func() {
+			defer wg.Done()
+			release, err := l.acquire(ctx, databaseID)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			order <- databaseID
+			release()
+		}()
+		// Wait until it has queued, so the queue order is known
+		for queuedCount(l) == queuedBefore {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	for i := 0; i < 3; i++ {
+		enqueue("busy")
+	}
+	enqueue("quiet-1")
+	enqueue("quiet-2")
+
+	hold()
+	wg.Wait()
+	close(order)
+
+	got := ""
+	for databaseID := range order {
+		got += databaseID + " "
+	}
+	if want := "busy quiet-1 quiet-2 busy busy "; got != want {
+		t.Errorf("expected databases to take turns: want %q, got %q", want, got)
+	}
+}
+
+func TestBackupLimiter_CancelledWhileQueued(t *testing.T) {
+	l := newBackupLimiter(1)
+	hold, _ := l.acquire(context.Background(), "a")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error, 1)
+	go func() {
+		_, err := l.acquire(ctx, "b")
+		errs <- err
+	}()
+	for queuedCount(l) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	if err := <-errs; err == nil {
+		t.Fatal("expected a cancelled backup to give up its place")
+	}
+
+	hold()
+	if full, running, queued := l.full(); full || running != 0 || queued != 0 {
+		t.Errorf("expected an idle limiter, got running %d queued %d", running, queued)
+	}
+}
+
+func queuedCount(l *backupLimiter) int {
+	_, _, queued := l.full()
+	return queued
+}
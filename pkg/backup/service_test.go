@@ -0,0 +1,194 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sharding-system/pkg/notify"
+	"github.com/sharding-system/pkg/storage"
+	"go.uber.org/zap/zaptest"
+)
+
+// staticResolver maps database IDs to fixed storage targets
+type staticResolver map[string]struct {
+	cfg    storage.StorageConfig
+	bucket string
+}
+
+func (r staticResolver) BackupStorage(databaseID string) (storage.StorageConfig, string, bool) {
+	target, ok := r[databaseID]
+	return target.cfg, target.bucket, ok
+}
+
+// waitForBackup polls until a backup leaves the pending and in-progress states
+func waitForBackup(t *testing.T, s *BackupService, backupID string) Backup {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		s.mu.RLock()
+		backup := *s.backups[backupID]
+		s.mu.RUnlock()
+		if backup.Status == "completed" || backup.Status == "failed" {
+			return backup
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("backup %s did not finish", backupID)
+	return Backup{}
+}
+
+func TestBackupService_PerDatabaseStorage(t *testing.T) {
+	s := NewBackupService(t.TempDir(), zaptest.NewLogger(t))
+	s.SetStorageResolver(staticResolver{
+		"tenant-a": {cfg: storage.StorageConfig{Type: "local", Endpoint: "/backups/tenant-a"}, bucket: "tenant-a-bucket"},
+		"tenant-b": {cfg: storage.StorageConfig{Type: "local", Endpoint: "/backups/tenant-b"}, bucket: "tenant-b-bucket"},
+	})
+
+	ctx := context.Background()
+	backups := make(map[string]Backup)
+	for _, databaseID := range []string{"tenant-a", "tenant-b"} {
+		created, err := s.CreateBackup(ctx, databaseID, "full")
+		if err != nil {
+			t.Fatalf("failed to create backup for %s: %v", databaseID, err)
+		}
+		backups[databaseID] = waitForBackup(t, s, created.ID)
+	}
+
+	a, b := s.backends["tenant-a"], s.backends["tenant-b"]
+	if a == nil || b == nil || a.store == b.store {
+		t.Fatalf("expected each database to get its own backend, got %+v and %+v", a, b)
+	}
+
+	for databaseID, backup := range backups {
+		if backup.Status != "completed" {
+			t.Fatalf("expected %s backup to complete, got %s: %s", databaseID, backup.Status, backup.Error)
+		}
+		if backup.StorageType != "local" || backup.Bucket != databaseID+"-bucket" {
+			t.Errorf("expected %s backup in its own bucket, got %s/%s", databaseID, backup.StorageType, backup.Bucket)
+		}
+
+		own, other := s.backends[databaseID], a
+		if own == a {
+			other = b
+		}
+		rc, err := own.store.Download(ctx, backup.Bucket, backup.StoragePath)
+		if err != nil {
+			t.Fatalf("expected %s backup in its backend: %v", databaseID, err)
+		}
+		data, _ := io.ReadAll(rc)
+		rc.Close()
+		if !strings.Contains(string(data), "Backup for database "+databaseID) {
+			t.Errorf("unexpected backup contents %q", data)
+		}
+		if exists, _ := other.store.Exists(ctx, backup.Bucket, backup.StoragePath); exists {
+			t.Errorf("expected %s backup to be absent from the other tenant's backend", databaseID)
+		}
+	}
+}
+
+func TestBackupService_FallsBackToStoragePath(t *testing.T) {
+	s := NewBackupService(t.TempDir(), zaptest.NewLogger(t))
+	s.SetStorageResolver(staticResolver{})
+
+	created, err := s.CreateBackup(context.Background(), "shared", "full")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	backup := waitForBackup(t, s, created.ID)
+	if backup.Status != "completed" || backup.StorageType != "file" {
+		t.Fatalf("expected a completed file backup, got %+v", backup)
+	}
+	if _, err := os.Stat(backup.StoragePath); err != nil {
+		t.Errorf("expected backup file at %s: %v", backup.StoragePath, err)
+	}
+}
+
+func TestBackupService_OutlivesTheRequest(t *testing.T) {
+	s := NewBackupService(t.TempDir(), zaptest.NewLogger(t))
+	s.limiter = newBackupLimiter(1)
+	hold, _ := s.limiter.acquire(context.Background(), "other")
+
+	// The backup queues behind another one and the request that created it ends
+	ctx, cancel := context.WithCancel(context.Background())
+	created, err := s.CreateBackup(ctx, "orders", "full")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for queuedCount(s.limiter) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	hold()
+
+	if backup := waitForBackup(t, s, created.ID); backup.Status != "completed" {
+		t.Errorf("expected the backup to complete after its request ended, got %s: %s", backup.Status, backup.Error)
+	}
+}
+
+func TestBackupService_RestoreBackupUnsupported(t *testing.T) {
+	s := NewBackupService(t.TempDir(), zaptest.NewLogger(t))
+	created, err := s.CreateBackup(context.Background(), "orders", "full")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	waitForBackup(t, s, created.ID)
+
+	if err := s.RestoreBackup(context.Background(), created.ID, "orders-copy"); !errors.Is(err, ErrFullRestoreUnsupported) {
+		t.Errorf("expected ErrFullRestoreUnsupported, got %v", err)
+	}
+}
+
+func TestBackupService_RejectsUnsupportedStorage(t *testing.T) {
+	s := NewBackupService(t.TempDir(), zaptest.NewLogger(t))
+	s.SetStorageResolver(staticResolver{"tenant": {cfg: storage.StorageConfig{Type: "ftp"}}})
+
+	if _, err := s.CreateBackup(context.Background(), "tenant", "full"); err == nil {
+		t.Error("expected an error for an unsupported storage type")
+	}
+}
+
+// recordingNotifier passes events on to a channel
+type recordingNotifier chan notify.Event
+
+func (n recordingNotifier) Notify(ctx context.Context, event notify.Event) error {
+	n <- event
+	return nil
+}
+
+func TestBackupService_NotifiesBackupOutcomes(t *testing.T) {
+	dir := t.TempDir()
+	s := NewBackupService(dir, zaptest.NewLogger(t))
+	notifier := make(recordingNotifier, 10)
+	s.SetNotifier(notifier)
+
+	created, err := s.CreateBackup(context.Background(), "orders", "full")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	waitForBackup(t, s, created.ID)
+	if event := <-notifier; event.Type != notify.EventBackup || event.Resource != created.ID || event.Status != notify.StatusSucceeded || event.Time.IsZero() {
+		t.Errorf("expected a backup completion notification, got %+v", event)
+	}
+
+	// A file where the database's backup directory should go fails the backup
+	if err := os.WriteFile(filepath.Join(dir, "customers"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	created, err = s.CreateBackup(context.Background(), "customers", "full")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if backup := waitForBackup(t, s, created.ID); backup.Status != "failed" {
+		t.Fatalf("expected the backup to fail, got %s", backup.Status)
+	}
+	event := <-notifier
+	if event.Resource != created.ID || event.Status != notify.StatusFailed || !strings.Contains(event.Detail, "customers") {
+		t.Errorf("expected a backup failure notification, got %+v", event)
+	}
+}
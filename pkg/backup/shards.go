@@ -0,0 +1,156 @@
+package backup
+
+import (
+	"sort"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ShardResolver resolves the shards a database's backups cover
+type ShardResolver interface {
+	BackupShards(databaseID string) []string
+}
+
+// ShardResolvers resolves shards with the first resolver that knows the database
+type ShardResolvers []ShardResolver
+
+// BackupShards returns the shards of the first resolver with any for the database
+func (r ShardResolvers) BackupShards(databaseID string) []string {
+	for _, resolver := range r {
+		if shardIDs := resolver.BackupShards(databaseID); len(shardIDs) > 0 {
+			return shardIDs
+		}
+	}
+	return nil
+}
+
+// ShardBackupStatus is how a shard's backups are going, so shards falling
+// behind can be spotted
+type ShardBackupStatus struct {
+	ShardID    string `json:"shard_id"`
+	DatabaseID string `json:"database_id"`
+	// LastBackupAt is when the shard's last successful backup completed
+	LastBackupAt *time.Time `json:"last_backup_at,omitempty"`
+	LastBackupID string     `json:"last_backup_id,omitempty"`
+	// LastStatus is the outcome of the latest backup to finish, "completed" or "failed"
+	LastStatus    string    `json:"last_status,omitempty"`
+	LastError     string    `json:"last_error,omitempty"`
+	LastAttemptAt time.Time `json:"last_attempt_at"`
+}
+
+// SetShardResolver sets how the shards covered by each backup are resolved.
+// Backups of databases it knows no shards for are not tracked per shard.
+func (s *BackupService) SetShardResolver(resolver ShardResolver) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.shardResolver = resolver
+}
+
+// SetOnBackupFinished sets a callback for when a backup completes or fails
+func (s *BackupService) SetOnBackupFinished(callback func(Backup)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onFinished = callback
+}
+
+// backupShards returns the shards a backup of a database covers
+func (s *BackupService) backupShards(databaseID string) []string {
+	s.mu.RLock()
+	resolver := s.shardResolver
+	s.mu.RUnlock()
+	if resolver == nil {
+		return nil
+	}
+	return resolver.BackupShards(databaseID)
+}
+
+// recordShards updates the status of the shards a finished backup covers.
+// It must be called with s.mu held.
+func (s *BackupService) recordShards(backup *Backup, at time.Time) {
+	for _, shardID := range backup.ShardIDs {
+		status, ok := s.shardStatus[shardID]
+		if !ok {
+			status = &ShardBackupStatus{ShardID: shardID}
+			s.shardStatus[shardID] = status
+		}
+		status.DatabaseID = backup.DatabaseID
+		status.LastStatus = backup.Status
+		status.LastAttemptAt = at
+		status.LastError = backup.Error
+		if backup.Status == "completed" {
+			completedAt := at
+			status.LastBackupAt = &completedAt
+			status.LastBackupID = backup.ID
+		}
+	}
+}
+
+// finished announces a finished backup to the callback, if one is set
+func (s *BackupService) finished(backup *Backup) {
+	s.mu.RLock()
+	callback, snapshot := s.onFinished, *backup
+	s.mu.RUnlock()
+	if callback != nil {
+		callback(snapshot)
+	}
+}
+
+// ShardBackupStatus returns how a shard's backups are going, false until a
+// backup covering it has finished
+func (s *BackupService) ShardBackupStatus(shardID string) (ShardBackupStatus, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	status, ok := s.shardStatus[shardID]
+	if !ok {
+		return ShardBackupStatus{}, false
+	}
+	return *status, true
+}
+
+// ShardBackupStatuses returns the backup status of every tracked shard, by shard ID
+func (s *BackupService) ShardBackupStatuses() []ShardBackupStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	statuses := make([]ShardBackupStatus, 0, len(s.shardStatus))
+	for _, status := range s.shardStatus {
+		statuses = append(statuses, *status)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].ShardID < statuses[j].ShardID })
+	return statuses
+}
+
+// lastBackupAge is the description of sharding_shard_last_backup_age_seconds
+var lastBackupAge = prometheus.NewDesc(
+	"sharding_shard_last_backup_age_seconds",
+	"Seconds since the shard's last successful backup completed; absent until one has",
+	[]string{"shard_id", "database"}, nil,
+)
+
+// backupMetrics exposes the age of each shard's last backup, computed when
+// scraped so it keeps growing while backups are missed
+type backupMetrics struct {
+	service *BackupService
+	now     func() time.Time
+}
+
+// Metrics returns a Prometheus collector of the shards' backup ages, for
+// alerting on shards whose backups stopped succeeding
+func (s *BackupService) Metrics() prometheus.Collector {
+	return &backupMetrics{service: s, now: time.Now}
+}
+
+func (m *backupMetrics) Describe(ch chan<- *prometheus.Desc) {
+	ch <- lastBackupAge
+}
+
+func (m *backupMetrics) Collect(ch chan<- prometheus.Metric) {
+	now := m.now()
+	for _, status := range m.service.ShardBackupStatuses() {
+		if status.LastBackupAt == nil {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(lastBackupAge, prometheus.GaugeValue,
+			now.Sub(*status.LastBackupAt).Seconds(), status.ShardID, status.DatabaseID)
+	}
+}
@@ -0,0 +1,146 @@
+package backup
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.uber.org/zap/zaptest"
+)
+
+// staticShards maps database IDs to the shards their backups cover
+type staticShards map[string][]string
+
+func (r staticShards) BackupShards(databaseID string) []string {
+	return r[databaseID]
+}
+
+func TestBackupService_LoadsRecordedBackups(t *testing.T) {
+	dir := t.TempDir()
+	s := NewBackupService(dir, zaptest.NewLogger(t))
+	s.SetShardResolver(staticShards{"orders": {"orders-0", "orders-1"}})
+	finished := make(chan Backup, 2)
+	s.SetOnBackupFinished(func(b Backup) { finished <- b })
+
+	var last Backup
+	for i := 0; i < 2; i++ {
+		if _, err := s.CreateBackup(context.Background(), "orders", "full"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		last = <-finished
+	}
+
+	// The manager restarts
+	restarted := NewBackupService(dir, zaptest.NewLogger(t))
+	if err := restarted.LoadBackups(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if backups, _ := restarted.ListBackups("orders"); len(backups) != 2 {
+		t.Errorf("expected both backups to be known again, got %d", len(backups))
+	}
+	for _, shardID := range []string{"orders-0", "orders-1"} {
+		status, ok := restarted.ShardBackupStatus(shardID)
+		if !ok || status.LastBackupID != last.ID || status.LastBackupAt == nil || !status.LastBackupAt.Equal(*last.CompletedAt) {
+			t.Errorf("%s: expected the latest backup to be restored, got %+v", shardID, status)
+		}
+	}
+}
+
+func TestBackupService_TracksShardBackups(t *testing.T) {
+	dir := t.TempDir()
+	s := NewBackupService(dir, zaptest.NewLogger(t))
+	s.SetShardResolver(ShardResolvers{staticShards{}, staticShards{"orders": {"orders-0", "orders-1"}}})
+	finished := make(chan Backup, 2)
+	s.SetOnBackupFinished(func(b Backup) { finished <- b })
+
+	if _, ok := s.ShardBackupStatus("orders-0"); ok {
+		t.Fatal("expected no status before a backup finished")
+	}
+
+	created, err := s.CreateBackup(context.Background(), "orders", "full")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	completed := waitForBackup(t, s, created.ID)
+	if len(completed.ShardIDs) != 2 {
+		t.Errorf("expected the backup to cover both shards, got %v", completed.ShardIDs)
+	}
+	for _, shardID := range []string{"orders-0", "orders-1"} {
+		status, ok := s.ShardBackupStatus(shardID)
+		if !ok || status.LastStatus != "completed" || status.LastBackupID != created.ID || status.DatabaseID != "orders" {
+			t.Errorf("%s: expected the completed backup to be recorded, got %+v", shardID, status)
+		}
+		if status.LastBackupAt == nil || !status.LastBackupAt.Equal(*completed.CompletedAt) {
+			t.Errorf("%s: expected the last backup time to be the completion time, got %v", shardID, status.LastBackupAt)
+		}
+	}
+	if b := <-finished; b.ID != created.ID || b.Status != "completed" {
+		t.Errorf("expected the completed backup to be announced, got %+v", b)
+	}
+
+	// A file where the database's backup directory should go fails the next backup
+	if err := os.RemoveAll(filepath.Join(dir, "orders")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "orders"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	failed, err := s.CreateBackup(context.Background(), "orders", "full")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	waitForBackup(t, s, failed.ID)
+
+	status, _ := s.ShardBackupStatus("orders-0")
+	if status.LastStatus != "failed" || status.LastError == "" || status.LastAttemptAt.Before(*completed.CompletedAt) {
+		t.Errorf("expected the failed backup to be recorded, got %+v", status)
+	}
+	if status.LastBackupID != created.ID || status.LastBackupAt == nil || !status.LastBackupAt.Equal(*completed.CompletedAt) {
+		t.Errorf("expected the last successful backup to be kept, got %+v", status)
+	}
+	if b := <-finished; b.ID != failed.ID || b.Status != "failed" {
+		t.Errorf("expected the failed backup to be announced, got %+v", b)
+	}
+}
+
+func TestBackupService_UntrackedWithoutShards(t *testing.T) {
+	s := NewBackupService(t.TempDir(), zaptest.NewLogger(t))
+	s.SetShardResolver(staticShards{})
+
+	created, err := s.CreateBackup(context.Background(), "orders", "full")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	waitForBackup(t, s, created.ID)
+	if statuses := s.ShardBackupStatuses(); len(statuses) != 0 {
+		t.Errorf("expected no shard statuses for a database without shards, got %+v", statuses)
+	}
+}
+
+func TestBackupService_LastBackupAgeMetric(t *testing.T) {
+	s := NewBackupService(t.TempDir(), zaptest.NewLogger(t))
+	now := time.Now()
+	completedAt := now.Add(-90 * time.Minute)
+	s.shardStatus = map[string]*ShardBackupStatus{
+		"orders-0": {ShardID: "orders-0", DatabaseID: "orders", LastBackupAt: &completedAt, LastStatus: "failed"},
+		"orders-1": {ShardID: "orders-1", DatabaseID: "orders", LastStatus: "failed"},
+	}
+	metrics := s.Metrics().(*backupMetrics)
+	metrics.now = func() time.Time { return now }
+
+	registry := prometheus.NewPedanticRegistry()
+	registry.MustRegister(metrics)
+	expected := `
+		# HELP sharding_shard_last_backup_age_seconds Seconds since the shard's last successful backup completed; absent until one has
+		# TYPE sharding_shard_last_backup_age_seconds gauge
+		sharding_shard_last_backup_age_seconds{database="orders",shard_id="orders-0"} 5400
+	`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expected), "sharding_shard_last_backup_age_seconds"); err != nil {
+		t.Error(err)
+	}
+}
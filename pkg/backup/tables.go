@@ -0,0 +1,333 @@
+package backup
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// TableRestoreRequest selects the tables to restore from a backup
+type TableRestoreRequest struct {
+	// Tables to restore, as "table" or "schema.table"; unqualified names are in public
+	Tables []string `json:"tables"`
+	// IncludeReferenced also restores the tables the selected tables reference
+	// through foreign keys, and the tables those reference
+	IncludeReferenced bool `json:"include_referenced,omitempty"`
+	// StagingSchema restores the tables into this schema instead of over the
+	// live tables, so they can be checked and copied back by hand
+	StagingSchema string `json:"staging_schema,omitempty"`
+}
+
+// TableRestoreResult describes a completed table restore
+type TableRestoreResult struct {
+	BackupID         string   `json:"backup_id"`
+	TargetDatabaseID string   `json:"target_database_id"`
+	Tables           []string `json:"tables"`                   // Restored tables, including referenced ones
+	Referenced       []string `json:"referenced,omitempty"`     // Tables restored because a selected table references them
+	StagingSchema    string   `json:"staging_schema,omitempty"` // Schema the tables were restored into, if not their own
+}
+
+// Markers of pg_dump output
+const (
+	customDumpMagic   = "PGDMP"                                    // Start of a custom-format archive
+	plainDumpHeader   = "--\n-- PostgreSQL database dump\n"        // Start of a plain SQL dump
+	plainDumpComplete = "\n-- PostgreSQL database dump complete\n" // Written once the whole dump is out
+)
+
+var (
+	stagingSchemaPattern = regexp.MustCompile(`^[a-z_][a-z0-9_]*$`)
+
+	createTablePattern = regexp.MustCompile(`^CREATE (?:UNLOGGED )?TABLE (\S+) \(`)
+	alterTablePattern  = regexp.MustCompile(`^ALTER TABLE (?:ONLY )?(\S+)`)
+	createIndexPattern = regexp.MustCompile(`^CREATE (?:UNIQUE )?INDEX \S+ ON (?:ONLY )?(\S+)`)
+	copyPattern        = regexp.MustCompile(`^COPY (\S+) (?:\(.*\) )?FROM stdin;`)
+	insertPattern      = regexp.MustCompile(`^INSERT INTO (\S+)`)
+	sequencePattern    = regexp.MustCompile(`^(?:CREATE|ALTER) SEQUENCE (\S+)`)
+	setvalPattern      = regexp.MustCompile(`^SELECT pg_catalog\.setval\('([^']+)'`)
+	ownedByPattern     = regexp.MustCompile(`OWNED BY (\S+)\.[^.\s;]+;`)
+	referencesPattern  = regexp.MustCompile(`FOREIGN KEY .* REFERENCES (\S+?)\(`)
+)
+
+// dumpScript returns a backup as a plain SQL dump, converting custom-format
+// archives with pg_restore. Anything else, such as the placeholder written
+// when no dump was taken, or a dump cut short, is refused.
+func (s *BackupService) dumpScript(ctx context.Context, data []byte) ([]byte, error) {
+	if bytes.HasPrefix(data, []byte(customDumpMagic)) {
+		script, err := s.toScript(ctx, data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read custom-format dump: %w", err)
+		}
+		data = script
+	}
+	if !bytes.HasPrefix(bytes.TrimLeft(data, "\n"), []byte(plainDumpHeader)) {
+		return nil, fmt.Errorf("not a pg_dump in plain or custom format")
+	}
+	if !bytes.Contains(data, []byte(plainDumpComplete)) {
+		return nil, fmt.Errorf("dump is incomplete")
+	}
+	return data, nil
+}
+
+// dumpStatement is one statement of a plain SQL dump
+type dumpStatement struct {
+	sql        string
+	object     string // Table or sequence the statement belongs to, if any
+	references string // Table a foreign key constraint references
+}
+
+// sqlDump is a parsed plain-format pg_dump
+type sqlDump struct {
+	preamble   []string          // Session settings run before anything else
+	statements []dumpStatement   // Statements on tables and sequences, in dump order
+	tables     map[string]string // Tables by normalized name, with the name as written in the dump
+	sequences  map[string]string // Sequences by normalized name, with the table that owns them
+}
+
+// parseDump splits a plain-format pg_dump into statements and works out which
+// table each belongs to. Statements on other objects, such as functions and
+// views, are not needed to restore tables and are dropped.
+func parseDump(data []byte) (*sqlDump, error) {
+	dump := &sqlDump{
+		tables:    make(map[string]string),
+		sequences: make(map[string]string),
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	var stmt strings.Builder
+	inCopy := false
+	for scanner.Scan() {
+		line := scanner.Text()
+		if inCopy {
+			stmt.WriteString(line)
+			stmt.WriteString("\n")
+			if line == `\.` {
+				dump.add(stmt.String())
+				stmt.Reset()
+				inCopy = false
+			}
+			continue
+		}
+		if stmt.Len() == 0 && (line == "" || strings.HasPrefix(line, "--")) {
+			continue
+		}
+
+		stmt.WriteString(line)
+		stmt.WriteString("\n")
+		if copyPattern.MatchString(stmt.String()) {
+			inCopy = true
+			continue
+		}
+		if strings.HasSuffix(line, ";") {
+			dump.add(stmt.String())
+			stmt.Reset()
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read backup: %w", err)
+	}
+	if inCopy {
+		return nil, fmt.Errorf("backup is truncated: COPY data is not terminated")
+	}
+
+	// Sequences belong to the table whose column owns them
+	for i := range dump.statements {
+		if seq, ok := dump.sequences[dump.statements[i].object]; ok && seq != "" {
+			dump.statements[i].object = seq
+		}
+	}
+	return dump, nil
+}
+
+// add classifies a statement by the object it belongs to
+func (d *sqlDump) add(sql string) {
+	first := sql
+	if i := strings.IndexByte(sql, '\n'); i >= 0 {
+		first = sql[:i]
+	}
+
+	if strings.HasPrefix(first, "SET ") || strings.HasPrefix(first, "SELECT pg_catalog.set_config(") {
+		d.preamble = append(d.preamble, sql)
+		return
+	}
+
+	stmt := dumpStatement{sql: sql}
+	switch {
+	case createTablePattern.MatchString(first):
+		name := createTablePattern.FindStringSubmatch(first)[1]
+		stmt.object = normalizeTableName(name)
+		d.tables[stmt.object] = name
+	case sequencePattern.MatchString(first):
+		stmt.object = normalizeTableName(sequencePattern.FindStringSubmatch(first)[1])
+		if _, ok := d.sequences[stmt.object]; !ok {
+			d.sequences[stmt.object] = ""
+		}
+		if owner := ownedByPattern.FindStringSubmatch(sql); owner != nil {
+			d.sequences[stmt.object] = normalizeTableName(owner[1])
+		}
+	case setvalPattern.MatchString(first):
+		stmt.object = normalizeTableName(setvalPattern.FindStringSubmatch(first)[1])
+	case alterTablePattern.MatchString(first):
+		stmt.object = normalizeTableName(alterTablePattern.FindStringSubmatch(first)[1])
+		if ref := referencesPattern.FindStringSubmatch(strings.ReplaceAll(sql, "\n", " ")); ref != nil {
+			stmt.references = normalizeTableName(ref[1])
+		}
+	case createIndexPattern.MatchString(first):
+		stmt.object = normalizeTableName(createIndexPattern.FindStringSubmatch(first)[1])
+	case copyPattern.MatchString(first):
+		stmt.object = normalizeTableName(copyPattern.FindStringSubmatch(first)[1])
+	case insertPattern.MatchString(first):
+		stmt.object = normalizeTableName(insertPattern.FindStringSubmatch(first)[1])
+	default:
+		return
+	}
+	d.statements = append(d.statements, stmt)
+}
+
+// normalizeTableName qualifies a table name with its schema and strips quotes
+func normalizeTableName(name string) string {
+	name = strings.ReplaceAll(name, `"`, "")
+	if !strings.Contains(name, ".") {
+		name = "public." + name
+	}
+	return name
+}
+
+// selectTables resolves the requested tables, adding the tables they reference
+// when asked to. It returns all tables to restore and those that were added.
+func (d *sqlDump) selectTables(req TableRestoreRequest) (selected map[string]bool, referenced []string, err error) {
+	selected = make(map[string]bool)
+	for _, table := range req.Tables {
+		name := normalizeTableName(strings.TrimSpace(table))
+		if _, ok := d.tables[name]; !ok {
+			return nil, nil, fmt.Errorf("table %s is not in the backup", table)
+		}
+		selected[name] = true
+	}
+	if !req.IncludeReferenced {
+		return selected, nil, nil
+	}
+
+	for added := true; added; {
+		added = false
+		for _, stmt := range d.statements {
+			if !selected[stmt.object] || stmt.references == "" || selected[stmt.references] {
+				continue
+			}
+			if _, ok := d.tables[stmt.references]; !ok {
+				return nil, nil, fmt.Errorf("table %s references %s, which is not in the backup", stmt.object, stmt.references)
+			}
+			selected[stmt.references] = true
+			referenced = append(referenced, stmt.references)
+			added = true
+		}
+	}
+	sort.Strings(referenced)
+	return selected, referenced, nil
+}
+
+// tableRestoreScript builds a SQL script restoring the selected tables of a
+// dump. Restored tables are dropped and recreated first, so the script must
+// run in a single transaction: if another table depends on one being
+// restored, the drop fails and nothing changes. In a staging schema the
+// tables are created alongside the live ones, and foreign keys to tables not
+// restored keep pointing at the live tables.
+func (d *sqlDump) tableRestoreScript(selected map[string]bool, stagingSchema string) string {
+	// Restored tables and their sequences move to the staging schema
+	rename := make(map[string]string)
+	for name := range selected {
+		if stagingSchema != "" {
+			rename[d.tables[name]] = stagingSchema + "." + tableOnly(d.tables[name])
+		}
+	}
+	for seq, owner := range d.sequences {
+		if selected[owner] && stagingSchema != "" {
+			rename[seq] = stagingSchema + "." + tableOnly(seq)
+		}
+	}
+
+	var script strings.Builder
+	for _, sql := range d.preamble {
+		script.WriteString(sql)
+	}
+	script.WriteString("\n")
+	if stagingSchema != "" {
+		fmt.Fprintf(&script, "CREATE SCHEMA IF NOT EXISTS %s;\n", stagingSchema)
+	}
+	// One statement, so foreign keys between the restored tables do not block it
+	targets := make([]string, 0, len(selected))
+	for _, name := range sortedTables(selected) {
+		target := d.tables[name]
+		if stagingSchema != "" {
+			target = rename[target]
+		}
+		targets = append(targets, target)
+	}
+	fmt.Fprintf(&script, "DROP TABLE IF EXISTS %s;\n\n", strings.Join(targets, ", "))
+
+	for _, stmt := range d.statements {
+		if !selected[stmt.object] {
+			continue
+		}
+		script.WriteString(renameObjects(stmt.sql, rename))
+	}
+	return script.String()
+}
+
+// tableOnly returns a table name without its schema
+func tableOnly(name string) string {
+	if i := strings.LastIndexByte(name, '.'); i >= 0 {
+		return name[i+1:]
+	}
+	return name
+}
+
+// renameObjects rewrites whole object names in a statement, leaving longer
+// names that merely start with one alone
+func renameObjects(sql string, rename map[string]string) string {
+	if len(rename) == 0 {
+		return sql
+	}
+	names := make([]string, 0, len(rename))
+	for name := range rename {
+		names = append(names, regexp.QuoteMeta(name))
+	}
+	sort.Slice(names, func(i, j int) bool { return len(names[i]) > len(names[j]) })
+
+	// Object names in dumps are bare or double quoted identifiers, so a name
+	// is whole when no identifier character follows it. A dot may, as in the
+	// column of OWNED BY public.orders.id.
+	pattern := regexp.MustCompile(`(` + strings.Join(names, "|") + `)([^\w"]|$)`)
+	return pattern.ReplaceAllStringFunc(sql, func(match string) string {
+		parts := pattern.FindStringSubmatch(match)
+		return rename[parts[1]] + parts[2]
+	})
+}
+
+// sortedTables returns the names of a set of tables in order
+func sortedTables(tables map[string]bool) []string {
+	names := make([]string, 0, len(tables))
+	for name := range tables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// validateTableRestore checks a table restore request
+func validateTableRestore(req TableRestoreRequest) error {
+	if len(req.Tables) == 0 {
+		return fmt.Errorf("at least one table is required")
+	}
+	if req.StagingSchema != "" && !stagingSchemaPattern.MatchString(req.StagingSchema) {
+		return fmt.Errorf("staging schema %q must be a lowercase identifier", req.StagingSchema)
+	}
+	if req.StagingSchema == "public" {
+		return fmt.Errorf("staging schema must not be public, restore over the live tables by leaving it empty")
+	}
+	return nil
+}
@@ -0,0 +1,298 @@
+package backup
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zaptest"
+)
+
+// testDump is a plain-format pg_dump of three tables, where orders references
+// customers and order_items references orders
+const testDump = `--
+-- PostgreSQL database dump
+--
+
+SET statement_timeout = 0;
+SET client_encoding = 'UTF8';
+SELECT pg_catalog.set_config('search_path', '', false);
+
+CREATE FUNCTION public.touch() RETURNS trigger
+    LANGUAGE plpgsql
+    AS $$BEGIN RETURN NEW; END;$$;
+
+CREATE TABLE public.customers (
+    id integer NOT NULL,
+    name text
+);
+
+CREATE TABLE public.orders (
+    id integer NOT NULL,
+    customer_id integer,
+    total numeric
+);
+
+CREATE SEQUENCE public.orders_id_seq
+    AS integer
+    START WITH 1
+    INCREMENT BY 1;
+
+ALTER SEQUENCE public.orders_id_seq OWNED BY public.orders.id;
+
+CREATE TABLE public.order_items (
+    order_id integer,
+    sku text
+);
+
+ALTER TABLE ONLY public.orders ALTER COLUMN id SET DEFAULT nextval('public.orders_id_seq'::regclass);
+
+COPY public.customers (id, name) FROM stdin;
+1	Ada
+2	Grace
+\.
+
+COPY public.orders (id, customer_id, total) FROM stdin;
+10	1	9.99
+11	2	19.99
+\.
+
+COPY public.order_items (order_id, sku) FROM stdin;
+10	widget
+\.
+
+SELECT pg_catalog.setval('public.orders_id_seq', 11, true);
+
+ALTER TABLE ONLY public.customers
+    ADD CONSTRAINT customers_pkey PRIMARY KEY (id);
+
+ALTER TABLE ONLY public.orders
+    ADD CONSTRAINT orders_pkey PRIMARY KEY (id);
+
+CREATE INDEX orders_customer_id_idx ON public.orders USING btree (customer_id);
+
+ALTER TABLE ONLY public.order_items
+    ADD CONSTRAINT order_items_order_id_fkey FOREIGN KEY (order_id) REFERENCES public.orders(id);
+
+ALTER TABLE ONLY public.orders
+    ADD CONSTRAINT orders_customer_id_fkey FOREIGN KEY (customer_id) REFERENCES public.customers(id);
+
+--
+-- PostgreSQL database dump complete
+--
+`
+
+// scriptRecorder stands in for psql, keeping the scripts it was given
+type scriptRecorder struct {
+	connectionString string
+	script           string
+}
+
+func (r *scriptRecorder) run(ctx context.Context, connectionString string, script []byte) error {
+	r.connectionString = connectionString
+	r.script = string(script)
+	return nil
+}
+
+// staticConnections maps database IDs to connection strings
+type staticConnections map[string]string
+
+func (c staticConnections) ConnectionString(databaseID string) (string, bool) {
+	conn, ok := c[databaseID]
+	return conn, ok
+}
+
+// newTableRestoreService returns a backup service holding a completed backup of testDump
+func newTableRestoreService(t *testing.T) (*BackupService, *scriptRecorder) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "backup.sql")
+	if err := os.WriteFile(path, []byte(testDump), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewBackupService(dir, zaptest.NewLogger(t))
+	now := time.Now()
+	s.backups["b-1"] = &Backup{ID: "b-1", DatabaseID: "shop", Status: "completed", StorageType: "file", StoragePath: path, CompletedAt: &now}
+	s.SetConnectionResolver(staticConnections{"shop": "postgres://shop-primary/shop"})
+	recorder := &scriptRecorder{}
+	s.runScript = recorder.run
+	return s, recorder
+}
+
+func TestRestoreTables_SingleTable(t *testing.T) {
+	s, recorder := newTableRestoreService(t)
+
+	result, err := s.RestoreTables(context.Background(), "b-1", "shop", TableRestoreRequest{Tables: []string{"orders"}})
+	if err != nil {
+		t.Fatalf("RestoreTables: %v", err)
+	}
+	if strings.Join(result.Tables, ",") != "public.orders" || len(result.Referenced) != 0 {
+		t.Fatalf("expected only orders to be restored, got %+v", result)
+	}
+	if recorder.connectionString != "postgres://shop-primary/shop" {
+		t.Errorf("expected the restore to run against the target database, got %q", recorder.connectionString)
+	}
+
+	script := recorder.script
+	for _, want := range []string{
+		"SELECT pg_catalog.set_config('search_path', '', false);",
+		"DROP TABLE IF EXISTS public.orders;",
+		"CREATE TABLE public.orders (",
+		"CREATE SEQUENCE public.orders_id_seq",
+		"10\t1\t9.99\n11\t2\t19.99\n\\.",
+		"SELECT pg_catalog.setval('public.orders_id_seq', 11, true);",
+		"ADD CONSTRAINT orders_pkey PRIMARY KEY (id);",
+		"CREATE INDEX orders_customer_id_idx ON public.orders",
+		"REFERENCES public.customers(id);",
+	} {
+		if !strings.Contains(script, want) {
+			t.Errorf("expected the script to contain %q, got:\n%s", want, script)
+		}
+	}
+	for _, unwanted := range []string{"CREATE TABLE public.customers", "CREATE TABLE public.order_items", "Ada", "widget", "order_items_order_id_fkey", "CREATE FUNCTION"} {
+		if strings.Contains(script, unwanted) {
+			t.Errorf("expected the script not to contain %q, got:\n%s", unwanted, script)
+		}
+	}
+
+	// Data must be loaded before constraints are added
+	if strings.Index(script, "COPY public.orders") > strings.Index(script, "orders_customer_id_fkey") {
+		t.Errorf("expected data before foreign keys, got:\n%s", script)
+	}
+}
+
+func TestRestoreTables_IncludeReferenced(t *testing.T) {
+	s, recorder := newTableRestoreService(t)
+
+	result, err := s.RestoreTables(context.Background(), "b-1", "shop", TableRestoreRequest{Tables: []string{"public.order_items"}, IncludeReferenced: true})
+	if err != nil {
+		t.Fatalf("RestoreTables: %v", err)
+	}
+	if got := strings.Join(result.Tables, ","); got != "public.customers,public.order_items,public.orders" {
+		t.Fatalf("expected order_items with the tables it references, got %s", got)
+	}
+	if got := strings.Join(result.Referenced, ","); got != "public.customers,public.orders" {
+		t.Errorf("expected customers and orders to be reported as referenced, got %s", got)
+	}
+	if !strings.Contains(recorder.script, "DROP TABLE IF EXISTS public.customers, public.order_items, public.orders;") {
+		t.Errorf("expected the tables to be dropped together, got:\n%s", recorder.script)
+	}
+	for _, want := range []string{"Ada", "widget", "order_items_order_id_fkey", "orders_customer_id_fkey"} {
+		if !strings.Contains(recorder.script, want) {
+			t.Errorf("expected the script to contain %q", want)
+		}
+	}
+}
+
+func TestRestoreTables_StagingSchema(t *testing.T) {
+	s, recorder := newTableRestoreService(t)
+
+	_, err := s.RestoreTables(context.Background(), "b-1", "shop", TableRestoreRequest{Tables: []string{"orders"}, StagingSchema: "restore_b1"})
+	if err != nil {
+		t.Fatalf("RestoreTables: %v", err)
+	}
+
+	script := recorder.script
+	for _, want := range []string{
+		"CREATE SCHEMA IF NOT EXISTS restore_b1;",
+		"DROP TABLE IF EXISTS restore_b1.orders;",
+		"CREATE TABLE restore_b1.orders (",
+		"CREATE SEQUENCE restore_b1.orders_id_seq",
+		"nextval('restore_b1.orders_id_seq'::regclass)",
+		"COPY restore_b1.orders (id, customer_id, total) FROM stdin;",
+		"CREATE INDEX orders_customer_id_idx ON restore_b1.orders",
+		// customers is not restored, so the foreign key stays on the live table
+		"REFERENCES public.customers(id);",
+	} {
+		if !strings.Contains(script, want) {
+			t.Errorf("expected the script to contain %q, got:\n%s", want, script)
+		}
+	}
+	if strings.Contains(script, "public.orders") {
+		t.Errorf("expected the live orders table to be left alone, got:\n%s", script)
+	}
+}
+
+func TestRestoreTables_Rejects(t *testing.T) {
+	tests := []struct {
+		name     string
+		backupID string
+		target   string
+		req      TableRestoreRequest
+	}{
+		{"no tables", "b-1", "shop", TableRestoreRequest{}},
+		{"table not in backup", "b-1", "shop", TableRestoreRequest{Tables: []string{"invoices"}}},
+		{"invalid staging schema", "b-1", "shop", TableRestoreRequest{Tables: []string{"orders"}, StagingSchema: "Restore; DROP"}},
+		{"public staging schema", "b-1", "shop", TableRestoreRequest{Tables: []string{"orders"}, StagingSchema: "public"}},
+		{"unknown backup", "b-2", "shop", TableRestoreRequest{Tables: []string{"orders"}}},
+		{"unknown target", "b-1", "warehouse", TableRestoreRequest{Tables: []string{"orders"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, recorder := newTableRestoreService(t)
+			if _, err := s.RestoreTables(context.Background(), tt.backupID, tt.target, tt.req); err == nil {
+				t.Fatal("expected the restore to be rejected")
+			}
+			if recorder.script != "" {
+				t.Errorf("expected nothing to run, got:\n%s", recorder.script)
+			}
+		})
+	}
+}
+
+func TestParseDump_TruncatedCopy(t *testing.T) {
+	if _, err := parseDump([]byte("COPY public.orders (id) FROM stdin;\n10\n")); err == nil {
+		t.Fatal("expected unterminated COPY data to be rejected")
+	}
+}
+
+func TestRestoreTables_RejectsBackupsThatAreNotDumps(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"placeholder", "-- Backup for database shop\n-- Created at 2024-01-01T00:00:00Z\n-- Type: full\n"},
+		{"truncated", strings.SplitAfter(testDump, "\\.\n")[0]},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, recorder := newTableRestoreService(t)
+			if err := os.WriteFile(s.backups["b-1"].StoragePath, []byte(tt.data), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := s.RestoreTables(context.Background(), "b-1", "shop", TableRestoreRequest{Tables: []string{"orders"}}); err == nil {
+				t.Fatal("expected the backup to be refused")
+			}
+			if recorder.script != "" {
+				t.Errorf("expected nothing to run, got:\n%s", recorder.script)
+			}
+		})
+	}
+}
+
+func TestRestoreTables_CustomFormat(t *testing.T) {
+	s, recorder := newTableRestoreService(t)
+	if err := os.WriteFile(s.backups["b-1"].StoragePath, []byte("PGDMP\x01\x0e\x00archive"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var converted []byte
+	s.toScript = func(ctx context.Context, archive []byte) ([]byte, error) {
+		converted = archive
+		return []byte(testDump), nil
+	}
+
+	if _, err := s.RestoreTables(context.Background(), "b-1", "shop", TableRestoreRequest{Tables: []string{"orders"}}); err != nil {
+		t.Fatalf("RestoreTables: %v", err)
+	}
+	if !strings.HasPrefix(string(converted), "PGDMP") {
+		t.Errorf("expected the archive to be converted with pg_restore, got %q", converted)
+	}
+	if !strings.Contains(recorder.script, "CREATE TABLE public.orders (") {
+		t.Errorf("expected orders to be restored from the converted dump, got:\n%s", recorder.script)
+	}
+}
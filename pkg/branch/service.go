@@ -2,6 +2,7 @@ package branch
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
@@ -9,37 +10,90 @@ import (
 	"github.com/google/uuid"
 	"github.com/sharding-system/pkg/backup"
 	"github.com/sharding-system/pkg/database"
+	"github.com/sharding-system/pkg/notify"
 	"github.com/sharding-system/pkg/operator"
 	"go.uber.org/zap"
 )
 
+var (
+	// ErrBranchNotFound is returned when a branch does not exist
+	ErrBranchNotFound = errors.New("branch not found")
+	// ErrBranchHasChildren is returned when deleting a branch that other branches were created from
+	ErrBranchHasChildren = errors.New("branch has child branches")
+)
+
+// Branch methods record how a branch's data was copied from its parent
+const (
+	MethodSnapshot      = "snapshot"
+	MethodBackupRestore = "backup_restore"
+)
+
 // Branch represents a database branch (dev environment)
 type Branch struct {
 	ID          string                 `json:"id"`
 	Name        string                 `json:"name"`
 	ParentDBID  string                 `json:"parent_db_id"`
 	ParentDBName string                `json:"parent_db_name"`
+	ParentBranchID string              `json:"parent_branch_id,omitempty"` // Set when branching from another branch
+	RootDBName  string                 `json:"root_db_name"`               // Database at the top of the lineage
+	Depth       int                    `json:"depth"`                      // 1 for branches of a database, 2 for branches of those, ...
+	Method      string                 `json:"method,omitempty"`           // "snapshot" or "backup_restore"
+	BackupID    string                 `json:"backup_id,omitempty"`        // Backup restored into the branch
 	Status      string                 `json:"status"` // "creating", "ready", "failed", "deleting"
+	Error       string                 `json:"error,omitempty"`
 	ConnectionString string             `json:"connection_string"`
 	CreatedAt   time.Time              `json:"created_at"`
 	UpdatedAt   time.Time              `json:"updated_at"`
 	Metadata    map[string]interface{} `json:"metadata,omitempty"`
 }
 
-// BranchService manages database branches
+// DatabaseController creates and deletes the databases backing branches
+type DatabaseController interface {
+	GetDatabase(name string) (*database.Database, bool)
+	CreateDatabase(ctx context.Context, req database.CreateDatabaseRequest) (*database.Database, error)
+	DeleteDatabase(ctx context.Context, name string) error
+}
+
+// BackupProvider takes and restores the backups branches fall back to
+type BackupProvider interface {
+	CreateBackup(ctx context.Context, databaseID string, backupType string) (*backup.Backup, error)
+	GetBackup(backupID string) (*backup.Backup, error)
+	ListBackups(databaseID string) ([]*backup.Backup, error)
+	RestoreBackup(ctx context.Context, backupID string, targetDatabaseID string) error
+}
+
+// Snapshotter clones a database's storage copy-on-write, e.g. through CSI
+// volume snapshots, so a branch shares unchanged blocks with its parent
+type Snapshotter interface {
+	CloneFromSnapshot(ctx context.Context, sourceDB, targetDB string) error
+}
+
+// BranchService manages database branches.
+//
+// A branch is a single-shard database holding a copy of its parent's data.
+// The copy is taken from a storage snapshot when a Snapshotter is configured
+// and falls back to restoring the parent's latest completed backup, taking a
+// new backup first if none exists. The backup service cannot restore a whole
+// database yet, so without a Snapshotter branches fail with
+// backup.ErrFullRestoreUnsupported rather than being created empty. Branches
+// may be created from other branches; a branch cannot be deleted while it
+// still has children.
 type BranchService struct {
-	backupService *backup.BackupService
-	dbController  *database.Controller
+	backupService BackupProvider
+	dbController  DatabaseController
 	operator      *operator.Operator
+	snapshotter   Snapshotter
+	notifier      notify.Notifier
 	logger        *zap.Logger
 	branches      map[string]*Branch
+	backupPoll    time.Duration
 	mu            sync.RWMutex
 }
 
 // NewBranchService creates a new branch service
 func NewBranchService(
-	backupService *backup.BackupService,
-	dbController *database.Controller,
+	backupService BackupProvider,
+	dbController DatabaseController,
 	op *operator.Operator,
 	logger *zap.Logger,
 ) *BranchService {
@@ -49,10 +103,38 @@ func NewBranchService(
 		operator:      op,
 		logger:        logger,
 		branches:      make(map[string]*Branch),
+		backupPoll:    time.Second,
 	}
 }
 
-// CreateBranch creates a new branch from a production database
+// SetSnapshotter enables copy-on-write branching through storage snapshots
+func (s *BranchService) SetSnapshotter(snapshotter Snapshotter) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.snapshotter = snapshotter
+}
+
+// SetNotifier sets where branch creations and deletions are announced
+func (s *BranchService) SetNotifier(notifier notify.Notifier) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.notifier = notifier
+}
+
+// emit announces a branch event through the notifier, if one is set
+func (s *BranchService) emit(ctx context.Context, eventType string, branch *Branch, status string, detail string) {
+	s.mu.RLock()
+	notifier := s.notifier
+	s.mu.RUnlock()
+	notify.Send(ctx, notifier, notify.Event{
+		Type:     eventType,
+		Resource: branch.ID,
+		Status:   status,
+		Detail:   detail,
+	}, s.logger)
+}
+
+// CreateBranch creates a new branch from a database or from another branch
 func (s *BranchService) CreateBranch(ctx context.Context, parentDBName string, branchName string) (*Branch, error) {
 	// Get parent database
 	parentDB, ok := s.dbController.GetDatabase(parentDBName)
@@ -66,6 +148,8 @@ func (s *BranchService) CreateBranch(ctx context.Context, parentDBName string, b
 		Name:          branchName,
 		ParentDBID:    parentDB.ID,
 		ParentDBName:  parentDB.Name,
+		RootDBName:    parentDB.Name,
+		Depth:         1,
 		Status:        "creating",
 		CreatedAt:     time.Now(),
 		UpdatedAt:     time.Now(),
@@ -73,50 +157,46 @@ func (s *BranchService) CreateBranch(ctx context.Context, parentDBName string, b
 	}
 
 	s.mu.Lock()
+	for _, existing := range s.branches {
+		if existing.Name == branchName {
+			s.mu.Unlock()
+			return nil, fmt.Errorf("branch %s already exists", branchName)
+		}
+	}
+	// Branching from a branch extends its lineage
+	if parent := s.branchByNameLocked(parentDBName); parent != nil {
+		branch.ParentBranchID = parent.ID
+		branch.RootDBName = parent.RootDBName
+		branch.Depth = parent.Depth + 1
+	}
 	s.branches[branch.ID] = branch
 	s.mu.Unlock()
 
-	// Create branch asynchronously
-	go s.provisionBranch(ctx, branch, parentDB)
+	// Provisioning outlives the request that started it
+	go s.provisionBranch(context.WithoutCancel(ctx), branch, parentDB)
 
 	s.logger.Info("branch creation initiated",
 		zap.String("branch_id", branch.ID),
 		zap.String("branch_name", branchName),
-		zap.String("parent_db", parentDBName))
+		zap.String("parent_db", parentDBName),
+		zap.String("parent_branch_id", branch.ParentBranchID))
 
 	return branch, nil
 }
 
-// provisionBranch provisions a branch database from a backup
-func (s *BranchService) provisionBranch(ctx context.Context, branch *Branch, parentDB *database.Database) {
-	// Step 1: Get latest backup of parent database
-	backups, err := s.backupService.ListBackups(parentDB.Name)
-	if err != nil {
-		s.mu.Lock()
-		branch.Status = "failed"
-		branch.UpdatedAt = time.Now()
-		s.mu.Unlock()
-		s.logger.Error("failed to list backups for parent database",
-			zap.String("branch_id", branch.ID),
-			zap.String("parent_db", parentDB.Name),
-			zap.Error(err))
-		return
-	}
-	if len(backups) == 0 {
-		s.mu.Lock()
-		branch.Status = "failed"
-		branch.UpdatedAt = time.Now()
-		s.mu.Unlock()
-		s.logger.Error("no backups found for parent database",
-			zap.String("branch_id", branch.ID),
-			zap.String("parent_db", parentDB.Name))
-		return
+// branchByNameLocked returns the branch backed by the named database. The caller must hold s.mu.
+func (s *BranchService) branchByNameLocked(name string) *Branch {
+	for _, branch := range s.branches {
+		if branch.Name == name {
+			return branch
+		}
 	}
+	return nil
+}
 
-	// Use latest backup
-	latestBackup := backups[len(backups)-1]
-
-	// Step 2: Create single-instance database for branch (cost-optimized)
+// provisionBranch creates the branch database and copies the parent's data into it
+func (s *BranchService) provisionBranch(ctx context.Context, branch *Branch, parentDB *database.Database) {
+	// Step 1: Create single-instance database for branch (cost-optimized)
 	// Branches use single instance instead of full sharding
 	branchDBReq := database.CreateDatabaseRequest{
 		Name:         branch.Name,
@@ -129,26 +209,29 @@ func (s *BranchService) provisionBranch(ctx context.Context, branch *Branch, par
 		Strategy:     parentDB.Strategy,
 	}
 
-	// Step 3: Create branch database
 	branchDB, err := s.dbController.CreateDatabase(ctx, branchDBReq)
 	if err != nil {
-		s.mu.Lock()
-		branch.Status = "failed"
-		branch.UpdatedAt = time.Now()
-		s.mu.Unlock()
-		s.logger.Error("failed to create branch database",
-			zap.String("branch_id", branch.ID),
-			zap.Error(err))
+		s.failBranch(ctx, branch, fmt.Errorf("failed to create branch database: %w", err))
 		return
 	}
 
-	// Step 4: Restore from backup
-	// Note: This would require restore functionality in backup service
-	// For now, we'll mark it as ready after database creation
-	// In production, you'd restore the backup here
+	// Step 2: Copy the parent's data, preferring a copy-on-write snapshot
+	method, backupID, err := s.copyParentData(ctx, branch, parentDB.Name)
+	if err != nil {
+		// Don't leave an empty database behind
+		if delErr := s.dbController.DeleteDatabase(ctx, branch.Name); delErr != nil {
+			s.logger.Warn("failed to clean up branch database",
+				zap.String("branch_id", branch.ID),
+				zap.Error(delErr))
+		}
+		s.failBranch(ctx, branch, err)
+		return
+	}
 
 	s.mu.Lock()
 	branch.Status = "ready"
+	branch.Method = method
+	branch.BackupID = backupID
 	branch.ConnectionString = fmt.Sprintf("postgresql://%s:5432/%s", branchDB.Name, branchDB.Name)
 	branch.UpdatedAt = time.Now()
 	s.mu.Unlock()
@@ -156,7 +239,99 @@ func (s *BranchService) provisionBranch(ctx context.Context, branch *Branch, par
 	s.logger.Info("branch created successfully",
 		zap.String("branch_id", branch.ID),
 		zap.String("branch_name", branch.Name),
-		zap.String("backup_id", latestBackup.ID))
+		zap.String("method", method),
+		zap.String("backup_id", backupID))
+	s.emit(ctx, notify.EventBranchCreate, branch, notify.StatusSucceeded,
+		fmt.Sprintf("branch %s of %s is ready (%s)", branch.Name, branch.ParentDBName, method))
+}
+
+// copyParentData copies the parent's data into the branch database and
+// reports the method used and, for backup restores, the backup ID
+func (s *BranchService) copyParentData(ctx context.Context, branch *Branch, parentDBName string) (string, string, error) {
+	s.mu.RLock()
+	snapshotter := s.snapshotter
+	s.mu.RUnlock()
+
+	if snapshotter != nil {
+		err := snapshotter.CloneFromSnapshot(ctx, parentDBName, branch.Name)
+		if err == nil {
+			return MethodSnapshot, "", nil
+		}
+		s.logger.Warn("snapshot clone failed, falling back to backup and restore",
+			zap.String("branch_id", branch.ID),
+			zap.String("parent_db", parentDBName),
+			zap.Error(err))
+	}
+
+	parentBackup, err := s.latestBackup(ctx, parentDBName)
+	if err != nil {
+		return "", "", err
+	}
+	if err := s.backupService.RestoreBackup(ctx, parentBackup.ID, branch.Name); err != nil {
+		return "", "", fmt.Errorf("failed to restore backup %s: %w", parentBackup.ID, err)
+	}
+	return MethodBackupRestore, parentBackup.ID, nil
+}
+
+// latestBackup returns the parent's most recent completed backup, taking a
+// new one and waiting for it when none exists
+func (s *BranchService) latestBackup(ctx context.Context, parentDBName string) (*backup.Backup, error) {
+	backups, err := s.backupService.ListBackups(parentDBName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list backups for %s: %w", parentDBName, err)
+	}
+
+	var latest *backup.Backup
+	for _, b := range backups {
+		if b.Status == "completed" && (latest == nil || b.CreatedAt.After(latest.CreatedAt)) {
+			latest = b
+		}
+	}
+	if latest != nil {
+		return latest, nil
+	}
+
+	created, err := s.backupService.CreateBackup(ctx, parentDBName, "full")
+	if err != nil {
+		return nil, fmt.Errorf("failed to back up %s: %w", parentDBName, err)
+	}
+
+	ticker := time.NewTicker(s.backupPoll)
+	defer ticker.Stop()
+	for {
+		b, err := s.backupService.GetBackup(created.ID)
+		if err != nil {
+			return nil, err
+		}
+		switch b.Status {
+		case "completed":
+			return b, nil
+		case "failed":
+			return nil, fmt.Errorf("backup %s of %s failed: %s", b.ID, parentDBName, b.Error)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// failBranch marks a branch as failed
+func (s *BranchService) failBranch(ctx context.Context, branch *Branch, err error) {
+	s.mu.Lock()
+	branch.Status = "failed"
+	branch.Error = err.Error()
+	branch.UpdatedAt = time.Now()
+	s.mu.Unlock()
+
+	s.logger.Error("branch creation failed",
+		zap.String("branch_id", branch.ID),
+		zap.String("branch_name", branch.Name),
+		zap.Error(err))
+	s.emit(ctx, notify.EventBranchCreate, branch, notify.StatusFailed,
+		fmt.Sprintf("branch %s of %s failed: %v", branch.Name, branch.ParentDBName, err))
 }
 
 // ListBranches lists all branches for a parent database
@@ -180,40 +355,89 @@ func (s *BranchService) GetBranch(branchID string) (*Branch, error) {
 
 	branch, ok := s.branches[branchID]
 	if !ok {
-		return nil, fmt.Errorf("branch %s not found", branchID)
+		return nil, fmt.Errorf("%w: %s", ErrBranchNotFound, branchID)
 	}
 	return branch, nil
 }
 
-// DeleteBranch deletes a branch
+// Lineage returns the chain of branches from the branch's root database down
+// to the branch itself, oldest first
+func (s *BranchService) Lineage(branchID string) ([]*Branch, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	branch, ok := s.branches[branchID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrBranchNotFound, branchID)
+	}
+
+	lineage := []*Branch{branch}
+	for branch.ParentBranchID != "" {
+		parent, ok := s.branches[branch.ParentBranchID]
+		if !ok {
+			break
+		}
+		lineage = append([]*Branch{parent}, lineage...)
+		branch = parent
+	}
+	return lineage, nil
+}
+
+// Children returns the branches created directly from a branch
+func (s *BranchService) Children(branchID string) []*Branch {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	children := make([]*Branch, 0)
+	for _, branch := range s.branches {
+		if branch.ParentBranchID == branchID {
+			children = append(children, branch)
+		}
+	}
+	return children
+}
+
+// DeleteBranch deletes a branch and its database. Branches created from it
+// must be deleted first.
 func (s *BranchService) DeleteBranch(ctx context.Context, branchID string) error {
 	s.mu.Lock()
 	branch, ok := s.branches[branchID]
 	if !ok {
 		s.mu.Unlock()
-		return fmt.Errorf("branch %s not found", branchID)
+		return fmt.Errorf("%w: %s", ErrBranchNotFound, branchID)
+	}
+	for _, other := range s.branches {
+		if other.ParentBranchID == branchID {
+			s.mu.Unlock()
+			return fmt.Errorf("%w: %s is the parent of %s", ErrBranchHasChildren, branch.Name, other.Name)
+		}
 	}
 	branch.Status = "deleting"
 	branch.UpdatedAt = time.Now()
 	s.mu.Unlock()
 
-	// Delete branch database
-	go func() {
+	// A failed branch may never have had a database
+	if _, exists := s.dbController.GetDatabase(branch.Name); exists {
 		if err := s.dbController.DeleteDatabase(ctx, branch.Name); err != nil {
-			s.logger.Error("failed to delete branch database",
-				zap.String("branch_id", branchID),
-				zap.Error(err))
-			return
+			s.mu.Lock()
+			branch.Status = "failed"
+			branch.Error = err.Error()
+			branch.UpdatedAt = time.Now()
+			s.mu.Unlock()
+			s.emit(ctx, notify.EventBranchDelete, branch, notify.StatusFailed,
+				fmt.Sprintf("failed to delete branch %s: %v", branch.Name, err))
+			return fmt.Errorf("failed to delete branch database: %w", err)
 		}
+	}
 
-		s.mu.Lock()
-		delete(s.branches, branchID)
-		s.mu.Unlock()
-
-		s.logger.Info("branch deleted successfully",
-			zap.String("branch_id", branchID))
-	}()
+	s.mu.Lock()
+	delete(s.branches, branchID)
+	s.mu.Unlock()
 
+	s.logger.Info("branch deleted successfully",
+		zap.String("branch_id", branchID),
+		zap.String("branch_name", branch.Name))
+	s.emit(ctx, notify.EventBranchDelete, branch, notify.StatusSucceeded, fmt.Sprintf("branch %s deleted", branch.Name))
 	return nil
 }
 
@@ -0,0 +1,293 @@
+package branch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sharding-system/pkg/backup"
+	"github.com/sharding-system/pkg/database"
+	"github.com/sharding-system/pkg/notify"
+	"go.uber.org/zap/zaptest"
+)
+
+// fakeController keeps databases in memory
+type fakeController struct {
+	mu        sync.Mutex
+	databases map[string]*database.Database
+	deleted   []string
+}
+
+func newFakeController(names ...string) *fakeController {
+	c := &fakeController{databases: make(map[string]*database.Database)}
+	for _, name := range names {
+		c.databases[name] = &database.Database{ID: name + "-id", Name: name, ShardKey: "id", Strategy: "hash"}
+	}
+	return c
+}
+
+func (c *fakeController) GetDatabase(name string) (*database.Database, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	db, ok := c.databases[name]
+	return db, ok
+}
+
+func (c *fakeController) CreateDatabase(ctx context.Context, req database.CreateDatabaseRequest) (*database.Database, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, exists := c.databases[req.Name]; exists {
+		return nil, fmt.Errorf("database %s already exists", req.Name)
+	}
+	db := &database.Database{ID: req.Name + "-id", Name: req.Name, ShardCount: req.ShardCount}
+	c.databases[req.Name] = db
+	return db, nil
+}
+
+func (c *fakeController) DeleteDatabase(ctx context.Context, name string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.databases, name)
+	c.deleted = append(c.deleted, name)
+	return nil
+}
+
+func (c *fakeController) has(name string) bool {
+	_, ok := c.GetDatabase(name)
+	return ok
+}
+
+// fakeBackups completes backups immediately and records restores
+type fakeBackups struct {
+	mu       sync.Mutex
+	backups  map[string]*backup.Backup
+	restored map[string]string // target database -> backup ID
+}
+
+func newFakeBackups() *fakeBackups {
+	return &fakeBackups{backups: make(map[string]*backup.Backup), restored: make(map[string]string)}
+}
+
+func (b *fakeBackups) CreateBackup(ctx context.Context, databaseID string, backupType string) (*backup.Backup, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	created := &backup.Backup{ID: fmt.Sprintf("backup-%d", len(b.backups)+1), DatabaseID: databaseID, Status: "completed", CreatedAt: time.Now()}
+	b.backups[created.ID] = created
+	return created, nil
+}
+
+func (b *fakeBackups) GetBackup(backupID string) (*backup.Backup, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	found, ok := b.backups[backupID]
+	if !ok {
+		return nil, fmt.Errorf("backup not found: %s", backupID)
+	}
+	return found, nil
+}
+
+func (b *fakeBackups) ListBackups(databaseID string) ([]*backup.Backup, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	backups := make([]*backup.Backup, 0)
+	for _, found := range b.backups {
+		if found.DatabaseID == databaseID {
+			backups = append(backups, found)
+		}
+	}
+	return backups, nil
+}
+
+func (b *fakeBackups) RestoreBackup(ctx context.Context, backupID string, targetDatabaseID string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.restored[targetDatabaseID] = backupID
+	return nil
+}
+
+// fakeSnapshotter clones by recording the pair, or fails with err
+type fakeSnapshotter struct {
+	err    error
+	clones []string
+}
+
+func (s *fakeSnapshotter) CloneFromSnapshot(ctx context.Context, sourceDB, targetDB string) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.clones = append(s.clones, sourceDB+"->"+targetDB)
+	return nil
+}
+
+// waitForStatus polls until a branch leaves the creating state
+func waitForStatus(t *testing.T, s *BranchService, branchID string) Branch {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		s.mu.RLock()
+		branch := *s.branches[branchID]
+		s.mu.RUnlock()
+		if branch.Status != "creating" {
+			return branch
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("branch %s was not provisioned", branchID)
+	return Branch{}
+}
+
+func newTestService(t *testing.T, controller *fakeController, backups BackupProvider) *BranchService {
+	s := NewBranchService(backups, controller, nil, zaptest.NewLogger(t))
+	s.backupPoll = time.Millisecond
+	return s
+}
+
+func TestBranchService_CreateBranchTracksLineage(t *testing.T) {
+	controller, backups := newFakeController("orders"), newFakeBackups()
+	s := newTestService(t, controller, backups)
+	snapshotter := &fakeSnapshotter{}
+	s.SetSnapshotter(snapshotter)
+	ctx := context.Background()
+
+	feature, err := s.CreateBranch(ctx, "orders", "feature-x")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ready := waitForStatus(t, s, feature.ID)
+	if ready.Status != "ready" || ready.Method != MethodSnapshot || ready.Depth != 1 || ready.ParentBranchID != "" {
+		t.Fatalf("expected a ready snapshot branch of orders, got %+v", ready)
+	}
+
+	// Branch the branch, falling back to backup and restore
+	snapshotter.err = errors.New("volume snapshots not supported")
+	child, err := s.CreateBranch(ctx, "feature-x", "feature-x-experiment")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	childReady := waitForStatus(t, s, child.ID)
+	if childReady.Status != "ready" || childReady.Method != MethodBackupRestore {
+		t.Fatalf("expected a ready backup-restore branch, got %+v", childReady)
+	}
+	if childReady.ParentBranchID != feature.ID || childReady.RootDBName != "orders" || childReady.Depth != 2 {
+		t.Errorf("expected the child to descend from feature-x and orders, got %+v", childReady)
+	}
+	if backups.restored["feature-x-experiment"] != childReady.BackupID || childReady.BackupID == "" {
+		t.Errorf("expected a fresh backup of feature-x to be restored, got %v", backups.restored)
+	}
+
+	lineage, err := s.Lineage(child.ID)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(lineage) != 2 || lineage[0].ID != feature.ID || lineage[1].ID != child.ID {
+		t.Errorf("expected lineage feature-x -> feature-x-experiment, got %+v", lineage)
+	}
+	if children := s.Children(feature.ID); len(children) != 1 || children[0].ID != child.ID {
+		t.Errorf("expected one child of feature-x, got %+v", children)
+	}
+}
+
+func TestBranchService_DeleteBranchCleansUp(t *testing.T) {
+	controller, backups := newFakeController("orders"), newFakeBackups()
+	s := newTestService(t, controller, backups)
+	ctx := context.Background()
+
+	parent, _ := s.CreateBranch(ctx, "orders", "feature-x")
+	waitForStatus(t, s, parent.ID)
+	child, _ := s.CreateBranch(ctx, "feature-x", "feature-x-experiment")
+	waitForStatus(t, s, child.ID)
+
+	if err := s.DeleteBranch(ctx, parent.ID); !errors.Is(err, ErrBranchHasChildren) {
+		t.Fatalf("expected deleting a parent branch to fail, got %v", err)
+	}
+	if !controller.has("feature-x") {
+		t.Fatal("expected the parent branch database to be kept")
+	}
+
+	for _, id := range []string{child.ID, parent.ID} {
+		if err := s.DeleteBranch(ctx, id); err != nil {
+			t.Fatalf("unexpected error deleting %s: %v", id, err)
+		}
+	}
+	if controller.has("feature-x") || controller.has("feature-x-experiment") || !controller.has("orders") {
+		t.Errorf("expected only the branch databases to be deleted, got %v", controller.deleted)
+	}
+	if _, err := s.GetBranch(parent.ID); !errors.Is(err, ErrBranchNotFound) {
+		t.Errorf("expected the branch record to be removed, got %v", err)
+	}
+	if err := s.DeleteBranch(ctx, parent.ID); !errors.Is(err, ErrBranchNotFound) {
+		t.Errorf("expected ErrBranchNotFound, got %v", err)
+	}
+}
+
+func TestBranchService_FailedCopyRemovesBranchDatabase(t *testing.T) {
+	controller := newFakeController("orders")
+	s := newTestService(t, controller, &failingBackups{newFakeBackups()})
+
+	created, err := s.CreateBranch(context.Background(), "orders", "feature-x")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	failed := waitForStatus(t, s, created.ID)
+	if failed.Status != "failed" || failed.Error == "" {
+		t.Fatalf("expected the branch to fail, got %+v", failed)
+	}
+	if controller.has("feature-x") {
+		t.Error("expected the empty branch database to be removed")
+	}
+}
+
+// failingBackups fails every backup it takes
+type failingBackups struct {
+	*fakeBackups
+}
+
+func (b *failingBackups) CreateBackup(ctx context.Context, databaseID string, backupType string) (*backup.Backup, error) {
+	created, _ := b.fakeBackups.CreateBackup(ctx, databaseID, backupType)
+	b.mu.Lock()
+	created.Status, created.Error = "failed", "pg_dump failed"
+	b.mu.Unlock()
+	return created, nil
+}
+
+// recordingNotifier passes events on to a channel
+type recordingNotifier chan notify.Event
+
+func (n recordingNotifier) Notify(ctx context.Context, event notify.Event) error {
+	n <- event
+	return nil
+}
+
+func TestBranchService_NotifiesBranchOutcomes(t *testing.T) {
+	controller := newFakeController("orders")
+	s := newTestService(t, controller, newFakeBackups())
+	notifier := make(recordingNotifier, 10)
+	s.SetNotifier(notifier)
+	ctx := context.Background()
+
+	created, _ := s.CreateBranch(ctx, "orders", "feature-x")
+	waitForStatus(t, s, created.ID)
+	if event := <-notifier; event.Type != notify.EventBranchCreate || event.Resource != created.ID || event.Status != notify.StatusSucceeded {
+		t.Errorf("expected a branch creation notification, got %+v", event)
+	}
+
+	if err := s.DeleteBranch(ctx, created.ID); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if event := <-notifier; event.Type != notify.EventBranchDelete || event.Resource != created.ID || event.Status != notify.StatusSucceeded {
+		t.Errorf("expected a branch deletion notification, got %+v", event)
+	}
+
+	// A failed branch is announced as such
+	s.backupService = &failingBackups{newFakeBackups()}
+	failed, _ := s.CreateBranch(ctx, "orders", "feature-y")
+	waitForStatus(t, s, failed.ID)
+	event := <-notifier
+	if event.Type != notify.EventBranchCreate || event.Status != notify.StatusFailed || !strings.Contains(event.Detail, "pg_dump failed") {
+		t.Errorf("expected a branch failure notification, got %+v", event)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/sharding-system/pkg/hashing"
+	"github.com/sharding-system/pkg/keyrange"
 	"github.com/sharding-system/pkg/models"
 	clientv3 "go.etcd.io/etcd/client/v3"
 	"go.uber.org/zap"
@@ -23,6 +24,7 @@ type Catalog interface {
 	DeleteShard(shardID string) error
 	GetCatalogVersion() (int64, error)
 	Watch(ctx context.Context) (<-chan *models.ShardCatalog, error)
+	Reload() error // Force a reload of the local cache from the backing store
 }
 
 // EtcdCatalog implements Catalog using etcd
@@ -34,11 +36,16 @@ type EtcdCatalog struct {
 	cache     map[string]*models.Shard
 	version   int64
 	watchChan chan *models.ShardCatalog
+	events    EventLog
+	timeline  ShardTimeline
+	snapshots SnapshotStore
 }
 
 // ConsistentHashRing wraps the hashing logic with catalog integration
 type ConsistentHashRing struct {
 	hashFunc *hashing.ConsistentHash
+	apps     map[string]*hashing.ConsistentHash // Each client app's shards, hashed with the app's hash function
+	ranges   map[string][]string                // Each client app's range-strategy shard IDs
 	shards   map[string]*models.Shard
 	mu       sync.RWMutex
 }
@@ -59,6 +66,9 @@ func NewEtcdCatalog(endpoints []string, logger *zap.Logger) (*EtcdCatalog, error
 		hashRing:  &ConsistentHashRing{shards: make(map[string]*models.Shard)},
 		cache:     make(map[string]*models.Shard),
 		watchChan: make(chan *models.ShardCatalog, 10),
+		events:    NewEtcdEventLog(client),
+		timeline:  NewEtcdShardTimeline(client),
+		snapshots: NewEtcdSnapshotStore(client),
 	}
 
 	// Load initial catalog
@@ -74,16 +84,78 @@ func (c *EtcdCatalog) GetEtcdClient() *clientv3.Client {
 	return c.client
 }
 
+// EventLog returns the log of catalog mutations
+func (c *EtcdCatalog) EventLog() EventLog {
+	return c.events
+}
+
+// Timeline returns the history of each shard
+func (c *EtcdCatalog) Timeline() ShardTimeline {
+	return c.timeline
+}
+
+// Snapshots returns the named snapshots captured of the catalog
+func (c *EtcdCatalog) Snapshots() SnapshotStore {
+	return c.snapshots
+}
+
+// commitMutation applies a shard mutation and records its event together, so
+// the log neither misses a mutation nor records one that did not happen. The
+// mutation's ops run only while conds hold; applied is false when they did
+// not. An etcd event log is written in the mutation's transaction, retried
+// while other writers race for the sequence number. Any other log is appended
+// to after the mutation commits, and a failed append fails the mutation. It is
+// called with c.mu held so events are logged in the same order the mutations
+// were applied.
+func (c *EtcdCatalog) commitMutation(ctx context.Context, event *Event, conds []clientv3.Cmp, ops ...clientv3.Op) (applied bool, err error) {
+	log, ok := c.events.(txnEventLog)
+	if !ok {
+		resp, err := c.client.Txn(ctx).If(conds...).Then(ops...).Commit()
+		if err != nil {
+			return false, err
+		}
+		if !resp.Succeeded {
+			return false, nil
+		}
+		if err := c.events.Append(ctx, event); err != nil {
+			return false, fmt.Errorf("failed to record catalog event: %w", err)
+		}
+		return true, nil
+	}
+
+	for attempt := 0; attempt < maxAppendAttempts; attempt++ {
+		seqCmp, eventOps, err := log.appendOps(ctx, event)
+		if err != nil {
+			return false, fmt.Errorf("failed to record catalog event: %w", err)
+		}
+
+		// The outer condition guards the mutation, the inner one the event's
+		// sequence number
+		writes := append(append([]clientv3.Op{}, ops...), eventOps...)
+		resp, err := c.client.Txn(ctx).
+			If(conds...).
+			Then(clientv3.OpTxn([]clientv3.Cmp{seqCmp}, writes, nil)).
+			Commit()
+		if err != nil {
+			return false, err
+		}
+		if !resp.Succeeded {
+			return false, nil
+		}
+		if resp.Responses[0].GetResponseTxn().Succeeded {
+			return true, nil
+		}
+	}
+	return false, fmt.Errorf("failed to record catalog event: sequence contention after %d attempts", maxAppendAttempts)
+}
+
 // GetShard returns the shard for a given key, scoped to a client application
 func (c *EtcdCatalog) GetShard(key string, clientAppID string) (*models.Shard, error) {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
-	c.hashRing.mu.RLock()
-	defer c.hashRing.mu.RUnlock()
-
 	// Get shard ID from hash ring
-	shardID := c.hashRing.hashFunc.GetShard(key)
+	shardID := c.hashRing.getShard(key, clientAppID)
 	if shardID == "" {
 		return nil, fmt.Errorf("no shard found for key: %s", key)
 	}
@@ -137,25 +209,23 @@ func (c *EtcdCatalog) CreateShard(shard *models.Shard) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
+	shard.SchemaVersion = CurrentSchemaVersion
 	shardData, err := json.Marshal(shard)
 	if err != nil {
 		return fmt.Errorf("failed to marshal shard: %w", err)
 	}
 
-	key := fmt.Sprintf("/shards/%s", shard.ID)
-
-	// Use transaction to ensure atomicity
-	txn := c.client.Txn(ctx)
-	txn.If(clientv3.Compare(clientv3.Version(key), "=", 0)).
-		Then(clientv3.OpPut(key, string(shardData))).
-		Else(clientv3.OpGet(key))
+	key := shardKey(shard)
 
-	resp, err := txn.Commit()
+	// Create only if the key is free, logging the creation in the same transaction
+	created, err := c.commitMutation(ctx, newEvent(EventShardCreated, shard),
+		[]clientv3.Cmp{clientv3.Compare(clientv3.Version(key), "=", 0)},
+		clientv3.OpPut(key, string(shardData)))
 	if err != nil {
 		return fmt.Errorf("failed to create shard in etcd: %w", err)
 	}
 
-	if !resp.Succeeded {
+	if !created {
 		return fmt.Errorf("shard %s already exists", shard.ID)
 	}
 
@@ -178,19 +248,21 @@ func (c *EtcdCatalog) UpdateShard(shard *models.Shard) error {
 
 	shard.UpdatedAt = time.Now()
 	shard.Version++
+	shard.SchemaVersion = CurrentSchemaVersion
 	shardData, err := json.Marshal(shard)
 	if err != nil {
 		return fmt.Errorf("failed to marshal shard: %w", err)
 	}
 
-	key := fmt.Sprintf("/shards/%s/%s", shard.ClientAppID, shard.ID)
-	_, err = c.client.Put(ctx, key, string(shardData))
+	key := shardKey(shard)
+	_, err = c.commitMutation(ctx, newEvent(EventShardUpdated, shard), nil, clientv3.OpPut(key, string(shardData)))
 	if err != nil {
 		return fmt.Errorf("failed to update shard in etcd: %w", err)
 	}
 
 	// Update local cache
 	c.cache[shard.ID] = shard
+	c.hashRing.updateShard(shard)
 	c.version++
 
 	c.logger.Info("updated shard", zap.String("shard_id", shard.ID))
@@ -211,8 +283,8 @@ func (c *EtcdCatalog) DeleteShard(shardID string) error {
 	if !exists {
 		return fmt.Errorf("shard %s not found", shardID)
 	}
-	key := fmt.Sprintf("/shards/%s/%s", shard.ClientAppID, shardID)
-	_, err := c.client.Delete(ctx, key)
+	key := shardKey(shard)
+	_, err := c.commitMutation(ctx, newEvent(EventShardDeleted, shard), nil, clientv3.OpDelete(key))
 	if err != nil {
 		return fmt.Errorf("failed to delete shard from etcd: %w", err)
 	}
@@ -233,6 +305,18 @@ func (c *EtcdCatalog) GetCatalogVersion() (int64, error) {
 	return c.version, nil
 }
 
+// Reload discards the local cache and reloads all shards from etcd
+func (c *EtcdCatalog) Reload() error {
+	if err := c.loadCatalog(); err != nil {
+		return err
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	c.logger.Info("reloaded catalog", zap.Int64("version", c.version), zap.Int("shard_count", len(c.cache)))
+	return nil
+}
+
 // Watch watches for catalog changes
 func (c *EtcdCatalog) Watch(ctx context.Context) (<-chan *models.ShardCatalog, error) {
 	watchChan := make(chan *models.ShardCatalog, 10)
@@ -283,25 +367,105 @@ func (c *EtcdCatalog) loadCatalog() error {
 	defer c.mu.Unlock()
 
 	c.cache = make(map[string]*models.Shard)
-	c.hashRing.mu.Lock()
-	c.hashRing.shards = make(map[string]*models.Shard)
-	c.hashRing.mu.Unlock()
+	c.hashRing.reset()
 
 	for _, kv := range resp.Kvs {
-		var shard models.Shard
-		if err := json.Unmarshal(kv.Value, &shard); err != nil {
-			c.logger.Warn("failed to unmarshal shard", zap.Error(err))
+		shard, _, err := DecodeShard(kv.Value)
+		if err != nil {
+			c.logger.Warn("failed to decode shard", zap.String("key", string(kv.Key)), zap.Error(err))
+			continue
+		}
+
+		// A shard stored under more than one key keeps its newest record
+		if existing, ok := c.cache[shard.ID]; ok && existing.Version >= shard.Version {
 			continue
 		}
 
-		c.cache[shard.ID] = &shard
-		c.hashRing.addShard(&shard)
+		c.cache[shard.ID] = shard
+		c.hashRing.addShard(shard)
 	}
 
 	c.version = resp.Header.Revision
 	return nil
 }
 
+// MigrateSchema rewrites catalog records written by older versions to the
+// current schema version and canonical key layout. It is run once on manager
+// startup and returns the number of records that were rewritten or removed.
+func (c *EtcdCatalog) MigrateSchema(ctx context.Context) (int, error) {
+	resp, err := c.client.Get(ctx, "/shards/", clientv3.WithPrefix())
+	if err != nil {
+		return 0, fmt.Errorf("failed to get shards from etcd: %w", err)
+	}
+
+	records := make([]storedRecord, 0, len(resp.Kvs))
+	revisions := make(map[string]int64, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		records = append(records, storedRecord{Key: string(kv.Key), Value: kv.Value})
+		revisions[string(kv.Key)] = kv.ModRevision
+	}
+
+	plan := planMigration(records)
+	for _, key := range plan.Skipped {
+		c.logger.Warn("skipping undecodable shard record during migration", zap.String("key", key))
+	}
+
+	changed := 0
+	unwritten := make(map[string]bool)
+	for key, shard := range plan.Puts {
+		data, err := json.Marshal(shard)
+		if err != nil {
+			return changed, fmt.Errorf("failed to marshal shard %s: %w", shard.ID, err)
+		}
+
+		// Only overwrite if nobody touched the key since we read it
+		txn := c.client.Txn(ctx).
+			If(clientv3.Compare(clientv3.ModRevision(key), "=", revisions[key])).
+			Then(clientv3.OpPut(key, string(data)))
+		txnResp, err := txn.Commit()
+		if err != nil {
+			return changed, fmt.Errorf("failed to write migrated shard %s: %w", shard.ID, err)
+		}
+		if !txnResp.Succeeded {
+			c.logger.Warn("shard record changed during migration, skipping", zap.String("key", key))
+			unwritten[key] = true
+			continue
+		}
+		changed++
+	}
+
+	for key, canonical := range plan.Deletes {
+		// Keep the stale record while it is the only up-to-date copy
+		if unwritten[canonical] {
+			c.logger.Warn("canonical shard record was not written, keeping stale record",
+				zap.String("key", key), zap.String("canonical_key", canonical))
+			continue
+		}
+
+		txn := c.client.Txn(ctx).
+			If(clientv3.Compare(clientv3.ModRevision(key), "=", revisions[key])).
+			Then(clientv3.OpDelete(key))
+		txnResp, err := txn.Commit()
+		if err != nil {
+			return changed, fmt.Errorf("failed to delete stale shard record %s: %w", key, err)
+		}
+		if !txnResp.Succeeded {
+			c.logger.Warn("stale shard record changed during migration, skipping", zap.String("key", key))
+			continue
+		}
+		changed++
+	}
+
+	if err := c.loadCatalog(); err != nil {
+		return changed, err
+	}
+
+	c.logger.Info("catalog schema migration complete",
+		zap.Int("schema_version", CurrentSchemaVersion),
+		zap.Int("records_changed", changed))
+	return changed, nil
+}
+
 // addShard adds a shard to the hash ring
 func (r *ConsistentHashRing) addShard(shard *models.Shard) {
 	r.mu.Lock()
@@ -315,9 +479,102 @@ func (r *ConsistentHashRing) addShard(shard *models.Shard) {
 	if vnodeCount == 0 {
 		vnodeCount = 256 // default
 	}
-
 	r.hashFunc.AddShard(shard.ID, vnodeCount)
 	r.shards[shard.ID] = shard
+
+	// Range shards own a key range instead of a share of the app's ring
+	if shard.Strategy == "range" {
+		if r.ranges == nil {
+			r.ranges = make(map[string][]string)
+		}
+		r.ranges[shard.ClientAppID] = append(r.ranges[shard.ClientAppID], shard.ID)
+		return
+	}
+
+	if routesAppKeys(shard) {
+		r.addToAppRing(shard)
+	}
+}
+
+// routesAppKeys reports whether a hash shard takes its share of the app's
+// keys. Shards still being filled, such as rekey targets, join the app's ring
+// at cutover; retired shards leave it.
+func routesAppKeys(shard *models.Shard) bool {
+	switch shard.Status {
+	case models.ShardStatusActive, models.ShardStatusReadOnly, models.ShardStatusDraining:
+		return true
+	}
+	return false
+}
+
+// addToAppRing places a hash shard on its app's ring
+func (r *ConsistentHashRing) addToAppRing(shard *models.Shard) {
+	if r.apps == nil {
+		r.apps = make(map[string]*hashing.ConsistentHash)
+	}
+	appRing, ok := r.apps[shard.ClientAppID]
+	if !ok {
+		// The manager keeps every shard of an app on the same hash function
+		appRing = hashing.NewConsistentHash(hashing.NewHashFunction(shard.HashFunction))
+		r.apps[shard.ClientAppID] = appRing
+	}
+	vnodeCount := len(shard.VNodes)
+	if vnodeCount == 0 {
+		vnodeCount = 256 // default
+	}
+	appRing.AddShard(shard.ID, vnodeCount)
+}
+
+// removeFromAppRing takes a shard off its app's ring, dropping the ring once
+// it is empty
+func (r *ConsistentHashRing) removeFromAppRing(shard *models.Shard) {
+	appRing, ok := r.apps[shard.ClientAppID]
+	if !ok {
+		return
+	}
+	appRing.RemoveShard(shard.ID)
+	if len(appRing.GetShards()) == 0 {
+		delete(r.apps, shard.ClientAppID)
+	}
+}
+
+// onAppRing reports whether a shard is on its app's ring
+func (r *ConsistentHashRing) onAppRing(shard *models.Shard) bool {
+	appRing, ok := r.apps[shard.ClientAppID]
+	if !ok {
+		return false
+	}
+	for _, id := range appRing.GetShards() {
+		if id == shard.ID {
+			return true
+		}
+	}
+	return false
+}
+
+// updateShard replaces the ring's copy of a shard, so range lookups see its
+// current status, and puts a hash shard on or takes it off its app's ring
+// when its status changes whether it routes keys
+func (r *ConsistentHashRing) updateShard(shard *models.Shard) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.shards[shard.ID]; !ok {
+		return
+	}
+	r.shards[shard.ID] = shard
+	if shard.Strategy == "range" {
+		return
+	}
+
+	// The cached shard may be the one just updated, so the ring itself says
+	// where the shard was
+	switch routed := r.onAppRing(shard); {
+	case routesAppKeys(shard) && !routed:
+		r.addToAppRing(shard)
+	case !routesAppKeys(shard) && routed:
+		r.removeFromAppRing(shard)
+	}
 }
 
 // removeShard removes a shard from the hash ring
@@ -328,5 +585,72 @@ func (r *ConsistentHashRing) removeShard(shardID string) {
 	if r.hashFunc != nil {
 		r.hashFunc.RemoveShard(shardID)
 	}
+	if shard, ok := r.shards[shardID]; ok {
+		r.removeFromAppRing(shard)
+		ids := r.ranges[shard.ClientAppID]
+		for i, id := range ids {
+			if id == shardID {
+				r.ranges[shard.ClientAppID] = append(ids[:i:i], ids[i+1:]...)
+				break
+			}
+		}
+		if len(r.ranges[shard.ClientAppID]) == 0 {
+			delete(r.ranges, shard.ClientAppID)
+		}
+	}
 	delete(r.shards, shardID)
 }
+
+// getShard returns the shard ID a key is placed on. Keys scoped to a client
+// app are placed among that app's shards only: on the range shard whose key
+// range holds the key, otherwise on the app's ring.
+func (r *ConsistentHashRing) getShard(key, clientAppID string) string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if clientAppID != "" {
+		if shardID := r.rangeShard(key, clientAppID); shardID != "" {
+			return shardID
+		}
+		appRing, ok := r.apps[clientAppID]
+		if !ok {
+			return ""
+		}
+		return appRing.GetShard(key)
+	}
+	if r.hashFunc == nil {
+		return ""
+	}
+	return r.hashFunc.GetShard(key)
+}
+
+// rangeShard returns the range shard of an app whose key range holds key.
+// While a split or merge is in progress the source and target ranges overlap,
+// so active shards are preferred over migrating or read-only ones.
+func (r *ConsistentHashRing) rangeShard(key, clientAppID string) string {
+	candidate := ""
+	for _, id := range r.ranges[clientAppID] {
+		shard := r.shards[id]
+		if shard.Status == "inactive" || !keyrange.Contains(shard.Collation, shard.KeyRangeStart, shard.KeyRangeEnd, key) {
+			continue
+		}
+		if shard.Status == "active" {
+			return id
+		}
+		if candidate == "" {
+			candidate = id
+		}
+	}
+	return candidate
+}
+
+// reset empties the ring
+func (r *ConsistentHashRing) reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.hashFunc = nil
+	r.apps = nil
+	r.ranges = nil
+	r.shards = make(map[string]*models.Shard)
+}
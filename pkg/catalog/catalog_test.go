@@ -0,0 +1,188 @@
+package catalog
+
+import (
+	"testing"
+
+	"github.com/sharding-system/pkg/hashing"
+	"github.com/sharding-system/pkg/models"
+)
+
+func TestEtcdCatalog_GetShardUsesClientAppRing(t *testing.T) {
+	c := newTestCatalog(NewMemoryEventLog())
+	for _, shard := range []*models.Shard{
+		{ID: "orders-1", ClientAppID: "orders", Status: "active", HashFunction: hashing.HashCRC32},
+		{ID: "orders-2", ClientAppID: "orders", Status: "active", HashFunction: hashing.HashCRC32},
+		{ID: "users-1", ClientAppID: "users", Status: "active"},
+	} {
+		if err := c.CreateShard(shard); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	// The app's ring only holds its own shards, placed with its hash function
+	ring := hashing.NewConsistentHash(hashing.NewHashFunction(hashing.HashCRC32))
+	ring.AddShard("orders-1", 256)
+	ring.AddShard("orders-2", 256)
+	for _, key := range []string{"a", "b", "c", "d", "e", "f"} {
+		shard, err := c.GetShard(key, "orders")
+		if err != nil {
+			t.Fatalf("unexpected error for %q: %v", key, err)
+		}
+		if want := ring.GetShard(key); shard.ID != want {
+			t.Errorf("expected %q on %s, got %s", key, want, shard.ID)
+		}
+
+		if shard, err := c.GetShard(key, "users"); err != nil || shard.ID != "users-1" {
+			t.Errorf("expected users keys to stay on users-1, got %v, %v", shard, err)
+		}
+	}
+
+	if _, err := c.GetShard("a", "billing"); err == nil {
+		t.Error("expected an error for a client app without shards")
+	}
+
+	if err := c.DeleteShard("users-1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := c.GetShard("a", "users"); err == nil {
+		t.Error("expected an error once the app's last shard is deleted")
+	}
+}
+
+func TestEtcdCatalog_GetShardRoutesAppKeysByStatus(t *testing.T) {
+	c := newTestCatalog(NewMemoryEventLog())
+	source := &models.Shard{ID: "source", ClientAppID: "orders", Status: "active"}
+	target := &models.Shard{ID: "target", ClientAppID: "orders", Status: "migrating"}
+	for _, shard := range []*models.Shard{source, target} {
+		if err := c.CreateShard(shard); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	keys := []string{"a", "b", "c", "d", "e", "f", "g", "h"}
+	expectAll := func(want string) {
+		t.Helper()
+		for _, key := range keys {
+			shard, err := c.GetShard(key, "orders")
+			if err != nil {
+				t.Fatalf("unexpected error for %q: %v", key, err)
+			}
+			if shard.ID != want {
+				t.Errorf("expected %q on %s, got %s", key, want, shard.ID)
+			}
+		}
+	}
+
+	// A target being filled takes no keys until cutover
+	expectAll("source")
+
+	// At cutover the target joins the ring, and once the source is retired
+	// it holds every key
+	target.Status = "active"
+	if err := c.UpdateShard(target); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	source.Status = "inactive"
+	if err := c.UpdateShard(source); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expectAll("target")
+
+	target.Status = "failed"
+	if err := c.UpdateShard(target); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := c.GetShard("a", "orders"); err == nil {
+		t.Error("expected an error once no shard of the app routes keys")
+	}
+}
+
+func TestEtcdCatalog_GetShardByKeyRange(t *testing.T) {
+	c := newTestCatalog(NewMemoryEventLog())
+	for _, shard := range []*models.Shard{
+		{ID: "a-m", ClientAppID: "users", Status: "active", Strategy: "range", KeyRangeEnd: "N"},
+		{ID: "n-z", ClientAppID: "users", Status: "active", Strategy: "range", KeyRangeStart: "N"},
+	} {
+		if err := c.CreateShard(shard); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	tests := map[string]string{
+		"":       "a-m", // the empty key sorts first
+		"Alice":  "a-m",
+		"Mzzz":   "a-m",
+		"N":      "n-z", // end bounds are exclusive
+		"Nadia":  "n-z",
+		"zoe":    "n-z",
+		"Ærøskø": "n-z", // bytewise, non-ASCII sorts after ASCII
+	}
+	for key, want := range tests {
+		shard, err := c.GetShard(key, "users")
+		if err != nil {
+			t.Fatalf("unexpected error for %q: %v", key, err)
+		}
+		if shard.ID != want {
+			t.Errorf("expected %q on %s, got %s", key, want, shard.ID)
+		}
+	}
+
+	// While n-z is split, its keys stay on it until the targets are cut over
+	targets := []*models.Shard{
+		{ID: "n-s", ClientAppID: "users", Status: "migrating", Strategy: "range", KeyRangeStart: "N", KeyRangeEnd: "T"},
+		{ID: "t-z", ClientAppID: "users", Status: "migrating", Strategy: "range", KeyRangeStart: "T"},
+	}
+	for _, shard := range targets {
+		if err := c.CreateShard(shard); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if shard, _ := c.GetShard("Tom", "users"); shard.ID != "n-z" {
+		t.Errorf("expected Tom on n-z during the split, got %s", shard.ID)
+	}
+
+	source, _ := c.GetShardByID("n-z")
+	updated := *source
+	updated.Status = "readonly"
+	c.UpdateShard(&updated)
+	for _, shard := range targets {
+		updated := *shard
+		updated.Status = "active"
+		c.UpdateShard(&updated)
+	}
+	if shard, _ := c.GetShard("Tom", "users"); shard.ID != "t-z" {
+		t.Errorf("expected Tom on t-z after cutover, got %s", shard.ID)
+	}
+	if shard, _ := c.GetShard("Nadia", "users"); shard.ID != "n-s" {
+		t.Errorf("expected Nadia on n-s after cutover, got %s", shard.ID)
+	}
+}
+
+func TestEtcdCatalog_GetShardByKeyRange_UnicodeCollation(t *testing.T) {
+	c := newTestCatalog(NewMemoryEventLog())
+	for _, shard := range []*models.Shard{
+		{ID: "a-m", ClientAppID: "users", Status: "active", Strategy: "range", KeyRangeEnd: "N", Collation: "unicode"},
+		{ID: "n-z", ClientAppID: "users", Status: "active", Strategy: "range", KeyRangeStart: "N", Collation: "unicode"},
+	} {
+		if err := c.CreateShard(shard); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	tests := map[string]string{
+		"éclair": "a-m",
+		"Émile":  "a-m",
+		"alice":  "a-m", // case does not move keys across letters
+		"nadia":  "n-z",
+		"Ærøskø": "a-m",
+		"Ωmega":  "n-z", // Greek sorts after Latin
+	}
+	for key, want := range tests {
+		shard, err := c.GetShard(key, "users")
+		if err != nil {
+			t.Fatalf("unexpected error for %q: %v", key, err)
+		}
+		if shard.ID != want {
+			t.Errorf("expected %q on %s, got %s", key, want, shard.ID)
+		}
+	}
+}
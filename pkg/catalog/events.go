@@ -0,0 +1,211 @@
+package catalog
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sharding-system/pkg/models"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// EventType identifies the kind of catalog mutation
+type EventType string
+
+const (
+	EventShardCreated EventType = "shard_created"
+	EventShardUpdated EventType = "shard_updated"
+	EventShardDeleted EventType = "shard_deleted"
+)
+
+// Event is a single entry in the catalog mutation log. Each event carries a
+// full snapshot of the shard, less its credentials, so consumers can rebuild
+// state by replaying the log from any sequence number; applying the same
+// event twice is harmless.
+type Event struct {
+	Seq         int64         `json:"seq"`
+	Type        EventType     `json:"type"`
+	ShardID     string        `json:"shard_id"`
+	ClientAppID string        `json:"client_app_id,omitempty"`
+	Shard       *models.Shard `json:"shard,omitempty"` // Shard state after the mutation (nil for deletes)
+	Timestamp   time.Time     `json:"timestamp"`
+}
+
+// EventLog is an append-only log of catalog mutations
+type EventLog interface {
+	// Append assigns the next sequence number to the event and stores it
+	Append(ctx context.Context, event *Event) error
+	// Since returns up to limit events with a sequence number greater than seq, oldest first
+	Since(ctx context.Context, seq int64, limit int) ([]Event, error)
+}
+
+// newEvent builds an event for a mutation, snapshotting the shard so later
+// in-place edits of the cached shard do not leak into the log. The log is
+// served over the API, so the snapshot drops the shard's credentials.
+func newEvent(eventType EventType, shard *models.Shard) *Event {
+	event := &Event{
+		Type:        eventType,
+		ShardID:     shard.ID,
+		ClientAppID: shard.ClientAppID,
+		Timestamp:   time.Now(),
+	}
+	if eventType != EventShardDeleted {
+		snapshot := shard.WithoutCredentials()
+		snapshot.VNodes = append([]models.VNode(nil), shard.VNodes...)
+		event.Shard = &snapshot
+	}
+	return event
+}
+
+// MemoryEventLog keeps catalog events in memory. It is used in tests and when
+// no persistent store is available.
+type MemoryEventLog struct {
+	mu     sync.RWMutex
+	events []Event
+	seq    int64
+}
+
+// NewMemoryEventLog creates an empty in-memory event log
+func NewMemoryEventLog() *MemoryEventLog {
+	return &MemoryEventLog{events: make([]Event, 0)}
+}
+
+// Append stores an event with the next sequence number
+func (l *MemoryEventLog) Append(ctx context.Context, event *Event) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.seq++
+	event.Seq = l.seq
+	l.events = append(l.events, *event)
+	return nil
+}
+
+// Since returns events after seq, oldest first
+func (l *MemoryEventLog) Since(ctx context.Context, seq int64, limit int) ([]Event, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	result := make([]Event, 0)
+	for _, event := range l.events {
+		if event.Seq <= seq {
+			continue
+		}
+		result = append(result, event)
+		if limit > 0 && len(result) >= limit {
+			break
+		}
+	}
+	return result, nil
+}
+
+const (
+	eventKeyPrefix = "/catalog/events/"
+	eventSeqKey    = "/catalog/event_seq"
+	// maxAppendAttempts bounds retries when concurrent writers race for a sequence number
+	maxAppendAttempts = 10
+)
+
+// EtcdEventLog persists catalog events in etcd under /catalog/events/
+type EtcdEventLog struct {
+	client *clientv3.Client
+}
+
+// NewEtcdEventLog creates an event log backed by etcd
+func NewEtcdEventLog(client *clientv3.Client) *EtcdEventLog {
+	return &EtcdEventLog{client: client}
+}
+
+// eventKey returns the etcd key for a sequence number. Sequence numbers are
+// zero-padded so that lexical key order matches numeric order.
+func eventKey(seq int64) string {
+	return fmt.Sprintf("%s%020d", eventKeyPrefix, seq)
+}
+
+// txnEventLog is an event log that can store an event in the same etcd
+// transaction as the mutation it records
+type txnEventLog interface {
+	// appendOps assigns the next sequence number to the event and returns the
+	// condition under which the number is still free and the operations
+	// storing the event
+	appendOps(ctx context.Context, event *Event) (clientv3.Cmp, []clientv3.Op, error)
+}
+
+// appendOps reads the current sequence number and prepares the writes that
+// claim the next one for the event
+func (l *EtcdEventLog) appendOps(ctx context.Context, event *Event) (clientv3.Cmp, []clientv3.Op, error) {
+	resp, err := l.client.Get(ctx, eventSeqKey)
+	if err != nil {
+		return clientv3.Cmp{}, nil, fmt.Errorf("failed to read event sequence: %w", err)
+	}
+
+	var current, modRevision int64
+	if len(resp.Kvs) > 0 {
+		current, err = strconv.ParseInt(string(resp.Kvs[0].Value), 10, 64)
+		if err != nil {
+			return clientv3.Cmp{}, nil, fmt.Errorf("invalid event sequence value: %w", err)
+		}
+		modRevision = resp.Kvs[0].ModRevision
+	}
+
+	event.Seq = current + 1
+	data, err := json.Marshal(event)
+	if err != nil {
+		return clientv3.Cmp{}, nil, fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	return clientv3.Compare(clientv3.ModRevision(eventSeqKey), "=", modRevision), []clientv3.Op{
+		clientv3.OpPut(eventSeqKey, strconv.FormatInt(event.Seq, 10)),
+		clientv3.OpPut(eventKey(event.Seq), string(data)),
+	}, nil
+}
+
+// Append allocates the next sequence number and stores the event in a single
+// transaction, retrying if another writer claimed the number first
+func (l *EtcdEventLog) Append(ctx context.Context, event *Event) error {
+	for attempt := 0; attempt < maxAppendAttempts; attempt++ {
+		cmp, ops, err := l.appendOps(ctx, event)
+		if err != nil {
+			return err
+		}
+
+		txnResp, err := l.client.Txn(ctx).If(cmp).Then(ops...).Commit()
+		if err != nil {
+			return fmt.Errorf("failed to append event: %w", err)
+		}
+		if txnResp.Succeeded {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("failed to append event: sequence contention after %d attempts", maxAppendAttempts)
+}
+
+// Since returns events after seq, oldest first
+func (l *EtcdEventLog) Since(ctx context.Context, seq int64, limit int) ([]Event, error) {
+	opts := []clientv3.OpOption{
+		clientv3.WithRange(clientv3.GetPrefixRangeEnd(eventKeyPrefix)),
+		clientv3.WithSort(clientv3.SortByKey, clientv3.SortAscend),
+	}
+	if limit > 0 {
+		opts = append(opts, clientv3.WithLimit(int64(limit)))
+	}
+
+	resp, err := l.client.Get(ctx, eventKey(seq+1), opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read events: %w", err)
+	}
+
+	events := make([]Event, 0, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		var event Event
+		if err := json.Unmarshal(kv.Value, &event); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal event %s: %w", string(kv.Key), err)
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
@@ -0,0 +1,140 @@
+package catalog
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/sharding-system/pkg/models"
+)
+
+// recordMigration upgrades a raw shard record by exactly one schema version.
+// Migrations operate on the decoded JSON map so they can handle fields that no
+// longer exist on models.Shard.
+type recordMigration func(record map[string]interface{}) error
+
+// shardMigrations holds the upgrade steps for shard records. The migration at
+// index i upgrades a record from version i to version i+1.
+var shardMigrations = []recordMigration{
+	migrateShardV0ToV1,
+}
+
+// CurrentSchemaVersion is the catalog record format written by this build
+var CurrentSchemaVersion = len(shardMigrations)
+
+// migrateShardV0ToV1 upgrades unversioned records. Early records were written
+// without a status or replica list, and were keyed without the client app.
+func migrateShardV0ToV1(record map[string]interface{}) error {
+	if status, _ := record["status"].(string); status == "" {
+		record["status"] = "active"
+	}
+	if record["replicas"] == nil {
+		record["replicas"] = []interface{}{}
+	}
+	return nil
+}
+
+// DecodeShard decodes a catalog record and upgrades it to the current schema
+// version. The returned bool reports whether the record had to be upgraded.
+func DecodeShard(data []byte) (*models.Shard, bool, error) {
+	record := make(map[string]interface{})
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, false, fmt.Errorf("failed to unmarshal shard record: %w", err)
+	}
+
+	version := 0
+	if v, ok := record["schema_version"].(float64); ok {
+		version = int(v)
+	}
+	if version > CurrentSchemaVersion {
+		return nil, false, fmt.Errorf("shard record has schema version %d, newer than supported version %d", version, CurrentSchemaVersion)
+	}
+
+	migrated := version < CurrentSchemaVersion
+	for ; version < CurrentSchemaVersion; version++ {
+		if err := shardMigrations[version](record); err != nil {
+			return nil, false, fmt.Errorf("failed to migrate shard record from version %d: %w", version, err)
+		}
+	}
+	record["schema_version"] = CurrentSchemaVersion
+
+	upgraded, err := json.Marshal(record)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to marshal migrated shard record: %w", err)
+	}
+
+	var shard models.Shard
+	if err := json.Unmarshal(upgraded, &shard); err != nil {
+		return nil, false, fmt.Errorf("failed to unmarshal migrated shard record: %w", err)
+	}
+
+	return &shard, migrated, nil
+}
+
+// shardKey returns the canonical etcd key for a shard
+func shardKey(shard *models.Shard) string {
+	return fmt.Sprintf("/shards/%s/%s", shard.ClientAppID, shard.ID)
+}
+
+// storedRecord is a raw shard record as read from the backing store
+type storedRecord struct {
+	Key   string
+	Value []byte
+}
+
+// migrationPlan describes the writes needed to bring stored records up to date
+type migrationPlan struct {
+	Puts    map[string]*models.Shard // canonical key -> upgraded shard
+	Deletes map[string]string        // stale key -> canonical key of the record superseding it
+	Skipped []string                 // keys that could not be decoded
+}
+
+// planMigration works out which records must be rewritten or removed. When the
+// same shard was stored under more than one key, the record with the highest
+// shard version wins and the others are deleted.
+func planMigration(records []storedRecord) *migrationPlan {
+	plan := &migrationPlan{Puts: make(map[string]*models.Shard), Deletes: make(map[string]string)}
+
+	type candidate struct {
+		key      string
+		shard    *models.Shard
+		migrated bool
+	}
+	byID := make(map[string][]candidate)
+	ids := make([]string, 0)
+
+	for _, rec := range records {
+		shard, migrated, err := DecodeShard(rec.Value)
+		if err != nil {
+			plan.Skipped = append(plan.Skipped, rec.Key)
+			continue
+		}
+		if _, seen := byID[shard.ID]; !seen {
+			ids = append(ids, shard.ID)
+		}
+		byID[shard.ID] = append(byID[shard.ID], candidate{key: rec.Key, shard: shard, migrated: migrated})
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		candidates := byID[id]
+		winner := candidates[0]
+		for _, c := range candidates[1:] {
+			if c.shard.Version > winner.shard.Version {
+				winner = c
+			}
+		}
+
+		canonical := shardKey(winner.shard)
+		if winner.migrated || winner.key != canonical {
+			plan.Puts[canonical] = winner.shard
+		}
+		for _, c := range candidates {
+			if c.key != canonical {
+				plan.Deletes[c.key] = canonical
+			}
+		}
+	}
+
+	return plan
+}
@@ -0,0 +1,98 @@
+package catalog
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/sharding-system/pkg/models"
+)
+
+func TestDecodeShard_MigratesLegacyRecord(t *testing.T) {
+	// Records written before schema versioning have no schema_version, status or replicas
+	legacy := []byte(`{"id":"shard1","name":"legacy","client_app_id":"app1","primary_endpoint":"postgres://db1/app","version":3}`)
+
+	shard, migrated, err := DecodeShard(legacy)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !migrated {
+		t.Error("Expected legacy record to be reported as migrated")
+	}
+	if shard.SchemaVersion != CurrentSchemaVersion {
+		t.Errorf("Expected schema version %d, got %d", CurrentSchemaVersion, shard.SchemaVersion)
+	}
+	if shard.Status != "active" {
+		t.Errorf("Expected status active, got %s", shard.Status)
+	}
+	if shard.Replicas == nil {
+		t.Error("Expected replicas to be initialized")
+	}
+	if shard.PrimaryEndpoint != "postgres://db1/app" || shard.Version != 3 {
+		t.Errorf("Expected existing fields to be preserved, got %+v", shard)
+	}
+}
+
+func TestDecodeShard_CurrentRecordUnchanged(t *testing.T) {
+	data, _ := json.Marshal(&models.Shard{ID: "shard1", Status: "readonly", Replicas: []string{}, SchemaVersion: CurrentSchemaVersion})
+
+	shard, migrated, err := DecodeShard(data)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if migrated {
+		t.Error("Expected current record not to be migrated")
+	}
+	if shard.Status != "readonly" {
+		t.Errorf("Expected status readonly to be kept, got %s", shard.Status)
+	}
+}
+
+func TestDecodeShard_RejectsNewerVersion(t *testing.T) {
+	data := []byte(`{"id":"shard1","schema_version":999}`)
+
+	if _, _, err := DecodeShard(data); err == nil {
+		t.Error("Expected error for record from a newer schema version")
+	}
+}
+
+func TestPlanMigration(t *testing.T) {
+	current, _ := json.Marshal(&models.Shard{ID: "shard3", ClientAppID: "app1", Status: "active", Replicas: []string{}, SchemaVersion: CurrentSchemaVersion})
+
+	records := []storedRecord{
+		// Legacy record under the old client-less key
+		{Key: "/shards/shard1", Value: []byte(`{"id":"shard1","client_app_id":"app1","version":1}`)},
+		// Same shard written later by UpdateShard under the canonical key
+		{Key: "/shards/app1/shard1", Value: []byte(`{"id":"shard1","client_app_id":"app1","status":"readonly","version":2}`)},
+		// Legacy record that only exists under the old key
+		{Key: "/shards/shard2", Value: []byte(`{"id":"shard2","client_app_id":"app2"}`)},
+		// Already current
+		{Key: "/shards/app1/shard3", Value: current},
+		{Key: "/shards/broken", Value: []byte(`not json`)},
+	}
+
+	plan := planMigration(records)
+
+	if len(plan.Puts) != 2 {
+		t.Fatalf("Expected 2 puts, got %d: %v", len(plan.Puts), plan.Puts)
+	}
+	shard1, ok := plan.Puts["/shards/app1/shard1"]
+	if !ok {
+		t.Fatal("Expected shard1 to be written to its canonical key")
+	}
+	if shard1.Version != 2 || shard1.Status != "readonly" {
+		t.Errorf("Expected newest shard1 record to win, got version %d status %s", shard1.Version, shard1.Status)
+	}
+	if shard2, ok := plan.Puts["/shards/app2/shard2"]; !ok || shard2.SchemaVersion != CurrentSchemaVersion {
+		t.Error("Expected shard2 to be moved to its canonical key with the current schema version")
+	}
+	if _, ok := plan.Puts["/shards/app1/shard3"]; ok {
+		t.Error("Expected current record not to be rewritten")
+	}
+
+	if len(plan.Deletes) != 2 || plan.Deletes["/shards/shard1"] != "/shards/app1/shard1" || plan.Deletes["/shards/shard2"] != "/shards/app2/shard2" {
+		t.Errorf("Expected legacy keys to be deleted, got %v", plan.Deletes)
+	}
+	if len(plan.Skipped) != 1 || plan.Skipped[0] != "/shards/broken" {
+		t.Errorf("Expected broken record to be skipped, got %v", plan.Skipped)
+	}
+}
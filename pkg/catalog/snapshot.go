@@ -0,0 +1,425 @@
+package catalog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sharding-system/pkg/models"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+var (
+	// ErrSnapshotNotFound is returned for a snapshot name that was never captured
+	ErrSnapshotNotFound = errors.New("catalog snapshot not found")
+	// ErrSnapshotExists is returned when capturing a snapshot under a name already taken
+	ErrSnapshotExists = errors.New("catalog snapshot already exists")
+	// ErrInvalidSnapshotName is returned when capturing a snapshot under a name
+	// that cannot be stored
+	ErrInvalidSnapshotName = errors.New("invalid snapshot name")
+)
+
+// snapshotName matches names snapshots can be captured under; they are used
+// as etcd key segments
+var snapshotName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,127}$`)
+
+// Snapshot is the catalog's shards as they were at a point in time, captured
+// under a name so the catalog can be compared before and after a change.
+// Shards are kept without their credentials.
+type Snapshot struct {
+	Name    string         `json:"name"`
+	Version int64          `json:"version"` // Catalog version when captured
+	TakenAt time.Time      `json:"taken_at"`
+	Shards  []models.Shard `json:"shards"`
+}
+
+// SnapshotInfo describes a snapshot without its shards
+type SnapshotInfo struct {
+	Name       string    `json:"name"`
+	Version    int64     `json:"version"`
+	TakenAt    time.Time `json:"taken_at"`
+	ShardCount int       `json:"shard_count"`
+}
+
+// Info describes the snapshot without its shards
+func (s *Snapshot) Info() SnapshotInfo {
+	return SnapshotInfo{Name: s.Name, Version: s.Version, TakenAt: s.TakenAt, ShardCount: len(s.Shards)}
+}
+
+// SnapshotStore keeps named catalog snapshots. Snapshots are immutable: a name
+// can be captured once.
+type SnapshotStore interface {
+	// Save stores a snapshot, failing with ErrSnapshotExists if its name is taken
+	Save(ctx context.Context, snapshot *Snapshot) error
+	// Get returns a snapshot, or ErrSnapshotNotFound
+	Get(ctx context.Context, name string) (*Snapshot, error)
+	// List describes every snapshot, oldest first
+	List(ctx context.Context) ([]SnapshotInfo, error)
+}
+
+// CaptureSnapshot records the catalog's current shards in store under name
+func CaptureSnapshot(ctx context.Context, cat Catalog, store SnapshotStore, name string) (*Snapshot, error) {
+	if !snapshotName.MatchString(name) {
+		return nil, fmt.Errorf("%w %q: use up to 128 letters, digits, '.', '_' or '-'", ErrInvalidSnapshotName, name)
+	}
+
+	version, err := cat.GetCatalogVersion()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read catalog version: %w", err)
+	}
+	shards, err := cat.ListShards("")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list shards: %w", err)
+	}
+	sort.Slice(shards, func(i, j int) bool { return shards[i].ID < shards[j].ID })
+	for i := range shards {
+		shards[i] = shards[i].WithoutCredentials()
+	}
+
+	snapshot := &Snapshot{Name: name, Version: version, TakenAt: time.Now(), Shards: shards}
+	if err := store.Save(ctx, snapshot); err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// ShardRoute is where a shard sits in routing: the keys it owns and the
+// endpoints they are served from
+type ShardRoute struct {
+	ShardID         string   `json:"shard_id"`
+	ClientAppID     string   `json:"client_app_id"`
+	Strategy        string   `json:"strategy,omitempty"`
+	HashFunction    string   `json:"hash_function,omitempty"`
+	HashRangeStart  uint64   `json:"hash_range_start"`
+	HashRangeEnd    uint64   `json:"hash_range_end"`
+	VNodeCount      int      `json:"vnode_count"`
+	KeyRangeStart   string   `json:"key_range_start,omitempty"`
+	KeyRangeEnd     string   `json:"key_range_end,omitempty"`
+	Collation       string   `json:"collation,omitempty"`
+	PrimaryEndpoint string   `json:"primary_endpoint"`
+	Replicas        []string `json:"replicas"`
+}
+
+func routeOf(shard *models.Shard) ShardRoute {
+	return ShardRoute{
+		ShardID:         shard.ID,
+		ClientAppID:     shard.ClientAppID,
+		Strategy:        shard.Strategy,
+		HashFunction:    shard.HashFunction,
+		HashRangeStart:  shard.HashRangeStart,
+		HashRangeEnd:    shard.HashRangeEnd,
+		VNodeCount:      len(shard.VNodes),
+		KeyRangeStart:   shard.KeyRangeStart,
+		KeyRangeEnd:     shard.KeyRangeEnd,
+		Collation:       shard.Collation,
+		PrimaryEndpoint: models.EndpointLabel(shard.PrimaryEndpoint),
+		Replicas:        endpointLabels(shard.Replicas),
+	}
+}
+
+func endpointLabels(endpoints []string) []string {
+	labels := make([]string, len(endpoints))
+	for i, endpoint := range endpoints {
+		labels[i] = models.EndpointLabel(endpoint)
+	}
+	return labels
+}
+
+// FieldChange is a shard field whose value differs between two snapshots.
+// Values are as the field is serialized; credentials are redacted.
+type FieldChange struct {
+	Field string          `json:"field"`
+	From  json.RawMessage `json:"from"`
+	To    json.RawMessage `json:"to"`
+}
+
+// ShardChange lists the fields of a shard that changed between two snapshots
+type ShardChange struct {
+	ShardID string        `json:"shard_id"`
+	Fields  []FieldChange `json:"fields"`
+}
+
+// RouteChange is a shard whose route changed between two snapshots
+type RouteChange struct {
+	ShardID string     `json:"shard_id"`
+	From    ShardRoute `json:"from"`
+	To      ShardRoute `json:"to"`
+}
+
+// SnapshotDiff is what changed in the catalog between two snapshots
+type SnapshotDiff struct {
+	From          SnapshotInfo  `json:"from"`
+	To            SnapshotInfo  `json:"to"`
+	AddedShards   []string      `json:"added_shards"`
+	RemovedShards []string      `json:"removed_shards"`
+	ChangedShards []ShardChange `json:"changed_shards"`
+	AddedRoutes   []ShardRoute  `json:"added_routes"`
+	RemovedRoutes []ShardRoute  `json:"removed_routes"`
+	ChangedRoutes []RouteChange `json:"changed_routes"`
+}
+
+// Empty reports whether the snapshots hold the same shards and routes
+func (d *SnapshotDiff) Empty() bool {
+	return len(d.AddedShards) == 0 && len(d.RemovedShards) == 0 && len(d.ChangedShards) == 0 &&
+		len(d.AddedRoutes) == 0 && len(d.RemovedRoutes) == 0 && len(d.ChangedRoutes) == 0
+}
+
+// Fields every update touches; a change in them alone says nothing about
+// what an operation did
+var bookkeepingFields = map[string]bool{"version": true, "updated_at": true}
+
+// Fields whose values are never returned
+var secretFields = map[string]bool{"password": true}
+
+// DiffSnapshots compares two snapshots shard by shard, in shard ID order
+func DiffSnapshots(from, to *Snapshot) (*SnapshotDiff, error) {
+	diff := &SnapshotDiff{
+		From:          from.Info(),
+		To:            to.Info(),
+		AddedShards:   make([]string, 0),
+		RemovedShards: make([]string, 0),
+		ChangedShards: make([]ShardChange, 0),
+		AddedRoutes:   make([]ShardRoute, 0),
+		RemovedRoutes: make([]ShardRoute, 0),
+		ChangedRoutes: make([]RouteChange, 0),
+	}
+
+	before := shardsByID(from.Shards)
+	after := shardsByID(to.Shards)
+	ids := make([]string, 0, len(before)+len(after))
+	for id := range before {
+		ids = append(ids, id)
+	}
+	for id := range after {
+		if _, ok := before[id]; !ok {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		prev, next := before[id], after[id]
+		switch {
+		case prev == nil:
+			diff.AddedShards = append(diff.AddedShards, id)
+			diff.AddedRoutes = append(diff.AddedRoutes, routeOf(next))
+		case next == nil:
+			diff.RemovedShards = append(diff.RemovedShards, id)
+			diff.RemovedRoutes = append(diff.RemovedRoutes, routeOf(prev))
+		default:
+			fields, err := changedFields(prev, next)
+			if err != nil {
+				return nil, fmt.Errorf("failed to compare shard %s: %w", id, err)
+			}
+			if len(fields) > 0 {
+				diff.ChangedShards = append(diff.ChangedShards, ShardChange{ShardID: id, Fields: fields})
+			}
+			oldRoute, newRoute := routeOf(prev), routeOf(next)
+			if !sameRoute(oldRoute, newRoute) {
+				diff.ChangedRoutes = append(diff.ChangedRoutes, RouteChange{ShardID: id, From: oldRoute, To: newRoute})
+			}
+		}
+	}
+	return diff, nil
+}
+
+// shardsByID indexes a snapshot's shards without their credentials, which
+// snapshots captured before credentials were left out still hold
+func shardsByID(shards []models.Shard) map[string]*models.Shard {
+	byID := make(map[string]*models.Shard, len(shards))
+	for i := range shards {
+		shard := shards[i].WithoutCredentials()
+		byID[shard.ID] = &shard
+	}
+	return byID
+}
+
+// changedFields compares two versions of a shard field by field, as serialized
+func changedFields(prev, next *models.Shard) ([]FieldChange, error) {
+	before, err := shardFields(prev)
+	if err != nil {
+		return nil, err
+	}
+	after, err := shardFields(next)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(before)+len(after))
+	for name := range before {
+		names = append(names, name)
+	}
+	for name := range after {
+		if _, ok := before[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	changes := make([]FieldChange, 0)
+	for _, name := range names {
+		from, to := before[name], after[name]
+		if bookkeepingFields[name] || bytes.Equal(from, to) {
+			continue
+		}
+		if secretFields[name] {
+			from, to = redacted(from), redacted(to)
+		}
+		changes = append(changes, FieldChange{Field: name, From: orNull(from), To: orNull(to)})
+	}
+	return changes, nil
+}
+
+func shardFields(shard *models.Shard) (map[string]json.RawMessage, error) {
+	data, err := json.Marshal(shard)
+	if err != nil {
+		return nil, err
+	}
+	fields := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+func redacted(value json.RawMessage) json.RawMessage {
+	if value == nil {
+		return nil
+	}
+	return json.RawMessage(`"[redacted]"`)
+}
+
+// orNull stands in for fields omitted from one side of a change
+func orNull(value json.RawMessage) json.RawMessage {
+	if value == nil {
+		return json.RawMessage("null")
+	}
+	return value
+}
+
+func sameRoute(a, b ShardRoute) bool {
+	return reflect.DeepEqual(a, b)
+}
+
+// MemorySnapshotStore keeps catalog snapshots in memory. It is used in tests
+// and when the catalog does not persist snapshots.
+type MemorySnapshotStore struct {
+	mu        sync.RWMutex
+	snapshots map[string]*Snapshot
+	order     []string
+}
+
+// NewMemorySnapshotStore creates an empty in-memory snapshot store
+func NewMemorySnapshotStore() *MemorySnapshotStore {
+	return &MemorySnapshotStore{snapshots: make(map[string]*Snapshot)}
+}
+
+// Save stores a snapshot under its name
+func (s *MemorySnapshotStore) Save(ctx context.Context, snapshot *Snapshot) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.snapshots[snapshot.Name]; ok {
+		return fmt.Errorf("%w: %s", ErrSnapshotExists, snapshot.Name)
+	}
+	s.snapshots[snapshot.Name] = snapshot
+	s.order = append(s.order, snapshot.Name)
+	return nil
+}
+
+// Get returns a snapshot by name
+func (s *MemorySnapshotStore) Get(ctx context.Context, name string) (*Snapshot, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	snapshot, ok := s.snapshots[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrSnapshotNotFound, name)
+	}
+	return snapshot, nil
+}
+
+// List describes every snapshot in the order they were captured
+func (s *MemorySnapshotStore) List(ctx context.Context) ([]SnapshotInfo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	infos := make([]SnapshotInfo, 0, len(s.order))
+	for _, name := range s.order {
+		infos = append(infos, s.snapshots[name].Info())
+	}
+	return infos, nil
+}
+
+const snapshotKeyPrefix = "/catalog/snapshots/"
+
+// EtcdSnapshotStore persists catalog snapshots in etcd under /catalog/snapshots/
+type EtcdSnapshotStore struct {
+	client *clientv3.Client
+}
+
+// NewEtcdSnapshotStore creates a snapshot store backed by etcd
+func NewEtcdSnapshotStore(client *clientv3.Client) *EtcdSnapshotStore {
+	return &EtcdSnapshotStore{client: client}
+}
+
+// Save stores a snapshot, unless another was captured under its name first
+func (s *EtcdSnapshotStore) Save(ctx context.Context, snapshot *Snapshot) error {
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return fmt.Errorf("failed to marshal catalog snapshot: %w", err)
+	}
+
+	key := snapshotKeyPrefix + snapshot.Name
+	resp, err := s.client.Txn(ctx).
+		If(clientv3.Compare(clientv3.CreateRevision(key), "=", 0)).
+		Then(clientv3.OpPut(key, string(data))).
+		Commit()
+	if err != nil {
+		return fmt.Errorf("failed to save catalog snapshot: %w", err)
+	}
+	if !resp.Succeeded {
+		return fmt.Errorf("%w: %s", ErrSnapshotExists, snapshot.Name)
+	}
+	return nil
+}
+
+// Get returns a snapshot by name
+func (s *EtcdSnapshotStore) Get(ctx context.Context, name string) (*Snapshot, error) {
+	resp, err := s.client.Get(ctx, snapshotKeyPrefix+name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read catalog snapshot: %w", err)
+	}
+	if len(resp.Kvs) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrSnapshotNotFound, name)
+	}
+
+	var snapshot Snapshot
+	if err := json.Unmarshal(resp.Kvs[0].Value, &snapshot); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal catalog snapshot %s: %w", name, err)
+	}
+	return &snapshot, nil
+}
+
+// List describes every snapshot, oldest first
+func (s *EtcdSnapshotStore) List(ctx context.Context) ([]SnapshotInfo, error) {
+	resp, err := s.client.Get(ctx, snapshotKeyPrefix, clientv3.WithPrefix(),
+		clientv3.WithSort(clientv3.SortByCreateRevision, clientv3.SortAscend))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list catalog snapshots: %w", err)
+	}
+
+	infos := make([]SnapshotInfo, 0, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		var snapshot Snapshot
+		if err := json.Unmarshal(kv.Value, &snapshot); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal catalog snapshot %s: %w", string(kv.Key), err)
+		}
+		infos = append(infos, snapshot.Info())
+	}
+	return infos, nil
+}
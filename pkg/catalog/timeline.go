@@ -0,0 +1,190 @@
+package catalog
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// ShardEventType identifies what happened to a shard
+type ShardEventType string
+
+const (
+	ShardEventCreated        ShardEventType = "created"
+	ShardEventStatusChanged  ShardEventType = "status_changed"
+	ShardEventSplitStarted   ShardEventType = "split_started"
+	ShardEventSplitCompleted ShardEventType = "split_completed"
+	ShardEventSplitFailed    ShardEventType = "split_failed"
+	ShardEventMergeStarted   ShardEventType = "merge_started"
+	ShardEventMergeCompleted ShardEventType = "merge_completed"
+	ShardEventMergeFailed    ShardEventType = "merge_failed"
+	ShardEventMoveStarted    ShardEventType = "move_started"
+	ShardEventMoveCompleted  ShardEventType = "move_completed"
+	ShardEventMoveFailed     ShardEventType = "move_failed"
+	ShardEventRekeyStarted   ShardEventType = "rekey_started"
+	ShardEventRekeyCompleted ShardEventType = "rekey_completed"
+	ShardEventRekeyFailed    ShardEventType = "rekey_failed"
+	ShardEventPromoted       ShardEventType = "promoted"
+	ShardEventFailedOver     ShardEventType = "failed_over"
+	ShardEventSwitchedOver   ShardEventType = "switched_over"
+	ShardEventDeleted        ShardEventType = "deleted"
+)
+
+// ShardEvent is an entry in a shard's timeline. Unlike catalog events, which
+// record every mutation for replay, shard events describe the operations an
+// operator ran or the system performed, in the order they happened.
+type ShardEvent struct {
+	Seq       int64             `json:"seq"` // Position in the shard's timeline, from 1
+	ShardID   string            `json:"shard_id"`
+	Type      ShardEventType    `json:"type"`
+	Message   string            `json:"message"`
+	Details   map[string]string `json:"details,omitempty"`
+	Timestamp time.Time         `json:"timestamp"`
+}
+
+// ShardTimeline is an append-only history of each shard. A shard's timeline
+// outlives the shard, so its deletion stays on record.
+type ShardTimeline interface {
+	// Record assigns the event the next sequence number of its shard and stores it
+	Record(ctx context.Context, event *ShardEvent) error
+	// Events returns up to limit events of a shard with a sequence number
+	// greater than seq, oldest first
+	Events(ctx context.Context, shardID string, seq int64, limit int) ([]ShardEvent, error)
+}
+
+// MemoryShardTimeline keeps shard timelines in memory. It is used in tests and
+// when the catalog does not persist timelines.
+type MemoryShardTimeline struct {
+	mu     sync.RWMutex
+	events map[string][]ShardEvent
+}
+
+// NewMemoryShardTimeline creates an empty in-memory shard timeline
+func NewMemoryShardTimeline() *MemoryShardTimeline {
+	return &MemoryShardTimeline{events: make(map[string][]ShardEvent)}
+}
+
+// Record stores an event with the next sequence number of its shard
+func (t *MemoryShardTimeline) Record(ctx context.Context, event *ShardEvent) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	event.Seq = int64(len(t.events[event.ShardID])) + 1
+	t.events[event.ShardID] = append(t.events[event.ShardID], *event)
+	return nil
+}
+
+// Events returns a shard's events after seq, oldest first
+func (t *MemoryShardTimeline) Events(ctx context.Context, shardID string, seq int64, limit int) ([]ShardEvent, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	result := make([]ShardEvent, 0)
+	for _, event := range t.events[shardID] {
+		if event.Seq <= seq {
+			continue
+		}
+		result = append(result, event)
+		if limit > 0 && len(result) >= limit {
+			break
+		}
+	}
+	return result, nil
+}
+
+const (
+	shardEventKeyPrefix = "/catalog/shard_events/"
+	shardEventSeqPrefix = "/catalog/shard_event_seq/"
+)
+
+// EtcdShardTimeline persists shard timelines in etcd under
+// /catalog/shard_events/<shard>/, numbering each shard's events separately
+type EtcdShardTimeline struct {
+	client *clientv3.Client
+}
+
+// NewEtcdShardTimeline creates a shard timeline backed by etcd
+func NewEtcdShardTimeline(client *clientv3.Client) *EtcdShardTimeline {
+	return &EtcdShardTimeline{client: client}
+}
+
+// shardEventKey returns the etcd key of a shard's event. Sequence numbers are
+// zero-padded so that lexical key order matches numeric order.
+func shardEventKey(shardID string, seq int64) string {
+	return fmt.Sprintf("%s%s/%020d", shardEventKeyPrefix, shardID, seq)
+}
+
+// Record allocates the shard's next sequence number and stores the event in a
+// single transaction, retrying if another writer claimed the number first
+func (t *EtcdShardTimeline) Record(ctx context.Context, event *ShardEvent) error {
+	seqKey := shardEventSeqPrefix + event.ShardID
+	for attempt := 0; attempt < maxAppendAttempts; attempt++ {
+		resp, err := t.client.Get(ctx, seqKey)
+		if err != nil {
+			return fmt.Errorf("failed to read shard event sequence: %w", err)
+		}
+
+		var current, modRevision int64
+		if len(resp.Kvs) > 0 {
+			current, err = strconv.ParseInt(string(resp.Kvs[0].Value), 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid shard event sequence value: %w", err)
+			}
+			modRevision = resp.Kvs[0].ModRevision
+		}
+
+		event.Seq = current + 1
+		data, err := json.Marshal(event)
+		if err != nil {
+			return fmt.Errorf("failed to marshal shard event: %w", err)
+		}
+
+		txnResp, err := t.client.Txn(ctx).
+			If(clientv3.Compare(clientv3.ModRevision(seqKey), "=", modRevision)).
+			Then(
+				clientv3.OpPut(seqKey, strconv.FormatInt(event.Seq, 10)),
+				clientv3.OpPut(shardEventKey(event.ShardID, event.Seq), string(data)),
+			).
+			Commit()
+		if err != nil {
+			return fmt.Errorf("failed to record shard event: %w", err)
+		}
+		if txnResp.Succeeded {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("failed to record shard event: sequence contention after %d attempts", maxAppendAttempts)
+}
+
+// Events returns a shard's events after seq, oldest first
+func (t *EtcdShardTimeline) Events(ctx context.Context, shardID string, seq int64, limit int) ([]ShardEvent, error) {
+	prefix := shardEventKeyPrefix + shardID + "/"
+	opts := []clientv3.OpOption{
+		clientv3.WithRange(clientv3.GetPrefixRangeEnd(prefix)),
+		clientv3.WithSort(clientv3.SortByKey, clientv3.SortAscend),
+	}
+	if limit > 0 {
+		opts = append(opts, clientv3.WithLimit(int64(limit)))
+	}
+
+	resp, err := t.client.Get(ctx, shardEventKey(shardID, seq+1), opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read shard events: %w", err)
+	}
+
+	events := make([]ShardEvent, 0, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		var event ShardEvent
+		if err := json.Unmarshal(kv.Value, &event); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal shard event %s: %w", string(kv.Key), err)
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
@@ -0,0 +1,40 @@
+package catalog
+
+import (
+	"context"
+	"testing"
+)
+
+func TestMemoryShardTimeline_NumbersEachShard(t *testing.T) {
+	timeline := NewMemoryShardTimeline()
+	ctx := context.Background()
+
+	for _, event := range []ShardEvent{
+		{ShardID: "a", Type: ShardEventCreated},
+		{ShardID: "b", Type: ShardEventCreated},
+		{ShardID: "a", Type: ShardEventSplitStarted},
+		{ShardID: "a", Type: ShardEventSplitCompleted},
+	} {
+		if err := timeline.Record(ctx, &event); err != nil {
+			t.Fatalf("Record: %v", err)
+		}
+	}
+
+	events, _ := timeline.Events(ctx, "a", 0, 0)
+	want := []ShardEventType{ShardEventCreated, ShardEventSplitStarted, ShardEventSplitCompleted}
+	if len(events) != len(want) {
+		t.Fatalf("expected %d events for a, got %+v", len(want), events)
+	}
+	for i, event := range events {
+		if event.Type != want[i] || event.Seq != int64(i+1) {
+			t.Errorf("event %d: expected %s with seq %d, got %s with seq %d", i, want[i], i+1, event.Type, event.Seq)
+		}
+	}
+
+	if events, _ := timeline.Events(ctx, "b", 0, 0); len(events) != 1 || events[0].Seq != 1 {
+		t.Errorf("expected b to have its own sequence, got %+v", events)
+	}
+	if events, _ := timeline.Events(ctx, "a", 1, 1); len(events) != 1 || events[0].Type != ShardEventSplitStarted {
+		t.Errorf("expected one event after seq 1, got %+v", events)
+	}
+}
@@ -60,7 +60,23 @@ func (c *Client) Query(shardKey string, query string, params []interface{}, cons
 		Params:      params,
 		Consistency: consistency,
 	}
+	return c.execute(req)
+}
+
+// QueryAfter executes an eventual read that sees the write sessionToken was
+// returned for: it is only served by a replica that has applied the write
+func (c *Client) QueryAfter(sessionToken string, shardKey string, query string, params ...interface{}) (*models.QueryResponse, error) {
+	return c.execute(&models.QueryRequest{
+		ShardKey:     shardKey,
+		Query:        query,
+		Params:       params,
+		Consistency:  "eventual",
+		SessionToken: sessionToken,
+	})
+}
 
+// execute sends a query request to the router
+func (c *Client) execute(req *models.QueryRequest) (*models.QueryResponse, error) {
 	url := fmt.Sprintf("%s/v1/execute", c.routerURL)
 	
 	body, err := json.Marshal(req)
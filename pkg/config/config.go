@@ -15,6 +15,16 @@ type Config struct {
 	Security      SecurityConfig      `json:"security"`
 	Observability ObservabilityConfig `json:"observability"`
 	Pricing       PricingConfig       `json:"pricing"`
+	ClientApps    ClientAppsConfig    `json:"client_apps"`
+}
+
+// ClientAppsConfig holds client application registration configuration
+type ClientAppsConfig struct {
+	// NamespaceValidation checks that an app's namespace exists in its cluster: "off", "warn" or "strict"
+	NamespaceValidation string `json:"namespace_validation"`
+	// DiscoveryFilter is "strict" to hide discovered apps with incomplete database
+	// configuration, or "lenient" to list them as needing manual database info
+	DiscoveryFilter string `json:"discovery_filter"`
 }
 
 // PricingConfig holds pricing tier configuration
@@ -32,6 +42,23 @@ type ServerConfig struct {
 	ReadTimeoutStr  string        `json:"read_timeout"`
 	WriteTimeoutStr string        `json:"write_timeout"`
 	IdleTimeoutStr  string        `json:"idle_timeout"`
+	// RequestTimeout caps how long a handler may run. Responses are buffered
+	// until the handler completes, so it is off unless set. Streams, the
+	// shard-map long-poll and downloads are not bounded or buffered.
+	RequestTimeout    time.Duration `json:"-"`
+	RequestTimeoutStr string        `json:"request_timeout"`
+	// SocketPath, when set, also serves the API on a Unix domain socket
+	SocketPath string `json:"socket_path"`
+	// TCPKeepAlive is the keepalive probe interval on accepted connections;
+	// zero keeps Go's default of 15s and a negative value disables keepalive
+	TCPKeepAlive    time.Duration `json:"-"`
+	TCPKeepAliveStr string        `json:"tcp_keepalive"`
+	// MaxHeaderBytes caps the size of request headers; zero keeps the 1MB default
+	MaxHeaderBytes int `json:"max_header_bytes"`
+	// DefaultAPIVersion is the payload shape served to clients that send no
+	// Accept-Version or X-API-Version header: "v1" (the default) or "v2". Only
+	// endpoints that document a v2 shape serve anything different under v2.
+	DefaultAPIVersion string `json:"default_api_version,omitempty"`
 }
 
 // MetadataConfig holds metadata store configuration
@@ -54,6 +81,71 @@ type ShardingConfig struct {
 	MaxConnections   int           `json:"max_connections"`
 	ConnectionTTL    time.Duration `json:"-"`
 	ConnectionTTLStr string        `json:"connection_ttl"`
+	// StatementTimeout bounds every routed shard query, even if the client allows longer
+	StatementTimeout    time.Duration `json:"-"`
+	StatementTimeoutStr string        `json:"statement_timeout"`
+	// ProvisioningTimeout is how long a new database may take to become ready
+	// before it is marked failed and its partial resources are removed
+	ProvisioningTimeout    time.Duration `json:"-"`
+	ProvisioningTimeoutStr string        `json:"provisioning_timeout"`
+	// PlacementHosts lists the database hosts new shards may be placed on
+	PlacementHosts []PlacementHost `json:"placement_hosts,omitempty"`
+	// QueryGuard rejects or flags routed queries whose planner cost exceeds a budget
+	QueryGuard QueryGuardConfig `json:"query_guard"`
+	// ReplicaBalancing weights replica selection for reads by replica health
+	ReplicaBalancing ReplicaBalancingConfig `json:"replica_balancing"`
+	// ShadowReadPercent mirrors this percentage of reads on shards being split
+	// to the split's targets and compares the results; 0 turns it off
+	ShadowReadPercent float64 `json:"shadow_read_percent"`
+	// DefaultDatabaseTemplate is the template databases are created from when
+	// they name none; a built-in or registered custom template. Defaults to starter.
+	DefaultDatabaseTemplate string `json:"default_database_template,omitempty"`
+	// FailoverOnDrain switches primaries running on a Kubernetes node being
+	// drained over to a replica before their pod is evicted
+	FailoverOnDrain bool `json:"failover_on_drain"`
+	// StorageExpansion grows the volumes of shards running out of storage
+	// instead of splitting them
+	StorageExpansion StorageExpansionConfig `json:"storage_expansion"`
+}
+
+// StorageExpansionConfig holds the shard volume expansion configuration.
+// Expansion needs the shards' StorageClass to allow volume expansion.
+type StorageExpansionConfig struct {
+	Enabled       bool   `json:"enabled"`        // Grow shards that exceed only their storage threshold automatically
+	GrowthPercent int    `json:"growth_percent"` // Growth of each expansion, in percent of the current size
+	MaxSize       string `json:"max_size"`       // Size past which shards are split instead, e.g. "500Gi"; empty for no limit
+}
+
+// ReplicaBalancingConfig holds the replica read load balancer configuration.
+// Each weight in [0, 1] sets how much of a replica's share a fully erroring,
+// saturated or lagging replica loses; 0 ignores the signal. When no weight is
+// set all three default to 1.
+type ReplicaBalancingConfig struct {
+	ErrorWeight      float64       `json:"error_weight"`      // Recent connection error rate
+	SaturationWeight float64       `json:"saturation_weight"` // Share of the connection pool in use
+	LagWeight        float64       `json:"lag_weight"`        // Replication lag relative to MaxLag
+	MaxErrorRate     float64       `json:"max_error_rate"`    // Replicas erring more often are excluded
+	MaxLag           time.Duration `json:"-"`                 // Replicas lagging further behind are excluded
+	MaxLagStr        string        `json:"max_lag"`
+}
+
+// QueryGuardConfig holds the query cost guardrail configuration
+type QueryGuardConfig struct {
+	MaxCost       float64            `json:"max_cost"`                  // Estimated planner cost budget; 0 disables the guardrail
+	TenantMaxCost map[string]float64 `json:"tenant_max_cost,omitempty"` // Per client application budgets, overriding MaxCost
+	Action        string             `json:"action"`                    // "reject" or "flag"
+	PlanCacheSize int                `json:"plan_cache_size"`           // Number of cached cost estimates
+}
+
+// Enabled reports whether any cost budget is configured
+func (q QueryGuardConfig) Enabled() bool {
+	return q.MaxCost > 0 || len(q.TenantMaxCost) > 0
+}
+
+// PlacementHost declares a database host available for shard placement
+type PlacementHost struct {
+	Host   string  `json:"host"`
+	Weight float64 `json:"weight"` // Relative capacity; a host with weight 2 takes twice the load of weight 1
 }
 
 // SecurityConfig holds security configuration
@@ -62,10 +154,13 @@ type SecurityConfig struct {
 	TLSCertPath  string `json:"tls_cert_path"`
 	TLSKeyPath   string `json:"tls_key_path"`
 	EnableRBAC   bool   `json:"enable_rbac"`
-	JWTSecret    string `json:"jwt_secret"`
+	JWTSecret    string `json:"jwt_secret"` // Falls back to JWT_SECRET when empty
 	AuditLogPath string `json:"audit_log_path"`
 	// UserDatabaseDSN is the PostgreSQL DSN for user storage (MAANG standard)
 	UserDatabaseDSN string `json:"user_database_dsn"`
+	// CORSAllowedOrigins lists the origins allowed to call the API, "*.example.com"
+	// matching subdomains; empty falls back to CORS_ALLOWED_ORIGINS
+	CORSAllowedOrigins []string `json:"cors_allowed_origins,omitempty"`
 }
 
 // ObservabilityConfig holds observability configuration
@@ -74,8 +169,82 @@ type ObservabilityConfig struct {
 	EnableTracing   bool   `json:"enable_tracing"`
 	TracingEndpoint string `json:"tracing_endpoint"`
 	LogLevel        string `json:"log_level"`
+	// CollectionInterval is how often the metrics and PostgreSQL stats
+	// collectors poll registered shards
+	CollectionInterval    time.Duration `json:"-"`
+	CollectionIntervalStr string        `json:"collection_interval"`
+	// SlowQueryThreshold is how long a query must run before it counts as slow
+	SlowQueryThreshold    time.Duration `json:"-"`
+	SlowQueryThresholdStr string        `json:"slow_query_threshold"`
+	// SizeHistoryRetention is how long the stats collector keeps database and
+	// table size samples for growth trending
+	SizeHistoryRetention    time.Duration `json:"-"`
+	SizeHistoryRetentionStr string        `json:"size_history_retention"`
+	// QueryDurationBuckets and RouterLatencyBuckets override the upper bounds,
+	// in seconds, of the shard query and router latency histogram buckets
+	QueryDurationBuckets []float64 `json:"query_duration_buckets"`
+	RouterLatencyBuckets []float64 `json:"router_latency_buckets"`
+	// MaxTableSeries caps how many tables of each database get their own
+	// row count series; the rest are summed into one "others" series
+	MaxTableSeries int `json:"max_table_series"`
+	// MetricsAuth requires a scrape credential on /metrics
+	MetricsAuth MetricsAuthConfig `json:"metrics_auth"`
+	// MetricsCollectorPool and StatsCollectorPool size the connection pools
+	// the metrics and PostgreSQL stats collectors open to each shard
+	MetricsCollectorPool CollectorPoolConfig `json:"metrics_collector_pool"`
+	StatsCollectorPool   CollectorPoolConfig `json:"stats_collector_pool"`
+	// ShardRegistration paces registering existing shards with the collectors
+	// on startup
+	ShardRegistration ShardRegistrationConfig `json:"shard_registration"`
+}
+
+// ShardRegistrationConfig bounds how many existing shards the collectors
+// register at once on startup, so a large cluster is not connected to all at
+// once
+type ShardRegistrationConfig struct {
+	// Concurrency is how many shards register at the same time, across both
+	// collectors
+	Concurrency int `json:"concurrency"`
+	// BatchSize is how many shards a collector registers before pausing for
+	// BatchDelay
+	BatchSize     int           `json:"batch_size"`
+	BatchDelay    time.Duration `json:"-"`
+	BatchDelayStr string        `json:"batch_delay"`
+	// ReconcileInterval is how often the shards registered with the
+	// collectors are checked against the active shards in the catalog, to
+	// heal missed or failed registrations. Negative disables the checks.
+	ReconcileInterval    time.Duration `json:"-"`
+	ReconcileIntervalStr string        `json:"reconcile_interval"`
+}
+
+// PoolSizeConfig bounds the connections a collector keeps to one shard
+type PoolSizeConfig struct {
+	MaxOpenConns int  `json:"max_open_conns"`
+	MaxIdleConns *int `json:"max_idle_conns,omitempty"` // Unset keeps the default; 0 keeps no idle connections
 }
 
+// CollectorPoolConfig sizes a collector's connection pools. Large shards may
+// need more connections for collection to keep up; small ones fewer.
+type CollectorPoolConfig struct {
+	PoolSizeConfig
+	// Shards overrides the pool size of individual shards, by shard ID. Zero
+	// fields keep the collector's size.
+	Shards map[string]PoolSizeConfig `json:"shards,omitempty"`
+}
+
+// MetricsAuthConfig holds the credentials /metrics accepts. With none set,
+// the endpoint is open so Prometheus can scrape it without configuration.
+// Setting a bearer token, basic auth, or both rejects requests without one.
+type MetricsAuthConfig struct {
+	BearerToken string `json:"bearer_token"` // Falls back to METRICS_BEARER_TOKEN when empty
+	Username    string `json:"username"`     // Defaults to DefaultMetricsUsername when a password is set
+	Password    string `json:"password"`     // Falls back to METRICS_PASSWORD when empty
+}
+
+// DefaultMetricsUsername is the basic auth username /metrics requires when
+// only a password is configured
+const DefaultMetricsUsername = "prometheus"
+
 // LoadConfig loads configuration from a JSON file
 func LoadConfig(path string) (*Config, error) {
 	data, err := os.ReadFile(path)
@@ -96,6 +265,20 @@ func LoadConfig(path string) (*Config, error) {
 	// Set defaults
 	setDefaults(&config)
 
+	// Secrets may come from the environment instead of the file
+	if config.Security.JWTSecret == "" {
+		config.Security.JWTSecret = os.Getenv("JWT_SECRET")
+	}
+	if config.Observability.MetricsAuth.BearerToken == "" {
+		config.Observability.MetricsAuth.BearerToken = os.Getenv("METRICS_BEARER_TOKEN")
+	}
+	if config.Observability.MetricsAuth.Password == "" {
+		config.Observability.MetricsAuth.Password = os.Getenv("METRICS_PASSWORD")
+	}
+	if config.Observability.MetricsAuth.Password != "" && config.Observability.MetricsAuth.Username == "" {
+		config.Observability.MetricsAuth.Username = DefaultMetricsUsername
+	}
+
 	return &config, nil
 }
 
@@ -122,6 +305,19 @@ func parseDurations(c *Config) error {
 			return fmt.Errorf("invalid idle_timeout: %w", err)
 		}
 	}
+	if c.Server.RequestTimeoutStr != "" {
+		c.Server.RequestTimeout, err = time.ParseDuration(c.Server.RequestTimeoutStr)
+		if err != nil {
+			return fmt.Errorf("invalid request_timeout: %w", err)
+		}
+	}
+
+	if c.Server.TCPKeepAliveStr != "" {
+		c.Server.TCPKeepAlive, err = time.ParseDuration(c.Server.TCPKeepAliveStr)
+		if err != nil {
+			return fmt.Errorf("invalid tcp_keepalive: %w", err)
+		}
+	}
 
 	// Parse metadata timeout
 	if c.Metadata.TimeoutStr != "" {
@@ -138,6 +334,58 @@ func parseDurations(c *Config) error {
 			return fmt.Errorf("invalid connection_ttl: %w", err)
 		}
 	}
+	if c.Sharding.StatementTimeoutStr != "" {
+		c.Sharding.StatementTimeout, err = time.ParseDuration(c.Sharding.StatementTimeoutStr)
+		if err != nil {
+			return fmt.Errorf("invalid statement_timeout: %w", err)
+		}
+	}
+	if c.Sharding.ProvisioningTimeoutStr != "" {
+		c.Sharding.ProvisioningTimeout, err = time.ParseDuration(c.Sharding.ProvisioningTimeoutStr)
+		if err != nil {
+			return fmt.Errorf("invalid provisioning_timeout: %w", err)
+		}
+	}
+
+	if c.Sharding.ReplicaBalancing.MaxLagStr != "" {
+		c.Sharding.ReplicaBalancing.MaxLag, err = time.ParseDuration(c.Sharding.ReplicaBalancing.MaxLagStr)
+		if err != nil {
+			return fmt.Errorf("invalid replica_balancing.max_lag: %w", err)
+		}
+	}
+
+	if c.Observability.CollectionIntervalStr != "" {
+		c.Observability.CollectionInterval, err = time.ParseDuration(c.Observability.CollectionIntervalStr)
+		if err != nil {
+			return fmt.Errorf("invalid collection_interval: %w", err)
+		}
+	}
+
+	// Parse slow query threshold
+	if c.Observability.ShardRegistration.BatchDelayStr != "" {
+		c.Observability.ShardRegistration.BatchDelay, err = time.ParseDuration(c.Observability.ShardRegistration.BatchDelayStr)
+		if err != nil {
+			return fmt.Errorf("invalid shard_registration.batch_delay: %w", err)
+		}
+	}
+	if c.Observability.ShardRegistration.ReconcileIntervalStr != "" {
+		c.Observability.ShardRegistration.ReconcileInterval, err = time.ParseDuration(c.Observability.ShardRegistration.ReconcileIntervalStr)
+		if err != nil {
+			return fmt.Errorf("invalid shard_registration.reconcile_interval: %w", err)
+		}
+	}
+	if c.Observability.SlowQueryThresholdStr != "" {
+		c.Observability.SlowQueryThreshold, err = time.ParseDuration(c.Observability.SlowQueryThresholdStr)
+		if err != nil {
+			return fmt.Errorf("invalid slow_query_threshold: %w", err)
+		}
+	}
+	if c.Observability.SizeHistoryRetentionStr != "" {
+		c.Observability.SizeHistoryRetention, err = time.ParseDuration(c.Observability.SizeHistoryRetentionStr)
+		if err != nil {
+			return fmt.Errorf("invalid size_history_retention: %w", err)
+		}
+	}
 
 	return nil
 }
@@ -173,13 +421,77 @@ func setDefaults(c *Config) {
 	if c.Sharding.ConnectionTTL == 0 {
 		c.Sharding.ConnectionTTL = 5 * time.Minute
 	}
+	if c.Sharding.StatementTimeout == 0 {
+		c.Sharding.StatementTimeout = 30 * time.Second
+	}
+	if c.Sharding.ProvisioningTimeout == 0 {
+		c.Sharding.ProvisioningTimeout = 30 * time.Minute
+	}
+	if c.Sharding.QueryGuard.Action == "" {
+		c.Sharding.QueryGuard.Action = "reject"
+	}
+	if c.Sharding.QueryGuard.PlanCacheSize == 0 {
+		c.Sharding.QueryGuard.PlanCacheSize = 1024
+	}
+	if c.Sharding.StorageExpansion.GrowthPercent == 0 {
+		c.Sharding.StorageExpansion.GrowthPercent = 50
+	}
+	balancing := &c.Sharding.ReplicaBalancing
+	if balancing.ErrorWeight == 0 && balancing.SaturationWeight == 0 && balancing.LagWeight == 0 {
+		balancing.ErrorWeight, balancing.SaturationWeight, balancing.LagWeight = 1, 1, 1
+	}
+	if balancing.MaxErrorRate == 0 {
+		balancing.MaxErrorRate = 0.5
+	}
+	if balancing.MaxLag == 0 {
+		balancing.MaxLag = 30 * time.Second
+	}
 	if c.Observability.MetricsPort == 0 {
 		c.Observability.MetricsPort = 9090
 	}
 	if c.Observability.LogLevel == "" {
 		c.Observability.LogLevel = "info"
 	}
+	if c.Observability.CollectionInterval == 0 {
+		c.Observability.CollectionInterval = 30 * time.Second
+	}
+	if c.Observability.SlowQueryThreshold == 0 {
+		c.Observability.SlowQueryThreshold = time.Second
+	}
+	if c.Observability.SizeHistoryRetention == 0 {
+		c.Observability.SizeHistoryRetention = 7 * 24 * time.Hour
+	}
+	if c.Observability.MaxTableSeries == 0 {
+		c.Observability.MaxTableSeries = 50
+	}
+	if c.Observability.ShardRegistration.Concurrency == 0 {
+		c.Observability.ShardRegistration.Concurrency = 8
+	}
+	if c.Observability.ShardRegistration.BatchSize == 0 {
+		c.Observability.ShardRegistration.BatchSize = 50
+	}
+	if c.Observability.ShardRegistration.BatchDelay == 0 {
+		c.Observability.ShardRegistration.BatchDelay = time.Second
+	}
+	if c.Observability.ShardRegistration.ReconcileInterval == 0 {
+		c.Observability.ShardRegistration.ReconcileInterval = 5 * time.Minute
+	}
+	for _, pool := range []*CollectorPoolConfig{&c.Observability.MetricsCollectorPool, &c.Observability.StatsCollectorPool} {
+		if pool.MaxOpenConns == 0 {
+			pool.MaxOpenConns = 2
+		}
+		if pool.MaxIdleConns == nil {
+			idle := 1
+			pool.MaxIdleConns = &idle
+		}
+	}
 	if c.Pricing.Tier == "" {
 		c.Pricing.Tier = "free"
 	}
+	if c.ClientApps.NamespaceValidation == "" {
+		c.ClientApps.NamespaceValidation = "warn"
+	}
+	if c.ClientApps.DiscoveryFilter == "" {
+		c.ClientApps.DiscoveryFilter = "strict"
+	}
 }
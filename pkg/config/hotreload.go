@@ -27,12 +27,22 @@ type HotReloader struct {
 	mu            sync.RWMutex
 	checkInterval time.Duration
 	stopCh        chan struct{}
+	reloadMu      sync.Mutex // Serializes reloads from the watcher, signals and the API
+}
+
+// ReloadResult reports what a configuration reload changed
+type ReloadResult struct {
+	Applied         []string `json:"applied"`          // Reload-safe settings now in effect
+	RestartRequired []string `json:"restart_required"` // Changed settings that only take effect after a restart
 }
 
 // HotReloaderConfig holds configuration for the hot reloader
 type HotReloaderConfig struct {
 	ConfigPath    string
 	CheckInterval time.Duration
+	// Initial is the configuration already loaded from ConfigPath; when nil
+	// the file is loaded
+	Initial *Config
 }
 
 // NewHotReloader creates a new configuration hot reloader
@@ -41,9 +51,12 @@ func NewHotReloader(logger *zap.Logger, cfg HotReloaderConfig) (*HotReloader, er
 		cfg.CheckInterval = 10 * time.Second
 	}
 
-	config, err := LoadConfig(cfg.ConfigPath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to load initial config: %w", err)
+	config := cfg.Initial
+	if config == nil {
+		var err error
+		if config, err = LoadConfig(cfg.ConfigPath); err != nil {
+			return nil, fmt.Errorf("failed to load initial config: %w", err)
+		}
 	}
 
 	hash, err := calculateConfigHash(cfg.ConfigPath)
@@ -119,51 +132,69 @@ func (hr *HotReloader) checkAndReload() error {
 	}
 
 	hr.logger.Info("configuration change detected, reloading", zap.String("old_hash", currentHash), zap.String("new_hash", newHash))
+	_, err = hr.reload(newHash)
+	return err
+}
+
+// Reload loads the configuration file and applies its reload-safe settings,
+// whether or not the file changed since it was last read. An invalid file is
+// rejected and the running configuration is kept. Changes to settings that
+// need a restart are reported but not applied; see ReloadSafeSettings.
+func (hr *HotReloader) Reload() (*ReloadResult, error) {
+	hash, err := calculateConfigHash(hr.configPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to calculate config hash: %w", err)
+	}
+	return hr.reload(hash)
+}
+
+func (hr *HotReloader) reload(newHash string) (*ReloadResult, error) {
+	hr.reloadMu.Lock()
+	defer hr.reloadMu.Unlock()
 
 	newConfig, err := LoadConfig(hr.configPath)
 	if err != nil {
-		return fmt.Errorf("failed to load new config: %w", err)
+		return nil, fmt.Errorf("failed to load new config: %w", err)
 	}
 
 	if err := hr.validateConfig(newConfig); err != nil {
 		hr.logger.Warn("new configuration is invalid, not reloading", zap.Error(err))
-		return fmt.Errorf("invalid config: %w", err)
+		return nil, fmt.Errorf("invalid config: %w", err)
 	}
 
-	hr.mu.Lock()
-	oldConfig := hr.currentConfig
-	hr.mu.Unlock()
-
 	hr.mu.RLock()
+	oldConfig := hr.currentConfig
 	callbacks := hr.callbacks
 	hr.mu.RUnlock()
 
+	// Only reload-safe settings change on a running process
+	applied := withReloadSafeSettings(oldConfig, newConfig)
+	result := &ReloadResult{
+		Applied:         changedSettings(oldConfig, applied),
+		RestartRequired: changedSettings(applied, newConfig),
+	}
+	if len(result.RestartRequired) > 0 {
+		hr.logger.Warn("changed settings take effect after a restart, keeping their running values",
+			zap.Strings("settings", result.RestartRequired))
+	}
+
 	for _, callback := range callbacks {
-		if err := callback(oldConfig, newConfig); err != nil {
+		if err := callback(oldConfig, applied); err != nil {
 			hr.logger.Error("reload callback failed", zap.Error(err))
 		}
 	}
 
 	hr.mu.Lock()
-	hr.currentConfig = newConfig
+	hr.currentConfig = applied
 	hr.currentHash = newHash
 	hr.mu.Unlock()
 
-	hr.logger.Info("configuration reloaded successfully")
-	return nil
+	hr.logger.Info("configuration reloaded successfully", zap.Strings("applied", result.Applied))
+	return result, nil
 }
 
 func (hr *HotReloader) validateConfig(cfg *Config) error {
-	if cfg.Server.Port < 1 || cfg.Server.Port > 65535 {
-		return fmt.Errorf("invalid server port: %d", cfg.Server.Port)
-	}
-	if cfg.Sharding.VNodeCount < 1 {
-		return fmt.Errorf("invalid vnode count: %d", cfg.Sharding.VNodeCount)
-	}
-	if cfg.Sharding.MaxConnections < 1 {
-		return fmt.Errorf("invalid max connections: %d", cfg.Sharding.MaxConnections)
-	}
-	return nil
+	return cfg.Validate()
 }
 
 // ForceReload forces a configuration reload
@@ -0,0 +1,136 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zaptest"
+)
+
+const reloadTestConfig = `{
+	"server": {"port": 8081},
+	"metadata": {"endpoints": ["localhost:2379"]},
+	"observability": {"log_level": "%LEVEL%", "collection_interval": "%INTERVAL%"}
+}`
+
+// writeReloadConfig writes the reload test config with the given log level
+// and collection interval
+func writeReloadConfig(t *testing.T, path, level, interval string) {
+	t.Helper()
+	contents := strings.NewReplacer("%LEVEL%", level, "%INTERVAL%", interval).Replace(reloadTestConfig)
+	if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+}
+
+func newTestReloader(t *testing.T) (*HotReloader, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "manager.json")
+	writeReloadConfig(t, path, "info", "30s")
+	reloader, err := NewHotReloader(zaptest.NewLogger(t), HotReloaderConfig{ConfigPath: path})
+	if err != nil {
+		t.Fatalf("failed to create reloader: %v", err)
+	}
+	return reloader, path
+}
+
+func TestHotReloader_Reload_AppliesReloadSafeSettings(t *testing.T) {
+	reloader, path := newTestReloader(t)
+
+	var seen *Config
+	reloader.OnReload(func(old, new *Config) error {
+		seen = new
+		return nil
+	})
+
+	writeReloadConfig(t, path, "debug", "1m")
+	result, err := reloader.Reload()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []string{"observability.log_level", "observability.collection_interval"}
+	if !reflect.DeepEqual(result.Applied, want) {
+		t.Errorf("expected %v to be applied, got %v", want, result.Applied)
+	}
+	if len(result.RestartRequired) != 0 {
+		t.Errorf("expected no restart, got %v", result.RestartRequired)
+	}
+	if seen == nil || seen.Observability.LogLevel != "debug" {
+		t.Fatalf("expected the callback to see the debug level, got %+v", seen)
+	}
+	if got := reloader.GetConfig().Observability.CollectionInterval; got != time.Minute {
+		t.Errorf("expected a 1m collection interval, got %s", got)
+	}
+}
+
+func TestHotReloader_Reload_RejectsInvalidConfig(t *testing.T) {
+	reloader, path := newTestReloader(t)
+	called := false
+	reloader.OnReload(func(old, new *Config) error {
+		called = true
+		return nil
+	})
+
+	writeReloadConfig(t, path, "verbose", "-5s")
+	_, err := reloader.Reload()
+	if err == nil {
+		t.Fatal("expected an invalid config to be rejected")
+	}
+	for _, want := range []string{
+		`observability.log_level must be debug, info, warn or error, got "verbose"`,
+		"observability.collection_interval must be positive, got -5s",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected %q in %q", want, err.Error())
+		}
+	}
+	if called {
+		t.Error("expected no callback for a rejected reload")
+	}
+	if got := reloader.GetConfig().Observability.LogLevel; got != "info" {
+		t.Errorf("expected the running log level to stay info, got %s", got)
+	}
+}
+
+func TestHotReloader_Reload_KeepsRestartOnlySettings(t *testing.T) {
+	reloader, path := newTestReloader(t)
+
+	contents := strings.NewReplacer("%LEVEL%", "warn", "%INTERVAL%", "30s", "8081", "9000").Replace(reloadTestConfig)
+	if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+	result, err := reloader.Reload()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !reflect.DeepEqual(result.Applied, []string{"observability.log_level"}) {
+		t.Errorf("expected only the log level to be applied, got %v", result.Applied)
+	}
+	if !reflect.DeepEqual(result.RestartRequired, []string{"server.port"}) {
+		t.Errorf("expected server.port to need a restart, got %v", result.RestartRequired)
+	}
+	if got := reloader.GetConfig().Server.Port; got != 8081 {
+		t.Errorf("expected the running port to stay 8081, got %d", got)
+	}
+}
+
+func TestChangedSettings_ComparesParsedDurations(t *testing.T) {
+	old := &Config{}
+	old.Server.ReadTimeout, old.Server.ReadTimeoutStr = time.Minute, "60s"
+	new := &Config{}
+	new.Server.ReadTimeout, new.Server.ReadTimeoutStr = time.Minute, "1m"
+	if changed := changedSettings(old, new); len(changed) != 0 {
+		t.Errorf("expected 60s and 1m to be the same, got %v", changed)
+	}
+
+	new.Server.ReadTimeout = time.Second
+	if changed := changedSettings(old, new); !reflect.DeepEqual(changed, []string{"server.read_timeout"}) {
+		t.Errorf("expected server.read_timeout to change, got %v", changed)
+	}
+}
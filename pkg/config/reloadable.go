@@ -0,0 +1,94 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+)
+
+// ReloadSafeSettings lists the settings a running process picks up when its
+// configuration is reloaded:
+//
+//	observability.log_level              level of the process logger
+//	observability.collection_interval    poll interval of the metrics and stats collectors
+//	observability.slow_query_threshold   duration a query must run to count as slow
+//	observability.size_history_retention how long size samples are kept for growth trending
+//	observability.max_table_series       per-database cap on table row series
+//	observability.metrics_collector_pool connections the metrics collector keeps per shard
+//	observability.stats_collector_pool   connections the stats collector keeps per shard
+//	security.cors_allowed_origins        origins allowed to call the API
+//
+// Every other setting, such as ports, timeouts, the metadata store, sharding
+// and RBAC, is only read at startup and needs a restart to change. A reload
+// reports such changes and keeps running with their current values.
+var ReloadSafeSettings = []string{
+	"observability.log_level",
+	"observability.collection_interval",
+	"observability.slow_query_threshold",
+	"observability.size_history_retention",
+	"observability.max_table_series",
+	"observability.metrics_collector_pool",
+	"observability.stats_collector_pool",
+	"security.cors_allowed_origins",
+}
+
+// withReloadSafeSettings returns a copy of live carrying the reload-safe
+// settings of next
+func withReloadSafeSettings(live, next *Config) *Config {
+	applied := *live
+	applied.Observability.LogLevel = next.Observability.LogLevel
+	applied.Observability.CollectionInterval = next.Observability.CollectionInterval
+	applied.Observability.CollectionIntervalStr = next.Observability.CollectionIntervalStr
+	applied.Observability.SlowQueryThreshold = next.Observability.SlowQueryThreshold
+	applied.Observability.SlowQueryThresholdStr = next.Observability.SlowQueryThresholdStr
+	applied.Observability.SizeHistoryRetention = next.Observability.SizeHistoryRetention
+	applied.Observability.SizeHistoryRetentionStr = next.Observability.SizeHistoryRetentionStr
+	applied.Observability.MaxTableSeries = next.Observability.MaxTableSeries
+	applied.Observability.MetricsCollectorPool = next.Observability.MetricsCollectorPool
+	applied.Observability.StatsCollectorPool = next.Observability.StatsCollectorPool
+	applied.Security.CORSAllowedOrigins = next.Security.CORSAllowedOrigins
+	return &applied
+}
+
+// changedSettings returns the "section.setting" names of the settings whose
+// values differ between old and new. Durations are compared by their parsed
+// value, so "60s" and "1m" are the same setting value.
+func changedSettings(old, new *Config) []string {
+	changed := make([]string, 0)
+	oldValue, newValue := reflect.ValueOf(*old), reflect.ValueOf(*new)
+	configType := oldValue.Type()
+
+	for i := 0; i < configType.NumField(); i++ {
+		section := jsonName(configType.Field(i))
+		sectionType := configType.Field(i).Type
+		for j := 0; j < sectionType.NumField(); j++ {
+			field := sectionType.Field(j)
+			name := jsonName(field)
+			if name == "-" {
+				// Parsed durations are named after the string they are parsed from
+				source, ok := sectionType.FieldByName(field.Name + "Str")
+				if !ok {
+					continue
+				}
+				name = jsonName(source)
+			} else if base := strings.TrimSuffix(field.Name, "Str"); base != field.Name {
+				if _, ok := sectionType.FieldByName(base); ok {
+					continue
+				}
+			}
+
+			if !reflect.DeepEqual(oldValue.Field(i).Field(j).Interface(), newValue.Field(i).Field(j).Interface()) {
+				changed = append(changed, section+"."+name)
+			}
+		}
+	}
+	return changed
+}
+
+// jsonName returns the name a struct field is encoded under
+func jsonName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "" {
+		return field.Name
+	}
+	return name
+}
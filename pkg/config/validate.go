@@ -0,0 +1,294 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/sharding-system/pkg/hashing"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// MinJWTSecretLength is the shortest JWT secret the manager and router accept
+// when RBAC is on
+const MinJWTSecretLength = 32
+
+// ValidationError lists every problem found in a configuration, so all of
+// them can be fixed at once instead of one per restart
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid configuration: " + strings.Join(e.Problems, "; ")
+}
+
+// Validate checks that required fields are set, that timeouts, intervals and
+// limits are in sane ranges, and that settings which depend on each other are
+// set together. It returns a *ValidationError listing every problem found.
+func (c *Config) Validate() error {
+	return c.validate(false)
+}
+
+// ValidateManager validates a manager's configuration: on top of Validate, it
+// requires the JWT secret its API authentication needs when RBAC is on.
+func (c *Config) ValidateManager() error {
+	return c.validate(true)
+}
+
+// ValidateRouter validates a router's configuration: on top of Validate, it
+// requires the JWT secret the router verifies clients' tokens with when RBAC
+// is on.
+func (c *Config) ValidateRouter() error {
+	return c.validate(true)
+}
+
+func (c *Config) validate(authenticates bool) error {
+	var problems []string
+	report := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	// Server
+	if c.Server.Port < 1 || c.Server.Port > 65535 {
+		report("server.port must be between 1 and 65535, got %d", c.Server.Port)
+	}
+	if c.Server.ReadTimeout <= 0 {
+		report("server.read_timeout must be positive, got %s", c.Server.ReadTimeout)
+	}
+	if c.Server.WriteTimeout <= 0 {
+		report("server.write_timeout must be positive, got %s", c.Server.WriteTimeout)
+	}
+	if c.Server.IdleTimeout <= 0 {
+		report("server.idle_timeout must be positive, got %s", c.Server.IdleTimeout)
+	}
+	if c.Server.RequestTimeout < 0 {
+		report("server.request_timeout must not be negative, got %s", c.Server.RequestTimeout)
+	}
+	if c.Server.MaxHeaderBytes < 0 {
+		report("server.max_header_bytes must not be negative, got %d", c.Server.MaxHeaderBytes)
+	}
+	switch c.Server.DefaultAPIVersion {
+	case "", "v1", "v2":
+	default:
+		report("server.default_api_version must be v1 or v2, got %q", c.Server.DefaultAPIVersion)
+	}
+
+	// Metadata store
+	switch c.Metadata.Type {
+	case "", "etcd", "postgres":
+	default:
+		report("metadata.type must be etcd or postgres, got %q", c.Metadata.Type)
+	}
+	if len(c.Metadata.Endpoints) == 0 {
+		report("metadata.endpoints is required")
+	}
+	for i, endpoint := range c.Metadata.Endpoints {
+		if strings.TrimSpace(endpoint) == "" {
+			report("metadata.endpoints[%d] is empty", i)
+		}
+	}
+	if c.Metadata.Timeout < 0 {
+		report("metadata.timeout must not be negative, got %s", c.Metadata.Timeout)
+	}
+
+	// Sharding
+	switch c.Sharding.Strategy {
+	case "hash", "range":
+	default:
+		report("sharding.strategy must be hash or range, got %q", c.Sharding.Strategy)
+	}
+	if !hashing.ValidHashFunction(c.Sharding.HashFunction) {
+		report("sharding.hash_function must be murmur3, xxhash or crc32, got %q", c.Sharding.HashFunction)
+	}
+	if c.Sharding.VNodeCount < 1 {
+		report("sharding.vnode_count must be at least 1, got %d", c.Sharding.VNodeCount)
+	}
+	switch c.Sharding.ReplicaPolicy {
+	case "", "primary", "replica_ok":
+	default:
+		report("sharding.replica_policy must be primary or replica_ok, got %q", c.Sharding.ReplicaPolicy)
+	}
+	if c.Sharding.MaxConnections < 1 {
+		report("sharding.max_connections must be at least 1, got %d", c.Sharding.MaxConnections)
+	}
+	if c.Sharding.ConnectionTTL <= 0 {
+		report("sharding.connection_ttl must be positive, got %s", c.Sharding.ConnectionTTL)
+	}
+	if c.Sharding.StatementTimeout <= 0 {
+		report("sharding.statement_timeout must be positive, got %s", c.Sharding.StatementTimeout)
+	}
+	if c.Sharding.ProvisioningTimeout <= 0 {
+		report("sharding.provisioning_timeout must be positive, got %s", c.Sharding.ProvisioningTimeout)
+	}
+	for i, host := range c.Sharding.PlacementHosts {
+		if host.Host == "" {
+			report("sharding.placement_hosts[%d].host is required", i)
+		}
+		if host.Weight < 0 {
+			report("sharding.placement_hosts[%d].weight must not be negative, got %g", i, host.Weight)
+		}
+	}
+
+	if c.Sharding.ShadowReadPercent < 0 || c.Sharding.ShadowReadPercent > 100 {
+		report("sharding.shadow_read_percent must be between 0 and 100, got %g", c.Sharding.ShadowReadPercent)
+	}
+
+	guard := c.Sharding.QueryGuard
+	switch guard.Action {
+	case "reject", "flag":
+	default:
+		report("sharding.query_guard.action must be reject or flag, got %q", guard.Action)
+	}
+	if guard.MaxCost < 0 {
+		report("sharding.query_guard.max_cost must not be negative, got %g", guard.MaxCost)
+	}
+	tenants := make([]string, 0, len(guard.TenantMaxCost))
+	for tenant := range guard.TenantMaxCost {
+		tenants = append(tenants, tenant)
+	}
+	sort.Strings(tenants)
+	for _, tenant := range tenants {
+		if cost := guard.TenantMaxCost[tenant]; cost < 0 {
+			report("sharding.query_guard.tenant_max_cost[%s] must not be negative, got %g", tenant, cost)
+		}
+	}
+	if guard.PlanCacheSize < 1 {
+		report("sharding.query_guard.plan_cache_size must be at least 1, got %d", guard.PlanCacheSize)
+	}
+
+	expansion := c.Sharding.StorageExpansion
+	if expansion.GrowthPercent < 1 {
+		report("sharding.storage_expansion.growth_percent must be at least 1, got %d", expansion.GrowthPercent)
+	}
+	if expansion.MaxSize != "" {
+		if _, err := resource.ParseQuantity(expansion.MaxSize); err != nil {
+			report("sharding.storage_expansion.max_size must be a storage size such as 500Gi, got %q", expansion.MaxSize)
+		}
+	}
+
+	balancing := c.Sharding.ReplicaBalancing
+	for _, weight := range []struct {
+		name  string
+		value float64
+	}{
+		{"error_weight", balancing.ErrorWeight},
+		{"saturation_weight", balancing.SaturationWeight},
+		{"lag_weight", balancing.LagWeight},
+	} {
+		if weight.value < 0 || weight.value > 1 {
+			report("sharding.replica_balancing.%s must be between 0 and 1, got %g", weight.name, weight.value)
+		}
+	}
+	if balancing.MaxErrorRate <= 0 || balancing.MaxErrorRate > 1 {
+		report("sharding.replica_balancing.max_error_rate must be above 0 and at most 1, got %g", balancing.MaxErrorRate)
+	}
+	if balancing.MaxLag <= 0 {
+		report("sharding.replica_balancing.max_lag must be positive, got %s", balancing.MaxLag)
+	}
+
+	// Security
+	if authenticates && c.Security.EnableRBAC {
+		if c.Security.JWTSecret == "" {
+			report("security.jwt_secret is required when enable_rbac is on; set it or JWT_SECRET")
+		} else if len(c.Security.JWTSecret) < MinJWTSecretLength {
+			report("security.jwt_secret must be at least %d characters, got %d", MinJWTSecretLength, len(c.Security.JWTSecret))
+		}
+	}
+	if (c.Security.TLSCertPath == "") != (c.Security.TLSKeyPath == "") {
+		report("security.tls_cert_path and security.tls_key_path must be set together")
+	}
+	for i, origin := range c.Security.CORSAllowedOrigins {
+		if strings.TrimSpace(origin) == "" {
+			report("security.cors_allowed_origins[%d] is empty", i)
+		}
+	}
+
+	// Observability
+	if c.Observability.MetricsPort < 1 || c.Observability.MetricsPort > 65535 {
+		report("observability.metrics_port must be between 1 and 65535, got %d", c.Observability.MetricsPort)
+	}
+	if c.Observability.EnableTracing && c.Observability.TracingEndpoint == "" {
+		report("observability.tracing_endpoint is required when enable_tracing is on")
+	}
+	switch c.Observability.LogLevel {
+	case "debug", "info", "warn", "error":
+	default:
+		report("observability.log_level must be debug, info, warn or error, got %q", c.Observability.LogLevel)
+	}
+	if c.Observability.CollectionInterval <= 0 {
+		report("observability.collection_interval must be positive, got %s", c.Observability.CollectionInterval)
+	}
+	if c.Observability.SlowQueryThreshold <= 0 {
+		report("observability.slow_query_threshold must be positive, got %s", c.Observability.SlowQueryThreshold)
+	}
+	if c.Observability.SizeHistoryRetention <= 0 {
+		report("observability.size_history_retention must be positive, got %s", c.Observability.SizeHistoryRetention)
+	}
+	if registration := c.Observability.ShardRegistration; registration.Concurrency < 0 || registration.BatchSize < 0 || registration.BatchDelay < 0 {
+		report("observability.shard_registration concurrency, batch_size and batch_delay must not be negative")
+	}
+	for _, histogram := range []struct {
+		name    string
+		buckets []float64
+	}{
+		{"query_duration_buckets", c.Observability.QueryDurationBuckets},
+		{"router_latency_buckets", c.Observability.RouterLatencyBuckets},
+	} {
+		for i, bound := range histogram.buckets {
+			if bound <= 0 || (i > 0 && bound <= histogram.buckets[i-1]) {
+				report("observability.%s must be positive and increasing", histogram.name)
+				break
+			}
+		}
+	}
+	if c.Observability.MaxTableSeries < 1 {
+		report("observability.max_table_series must be at least 1, got %d", c.Observability.MaxTableSeries)
+	}
+	for _, pool := range []struct {
+		name   string
+		config CollectorPoolConfig
+	}{
+		{"metrics_collector_pool", c.Observability.MetricsCollectorPool},
+		{"stats_collector_pool", c.Observability.StatsCollectorPool},
+	} {
+		if pool.config.MaxOpenConns < 1 {
+			report("observability.%s.max_open_conns must be at least 1, got %d", pool.name, pool.config.MaxOpenConns)
+		}
+		if idle := pool.config.MaxIdleConns; idle != nil && (*idle < 0 || *idle > pool.config.MaxOpenConns) {
+			report("observability.%s.max_idle_conns must be between 0 and max_open_conns, got %d", pool.name, *idle)
+		}
+		for shardID, size := range pool.config.Shards {
+			if size.MaxOpenConns < 0 || (size.MaxIdleConns != nil && *size.MaxIdleConns < 0) {
+				report("observability.%s.shards.%s must not be negative", pool.name, shardID)
+			}
+			if size.MaxOpenConns > 0 && size.MaxIdleConns != nil && *size.MaxIdleConns > size.MaxOpenConns {
+				report("observability.%s.shards.%s.max_idle_conns must not exceed max_open_conns", pool.name, shardID)
+			}
+		}
+	}
+	if metricsAuth := c.Observability.MetricsAuth; metricsAuth.Username != "" && metricsAuth.Password == "" {
+		report("observability.metrics_auth.username requires observability.metrics_auth.password, which may come from METRICS_PASSWORD")
+	}
+
+	switch strings.ToLower(c.Pricing.Tier) {
+	case "free", "pro", "enterprise":
+	default:
+		report("pricing.tier must be free, pro or enterprise, got %q", c.Pricing.Tier)
+	}
+	switch c.ClientApps.NamespaceValidation {
+	case "off", "warn", "strict":
+	default:
+		report("client_apps.namespace_validation must be off, warn or strict, got %q", c.ClientApps.NamespaceValidation)
+	}
+	switch c.ClientApps.DiscoveryFilter {
+	case "strict", "lenient":
+	default:
+		report("client_apps.discovery_filter must be strict or lenient, got %q", c.ClientApps.DiscoveryFilter)
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
@@ -0,0 +1,208 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// loadTestConfig writes contents to a config file and loads it
+func loadTestConfig(t *testing.T, contents string) *Config {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	return cfg
+}
+
+func TestValidate_ShippedConfigs(t *testing.T) {
+	t.Setenv("JWT_SECRET", "a-secret-that-is-at-least-32-characters")
+	for _, name := range []string{"manager.json", "manager-extended.json"} {
+		cfg, err := LoadConfig(filepath.Join("../../configs", name))
+		if err != nil {
+			t.Fatalf("%s: failed to load config: %v", name, err)
+		}
+		if err := cfg.ValidateManager(); err != nil {
+			t.Errorf("%s: expected a valid config, got %v", name, err)
+		}
+	}
+
+	cfg, err := LoadConfig("../../configs/router.json")
+	if err != nil {
+		t.Fatalf("router.json: failed to load config: %v", err)
+	}
+	if err := cfg.ValidateRouter(); err != nil {
+		t.Errorf("router.json: expected a valid config, got %v", err)
+	}
+
+	// The router verifies client tokens with RBAC on, so it needs the secret too
+	t.Setenv("JWT_SECRET", "")
+	if cfg, err = LoadConfig("../../configs/router.json"); err != nil {
+		t.Fatalf("router.json: failed to load config: %v", err)
+	}
+	if err := cfg.ValidateRouter(); err == nil || !strings.Contains(err.Error(), "security.jwt_secret is required") {
+		t.Errorf("router.json: expected a missing secret to be reported, got %v", err)
+	}
+}
+
+func TestValidate_ReportsEveryProblem(t *testing.T) {
+	t.Setenv("JWT_SECRET", "")
+	cfg := loadTestConfig(t, `{
+		"server": {"port": 70000, "read_timeout": "-1s"},
+		"metadata": {"type": "zookeeper", "endpoints": []},
+		"sharding": {
+			"strategy": "list",
+			"hash_function": "md5",
+			"vnode_count": -1,
+			"query_guard": {"action": "log"},
+			"replica_balancing": {"error_weight": 2, "max_error_rate": 1.5},
+			"storage_expansion": {"growth_percent": -5, "max_size": "lots"}
+		},
+		"security": {"enable_rbac": true, "tls_cert_path": "/etc/tls/cert.pem"},
+		"observability": {"log_level": "trace", "query_duration_buckets": [0.5, 0.1]},
+		"client_apps": {"discovery_filter": "loose"}
+	}`)
+
+	err := cfg.ValidateManager()
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("expected a ValidationError, got %v", err)
+	}
+	for _, want := range []string{
+		"server.port must be between 1 and 65535, got 70000",
+		"server.read_timeout must be positive, got -1s",
+		`metadata.type must be etcd or postgres, got "zookeeper"`,
+		"metadata.endpoints is required",
+		`sharding.strategy must be hash or range, got "list"`,
+		`sharding.hash_function must be murmur3, xxhash or crc32, got "md5"`,
+		"sharding.vnode_count must be at least 1, got -1",
+		`sharding.query_guard.action must be reject or flag, got "log"`,
+		"sharding.replica_balancing.error_weight must be between 0 and 1, got 2",
+		"sharding.replica_balancing.max_error_rate must be above 0 and at most 1, got 1.5",
+		"sharding.storage_expansion.growth_percent must be at least 1, got -5",
+		`sharding.storage_expansion.max_size must be a storage size such as 500Gi, got "lots"`,
+		"security.jwt_secret is required when enable_rbac is on; set it or JWT_SECRET",
+		"security.tls_cert_path and security.tls_key_path must be set together",
+		`observability.log_level must be debug, info, warn or error, got "trace"`,
+		"observability.query_duration_buckets must be positive and increasing",
+		`client_apps.discovery_filter must be strict or lenient, got "loose"`,
+	} {
+		found := false
+		for _, problem := range verr.Problems {
+			if problem == want {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Errorf("expected problem %q, got %v", want, verr.Problems)
+		}
+	}
+	if len(verr.Problems) != 17 {
+		t.Errorf("expected 17 problems, got %d: %v", len(verr.Problems), verr.Problems)
+	}
+	if !strings.HasPrefix(err.Error(), "invalid configuration: server.port must be") {
+		t.Errorf("expected the problems in one message, got %q", err.Error())
+	}
+}
+
+func TestValidate_JWTSecret(t *testing.T) {
+	const base = `{"metadata": {"endpoints": ["localhost:2379"]}, "security": {"enable_rbac": true, "jwt_secret": "%s"}}`
+
+	t.Setenv("JWT_SECRET", "")
+	cfg := loadTestConfig(t, strings.Replace(base, "%s", "too-short", 1))
+	if err := cfg.ValidateManager(); err == nil || !strings.Contains(err.Error(), "security.jwt_secret must be at least 32 characters, got 9") {
+		t.Errorf("expected a short secret to be rejected, got %v", err)
+	}
+
+	// The environment fills in a secret the file leaves empty
+	t.Setenv("JWT_SECRET", "a-secret-that-is-at-least-32-characters")
+	cfg = loadTestConfig(t, strings.Replace(base, "%s", "", 1))
+	if err := cfg.ValidateManager(); err != nil {
+		t.Errorf("expected JWT_SECRET to satisfy enable_rbac, got %v", err)
+	}
+
+	// Without RBAC no secret is needed
+	t.Setenv("JWT_SECRET", "")
+	cfg = loadTestConfig(t, `{"metadata": {"endpoints": ["localhost:2379"]}}`)
+	if err := cfg.ValidateManager(); err != nil {
+		t.Errorf("expected the defaults to be valid, got %v", err)
+	}
+
+	// Only the manager needs a secret
+	cfg = loadTestConfig(t, strings.Replace(base, "%s", "", 1))
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("expected RBAC without a secret to be valid outside the manager, got %v", err)
+	}
+}
+
+func TestValidate_MetricsAuth(t *testing.T) {
+	const base = `{"metadata": {"endpoints": ["localhost:2379"]}, "observability": {"metrics_auth": %s}}`
+
+	t.Setenv("METRICS_PASSWORD", "")
+	cfg := loadTestConfig(t, strings.Replace(base, "%s", `{"username": "prometheus"}`, 1))
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "metrics_auth.username requires observability.metrics_auth.password") {
+		t.Errorf("expected a username without a password to be rejected, got %v", err)
+	}
+
+	// The environment fills in credentials the file leaves empty
+	t.Setenv("METRICS_PASSWORD", "s3cret")
+	t.Setenv("METRICS_BEARER_TOKEN", "scrape-token")
+	cfg = loadTestConfig(t, strings.Replace(base, "%s", `{"username": "prometheus"}`, 1))
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("expected METRICS_PASSWORD to complete basic auth, got %v", err)
+	}
+	if cfg.Observability.MetricsAuth.BearerToken != "scrape-token" {
+		t.Errorf("expected METRICS_BEARER_TOKEN to set the bearer token, got %q", cfg.Observability.MetricsAuth.BearerToken)
+	}
+
+	// A password on its own gets the default username
+	cfg = loadTestConfig(t, strings.Replace(base, "%s", `{}`, 1))
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("expected METRICS_PASSWORD alone to enable basic auth, got %v", err)
+	}
+	if cfg.Observability.MetricsAuth.Username != DefaultMetricsUsername {
+		t.Errorf("expected username %q, got %q", DefaultMetricsUsername, cfg.Observability.MetricsAuth.Username)
+	}
+}
+
+func TestValidate_CollectorPools(t *testing.T) {
+	cfg := loadTestConfig(t, `{"metadata": {"endpoints": ["localhost:2379"]}, "observability": {
+		"metrics_collector_pool": {"max_open_conns": 8, "shards": {"big": {"max_open_conns": 16}}},
+		"stats_collector_pool": {"max_open_conns": 2, "max_idle_conns": 3, "shards": {"small": {"max_open_conns": 1, "max_idle_conns": 2}}}
+	}}`)
+
+	pool := cfg.Observability.MetricsCollectorPool
+	if pool.MaxOpenConns != 8 || pool.MaxIdleConns == nil || *pool.MaxIdleConns != 1 || pool.Shards["big"].MaxOpenConns != 16 {
+		t.Errorf("expected the metrics collector pool with a default idle size, got %+v", pool)
+	}
+
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("expected more idle than open connections to be rejected")
+	}
+	for _, want := range []string{
+		"observability.stats_collector_pool.max_idle_conns must be between 0 and max_open_conns, got 3",
+		"observability.stats_collector_pool.shards.small.max_idle_conns must not exceed max_open_conns",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected %q in %v", want, err)
+		}
+	}
+	if strings.Contains(err.Error(), "metrics_collector_pool") {
+		t.Errorf("expected the metrics collector pool to be valid, got %v", err)
+	}
+
+	// An explicit zero keeps no idle connections rather than the default
+	cfg = loadTestConfig(t, `{"metadata": {"endpoints": ["localhost:2379"]}, "observability": {"metrics_collector_pool": {"max_idle_conns": 0}}}`)
+	if idle := cfg.Observability.MetricsCollectorPool.MaxIdleConns; idle == nil || *idle != 0 {
+		t.Errorf("expected max_idle_conns 0 to be kept, got %v", idle)
+	}
+}
@@ -9,8 +9,10 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/sharding-system/pkg/backup"
 	"github.com/sharding-system/pkg/operator"
 	"github.com/sharding-system/pkg/schema"
+	"github.com/sharding-system/pkg/storage"
 	"go.uber.org/zap"
 )
 
@@ -51,6 +53,10 @@ type ShardStatus struct {
 	Connections  int       `json:"connections"`
 	ReplicaLag   int64     `json:"replica_lag_ms"`
 	LastHealthAt time.Time `json:"last_health_at"`
+	// LastBackupAt is when the shard's last successful backup completed, and
+	// LastBackupStatus the outcome of its latest backup to finish
+	LastBackupAt     *time.Time `json:"last_backup_at,omitempty"`
+	LastBackupStatus string     `json:"last_backup_status,omitempty"`
 }
 
 // TableInfo represents a table in the database
@@ -78,6 +84,10 @@ type DatabaseConfig struct {
 	Storage     StorageConfig  `json:"storage"`
 	Replication ReplicaConfig  `json:"replication"`
 	Backup      BackupConfig   `json:"backup"`
+	Image       ImageConfig    `json:"image"`
+
+	// SchemaBootstrap is how the initial schema is applied to new shards
+	SchemaBootstrap string `json:"schema_bootstrap,omitempty"`
 }
 
 // ResourceConfig defines compute resources
@@ -99,11 +109,28 @@ type ReplicaConfig struct {
 	ReplicasPerShard int  `json:"replicas_per_shard"`
 }
 
+// ImageConfig defines the PostgreSQL image shards run
+type ImageConfig struct {
+	Repository string `json:"repository,omitempty"` // postgres when unset
+	Tag        string `json:"tag,omitempty"`
+	Version    int    `json:"version,omitempty"` // PostgreSQL major version, for tags that do not start with it
+	PullSecret string `json:"pull_secret,omitempty"`
+}
+
+// spec returns the image as the operator takes it
+func (i ImageConfig) spec() operator.PostgresImage {
+	return operator.PostgresImage{Repository: i.Repository, Tag: i.Tag, Version: i.Version, PullSecret: i.PullSecret}
+}
+
 // BackupConfig defines backup settings
 type BackupConfig struct {
 	Enabled   bool   `json:"enabled"`
 	Schedule  string `json:"schedule"` // Cron expression
 	Retention int    `json:"retention_days"`
+	// Storage is the object storage backups are written to, e.g. a bucket in
+	// the tenant's own account. Backups use the shared backup path when unset.
+	Storage *storage.StorageConfig `json:"storage,omitempty"`
+	Bucket  string                 `json:"bucket,omitempty"`
 }
 
 // DatabaseMetrics holds real-time metrics
@@ -118,29 +145,47 @@ type DatabaseMetrics struct {
 
 // CreateDatabaseRequest represents a request to create a new database
 type CreateDatabaseRequest struct {
-	Name           string                 `json:"name"`
-	DisplayName    string                 `json:"display_name,omitempty"`
-	Description    string                 `json:"description,omitempty"`
-	Template       string                 `json:"template,omitempty"` // "starter", "production", "enterprise"
-	ShardCount     int                    `json:"shard_count"`
-	ShardKey       string                 `json:"shard_key"`
-	ShardKeyType   string                 `json:"shard_key_type"`
-	Strategy       string                 `json:"strategy"`
-	Schema         string                 `json:"schema,omitempty"`          // Initial SQL schema
-	SchemaTemplate string                 `json:"schema_template,omitempty"` // Pre-defined template
-	Resources      *ResourceConfig        `json:"resources,omitempty"`
-	Storage        *StorageConfig         `json:"storage,omitempty"`
-	Metadata       map[string]interface{} `json:"metadata,omitempty"`
+	Name            string                 `json:"name"`
+	DisplayName     string                 `json:"display_name,omitempty"`
+	Description     string                 `json:"description,omitempty"`
+	Template        string                 `json:"template,omitempty"` // Built-in or custom template; defaults to the configured default
+	ShardCount      int                    `json:"shard_count"`
+	ShardKey        string                 `json:"shard_key"`
+	ShardKeyType    string                 `json:"shard_key_type"`
+	Strategy        string                 `json:"strategy"`
+	Schema          string                 `json:"schema,omitempty"`           // Initial SQL schema
+	SchemaTemplate  string                 `json:"schema_template,omitempty"`  // Pre-defined template
+	SchemaBootstrap string                 `json:"schema_bootstrap,omitempty"` // "job" (default) or "initdb", applied by PostgreSQL on first start
+	Resources       *ResourceConfig        `json:"resources,omitempty"`
+	Storage         *StorageConfig         `json:"storage,omitempty"`
+	Backup          *BackupConfig          `json:"backup,omitempty"`
+	Metadata        map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// ApplySchemaRequest represents a schema change applied to every shard
+type ApplySchemaRequest struct {
+	SQL    string `json:"sql"`
+	Online bool   `json:"online,omitempty"` // Rewrite table-locking ALTER TABLE statements through a shadow table
 }
 
 // Controller manages sharded databases at a high level
 type Controller struct {
 	logger        *zap.Logger
-	operator      *operator.Operator
+	operator      operator.Provisioner
 	schemaManager *schema.Manager
 	databases     map[string]*Database
 	mu            sync.RWMutex
 	namespace     string
+	readyPoll     time.Duration // How often provisioning checks the operator for readiness
+	templates     *TemplateRegistry
+
+	// provisionTimeout is how long a database may stay creating before the
+	// reaper marks it failed
+	provisionTimeout time.Duration
+
+	// provisioning holds the databases whose operator creation call is still
+	// running; the reaper leaves them alone so it can't race the call
+	provisioning map[*Database]bool
 
 	// Event callbacks
 	onDatabaseReady  func(*Database)
@@ -148,16 +193,28 @@ type Controller struct {
 }
 
 // NewController creates a new database controller
-func NewController(logger *zap.Logger, op *operator.Operator, sm *schema.Manager, namespace string) *Controller {
+func NewController(logger *zap.Logger, op operator.Provisioner, sm *schema.Manager, namespace string) *Controller {
 	return &Controller{
 		logger:        logger,
 		operator:      op,
 		schemaManager: sm,
 		databases:     make(map[string]*Database),
 		namespace:     namespace,
+		readyPoll:     5 * time.Second,
+
+		provisionTimeout: DefaultProvisionTimeout,
+		provisioning:     make(map[*Database]bool),
 	}
 }
 
+// SetTemplates resolves the templates databases are created from in registry,
+// so custom templates can be selected by name
+func (c *Controller) SetTemplates(registry *TemplateRegistry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.templates = registry
+}
+
 // SetOnDatabaseReady sets callback for when database becomes ready
 func (c *Controller) SetOnDatabaseReady(callback func(*Database)) {
 	c.onDatabaseReady = callback
@@ -169,6 +226,35 @@ func (c *Controller) CreateDatabase(ctx context.Context, req CreateDatabaseReque
 	if req.Name == "" {
 		return nil, fmt.Errorf("database name is required")
 	}
+	switch req.SchemaBootstrap {
+	case "", operator.SchemaBootstrapJob, operator.SchemaBootstrapInitDB:
+	default:
+		return nil, fmt.Errorf("invalid schema bootstrap %q: must be %q or %q", req.SchemaBootstrap, operator.SchemaBootstrapJob, operator.SchemaBootstrapInitDB)
+	}
+
+	// Apply template defaults
+	template := operator.PredefinedTemplates["starter"]
+	var backupDefaults *BackupConfig
+	c.mu.RLock()
+	registry := c.templates
+	c.mu.RUnlock()
+	if registry != nil {
+		resolved, err := registry.Get(req.Template)
+		if err != nil {
+			return nil, err
+		}
+		req.Template = resolved.Name
+		template = resolved.provisioning()
+		backupDefaults = resolved.Backup
+		if req.ShardCount < 1 && resolved.Custom {
+			req.ShardCount = resolved.ShardCount
+		}
+	} else if req.Template != "" {
+		if t, ok := operator.PredefinedTemplates[req.Template]; ok {
+			template = t
+		}
+	}
+
 	if req.ShardCount < 1 {
 		req.ShardCount = 2 // Default
 	}
@@ -188,14 +274,6 @@ func (c *Controller) CreateDatabase(ctx context.Context, req CreateDatabaseReque
 		return nil, fmt.Errorf("database %s already exists", req.Name)
 	}
 
-	// Apply template defaults
-	template := operator.PredefinedTemplates["starter"]
-	if req.Template != "" {
-		if t, ok := operator.PredefinedTemplates[req.Template]; ok {
-			template = t
-		}
-	}
-
 	// Override with custom settings
 	resources := template.Resources
 	if req.Resources != nil {
@@ -226,6 +304,13 @@ func (c *Controller) CreateDatabase(ctx context.Context, req CreateDatabaseReque
 		displayName = req.Name
 	}
 
+	var backupConfig BackupConfig
+	if req.Backup != nil {
+		backupConfig = *req.Backup
+	} else if backupDefaults != nil {
+		backupConfig = *backupDefaults
+	}
+
 	// Create database record
 	db := &Database{
 		ID:            uuid.New().String(),
@@ -252,6 +337,14 @@ func (c *Controller) CreateDatabase(ctx context.Context, req CreateDatabaseReque
 				Enabled:          template.Replication.Enabled,
 				ReplicasPerShard: template.Replication.Replicas,
 			},
+			Backup: backupConfig,
+			Image: ImageConfig{
+				Repository: template.Image.Repository,
+				Tag:        template.Image.Tag,
+				Version:    template.Image.Version,
+				PullSecret: template.Image.PullSecret,
+			},
+			SchemaBootstrap: req.SchemaBootstrap,
 		},
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
@@ -259,6 +352,7 @@ func (c *Controller) CreateDatabase(ctx context.Context, req CreateDatabaseReque
 	}
 
 	c.databases[req.Name] = db
+	c.provisioning[db] = true
 	c.mu.Unlock()
 
 	// Provision infrastructure asynchronously
@@ -283,6 +377,9 @@ func (c *Controller) provisionDatabase(ctx context.Context, db *Database, resour
 		Resources:  resources,
 		Storage:    storage,
 		Schema:     initialSchema,
+		Image:      db.Config.Image.spec(),
+
+		SchemaBootstrap: db.Config.SchemaBootstrap,
 	}
 
 	// Set up callback to track shard creation
@@ -303,11 +400,13 @@ func (c *Controller) provisionDatabase(ctx context.Context, db *Database, resour
 	})
 
 	shardedDB, err := c.operator.CreateShardedDatabase(ctx, spec)
+	c.mu.Lock()
+	delete(c.provisioning, db)
+	c.mu.Unlock()
 	if err != nil {
-		c.mu.Lock()
-		db.Status = "failed"
-		db.UpdatedAt = time.Now()
-		c.mu.Unlock()
+		if !c.finishCreating(db, "failed") {
+			return
+		}
 
 		c.logger.Error("failed to create sharded database",
 			zap.String("name", db.Name),
@@ -319,10 +418,13 @@ func (c *Controller) provisionDatabase(ctx context.Context, db *Database, resour
 		return
 	}
 
-	// Wait for operator to complete
+	// Wait for operator to complete, unless the reaper gave up on the database
 	for {
-		time.Sleep(5 * time.Second)
+		time.Sleep(c.readyPoll)
 
+		if !c.creating(db) {
+			return
+		}
 		opDB, exists := c.operator.GetDatabase(db.Name)
 		if !exists {
 			continue
@@ -330,6 +432,10 @@ func (c *Controller) provisionDatabase(ctx context.Context, db *Database, resour
 
 		if opDB.Status.Phase == "Ready" {
 			c.mu.Lock()
+			if db.Status != "creating" {
+				c.mu.Unlock()
+				return
+			}
 			db.Status = "ready"
 			db.ConnectionString = opDB.Status.ConnectionString
 			db.ProxyEndpoint = opDB.Status.ProxyEndpoint
@@ -361,10 +467,9 @@ func (c *Controller) provisionDatabase(ctx context.Context, db *Database, resour
 		}
 
 		if opDB.Status.Phase == "Failed" {
-			c.mu.Lock()
-			db.Status = "failed"
-			db.UpdatedAt = time.Now()
-			c.mu.Unlock()
+			if !c.finishCreating(db, "failed") {
+				return
+			}
 
 			if c.onDatabaseFailed != nil {
 				c.onDatabaseFailed(db, fmt.Errorf("database provisioning failed: %s", shardedDB.Status.Message))
@@ -374,6 +479,26 @@ func (c *Controller) provisionDatabase(ctx context.Context, db *Database, resour
 	}
 }
 
+// creating reports whether a database is still being created
+func (c *Controller) creating(db *Database) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return db.Status == "creating"
+}
+
+// finishCreating moves a database being created to status, or returns false
+// if it is no longer being created, e.g. because the reaper marked it failed
+func (c *Controller) finishCreating(db *Database, status string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if db.Status != "creating" {
+		return false
+	}
+	db.Status = status
+	db.UpdatedAt = time.Now()
+	return true
+}
+
 // GetDatabase retrieves a database by name
 func (c *Controller) GetDatabase(name string) (*Database, bool) {
 	c.mu.RLock()
@@ -382,6 +507,69 @@ func (c *Controller) GetDatabase(name string) (*Database, bool) {
 	return db, exists
 }
 
+// BackupStorage returns the object storage a database's backups are written
+// to, as configured in its backup settings
+func (c *Controller) BackupStorage(name string) (storage.StorageConfig, string, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	db, exists := c.databases[name]
+	if !exists || db.Config.Backup.Storage == nil {
+		return storage.StorageConfig{}, "", false
+	}
+	return *db.Config.Backup.Storage, db.Config.Backup.Bucket, true
+}
+
+// BackupShards returns the IDs of the shards a backup of a database covers
+func (c *Controller) BackupShards(name string) []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	db, exists := c.databases[name]
+	if !exists {
+		return nil
+	}
+	shardIDs := make([]string, 0, len(db.Shards))
+	for _, shard := range db.Shards {
+		shardIDs = append(shardIDs, shard.ID)
+	}
+	return shardIDs
+}
+
+// RecordBackup records a finished backup on the shards of its database it covers
+func (c *Controller) RecordBackup(b backup.Backup) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	db, exists := c.databases[b.DatabaseID]
+	if !exists {
+		return
+	}
+	covered := make(map[string]bool, len(b.ShardIDs))
+	for _, shardID := range b.ShardIDs {
+		covered[shardID] = true
+	}
+	for i := range db.Shards {
+		shard := &db.Shards[i]
+		if !covered[shard.ID] {
+			continue
+		}
+		shard.LastBackupStatus = b.Status
+		if b.Status == "completed" && b.CompletedAt != nil {
+			completedAt := *b.CompletedAt
+			shard.LastBackupAt = &completedAt
+		}
+	}
+}
+
+// ConnectionString returns the connection string of a provisioned database
+func (c *Controller) ConnectionString(name string) (string, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	db, exists := c.databases[name]
+	if !exists || db.ConnectionString == "" {
+		return "", false
+	}
+	return db.ConnectionString, true
+}
+
 // ListDatabases returns all databases
 func (c *Controller) ListDatabases() []*Database {
 	c.mu.RLock()
@@ -460,7 +648,11 @@ func (c *Controller) ScaleDatabase(ctx context.Context, name string, newShardCou
 }
 
 // ApplySchema applies a schema migration to all shards
-func (c *Controller) ApplySchema(ctx context.Context, name string, sql string) error {
+func (c *Controller) ApplySchema(ctx context.Context, name string, req ApplySchemaRequest) error {
+	if req.SQL == "" {
+		return fmt.Errorf("sql is required")
+	}
+
 	c.mu.RLock()
 	db, exists := c.databases[name]
 	if !exists {
@@ -493,11 +685,11 @@ func (c *Controller) ApplySchema(ctx context.Context, name string, sql string) e
 	newVersion := db.SchemaVersion + 1
 	c.mu.Unlock()
 
-	if err := c.schemaManager.RegisterMigration(newVersion, fmt.Sprintf("migration_%d", newVersion), "", sql); err != nil {
+	if err := c.schemaManager.RegisterMigration(newVersion, fmt.Sprintf("migration_%d", newVersion), "", req.SQL); err != nil {
 		return err
 	}
 
-	statuses, err := c.schemaManager.ApplyMigrations(ctx, shards)
+	statuses, err := c.schemaManager.ApplyMigrationsWithOptions(ctx, shards, schema.ApplyOptions{Online: req.Online})
 	if err != nil {
 		return fmt.Errorf("migration failed: %w", err)
 	}
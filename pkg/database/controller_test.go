@@ -0,0 +1,40 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sharding-system/pkg/backup"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestController_RecordBackup(t *testing.T) {
+	c := NewController(zaptest.NewLogger(t), &stalledProvisioner{}, nil, "default")
+	c.databases["orders"] = &Database{Name: "orders", Shards: []ShardStatus{{ID: "orders-0"}, {ID: "orders-1"}, {ID: "orders-2"}}}
+
+	if got := c.BackupShards("orders"); len(got) != 3 || got[0] != "orders-0" {
+		t.Errorf("expected the database's shards, got %v", got)
+	}
+	if got := c.BackupShards("unknown"); got != nil {
+		t.Errorf("expected no shards for an unknown database, got %v", got)
+	}
+
+	// orders-2 was added after the backup started
+	completedAt := time.Now()
+	c.RecordBackup(backup.Backup{ID: "b1", DatabaseID: "orders", Status: "completed", CompletedAt: &completedAt, ShardIDs: []string{"orders-0", "orders-1"}})
+	db, _ := c.GetDatabase("orders")
+	for _, shard := range db.Shards[:2] {
+		if shard.LastBackupStatus != "completed" || shard.LastBackupAt == nil || !shard.LastBackupAt.Equal(completedAt) {
+			t.Errorf("%s: expected the completed backup to be recorded, got %+v", shard.ID, shard)
+		}
+	}
+	if shard := db.Shards[2]; shard.LastBackupStatus != "" || shard.LastBackupAt != nil {
+		t.Errorf("expected a shard the backup did not cover to be left alone, got %+v", shard)
+	}
+
+	// A failed backup keeps the time of the last successful one
+	c.RecordBackup(backup.Backup{ID: "b2", DatabaseID: "orders", Status: "failed", ShardIDs: []string{"orders-0"}})
+	if shard := db.Shards[0]; shard.LastBackupStatus != "failed" || shard.LastBackupAt == nil || !shard.LastBackupAt.Equal(completedAt) {
+		t.Errorf("expected the failure to be recorded and the last backup time kept, got %+v", shard)
+	}
+}
@@ -32,7 +32,7 @@ type SimpleDatabase struct {
 // SimpleCreateDatabaseRequest represents a simplified request to create a database
 type SimpleCreateDatabaseRequest struct {
 	Name        string `json:"name"`                  // Required: Database name
-	Template    string `json:"template,omitempty"`    // Optional: "starter", "production", "enterprise" (default: "starter")
+	Template    string `json:"template,omitempty"`    // Optional: built-in ("starter", "production", "enterprise") or custom template (default: the configured default)
 	ShardKey    string `json:"shard_key,omitempty"`   // Optional: Auto-detected if not provided
 	DisplayName string `json:"display_name,omitempty"` // Optional: Display name
 	Description string `json:"description,omitempty"`  // Optional: Description
@@ -44,6 +44,7 @@ type DatabaseService struct {
 	logger     *zap.Logger
 	routerHost string
 	routerPort int
+	templates  *TemplateRegistry
 }
 
 // NewDatabaseService creates a new database service
@@ -56,6 +57,18 @@ func NewDatabaseService(mgr *manager.Manager, logger *zap.Logger, routerHost str
 	}
 }
 
+// SetTemplates resolves the templates databases are created from in registry,
+// so custom templates can be selected by name
+func (s *DatabaseService) SetTemplates(registry *TemplateRegistry) {
+	s.templates = registry
+}
+
+// Templates returns the registry templates are resolved in, or nil when only
+// the built-in templates are available
+func (s *DatabaseService) Templates() *TemplateRegistry {
+	return s.templates
+}
+
 // CreateDatabase creates a new sharded database with minimal configuration
 func (s *DatabaseService) CreateDatabase(ctx context.Context, req SimpleCreateDatabaseRequest) (*SimpleDatabase, error) {
 	// Validate request
@@ -65,7 +78,13 @@ func (s *DatabaseService) CreateDatabase(ctx context.Context, req SimpleCreateDa
 
 	// Get template
 	template := GetTemplate(req.Template)
-	if req.Template == "" {
+	if s.templates != nil {
+		var err error
+		if template, err = s.templates.Get(req.Template); err != nil {
+			return nil, err
+		}
+		req.Template = template.Name
+	} else if req.Template == "" {
 		req.Template = "starter"
 	}
 
@@ -0,0 +1,89 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// DefaultProvisionTimeout is how long a database may take to become ready
+	// before it is marked failed
+	DefaultProvisionTimeout = 30 * time.Minute
+
+	// reapInterval is how often databases are checked for stuck provisioning
+	reapInterval = time.Minute
+
+	// reapCleanupTimeout bounds removing a stuck database's partial resources
+	reapCleanupTimeout = 2 * time.Minute
+)
+
+// SetProvisionTimeout sets how long a database may stay "creating" before it
+// is marked failed; non-positive timeouts use DefaultProvisionTimeout
+func (c *Controller) SetProvisionTimeout(timeout time.Duration) {
+	if timeout <= 0 {
+		timeout = DefaultProvisionTimeout
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.provisionTimeout = timeout
+}
+
+// StartReaper marks databases stuck creating past the provisioning timeout as
+// failed until ctx is cancelled, so creations the operator never finishes
+// don't accumulate
+func (c *Controller) StartReaper(ctx context.Context) {
+	ticker := time.NewTicker(reapInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			c.reapStale(ctx, now)
+		}
+	}
+}
+
+// reapStale marks databases created more than the provisioning timeout before
+// now that are still creating as failed and deletes their partial resources.
+// Databases the operator is still being asked to create are skipped until the
+// call returns, so their resources aren't deleted while being created.
+func (c *Controller) reapStale(ctx context.Context, now time.Time) {
+	c.mu.Lock()
+	stale := make([]*Database, 0)
+	for _, db := range c.databases {
+		if c.provisioning[db] {
+			continue
+		}
+		if db.Status == "creating" && now.Sub(db.CreatedAt) > c.provisionTimeout {
+			db.Status = "failed"
+			db.UpdatedAt = now
+			stale = append(stale, db)
+		}
+	}
+	timeout := c.provisionTimeout
+	c.mu.Unlock()
+
+	for _, db := range stale {
+		err := fmt.Errorf("database did not become ready within %s", timeout)
+		c.logger.Warn("database provisioning timed out, cleaning up",
+			zap.String("name", db.Name),
+			zap.Duration("timeout", timeout))
+
+		cleanupCtx, cancel := context.WithTimeout(ctx, reapCleanupTimeout)
+		if cleanupErr := c.operator.DeleteDatabase(cleanupCtx, db.Name); cleanupErr != nil {
+			c.logger.Error("failed to clean up timed out database",
+				zap.String("name", db.Name),
+				zap.Error(cleanupErr))
+		}
+		cancel()
+
+		if c.onDatabaseFailed != nil {
+			c.onDatabaseFailed(db, err)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package database
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sharding-system/pkg/operator"
+	"go.uber.org/zap/zaptest"
+)
+
+// stalledProvisioner is an operator whose databases stay provisioning until
+// finish is called. When creating is set, creation calls block until it is
+// closed.
+type stalledProvisioner struct {
+	mu       sync.Mutex
+	finished bool
+	deleted  []string
+	creating chan struct{}
+}
+
+func (p *stalledProvisioner) SetOnShardReady(func(dbName string, shard operator.ShardInfo)) {}
+
+func (p *stalledProvisioner) CreateShardedDatabase(ctx context.Context, spec operator.ShardedDatabaseSpec) (*operator.ShardedDatabase, error) {
+	if p.creating != nil {
+		<-p.creating
+	}
+	return &operator.ShardedDatabase{Spec: spec, Status: operator.ShardedDatabaseStatus{Phase: "Creating"}}, nil
+}
+
+func (p *stalledProvisioner) GetDatabase(name string) (*operator.ShardedDatabase, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	phase := "Creating"
+	if p.finished {
+		phase = "Ready"
+	}
+	return &operator.ShardedDatabase{Status: operator.ShardedDatabaseStatus{Phase: phase}}, true
+}
+
+func (p *stalledProvisioner) ListDatabases() []*operator.ShardedDatabase { return nil }
+
+func (p *stalledProvisioner) DeleteDatabase(ctx context.Context, name string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.deleted = append(p.deleted, name)
+	return nil
+}
+
+func (p *stalledProvisioner) ScaleShards(ctx context.Context, name string, newCount int) error {
+	return nil
+}
+
+// finish makes the operator report its databases ready
+func (p *stalledProvisioner) finish() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.finished = true
+}
+
+func (p *stalledProvisioner) deletedDatabases() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.deleted...)
+}
+
+// databaseStatus returns a database's status, read under the controller's lock
+func databaseStatus(c *Controller, name string) string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.databases[name].Status
+}
+
+// waitCreateCalled waits for the operator's creation call for db to return
+func waitCreateCalled(t *testing.T, c *Controller, db *Database) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for {
+		c.mu.RLock()
+		provisioning := c.provisioning[db]
+		c.mu.RUnlock()
+		if !provisioning {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected the operator creation call to return")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func newReaperTestController(t *testing.T) (*Controller, *stalledProvisioner) {
+	t.Helper()
+	provisioner := &stalledProvisioner{}
+	controller := NewController(zaptest.NewLogger(t), provisioner, nil, "default")
+	controller.readyPoll = time.Millisecond
+	controller.SetProvisionTimeout(10 * time.Minute)
+	return controller, provisioner
+}
+
+func TestReapStale_FailsStuckDatabases(t *testing.T) {
+	controller, provisioner := newReaperTestController(t)
+	var failed []string
+	controller.onDatabaseFailed = func(db *Database, err error) { failed = append(failed, db.Name) }
+
+	db, err := controller.CreateDatabase(context.Background(), CreateDatabaseRequest{Name: "orders"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	waitCreateCalled(t, controller, db)
+
+	// Within the deadline the database is left to finish
+	controller.reapStale(context.Background(), db.CreatedAt.Add(5*time.Minute))
+	if status := databaseStatus(controller, "orders"); status != "creating" {
+		t.Fatalf("expected the database to still be creating, got %s", status)
+	}
+
+	controller.reapStale(context.Background(), db.CreatedAt.Add(11*time.Minute))
+	if status := databaseStatus(controller, "orders"); status != "failed" {
+		t.Fatalf("expected the database to be marked failed, got %s", status)
+	}
+	if deleted := provisioner.deletedDatabases(); len(deleted) != 1 || deleted[0] != "orders" {
+		t.Errorf("expected the partial resources to be deleted, got %v", deleted)
+	}
+	if len(failed) != 1 || failed[0] != "orders" {
+		t.Errorf("expected the failure to be reported, got %v", failed)
+	}
+
+	// Provisioning finishing late doesn't revive the database
+	provisioner.finish()
+	time.Sleep(20 * time.Millisecond)
+	if status := databaseStatus(controller, "orders"); status != "failed" {
+		t.Errorf("expected the database to stay failed, got %s", status)
+	}
+}
+
+func TestReapStale_SkipsDatabasesStillBeingCreated(t *testing.T) {
+	controller, provisioner := newReaperTestController(t)
+	provisioner.creating = make(chan struct{})
+
+	db, err := controller.CreateDatabase(context.Background(), CreateDatabaseRequest{Name: "orders"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The operator is still creating the database, so deleting it now would
+	// race the creation
+	controller.reapStale(context.Background(), db.CreatedAt.Add(11*time.Minute))
+	if status := databaseStatus(controller, "orders"); status != "creating" {
+		t.Fatalf("expected the database to still be creating, got %s", status)
+	}
+	if deleted := provisioner.deletedDatabases(); len(deleted) != 0 {
+		t.Fatalf("expected nothing to be deleted, got %v", deleted)
+	}
+
+	close(provisioner.creating)
+	waitCreateCalled(t, controller, db)
+
+	controller.reapStale(context.Background(), db.CreatedAt.Add(11*time.Minute))
+	if status := databaseStatus(controller, "orders"); status != "failed" {
+		t.Fatalf("expected the database to be marked failed, got %s", status)
+	}
+	if deleted := provisioner.deletedDatabases(); len(deleted) != 1 || deleted[0] != "orders" {
+		t.Errorf("expected the partial resources to be deleted, got %v", deleted)
+	}
+}
+
+func TestReapStale_LeavesReadyDatabases(t *testing.T) {
+	controller, provisioner := newReaperTestController(t)
+	provisioner.finish()
+
+	db, err := controller.CreateDatabase(context.Background(), CreateDatabaseRequest{Name: "orders"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for databaseStatus(controller, "orders") != "ready" {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the database to become ready")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	controller.reapStale(context.Background(), db.CreatedAt.Add(time.Hour))
+	if status := databaseStatus(controller, "orders"); status != "ready" {
+		t.Errorf("expected a ready database to be left alone, got %s", status)
+	}
+	if deleted := provisioner.deletedDatabases(); len(deleted) != 0 {
+		t.Errorf("expected nothing to be deleted, got %v", deleted)
+	}
+}
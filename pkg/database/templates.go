@@ -1,48 +1,87 @@
 package database
 
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"sync"
+
+	"github.com/sharding-system/pkg/operator"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// DefaultTemplate is the template used for databases created without one,
+// unless another default is configured
+const DefaultTemplate = "starter"
+
+var (
+	// ErrTemplateNotFound is returned for a template name that is neither
+	// built in nor registered
+	ErrTemplateNotFound = errors.New("database template not found")
+	// ErrInvalidTemplate is returned when registering a template that cannot be used
+	ErrInvalidTemplate = errors.New("invalid database template")
+)
+
+// templateName matches names templates can be registered under; they are used
+// as etcd key segments
+var templateName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,62}$`)
+
 // DatabaseTemplate defines a pre-configured template for database creation
 type DatabaseTemplate struct {
-	Name            string   `json:"name"`
-	DisplayName     string   `json:"display_name"`
-	Description     string   `json:"description"`
-	ShardCount      int      `json:"shard_count"`
-	ReplicasPerShard int     `json:"replicas_per_shard"`
-	VNodeCount      int      `json:"vnode_count"`
-	AutoScale       bool     `json:"auto_scale"`
-	EstimatedCost   string   `json:"estimated_cost"` // Monthly estimate
+	Name             string `json:"name"`
+	DisplayName      string `json:"display_name"`
+	Description      string `json:"description"`
+	ShardCount       int    `json:"shard_count"`
+	ReplicasPerShard int    `json:"replicas_per_shard"`
+	VNodeCount       int    `json:"vnode_count"`
+	AutoScale        bool   `json:"auto_scale"`
+	EstimatedCost    string `json:"estimated_cost"` // Monthly estimate
+
+	// Defaults for databases created from the template. Left unset, they come
+	// from the built-in template of the same name, or else from starter.
+	Resources *ResourceConfig `json:"resources,omitempty"`
+	Storage   *StorageConfig  `json:"storage,omitempty"`
+	Backup    *BackupConfig   `json:"backup,omitempty"`
+	Image     *ImageConfig    `json:"image,omitempty"`
+
+	// Custom marks templates registered by operators rather than built in
+	Custom bool `json:"custom,omitempty"`
 }
 
 // PredefinedTemplates contains templates for different use cases
 var PredefinedTemplates = map[string]DatabaseTemplate{
 	"starter": {
-		Name:            "starter",
-		DisplayName:     "Starter",
-		Description:     "Perfect for small applications and development. 2 shards with 1 replica each.",
-		ShardCount:      2,
+		Name:             "starter",
+		DisplayName:      "Starter",
+		Description:      "Perfect for small applications and development. 2 shards with 1 replica each.",
+		ShardCount:       2,
 		ReplicasPerShard: 1,
-		VNodeCount:      256,
-		AutoScale:       true,
-		EstimatedCost:   "$99/month",
+		VNodeCount:       256,
+		AutoScale:        true,
+		EstimatedCost:    "$99/month",
 	},
 	"production": {
-		Name:            "production",
-		DisplayName:     "Production",
-		Description:     "For production workloads. 4 shards with 2 replicas each for high availability.",
-		ShardCount:      4,
+		Name:             "production",
+		DisplayName:      "Production",
+		Description:      "For production workloads. 4 shards with 2 replicas each for high availability.",
+		ShardCount:       4,
 		ReplicasPerShard: 2,
-		VNodeCount:      512,
-		AutoScale:       true,
-		EstimatedCost:   "$299/month",
+		VNodeCount:       512,
+		AutoScale:        true,
+		EstimatedCost:    "$299/month",
 	},
 	"enterprise": {
-		Name:            "enterprise",
-		DisplayName:     "Enterprise",
-		Description:     "For large-scale enterprise applications. 8 shards with 2 replicas each, multi-region ready.",
-		ShardCount:      8,
+		Name:             "enterprise",
+		DisplayName:      "Enterprise",
+		Description:      "For large-scale enterprise applications. 8 shards with 2 replicas each, multi-region ready.",
+		ShardCount:       8,
 		ReplicasPerShard: 2,
-		VNodeCount:      1024,
-		AutoScale:       true,
-		EstimatedCost:   "Custom pricing",
+		VNodeCount:       1024,
+		AutoScale:        true,
+		EstimatedCost:    "Custom pricing",
 	},
 }
 
@@ -63,3 +102,229 @@ func ListTemplates() []DatabaseTemplate {
 	return templates
 }
 
+// provisioning returns the operator settings databases created from the
+// template are provisioned with: the built-in operator template of the same
+// name, or starter's, overlaid with the template's own defaults
+func (t DatabaseTemplate) provisioning() operator.DatabaseTemplate {
+	base, ok := operator.PredefinedTemplates[t.Name]
+	if !ok {
+		base = operator.PredefinedTemplates["starter"]
+	}
+	if t.ShardCount > 0 {
+		base.ShardCount = t.ShardCount
+	}
+	if t.Resources != nil {
+		base.Resources = operator.ShardResources{CPU: t.Resources.CPU, Memory: t.Resources.Memory}
+	}
+	if t.Storage != nil {
+		base.Storage = operator.StorageConfig{Size: t.Storage.SizePerShard, StorageClass: t.Storage.StorageClass}
+	}
+	if t.Image != nil {
+		base.Image = t.Image.spec()
+	}
+	return base
+}
+
+// TemplateStore persists custom database templates
+type TemplateStore interface {
+	// Save stores a template, replacing any registered under its name
+	Save(ctx context.Context, template DatabaseTemplate) error
+	// List returns every stored template
+	List(ctx context.Context) ([]DatabaseTemplate, error)
+}
+
+// TemplateRegistry resolves database templates by name: custom templates
+// operators register, which may override a built-in one of the same name,
+// and the built-in templates. Custom templates are persisted in a store.
+type TemplateRegistry struct {
+	store       TemplateStore
+	mu          sync.RWMutex
+	custom      map[string]DatabaseTemplate
+	defaultName string
+}
+
+// NewTemplateRegistry creates a registry persisting custom templates in store
+func NewTemplateRegistry(store TemplateStore) *TemplateRegistry {
+	return &TemplateRegistry{
+		store:       store,
+		custom:      make(map[string]DatabaseTemplate),
+		defaultName: DefaultTemplate,
+	}
+}
+
+// Load reads the custom templates registered before from the store
+func (r *TemplateRegistry) Load(ctx context.Context) error {
+	templates, err := r.store.List(ctx)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, template := range templates {
+		r.custom[template.Name] = template
+	}
+	return nil
+}
+
+// Register validates a custom template, fills in its defaults and stores it,
+// replacing any template registered under its name
+func (r *TemplateRegistry) Register(ctx context.Context, template DatabaseTemplate) (*DatabaseTemplate, error) {
+	if !templateName.MatchString(template.Name) {
+		return nil, fmt.Errorf("%w: name %q must be up to 63 lowercase letters, digits, '_' or '-'", ErrInvalidTemplate, template.Name)
+	}
+	if template.ShardCount < 0 || template.VNodeCount < 0 || template.ReplicasPerShard < 0 {
+		return nil, fmt.Errorf("%w: counts cannot be negative", ErrInvalidTemplate)
+	}
+	if template.Image != nil {
+		if err := operator.ValidateImage(template.Image.spec()); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidTemplate, err)
+		}
+	}
+
+	// Unset sizes come from the template being overridden, or else from starter
+	base := GetTemplate(template.Name)
+	if template.ShardCount == 0 {
+		template.ShardCount = base.ShardCount
+	}
+	if template.VNodeCount == 0 {
+		template.VNodeCount = base.VNodeCount
+	}
+	if template.DisplayName == "" {
+		template.DisplayName = template.Name
+	}
+	template.Custom = true
+
+	if err := r.store.Save(ctx, template); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	r.custom[template.Name] = template
+	r.mu.Unlock()
+	return &template, nil
+}
+
+// SetDefault makes name the template used for databases created without one
+func (r *TemplateRegistry) SetDefault(name string) error {
+	if _, err := r.Get(name); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.defaultName = name
+	return nil
+}
+
+// Default returns the name of the template used when none is requested
+func (r *TemplateRegistry) Default() string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.defaultName
+}
+
+// Get returns a template by name, or the default template for an empty name
+func (r *TemplateRegistry) Get(name string) (DatabaseTemplate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if name == "" {
+		name = r.defaultName
+	}
+	if template, ok := r.custom[name]; ok {
+		return template, nil
+	}
+	if template, ok := PredefinedTemplates[name]; ok {
+		return template, nil
+	}
+	return DatabaseTemplate{}, fmt.Errorf("%w: %s", ErrTemplateNotFound, name)
+}
+
+// List returns every template, custom ones in place of the built-in templates
+// they override, sorted by name
+func (r *TemplateRegistry) List() []DatabaseTemplate {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	templates := make([]DatabaseTemplate, 0, len(PredefinedTemplates)+len(r.custom))
+	for name, template := range PredefinedTemplates {
+		if _, overridden := r.custom[name]; !overridden {
+			templates = append(templates, template)
+		}
+	}
+	for _, template := range r.custom {
+		templates = append(templates, template)
+	}
+	sort.Slice(templates, func(i, j int) bool { return templates[i].Name < templates[j].Name })
+	return templates
+}
+
+// MemoryTemplateStore keeps custom templates in memory. It is used in tests
+// and when the catalog cannot persist templates.
+type MemoryTemplateStore struct {
+	mu        sync.RWMutex
+	templates map[string]DatabaseTemplate
+}
+
+// NewMemoryTemplateStore creates an empty in-memory template store
+func NewMemoryTemplateStore() *MemoryTemplateStore {
+	return &MemoryTemplateStore{templates: make(map[string]DatabaseTemplate)}
+}
+
+// Save stores a template under its name
+func (s *MemoryTemplateStore) Save(ctx context.Context, template DatabaseTemplate) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.templates[template.Name] = template
+	return nil
+}
+
+// List returns every stored template
+func (s *MemoryTemplateStore) List(ctx context.Context) ([]DatabaseTemplate, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	templates := make([]DatabaseTemplate, 0, len(s.templates))
+	for _, template := range s.templates {
+		templates = append(templates, template)
+	}
+	return templates, nil
+}
+
+const templateKeyPrefix = "/catalog/templates/"
+
+// EtcdTemplateStore persists custom templates in the catalog's etcd under
+// /catalog/templates/
+type EtcdTemplateStore struct {
+	client *clientv3.Client
+}
+
+// NewEtcdTemplateStore creates a template store backed by etcd
+func NewEtcdTemplateStore(client *clientv3.Client) *EtcdTemplateStore {
+	return &EtcdTemplateStore{client: client}
+}
+
+// Save stores a template under its name
+func (s *EtcdTemplateStore) Save(ctx context.Context, template DatabaseTemplate) error {
+	data, err := json.Marshal(template)
+	if err != nil {
+		return fmt.Errorf("failed to marshal database template: %w", err)
+	}
+	if _, err := s.client.Put(ctx, templateKeyPrefix+template.Name, string(data)); err != nil {
+		return fmt.Errorf("failed to save database template: %w", err)
+	}
+	return nil
+}
+
+// List returns every stored template
+func (s *EtcdTemplateStore) List(ctx context.Context) ([]DatabaseTemplate, error) {
+	resp, err := s.client.Get(ctx, templateKeyPrefix, clientv3.WithPrefix())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list database templates: %w", err)
+	}
+
+	templates := make([]DatabaseTemplate, 0, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		var template DatabaseTemplate
+		if err := json.Unmarshal(kv.Value, &template); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal database template %s: %w", string(kv.Key), err)
+		}
+		templates = append(templates, template)
+	}
+	return templates, nil
+}
@@ -0,0 +1,178 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sharding-system/pkg/operator"
+	"go.uber.org/zap/zaptest"
+)
+
+// recordingProvisioner records the specs databases are provisioned with
+type recordingProvisioner struct {
+	mu    sync.Mutex
+	specs map[string]operator.ShardedDatabaseSpec
+}
+
+func (p *recordingProvisioner) SetOnShardReady(func(dbName string, shard operator.ShardInfo)) {}
+
+func (p *recordingProvisioner) CreateShardedDatabase(ctx context.Context, spec operator.ShardedDatabaseSpec) (*operator.ShardedDatabase, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.specs[spec.Name] = spec
+	return &operator.ShardedDatabase{Spec: spec}, nil
+}
+
+func (p *recordingProvisioner) spec(name string) (operator.ShardedDatabaseSpec, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	spec, ok := p.specs[name]
+	return spec, ok
+}
+
+func (p *recordingProvisioner) GetDatabase(name string) (*operator.ShardedDatabase, bool) {
+	return nil, false
+}
+func (p *recordingProvisioner) ListDatabases() []*operator.ShardedDatabase            { return nil }
+func (p *recordingProvisioner) DeleteDatabase(ctx context.Context, name string) error { return nil }
+func (p *recordingProvisioner) ScaleShards(ctx context.Context, name string, newCount int) error {
+	return nil
+}
+
+func newTemplateController(t *testing.T, registry *TemplateRegistry) (*Controller, *recordingProvisioner) {
+	t.Helper()
+	provisioner := &recordingProvisioner{specs: make(map[string]operator.ShardedDatabaseSpec)}
+	controller := NewController(zaptest.NewLogger(t), provisioner, nil, "default")
+	controller.readyPoll = time.Hour // Provisioning never completes in these tests
+	controller.SetTemplates(registry)
+	return controller, provisioner
+}
+
+func TestTemplateRegistry_CreateDatabaseFromCustomTemplate(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryTemplateStore()
+	registry := NewTemplateRegistry(store)
+
+	registered, err := registry.Register(ctx, DatabaseTemplate{
+		Name:        "analytics",
+		Description: "Wide shards for reporting",
+		ShardCount:  6,
+		Resources:   &ResourceConfig{CPU: "4000m", Memory: "16Gi"},
+		Storage:     &StorageConfig{SizePerShard: "500Gi", StorageClass: "ssd"},
+		Backup:      &BackupConfig{Enabled: true, Schedule: "0 2 * * *", Retention: 30},
+		Image:       &ImageConfig{Repository: "registry.example.com/postgres", Tag: "16.2-hardened", PullSecret: "registry-creds"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !registered.Custom || registered.VNodeCount != 256 {
+		t.Errorf("expected defaults to be filled in, got %+v", registered)
+	}
+
+	// Registered templates survive a restart, alongside the built-in ones
+	reloaded := NewTemplateRegistry(store)
+	if err := reloaded.Load(ctx); err != nil {
+		t.Fatalf("failed to load templates: %v", err)
+	}
+	names := make(map[string]bool)
+	for _, template := range reloaded.List() {
+		names[template.Name] = true
+	}
+	if !names["analytics"] || !names["starter"] || len(names) != 4 {
+		t.Errorf("expected the custom and built-in templates, got %v", names)
+	}
+
+	controller, provisioner := newTemplateController(t, reloaded)
+	db, err := controller.CreateDatabase(ctx, CreateDatabaseRequest{Name: "reports", Template: "analytics"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if db.Template != "analytics" || db.ShardCount != 6 {
+		t.Errorf("expected 6 shards from the analytics template, got %d from %q", db.ShardCount, db.Template)
+	}
+	if db.Config.Resources.CPU != "4000m" || db.Config.Storage.SizePerShard != "500Gi" || db.Config.Storage.StorageClass != "ssd" {
+		t.Errorf("expected the template's resources and storage, got %+v", db.Config)
+	}
+	if !db.Config.Backup.Enabled || db.Config.Backup.Schedule != "0 2 * * *" || db.Config.Backup.Retention != 30 {
+		t.Errorf("expected the template's backup defaults, got %+v", db.Config.Backup)
+	}
+
+	// The operator provisions the database with the template's settings
+	deadline := time.Now().Add(5 * time.Second)
+	spec, ok := provisioner.spec("reports")
+	for !ok && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		spec, ok = provisioner.spec("reports")
+	}
+	if !ok {
+		t.Fatal("expected the database to be provisioned")
+	}
+	if spec.ShardCount != 6 || spec.Resources.Memory != "16Gi" || spec.Storage.Size != "500Gi" {
+		t.Errorf("expected the template's settings to be provisioned, got %+v", spec)
+	}
+	if spec.Image.Repository != "registry.example.com/postgres" || spec.Image.Tag != "16.2-hardened" || spec.Image.PullSecret != "registry-creds" {
+		t.Errorf("expected the template's image to be provisioned, got %+v", spec.Image)
+	}
+
+	// Request settings still take precedence over the template
+	db, err = controller.CreateDatabase(ctx, CreateDatabaseRequest{Name: "small-reports", Template: "analytics", ShardCount: 2,
+		Resources: &ResourceConfig{CPU: "500m", Memory: "1Gi"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if db.ShardCount != 2 || db.Config.Resources.CPU != "500m" || db.Config.Storage.SizePerShard != "500Gi" {
+		t.Errorf("expected request overrides on top of the template, got %d shards and %+v", db.ShardCount, db.Config)
+	}
+}
+
+func TestTemplateRegistry_DefaultAndOverrides(t *testing.T) {
+	ctx := context.Background()
+	registry := NewTemplateRegistry(NewMemoryTemplateStore())
+
+	// A template named like a built-in one replaces it
+	if _, err := registry.Register(ctx, DatabaseTemplate{Name: "starter", Resources: &ResourceConfig{CPU: "100m", Memory: "256Mi"}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := registry.Register(ctx, DatabaseTemplate{Name: "tiny", ShardCount: 1}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	starter, _ := registry.Get("starter")
+	if !starter.Custom || starter.ShardCount != 2 {
+		t.Errorf("expected the overriding starter template to keep starter's shard count, got %+v", starter)
+	}
+	if templates := registry.List(); len(templates) != 4 {
+		t.Errorf("expected the override to replace the built-in template in the list, got %d templates", len(templates))
+	}
+
+	controller, _ := newTemplateController(t, registry)
+	db, err := controller.CreateDatabase(ctx, CreateDatabaseRequest{Name: "plain"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if db.Template != "starter" || db.Config.Resources.CPU != "100m" {
+		t.Errorf("expected the overridden starter template by default, got %q with %+v", db.Template, db.Config.Resources)
+	}
+
+	if err := registry.SetDefault("tiny"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if db, _ = controller.CreateDatabase(ctx, CreateDatabaseRequest{Name: "defaulted"}); db.Template != "tiny" || db.ShardCount != 1 {
+		t.Errorf("expected the configured default template, got %d shards from %q", db.ShardCount, db.Template)
+	}
+
+	if err := registry.SetDefault("missing"); !errors.Is(err, ErrTemplateNotFound) {
+		t.Errorf("expected an unknown default to be refused, got %v", err)
+	}
+	if _, err := controller.CreateDatabase(ctx, CreateDatabaseRequest{Name: "unknown", Template: "missing"}); !errors.Is(err, ErrTemplateNotFound) {
+		t.Errorf("expected an unknown template to be refused, got %v", err)
+	}
+	if _, err := registry.Register(ctx, DatabaseTemplate{Name: "Bad Name"}); !errors.Is(err, ErrInvalidTemplate) {
+		t.Errorf("expected an invalid name to be refused, got %v", err)
+	}
+	if _, err := registry.Register(ctx, DatabaseTemplate{Name: "newest", Image: &ImageConfig{Repository: "postgres", Tag: "17-alpine"}}); !errors.Is(err, ErrInvalidTemplate) {
+		t.Errorf("expected an unsupported PostgreSQL version to be refused, got %v", err)
+	}
+}
@@ -0,0 +1,136 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultCacheTTL is how long a discovery scan is reused before the
+	// cluster is scanned again
+	DefaultCacheTTL = 30 * time.Second
+
+	// minScanBackoff and maxScanBackoff bound how long scans are held off
+	// after consecutive failures
+	minScanBackoff = time.Second
+	maxScanBackoff = 2 * time.Minute
+)
+
+// ScanFunc runs one discovery scan
+type ScanFunc func(ctx context.Context) ([]DiscoveredApp, error)
+
+// Cache reuses discovery results for a short TTL and shares one scan between
+// concurrent callers, so a burst of requests does not list every namespace of
+// the cluster once per request. Scans that keep failing, e.g. because the
+// Kubernetes API is unreachable, are retried with exponential backoff.
+type Cache struct {
+	scan ScanFunc
+	ttl  time.Duration
+	now  func() time.Time
+
+	mu       sync.Mutex
+	apps     []DiscoveredApp
+	scanned  time.Time // When apps was scanned; zero if there is no result
+	inflight *scanCall
+	err      error         // Error of the last scan, nil if it succeeded
+	backoff  time.Duration // Hold-off after the next failure
+	retryAt  time.Time     // No scan is started before this time after a failure
+}
+
+// scanCall is a scan in progress that concurrent callers wait on
+type scanCall struct {
+	done chan struct{}
+	apps []DiscoveredApp
+	err  error
+}
+
+// NewCache creates a discovery cache that runs scan when its result is older
+// than ttl. A ttl of zero or less uses DefaultCacheTTL.
+func NewCache(scan ScanFunc, ttl time.Duration) *Cache {
+	if ttl <= 0 {
+		ttl = DefaultCacheTTL
+	}
+	return &Cache{
+		scan:    scan,
+		ttl:     ttl,
+		now:     time.Now,
+		backoff: minScanBackoff,
+	}
+}
+
+// Get returns the discovered applications. The last result is returned while
+// it is younger than the TTL; refresh skips it and scans again. Callers that
+// arrive while a scan is running wait for that scan instead of starting their
+// own. After a failed scan, no new scan starts until the backoff has passed,
+// even on refresh: the previous result is returned if there is one, and the
+// error otherwise.
+func (c *Cache) Get(ctx context.Context, refresh bool) ([]DiscoveredApp, error) {
+	c.mu.Lock()
+	call := c.inflight
+	if call == nil {
+		now := c.now()
+		switch {
+		case c.err != nil && now.Before(c.retryAt):
+			apps, scanned, err, retryAt := c.apps, c.scanned, c.err, c.retryAt
+			c.mu.Unlock()
+			if scanned.IsZero() {
+				return nil, fmt.Errorf("discovery retry held off until %s: %w", retryAt.Format(time.RFC3339), err)
+			}
+			return copyApps(apps), nil
+		case !refresh && !c.scanned.IsZero() && now.Sub(c.scanned) < c.ttl:
+			apps := c.apps
+			c.mu.Unlock()
+			return copyApps(apps), nil
+		}
+		call = &scanCall{done: make(chan struct{})}
+		c.inflight = call
+		// The scan outlives a caller that gives up, since others may share it
+		go c.run(context.WithoutCancel(ctx), call)
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-call.done:
+		if call.err != nil {
+			return nil, call.err
+		}
+		return copyApps(call.apps), nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// run scans and records the result for call and the cache
+func (c *Cache) run(ctx context.Context, call *scanCall) {
+	apps, err := c.scan(ctx)
+
+	c.mu.Lock()
+	if err != nil {
+		c.err = err
+		c.retryAt = c.now().Add(c.backoff)
+		c.backoff *= 2
+		if c.backoff > maxScanBackoff {
+			c.backoff = maxScanBackoff
+		}
+	} else {
+		c.apps = apps
+		c.scanned = c.now()
+		c.err = nil
+		c.retryAt = time.Time{}
+		c.backoff = minScanBackoff
+	}
+	c.inflight = nil
+	c.mu.Unlock()
+
+	call.apps, call.err = apps, err
+	close(call.done)
+}
+
+// copyApps returns a copy of apps that callers may modify, never nil
+func copyApps(apps []DiscoveredApp) []DiscoveredApp {
+	copied := make([]DiscoveredApp, len(apps))
+	copy(copied, apps)
+	return copied
+}
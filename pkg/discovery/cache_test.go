@@ -0,0 +1,191 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingScan is a ScanFunc that counts its scans and can be held until
+// released
+type countingScan struct {
+	scans   atomic.Int32
+	started chan struct{}
+	release chan struct{}
+	err     error
+}
+
+func (s *countingScan) scan(ctx context.Context) ([]DiscoveredApp, error) {
+	n := s.scans.Add(1)
+	if s.started != nil {
+		s.started <- struct{}{}
+	}
+	if s.release != nil {
+		<-s.release
+	}
+	if s.err != nil {
+		return nil, s.err
+	}
+	return []DiscoveredApp{{Namespace: "shop", Name: "orders", DatabaseName: "orders", IsRegistered: n > 1}}, nil
+}
+
+// newTestCache creates a cache with a clock the test moves by hand
+func newTestCache(scan ScanFunc, ttl time.Duration) (*Cache, *time.Time) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	c := NewCache(scan, ttl)
+	c.now = func() time.Time { return now }
+	return c, &now
+}
+
+func TestCache_ConcurrentRequestsShareOneScan(t *testing.T) {
+	s := &countingScan{started: make(chan struct{}, 1), release: make(chan struct{})}
+	c, _ := newTestCache(s.scan, time.Minute)
+
+	const callers = 10
+	var wg sync.WaitGroup
+	results := make([][]DiscoveredApp, callers)
+	errs := make([]error, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], errs[i] = c.Get(context.Background(), false)
+		}(i)
+	}
+
+	<-s.started
+	// Give the other callers time to join the running scan
+	time.Sleep(50 * time.Millisecond)
+	close(s.release)
+	wg.Wait()
+
+	if got := s.scans.Load(); got != 1 {
+		t.Fatalf("scans = %d, want 1", got)
+	}
+	for i := 0; i < callers; i++ {
+		if errs[i] != nil {
+			t.Fatalf("caller %d: %v", i, errs[i])
+		}
+		if len(results[i]) != 1 || results[i][0].Name != "orders" {
+			t.Fatalf("caller %d got %+v", i, results[i])
+		}
+	}
+}
+
+func TestCache_ReusesResultWithinTTL(t *testing.T) {
+	s := &countingScan{}
+	c, now := newTestCache(s.scan, 30*time.Second)
+	ctx := context.Background()
+
+	if _, err := c.Get(ctx, false); err != nil {
+		t.Fatal(err)
+	}
+	*now = now.Add(29 * time.Second)
+	apps, err := c.Get(ctx, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := s.scans.Load(); got != 1 {
+		t.Fatalf("scans within TTL = %d, want 1", got)
+	}
+	if apps[0].IsRegistered {
+		t.Error("expected the cached result of the first scan")
+	}
+
+	// Callers may modify what they get without touching the cache
+	apps[0].Name = "changed"
+	if apps, _ := c.Get(ctx, false); apps[0].Name != "orders" {
+		t.Errorf("cached result was modified: %+v", apps[0])
+	}
+
+	*now = now.Add(2 * time.Second)
+	if _, err := c.Get(ctx, false); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.scans.Load(); got != 2 {
+		t.Fatalf("scans after TTL = %d, want 2", got)
+	}
+}
+
+func TestCache_RefreshScansAgain(t *testing.T) {
+	s := &countingScan{}
+	c, _ := newTestCache(s.scan, time.Minute)
+	ctx := context.Background()
+
+	c.Get(ctx, false)
+	apps, err := c.Get(ctx, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := s.scans.Load(); got != 2 {
+		t.Fatalf("scans = %d, want 2", got)
+	}
+	if !apps[0].IsRegistered {
+		t.Error("expected the result of the refreshed scan")
+	}
+}
+
+func TestCache_BacksOffAfterFailures(t *testing.T) {
+	s := &countingScan{err: errors.New("connection refused")}
+	c, now := newTestCache(s.scan, time.Minute)
+	ctx := context.Background()
+
+	if _, err := c.Get(ctx, false); err == nil {
+		t.Fatal("expected the scan error")
+	}
+
+	// Held off for a second, even when a refresh is asked for
+	*now = now.Add(500 * time.Millisecond)
+	if _, err := c.Get(ctx, true); err == nil || !errors.Is(err, s.err) {
+		t.Fatalf("expected the held-off scan error, got %v", err)
+	}
+	if got := s.scans.Load(); got != 1 {
+		t.Fatalf("scans during backoff = %d, want 1", got)
+	}
+
+	// The second failure doubles the backoff to two seconds
+	*now = now.Add(time.Second)
+	c.Get(ctx, false)
+	*now = now.Add(1500 * time.Millisecond)
+	c.Get(ctx, false)
+	if got := s.scans.Load(); got != 2 {
+		t.Fatalf("scans after first backoff = %d, want 2", got)
+	}
+
+	// A successful scan resets the backoff
+	*now = now.Add(time.Second)
+	s.err = nil
+	if _, err := c.Get(ctx, false); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.scans.Load(); got != 3 {
+		t.Fatalf("scans after second backoff = %d, want 3", got)
+	}
+	if c.backoff != minScanBackoff {
+		t.Errorf("backoff = %s, want %s", c.backoff, minScanBackoff)
+	}
+}
+
+func TestCache_ServesLastResultDuringBackoff(t *testing.T) {
+	s := &countingScan{}
+	c, now := newTestCache(s.scan, time.Second)
+	ctx := context.Background()
+
+	c.Get(ctx, false)
+	s.err = errors.New("connection refused")
+	*now = now.Add(2 * time.Second)
+	if _, err := c.Get(ctx, false); err == nil {
+		t.Fatal("expected the scan error")
+	}
+
+	apps, err := c.Get(ctx, false)
+	if err != nil {
+		t.Fatalf("expected the last result during backoff, got %v", err)
+	}
+	if len(apps) != 1 || apps[0].Name != "orders" {
+		t.Errorf("got %+v", apps)
+	}
+}
@@ -0,0 +1,65 @@
+package discovery
+
+import (
+	"fmt"
+
+	"go.uber.org/zap"
+)
+
+// Filter modes for discovered apps
+const (
+	FilterStrict  = "strict"  // Keep only apps with a database URL, or a host and database name
+	FilterLenient = "lenient" // Also keep partially-configured apps, marked as needing database info
+)
+
+// HasDatabaseInfo reports whether an app's database can be reached from what
+// was discovered: a URL, or a host and a database name
+func (a *DiscoveredApp) HasDatabaseInfo() bool {
+	return a.DatabaseURL != "" || (a.DatabaseHost != "" && a.DatabaseName != "")
+}
+
+// hasPartialDatabaseInfo reports whether an app exposes some of its database
+// configuration, e.g. only a host with the database name left to convention
+func (a *DiscoveredApp) hasPartialDatabaseInfo() bool {
+	return a.DatabaseHost != "" || a.DatabaseName != ""
+}
+
+// Filter drops discovered apps that cannot be sharded for lack of database
+// information
+type Filter struct {
+	mode   string
+	logger *zap.Logger
+}
+
+// NewFilter creates a filter with the given mode
+func NewFilter(mode string, logger *zap.Logger) (*Filter, error) {
+	switch mode {
+	case FilterStrict, FilterLenient:
+	default:
+		return nil, fmt.Errorf("invalid discovery filter %q: must be strict or lenient", mode)
+	}
+	return &Filter{mode: mode, logger: logger}, nil
+}
+
+// Apply returns the apps to surface. Apps with full database information are
+// always kept and apps without any are always dropped. Under the lenient
+// mode, partially-configured apps are kept with NeedsDatabaseInfo set so the
+// missing details can be entered by hand; under strict they are dropped.
+func (f *Filter) Apply(apps []DiscoveredApp) []DiscoveredApp {
+	filtered := make([]DiscoveredApp, 0, len(apps))
+	for _, app := range apps {
+		app.NeedsDatabaseInfo = false
+		switch {
+		case app.HasDatabaseInfo():
+		case f.mode == FilterLenient && app.hasPartialDatabaseInfo():
+			app.NeedsDatabaseInfo = true
+		default:
+			f.logger.Debug("filtering out discovered app with insufficient database information",
+				zap.String("app_name", app.Name),
+				zap.String("namespace", app.Namespace))
+			continue
+		}
+		filtered = append(filtered, app)
+	}
+	return filtered
+}
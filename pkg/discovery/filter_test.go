@@ -0,0 +1,70 @@
+package discovery
+
+import (
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+// filterTestApps returns apps with full, partial and no database configuration
+func filterTestApps() []DiscoveredApp {
+	return []DiscoveredApp{
+		{Name: "orders", DatabaseHost: "orders-db", DatabaseName: "orders"},
+		{Name: "billing", DatabaseURL: "postgres://billing-db:5432/billing"},
+		{Name: "users", DatabaseHost: "users-db"},
+		{Name: "reports", DatabaseName: "reports"},
+		{Name: "frontend"},
+	}
+}
+
+// filteredNames returns the names of the apps kept by a filter and whether
+// each needs database info
+func filteredNames(t *testing.T, mode string) map[string]bool {
+	t.Helper()
+	filter, err := NewFilter(mode, zap.NewNop())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	names := make(map[string]bool)
+	for _, app := range filter.Apply(filterTestApps()) {
+		names[app.Name] = app.NeedsDatabaseInfo
+	}
+	return names
+}
+
+func TestFilter_Strict(t *testing.T) {
+	names := filteredNames(t, FilterStrict)
+
+	if len(names) != 2 {
+		t.Fatalf("expected only fully-configured apps, got %v", names)
+	}
+	for _, name := range []string{"orders", "billing"} {
+		if needsInfo, ok := names[name]; !ok || needsInfo {
+			t.Errorf("expected %s to be kept as complete, got %v", name, names)
+		}
+	}
+}
+
+func TestFilter_Lenient(t *testing.T) {
+	names := filteredNames(t, FilterLenient)
+
+	want := map[string]bool{"orders": false, "billing": false, "users": true, "reports": true}
+	if len(names) != len(want) {
+		t.Fatalf("expected %v, got %v", want, names)
+	}
+	for name, needsInfo := range want {
+		if got, ok := names[name]; !ok || got != needsInfo {
+			t.Errorf("expected %s kept with needs_database_info %v, got %v", name, needsInfo, names)
+		}
+	}
+	// Apps without any database configuration don't use one
+	if _, ok := names["frontend"]; ok {
+		t.Error("expected apps without database configuration to be dropped")
+	}
+}
+
+func TestNewFilter_InvalidMode(t *testing.T) {
+	if _, err := NewFilter("loose", zap.NewNop()); err == nil {
+		t.Error("expected an unknown filter mode to be refused")
+	}
+}
@@ -17,24 +17,28 @@ import (
 
 // DiscoveredApp represents an application discovered in Kubernetes
 type DiscoveredApp struct {
-	Namespace    string            `json:"namespace"`
-	Name         string            `json:"name"`
-	Type         string            `json:"type"` // "deployment", "statefulset", "pod"
-	DatabaseName string            `json:"database_name"`
-	DatabaseURL  string            `json:"database_url,omitempty"`
-	DatabaseHost string            `json:"database_host,omitempty"`
-	DatabasePort string            `json:"database_port,omitempty"`
-	DatabaseUser string            `json:"database_user,omitempty"`
-	Labels       map[string]string `json:"labels"`
-	Annotations  map[string]string `json:"annotations"`
-	IsRegistered bool              `json:"is_registered"` // Whether already registered as client app
+	Namespace         string            `json:"namespace"`
+	Name              string            `json:"name"`
+	Type              string            `json:"type"` // "deployment", "statefulset", "pod"
+	DatabaseName      string            `json:"database_name"`
+	DatabaseURL       string            `json:"database_url,omitempty"`
+	DatabaseHost      string            `json:"database_host,omitempty"`
+	DatabasePort      string            `json:"database_port,omitempty"`
+	DatabaseUser      string            `json:"database_user,omitempty"`
+	Operator          string            `json:"operator,omitempty"`           // Operator or chart that deployed the database, e.g. "cloudnativepg"
+	CredentialsSecret string            `json:"credentials_secret,omitempty"` // Secret holding the database password
+	Labels            map[string]string `json:"labels"`
+	Annotations       map[string]string `json:"annotations"`
+	IsRegistered      bool              `json:"is_registered"`                 // Whether already registered as client app
+	NeedsDatabaseInfo bool              `json:"needs_database_info,omitempty"` // Database info is incomplete and must be entered manually
 }
 
 // KubernetesDiscovery discovers applications and databases in Kubernetes clusters
 type KubernetesDiscovery struct {
-	client         *kubernetes.Clientset
+	client         kubernetes.Interface
 	logger         *zap.Logger
 	registeredApps map[string]bool // Track registered app names
+	detectors      []DatabaseDetector
 }
 
 // NewKubernetesDiscovery creates a new Kubernetes discovery service
@@ -66,11 +70,12 @@ func NewKubernetesDiscovery(logger *zap.Logger, registeredAppNames []string) (*K
 		client:         clientset,
 		logger:         logger,
 		registeredApps: registeredMap,
+		detectors:      DefaultDetectors(),
 	}, nil
 }
 
 // NewKubernetesDiscoveryFromClient creates a new Kubernetes discovery service from an existing client
-func NewKubernetesDiscoveryFromClient(client *kubernetes.Clientset, logger *zap.Logger, registeredAppNames []string) (*KubernetesDiscovery, error) {
+func NewKubernetesDiscoveryFromClient(client kubernetes.Interface, logger *zap.Logger, registeredAppNames []string) (*KubernetesDiscovery, error) {
 	registeredMap := make(map[string]bool)
 	for _, name := range registeredAppNames {
 		registeredMap[name] = true
@@ -80,6 +85,7 @@ func NewKubernetesDiscoveryFromClient(client *kubernetes.Clientset, logger *zap.
 		client:         client,
 		logger:         logger,
 		registeredApps: registeredMap,
+		detectors:      DefaultDetectors(),
 	}, nil
 }
 
@@ -108,6 +114,9 @@ func (k *KubernetesDiscovery) DiscoverApplications(ctx context.Context) ([]Disco
 		}
 
 		for _, deployment := range deployments.Items {
+			if k.managedByDetector(deployment.Labels) {
+				continue
+			}
 			app := k.discoverFromDeployment(ctx, &deployment)
 			if app != nil {
 				discoveredApps = append(discoveredApps, *app)
@@ -122,11 +131,30 @@ func (k *KubernetesDiscovery) DiscoverApplications(ctx context.Context) ([]Disco
 		}
 
 		for _, sts := range statefulSets.Items {
+			if k.managedByDetector(sts.Labels) {
+				continue
+			}
 			app := k.discoverFromStatefulSet(ctx, &sts)
 			if app != nil {
 				discoveredApps = append(discoveredApps, *app)
 			}
 		}
+
+		// Discover databases deployed by operators and Helm charts
+		for _, detector := range k.detectors {
+			apps, err := detector.Detect(ctx, k.client, ns.Name)
+			if err != nil {
+				k.logger.Warn("database detector failed",
+					zap.String("detector", detector.Name()),
+					zap.String("namespace", ns.Name),
+					zap.Error(err))
+				continue
+			}
+			for _, app := range apps {
+				app.IsRegistered = k.registeredApps[app.Name]
+				discoveredApps = append(discoveredApps, app)
+			}
+		}
 	}
 
 	// Ensure we always return a non-nil slice
@@ -137,6 +165,16 @@ func (k *KubernetesDiscovery) DiscoverApplications(ctx context.Context) ([]Disco
 	return discoveredApps, nil
 }
 
+// managedByDetector reports whether a workload is a database server reported by a detector
+func (k *KubernetesDiscovery) managedByDetector(labels map[string]string) bool {
+	for _, detector := range k.detectors {
+		if detector.Manages(labels) {
+			return true
+		}
+	}
+	return false
+}
+
 // discoverFromDeployment extracts application and database info from a deployment
 func (k *KubernetesDiscovery) discoverFromDeployment(ctx context.Context, deployment *appsv1.Deployment) *DiscoveredApp {
 	app := &DiscoveredApp{
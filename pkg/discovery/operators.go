@@ -0,0 +1,248 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// DatabaseDetector recognises PostgreSQL databases deployed by an operator or
+// Helm chart from the services and secrets it generates
+type DatabaseDetector interface {
+	// Name identifies the operator or chart, e.g. "cloudnativepg"
+	Name() string
+	// Detect returns the databases the detector finds in a namespace
+	Detect(ctx context.Context, client kubernetes.Interface, namespace string) ([]DiscoveredApp, error)
+	// Manages reports whether a workload with these labels runs a database
+	// server the detector reports, so it is not also discovered as an app
+	Manages(labels map[string]string) bool
+}
+
+// DefaultDetectors returns the detectors for the supported operators and charts
+func DefaultDetectors() []DatabaseDetector {
+	return []DatabaseDetector{
+		CloudNativePGDetector{},
+		ZalandoDetector{},
+		BitnamiDetector{},
+	}
+}
+
+// CloudNativePGDetector detects CloudNativePG clusters. The operator creates
+// a <cluster>-rw service for the primary and a <cluster>-app basic-auth
+// secret holding the application user's credentials.
+type CloudNativePGDetector struct{}
+
+// Name implements DatabaseDetector
+func (CloudNativePGDetector) Name() string { return "cloudnativepg" }
+
+// Manages implements DatabaseDetector
+func (CloudNativePGDetector) Manages(labels map[string]string) bool {
+	return labels["cnpg.io/cluster"] != ""
+}
+
+// Detect implements DatabaseDetector
+func (d CloudNativePGDetector) Detect(ctx context.Context, client kubernetes.Interface, namespace string) ([]DiscoveredApp, error) {
+	services, err := client.CoreV1().Services(namespace).List(ctx, metav1.ListOptions{LabelSelector: "cnpg.io/cluster"})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list CloudNativePG services: %w", err)
+	}
+
+	apps := make([]DiscoveredApp, 0)
+	for i := range services.Items {
+		svc := &services.Items[i]
+		cluster := svc.Labels["cnpg.io/cluster"]
+		if svc.Name != cluster+"-rw" {
+			continue // Only report the primary, not the -ro and -r services
+		}
+
+		app := newOperatorApp(d.Name(), cluster, svc)
+		app.DatabaseName, app.DatabaseUser = "app", "app" // CloudNativePG bootstrap defaults
+		secretName := cluster + "-app"
+		secret, err := getSecret(ctx, client, namespace, secretName)
+		if err != nil {
+			return nil, err
+		}
+		if secret != nil {
+			app.CredentialsSecret = secretName
+			setIfPresent(&app.DatabaseName, secret.Data["dbname"])
+			setIfPresent(&app.DatabaseUser, secret.Data["username"])
+		}
+		apps = append(apps, app)
+	}
+	return apps, nil
+}
+
+// ZalandoDetector detects clusters run by Zalando's postgres-operator. The
+// master service is named after the cluster and labelled spilo-role=master;
+// credentials live in <user>.<cluster>.credentials.postgresql.acid.zalan.do.
+type ZalandoDetector struct{}
+
+// Name implements DatabaseDetector
+func (ZalandoDetector) Name() string { return "zalando" }
+
+// Manages implements DatabaseDetector
+func (ZalandoDetector) Manages(labels map[string]string) bool {
+	return labels["application"] == "spilo"
+}
+
+// Detect implements DatabaseDetector
+func (d ZalandoDetector) Detect(ctx context.Context, client kubernetes.Interface, namespace string) ([]DiscoveredApp, error) {
+	services, err := client.CoreV1().Services(namespace).List(ctx, metav1.ListOptions{LabelSelector: "application=spilo,spilo-role=master"})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list postgres-operator services: %w", err)
+	}
+
+	apps := make([]DiscoveredApp, 0)
+	for i := range services.Items {
+		svc := &services.Items[i]
+		cluster := svc.Labels["cluster-name"]
+		if cluster == "" || svc.Name != cluster {
+			continue // Skip the -repl and -config services
+		}
+
+		app := newOperatorApp(d.Name(), cluster, svc)
+		app.DatabaseName, app.DatabaseUser = "postgres", "postgres"
+
+		// Prefer an application user over the superuser and replication users
+		secrets, err := client.CoreV1().Secrets(namespace).List(ctx, metav1.ListOptions{LabelSelector: "application=spilo,cluster-name=" + cluster})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list postgres-operator secrets: %w", err)
+		}
+		suffix := "." + cluster + ".credentials.postgresql.acid.zalan.do"
+		for _, secret := range secrets.Items {
+			user := strings.TrimSuffix(secret.Name, suffix)
+			if user == secret.Name {
+				continue
+			}
+			if app.CredentialsSecret == "" || (user != "postgres" && user != "standby") {
+				app.CredentialsSecret = secret.Name
+				app.DatabaseUser = user
+				setIfPresent(&app.DatabaseUser, secret.Data["username"])
+			}
+		}
+		apps = append(apps, app)
+	}
+	return apps, nil
+}
+
+// BitnamiDetector detects the Bitnami PostgreSQL Helm chart. The chart labels
+// its services with app.kubernetes.io/name=postgresql and stores passwords in
+// a secret named after the primary service.
+type BitnamiDetector struct{}
+
+// Name implements DatabaseDetector
+func (BitnamiDetector) Name() string { return "bitnami" }
+
+// Manages implements DatabaseDetector
+func (BitnamiDetector) Manages(labels map[string]string) bool {
+	return labels["app.kubernetes.io/name"] == "postgresql" && strings.HasPrefix(labels["helm.sh/chart"], "postgresql")
+}
+
+// Detect implements DatabaseDetector
+func (d BitnamiDetector) Detect(ctx context.Context, client kubernetes.Interface, namespace string) ([]DiscoveredApp, error) {
+	services, err := client.CoreV1().Services(namespace).List(ctx, metav1.ListOptions{LabelSelector: "app.kubernetes.io/name=postgresql,helm.sh/chart"})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list Bitnami PostgreSQL services: %w", err)
+	}
+
+	apps := make([]DiscoveredApp, 0)
+	for i := range services.Items {
+		svc := &services.Items[i]
+		if !strings.HasPrefix(svc.Labels["helm.sh/chart"], "postgresql") {
+			continue
+		}
+		// Skip the headless and read replica services
+		component := svc.Labels["app.kubernetes.io/component"]
+		if svc.Spec.ClusterIP == corev1.ClusterIPNone || strings.HasSuffix(svc.Name, "-hl") || (component != "" && component != "primary") {
+			continue
+		}
+
+		release := svc.Labels["app.kubernetes.io/instance"]
+		if release == "" {
+			release = svc.Name
+		}
+		app := newOperatorApp(d.Name(), release, svc)
+		app.DatabaseName, app.DatabaseUser = "postgres", "postgres"
+
+		// The chart passes the custom user and database to the server's environment
+		statefulSets, err := client.AppsV1().StatefulSets(namespace).List(ctx, metav1.ListOptions{LabelSelector: "app.kubernetes.io/instance=" + release + ",app.kubernetes.io/name=postgresql"})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list Bitnami PostgreSQL statefulsets: %w", err)
+		}
+		for _, sts := range statefulSets.Items {
+			for _, container := range sts.Spec.Template.Spec.Containers {
+				for _, env := range container.Env {
+					switch env.Name {
+					case "POSTGRES_USER", "POSTGRESQL_USERNAME":
+						setIfPresent(&app.DatabaseUser, []byte(env.Value))
+					case "POSTGRES_DATABASE", "POSTGRES_DB", "POSTGRESQL_DATABASE":
+						setIfPresent(&app.DatabaseName, []byte(env.Value))
+					}
+				}
+			}
+		}
+
+		secret, err := getSecret(ctx, client, namespace, svc.Name)
+		if err != nil {
+			return nil, err
+		}
+		if secret != nil {
+			app.CredentialsSecret = svc.Name
+		}
+		apps = append(apps, app)
+	}
+	return apps, nil
+}
+
+// newOperatorApp describes a database reached through a service
+func newOperatorApp(operator, name string, svc *corev1.Service) DiscoveredApp {
+	return DiscoveredApp{
+		Namespace:    svc.Namespace,
+		Name:         name,
+		Type:         "database",
+		Operator:     operator,
+		DatabaseHost: fmt.Sprintf("%s.%s.svc", svc.Name, svc.Namespace),
+		DatabasePort: strconv.Itoa(int(postgresPort(svc))),
+		Labels:       svc.Labels,
+		Annotations:  svc.Annotations,
+	}
+}
+
+// postgresPort returns the service's PostgreSQL port
+func postgresPort(svc *corev1.Service) int32 {
+	for _, port := range svc.Spec.Ports {
+		switch port.Name {
+		case "postgres", "postgresql", "tcp-postgresql":
+			return port.Port
+		}
+	}
+	if len(svc.Spec.Ports) > 0 {
+		return svc.Spec.Ports[0].Port
+	}
+	return 5432
+}
+
+// getSecret returns a secret, or nil when it does not exist
+func getSecret(ctx context.Context, client kubernetes.Interface, namespace, name string) (*corev1.Secret, error) {
+	secret, err := client.CoreV1().Secrets(namespace).Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get secret %s/%s: %w", namespace, name, err)
+	}
+	return secret, nil
+}
+
+// setIfPresent overwrites dst with a non-empty value
+func setIfPresent(dst *string, value []byte) {
+	if len(value) > 0 {
+		*dst = string(value)
+	}
+}
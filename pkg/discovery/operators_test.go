@@ -0,0 +1,167 @@
+package discovery
+
+import (
+	"context"
+	"testing"
+
+	"go.uber.org/zap/zaptest"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func service(namespace, name string, labels map[string]string, port int32) *corev1.Service {
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: labels},
+		Spec:       corev1.ServiceSpec{Ports: []corev1.ServicePort{{Name: "postgres", Port: port}}},
+	}
+}
+
+func secret(namespace, name string, labels map[string]string, data map[string]string) *corev1.Secret {
+	s := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: labels}, Data: map[string][]byte{}}
+	for k, v := range data {
+		s.Data[k] = []byte(v)
+	}
+	return s
+}
+
+func cloudNativePGObjects() []runtime.Object {
+	labels := map[string]string{"cnpg.io/cluster": "orders-db"}
+	return []runtime.Object{
+		service("shop", "orders-db-rw", labels, 5432),
+		service("shop", "orders-db-ro", labels, 5432),
+		service("shop", "orders-db-r", labels, 5432),
+		secret("shop", "orders-db-app", labels, map[string]string{"username": "orders", "password": "s3cret", "dbname": "orders"}),
+	}
+}
+
+func bitnamiObjects() []runtime.Object {
+	labels := func(component string) map[string]string {
+		return map[string]string{
+			"app.kubernetes.io/name":      "postgresql",
+			"app.kubernetes.io/instance":  "billing",
+			"app.kubernetes.io/component": component,
+			"helm.sh/chart":               "postgresql-12.5.6",
+		}
+	}
+	headless := service("payments", "billing-postgresql-hl", labels("primary"), 5432)
+	headless.Spec.ClusterIP = corev1.ClusterIPNone
+	sts := &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "billing-postgresql", Namespace: "payments", Labels: labels("primary")},
+		Spec: appsv1.StatefulSetSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{Containers: []corev1.Container{{
+			Name: "postgresql",
+			Env: []corev1.EnvVar{
+				{Name: "POSTGRES_USER", Value: "billing"},
+				{Name: "POSTGRES_DATABASE", Value: "invoices"},
+			},
+		}}}}},
+	}
+	return []runtime.Object{
+		service("payments", "billing-postgresql", labels("primary"), 5432),
+		service("payments", "billing-postgresql-read", labels("read"), 5432),
+		headless,
+		sts,
+		secret("payments", "billing-postgresql", nil, map[string]string{"postgres-password": "root", "password": "s3cret"}),
+	}
+}
+
+func TestCloudNativePGDetector(t *testing.T) {
+	client := fake.NewSimpleClientset(cloudNativePGObjects()...)
+
+	apps, err := CloudNativePGDetector{}.Detect(context.Background(), client, "shop")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(apps) != 1 {
+		t.Fatalf("expected only the primary service to be reported, got %+v", apps)
+	}
+
+	app := apps[0]
+	if app.Name != "orders-db" || app.Operator != "cloudnativepg" || app.Type != "database" {
+		t.Errorf("unexpected app %+v", app)
+	}
+	if app.DatabaseHost != "orders-db-rw.shop.svc" || app.DatabasePort != "5432" {
+		t.Errorf("expected the read-write service, got %s:%s", app.DatabaseHost, app.DatabasePort)
+	}
+	if app.DatabaseName != "orders" || app.DatabaseUser != "orders" || app.CredentialsSecret != "orders-db-app" {
+		t.Errorf("expected credentials from the app secret, got %+v", app)
+	}
+}
+
+func TestBitnamiDetector(t *testing.T) {
+	client := fake.NewSimpleClientset(bitnamiObjects()...)
+
+	apps, err := BitnamiDetector{}.Detect(context.Background(), client, "payments")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(apps) != 1 {
+		t.Fatalf("expected only the primary service to be reported, got %+v", apps)
+	}
+
+	app := apps[0]
+	if app.Name != "billing" || app.Operator != "bitnami" {
+		t.Errorf("unexpected app %+v", app)
+	}
+	if app.DatabaseHost != "billing-postgresql.payments.svc" || app.DatabaseName != "invoices" || app.DatabaseUser != "billing" {
+		t.Errorf("expected connection info from the chart, got %+v", app)
+	}
+	if app.CredentialsSecret != "billing-postgresql" {
+		t.Errorf("expected the chart secret, got %q", app.CredentialsSecret)
+	}
+}
+
+func TestZalandoDetector(t *testing.T) {
+	labels := map[string]string{"application": "spilo", "cluster-name": "acid-users"}
+	master := map[string]string{"application": "spilo", "cluster-name": "acid-users", "spilo-role": "master"}
+	client := fake.NewSimpleClientset(
+		service("auth", "acid-users", master, 5432),
+		service("auth", "acid-users-repl", map[string]string{"application": "spilo", "cluster-name": "acid-users", "spilo-role": "replica"}, 5432),
+		secret("auth", "postgres.acid-users.credentials.postgresql.acid.zalan.do", labels, map[string]string{"username": "postgres"}),
+		secret("auth", "accounts.acid-users.credentials.postgresql.acid.zalan.do", labels, map[string]string{"username": "accounts"}),
+	)
+
+	apps, err := ZalandoDetector{}.Detect(context.Background(), client, "auth")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(apps) != 1 || apps[0].DatabaseHost != "acid-users.auth.svc" {
+		t.Fatalf("expected the master service, got %+v", apps)
+	}
+	if apps[0].DatabaseUser != "accounts" || apps[0].CredentialsSecret != "accounts.acid-users.credentials.postgresql.acid.zalan.do" {
+		t.Errorf("expected the application user over the superuser, got %+v", apps[0])
+	}
+}
+
+func TestKubernetesDiscovery_IncludesOperatorDatabases(t *testing.T) {
+	objects := append(cloudNativePGObjects(), bitnamiObjects()...)
+	objects = append(objects,
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "shop"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "payments"}},
+	)
+	disc, err := NewKubernetesDiscoveryFromClient(fake.NewSimpleClientset(objects...), zaptest.NewLogger(t), []string{"billing"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	apps, err := disc.DiscoverApplications(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	byName := make(map[string]DiscoveredApp)
+	for _, app := range apps {
+		byName[app.Name] = app
+	}
+	if len(apps) != 2 {
+		t.Fatalf("expected the two databases without the Bitnami statefulset, got %+v", apps)
+	}
+	if byName["orders-db"].Operator != "cloudnativepg" || byName["billing"].Operator != "bitnami" {
+		t.Errorf("unexpected apps %+v", byName)
+	}
+	if !byName["billing"].IsRegistered {
+		t.Error("expected billing to be marked as registered")
+	}
+}
@@ -6,48 +6,83 @@ import (
 	"sync"
 	"time"
 
+	"github.com/sharding-system/pkg/catalog"
 	"github.com/sharding-system/pkg/health"
 	"github.com/sharding-system/pkg/manager"
+	"github.com/sharding-system/pkg/models"
 	"go.uber.org/zap"
 )
 
 // FailoverController manages automatic failover operations
 type FailoverController struct {
-	manager      *manager.Manager
-	healthCtrl   *health.Controller
-	logger       *zap.Logger
-	checkInterval time.Duration
-	enabled      bool
-	mu           sync.RWMutex
-	running      bool
-	stopCh       chan struct{}
+	manager         *manager.Manager
+	healthCtrl      *health.Controller
+	logger          *zap.Logger
+	checkInterval   time.Duration
+	enabled         bool
+	mu              sync.RWMutex
+	running         bool
+	stopCh          chan struct{}
 	failoverHistory []*FailoverEvent
+	zones           ZoneResolver
+	detector        *failureDetector
+	switchover      Switchover           // Carries out planned failovers
+	failingOver     map[string]string    // Type of the failover running on each shard
+	drains          DrainDetector        // Finds primaries on nodes being drained
+	drainAttempts   map[string]time.Time // When a drain failover of each shard was last attempted
+}
+
+// ZoneResolver reports the failure domain an endpoint runs in
+type ZoneResolver interface {
+	ZoneOf(ctx context.Context, endpoint string) (string, error)
 }
 
 // FailoverEvent represents a failover event
 type FailoverEvent struct {
-	ID          string    `json:"id"`
-	ShardID     string    `json:"shard_id"`
-	OldPrimary  string    `json:"old_primary"`
-	NewPrimary  string    `json:"new_primary"`
-	Reason      string    `json:"reason"`
-	Status      string    `json:"status"` // "success", "failed", "rolled_back"
-	StartedAt   time.Time `json:"started_at"`
+	ID          string     `json:"id"`
+	ShardID     string     `json:"shard_id"`
+	Type        string     `json:"type"` // "emergency" or "planned"
+	OldPrimary  string     `json:"old_primary"`
+	NewPrimary  string     `json:"new_primary"`
+	Reason      string     `json:"reason"`
+	Status      string     `json:"status"`          // "success", "failed", "rolled_back", "incomplete"
+	Steps       []string   `json:"steps,omitempty"` // Steps a planned failover reached, in order
+	StartedAt   time.Time  `json:"started_at"`
 	CompletedAt *time.Time `json:"completed_at,omitempty"`
-	Error       string    `json:"error,omitempty"`
+	Error       string     `json:"error,omitempty"`
 }
 
 // NewFailoverController creates a new failover controller
 func NewFailoverController(mgr *manager.Manager, healthCtrl *health.Controller, logger *zap.Logger, checkInterval time.Duration) *FailoverController {
 	return &FailoverController{
-		manager:        mgr,
-		healthCtrl:     healthCtrl,
-		logger:         logger,
-		checkInterval:  checkInterval,
-		enabled:        true,
+		manager:         mgr,
+		healthCtrl:      healthCtrl,
+		logger:          logger,
+		checkInterval:   checkInterval,
+		enabled:         true,
 		failoverHistory: make([]*FailoverEvent, 0),
-		stopCh:         make(chan struct{}),
+		stopCh:          make(chan struct{}),
+		detector:        newFailureDetector(DefaultFailureThreshold, checkInterval),
+		failingOver:     make(map[string]string),
+	}
+}
+
+// SetFailureThreshold sets how many failed health checks in a row trigger a
+// failover of shards that set no threshold of their own. Non-positive values
+// use DefaultFailureThreshold.
+func (c *FailoverController) SetFailureThreshold(threshold int) {
+	if threshold <= 0 {
+		threshold = DefaultFailureThreshold
 	}
+	c.detector.setThreshold(threshold)
+}
+
+// SetZoneResolver enables zone-aware promotion: replicas outside the failed
+// primary's zone are preferred
+func (c *FailoverController) SetZoneResolver(resolver ZoneResolver) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.zones = resolver
 }
 
 // Start starts the failover monitoring loop
@@ -104,51 +139,79 @@ func (c *FailoverController) IsEnabled() bool {
 	return c.enabled
 }
 
-// monitorLoop continuously monitors shards for failures
+// monitorLoop continuously monitors shards for failures. It wakes up when
+// the next shard is due for a check, since shards may be checked more often
+// than the controller's interval.
 func (c *FailoverController) monitorLoop() {
-	ticker := time.NewTicker(c.checkInterval)
-	defer ticker.Stop()
+	timer := time.NewTimer(c.checkInterval)
+	defer timer.Stop()
 
 	for {
 		select {
 		case <-c.stopCh:
 			return
-		case <-ticker.C:
+		case <-timer.C:
+			wait := c.checkInterval
 			if c.IsEnabled() {
-				c.checkAndFailover(context.Background())
+				wait = c.checkAndFailover(context.Background(), time.Now())
+				c.checkDrains(context.Background(), time.Now())
 			}
+			timer.Reset(wait)
 		}
 	}
 }
 
-// checkAndFailover checks all shards and performs failover if needed
-func (c *FailoverController) checkAndFailover(ctx context.Context) {
+// checkAndFailover checks the shards due for a check at now and performs
+// failover for those that failed enough checks in a row. It returns how long
+// until the next shard is due.
+func (c *FailoverController) checkAndFailover(ctx context.Context, now time.Time) time.Duration {
 	// Get all shards
 	shards, err := c.manager.ListShards()
 	if err != nil {
 		c.logger.Error("failed to list shards for failover check", zap.Error(err))
-		return
+		return c.checkInterval
 	}
+	c.detector.prune(shards)
+
+	for i := range shards {
+		shard := &shards[i]
+		if !c.detector.due(shard.ID, now) {
+			continue
+		}
+		// A planned failover takes the primary down on purpose
+		if c.inPlannedFailover(shard.ID) {
+			c.detector.skip(shard, now)
+			continue
+		}
 
-	for _, shard := range shards {
 		// Get shard health status
 		healthStatus, err := c.healthCtrl.GetHealth(shard.ID)
 		if err != nil {
 			c.logger.Warn("failed to get shard health",
 				zap.String("shard_id", shard.ID),
 				zap.Error(err))
+			c.detector.skip(shard, now)
 			continue
 		}
 
-		// Check if primary is down and we have healthy replicas
-		if !healthStatus.PrimaryUp && len(healthStatus.ReplicasUp) > 0 {
+		// Fail over once the primary failed the shard's threshold of checks in
+		// a row and we have healthy replicas
+		down := c.detector.record(shard, healthStatus.PrimaryUp, healthStatus.LastCheck, now)
+		if !healthStatus.PrimaryUp {
+			c.logger.Debug("primary shard failed health check",
+				zap.String("shard_id", shard.ID),
+				zap.Int("failures", c.detector.failures(shard.ID)),
+				zap.Int("threshold", c.detector.policyFor(shard).threshold))
+		}
+		if down && len(healthStatus.ReplicasUp) > 0 {
+			c.detector.reset(shard.ID)
 			c.logger.Warn("primary shard is down, initiating failover",
 				zap.String("shard_id", shard.ID),
 				zap.Strings("available_replicas", healthStatus.ReplicasUp))
 
-			// Select best replica (first available for now)
-			bestReplica := healthStatus.ReplicasUp[0]
-			
+			// Prefer a replica outside the failed primary's zone
+			bestReplica := c.selectReplica(ctx, shard.PrimaryEndpoint, healthStatus.ReplicasUp)
+
 			// Perform failover
 			if err := c.performFailover(ctx, shard.ID, shard.PrimaryEndpoint, bestReplica); err != nil {
 				c.logger.Error("failover failed",
@@ -157,13 +220,60 @@ func (c *FailoverController) checkAndFailover(ctx context.Context) {
 			}
 		}
 	}
+	return c.detector.nextDue(time.Now())
+}
+
+// selectReplica picks the replica to promote. With a zone resolver it picks the
+// first replica whose zone differs from the primary's, since a replica in the
+// same zone likely shares whatever took the primary down. Otherwise, or if no
+// such replica exists, it falls back to the first healthy replica.
+func (c *FailoverController) selectReplica(ctx context.Context, primary string, replicas []string) string {
+	c.mu.RLock()
+	resolver := c.zones
+	c.mu.RUnlock()
+
+	if resolver == nil || len(replicas) == 1 {
+		return replicas[0]
+	}
+
+	primaryZone, err := resolver.ZoneOf(ctx, primary)
+	if err != nil {
+		c.logger.Warn("failed to resolve primary zone, promoting first healthy replica",
+			zap.String("primary", primary),
+			zap.Error(err))
+		return replicas[0]
+	}
+
+	for _, replica := range replicas {
+		zone, err := resolver.ZoneOf(ctx, replica)
+		if err != nil {
+			c.logger.Debug("failed to resolve replica zone",
+				zap.String("replica", replica),
+				zap.Error(err))
+			continue
+		}
+		if zone != primaryZone {
+			return replica
+		}
+	}
+
+	c.logger.Warn("all healthy replicas share the primary's zone",
+		zap.String("zone", primaryZone),
+		zap.Strings("replicas", replicas))
+	return replicas[0]
 }
 
 // performFailover performs the actual failover operation
 func (c *FailoverController) performFailover(ctx context.Context, shardID string, oldPrimary string, newPrimary string) error {
+	if !c.beginFailover(shardID, FailoverTypeEmergency) {
+		return ErrFailoverInProgress
+	}
+	defer c.endFailover(shardID)
+
 	event := &FailoverEvent{
 		ID:         fmt.Sprintf("failover-%d", time.Now().Unix()),
 		ShardID:    shardID,
+		Type:       FailoverTypeEmergency,
 		OldPrimary: oldPrimary,
 		NewPrimary: newPrimary,
 		Reason:     "primary_unavailable",
@@ -225,6 +335,12 @@ func (c *FailoverController) performFailover(ctx context.Context, shardID string
 		zap.String("event_id", event.ID),
 		zap.String("shard_id", shardID),
 		zap.String("new_primary", newPrimary))
+	c.manager.RecordShardEvent(shardID, catalog.ShardEventFailedOver, "failed over after the primary became unavailable", map[string]string{
+		"failover_id": event.ID,
+		"reason":      event.Reason,
+		"old_primary": models.EndpointLabel(oldPrimary),
+		"new_primary": models.EndpointLabel(newPrimary),
+	})
 
 	return nil
 }
@@ -299,4 +415,3 @@ func (c *FailoverController) GetFailoverHistoryForShard(shardID string) []*Failo
 
 	return history
 }
-
@@ -0,0 +1,154 @@
+package failover
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/sharding-system/pkg/models"
+	"go.uber.org/zap"
+)
+
+// staticZones resolves endpoints from a fixed map
+type staticZones map[string]string
+
+func (z staticZones) ZoneOf(ctx context.Context, endpoint string) (string, error) {
+	zone, ok := z[endpoint]
+	if !ok {
+		return "", errors.New("unknown endpoint")
+	}
+	return zone, nil
+}
+
+func TestSelectReplica_PrefersOtherZone(t *testing.T) {
+	c := NewFailoverController(nil, nil, zap.NewNop(), time.Second)
+	c.SetZoneResolver(staticZones{
+		"primary":   "zone-a",
+		"replica-1": "zone-a",
+		"replica-2": "zone-b",
+	})
+
+	got := c.selectReplica(context.Background(), "primary", []string{"replica-1", "replica-2"})
+	if got != "replica-2" {
+		t.Errorf("expected replica-2 in another zone, got %s", got)
+	}
+}
+
+func TestSelectReplica_FallsBackToFirst(t *testing.T) {
+	c := NewFailoverController(nil, nil, zap.NewNop(), time.Second)
+	replicas := []string{"replica-1", "replica-2"}
+
+	// No resolver
+	if got := c.selectReplica(context.Background(), "primary", replicas); got != "replica-1" {
+		t.Errorf("expected replica-1 without resolver, got %s", got)
+	}
+
+	// Every replica shares the primary's zone
+	c.SetZoneResolver(staticZones{"primary": "zone-a", "replica-1": "zone-a", "replica-2": "zone-a"})
+	if got := c.selectReplica(context.Background(), "primary", replicas); got != "replica-1" {
+		t.Errorf("expected replica-1 when all share a zone, got %s", got)
+	}
+
+	// Primary zone unknown
+	c.SetZoneResolver(staticZones{"replica-2": "zone-b"})
+	if got := c.selectReplica(context.Background(), "primary", replicas); got != "replica-1" {
+		t.Errorf("expected replica-1 when primary zone is unknown, got %s", got)
+	}
+}
+
+func TestFailureDetector_LowerThresholdFailsOverSooner(t *testing.T) {
+	d := newFailureDetector(DefaultFailureThreshold, 10*time.Second)
+	critical := &models.Shard{ID: "critical", FailureThreshold: 2, HealthCheckIntervalSeconds: 1}
+	relaxed := &models.Shard{ID: "relaxed", FailureThreshold: 5}
+	shards := []*models.Shard{critical, relaxed}
+
+	// Both primaries are down from the start; step through the checks as the
+	// monitor loop would until both fail over
+	start := time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC)
+	now := start
+	failedOver := make(map[string]time.Duration)
+	for len(failedOver) < len(shards) && now.Sub(start) < time.Hour {
+		for _, shard := range shards {
+			if !d.due(shard.ID, now) {
+				continue
+			}
+			if d.record(shard, false, now, now) {
+				d.reset(shard.ID)
+				if _, done := failedOver[shard.ID]; !done {
+					failedOver[shard.ID] = now.Sub(start)
+				}
+			}
+		}
+		now = now.Add(d.nextDue(now))
+	}
+
+	if got := failedOver["critical"]; got != time.Second {
+		t.Errorf("expected the critical shard to fail over after its second check 1s in, got %v", got)
+	}
+	if got := failedOver["relaxed"]; got != 40*time.Second {
+		t.Errorf("expected the relaxed shard to fail over after its fifth check 40s in, got %v", got)
+	}
+}
+
+func TestFailureDetector_CountsConsecutiveFailures(t *testing.T) {
+	d := newFailureDetector(3, time.Second)
+	shard := &models.Shard{ID: "shard1"}
+	now := time.Now()
+	observed := now
+	check := func(primaryUp bool) bool {
+		observed = observed.Add(time.Second)
+		return d.record(shard, primaryUp, observed, now)
+	}
+
+	// A passing check starts the count over
+	check(false)
+	check(false)
+	check(true)
+	if check(false) || check(false) {
+		t.Fatal("expected the default threshold of 3 consecutive failures to apply")
+	}
+	if !check(false) {
+		t.Fatal("expected the third consecutive failure to trigger a failover")
+	}
+
+	// Skipped checks are neither counted nor reset
+	d.reset(shard.ID)
+	check(false)
+	d.skip(shard, now)
+	if got := d.failures(shard.ID); got != 1 {
+		t.Errorf("expected a skipped check to keep the count at 1, got %d", got)
+	}
+	if d.due(shard.ID, now) || !d.due(shard.ID, now.Add(time.Second)) {
+		t.Error("expected the shard to be due again after its interval")
+	}
+
+	// Shards that leave the catalog are forgotten
+	d.prune(nil)
+	if got := d.failures(shard.ID); got != 0 {
+		t.Errorf("expected a pruned shard to be forgotten, got %d failures", got)
+	}
+}
+
+func TestFailureDetector_CountsEachObservationOnce(t *testing.T) {
+	// Shards are checked every second, but health is only refreshed every 30s
+	d := newFailureDetector(2, time.Second)
+	shard := &models.Shard{ID: "shard1"}
+	observed := time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC)
+	now := observed
+
+	for i := 0; i < 30; i++ {
+		if d.record(shard, false, observed, now) {
+			t.Fatalf("expected one failed observation not to trigger a failover, check %d did", i+1)
+		}
+		now = now.Add(time.Second)
+	}
+	if got := d.failures(shard.ID); got != 1 {
+		t.Errorf("expected the repeated observation to count once, got %d failures", got)
+	}
+
+	// The next refresh is a new observation
+	if !d.record(shard, false, observed.Add(30*time.Second), now) {
+		t.Error("expected the second failed observation to trigger a failover")
+	}
+}
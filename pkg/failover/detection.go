@@ -0,0 +1,167 @@
+package failover
+
+import (
+	"sync"
+	"time"
+
+	"github.com/sharding-system/pkg/models"
+)
+
+// DefaultFailureThreshold is how many failed health checks in a row trigger a
+// failover of a shard that sets no threshold of its own
+const DefaultFailureThreshold = 1
+
+// detectionPolicy is how aggressively a shard's primary is declared down
+type detectionPolicy struct {
+	threshold int
+	interval  time.Duration
+}
+
+// shardDetection is the failure detection state of one shard
+type shardDetection struct {
+	failures  int
+	nextCheck time.Time
+	observed  time.Time // When the last health observation counted was made
+}
+
+// failureDetector counts failed health checks per shard and decides when a
+// shard is due for a check and when its primary counts as down. Shards use
+// their own threshold and interval when set, and the detector's otherwise.
+type failureDetector struct {
+	mu        sync.Mutex
+	threshold int
+	interval  time.Duration
+	shards    map[string]*shardDetection
+}
+
+func newFailureDetector(threshold int, interval time.Duration) *failureDetector {
+	return &failureDetector{
+		threshold: threshold,
+		interval:  interval,
+		shards:    make(map[string]*shardDetection),
+	}
+}
+
+// setThreshold changes the threshold of shards that set none
+func (d *failureDetector) setThreshold(threshold int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.threshold = threshold
+}
+
+// policyFor returns the detection policy of a shard
+func (d *failureDetector) policyFor(shard *models.Shard) detectionPolicy {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.policyLocked(shard)
+}
+
+func (d *failureDetector) policyLocked(shard *models.Shard) detectionPolicy {
+	policy := detectionPolicy{threshold: d.threshold, interval: d.interval}
+	if shard.FailureThreshold > 0 {
+		policy.threshold = shard.FailureThreshold
+	}
+	if shard.HealthCheckIntervalSeconds > 0 {
+		policy.interval = time.Duration(shard.HealthCheckIntervalSeconds) * time.Second
+	}
+	return policy
+}
+
+// due reports whether a shard should be checked at now
+func (d *failureDetector) due(shardID string, now time.Time) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	state, ok := d.shards[shardID]
+	return !ok || !now.Before(state.nextCheck)
+}
+
+// record records the result of checking a shard at now and schedules its
+// next check. It reports whether the shard has failed enough checks in a row
+// to fail over. observedAt is when the health result was measured: health is
+// refreshed on its own schedule, so a result no newer than the last one
+// counted is the same observation again and is not counted twice.
+func (d *failureDetector) record(shard *models.Shard, primaryUp bool, observedAt, now time.Time) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	policy := d.policyLocked(shard)
+	state := d.scheduleLocked(shard, now)
+	if !observedAt.After(state.observed) {
+		return false
+	}
+	state.observed = observedAt
+	if primaryUp {
+		state.failures = 0
+		return false
+	}
+	state.failures++
+	return state.failures >= policy.threshold
+}
+
+// reset starts a shard's count of failed checks over, e.g. after failing it over
+func (d *failureDetector) reset(shardID string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if state, ok := d.shards[shardID]; ok {
+		state.failures = 0
+	}
+}
+
+// skip schedules a shard's next check without counting this one, e.g. when
+// its health could not be read
+func (d *failureDetector) skip(shard *models.Shard, now time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.scheduleLocked(shard, now)
+}
+
+func (d *failureDetector) scheduleLocked(shard *models.Shard, now time.Time) *shardDetection {
+	state, ok := d.shards[shard.ID]
+	if !ok {
+		state = &shardDetection{}
+		d.shards[shard.ID] = state
+	}
+	state.nextCheck = now.Add(d.policyLocked(shard).interval)
+	return state
+}
+
+// failures returns how many checks in a row a shard has failed
+func (d *failureDetector) failures(shardID string) int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if state, ok := d.shards[shardID]; ok {
+		return state.failures
+	}
+	return 0
+}
+
+// prune forgets shards that left the catalog
+func (d *failureDetector) prune(shards []models.Shard) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	current := make(map[string]bool, len(shards))
+	for i := range shards {
+		current[shards[i].ID] = true
+	}
+	for id := range d.shards {
+		if !current[id] {
+			delete(d.shards, id)
+		}
+	}
+}
+
+// nextDue returns how long until the next shard is due for a check, at most
+// the detector's interval
+func (d *failureDetector) nextDue(now time.Time) time.Duration {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	wait := d.interval
+	for _, state := range d.shards {
+		if until := state.nextCheck.Sub(now); until < wait {
+			wait = until
+		}
+	}
+	if wait < 0 {
+		wait = 0
+	}
+	return wait
+}
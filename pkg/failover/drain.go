@@ -0,0 +1,87 @@
+package failover
+
+import (
+	"context"
+	"time"
+
+	"github.com/sharding-system/pkg/models"
+	"go.uber.org/zap"
+)
+
+// drainRetryInterval is how long a shard whose primary is on a draining node
+// waits before another planned failover is attempted
+const drainRetryInterval = time.Minute
+
+// DrainDetector reports whether the node running an endpoint is being
+// drained, e.g. because it was cordoned
+type DrainDetector interface {
+	Draining(ctx context.Context, endpoint string) (bool, error)
+}
+
+// SetDrainDetector enables drain-aware failover: primaries on nodes being
+// drained are switched over to a replica in a planned failover before their
+// pod is evicted. A nil detector turns it off.
+func (c *FailoverController) SetDrainDetector(detector DrainDetector) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.drains = detector
+	if c.drainAttempts == nil {
+		c.drainAttempts = make(map[string]time.Time)
+	}
+}
+
+// checkDrains starts a planned failover for each active shard with replicas
+// whose primary runs on a node being drained
+func (c *FailoverController) checkDrains(ctx context.Context, now time.Time) {
+	c.mu.RLock()
+	detector := c.drains
+	c.mu.RUnlock()
+	if detector == nil {
+		return
+	}
+
+	shards, err := c.manager.ListShards()
+	if err != nil {
+		c.logger.Error("failed to list shards for drain check", zap.Error(err))
+		return
+	}
+	for i := range shards {
+		shard := shards[i]
+		if shard.Status != models.ShardStatusActive || len(shard.Replicas) == 0 || c.inPlannedFailover(shard.ID) {
+			continue
+		}
+		draining, err := detector.Draining(ctx, shard.PrimaryEndpoint)
+		if err != nil {
+			c.logger.Debug("failed to check whether the primary's node is draining",
+				zap.String("shard_id", shard.ID),
+				zap.Error(err))
+			continue
+		}
+		if !draining || !c.attemptDrainFailover(shard.ID, now) {
+			continue
+		}
+
+		c.logger.Info("primary runs on a draining node, switching over to a replica",
+			zap.String("shard_id", shard.ID),
+			zap.String("primary", models.EndpointLabel(shard.PrimaryEndpoint)))
+		go func(shardID string) {
+			if _, err := c.PlannedFailover(ctx, PlannedFailoverRequest{ShardID: shardID, Reason: "node_drain"}); err != nil {
+				c.logger.Warn("failed to switch over a primary on a draining node",
+					zap.String("shard_id", shardID),
+					zap.Error(err))
+			}
+		}(shard.ID)
+	}
+}
+
+// attemptDrainFailover records a drain failover attempt for a shard, or
+// returns false if one was attempted within drainRetryInterval
+func (c *FailoverController) attemptDrainFailover(shardID string, now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if last, ok := c.drainAttempts[shardID]; ok && now.Sub(last) < drainRetryInterval {
+		return false
+	}
+	c.drainAttempts[shardID] = now
+	return true
+}
@@ -0,0 +1,63 @@
+package failover
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// drainingNodes reports the endpoints whose node is being drained
+type drainingNodes map[string]bool
+
+func (d drainingNodes) Draining(ctx context.Context, endpoint string) (bool, error) {
+	return d[endpoint], nil
+}
+
+func TestCheckDrains_SwitchesOverPrimaryOnDrainingNode(t *testing.T) {
+	c, cat, switchover := newPlannedController(t, "")
+	c.SetDrainDetector(drainingNodes{"postgres://db-1/orders": true})
+
+	now := time.Now()
+	c.checkDrains(context.Background(), now)
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		history := c.GetFailoverHistoryForShard("shard1")
+		if len(history) == 1 && history[0].Status != "in_progress" {
+			if history[0].Type != FailoverTypePlanned || history[0].Reason != "node_drain" || history[0].Status != "success" {
+				t.Errorf("expected a successful planned failover for the drain, got %+v", history[0])
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for the drain failover, got %+v", history)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if shard, _ := cat.GetShardByID("shard1"); shard.PrimaryEndpoint == "postgres://db-1/orders" {
+		t.Error("expected the primary to move off the draining node")
+	}
+
+	// The primary is back on the draining node, but a shard is not switched
+	// over again right away
+	shard, _ := cat.GetShardByID("shard1")
+	shard.PrimaryEndpoint, shard.Replicas = "postgres://db-1/orders", []string{"postgres://db-2/orders"}
+	cat.UpdateShard(shard)
+	c.checkDrains(context.Background(), now.Add(time.Second))
+	time.Sleep(20 * time.Millisecond)
+	switchover.mu.Lock()
+	defer switchover.mu.Unlock()
+	if len(switchover.steps) != 4 {
+		t.Errorf("expected no second failover within the retry interval, got steps %v", switchover.steps)
+	}
+}
+
+func TestCheckDrains_IgnoresShardsWithoutDrainingPrimary(t *testing.T) {
+	c, _, switchover := newPlannedController(t, "")
+	c.SetDrainDetector(drainingNodes{"postgres://db-2/orders": true})
+
+	c.checkDrains(context.Background(), time.Now())
+	time.Sleep(20 * time.Millisecond)
+	if len(c.GetFailoverHistoryForShard("shard1")) != 0 || len(switchover.steps) != 0 {
+		t.Errorf("expected no failover when only a replica's node drains, got steps %v", switchover.steps)
+	}
+}
@@ -0,0 +1,259 @@
+package failover
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sharding-system/pkg/catalog"
+	"github.com/sharding-system/pkg/models"
+	"go.uber.org/zap"
+)
+
+// Failover types recorded in the history
+const (
+	FailoverTypeEmergency = "emergency" // The primary failed its health checks
+	FailoverTypePlanned   = "planned"   // An operator switched primaries over
+)
+
+var (
+	// ErrSwitchoverUnsupported is returned for a planned failover when the
+	// controller has no Switchover to carry it out with
+	ErrSwitchoverUnsupported = errors.New("planned failover is not supported")
+	// ErrFailoverInProgress is returned for a failover of a shard that another
+	// failover is already running on
+	ErrFailoverInProgress = errors.New("a failover of this shard is already in progress")
+	// ErrInvalidReplica is returned when the replica to promote does not
+	// belong to the shard or is not healthy
+	ErrInvalidReplica = errors.New("invalid replica for planned failover")
+)
+
+// Switchover carries out the database side of a planned failover. The
+// controller calls its steps in order: DrainWrites, Demote, Promote and
+// Reconfigure. Resume undoes DrainWrites and Demote when the failover is
+// abandoned before the replica is promoted.
+type Switchover interface {
+	// DrainWrites stops the primary accepting writes and waits for the writes
+	// in flight to finish
+	DrainWrites(ctx context.Context, shard *models.Shard) error
+	// Demote fences the drained primary so that nothing can write to it,
+	// disconnecting its remaining clients
+	Demote(ctx context.Context, shard *models.Shard) error
+	// Promote waits for replica to replay everything the primary wrote, then
+	// makes it a primary
+	Promote(ctx context.Context, shard *models.Shard, replica string) error
+	// Reconfigure points the shard's other replicas at the new primary
+	Reconfigure(ctx context.Context, shard *models.Shard, newPrimary string) error
+	// Resume lets the primary accept writes again
+	Resume(ctx context.Context, shard *models.Shard) error
+}
+
+// PlannedFailoverRequest asks for a shard's primary to be switched over
+type PlannedFailoverRequest struct {
+	ShardID string `json:"shard_id"`
+	// Replica is the replica to promote; empty picks one as an emergency
+	// failover would
+	Replica string `json:"replica,omitempty"`
+	// Reason is recorded in the history; defaults to planned_maintenance
+	Reason string `json:"reason,omitempty"`
+}
+
+// SetSwitchover sets what planned failovers are carried out with
+func (c *FailoverController) SetSwitchover(switchover Switchover) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.switchover = switchover
+}
+
+// PlannedFailover gracefully moves a shard's primary to one of its replicas,
+// e.g. ahead of maintenance. Writes are drained from the primary, which is
+// then fenced before the replica is promoted and the other replicas
+// reconfigured to follow it. No other failover of the shard runs meanwhile.
+// The failover is recorded in the history as planned; the returned event
+// describes how it went.
+func (c *FailoverController) PlannedFailover(ctx context.Context, req PlannedFailoverRequest) (*FailoverEvent, error) {
+	c.mu.RLock()
+	switchover := c.switchover
+	c.mu.RUnlock()
+	if switchover == nil {
+		return nil, ErrSwitchoverUnsupported
+	}
+
+	// Read the shard once no other failover can change its primary
+	if !c.beginFailover(req.ShardID, FailoverTypePlanned) {
+		return nil, ErrFailoverInProgress
+	}
+	defer c.endFailover(req.ShardID)
+
+	shard, err := c.manager.GetShard(req.ShardID)
+	if err != nil {
+		return nil, err
+	}
+	replica, err := c.plannedReplica(ctx, shard, req.Replica)
+	if err != nil {
+		return nil, err
+	}
+
+	reason := req.Reason
+	if reason == "" {
+		reason = "planned_maintenance"
+	}
+	event := &FailoverEvent{
+		ID:         fmt.Sprintf("failover-%d", time.Now().UnixNano()),
+		ShardID:    shard.ID,
+		Type:       FailoverTypePlanned,
+		OldPrimary: shard.PrimaryEndpoint,
+		NewPrimary: replica,
+		Reason:     reason,
+		Status:     "in_progress",
+		StartedAt:  time.Now(),
+	}
+	c.mu.Lock()
+	c.failoverHistory = append(c.failoverHistory, event)
+	c.mu.Unlock()
+
+	logger := c.logger.With(
+		zap.String("event_id", event.ID),
+		zap.String("shard_id", shard.ID),
+		zap.String("old_primary", models.EndpointLabel(event.OldPrimary)),
+		zap.String("new_primary", models.EndpointLabel(replica)))
+	logger.Info("performing planned failover")
+
+	// Until the replica is promoted the old primary can take writes again
+	abandon := func(step string, err error) (*FailoverEvent, error) {
+		if resumeErr := switchover.Resume(context.Background(), shard); resumeErr != nil {
+			logger.Error("failed to resume writes on the primary after an abandoned planned failover", zap.Error(resumeErr))
+		}
+		c.finishEvent(event, "failed", fmt.Errorf("%s: %w", step, err))
+		logger.Error("planned failover failed", zap.String("step", step), zap.Error(err))
+		return c.copyEvent(event), fmt.Errorf("planned failover failed to %s: %w", step, err)
+	}
+
+	c.recordStep(event, "drain")
+	if err := switchover.DrainWrites(ctx, shard); err != nil {
+		return abandon("drain writes", err)
+	}
+	c.recordStep(event, "demote")
+	if err := switchover.Demote(ctx, shard); err != nil {
+		return abandon("demote the primary", err)
+	}
+	c.recordStep(event, "promote")
+	if err := switchover.Promote(ctx, shard, replica); err != nil {
+		return abandon("promote the replica", err)
+	}
+	if err := c.manager.PromoteReplica(shard.ID, replica); err != nil {
+		// The replica already runs as a primary, so the old one cannot resume
+		c.finishEvent(event, "failed", fmt.Errorf("update catalog: %w", err))
+		logger.Error("planned failover promoted the replica but failed to update the catalog", zap.Error(err))
+		return c.copyEvent(event), fmt.Errorf("planned failover failed to update the catalog: %w", err)
+	}
+
+	c.recordStep(event, "reconfigure")
+	if err := switchover.Reconfigure(ctx, shard, replica); err != nil {
+		// The new primary serves the shard; replicas still following the old
+		// one need reconfiguring by hand
+		c.finishEvent(event, "incomplete", fmt.Errorf("reconfigure replication: %w", err))
+		logger.Warn("planned failover switched primaries but failed to reconfigure replication", zap.Error(err))
+	} else {
+		c.finishEvent(event, "success", nil)
+		logger.Info("planned failover completed successfully")
+	}
+
+	c.manager.RecordShardEvent(shard.ID, catalog.ShardEventSwitchedOver, "switched over to a replica in a planned failover", map[string]string{
+		"failover_id": event.ID,
+		"reason":      reason,
+		"old_primary": models.EndpointLabel(event.OldPrimary),
+		"new_primary": models.EndpointLabel(replica),
+	})
+	return c.copyEvent(event), nil
+}
+
+// plannedReplica returns the replica a planned failover promotes: the
+// requested one, which must belong to the shard and not be known to be down,
+// or else the one an emergency failover would pick among the healthy replicas
+func (c *FailoverController) plannedReplica(ctx context.Context, shard *models.Shard, requested string) (string, error) {
+	healthy := shard.Replicas
+	if c.healthCtrl != nil {
+		if status, err := c.healthCtrl.GetHealth(shard.ID); err == nil {
+			healthy = status.ReplicasUp
+		}
+	}
+
+	if requested == "" {
+		if len(healthy) == 0 {
+			return "", fmt.Errorf("%w: shard %s has no healthy replica", ErrInvalidReplica, shard.ID)
+		}
+		return c.selectReplica(ctx, shard.PrimaryEndpoint, healthy), nil
+	}
+	if !containsEndpoint(shard.Replicas, requested) {
+		return "", fmt.Errorf("%w: %s is not a replica of shard %s", ErrInvalidReplica, models.EndpointLabel(requested), shard.ID)
+	}
+	if !containsEndpoint(healthy, requested) {
+		return "", fmt.Errorf("%w: replica %s is not healthy", ErrInvalidReplica, models.EndpointLabel(requested))
+	}
+	return requested, nil
+}
+
+func containsEndpoint(endpoints []string, endpoint string) bool {
+	for _, e := range endpoints {
+		if e == endpoint {
+			return true
+		}
+	}
+	return false
+}
+
+// beginFailover marks a shard as failing over, or returns false if a failover
+// of it is already running. Emergency and planned failovers of a shard
+// exclude each other this way.
+func (c *FailoverController) beginFailover(shardID, failoverType string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, running := c.failingOver[shardID]; running {
+		return false
+	}
+	c.failingOver[shardID] = failoverType
+	return true
+}
+
+func (c *FailoverController) endFailover(shardID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.failingOver, shardID)
+}
+
+// inPlannedFailover reports whether a shard is switching over
+func (c *FailoverController) inPlannedFailover(shardID string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.failingOver[shardID] == FailoverTypePlanned
+}
+
+// recordStep notes the step a failover reached
+func (c *FailoverController) recordStep(event *FailoverEvent, step string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	event.Steps = append(event.Steps, step)
+}
+
+// finishEvent completes a failover event with status and, unless nil, err
+func (c *FailoverController) finishEvent(event *FailoverEvent, status string, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	event.Status = status
+	event.CompletedAt = &now
+	if err != nil {
+		event.Error = err.Error()
+	}
+}
+
+// copyEvent returns a copy of an event the history keeps updating
+func (c *FailoverController) copyEvent(event *FailoverEvent) *FailoverEvent {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	copied := *event
+	copied.Steps = append([]string(nil), event.Steps...)
+	return &copied
+}
@@ -0,0 +1,202 @@
+package failover
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sharding-system/pkg/config"
+	"github.com/sharding-system/pkg/manager"
+	"github.com/sharding-system/pkg/models"
+	"go.uber.org/zap/zaptest"
+)
+
+// shardCatalog keeps shards in memory
+type shardCatalog struct {
+	mu     sync.Mutex
+	shards map[string]models.Shard
+}
+
+func (c *shardCatalog) GetShard(key string, clientAppID string) (*models.Shard, error) {
+	return nil, errors.New("not supported")
+}
+func (c *shardCatalog) GetShardByID(shardID string) (*models.Shard, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	shard, ok := c.shards[shardID]
+	if !ok {
+		return nil, fmt.Errorf("shard not found: %s", shardID)
+	}
+	shard.Replicas = append([]string(nil), shard.Replicas...)
+	return &shard, nil
+}
+func (c *shardCatalog) ListShards(clientAppID string) ([]models.Shard, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	shards := make([]models.Shard, 0, len(c.shards))
+	for _, shard := range c.shards {
+		shards = append(shards, shard)
+	}
+	return shards, nil
+}
+func (c *shardCatalog) CreateShard(shard *models.Shard) error { return c.UpdateShard(shard) }
+func (c *shardCatalog) UpdateShard(shard *models.Shard) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.shards[shard.ID] = *shard
+	return nil
+}
+func (c *shardCatalog) DeleteShard(shardID string) error  { return nil }
+func (c *shardCatalog) GetCatalogVersion() (int64, error) { return 0, nil }
+func (c *shardCatalog) Reload() error                     { return nil }
+func (c *shardCatalog) Watch(ctx context.Context) (<-chan *models.ShardCatalog, error) {
+	return nil, errors.New("not supported")
+}
+
+// recordingSwitchover records the steps it is asked to carry out and fails
+// the one named in failAt
+type recordingSwitchover struct {
+	mu     sync.Mutex
+	steps  []string
+	failAt string
+	// primaryAtPromote is the catalog's primary when the replica is promoted
+	primaryAtPromote string
+	catalog          *shardCatalog
+}
+
+func (s *recordingSwitchover) step(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.steps = append(s.steps, name)
+	if name == s.failAt {
+		return fmt.Errorf("%s failed", name)
+	}
+	return nil
+}
+
+func (s *recordingSwitchover) DrainWrites(ctx context.Context, shard *models.Shard) error {
+	return s.step("drain")
+}
+func (s *recordingSwitchover) Demote(ctx context.Context, shard *models.Shard) error {
+	return s.step("demote")
+}
+func (s *recordingSwitchover) Promote(ctx context.Context, shard *models.Shard, replica string) error {
+	current, _ := s.catalog.GetShardByID(shard.ID)
+	s.primaryAtPromote = current.PrimaryEndpoint
+	return s.step("promote:" + replica)
+}
+func (s *recordingSwitchover) Reconfigure(ctx context.Context, shard *models.Shard, newPrimary string) error {
+	return s.step("reconfigure:" + newPrimary)
+}
+func (s *recordingSwitchover) Resume(ctx context.Context, shard *models.Shard) error {
+	return s.step("resume")
+}
+
+func newPlannedController(t *testing.T, failAt string) (*FailoverController, *shardCatalog, *recordingSwitchover) {
+	t.Helper()
+	cat := &shardCatalog{shards: map[string]models.Shard{
+		"shard1": {ID: "shard1", Status: models.ShardStatusActive, PrimaryEndpoint: "postgres://db-1/orders",
+			Replicas: []string{"postgres://db-2/orders", "postgres://db-3/orders"}},
+	}}
+	mgr := manager.NewManager(cat, zaptest.NewLogger(t), nil, config.PricingConfig{Tier: "pro"})
+	c := NewFailoverController(mgr, nil, zaptest.NewLogger(t), time.Second)
+	switchover := &recordingSwitchover{failAt: failAt, catalog: cat}
+	c.SetSwitchover(switchover)
+	return c, cat, switchover
+}
+
+func TestPlannedFailover_GracefulOrdering(t *testing.T) {
+	c, cat, switchover := newPlannedController(t, "")
+
+	event, err := c.PlannedFailover(context.Background(), PlannedFailoverRequest{ShardID: "shard1", Replica: "postgres://db-3/orders"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []string{"drain", "demote", "promote:postgres://db-3/orders", "reconfigure:postgres://db-3/orders"}
+	if !reflect.DeepEqual(switchover.steps, want) {
+		t.Errorf("expected steps %v, got %v", want, switchover.steps)
+	}
+	if !reflect.DeepEqual(event.Steps, []string{"drain", "demote", "promote", "reconfigure"}) {
+		t.Errorf("expected the event to record every step, got %v", event.Steps)
+	}
+	// The catalog only moves to the new primary once it was promoted
+	if switchover.primaryAtPromote != "postgres://db-1/orders" {
+		t.Errorf("expected the catalog to keep the old primary until promotion, got %s", switchover.primaryAtPromote)
+	}
+	shard, _ := cat.GetShardByID("shard1")
+	if shard.PrimaryEndpoint != "postgres://db-3/orders" {
+		t.Errorf("expected db-3 to be the primary, got %s", shard.PrimaryEndpoint)
+	}
+	if !containsEndpoint(shard.Replicas, "postgres://db-1/orders") || containsEndpoint(shard.Replicas, "postgres://db-3/orders") {
+		t.Errorf("expected the old primary to become a replica, got %v", shard.Replicas)
+	}
+
+	// Planned failovers are told apart from emergency ones in the history
+	history := c.GetFailoverHistoryForShard("shard1")
+	if len(history) != 1 || history[0].Type != FailoverTypePlanned || history[0].Status != "success" || history[0].Reason != "planned_maintenance" {
+		t.Errorf("expected a successful planned failover in the history, got %+v", history)
+	}
+}
+
+func TestPlannedFailover_AbandonsBeforePromotion(t *testing.T) {
+	for _, c := range []struct {
+		failAt string
+		want   []string
+	}{
+		{"drain", []string{"drain", "resume"}},
+		{"demote", []string{"drain", "demote", "resume"}},
+		{"promote:postgres://db-2/orders", []string{"drain", "demote", "promote:postgres://db-2/orders", "resume"}},
+	} {
+		t.Run(c.failAt, func(t *testing.T) {
+			ctrl, cat, switchover := newPlannedController(t, c.failAt)
+
+			event, err := ctrl.PlannedFailover(context.Background(), PlannedFailoverRequest{ShardID: "shard1"})
+			if err == nil {
+				t.Fatal("expected the planned failover to fail")
+			}
+			if !reflect.DeepEqual(switchover.steps, c.want) {
+				t.Errorf("expected steps %v, got %v", c.want, switchover.steps)
+			}
+			if event.Status != "failed" || event.Type != FailoverTypePlanned {
+				t.Errorf("expected a failed planned failover, got %+v", event)
+			}
+			if shard, _ := cat.GetShardByID("shard1"); shard.PrimaryEndpoint != "postgres://db-1/orders" {
+				t.Errorf("expected the primary to stay, got %s", shard.PrimaryEndpoint)
+			}
+		})
+	}
+}
+
+func TestPlannedFailover_Validation(t *testing.T) {
+	c, _, switchover := newPlannedController(t, "")
+
+	if _, err := c.PlannedFailover(context.Background(), PlannedFailoverRequest{ShardID: "shard1", Replica: "postgres://db-9/orders"}); !errors.Is(err, ErrInvalidReplica) {
+		t.Errorf("expected an unknown replica to be refused, got %v", err)
+	}
+
+	c.beginFailover("shard1", FailoverTypeEmergency)
+	if _, err := c.PlannedFailover(context.Background(), PlannedFailoverRequest{ShardID: "shard1"}); !errors.Is(err, ErrFailoverInProgress) {
+		t.Errorf("expected a concurrent failover to be refused, got %v", err)
+	}
+	c.endFailover("shard1")
+
+	// Nor may an emergency failover run during a planned one
+	c.beginFailover("shard1", FailoverTypePlanned)
+	if err := c.performFailover(context.Background(), "shard1", "postgres://db-1/orders", "postgres://db-2/orders"); !errors.Is(err, ErrFailoverInProgress) {
+		t.Errorf("expected an emergency failover during a planned one to be refused, got %v", err)
+	}
+	c.endFailover("shard1")
+	if len(switchover.steps) != 0 {
+		t.Errorf("expected no steps for refused failovers, got %v", switchover.steps)
+	}
+
+	c.SetSwitchover(nil)
+	if _, err := c.PlannedFailover(context.Background(), PlannedFailoverRequest{ShardID: "shard1"}); !errors.Is(err, ErrSwitchoverUnsupported) {
+		t.Errorf("expected planned failover without a switchover to be refused, got %v", err)
+	}
+}
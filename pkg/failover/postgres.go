@@ -0,0 +1,295 @@
+package failover
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	_ "github.com/lib/pq"
+	"github.com/sharding-system/pkg/models"
+	"go.uber.org/zap"
+)
+
+// DefaultDrainTimeout bounds how long a planned failover waits for writes in
+// flight on the primary, and for the replica to catch up, before giving up
+const DefaultDrainTimeout = 30 * time.Second
+
+// PostgresSwitchover carries out planned failovers of PostgreSQL shards over
+// SQL. The primary is drained by making new transactions on the shard's
+// database read-only and waiting for open write transactions to finish. It is
+// then fenced: connections to the database are disallowed and the remaining
+// sessions terminated, which no session can undo, so nothing writes to it
+// once the replica is promoted. The replica is promoted with pg_promote once
+// it replayed the primary's last write, and the other replicas are pointed at
+// it through primary_conninfo. The old primary stays fenced until it is
+// rebuilt as a replica of the new one.
+type PostgresSwitchover struct {
+	logger       *zap.Logger
+	drainTimeout time.Duration
+	pollInterval time.Duration
+	driver       string
+
+	mu        sync.Mutex
+	databases map[string]string // Database of each shard being switched over
+}
+
+// NewPostgresSwitchover creates a switchover waiting up to drainTimeout for
+// writes to drain and the replica to catch up. Non-positive timeouts use
+// DefaultDrainTimeout.
+func NewPostgresSwitchover(logger *zap.Logger, drainTimeout time.Duration) *PostgresSwitchover {
+	if drainTimeout <= 0 {
+		drainTimeout = DefaultDrainTimeout
+	}
+	return &PostgresSwitchover{
+		logger:       logger,
+		drainTimeout: drainTimeout,
+		pollInterval: 200 * time.Millisecond,
+		driver:       "postgres",
+		databases:    make(map[string]string),
+	}
+}
+
+// open connects to an endpoint of a PostgreSQL shard
+func (p *PostgresSwitchover) open(ctx context.Context, shard *models.Shard, endpoint string) (*sql.DB, error) {
+	if models.NormalizeEngine(shard.Engine) != models.EnginePostgres {
+		return nil, fmt.Errorf("planned failover of %s shards is not supported", shard.Engine)
+	}
+	db, err := sql.Open(p.driver, endpoint)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1)
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to connect to %s: %w", models.EndpointLabel(endpoint), err)
+	}
+	return db, nil
+}
+
+// DrainWrites makes new transactions on the primary's database read-only and
+// waits for the write transactions already open to finish
+func (p *PostgresSwitchover) DrainWrites(ctx context.Context, shard *models.Shard) error {
+	db, err := p.open(ctx, shard, shard.PrimaryEndpoint)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	var database string
+	if err := db.QueryRowContext(ctx, `SELECT current_database()`).Scan(&database); err != nil {
+		return fmt.Errorf("failed to read the shard's database: %w", err)
+	}
+	p.mu.Lock()
+	p.databases[shard.ID] = database
+	p.mu.Unlock()
+
+	if _, err := db.ExecContext(ctx, `ALTER DATABASE `+quoteIdent(database)+` SET default_transaction_read_only = on`); err != nil {
+		return fmt.Errorf("failed to make new transactions read-only: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, p.drainTimeout)
+	defer cancel()
+	for {
+		var writers int
+		err := db.QueryRowContext(ctx,
+			`SELECT count(*) FROM pg_stat_activity WHERE backend_xid IS NOT NULL AND pid <> pg_backend_pid() AND datname = current_database()`).Scan(&writers)
+		if err != nil {
+			return fmt.Errorf("failed to check for writes in flight: %w", err)
+		}
+		if writers == 0 {
+			return nil
+		}
+		p.logger.Debug("waiting for writes in flight to finish",
+			zap.String("shard_id", shard.ID),
+			zap.Int("transactions", writers))
+
+		select {
+		case <-time.After(p.pollInterval):
+		case <-ctx.Done():
+			return fmt.Errorf("%d write transactions still open after %s: %w", writers, p.drainTimeout, ctx.Err())
+		}
+	}
+}
+
+// Demote fences the primary: connections to the shard's database are
+// disallowed and every session on it is terminated, so its clients reconnect
+// to the new primary and nothing can write to the old one
+func (p *PostgresSwitchover) Demote(ctx context.Context, shard *models.Shard) error {
+	database, err := p.database(shard)
+	if err != nil {
+		return err
+	}
+	db, err := p.open(ctx, shard, maintenanceEndpoint(shard.PrimaryEndpoint))
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if _, err := db.ExecContext(ctx, `ALTER DATABASE `+quoteIdent(database)+` ALLOW_CONNECTIONS false`); err != nil {
+		return fmt.Errorf("failed to disallow connections to the primary: %w", err)
+	}
+	_, err = db.ExecContext(ctx, `SELECT pg_terminate_backend(pid) FROM pg_stat_activity
+		WHERE pid <> pg_backend_pid() AND datname = $1`, database)
+	if err != nil {
+		return fmt.Errorf("failed to disconnect clients from the primary: %w", err)
+	}
+	return nil
+}
+
+// Promote waits for the replica to replay the primary's last write, then
+// promotes it
+func (p *PostgresSwitchover) Promote(ctx context.Context, shard *models.Shard, replica string) error {
+	// The shard's database is fenced; the WAL position is the server's
+	primaryDB, err := p.open(ctx, shard, maintenanceEndpoint(shard.PrimaryEndpoint))
+	if err != nil {
+		return err
+	}
+	defer primaryDB.Close()
+	var target string
+	if err := primaryDB.QueryRowContext(ctx, `SELECT pg_current_wal_lsn()::text`).Scan(&target); err != nil {
+		return fmt.Errorf("failed to read the primary's WAL position: %w", err)
+	}
+
+	replicaDB, err := p.open(ctx, shard, replica)
+	if err != nil {
+		return err
+	}
+	defer replicaDB.Close()
+
+	waitCtx, cancel := context.WithTimeout(ctx, p.drainTimeout)
+	defer cancel()
+	for {
+		var caughtUp bool
+		err := replicaDB.QueryRowContext(waitCtx,
+			`SELECT COALESCE(pg_wal_lsn_diff(pg_last_wal_replay_lsn(), $1::pg_lsn) >= 0, false)`, target).Scan(&caughtUp)
+		if err != nil {
+			return fmt.Errorf("failed to check the replica's replay position: %w", err)
+		}
+		if caughtUp {
+			break
+		}
+		select {
+		case <-time.After(p.pollInterval):
+		case <-waitCtx.Done():
+			return fmt.Errorf("replica did not replay the primary's WAL up to %s within %s: %w", target, p.drainTimeout, waitCtx.Err())
+		}
+	}
+
+	var promoted bool
+	if err := replicaDB.QueryRowContext(ctx, `SELECT pg_promote(true, $1)`, int(p.drainTimeout.Seconds())).Scan(&promoted); err != nil {
+		return fmt.Errorf("failed to promote the replica: %w", err)
+	}
+	if !promoted {
+		return fmt.Errorf("replica was not promoted within %s", p.drainTimeout)
+	}
+	return nil
+}
+
+// Reconfigure points the shard's other replicas at the new primary, which
+// they pick up on reload. The fenced old primary is left out: it has to be
+// rebuilt as a replica of the new primary.
+func (p *PostgresSwitchover) Reconfigure(ctx context.Context, shard *models.Shard, newPrimary string) error {
+	p.forget(shard.ID)
+
+	var failed []string
+	for _, follower := range shard.Replicas {
+		if follower == newPrimary {
+			continue
+		}
+		if err := p.follow(ctx, shard, follower, newPrimary); err != nil {
+			p.logger.Warn("failed to point replica at the new primary",
+				zap.String("shard_id", shard.ID),
+				zap.String("replica", models.EndpointLabel(follower)),
+				zap.Error(err))
+			failed = append(failed, models.EndpointLabel(follower))
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to reconfigure %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+func (p *PostgresSwitchover) follow(ctx context.Context, shard *models.Shard, follower, newPrimary string) error {
+	db, err := p.open(ctx, shard, follower)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	// ALTER SYSTEM takes no parameters; the connection string is quoted instead
+	if _, err := db.ExecContext(ctx, `ALTER SYSTEM SET primary_conninfo = `+quoteLiteral(newPrimary)); err != nil {
+		return err
+	}
+	_, err = db.ExecContext(ctx, `SELECT pg_reload_conf()`)
+	return err
+}
+
+// Resume lets the primary accept connections and writes again
+func (p *PostgresSwitchover) Resume(ctx context.Context, shard *models.Shard) error {
+	database, err := p.database(shard)
+	if err != nil {
+		return nil // Draining never got as far as changing the primary
+	}
+	defer p.forget(shard.ID)
+	db, err := p.open(ctx, shard, maintenanceEndpoint(shard.PrimaryEndpoint))
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if _, err := db.ExecContext(ctx, `ALTER DATABASE `+quoteIdent(database)+` ALLOW_CONNECTIONS true`); err != nil {
+		return fmt.Errorf("failed to allow connections to the primary again: %w", err)
+	}
+	if _, err := db.ExecContext(ctx, `ALTER DATABASE `+quoteIdent(database)+` RESET default_transaction_read_only`); err != nil {
+		return fmt.Errorf("failed to let the primary take writes again: %w", err)
+	}
+	return nil
+}
+
+// database returns the database of a shard that DrainWrites recorded
+func (p *PostgresSwitchover) database(shard *models.Shard) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	database, ok := p.databases[shard.ID]
+	if !ok {
+		return "", fmt.Errorf("writes on shard %s were not drained", shard.ID)
+	}
+	return database, nil
+}
+
+func (p *PostgresSwitchover) forget(shardID string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.databases, shardID)
+}
+
+// maintenanceEndpoint returns endpoint connecting to the postgres maintenance
+// database instead, which stays reachable while the shard's database is fenced
+func maintenanceEndpoint(endpoint string) string {
+	if strings.HasPrefix(endpoint, "postgres://") || strings.HasPrefix(endpoint, "postgresql://") {
+		u, err := url.Parse(endpoint)
+		if err != nil {
+			return endpoint
+		}
+		u.Path = "/postgres"
+		u.RawPath = ""
+		return u.String()
+	}
+	// In key=value connection strings the last setting wins
+	return endpoint + " dbname=postgres"
+}
+
+// quoteIdent quotes s as a SQL identifier
+func quoteIdent(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+}
+
+// quoteLiteral quotes s as a SQL string literal
+func quoteLiteral(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
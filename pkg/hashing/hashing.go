@@ -1,6 +1,8 @@
 package hashing
 
 import (
+	"hash/crc32"
+
 	"github.com/cespare/xxhash/v2"
 	"github.com/spaolacci/murmur3"
 )
@@ -10,13 +12,28 @@ type HashFunction interface {
 	Hash(key string) uint64
 }
 
+// KeyHash is the hash keys are placed on the ring with, so clients can
+// compute placement themselves. It is the first 64 bits (h1) of MurmurHash3
+// x64_128 over the key's UTF-8 bytes with seed 0. This must never change:
+// doing so moves every key to a different shard.
+func KeyHash(key string) uint64 {
+	h := murmur3.New64()
+	h.Write([]byte(key))
+	return h.Sum64()
+}
+
+// VNodeKey is the string hashed to place a shard's index-th virtual node on
+// the ring: the shard ID, "-vnode-", then the UTF-8 encoding of the code point
+// equal to index. Like KeyHash it must stay stable.
+func VNodeKey(shardID string, index int) string {
+	return shardID + "-vnode-" + string(rune(index))
+}
+
 // Murmur3Hash implements Murmur3 hash
 type Murmur3Hash struct{}
 
 func (m *Murmur3Hash) Hash(key string) uint64 {
-	h := murmur3.New64()
-	h.Write([]byte(key))
-	return h.Sum64()
+	return KeyHash(key)
 }
 
 // XXHash implements xxHash
@@ -26,12 +43,51 @@ func (x *XXHash) Hash(key string) uint64 {
 	return xxhash.Sum64String(key)
 }
 
+// CRC32Hash implements the IEEE CRC-32 checksum, for schemes migrated from
+// CRC32-based sharding. The checksum is returned in the high 32 bits so keys
+// spread over the same range as the 64-bit hashes.
+type CRC32Hash struct{}
+
+func (c *CRC32Hash) Hash(key string) uint64 {
+	return uint64(crc32.ChecksumIEEE([]byte(key))) << 32
+}
+
+// Supported hash function names
+const (
+	HashMurmur3 = "murmur3"
+	HashXXHash  = "xxhash"
+	HashCRC32   = "crc32"
+)
+
+// DefaultHashFunction is used when no hash function is configured
+const DefaultHashFunction = HashMurmur3
+
+// NormalizeHashFunction returns the hash function name used for name,
+// resolving the empty name to the default
+func NormalizeHashFunction(name string) string {
+	if name == "" {
+		return DefaultHashFunction
+	}
+	return name
+}
+
+// ValidHashFunction reports whether name is a supported hash function or empty
+func ValidHashFunction(name string) bool {
+	switch NormalizeHashFunction(name) {
+	case HashMurmur3, HashXXHash, HashCRC32:
+		return true
+	}
+	return false
+}
+
 // NewHashFunction creates a hash function based on name
 func NewHashFunction(name string) HashFunction {
 	switch name {
-	case "xxhash":
+	case HashXXHash:
 		return &XXHash{}
-	case "murmur3":
+	case HashCRC32:
+		return &CRC32Hash{}
+	case HashMurmur3:
 		fallthrough
 	default:
 		return &Murmur3Hash{}
@@ -61,8 +117,7 @@ func NewConsistentHash(hashFunc HashFunction) *ConsistentHash {
 // AddShard adds a shard with virtual nodes to the ring
 func (ch *ConsistentHash) AddShard(shardID string, vnodeCount int) {
 	for i := 0; i < vnodeCount; i++ {
-		vnodeKey := shardID + "-vnode-" + string(rune(i))
-		hash := ch.hashFunc.Hash(vnodeKey)
+		hash := ch.hashFunc.Hash(VNodeKey(shardID, i))
 		ch.vnodes = append(ch.vnodes, VNodeEntry{
 			Hash:    hash,
 			ShardID: shardID,
@@ -219,3 +219,84 @@ func BenchmarkConsistentHash_GetShard(b *testing.B) {
 	}
 }
 
+
+// TestKeyHash_ReferenceVector pins the placement hash. SDKs compute the same
+// values, so a failure here means routing changed for every client.
+func TestKeyHash_ReferenceVector(t *testing.T) {
+	vectors := map[string]uint64{
+		"":         0,
+		"user-123": 17358980885433774459,
+		"order-42": 13231622246981318862,
+		"héllo":    5634419923683204234,
+	}
+	for key, want := range vectors {
+		if got := KeyHash(key); got != want {
+			t.Errorf("KeyHash(%q) = %d, want %d", key, got, want)
+		}
+	}
+}
+
+func TestConsistentHash_ReferencePlacement(t *testing.T) {
+	ring := NewConsistentHash(&Murmur3Hash{})
+	for _, shardID := range []string{"shard-a", "shard-b", "shard-c"} {
+		ring.AddShard(shardID, 16)
+	}
+	reordered := NewConsistentHash(&Murmur3Hash{})
+	for _, shardID := range []string{"shard-c", "shard-a", "shard-b"} {
+		reordered.AddShard(shardID, 16)
+	}
+
+	placements := map[string]string{
+		"user-1":   "shard-b",
+		"user-4":   "shard-a",
+		"order-42": "shard-a",
+		"user-123": "shard-c",
+	}
+	for key, want := range placements {
+		if got := ring.GetShard(key); got != want {
+			t.Errorf("GetShard(%q) = %s, want %s", key, got, want)
+		}
+		if got := reordered.GetShard(key); got != want {
+			t.Errorf("expected placement to ignore the order shards were added in, %q went to %s", key, got)
+		}
+	}
+}
+
+func TestHashFunctions_DeterministicAndDistinct(t *testing.T) {
+	keys := []string{"user-1", "user-2", "order-42", "tenant-7", "héllo"}
+	placements := make(map[string]string)
+
+	for _, name := range []string{HashMurmur3, HashXXHash, HashCRC32} {
+		if !ValidHashFunction(name) {
+			t.Fatalf("expected %s to be valid", name)
+		}
+		ring := NewConsistentHash(NewHashFunction(name))
+		for _, shardID := range []string{"shard-a", "shard-b", "shard-c", "shard-d"} {
+			ring.AddShard(shardID, 32)
+		}
+
+		placement := ""
+		for _, key := range keys {
+			shard := ring.GetShard(key)
+			if again := ring.GetShard(key); again != shard {
+				t.Errorf("%s: expected %q to route deterministically, got %s then %s", name, key, shard, again)
+			}
+			placement += key + "=" + shard + " "
+		}
+		placements[name] = placement
+
+		if NewHashFunction(name).Hash("user-1") != NewHashFunction(name).Hash("user-1") {
+			t.Errorf("%s: expected a stable hash", name)
+		}
+	}
+
+	if placements[HashMurmur3] == placements[HashXXHash] || placements[HashMurmur3] == placements[HashCRC32] || placements[HashXXHash] == placements[HashCRC32] {
+		t.Errorf("expected each hash function to place keys differently, got %v", placements)
+	}
+	if ValidHashFunction("md5") {
+		t.Error("expected md5 to be rejected")
+	}
+	if NormalizeHashFunction("") != HashMurmur3 {
+		t.Error("expected murmur3 to be the default")
+	}
+}
@@ -0,0 +1,89 @@
+package health
+
+import (
+	"github.com/sharding-system/pkg/models"
+	"github.com/sharding-system/pkg/observability"
+	"go.uber.org/zap"
+)
+
+const (
+	// DefaultDownAfter is how many checks in a row a replica must fail before
+	// it is taken out of read routing
+	DefaultDownAfter = 3
+	// DefaultUpAfter is how many checks in a row an excluded replica must pass
+	// before reads are routed to it again
+	DefaultUpAfter = 2
+)
+
+// endpointState tracks the recent check results of an endpoint
+type endpointState struct {
+	failures  int  // Consecutive failed checks
+	successes int  // Consecutive passed checks
+	down      bool // Persistently unhealthy and excluded from routing
+}
+
+// SetEndpointThresholds sets how many checks in a row a replica must fail to
+// be excluded from read routing, and pass to be included again. A single
+// failed check is often a blip; requiring several keeps replicas from
+// flapping in and out of rotation.
+func (c *Controller) SetEndpointThresholds(downAfter, upAfter int) {
+	if downAfter < 1 {
+		downAfter = DefaultDownAfter
+	}
+	if upAfter < 1 {
+		upAfter = DefaultUpAfter
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.downAfter = downAfter
+	c.upAfter = upAfter
+}
+
+// EndpointDown reports whether an endpoint has failed enough health checks in
+// a row to be kept out of routing, and has not recovered since. Endpoints that
+// were never checked are not down.
+func (c *Controller) EndpointDown(endpoint string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	state, ok := c.endpoints[endpoint]
+	return ok && state.down
+}
+
+// recordEndpoint records a check result of a shard's replica and returns
+// whether the replica is excluded from routing. Must be called with c.mu held.
+func (c *Controller) recordEndpoint(shardID, endpoint string, up bool) bool {
+	state, ok := c.endpoints[endpoint]
+	if !ok {
+		state = &endpointState{}
+		c.endpoints[endpoint] = state
+	}
+
+	if up {
+		state.failures = 0
+		state.successes++
+		if state.down && state.successes >= c.upAfter {
+			state.down = false
+			c.logger.Info("replica recovered, routing reads to it again",
+				zap.String("shard_id", shardID),
+				zap.String("endpoint", models.EndpointLabel(endpoint)))
+		}
+	} else {
+		state.successes = 0
+		state.failures++
+		if !state.down && state.failures >= c.downAfter {
+			state.down = true
+			c.logger.Warn("replica persistently unhealthy, excluding it from read routing",
+				zap.String("shard_id", shardID),
+				zap.String("endpoint", models.EndpointLabel(endpoint)),
+				zap.Int("failed_checks", state.failures))
+		}
+	}
+
+	excluded := 0.0
+	if state.down {
+		excluded = 1
+	}
+	observability.ReplicaExcluded.WithLabelValues(shardID, models.EndpointLabel(endpoint)).Set(excluded)
+	return state.down
+}
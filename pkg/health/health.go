@@ -7,6 +7,7 @@ import (
 	"sync"
 	"time"
 
+	_ "github.com/go-sql-driver/mysql"
 	_ "github.com/lib/pq"
 	"github.com/sharding-system/pkg/catalog"
 	"github.com/sharding-system/pkg/models"
@@ -21,17 +22,26 @@ type Controller struct {
 	mu                      sync.RWMutex
 	checkInterval           time.Duration
 	replicationLagThreshold time.Duration
+	endpoints               map[string]*endpointState
+	downAfter               int
+	upAfter                 int
+	probe                   func(ctx context.Context, engine, endpoint string) bool
 }
 
 // NewController creates a new health controller
 func NewController(catalog catalog.Catalog, logger *zap.Logger, checkInterval, lagThreshold time.Duration) *Controller {
-	return &Controller{
+	c := &Controller{
 		catalog:                 catalog,
 		logger:                  logger,
 		healthStatus:            make(map[string]*models.ShardHealth),
 		checkInterval:           checkInterval,
 		replicationLagThreshold: lagThreshold,
+		endpoints:               make(map[string]*endpointState),
+		downAfter:               DefaultDownAfter,
+		upAfter:                 DefaultUpAfter,
 	}
+	c.probe = c.checkEndpoint
+	return c
 }
 
 // Start starts the health monitoring loop
@@ -77,7 +87,7 @@ func (c *Controller) checkShard(ctx context.Context, shard *models.Shard) {
 	}
 
 	// Check primary
-	if c.checkEndpoint(ctx, shard.PrimaryEndpoint) {
+	if c.probe(ctx, shard.Engine, shard.PrimaryEndpoint) {
 		health.PrimaryUp = true
 	} else {
 		health.Status = "unhealthy"
@@ -88,8 +98,10 @@ func (c *Controller) checkShard(ctx context.Context, shard *models.Shard) {
 	}
 
 	// Check replicas
-	for _, replicaEndpoint := range shard.Replicas {
-		if c.checkEndpoint(ctx, replicaEndpoint) {
+	replicasUp := make([]bool, len(shard.Replicas))
+	for i, replicaEndpoint := range shard.Replicas {
+		replicasUp[i] = c.probe(ctx, shard.Engine, replicaEndpoint)
+		if replicasUp[i] {
 			health.ReplicasUp = append(health.ReplicasUp, replicaEndpoint)
 		} else {
 			health.ReplicasDown = append(health.ReplicasDown, replicaEndpoint)
@@ -112,13 +124,23 @@ func (c *Controller) checkShard(ctx context.Context, shard *models.Shard) {
 	}
 
 	c.mu.Lock()
+	// Replicas failing check after check are kept out of read routing until they recover
+	for i, replicaEndpoint := range shard.Replicas {
+		if c.recordEndpoint(shard.ID, replicaEndpoint, replicasUp[i]) {
+			health.ReplicasExcluded = append(health.ReplicasExcluded, replicaEndpoint)
+		}
+	}
 	c.healthStatus[shard.ID] = health
 	c.mu.Unlock()
 }
 
-// checkEndpoint checks if an endpoint is reachable
-func (c *Controller) checkEndpoint(ctx context.Context, endpoint string) bool {
-	db, err := sql.Open("postgres", endpoint)
+// checkEndpoint checks if an endpoint of a shard running on engine is reachable
+func (c *Controller) checkEndpoint(ctx context.Context, engine, endpoint string) bool {
+	driverName, err := models.DriverName(engine)
+	if err != nil {
+		return false
+	}
+	db, err := sql.Open(driverName, endpoint)
 	if err != nil {
 		return false
 	}
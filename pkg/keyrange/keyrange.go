@@ -0,0 +1,197 @@
+package keyrange
+
+import (
+	"fmt"
+	"sync"
+	"unicode/utf8"
+
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
+)
+
+// Supported collations for ordering string shard keys
+const (
+	// CollationBinary orders keys by their UTF-8 bytes, which is code point
+	// order and matches PostgreSQL's "C" collation
+	CollationBinary = "binary"
+	// CollationUnicode orders keys by the Unicode Collation Algorithm's root
+	// order, so accented and differently cased letters sort next to each other
+	CollationUnicode = "unicode"
+)
+
+// DefaultCollation is used when no collation is configured
+const DefaultCollation = CollationBinary
+
+// NormalizeCollation returns the collation used for name, resolving the empty
+// name to the default
+func NormalizeCollation(name string) string {
+	if name == "" {
+		return DefaultCollation
+	}
+	return name
+}
+
+// ValidCollation reports whether name is a supported collation or empty
+func ValidCollation(name string) bool {
+	switch NormalizeCollation(name) {
+	case CollationBinary, CollationUnicode:
+		return true
+	}
+	return false
+}
+
+var (
+	unicodeMu       sync.Mutex
+	unicodeCollator *collate.Collator
+)
+
+// Compare orders a and b under collation, returning -1, 0 or 1
+func Compare(collation, a, b string) int {
+	if NormalizeCollation(collation) != CollationUnicode {
+		switch {
+		case a < b:
+			return -1
+		case a > b:
+			return 1
+		}
+		return 0
+	}
+
+	// Collators keep internal buffers, so they are not safe for concurrent use
+	unicodeMu.Lock()
+	defer unicodeMu.Unlock()
+	if unicodeCollator == nil {
+		unicodeCollator = collate.New(language.Und)
+	}
+	if c := unicodeCollator.CompareString(a, b); c != 0 {
+		return c
+	}
+	// Break ties between keys the collation considers equal so every key has
+	// exactly one place
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// Contains reports whether key falls in the range [start, end) under
+// collation. An empty start is unbounded below, so it holds the empty key,
+// and an empty end is unbounded above.
+func Contains(collation, start, end, key string) bool {
+	if start != "" && Compare(collation, key, start) < 0 {
+		return false
+	}
+	return end == "" || Compare(collation, key, end) < 0
+}
+
+// Validate checks that [start, end) is a non-empty range under collation
+func Validate(collation, start, end string) error {
+	if !ValidCollation(collation) {
+		return fmt.Errorf("invalid collation %q: must be binary or unicode", collation)
+	}
+	if !utf8.ValidString(start) || !utf8.ValidString(end) {
+		return fmt.Errorf("key range bounds must be valid UTF-8")
+	}
+	if start != "" && end != "" && Compare(collation, start, end) >= 0 {
+		return fmt.Errorf("key range start %q must sort before end %q", start, end)
+	}
+	return nil
+}
+
+// Overlaps reports whether the ranges [aStart, aEnd) and [bStart, bEnd)
+// share any key under collation
+func Overlaps(collation, aStart, aEnd, bStart, bEnd string) bool {
+	if aEnd != "" && bStart != "" && Compare(collation, aEnd, bStart) <= 0 {
+		return false
+	}
+	if bEnd != "" && aStart != "" && Compare(collation, bEnd, aStart) <= 0 {
+		return false
+	}
+	return true
+}
+
+// SplitPoint picks a key strictly inside [start, end) to split the range at,
+// roughly halfway between the bounds by code point. Keys with no upper bound
+// are assumed to be printable ASCII, so a whole range splits at "O".
+func SplitPoint(collation, start, end string) (string, error) {
+	if err := Validate(collation, start, end); err != nil {
+		return "", err
+	}
+
+	point := string(midpoint([]rune(start), []rune(end), end == ""))
+	if Compare(collation, point, start) <= 0 || (end != "" && Compare(collation, point, end) >= 0) {
+		return "", fmt.Errorf("no split point found between %q and %q under %s collation; pass an explicit split key",
+			start, end, NormalizeCollation(collation))
+	}
+	return point, nil
+}
+
+// printableEnd and printableStart bound the code points assumed for keys
+// past the end of a bound
+const (
+	printableStart = ' '
+	printableEnd   = 0x7F
+)
+
+// midpoint returns a code point sequence strictly between lo and hi, treating
+// the bounds as digits of a fraction. An unbounded hi is above every key.
+func midpoint(lo, hi []rune, unbounded bool) []rune {
+	for i := 0; ; i++ {
+		if !unbounded && i >= len(lo) && i >= len(hi) {
+			// hi is lo followed by NULs, leaving nothing in between
+			return nil
+		}
+		var l, h rune
+		if i < len(lo) {
+			l = lo[i]
+		}
+		switch {
+		case unbounded:
+			h = utf8.MaxRune + 1
+			if l < printableEnd-1 {
+				h = printableEnd
+			}
+		case i < len(hi):
+			h = hi[i]
+		}
+		if i >= len(lo) && h > printableStart+1 {
+			// Past the end of lo any digit will do, so stay printable
+			l = printableStart
+		}
+
+		if l == h {
+			continue
+		}
+		prefix := append([]rune{}, lo[:min(i, len(lo))]...)
+		for len(prefix) < i {
+			prefix = append(prefix, hi[len(prefix)])
+		}
+		if h-l >= 2 {
+			return append(prefix, validRune(l+(h-l)/2, l, h))
+		}
+		// Adjacent digits: keep lo's digit and find anything above the rest of lo
+		var rest []rune
+		if i < len(lo) {
+			rest = lo[i+1:]
+		}
+		return append(append(prefix, l), midpoint(rest, nil, true)...)
+	}
+}
+
+// validRune moves r out of the surrogate range while keeping it strictly
+// between l and h
+func validRune(r, l, h rune) rune {
+	if r < 0xD800 || r > 0xDFFF {
+		return r
+	}
+	if l < 0xD7FF {
+		return 0xD7FF
+	}
+	if h > 0xE000 {
+		return 0xE000
+	}
+	return r
+}
@@ -0,0 +1,139 @@
+package keyrange
+
+import (
+	"testing"
+	"unicode/utf8"
+)
+
+func TestContains_Boundaries(t *testing.T) {
+	tests := []struct {
+		start, end, key string
+		want            bool
+	}{
+		{"A", "N", "A", true},    // start is inclusive
+		{"A", "N", "N", false},   // end is exclusive
+		{"A", "N", "Mzzz", true}, // just below end
+		{"N", "", "N", true},
+		{"N", "", "zzz", true}, // empty end is unbounded
+		{"A", "N", "", false},
+		{"", "N", "", true}, // empty start holds the empty key
+		{"", "", "", true},
+		{"", "", "anything", true},
+		{"a", "b", "a\x00", true},
+		{"A", "N", "a", false}, // lowercase sorts after uppercase bytewise
+	}
+
+	for _, tt := range tests {
+		if got := Contains(CollationBinary, tt.start, tt.end, tt.key); got != tt.want {
+			t.Errorf("Contains([%q, %q), %q) = %v, want %v", tt.start, tt.end, tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestCompare_UnicodeOrdering(t *testing.T) {
+	// Bytewise, every accented letter sorts after "z"
+	if Compare(CollationBinary, "é", "z") <= 0 {
+		t.Error("expected é after z under binary collation")
+	}
+	if Compare(CollationUnicode, "é", "z") >= 0 {
+		t.Error("expected é before z under unicode collation")
+	}
+	if Compare(CollationUnicode, "e", "é") >= 0 || Compare(CollationUnicode, "é", "f") >= 0 {
+		t.Error("expected é between e and f under unicode collation")
+	}
+
+	// Under unicode collation "éclair" lands in the A-M range, not after it
+	if !Contains(CollationUnicode, "A", "N", "éclair") {
+		t.Error("expected éclair in [A, N) under unicode collation")
+	}
+	if Contains(CollationBinary, "A", "N", "éclair") {
+		t.Error("expected éclair outside [A, N) under binary collation")
+	}
+
+	// Keys are never equal unless identical, so each has one place
+	if Compare(CollationUnicode, "a", "a") != 0 {
+		t.Error("expected identical keys to compare equal")
+	}
+	if Compare(CollationUnicode, "\u00e9", "e\u0301") == 0 {
+		t.Error("expected differently encoded keys to be ordered")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	valid := [][3]string{
+		{"", "A", "N"},
+		{"", "", ""},
+		{"unicode", "a", "B"},
+		{"binary", "N", ""},
+	}
+	for _, v := range valid {
+		if err := Validate(v[0], v[1], v[2]); err != nil {
+			t.Errorf("Validate(%q, %q, %q) unexpected error: %v", v[0], v[1], v[2], err)
+		}
+	}
+
+	invalid := [][3]string{
+		{"", "N", "A"},
+		{"", "A", "A"},
+		{"binary", "a", "B"},
+		{"latin1", "A", "N"},
+		{"", "\xff", "z"},
+	}
+	for _, v := range invalid {
+		if err := Validate(v[0], v[1], v[2]); err == nil {
+			t.Errorf("Validate(%q, %q, %q) expected an error", v[0], v[1], v[2])
+		}
+	}
+}
+
+func TestOverlaps(t *testing.T) {
+	if Overlaps(CollationBinary, "A", "N", "N", "") {
+		t.Error("expected adjacent ranges not to overlap")
+	}
+	if !Overlaps(CollationBinary, "A", "N", "M", "") {
+		t.Error("expected [A, N) and [M, ∞) to overlap")
+	}
+	if !Overlaps(CollationBinary, "", "", "A", "B") {
+		t.Error("expected the whole key space to overlap any range")
+	}
+}
+
+func TestSplitPoint(t *testing.T) {
+	tests := []struct {
+		collation, start, end string
+		want                  string
+	}{
+		{CollationBinary, "A", "N", "G"},
+		{CollationBinary, "", "", "O"},
+		{CollationBinary, "a", "b", "aO"},
+		{CollationBinary, "user-100", "user-200", "user-1W"},
+	}
+	for _, tt := range tests {
+		got, err := SplitPoint(tt.collation, tt.start, tt.end)
+		if err != nil {
+			t.Fatalf("SplitPoint(%q, %q) unexpected error: %v", tt.start, tt.end, err)
+		}
+		if got != tt.want {
+			t.Errorf("SplitPoint(%q, %q) = %q, want %q", tt.start, tt.end, got, tt.want)
+		}
+	}
+
+	// Whatever the bounds, the point is valid UTF-8 and strictly inside them
+	bounds := [][2]string{{"", "\x01"}, {"z", ""}, {"\U0010FFFE", ""}, {"日本", "日本語"}, {"퟾", ""}}
+	for _, b := range bounds {
+		got, err := SplitPoint(CollationBinary, b[0], b[1])
+		if err != nil {
+			t.Fatalf("SplitPoint(%q, %q) unexpected error: %v", b[0], b[1], err)
+		}
+		if !utf8.ValidString(got) || Compare(CollationBinary, got, b[0]) <= 0 || !Contains(CollationBinary, b[0], b[1], got) {
+			t.Errorf("SplitPoint(%q, %q) = %q is not strictly inside the range", b[0], b[1], got)
+		}
+	}
+
+	if _, err := SplitPoint(CollationBinary, "a", "a\x00"); err == nil {
+		t.Error("expected an error when no key fits between the bounds")
+	}
+	if _, err := SplitPoint(CollationBinary, "N", "A"); err == nil {
+		t.Error("expected an error for an inverted range")
+	}
+}
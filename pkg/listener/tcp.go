@@ -0,0 +1,32 @@
+package listener
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+)
+
+// TCPOptions tunes a TCP listener and the connections it accepts
+type TCPOptions struct {
+	// KeepAlive is the keepalive probe interval on accepted connections. Zero
+	// keeps Go's default of 15s and a negative value disables keepalive.
+	KeepAlive time.Duration
+}
+
+// TCP listens on a TCP address with opts applied. Go already sets
+// SO_REUSEADDR on Unix listeners, so a restarted server can bind while
+// connections from its previous run linger in TIME_WAIT.
+func TCP(addr string, opts TCPOptions) (net.Listener, error) {
+	lc := net.ListenConfig{KeepAlive: opts.KeepAlive}
+	if opts.KeepAlive > 0 {
+		// Probe at the configured interval both before and after the first probe
+		lc.KeepAliveConfig = net.KeepAliveConfig{Enable: true, Idle: opts.KeepAlive, Interval: opts.KeepAlive}
+	}
+
+	l, err := lc.Listen(context.Background(), "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+	return l, nil
+}
@@ -0,0 +1,76 @@
+package listener
+
+import (
+	"net"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// sockopt reads an integer socket option from a listener or connection
+func sockopt(t *testing.T, conn syscall.Conn, level, opt int) int {
+	t.Helper()
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		t.Fatalf("failed to get raw connection: %v", err)
+	}
+	var value int
+	var sockErr error
+	if err := raw.Control(func(fd uintptr) {
+		value, sockErr = syscall.GetsockoptInt(int(fd), level, opt)
+	}); err != nil {
+		t.Fatalf("failed to control socket: %v", err)
+	}
+	if sockErr != nil {
+		t.Fatalf("failed to read socket option: %v", sockErr)
+	}
+	return value
+}
+
+// accept dials l and returns the connection it accepts
+func accept(t *testing.T, l net.Listener) *net.TCPConn {
+	t.Helper()
+	client, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+
+	conn, err := l.Accept()
+	if err != nil {
+		t.Fatalf("failed to accept: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn.(*net.TCPConn)
+}
+
+func TestTCP_AppliesKeepAlive(t *testing.T) {
+	l, err := TCP("127.0.0.1:0", TCPOptions{KeepAlive: 42 * time.Second})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer l.Close()
+
+	conn := accept(t, l)
+	if got := sockopt(t, conn, syscall.SOL_SOCKET, syscall.SO_KEEPALIVE); got == 0 {
+		t.Error("expected keepalive on accepted connections")
+	}
+	if got := sockopt(t, conn, syscall.IPPROTO_TCP, syscall.TCP_KEEPIDLE); got != 42 {
+		t.Errorf("expected keepalive probes after 42s idle, got %ds", got)
+	}
+	if got := sockopt(t, conn, syscall.IPPROTO_TCP, syscall.TCP_KEEPINTVL); got != 42 {
+		t.Errorf("expected a 42s keepalive interval, got %ds", got)
+	}
+}
+
+func TestTCP_NegativeKeepAliveDisablesKeepAlive(t *testing.T) {
+	l, err := TCP("127.0.0.1:0", TCPOptions{KeepAlive: -1})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer l.Close()
+
+	if got := sockopt(t, accept(t, l), syscall.SOL_SOCKET, syscall.SO_KEEPALIVE); got != 0 {
+		t.Error("expected keepalive to be disabled")
+	}
+}
@@ -0,0 +1,32 @@
+package listener
+
+import (
+	"fmt"
+	"net"
+	"os"
+)
+
+// Unix listens on a Unix domain socket at path. A socket file left behind by
+// a process that did not shut down cleanly is removed first; any other file at
+// path is left alone and the listen fails. Closing the listener removes the
+// socket file.
+func Unix(path string) (net.Listener, error) {
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and is not a socket", path)
+		}
+		if conn, err := net.Dial("unix", path); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("socket %s is in use by another process", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("failed to remove stale socket %s: %w", path, err)
+		}
+	}
+
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", path, err)
+	}
+	return l, nil
+}
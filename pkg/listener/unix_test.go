@@ -0,0 +1,82 @@
+package listener
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestUnix_ServesAndRemovesSocketOnClose(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.sock")
+	l, err := Unix(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		conn.Write([]byte("ok"))
+		conn.Close()
+	}()
+
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatalf("failed to connect over the socket: %v", err)
+	}
+	buf := make([]byte, 2)
+	if _, err := conn.Read(buf); err != nil || string(buf) != "ok" {
+		t.Errorf("expected to read ok, got %q (%v)", buf, err)
+	}
+	conn.Close()
+
+	l.Close()
+	if _, err := os.Lstat(path); !os.IsNotExist(err) {
+		t.Errorf("expected the socket file to be removed on close, got %v", err)
+	}
+}
+
+func TestUnix_ReplacesStaleSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.sock")
+
+	// A listener whose file outlives it, as after a crash
+	stale, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatalf("failed to create socket: %v", err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	l, err := Unix(path)
+	if err != nil {
+		t.Fatalf("expected the stale socket to be replaced, got %v", err)
+	}
+	l.Close()
+}
+
+func TestUnix_RefusesLiveSocketAndOtherFiles(t *testing.T) {
+	dir := t.TempDir()
+
+	live, err := Unix(filepath.Join(dir, "live.sock"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer live.Close()
+	if _, err := Unix(filepath.Join(dir, "live.sock")); err == nil {
+		t.Error("expected a socket in use to be refused")
+	}
+
+	regular := filepath.Join(dir, "config.json")
+	if err := os.WriteFile(regular, []byte("{}"), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	if _, err := Unix(regular); err == nil {
+		t.Error("expected a regular file to be refused")
+	}
+	if _, err := os.Stat(regular); err != nil {
+		t.Errorf("expected the regular file to be left alone, got %v", err)
+	}
+}
@@ -172,6 +172,26 @@ const (
 	RequestIDKey contextKey = "request_id"
 )
 
+// ContextWithRequestID returns a copy of ctx carrying requestID
+func ContextWithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, RequestIDKey, requestID)
+}
+
+// RequestIDFromContext returns the request ID carried by ctx, or "" if there is none
+func RequestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(RequestIDKey).(string)
+	return requestID
+}
+
+// WithRequestID returns logger annotated with the request ID carried by ctx,
+// so log lines from every component handling a request can be correlated
+func WithRequestID(ctx context.Context, logger *zap.Logger) *zap.Logger {
+	if requestID := RequestIDFromContext(ctx); requestID != "" {
+		return logger.With(zap.String("request_id", requestID))
+	}
+	return logger
+}
+
 // LokiExporter exports logs to Grafana Loki
 type LokiExporter struct {
 	endpoint      string
@@ -0,0 +1,64 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/sharding-system/pkg/models"
+	"go.uber.org/zap"
+)
+
+// ErrClientAppHasShards is returned when deleting a client app that still owns
+// shards without cascading the deletion to them
+var ErrClientAppHasShards = errors.New("client application still owns shards")
+
+// DeleteClientApp deletes a client application. While the app owns shards the
+// deletion is refused with ErrClientAppHasShards unless cascade is set, in
+// which case each shard is drained (marked inactive so no new traffic is
+// routed to it) and deleted before the app. A cascade is refused while any of
+// the shards is being resharded. It returns the IDs of the deleted shards so
+// the caller can stop collecting from them, including when a later shard
+// fails to delete.
+func (m *Manager) DeleteClientApp(clientAppID string, cascade bool) ([]string, error) {
+	if _, err := m.clientAppMgr.GetClientApp(clientAppID); err != nil {
+		return nil, err
+	}
+
+	shards, err := m.ListShardsForClient(clientAppID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list shards for client application: %w", err)
+	}
+
+	if len(shards) > 0 && !cascade {
+		return nil, fmt.Errorf("%w: %s has %d shards, delete them first or cascade the deletion",
+			ErrClientAppHasShards, clientAppID, len(shards))
+	}
+	for _, shard := range shards {
+		if shard.Status == "migrating" {
+			return nil, fmt.Errorf("cannot delete client application %s: shard %s is being resharded", clientAppID, shard.ID)
+		}
+	}
+
+	deleted := make([]string, 0, len(shards))
+	for i := range shards {
+		shard := &shards[i]
+		// Shards still being provisioned serve no traffic and go straight to deletion
+		if shard.Status != models.ShardStatusInactive && models.ValidateShardTransition(shard.Status, models.ShardStatusInactive) == nil {
+			if err := m.UpdateShardStatus(shard.ID, models.ShardStatusInactive); err != nil {
+				return deleted, fmt.Errorf("failed to drain shard %s: %w", shard.ID, err)
+			}
+		}
+		if err := m.DeleteShard(shard.ID); err != nil {
+			return deleted, fmt.Errorf("failed to delete shard %s: %w", shard.ID, err)
+		}
+		deleted = append(deleted, shard.ID)
+		m.logger.Info("deleted shard of client application",
+			zap.String("client_app_id", clientAppID),
+			zap.String("shard_id", shard.ID))
+	}
+
+	if err := m.clientAppMgr.DeleteClientApp(clientAppID); err != nil {
+		return deleted, err
+	}
+	return deleted, nil
+}
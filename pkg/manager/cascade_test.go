@@ -0,0 +1,78 @@
+package manager
+
+import (
+	"errors"
+	"sort"
+	"testing"
+
+	"github.com/sharding-system/pkg/catalog"
+)
+
+func TestManager_DeleteClientApp_RefusesWithShards(t *testing.T) {
+	manager, catalog := newTierTestManager(t, "pro", 2)
+
+	deleted, err := manager.DeleteClientApp("app1", false)
+	if !errors.Is(err, ErrClientAppHasShards) {
+		t.Fatalf("expected ErrClientAppHasShards, got %v", err)
+	}
+	if len(deleted) != 0 {
+		t.Errorf("expected no shards to be deleted, got %v", deleted)
+	}
+	if _, err := manager.clientAppMgr.GetClientApp("app1"); err != nil {
+		t.Errorf("expected the client app to be kept: %v", err)
+	}
+	if len(catalog.shards) != 2 || catalog.shards["shard0"].Status != "active" {
+		t.Errorf("expected the shards to be left untouched, got %d shards", len(catalog.shards))
+	}
+}
+
+func TestManager_DeleteClientApp_WithoutShards(t *testing.T) {
+	manager, _ := newTierTestManager(t, "pro", 0)
+
+	if _, err := manager.DeleteClientApp("app1", false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := manager.clientAppMgr.GetClientApp("app1"); err == nil {
+		t.Error("expected the client app to be deleted")
+	}
+	if _, err := manager.DeleteClientApp("app1", false); err == nil {
+		t.Error("expected an unknown client app to be rejected")
+	}
+}
+
+func TestManager_DeleteClientApp_Cascade(t *testing.T) {
+	manager, cat := newTierTestManager(t, "pro", 2)
+
+	deleted, err := manager.DeleteClientApp("app1", true)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	sort.Strings(deleted)
+	if len(deleted) != 2 || deleted[0] != "shard0" || deleted[1] != "shard1" {
+		t.Errorf("expected shard0 and shard1 to be deleted, got %v", deleted)
+	}
+	if len(cat.shards) != 0 {
+		t.Errorf("expected the shards to be removed from the catalog, %d left", len(cat.shards))
+	}
+	if _, err := manager.clientAppMgr.GetClientApp("app1"); err == nil {
+		t.Error("expected the client app to be deleted")
+	}
+
+	// The shards go through the lifecycle, so their timelines record the deletion
+	events := waitForShardEvents(t, manager, "shard0", 2)
+	if len(events) != 2 || events[0].Type != catalog.ShardEventStatusChanged || events[1].Type != catalog.ShardEventDeleted {
+		t.Errorf("expected the shard to be drained and deleted, got %+v", events)
+	}
+}
+
+func TestManager_DeleteClientApp_CascadeRefusedWhileResharding(t *testing.T) {
+	manager, catalog := newTierTestManager(t, "pro", 2)
+	catalog.shards["shard1"].Status = "migrating"
+
+	if _, err := manager.DeleteClientApp("app1", true); err == nil {
+		t.Fatal("expected the cascade to be refused while a shard is being resharded")
+	}
+	if len(catalog.shards) != 2 || catalog.shards["shard0"].Status != "active" {
+		t.Error("expected no shard to be drained or deleted")
+	}
+}
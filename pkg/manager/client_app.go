@@ -10,6 +10,8 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/sharding-system/pkg/catalog"
+	"github.com/sharding-system/pkg/hashing"
+	"github.com/sharding-system/pkg/keyrange"
 	"github.com/sharding-system/pkg/validation"
 	clientv3 "go.etcd.io/etcd/client/v3"
 	"go.uber.org/zap"
@@ -21,7 +23,8 @@ type ClientAppManager struct {
 	logger     *zap.Logger
 	mu         sync.RWMutex
 	clientApps map[string]*ClientAppInfo
-	etcdClient *clientv3.Client // optional etcd client for persistence
+	etcdClient *clientv3.Client    // optional etcd client for persistence
+	namespaces *NamespaceValidator // optional check of the app's namespace and cluster
 }
 
 // ClientAppInfo tracks information about a client application
@@ -46,6 +49,42 @@ type ClientAppInfo struct {
 	RequestCount int64 `json:"request_count"`
 	// Client identifier pattern (e.g., "app1:", "app2:")
 	KeyPrefix string `json:"key_prefix,omitempty"`
+	// Defaults applied to shards created for this app
+	ShardingDefaults ShardingDefaults `json:"sharding_defaults"`
+	// Pricing tier of the app; empty means the manager's configured tier
+	Tier string `json:"tier,omitempty"`
+}
+
+// ShardingDefaults declares how shards are created for a client application
+// unless a shard request overrides them
+type ShardingDefaults struct {
+	Strategy   string `json:"strategy,omitempty"`    // "hash" or "range"
+	ShardKey   string `json:"shard_key,omitempty"`   // Key used to route rows to shards
+	ShardCount int    `json:"shard_count,omitempty"` // Planned number of shards; the vnode space is divided between them
+	// HashFunction places keys on the app's shards: "murmur3" (default), "xxhash" or "crc32".
+	// Once the app has shards, new shards keep the existing function until the data is resharded.
+	HashFunction string `json:"hash_function,omitempty"`
+	// Collation orders string keys of range-strategy shards: "binary" (default) or "unicode"
+	Collation string `json:"collation,omitempty"`
+}
+
+// Validate checks that the defaults are usable
+func (d ShardingDefaults) Validate() error {
+	switch d.Strategy {
+	case "", "hash", "range":
+	default:
+		return fmt.Errorf("invalid sharding strategy %q: must be hash or range", d.Strategy)
+	}
+	if d.ShardCount < 0 {
+		return fmt.Errorf("shard_count must not be negative")
+	}
+	if !hashing.ValidHashFunction(d.HashFunction) {
+		return fmt.Errorf("invalid hash function %q: must be murmur3, xxhash or crc32", d.HashFunction)
+	}
+	if !keyrange.ValidCollation(d.Collation) {
+		return fmt.Errorf("invalid collation %q: must be binary or unicode", d.Collation)
+	}
+	return nil
 }
 
 // NewClientAppManager creates a new client application manager
@@ -66,8 +105,25 @@ func NewClientAppManager(catalogInst catalog.Catalog, logger *zap.Logger) *Clien
 	return mgr
 }
 
+// SetNamespaceValidator enables checking the namespace and cluster of newly registered apps
+func (m *ClientAppManager) SetNamespaceValidator(v *NamespaceValidator) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.namespaces = v
+}
+
 // RegisterClientApp registers a new client application
 func (m *ClientAppManager) RegisterClientApp(ctx context.Context, name, description, databaseName, databaseHost, databasePort, databaseUser, databasePassword, keyPrefix, namespace, clusterName string) (*ClientAppInfo, error) {
+	// Check the namespace before taking the lock, it calls the Kubernetes API
+	m.mu.RLock()
+	namespaces := m.namespaces
+	m.mu.RUnlock()
+	if namespaces != nil {
+		if err := namespaces.Validate(ctx, name, namespace, clusterName); err != nil {
+			return nil, err
+		}
+	}
+
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
@@ -231,6 +287,69 @@ func (m *ClientAppManager) GetClientApp(id string) (*ClientAppInfo, error) {
 	return app, nil
 }
 
+// SetShardingDefaults sets the sharding defaults of a client application
+func (m *ClientAppManager) SetShardingDefaults(id string, defaults ShardingDefaults) error {
+	if err := defaults.Validate(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	app, exists := m.clientApps[id]
+	if !exists {
+		return fmt.Errorf("client application not found: %s", id)
+	}
+
+	previous := hashing.NormalizeHashFunction(app.ShardingDefaults.HashFunction)
+	if next := hashing.NormalizeHashFunction(defaults.HashFunction); next != previous && m.catalog != nil {
+		if shards, err := m.catalog.ListShards(id); err == nil && len(shards) > 0 {
+			m.logger.Warn("hash function changed for client app with existing shards; its keys stay placed with the old function until they are resharded",
+				zap.String("id", id),
+				zap.String("from", previous),
+				zap.String("to", next),
+				zap.Int("shard_count", len(shards)))
+		}
+	}
+
+	app.ShardingDefaults = defaults
+	app.UpdatedAt = time.Now()
+	if m.etcdClient != nil {
+		if err := m.persistClientApp(app); err != nil {
+			return fmt.Errorf("failed to persist client app: %w", err)
+		}
+	}
+	m.logger.Info("updated client app sharding defaults",
+		zap.String("id", id),
+		zap.String("strategy", defaults.Strategy),
+		zap.String("shard_key", defaults.ShardKey),
+		zap.Int("shard_count", defaults.ShardCount),
+		zap.String("hash_function", defaults.HashFunction),
+		zap.String("collation", defaults.Collation))
+
+	return nil
+}
+
+// SetTier sets the pricing tier of a client application
+func (m *ClientAppManager) SetTier(id string, tier string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	app, exists := m.clientApps[id]
+	if !exists {
+		return fmt.Errorf("client application not found: %s", id)
+	}
+
+	app.Tier = tier
+	app.UpdatedAt = time.Now()
+	if m.etcdClient != nil {
+		if err := m.persistClientApp(app); err != nil {
+			return fmt.Errorf("failed to persist client app: %w", err)
+		}
+	}
+	return nil
+}
+
 // UpdateClientAppStatus updates the status of a client application
 func (m *ClientAppManager) UpdateClientAppStatus(id string, status string) error {
 	m.mu.Lock()
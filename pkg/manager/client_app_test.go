@@ -0,0 +1,143 @@
+package manager
+
+import (
+	"testing"
+
+	"github.com/sharding-system/pkg/hashing"
+	"github.com/sharding-system/pkg/models"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestShardingDefaults_Validate(t *testing.T) {
+	valid := []ShardingDefaults{
+		{},
+		{Strategy: "hash", ShardKey: "user_id", ShardCount: 4},
+		{Strategy: "range", ShardKey: "created_at"},
+		{Strategy: "hash", HashFunction: "xxhash"},
+	}
+	for _, d := range valid {
+		if err := d.Validate(); err != nil {
+			t.Errorf("Expected %+v to be valid, got %v", d, err)
+		}
+	}
+
+	invalid := []ShardingDefaults{
+		{Strategy: "directory"},
+		{Strategy: "hash", ShardCount: -1},
+		{Strategy: "hash", HashFunction: "md5"},
+	}
+	for _, d := range invalid {
+		if err := d.Validate(); err == nil {
+			t.Errorf("Expected %+v to be invalid", d)
+		}
+	}
+}
+
+func TestClientAppManager_SetShardingDefaults(t *testing.T) {
+	mgr := NewClientAppManager(NewMockCatalog(), zaptest.NewLogger(t))
+	mgr.clientApps["app1"] = &ClientAppInfo{ID: "app1", Name: "orders"}
+
+	defaults := ShardingDefaults{Strategy: "hash", ShardKey: "order_id", ShardCount: 4}
+	if err := mgr.SetShardingDefaults("app1", defaults); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	app, _ := mgr.GetClientApp("app1")
+	if app.ShardingDefaults != defaults {
+		t.Errorf("Expected defaults %+v, got %+v", defaults, app.ShardingDefaults)
+	}
+
+	if err := mgr.SetShardingDefaults("missing", defaults); err == nil {
+		t.Error("Expected error for unknown app")
+	}
+	if err := mgr.SetShardingDefaults("app1", ShardingDefaults{Strategy: "bogus"}); err == nil {
+		t.Error("Expected error for invalid defaults")
+	}
+}
+
+func TestShardsInheritShardingDefaults(t *testing.T) {
+	defaults := ShardingDefaults{Strategy: "range", ShardKey: "tenant_id", ShardCount: 4}
+
+	req := &models.CreateShardRequest{Name: "shard-a", ClientAppID: "app1"}
+	applyShardingDefaults(req, defaults)
+	shard := newShardFromRequest(req, "active")
+
+	if shard.Strategy != "range" || shard.ShardKey != "tenant_id" {
+		t.Errorf("Expected shard to inherit range/tenant_id, got %s/%s", shard.Strategy, shard.ShardKey)
+	}
+	if len(shard.VNodes) != 64 {
+		t.Errorf("Expected 64 vnodes (256 split over 4 shards), got %d", len(shard.VNodes))
+	}
+}
+
+func TestShardRequestOverridesShardingDefaults(t *testing.T) {
+	defaults := ShardingDefaults{Strategy: "range", ShardKey: "tenant_id", ShardCount: 4}
+
+	req := &models.CreateShardRequest{
+		Name:        "shard-b",
+		ClientAppID: "app1",
+		Strategy:    "hash",
+		ShardKey:    "user_id",
+		VNodeCount:  10,
+	}
+	applyShardingDefaults(req, defaults)
+	shard := newShardFromRequest(req, "active")
+
+	if shard.Strategy != "hash" || shard.ShardKey != "user_id" {
+		t.Errorf("Expected per-shard overrides hash/user_id, got %s/%s", shard.Strategy, shard.ShardKey)
+	}
+	if len(shard.VNodes) != 10 {
+		t.Errorf("Expected 10 vnodes, got %d", len(shard.VNodes))
+	}
+}
+
+func TestShardsWithoutDefaults(t *testing.T) {
+	req := &models.CreateShardRequest{Name: "shard-c", ClientAppID: "app1"}
+	applyShardingDefaults(req, ShardingDefaults{})
+	shard := newShardFromRequest(req, "active")
+
+	if shard.Strategy != "" || shard.ShardKey != "" {
+		t.Errorf("Expected no strategy or key, got %s/%s", shard.Strategy, shard.ShardKey)
+	}
+	if len(shard.VNodes) != 256 {
+		t.Errorf("Expected default 256 vnodes, got %d", len(shard.VNodes))
+	}
+}
+
+func TestShardsInheritHashFunction(t *testing.T) {
+	req := &models.CreateShardRequest{Name: "shard-a", ClientAppID: "app1"}
+	applyShardingDefaults(req, ShardingDefaults{HashFunction: "crc32"})
+	shard := newShardFromRequest(req, "active")
+
+	if shard.HashFunction != "crc32" {
+		t.Errorf("Expected shard to inherit crc32, got %q", shard.HashFunction)
+	}
+	if want := hashing.NewHashFunction("crc32").Hash(shard.ID + "-vnode-0"); shard.VNodes[0].Hash != want {
+		t.Errorf("Expected vnodes hashed with crc32")
+	}
+}
+
+func TestClientAppManager_WarnsOnHashFunctionChangeWithShards(t *testing.T) {
+	core, logs := observer.New(zap.WarnLevel)
+	catalog := NewMockCatalog()
+	mgr := NewClientAppManager(catalog, zap.New(core))
+	mgr.clientApps["app1"] = &ClientAppInfo{ID: "app1", Name: "orders"}
+
+	// No shards yet, so the change takes effect silently
+	if err := mgr.SetShardingDefaults("app1", ShardingDefaults{HashFunction: "xxhash"}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if logs.Len() != 0 {
+		t.Fatalf("Expected no warning before shards exist, got %v", logs.All())
+	}
+
+	catalog.CreateShard(&models.Shard{ID: "shard-1", ClientAppID: "app1", HashFunction: "xxhash"})
+	if err := mgr.SetShardingDefaults("app1", ShardingDefaults{HashFunction: "crc32"}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if logs.Len() != 1 || logs.All()[0].ContextMap()["from"] != "xxhash" {
+		t.Errorf("Expected a migration warning, got %v", logs.All())
+	}
+}
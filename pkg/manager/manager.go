@@ -3,6 +3,8 @@ package manager
 import (
 	"context"
 	"fmt"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -10,8 +12,8 @@ import (
 	"github.com/sharding-system/pkg/catalog"
 	"github.com/sharding-system/pkg/config"
 	"github.com/sharding-system/pkg/hashing"
+	"github.com/sharding-system/pkg/keyrange"
 	"github.com/sharding-system/pkg/models"
-	"github.com/sharding-system/pkg/pricing"
 	"github.com/sharding-system/pkg/validation"
 	"go.uber.org/zap"
 )
@@ -25,6 +27,11 @@ type Manager struct {
 	resharder     Resharder
 	pricingConfig config.PricingConfig
 	clientAppMgr  *ClientAppManager
+	shardMapPoll  time.Duration // How often shard-map long-polls re-check the catalog
+	placement     PlacementStrategy
+	copier        ShardCopier
+	stats         ShardStatsSource
+	timeline      catalog.ShardTimeline
 }
 
 // Resharder handles data migration
@@ -42,6 +49,8 @@ func NewManager(catalog catalog.Catalog, logger *zap.Logger, resharder Resharder
 		resharder:     resharder,
 		pricingConfig: pricingConfig,
 		clientAppMgr:  NewClientAppManager(catalog, logger),
+		shardMapPoll:  defaultShardMapPollInterval,
+		timeline:      newMemoryTimeline(),
 	}
 }
 
@@ -55,6 +64,21 @@ func (m *Manager) GetPricingConfig() config.PricingConfig {
 	return m.pricingConfig
 }
 
+// SetPlacementStrategy sets the strategy used to pick hosts for shards created
+// without an explicit host or primary endpoint
+func (m *Manager) SetPlacementStrategy(placement PlacementStrategy) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.placement = placement
+}
+
+// HasPlacementStrategy reports whether shard hosts can be chosen automatically
+func (m *Manager) HasPlacementStrategy() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.placement != nil
+}
+
 // InitializeClientApps loads existing client applications from storage
 // No auto-creation of default apps - only registered apps are shown
 func (m *Manager) InitializeClientApps() error {
@@ -73,22 +97,81 @@ func (m *Manager) CreateShard(ctx context.Context, req *models.CreateShardReques
 
 	// Verify client application exists
 	clientAppMgr := m.GetClientAppManager()
-	_, err := clientAppMgr.GetClientApp(req.ClientAppID)
+	app, err := clientAppMgr.GetClientApp(req.ClientAppID)
 	if err != nil {
 		return nil, fmt.Errorf("client application not found: %s", req.ClientAppID)
 	}
 
+	// Fill in anything the request leaves to the app's sharding defaults
+	applyShardingDefaults(req, app.ShardingDefaults)
+
+	// All of an app's shards share one ring, so they must hash keys alike
+	existing, err := m.ListShardsForClient(req.ClientAppID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list shards for client application: %w", err)
+	}
+	if len(existing) > 0 {
+		inUse := hashing.NormalizeHashFunction(existing[0].HashFunction)
+		if requested := hashing.NormalizeHashFunction(req.HashFunction); requested != inUse {
+			m.logger.Warn("shard requested a different hash function than its client app's existing shards; keeping the existing one until the app is resharded",
+				zap.String("client_app_id", req.ClientAppID),
+				zap.String("requested", requested),
+				zap.String("in_use", inUse))
+		}
+		req.HashFunction = existing[0].HashFunction
+	}
+	if !hashing.ValidHashFunction(req.HashFunction) {
+		return nil, fmt.Errorf("invalid hash function %q: must be murmur3, xxhash or crc32", req.HashFunction)
+	}
+	if _, err := models.DriverName(req.Engine); err != nil {
+		return nil, err
+	}
+	if req.Status != "" && !models.IsInitialShardStatus(req.Status) {
+		return nil, fmt.Errorf("%w: shards cannot be created %s", models.ErrInvalidShardTransition, req.Status)
+	}
+	if req.FailureThreshold < 0 || req.HealthCheckIntervalSeconds < 0 {
+		return nil, fmt.Errorf("failure_threshold and health_check_interval_seconds must not be negative")
+	}
+	if err := models.ValidatePooler(req.Engine, req.Pooler, req.DirectEndpoint); err != nil {
+		return nil, err
+	}
+	if req.Strategy == "range" {
+		if err := checkKeyRange(req, existing); err != nil {
+			return nil, err
+		}
+	}
+
 	// Check pricing limits (per client app)
-	limits := pricing.GetLimits(m.pricingConfig.Tier)
+	limits := m.GetClientAppLimits(req.ClientAppID)
 	if limits.MaxShards != -1 {
-		shards, err := m.ListShardsForClient(req.ClientAppID)
-		if err != nil {
-			return nil, fmt.Errorf("failed to list shards for limit check: %w", err)
-		}
-		if len(shards) >= limits.MaxShards {
+		if len(existing) >= limits.MaxShards {
 			return nil, fmt.Errorf("shard limit reached for client application %s (max %d)", req.ClientAppID, limits.MaxShards)
 		}
 	}
+	if limits.MaxStorageBytes != -1 {
+		if _, used, _ := m.shardUsage(existing); used > limits.MaxStorageBytes {
+			return nil, fmt.Errorf("%w for client application %s (%d of %d bytes used)", ErrStorageQuotaExceeded, req.ClientAppID, used, limits.MaxStorageBytes)
+		}
+	}
+
+	// Choose a host when the caller left placement to us
+	if req.Host == "" && req.PrimaryEndpoint == "" {
+		m.mu.RLock()
+		placement := m.placement
+		m.mu.RUnlock()
+		if placement != nil {
+			existing, err := m.ListShards()
+			if err != nil {
+				return nil, fmt.Errorf("failed to list shards for placement: %w", err)
+			}
+			host, err := placement.SelectHost(existing)
+			if err != nil {
+				return nil, fmt.Errorf("failed to place shard: %w", err)
+			}
+			req.Host = host
+			m.logger.Info("placed shard on host", zap.String("name", req.Name), zap.String("host", host))
+		}
+	}
 
 	// Validate database connection before creating shard
 	// Shards must have a valid database connection
@@ -97,7 +180,17 @@ func (m *Manager) CreateShard(ctx context.Context, req *models.CreateShardReques
 	}
 
 	// Validate database connection
-	if err := validation.ValidateDatabaseConnection(ctx, req.Host, fmt.Sprintf("%d", req.Port), req.Database, req.Username, req.Password, req.PrimaryEndpoint); err != nil {
+	candidate := &models.Shard{
+		Engine:          req.Engine,
+		PrimaryEndpoint: req.PrimaryEndpoint,
+		Host:            req.Host,
+		Port:            req.Port,
+		Database:        req.Database,
+		Username:        req.Username,
+		Password:        req.Password,
+		ConnParams:      req.ConnParams,
+	}
+	if err := validateShardConnection(ctx, candidate); err != nil {
 		return nil, fmt.Errorf("database connection validation failed: %w. Shards cannot be created without a valid database connection", err)
 	}
 
@@ -111,6 +204,23 @@ func (m *Manager) CreateShard(ctx context.Context, req *models.CreateShardReques
 		// (already validated above, but this is a safety check)
 	}
 
+	shard := newShardFromRequest(req, status)
+
+	if err := m.catalog.CreateShard(shard); err != nil {
+		return nil, fmt.Errorf("failed to create shard in catalog: %w", err)
+	}
+
+	m.logger.Info("created shard", zap.String("shard_id", shard.ID), zap.String("name", shard.Name))
+	m.RecordShardEvent(shard.ID, catalog.ShardEventCreated, fmt.Sprintf("shard %s created as %s", shard.Name, shard.Status), map[string]string{
+		"status":        shard.Status,
+		"client_app_id": shard.ClientAppID,
+		"primary":       models.EndpointLabel(shard.ConnectionString("")),
+	})
+	return shard, nil
+}
+
+// newShardFromRequest builds a shard and its vnodes from a create request
+func newShardFromRequest(req *models.CreateShardRequest, status string) *models.Shard {
 	shard := &models.Shard{
 		ID:              uuid.New().String(),
 		Name:            req.Name,
@@ -122,12 +232,28 @@ func (m *Manager) CreateShard(ctx context.Context, req *models.CreateShardReques
 		CreatedAt:       time.Now(),
 		UpdatedAt:       time.Now(),
 		// Database connection details
-		Host:     req.Host,
-		Port:     req.Port,
-		Database: req.Database,
-		Username: req.Username,
-		Password: req.Password,
-		Weight:   req.Weight,
+		Host:         req.Host,
+		Port:         req.Port,
+		Database:     req.Database,
+		Username:     req.Username,
+		Password:     req.Password,
+		Weight:       req.Weight,
+		Engine:       models.NormalizeEngine(req.Engine),
+		ConnParams:   req.ConnParams,
+		Strategy:     req.Strategy,
+		ShardKey:     req.ShardKey,
+		HashFunction: req.HashFunction,
+
+		Pooler:         req.Pooler,
+		DirectEndpoint: req.DirectEndpoint,
+
+		FailureThreshold:           req.FailureThreshold,
+		HealthCheckIntervalSeconds: req.HealthCheckIntervalSeconds,
+	}
+	if req.Strategy == "range" {
+		shard.KeyRangeStart = req.KeyRangeStart
+		shard.KeyRangeEnd = req.KeyRangeEnd
+		shard.Collation = req.Collation
 	}
 
 	// Generate VNodes
@@ -137,7 +263,7 @@ func (m *Manager) CreateShard(ctx context.Context, req *models.CreateShardReques
 	}
 
 	shard.VNodes = make([]models.VNode, vnodeCount)
-	hashFunc := hashing.NewHashFunction("murmur3")
+	hashFunc := hashing.NewHashFunction(req.HashFunction)
 	for i := 0; i < vnodeCount; i++ {
 		vnodeKey := shard.ID + "-vnode-" + fmt.Sprintf("%d", i)
 		hash := hashFunc.Hash(vnodeKey)
@@ -148,12 +274,64 @@ func (m *Manager) CreateShard(ctx context.Context, req *models.CreateShardReques
 		}
 	}
 
-	if err := m.catalog.CreateShard(shard); err != nil {
-		return nil, fmt.Errorf("failed to create shard in catalog: %w", err)
+	return shard
+}
+
+// checkKeyRange validates a range-strategy shard's key range against the
+// app's other range shards. They must share a collation so keys order alike,
+// and live shards must not overlap. Shards created as migrating are split or
+// merge targets and overlap their sources until cutover.
+func checkKeyRange(req *models.CreateShardRequest, existing []models.Shard) error {
+	for _, shard := range existing {
+		if shard.Strategy != "range" {
+			continue
+		}
+		if keyrange.NormalizeCollation(shard.Collation) != keyrange.NormalizeCollation(req.Collation) {
+			return fmt.Errorf("collation %q differs from %q used by the client application's range shards",
+				keyrange.NormalizeCollation(req.Collation), keyrange.NormalizeCollation(shard.Collation))
+		}
+	}
+	if err := keyrange.Validate(req.Collation, req.KeyRangeStart, req.KeyRangeEnd); err != nil {
+		return err
+	}
+	if req.Status == "migrating" {
+		return nil
 	}
 
-	m.logger.Info("created shard", zap.String("shard_id", shard.ID), zap.String("name", shard.Name))
-	return shard, nil
+	for _, shard := range existing {
+		if shard.Strategy != "range" || shard.Status == "readonly" || shard.Status == "inactive" {
+			continue
+		}
+		if keyrange.Overlaps(req.Collation, req.KeyRangeStart, req.KeyRangeEnd, shard.KeyRangeStart, shard.KeyRangeEnd) {
+			return fmt.Errorf("key range [%q, %q) overlaps shard %s [%q, %q)",
+				req.KeyRangeStart, req.KeyRangeEnd, shard.ID, shard.KeyRangeStart, shard.KeyRangeEnd)
+		}
+	}
+	return nil
+}
+
+// applyShardingDefaults fills fields the request left empty from the client
+// app's sharding defaults
+func applyShardingDefaults(req *models.CreateShardRequest, defaults ShardingDefaults) {
+	if req.Strategy == "" {
+		req.Strategy = defaults.Strategy
+	}
+	if req.ShardKey == "" {
+		req.ShardKey = defaults.ShardKey
+	}
+	if req.HashFunction == "" {
+		req.HashFunction = defaults.HashFunction
+	}
+	if req.Collation == "" {
+		req.Collation = defaults.Collation
+	}
+	if req.VNodeCount == 0 && defaults.ShardCount > 0 {
+		// Divide the default vnode space between the planned shards
+		req.VNodeCount = 256 / defaults.ShardCount
+		if req.VNodeCount == 0 {
+			req.VNodeCount = 1
+		}
+	}
 }
 
 // GetShard retrieves a shard by ID
@@ -178,19 +356,43 @@ func (m *Manager) DeleteShard(shardID string) error {
 		return err
 	}
 
-	if shard.Status == "active" {
-		return fmt.Errorf("cannot delete active shard %s", shardID)
+	if err := models.ValidateShardTransition(shard.Status, models.ShardStatusDeleting); err != nil {
+		return fmt.Errorf("cannot delete shard %s: %w", shardID, err)
 	}
 
-	return m.catalog.DeleteShard(shardID)
+	status := shard.Status
+	if err := m.catalog.DeleteShard(shardID); err != nil {
+		return err
+	}
+	m.RecordShardEvent(shardID, catalog.ShardEventDeleted, fmt.Sprintf("%s shard deleted", status), nil)
+	return nil
 }
 
-// UpdateShardStatus updates the status of a shard
+// validateShardConnection checks that a shard's database can be reached with
+// the driver of its engine
+func validateShardConnection(ctx context.Context, shard *models.Shard) error {
+	if models.NormalizeEngine(shard.Engine) != models.EngineMySQL {
+		return validation.ValidateDatabaseConnection(ctx, shard.Host, fmt.Sprintf("%d", shard.Port), shard.Database, shard.Username, shard.Password, shard.PrimaryEndpoint)
+	}
+
+	dsn := shard.ConnectionString("")
+	if dsn == "" {
+		return fmt.Errorf("database host and database name are required for validation")
+	}
+	return validation.ValidateConnection(ctx, "mysql", dsn)
+}
+
+// UpdateShardStatus moves a shard to another lifecycle status. Transitions
+// the lifecycle does not allow are rejected with ErrInvalidShardTransition.
 func (m *Manager) UpdateShardStatus(shardID string, status string) error {
 	shard, err := m.catalog.GetShardByID(shardID)
 	if err != nil {
 		return err
 	}
+	if err := models.ValidateShardTransition(shard.Status, status); err != nil {
+		return fmt.Errorf("cannot update shard %s: %w", shardID, err)
+	}
+	previous := shard.Status
 
 	// If setting status to "active", validate database connection first
 	if status == "active" {
@@ -198,19 +400,8 @@ func (m *Manager) UpdateShardStatus(shardID string, status string) error {
 		dsn := ""
 		if shard.PrimaryEndpoint != "" {
 			dsn = shard.PrimaryEndpoint
-		} else if shard.Host != "" && shard.Database != "" {
-			port := shard.Port
-			if port == 0 {
-				port = 5432
-			}
-			dsn = fmt.Sprintf("host=%s port=%d dbname=%s", shard.Host, port, shard.Database)
-			if shard.Username != "" {
-				dsn += fmt.Sprintf(" user=%s", shard.Username)
-			}
-			if shard.Password != "" {
-				dsn += fmt.Sprintf(" password=%s", shard.Password)
-			}
-			dsn += " sslmode=prefer connect_timeout=10"
+		} else {
+			dsn = shard.ConnectionString("")
 		}
 
 		if dsn == "" {
@@ -220,7 +411,7 @@ func (m *Manager) UpdateShardStatus(shardID string, status string) error {
 		// Validate database connection
 		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 		defer cancel()
-		if err := validation.ValidateDatabaseConnection(ctx, shard.Host, fmt.Sprintf("%d", shard.Port), shard.Database, shard.Username, shard.Password, shard.PrimaryEndpoint); err != nil {
+		if err := validateShardConnection(ctx, shard); err != nil {
 			return fmt.Errorf("cannot set shard status to active: database connection validation failed: %w", err)
 		}
 	}
@@ -236,6 +427,10 @@ func (m *Manager) UpdateShardStatus(shardID string, status string) error {
 		zap.String("shard_id", shardID),
 		zap.String("status", status),
 	)
+	m.RecordShardEvent(shardID, catalog.ShardEventStatusChanged, fmt.Sprintf("status changed from %s to %s", previous, status), map[string]string{
+		"from": previous,
+		"to":   status,
+	})
 
 	return nil
 }
@@ -251,15 +446,22 @@ func (m *Manager) SplitShard(ctx context.Context, req *models.SplitRequest) (*mo
 		return nil, fmt.Errorf("source shard is not active: %s", sourceShard.Status)
 	}
 
+	// Range shards split at a key, each target taking one side of it
+	if sourceShard.Strategy == "range" {
+		if err := splitKeyRange(sourceShard, req); err != nil {
+			return nil, err
+		}
+	}
+
 	// Create target shards
 	targetShards := make([]*models.Shard, 0, len(req.TargetShards))
 	for _, targetReq := range req.TargetShards {
+		// Targets take no traffic until the resharder cuts over to them
+		targetReq.Status = models.ShardStatusMigrating
 		shard, err := m.CreateShard(ctx, &targetReq)
 		if err != nil {
 			return nil, fmt.Errorf("failed to create target shard: %w", err)
 		}
-		shard.Status = "migrating"
-		m.catalog.UpdateShard(shard)
 		targetShards = append(targetShards, shard)
 	}
 
@@ -283,6 +485,11 @@ func (m *Manager) SplitShard(ctx context.Context, req *models.SplitRequest) (*mo
 	m.jobs[job.ID] = job
 	m.mu.Unlock()
 
+	m.RecordShardEvent(req.SourceShardID, catalog.ShardEventSplitStarted, fmt.Sprintf("split into %d shards started", len(job.TargetShards)), map[string]string{
+		"job_id":  job.ID,
+		"targets": strings.Join(job.TargetShards, ","),
+	})
+
 	// Start async resharding
 	go m.executeReshard(ctx, job)
 
@@ -293,6 +500,7 @@ func (m *Manager) SplitShard(ctx context.Context, req *models.SplitRequest) (*mo
 // MergeShards starts a merge operation
 func (m *Manager) MergeShards(ctx context.Context, req *models.MergeRequest) (*models.ReshardJob, error) {
 	// Validate source shards
+	sources := make([]*models.Shard, 0, len(req.SourceShardIDs))
 	for _, shardID := range req.SourceShardIDs {
 		shard, err := m.catalog.GetShardByID(shardID)
 		if err != nil {
@@ -301,15 +509,22 @@ func (m *Manager) MergeShards(ctx context.Context, req *models.MergeRequest) (*m
 		if shard.Status != "active" {
 			return nil, fmt.Errorf("source shard is not active: %s", shardID)
 		}
+		sources = append(sources, shard)
+	}
+
+	// Range shards merge into the union of their ranges
+	if len(sources) > 0 && sources[0].Strategy == "range" {
+		if err := mergeKeyRanges(sources, req); err != nil {
+			return nil, err
+		}
 	}
 
-	// Create target shard
+	// Create target shard, taking no traffic until the resharder cuts over to it
+	req.TargetShard.Status = models.ShardStatusMigrating
 	targetShard, err := m.CreateShard(ctx, &req.TargetShard)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create target shard: %w", err)
 	}
-	targetShard.Status = "migrating"
-	m.catalog.UpdateShard(targetShard)
 
 	// Create reshard job
 	job := &models.ReshardJob{
@@ -327,6 +542,14 @@ func (m *Manager) MergeShards(ctx context.Context, req *models.MergeRequest) (*m
 	m.jobs[job.ID] = job
 	m.mu.Unlock()
 
+	for _, shardID := range req.SourceShardIDs {
+		m.RecordShardEvent(shardID, catalog.ShardEventMergeStarted, fmt.Sprintf("merge of %d shards into %s started", len(req.SourceShardIDs), targetShard.ID), map[string]string{
+			"job_id":  job.ID,
+			"sources": strings.Join(req.SourceShardIDs, ","),
+			"target":  targetShard.ID,
+		})
+	}
+
 	// Start async resharding
 	go m.executeReshard(ctx, job)
 
@@ -334,6 +557,77 @@ func (m *Manager) MergeShards(ctx context.Context, req *models.MergeRequest) (*m
 	return job, nil
 }
 
+// splitKeyRange divides a range shard's key range between the two targets of
+// a split, at the requested split key or a computed one
+func splitKeyRange(source *models.Shard, req *models.SplitRequest) error {
+	if len(req.TargetShards) != 2 {
+		return fmt.Errorf("range shards split into exactly two target shards, got %d", len(req.TargetShards))
+	}
+
+	point := req.SplitKey
+	if point == "" {
+		var err error
+		point, err = keyrange.SplitPoint(source.Collation, source.KeyRangeStart, source.KeyRangeEnd)
+		if err != nil {
+			return err
+		}
+	} else if point == source.KeyRangeStart || !keyrange.Contains(source.Collation, source.KeyRangeStart, source.KeyRangeEnd, point) {
+		return fmt.Errorf("split key %q must fall strictly inside the source shard's range [%q, %q)",
+			point, source.KeyRangeStart, source.KeyRangeEnd)
+	}
+
+	bounds := [][2]string{{source.KeyRangeStart, point}, {point, source.KeyRangeEnd}}
+	for i := range req.TargetShards {
+		target := &req.TargetShards[i]
+		if target.ClientAppID == "" {
+			target.ClientAppID = source.ClientAppID
+		}
+		target.Strategy = "range"
+		target.ShardKey = source.ShardKey
+		target.Collation = source.Collation
+		target.KeyRangeStart, target.KeyRangeEnd = bounds[i][0], bounds[i][1]
+		target.Status = "migrating"
+	}
+	req.SplitKey = point
+	return nil
+}
+
+// mergeKeyRanges gives a merge target the union of its range sources, which
+// must be adjacent
+func mergeKeyRanges(sources []*models.Shard, req *models.MergeRequest) error {
+	collation := sources[0].Collation
+	for _, shard := range sources {
+		if shard.Strategy != "range" {
+			return fmt.Errorf("cannot merge range shards with shard %s using the %q strategy", shard.ID, shard.Strategy)
+		}
+		if keyrange.NormalizeCollation(shard.Collation) != keyrange.NormalizeCollation(collation) {
+			return fmt.Errorf("cannot merge shards with different collations")
+		}
+	}
+
+	sorted := append([]*models.Shard{}, sources...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return keyrange.Compare(collation, sorted[i].KeyRangeStart, sorted[j].KeyRangeStart) < 0
+	})
+	for i := 1; i < len(sorted); i++ {
+		if prev := sorted[i-1]; prev.KeyRangeEnd == "" || prev.KeyRangeEnd != sorted[i].KeyRangeStart {
+			return fmt.Errorf("shards %s and %s do not have adjacent key ranges", prev.ID, sorted[i].ID)
+		}
+	}
+
+	target := &req.TargetShard
+	if target.ClientAppID == "" {
+		target.ClientAppID = sorted[0].ClientAppID
+	}
+	target.Strategy = "range"
+	target.ShardKey = sorted[0].ShardKey
+	target.Collation = collation
+	target.KeyRangeStart = sorted[0].KeyRangeStart
+	target.KeyRangeEnd = sorted[len(sorted)-1].KeyRangeEnd
+	target.Status = "migrating"
+	return nil
+}
+
 // GetReshardJob retrieves a reshard job by ID
 func (m *Manager) GetReshardJob(jobID string) (*models.ReshardJob, error) {
 	m.mu.RLock()
@@ -358,13 +652,13 @@ func (m *Manager) executeReshard(ctx context.Context, job *models.ReshardJob) {
 		err = m.resharder.Split(ctx, job)
 	} else if job.Type == "merge" {
 		err = m.resharder.Merge(ctx, job)
+	} else if rekeyer, ok := m.resharder.(Rekeyer); ok && job.Type == "rekey" {
+		err = m.finishRekey(job, rekeyer.Rekey(ctx, job))
 	} else {
 		err = fmt.Errorf("unknown reshard type: %s", job.Type)
 	}
 
 	m.mu.Lock()
-	defer m.mu.Unlock()
-
 	if err != nil {
 		job.Status = "failed"
 		job.ErrorMessage = err.Error()
@@ -375,6 +669,35 @@ func (m *Manager) executeReshard(ctx context.Context, job *models.ReshardJob) {
 		job.CompletedAt = &now
 		m.logger.Info("reshard completed", zap.String("job_id", job.ID))
 	}
+	m.mu.Unlock()
+
+	// Every shard of the job gets the outcome in its timeline
+	eventType, message, details := reshardOutcome(job, err)
+	for _, shardID := range append(append([]string{}, job.SourceShards...), job.TargetShards...) {
+		m.RecordShardEvent(shardID, eventType, message, details)
+	}
+}
+
+// reshardOutcome describes how a split, merge or rekey ended for shard timelines
+func reshardOutcome(job *models.ReshardJob, err error) (catalog.ShardEventType, string, map[string]string) {
+	details := map[string]string{"job_id": job.ID}
+	if err != nil {
+		details["error"] = err.Error()
+		if job.Type == "rekey" {
+			return catalog.ShardEventRekeyFailed, "rekey failed", details
+		}
+		if job.Type == "merge" {
+			return catalog.ShardEventMergeFailed, "merge failed", details
+		}
+		return catalog.ShardEventSplitFailed, "split failed", details
+	}
+	if job.Type == "rekey" {
+		return catalog.ShardEventRekeyCompleted, "rekey completed", details
+	}
+	if job.Type == "merge" {
+		return catalog.ShardEventMergeCompleted, "merge completed", details
+	}
+	return catalog.ShardEventSplitCompleted, "split completed", details
 }
 
 // PromoteReplica promotes a replica to primary
@@ -419,6 +742,10 @@ func (m *Manager) PromoteReplica(shardID string, replicaEndpoint string) error {
 		zap.String("shard_id", shardID),
 		zap.String("new_primary", replicaEndpoint),
 	)
+	m.RecordShardEvent(shardID, catalog.ShardEventPromoted, fmt.Sprintf("replica %s promoted to primary", models.EndpointLabel(replicaEndpoint)), map[string]string{
+		"old_primary": models.EndpointLabel(oldPrimary),
+		"new_primary": models.EndpointLabel(replicaEndpoint),
+	})
 
 	return nil
 }
@@ -3,6 +3,7 @@ package manager
 import (
 	"context"
 	"errors"
+	"sync"
 	"testing"
 
 	"github.com/sharding-system/pkg/config"
@@ -12,6 +13,7 @@ import (
 
 // MockCatalog implements catalog.Catalog for testing
 type MockCatalog struct {
+	mu     sync.RWMutex
 	shards map[string]*models.Shard
 }
 
@@ -22,6 +24,8 @@ func NewMockCatalog() *MockCatalog {
 }
 
 func (m *MockCatalog) GetShard(key string, clientAppID string) (*models.Shard, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
 	for _, shard := range m.shards {
 		return shard, nil
 	}
@@ -29,6 +33,8 @@ func (m *MockCatalog) GetShard(key string, clientAppID string) (*models.Shard, e
 }
 
 func (m *MockCatalog) GetShardByID(shardID string) (*models.Shard, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
 	shard, ok := m.shards[shardID]
 	if !ok {
 		return nil, errors.New("shard not found")
@@ -37,6 +43,8 @@ func (m *MockCatalog) GetShardByID(shardID string) (*models.Shard, error) {
 }
 
 func (m *MockCatalog) ListShards(clientAppID string) ([]models.Shard, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
 	shards := make([]models.Shard, 0, len(m.shards))
 	for _, shard := range m.shards {
 		shards = append(shards, *shard)
@@ -45,16 +53,22 @@ func (m *MockCatalog) ListShards(clientAppID string) ([]models.Shard, error) {
 }
 
 func (m *MockCatalog) CreateShard(shard *models.Shard) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	m.shards[shard.ID] = shard
 	return nil
 }
 
 func (m *MockCatalog) UpdateShard(shard *models.Shard) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	m.shards[shard.ID] = shard
 	return nil
 }
 
 func (m *MockCatalog) DeleteShard(shardID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	delete(m.shards, shardID)
 	return nil
 }
@@ -68,6 +82,10 @@ func (m *MockCatalog) Watch(ctx context.Context) (<-chan *models.ShardCatalog, e
 	return ch, nil
 }
 
+func (m *MockCatalog) Reload() error {
+	return nil
+}
+
 // MockResharder implements Resharder for testing
 type MockResharder struct {
 	splitError error
@@ -210,6 +228,70 @@ func TestManager_DeleteShard_InactiveShard(t *testing.T) {
 	}
 }
 
+func TestManager_UpdateShardStatus_Transitions(t *testing.T) {
+	tests := []struct {
+		from, to string
+		allowed  bool
+	}{
+		{models.ShardStatusInactive, models.ShardStatusDraining, true},
+		{models.ShardStatusDraining, models.ShardStatusInactive, true},
+		{models.ShardStatusReadOnly, models.ShardStatusDraining, true},
+		{models.ShardStatusProvisioning, models.ShardStatusFailed, true},
+		{models.ShardStatusDraining, models.ShardStatusReadOnly, false},
+		{models.ShardStatusDeleting, models.ShardStatusInactive, false},
+		{models.ShardStatusProvisioning, models.ShardStatusDraining, false},
+		{models.ShardStatusMigrating, models.ShardStatusInactive, false},
+		{models.ShardStatusInactive, "paused", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.from+"->"+tt.to, func(t *testing.T) {
+			catalog := NewMockCatalog()
+			manager := NewManager(catalog, zaptest.NewLogger(t), &MockResharder{}, config.PricingConfig{Tier: "pro"})
+			catalog.CreateShard(&models.Shard{ID: "shard1", Status: tt.from})
+
+			err := manager.UpdateShardStatus("shard1", tt.to)
+			shard, _ := catalog.GetShardByID("shard1")
+			if tt.allowed {
+				if err != nil {
+					t.Fatalf("expected the transition to be allowed, got %v", err)
+				}
+				if shard.Status != tt.to {
+					t.Errorf("expected status %s, got %s", tt.to, shard.Status)
+				}
+				return
+			}
+			if !models.IsInvalidShardTransition(err) {
+				t.Fatalf("expected ErrInvalidShardTransition, got %v", err)
+			}
+			if shard.Status != tt.from {
+				t.Errorf("expected a rejected transition to leave status %s, got %s", tt.from, shard.Status)
+			}
+		})
+	}
+}
+
+func TestManager_DeleteShard_FollowsLifecycle(t *testing.T) {
+	for status, deletable := range map[string]bool{
+		models.ShardStatusDraining:     true,
+		models.ShardStatusFailed:       true,
+		models.ShardStatusProvisioning: true,
+		models.ShardStatusActive:       false,
+	} {
+		catalog := NewMockCatalog()
+		manager := NewManager(catalog, zaptest.NewLogger(t), &MockResharder{}, config.PricingConfig{Tier: "pro"})
+		catalog.CreateShard(&models.Shard{ID: "shard1", Status: status})
+
+		err := manager.DeleteShard("shard1")
+		if deletable && err != nil {
+			t.Errorf("expected a %s shard to be deletable, got %v", status, err)
+		}
+		if !deletable && !models.IsInvalidShardTransition(err) {
+			t.Errorf("expected deleting a %s shard to be rejected, got %v", status, err)
+		}
+	}
+}
+
 func TestManager_GetReshardJob(t *testing.T) {
 	logger := zaptest.NewLogger(t)
 	catalog := NewMockCatalog()
@@ -246,3 +328,95 @@ func TestManager_GetReshardJob_NotFound(t *testing.T) {
 		t.Error("Expected error for nonexistent job")
 	}
 }
+
+func TestSplitKeyRange(t *testing.T) {
+	source := &models.Shard{ID: "a-z", ClientAppID: "app1", Strategy: "range", ShardKey: "name", KeyRangeStart: "A", KeyRangeEnd: "Z"}
+
+	req := &models.SplitRequest{TargetShards: make([]models.CreateShardRequest, 2)}
+	if err := splitKeyRange(source, req); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if req.SplitKey != "M" {
+		t.Errorf("Expected computed split key M, got %q", req.SplitKey)
+	}
+	low, high := req.TargetShards[0], req.TargetShards[1]
+	if low.KeyRangeStart != "A" || low.KeyRangeEnd != "M" || high.KeyRangeStart != "M" || high.KeyRangeEnd != "Z" {
+		t.Errorf("Expected [A, M) and [M, Z), got [%q, %q) and [%q, %q)", low.KeyRangeStart, low.KeyRangeEnd, high.KeyRangeStart, high.KeyRangeEnd)
+	}
+	if low.ClientAppID != "app1" || low.Strategy != "range" || low.ShardKey != "name" || low.Status != "migrating" {
+		t.Errorf("Expected targets to inherit the source's scheme, got %+v", low)
+	}
+
+	// An explicit split key must fall strictly inside the source's range
+	for _, key := range []string{"A", "Z", "a", ""} {
+		req := &models.SplitRequest{TargetShards: make([]models.CreateShardRequest, 2), SplitKey: key}
+		if key == "" {
+			source := *source
+			source.KeyRangeStart, source.KeyRangeEnd = "a", "a\x00"
+			if err := splitKeyRange(&source, req); err == nil {
+				t.Error("Expected an error when no split key fits")
+			}
+			continue
+		}
+		if err := splitKeyRange(source, req); err == nil {
+			t.Errorf("Expected an error for split key %q", key)
+		}
+	}
+
+	if err := splitKeyRange(source, &models.SplitRequest{TargetShards: make([]models.CreateShardRequest, 3)}); err == nil {
+		t.Error("Expected an error for three targets")
+	}
+}
+
+func TestMergeKeyRanges(t *testing.T) {
+	sources := []*models.Shard{
+		{ID: "n-z", ClientAppID: "app1", Strategy: "range", KeyRangeStart: "N"},
+		{ID: "a-m", ClientAppID: "app1", Strategy: "range", KeyRangeEnd: "N"},
+	}
+	req := &models.MergeRequest{}
+	if err := mergeKeyRanges(sources, req); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if req.TargetShard.KeyRangeStart != "" || req.TargetShard.KeyRangeEnd != "" || req.TargetShard.ClientAppID != "app1" {
+		t.Errorf("Expected the whole key space, got %+v", req.TargetShard)
+	}
+
+	gap := []*models.Shard{
+		{ID: "a-m", Strategy: "range", KeyRangeEnd: "M"},
+		{ID: "n-z", Strategy: "range", KeyRangeStart: "N"},
+	}
+	if err := mergeKeyRanges(gap, &models.MergeRequest{}); err == nil {
+		t.Error("Expected an error for non-adjacent ranges")
+	}
+
+	mixed := []*models.Shard{
+		{ID: "a-m", Strategy: "range", KeyRangeEnd: "N"},
+		{ID: "n-z", Strategy: "range", KeyRangeStart: "N", Collation: "unicode"},
+	}
+	if err := mergeKeyRanges(mixed, &models.MergeRequest{}); err == nil {
+		t.Error("Expected an error for different collations")
+	}
+}
+
+func TestCheckKeyRange(t *testing.T) {
+	existing := []models.Shard{
+		{ID: "a-m", Strategy: "range", Status: "active", KeyRangeEnd: "N"},
+		{ID: "old", Strategy: "range", Status: "readonly", KeyRangeStart: "N"},
+	}
+
+	if err := checkKeyRange(&models.CreateShardRequest{KeyRangeStart: "N"}, existing); err != nil {
+		t.Errorf("Expected an adjacent range to be accepted, got %v", err)
+	}
+	if err := checkKeyRange(&models.CreateShardRequest{KeyRangeStart: "M"}, existing); err == nil {
+		t.Error("Expected an overlapping range to be rejected")
+	}
+	if err := checkKeyRange(&models.CreateShardRequest{KeyRangeStart: "M", Status: "migrating"}, existing); err != nil {
+		t.Errorf("Expected split targets to overlap their source, got %v", err)
+	}
+	if err := checkKeyRange(&models.CreateShardRequest{KeyRangeStart: "Z", KeyRangeEnd: "N"}, nil); err == nil {
+		t.Error("Expected an inverted range to be rejected")
+	}
+	if err := checkKeyRange(&models.CreateShardRequest{KeyRangeStart: "N", Collation: "unicode"}, existing); err == nil {
+		t.Error("Expected a collation mismatch to be rejected")
+	}
+}
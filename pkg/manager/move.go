@@ -0,0 +1,271 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sharding-system/pkg/catalog"
+	"github.com/sharding-system/pkg/models"
+	"go.uber.org/zap"
+)
+
+// ShardCopier copies a shard's data to a new database and cleans up the old one
+type ShardCopier interface {
+	// CopyShardData copies all rows of the shard to targetEndpoint. It must be
+	// safe to call repeatedly; rows already present on the target are skipped.
+	CopyShardData(ctx context.Context, shard *models.Shard, targetEndpoint string) (int64, error)
+	// CountShardRows counts the shard rows at endpoint, so a move can check
+	// the target holds every row before cutting over
+	CountShardRows(ctx context.Context, endpoint string) (int64, error)
+	// DecommissionEndpoint removes shard data from an endpoint that no longer receives traffic
+	DecommissionEndpoint(ctx context.Context, endpoint string) error
+}
+
+// SetShardCopier sets the copier used to move shards between hosts
+func (m *Manager) SetShardCopier(copier ShardCopier) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.copier = copier
+}
+
+// MoveShard starts moving a shard to another host. The shard keeps its ID and
+// key range; only its endpoint changes once the data has been copied.
+func (m *Manager) MoveShard(ctx context.Context, shardID string, req *models.MoveShardRequest) (*models.ReshardJob, error) {
+	m.mu.RLock()
+	copier := m.copier
+	m.mu.RUnlock()
+	if copier == nil {
+		return nil, fmt.Errorf("shard moves are not supported: no shard copier configured")
+	}
+
+	shard, err := m.catalog.GetShardByID(shardID)
+	if err != nil {
+		return nil, fmt.Errorf("shard not found: %w", err)
+	}
+	if shard.Status != "active" {
+		return nil, fmt.Errorf("shard is not active: %s", shard.Status)
+	}
+
+	target, err := resolveMoveTarget(shard, req)
+	if err != nil {
+		return nil, err
+	}
+	if target.endpoint == shard.PrimaryEndpoint {
+		return nil, fmt.Errorf("shard %s is already on %s", shardID, target.endpoint)
+	}
+
+	job := &models.ReshardJob{
+		ID:           uuid.New().String(),
+		Type:         "move",
+		SourceShards: []string{shardID},
+		TargetShards: []string{shardID},
+		Status:       "pending",
+		Progress:     0.0,
+		StartedAt:    time.Now(),
+	}
+
+	m.mu.Lock()
+	m.jobs[job.ID] = job
+	m.mu.Unlock()
+
+	m.RecordShardEvent(shardID, catalog.ShardEventMoveStarted, fmt.Sprintf("move to %s started", models.EndpointLabel(target.endpoint)), map[string]string{
+		"job_id": job.ID,
+		"from":   models.EndpointLabel(shard.PrimaryEndpoint),
+		"to":     models.EndpointLabel(target.endpoint),
+	})
+
+	// The move outlives the request that started it
+	go m.executeMove(context.Background(), job, copier, target)
+
+	m.logger.Info("started shard move",
+		zap.String("job_id", job.ID),
+		zap.String("shard_id", shardID),
+		zap.String("target_host", target.host))
+	return job, nil
+}
+
+// moveTarget describes where a shard is being moved to
+type moveTarget struct {
+	endpoint string
+	host     string
+	port     int
+}
+
+// resolveMoveTarget works out the target DSN and host for a move request
+func resolveMoveTarget(shard *models.Shard, req *models.MoveShardRequest) (*moveTarget, error) {
+	if req.TargetEndpoint != "" {
+		target := &moveTarget{endpoint: req.TargetEndpoint, host: req.TargetHost, port: req.TargetPort}
+		if u, err := url.Parse(req.TargetEndpoint); err == nil && u.Hostname() != "" {
+			target.host = u.Hostname()
+			if p, err := strconv.Atoi(u.Port()); err == nil {
+				target.port = p
+			}
+		}
+		return target, nil
+	}
+
+	if req.TargetHost == "" {
+		return nil, fmt.Errorf("target_endpoint or target_host is required")
+	}
+	if shard.Database == "" {
+		return nil, fmt.Errorf("shard %s has no database name; provide target_endpoint", shard.ID)
+	}
+
+	port := req.TargetPort
+	if port == 0 {
+		port = shard.Port
+	}
+	if port == 0 {
+		port = 5432
+	}
+
+	dsn := fmt.Sprintf("host=%s port=%d dbname=%s", req.TargetHost, port, shard.Database)
+	if shard.Username != "" {
+		dsn += fmt.Sprintf(" user=%s", shard.Username)
+	}
+	if shard.Password != "" {
+		dsn += fmt.Sprintf(" password=%s", shard.Password)
+	}
+	dsn += " sslmode=prefer"
+
+	return &moveTarget{endpoint: dsn, host: req.TargetHost, port: port}, nil
+}
+
+// executeMove copies the shard, switches routing to the target and
+// decommissions the source
+func (m *Manager) executeMove(ctx context.Context, job *models.ReshardJob, copier ShardCopier, target *moveTarget) {
+	err := m.runMove(ctx, job, copier, target)
+	shardID := job.SourceShards[0]
+
+	m.mu.Lock()
+	if err != nil {
+		job.Status = "failed"
+		job.ErrorMessage = err.Error()
+		m.mu.Unlock()
+		m.logger.Error("shard move failed", zap.String("job_id", job.ID), zap.Error(err))
+		m.RecordShardEvent(shardID, catalog.ShardEventMoveFailed, "move failed", map[string]string{"job_id": job.ID, "error": err.Error()})
+		return
+	}
+
+	job.Status = "completed"
+	job.Progress = 1.0
+	now := time.Now()
+	job.CompletedAt = &now
+	m.mu.Unlock()
+	m.logger.Info("shard move completed", zap.String("job_id", job.ID))
+	m.RecordShardEvent(shardID, catalog.ShardEventMoveCompleted, fmt.Sprintf("moved to %s", models.EndpointLabel(target.endpoint)), map[string]string{"job_id": job.ID})
+}
+
+func (m *Manager) runMove(ctx context.Context, job *models.ReshardJob, copier ShardCopier, target *moveTarget) error {
+	shardID := job.SourceShards[0]
+
+	// Phase 1: bulk copy while the source keeps serving writes. The move works
+	// on a copy of the shard, as the catalog's may be read concurrently, and
+	// keeps the original to restore if the move is aborted.
+	m.setJobPhase(job, "precopy", 0.0)
+	current, err := m.catalog.GetShardByID(shardID)
+	if err != nil {
+		return fmt.Errorf("failed to get shard: %w", err)
+	}
+	original := *current
+	copied, err := copier.CopyShardData(ctx, &original, target.endpoint)
+	if err != nil {
+		return fmt.Errorf("pre-copy failed: %w", err)
+	}
+	m.recordCopied(job, copied)
+
+	// Phase 2: stop writes and copy what changed during the bulk copy
+	m.setJobPhase(job, "deltasync", 0.5)
+	readonly := original
+	readonly.Status = "readonly"
+	if err := m.catalog.UpdateShard(&readonly); err != nil {
+		return fmt.Errorf("failed to mark shard readonly: %w", err)
+	}
+	copied, err = copier.CopyShardData(ctx, &readonly, target.endpoint)
+	if err != nil {
+		m.restoreShard(original)
+		return fmt.Errorf("delta sync failed: %w", err)
+	}
+	m.recordCopied(job, copied)
+
+	// With writes stopped the target must hold every row of the source, or
+	// cutting over and decommissioning the source would lose data
+	if err := verifyMoveCopy(ctx, copier, original.PrimaryEndpoint, target.endpoint); err != nil {
+		m.restoreShard(original)
+		return err
+	}
+
+	// Phase 3: point the shard at the target in a single catalog write
+	m.setJobPhase(job, "cutover", 0.8)
+	moved := original
+	moved.PrimaryEndpoint = target.endpoint
+	moved.Host = target.host
+	if target.port != 0 {
+		moved.Port = target.port
+	}
+	moved.Status = "active"
+	if err := m.catalog.UpdateShard(&moved); err != nil {
+		m.restoreShard(original)
+		return fmt.Errorf("cutover failed: %w", err)
+	}
+
+	// Phase 4: the source no longer receives traffic, so clean it up. A
+	// failure here leaves stale data behind but the move itself succeeded.
+	m.setJobPhase(job, "decommission", 0.9)
+	if err := copier.DecommissionEndpoint(ctx, original.PrimaryEndpoint); err != nil {
+		m.logger.Warn("failed to decommission source after move",
+			zap.String("shard_id", shardID),
+			zap.Error(err))
+		m.mu.Lock()
+		job.ErrorMessage = fmt.Sprintf("source decommission failed: %v", err)
+		m.mu.Unlock()
+	}
+
+	return nil
+}
+
+// verifyMoveCopy checks that the target of a move holds as many rows as its
+// read-only source
+func verifyMoveCopy(ctx context.Context, copier ShardCopier, sourceEndpoint, targetEndpoint string) error {
+	sourceRows, err := copier.CountShardRows(ctx, sourceEndpoint)
+	if err != nil {
+		return fmt.Errorf("failed to count source rows: %w", err)
+	}
+	targetRows, err := copier.CountShardRows(ctx, targetEndpoint)
+	if err != nil {
+		return fmt.Errorf("failed to count target rows: %w", err)
+	}
+	if targetRows != sourceRows {
+		return fmt.Errorf("copy incomplete: target has %d rows, source has %d", targetRows, sourceRows)
+	}
+	return nil
+}
+
+// setJobPhase updates a job's status and progress
+func (m *Manager) setJobPhase(job *models.ReshardJob, status string, progress float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job.Status = status
+	job.Progress = progress
+}
+
+// recordCopied adds copied rows to a job's counters
+func (m *Manager) recordCopied(job *models.ReshardJob, rows int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job.KeysMigrated += rows
+	job.TotalKeys = job.KeysMigrated
+}
+
+// restoreShard puts a shard back as it was before an aborted move, active on
+// its original endpoint
+func (m *Manager) restoreShard(original models.Shard) {
+	original.Status = "active"
+	if err := m.catalog.UpdateShard(&original); err != nil {
+		m.logger.Error("failed to restore shard after aborted move", zap.String("shard_id", original.ID), zap.Error(err))
+	}
+}
@@ -0,0 +1,272 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sharding-system/pkg/config"
+	"github.com/sharding-system/pkg/models"
+	"go.uber.org/zap/zaptest"
+)
+
+// stubCopier records copy and decommission calls instead of touching databases
+type stubCopier struct {
+	mu             sync.Mutex
+	catalog        *MockCatalog
+	copies         []string
+	statusDuring   []string // Shard status observed at each copy
+	decommissioned []string
+	copyErr        error
+	decommErr      error
+	rows           map[string]int64 // Rows counted by endpoint; 10 when unset
+}
+
+func (c *stubCopier) CopyShardData(ctx context.Context, shard *models.Shard, targetEndpoint string) (int64, error) {
+	current, _ := c.catalog.GetShardByID(shard.ID)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.copies = append(c.copies, targetEndpoint)
+	c.statusDuring = append(c.statusDuring, current.Status)
+	if c.copyErr != nil {
+		return 0, c.copyErr
+	}
+	return 10, nil
+}
+
+func (c *stubCopier) CountShardRows(ctx context.Context, endpoint string) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if rows, ok := c.rows[endpoint]; ok {
+		return rows, nil
+	}
+	return 10, nil
+}
+
+func (c *stubCopier) DecommissionEndpoint(ctx context.Context, endpoint string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.decommissioned = append(c.decommissioned, endpoint)
+	return c.decommErr
+}
+
+func newMoveTestManager(t *testing.T) (*Manager, *MockCatalog, *stubCopier) {
+	catalog := NewMockCatalog()
+	manager := NewManager(catalog, zaptest.NewLogger(t), &MockResharder{}, config.PricingConfig{Tier: "pro"})
+	copier := &stubCopier{catalog: catalog}
+	manager.SetShardCopier(copier)
+
+	catalog.CreateShard(&models.Shard{
+		ID:              "shard1",
+		PrimaryEndpoint: "postgres://app@old-host:5432/app",
+		Status:          "active",
+		HashRangeStart:  100,
+		HashRangeEnd:    200,
+		VNodes:          []models.VNode{{ID: 0, ShardID: "shard1", Hash: 150}},
+	})
+	return manager, catalog, copier
+}
+
+func waitForJob(t *testing.T, manager *Manager, jobID string) *models.ReshardJob {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		job, err := manager.GetReshardJob(jobID)
+		if err != nil {
+			t.Fatalf("Expected job, got %v", err)
+		}
+		manager.mu.RLock()
+		status := job.Status
+		manager.mu.RUnlock()
+		if status == "completed" || status == "failed" {
+			return job
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("Timed out waiting for move job")
+	return nil
+}
+
+func TestManager_MoveShard_CutsOverAndDecommissionsSource(t *testing.T) {
+	manager, catalog, copier := newMoveTestManager(t)
+
+	job, err := manager.MoveShard(context.Background(), "shard1", &models.MoveShardRequest{
+		TargetEndpoint: "postgres://app@new-host:6432/app",
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if job.Type != "move" {
+		t.Errorf("Expected job type move, got %s", job.Type)
+	}
+
+	job = waitForJob(t, manager, job.ID)
+	if job.Status != "completed" {
+		t.Fatalf("Expected completed, got %s (%s)", job.Status, job.ErrorMessage)
+	}
+
+	shard, _ := catalog.GetShardByID("shard1")
+	if shard.PrimaryEndpoint != "postgres://app@new-host:6432/app" {
+		t.Errorf("Expected routing to point at new host, got %s", shard.PrimaryEndpoint)
+	}
+	if shard.Host != "new-host" || shard.Port != 6432 {
+		t.Errorf("Expected host new-host:6432, got %s:%d", shard.Host, shard.Port)
+	}
+	if shard.Status != "active" {
+		t.Errorf("Expected shard active after move, got %s", shard.Status)
+	}
+	if shard.HashRangeStart != 100 || shard.HashRangeEnd != 200 || len(shard.VNodes) != 1 {
+		t.Error("Expected key range and vnodes to be unchanged by the move")
+	}
+
+	// Bulk copy runs while active, catch-up copy while readonly
+	if len(copier.copies) != 2 {
+		t.Fatalf("Expected 2 copy passes, got %d", len(copier.copies))
+	}
+	if copier.statusDuring[0] != "active" || copier.statusDuring[1] != "readonly" {
+		t.Errorf("Expected copies during active then readonly, got %v", copier.statusDuring)
+	}
+	if job.KeysMigrated != 20 {
+		t.Errorf("Expected 20 keys migrated, got %d", job.KeysMigrated)
+	}
+
+	if len(copier.decommissioned) != 1 || copier.decommissioned[0] != "postgres://app@old-host:5432/app" {
+		t.Errorf("Expected old endpoint decommissioned, got %v", copier.decommissioned)
+	}
+}
+
+func TestManager_MoveShard_CopyFailureKeepsSource(t *testing.T) {
+	manager, catalog, copier := newMoveTestManager(t)
+	copier.copyErr = errors.New("copy failed")
+
+	job, err := manager.MoveShard(context.Background(), "shard1", &models.MoveShardRequest{
+		TargetEndpoint: "postgres://app@new-host:5432/app",
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	job = waitForJob(t, manager, job.ID)
+	if job.Status != "failed" {
+		t.Fatalf("Expected failed, got %s", job.Status)
+	}
+
+	shard, _ := catalog.GetShardByID("shard1")
+	if shard.PrimaryEndpoint != "postgres://app@old-host:5432/app" || shard.Status != "active" {
+		t.Errorf("Expected shard left active on old host, got %s (%s)", shard.PrimaryEndpoint, shard.Status)
+	}
+	if len(copier.decommissioned) != 0 {
+		t.Errorf("Expected source not decommissioned, got %v", copier.decommissioned)
+	}
+}
+
+func TestManager_MoveShard_IncompleteCopyKeepsSource(t *testing.T) {
+	manager, catalog, copier := newMoveTestManager(t)
+	copier.rows = map[string]int64{"postgres://app@new-host:5432/app": 0}
+
+	job, err := manager.MoveShard(context.Background(), "shard1", &models.MoveShardRequest{
+		TargetEndpoint: "postgres://app@new-host:5432/app",
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	job = waitForJob(t, manager, job.ID)
+	if job.Status != "failed" {
+		t.Fatalf("Expected a move to a target missing rows to fail, got %s", job.Status)
+	}
+	shard, _ := catalog.GetShardByID("shard1")
+	if shard.PrimaryEndpoint != "postgres://app@old-host:5432/app" || shard.Status != "active" {
+		t.Errorf("Expected shard left active on old host, got %s (%s)", shard.PrimaryEndpoint, shard.Status)
+	}
+	if len(copier.decommissioned) != 0 {
+		t.Errorf("Expected source not decommissioned, got %v", copier.decommissioned)
+	}
+}
+
+// cutoverFailingCatalog refuses to point shards at target
+type cutoverFailingCatalog struct {
+	*MockCatalog
+	target string
+}
+
+func (c *cutoverFailingCatalog) UpdateShard(shard *models.Shard) error {
+	if shard.PrimaryEndpoint == c.target {
+		return errors.New("etcd unavailable")
+	}
+	return c.MockCatalog.UpdateShard(shard)
+}
+
+func TestManager_MoveShard_FailedCutoverRestoresOriginalEndpoint(t *testing.T) {
+	_, mock, copier := newMoveTestManager(t)
+	catalog := &cutoverFailingCatalog{MockCatalog: mock, target: "postgres://app@new-host:5432/app"}
+	manager := NewManager(catalog, zaptest.NewLogger(t), &MockResharder{}, config.PricingConfig{Tier: "pro"})
+	manager.SetShardCopier(copier)
+	cached, _ := mock.GetShardByID("shard1")
+
+	job, err := manager.MoveShard(context.Background(), "shard1", &models.MoveShardRequest{
+		TargetEndpoint: "postgres://app@new-host:5432/app",
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	job = waitForJob(t, manager, job.ID)
+	if job.Status != "failed" {
+		t.Fatalf("Expected failed, got %s", job.Status)
+	}
+	shard, _ := mock.GetShardByID("shard1")
+	if shard.PrimaryEndpoint != "postgres://app@old-host:5432/app" || shard.Host != "" || shard.Status != "active" {
+		t.Errorf("Expected shard restored active on old host, got %s on %q (%s)", shard.PrimaryEndpoint, shard.Host, shard.Status)
+	}
+	if cached.PrimaryEndpoint != "postgres://app@old-host:5432/app" || cached.Status != "active" {
+		t.Errorf("Expected the catalog's shard not to be changed in place, got %s (%s)", cached.PrimaryEndpoint, cached.Status)
+	}
+	if len(copier.decommissioned) != 0 {
+		t.Errorf("Expected source not decommissioned, got %v", copier.decommissioned)
+	}
+}
+
+func TestManager_MoveShard_DecommissionFailureStillCompletes(t *testing.T) {
+	manager, catalog, copier := newMoveTestManager(t)
+	copier.decommErr = errors.New("drop failed")
+
+	job, err := manager.MoveShard(context.Background(), "shard1", &models.MoveShardRequest{
+		TargetEndpoint: "postgres://app@new-host:5432/app",
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	job = waitForJob(t, manager, job.ID)
+	if job.Status != "completed" || job.ErrorMessage == "" {
+		t.Errorf("Expected completed with decommission warning, got %s (%q)", job.Status, job.ErrorMessage)
+	}
+	shard, _ := catalog.GetShardByID("shard1")
+	if shard.PrimaryEndpoint != "postgres://app@new-host:5432/app" {
+		t.Errorf("Expected cutover to stand, got %s", shard.PrimaryEndpoint)
+	}
+}
+
+func TestManager_MoveShard_Validation(t *testing.T) {
+	manager, _, _ := newMoveTestManager(t)
+	ctx := context.Background()
+
+	if _, err := manager.MoveShard(ctx, "shard1", &models.MoveShardRequest{}); err == nil {
+		t.Error("Expected error without a target")
+	}
+	if _, err := manager.MoveShard(ctx, "shard1", &models.MoveShardRequest{TargetEndpoint: "postgres://app@old-host:5432/app"}); err == nil {
+		t.Error("Expected error moving to the current endpoint")
+	}
+	if _, err := manager.MoveShard(ctx, "missing", &models.MoveShardRequest{TargetHost: "new-host"}); err == nil {
+		t.Error("Expected error for unknown shard")
+	}
+
+	noCopier := NewManager(NewMockCatalog(), zaptest.NewLogger(t), &MockResharder{}, config.PricingConfig{Tier: "pro"})
+	if _, err := noCopier.MoveShard(ctx, "shard1", &models.MoveShardRequest{TargetHost: "new-host"}); err == nil {
+		t.Error("Expected error without a shard copier")
+	}
+}
@@ -0,0 +1,125 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.uber.org/zap"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Namespace validation policies for client app registration
+const (
+	NamespacePolicyOff    = "off"    // Don't check namespaces
+	NamespacePolicyWarn   = "warn"   // Log problems and register anyway
+	NamespacePolicyStrict = "strict" // Reject registrations that fail the check
+)
+
+var (
+	// ErrNamespaceNotFound is returned when a client app references a namespace missing from its cluster
+	ErrNamespaceNotFound = errors.New("namespace not found")
+	// ErrAppNotInNamespace is returned when no workload for the client app runs in its namespace
+	ErrAppNotInNamespace = errors.New("application not found in namespace")
+)
+
+// ClusterClientFunc returns the Kubernetes client for a cluster by name. An
+// empty name refers to the cluster the manager runs in.
+type ClusterClientFunc func(clusterName string) (kubernetes.Interface, error)
+
+// NamespaceValidator checks that the namespace a client app is registered
+// with exists in the referenced cluster and runs the app
+type NamespaceValidator struct {
+	clients ClusterClientFunc
+	policy  string
+	logger  *zap.Logger
+}
+
+// NewNamespaceValidator creates a namespace validator with the given policy
+func NewNamespaceValidator(clients ClusterClientFunc, policy string, logger *zap.Logger) (*NamespaceValidator, error) {
+	switch policy {
+	case NamespacePolicyOff, NamespacePolicyWarn, NamespacePolicyStrict:
+	default:
+		return nil, fmt.Errorf("invalid namespace validation policy %q: must be off, warn or strict", policy)
+	}
+	return &NamespaceValidator{clients: clients, policy: policy, logger: logger}, nil
+}
+
+// Validate checks a client app's namespace and cluster. Under the warn policy
+// problems are logged and nil is returned; under strict a missing namespace
+// or app is returned. When the cluster can't be queried the app is
+// registered with a warning under either policy.
+func (v *NamespaceValidator) Validate(ctx context.Context, appName, namespace, clusterName string) error {
+	if v.policy == NamespacePolicyOff || namespace == "" {
+		return nil
+	}
+
+	err := v.check(ctx, appName, namespace, clusterName)
+	if err == nil {
+		return nil
+	}
+	invalid := errors.Is(err, ErrNamespaceNotFound) || errors.Is(err, ErrAppNotInNamespace)
+	if invalid && v.policy == NamespacePolicyStrict {
+		return err
+	}
+
+	if !invalid {
+		v.logger.Warn("could not validate client app namespace, registering anyway",
+			zap.String("app", appName),
+			zap.String("namespace", namespace),
+			zap.String("cluster", clusterName),
+			zap.Error(err))
+		return nil
+	}
+	v.logger.Warn("client app namespace validation failed, registering anyway",
+		zap.String("app", appName),
+		zap.String("namespace", namespace),
+		zap.String("cluster", clusterName),
+		zap.Error(err))
+	return nil
+}
+
+func (v *NamespaceValidator) check(ctx context.Context, appName, namespace, clusterName string) error {
+	client, err := v.clients(clusterName)
+	if err != nil {
+		return fmt.Errorf("failed to get client for cluster %q: %w", clusterName, err)
+	}
+
+	if _, err := client.CoreV1().Namespaces().Get(ctx, namespace, metav1.GetOptions{}); err != nil {
+		if apierrors.IsNotFound(err) {
+			return fmt.Errorf("%w: %s in cluster %q", ErrNamespaceNotFound, namespace, clusterName)
+		}
+		return fmt.Errorf("failed to get namespace %s: %w", namespace, err)
+	}
+
+	// Discovery names apps after their Deployment or StatefulSet, so accept
+	// either a workload of that name or one labelled with it
+	deployments, err := client.AppsV1().Deployments(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to list deployments in %s: %w", namespace, err)
+	}
+	for _, d := range deployments.Items {
+		if matchesApp(appName, d.Name, d.Labels) {
+			return nil
+		}
+	}
+
+	statefulSets, err := client.AppsV1().StatefulSets(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to list statefulsets in %s: %w", namespace, err)
+	}
+	for _, sts := range statefulSets.Items {
+		if matchesApp(appName, sts.Name, sts.Labels) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: %s in %s", ErrAppNotInNamespace, appName, namespace)
+}
+
+// matchesApp reports whether a workload belongs to the named app
+func matchesApp(appName, workloadName string, labels map[string]string) bool {
+	return workloadName == appName || labels["app"] == appName || labels["app.kubernetes.io/name"] == appName
+}
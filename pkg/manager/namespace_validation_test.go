@@ -0,0 +1,108 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest"
+	"go.uber.org/zap/zaptest/observer"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func fakeClusterClients(t *testing.T) ClusterClientFunc {
+	client := fake.NewSimpleClientset(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "payments"}},
+		&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "billing-api", Namespace: "payments", Labels: map[string]string{"app": "billing"}}},
+		&appsv1.StatefulSet{ObjectMeta: metav1.ObjectMeta{Name: "ledger", Namespace: "payments"}},
+	)
+	return func(clusterName string) (kubernetes.Interface, error) {
+		if clusterName != "" && clusterName != "prod" {
+			return nil, errors.New("cluster not found: " + clusterName)
+		}
+		return client, nil
+	}
+}
+
+func TestNamespaceValidator_Strict(t *testing.T) {
+	v, err := NewNamespaceValidator(fakeClusterClients(t), NamespacePolicyStrict, zaptest.NewLogger(t))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ctx := context.Background()
+
+	// Apps match by workload name or app label
+	for _, app := range []string{"billing", "billing-api", "ledger"} {
+		if err := v.Validate(ctx, app, "payments", "prod"); err != nil {
+			t.Errorf("expected %s in payments to be valid, got %v", app, err)
+		}
+	}
+	if err := v.Validate(ctx, "billing", "", "prod"); err != nil {
+		t.Errorf("expected apps without a namespace to skip validation, got %v", err)
+	}
+
+	if err := v.Validate(ctx, "billing", "paymnets", "prod"); !errors.Is(err, ErrNamespaceNotFound) {
+		t.Errorf("expected ErrNamespaceNotFound for a typo'd namespace, got %v", err)
+	}
+	if err := v.Validate(ctx, "checkout", "payments", "prod"); !errors.Is(err, ErrAppNotInNamespace) {
+		t.Errorf("expected ErrAppNotInNamespace, got %v", err)
+	}
+}
+
+func TestNamespaceValidator_StrictAllowsUnreachableClusters(t *testing.T) {
+	core, logs := observer.New(zap.WarnLevel)
+	v, err := NewNamespaceValidator(fakeClusterClients(t), NamespacePolicyStrict, zap.New(core))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Only a missing namespace or app is a validation failure, errors reaching
+	// the cluster are logged and the app registered
+	if err := v.Validate(context.Background(), "billing", "payments", "staging"); err != nil {
+		t.Fatalf("expected an unreachable cluster not to block registration, got %v", err)
+	}
+	if logs.Len() != 1 || !strings.Contains(logs.All()[0].ContextMap()["error"].(string), "staging") {
+		t.Errorf("expected a warning about the unreachable cluster, got %v", logs.All())
+	}
+}
+
+func TestNamespaceValidator_WarnRegistersAnyway(t *testing.T) {
+	core, logs := observer.New(zap.WarnLevel)
+	v, err := NewNamespaceValidator(fakeClusterClients(t), NamespacePolicyWarn, zap.New(core))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := v.Validate(context.Background(), "billing", "paymnets", "prod"); err != nil {
+		t.Fatalf("expected the warn policy to allow registration, got %v", err)
+	}
+	if logs.Len() != 1 || logs.All()[0].ContextMap()["namespace"] != "paymnets" {
+		t.Errorf("expected a warning about the missing namespace, got %v", logs.All())
+	}
+}
+
+func TestNamespaceValidator_InvalidPolicy(t *testing.T) {
+	if _, err := NewNamespaceValidator(fakeClusterClients(t), "sometimes", zaptest.NewLogger(t)); err == nil {
+		t.Error("expected an error for an unknown policy")
+	}
+}
+
+func TestClientAppManager_RegisterRejectsMissingNamespace(t *testing.T) {
+	mgr := NewClientAppManager(NewMockCatalog(), zaptest.NewLogger(t))
+	v, _ := NewNamespaceValidator(fakeClusterClients(t), NamespacePolicyStrict, zaptest.NewLogger(t))
+	mgr.SetNamespaceValidator(v)
+
+	_, err := mgr.RegisterClientApp(context.Background(), "billing", "", "billing", "db.local", "5432", "app", "secret", "", "paymnets", "prod")
+	if !errors.Is(err, ErrNamespaceNotFound) {
+		t.Fatalf("expected ErrNamespaceNotFound, got %v", err)
+	}
+	if apps, _ := mgr.ListClientApps(); len(apps) != 0 {
+		t.Errorf("expected no app to be registered, got %d", len(apps))
+	}
+}
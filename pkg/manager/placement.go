@@ -0,0 +1,95 @@
+package manager
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/sharding-system/pkg/config"
+	"github.com/sharding-system/pkg/models"
+	"github.com/sharding-system/pkg/monitoring"
+)
+
+// PlacementStrategy chooses the host a new shard should be created on
+type PlacementStrategy interface {
+	// SelectHost picks a host given the shards that already exist
+	SelectHost(existing []models.Shard) (string, error)
+}
+
+// ShardLoadSource provides recent load metrics for shards
+type ShardLoadSource interface {
+	GetMetrics(shardID string) (*monitoring.ShardMetrics, bool)
+}
+
+// WeightedPlacement places shards on the host with the lowest load relative to
+// its declared capacity weight. Each shard counts as one unit of load plus its
+// recent CPU usage, so busy shards weigh more than idle ones.
+type WeightedPlacement struct {
+	hosts []config.PlacementHost
+	load  ShardLoadSource
+}
+
+// NewWeightedPlacement creates a weighted placement strategy. Hosts without a
+// positive weight default to 1. load may be nil, in which case only shard
+// counts are considered.
+func NewWeightedPlacement(hosts []config.PlacementHost, load ShardLoadSource) *WeightedPlacement {
+	normalized := make([]config.PlacementHost, 0, len(hosts))
+	for _, h := range hosts {
+		if h.Weight <= 0 {
+			h.Weight = 1
+		}
+		normalized = append(normalized, h)
+	}
+	return &WeightedPlacement{
+		hosts: normalized,
+		load:  load,
+	}
+}
+
+// SelectHost returns the least-loaded host. Ties go to the host declared first.
+func (p *WeightedPlacement) SelectHost(existing []models.Shard) (string, error) {
+	if len(p.hosts) == 0 {
+		return "", fmt.Errorf("no placement hosts configured")
+	}
+
+	hostLoad := make(map[string]float64, len(p.hosts))
+	for _, shard := range existing {
+		if shard.Status == "inactive" {
+			continue
+		}
+		hostLoad[shardHost(&shard)] += p.shardCost(shard.ID)
+	}
+
+	best := ""
+	bestScore := 0.0
+	for _, h := range p.hosts {
+		score := hostLoad[h.Host] / h.Weight
+		if best == "" || score < bestScore {
+			best = h.Host
+			bestScore = score
+		}
+	}
+	return best, nil
+}
+
+// shardCost returns the load a shard contributes to its host
+func (p *WeightedPlacement) shardCost(shardID string) float64 {
+	cost := 1.0
+	if p.load != nil {
+		if metrics, ok := p.load.GetMetrics(shardID); ok {
+			cost += metrics.CPUUsage / 100
+		}
+	}
+	return cost
+}
+
+// shardHost returns the host a shard lives on, falling back to the host in
+// its primary endpoint DSN
+func shardHost(shard *models.Shard) string {
+	if shard.Host != "" {
+		return shard.Host
+	}
+	if u, err := url.Parse(shard.PrimaryEndpoint); err == nil && u.Hostname() != "" {
+		return u.Hostname()
+	}
+	return ""
+}
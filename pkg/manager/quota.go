@@ -0,0 +1,118 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/sharding-system/pkg/pricing"
+	"go.uber.org/zap"
+)
+
+// ErrStorageQuotaExceeded is returned when a client app's shards hold more
+// data than its tier allows
+var ErrStorageQuotaExceeded = errors.New("storage quota exceeded")
+
+// StorageQuotaStatus is a client app's storage against its tier's quota
+type StorageQuotaStatus struct {
+	ClientAppID string `json:"client_app_id"`
+	Tier        string `json:"tier"`
+	UsedBytes   int64  `json:"used_bytes"`
+	QuotaBytes  int64  `json:"quota_bytes"` // -1 when the tier sets no quota
+	OverQuota   bool   `json:"over_quota"`
+}
+
+// StorageQuotaObserver receives the storage status of every client app on each
+// quota check, e.g. to export it as metrics that alerts are built on. Apps
+// that have been deleted since the previous check are removed.
+type StorageQuotaObserver interface {
+	SetClientAppStorage(clientAppID string, usedBytes, quotaBytes int64)
+	DeleteClientAppStorage(clientAppID string)
+}
+
+// CheckStorageQuotas sums the database size of each client app's shards and
+// compares it with the app's storage quota. Apps over quota are logged as
+// warnings.
+func (m *Manager) CheckStorageQuotas() []StorageQuotaStatus {
+	statuses, _, err := m.checkStorageQuotas()
+	if err != nil {
+		m.logger.Warn("failed to list client apps for storage quota check", zap.Error(err))
+	}
+	return statuses
+}
+
+// checkStorageQuotas returns the storage status of each client app whose
+// shards could be listed, along with the IDs of all client apps
+func (m *Manager) checkStorageQuotas() ([]StorageQuotaStatus, map[string]bool, error) {
+	apps, err := m.clientAppMgr.ListClientApps()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	statuses := make([]StorageQuotaStatus, 0, len(apps))
+	ids := make(map[string]bool, len(apps))
+	for _, app := range apps {
+		ids[app.ID] = true
+		shards, err := m.ListShardsForClient(app.ID)
+		if err != nil {
+			m.logger.Warn("failed to list shards for storage quota check",
+				zap.String("client_app_id", app.ID),
+				zap.Error(err))
+			continue
+		}
+		_, used, _ := m.shardUsage(shards)
+
+		tier := m.tierFor(app.ID)
+		quota := pricing.GetLimits(tier).MaxStorageBytes
+		status := StorageQuotaStatus{
+			ClientAppID: app.ID,
+			Tier:        tier,
+			UsedBytes:   used,
+			QuotaBytes:  quota,
+			OverQuota:   quota != -1 && used > quota,
+		}
+		if status.OverQuota {
+			m.logger.Warn("client app is over its storage quota; new shards are refused",
+				zap.String("client_app_id", app.ID),
+				zap.String("tier", tier),
+				zap.Int64("used_bytes", used),
+				zap.Int64("quota_bytes", quota))
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, ids, nil
+}
+
+// WatchStorageQuotas checks storage quotas every interval until ctx is
+// cancelled, reporting each app's status to observer if one is given
+func (m *Manager) WatchStorageQuotas(ctx context.Context, interval time.Duration, observer StorageQuotaObserver) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	reported := make(map[string]bool)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			statuses, ids, err := m.checkStorageQuotas()
+			if err != nil {
+				m.logger.Warn("failed to list client apps for storage quota check", zap.Error(err))
+				continue
+			}
+			if observer == nil {
+				continue
+			}
+			for _, status := range statuses {
+				observer.SetClientAppStorage(status.ClientAppID, status.UsedBytes, status.QuotaBytes)
+				reported[status.ClientAppID] = true
+			}
+			for id := range reported {
+				if !ids[id] {
+					observer.DeleteClientAppStorage(id)
+					delete(reported, id)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sharding-system/pkg/models"
+)
+
+// recordingQuotaObserver remembers the last storage reported for each app
+type recordingQuotaObserver struct {
+	mu      sync.Mutex
+	storage map[string][2]int64
+}
+
+func (r *recordingQuotaObserver) SetClientAppStorage(clientAppID string, usedBytes, quotaBytes int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.storage[clientAppID] = [2]int64{usedBytes, quotaBytes}
+}
+
+func (r *recordingQuotaObserver) DeleteClientAppStorage(clientAppID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.storage, clientAppID)
+}
+
+func (r *recordingQuotaObserver) reported(clientAppID string) ([2]int64, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	storage, ok := r.storage[clientAppID]
+	return storage, ok
+}
+
+// overQuotaStats puts 1.5GiB across two shards, over the free tier's 1GiB quota
+var overQuotaStats = fakeStatsSource{
+	"shard0": {Size: 1 << 30},
+	"shard1": {Size: 512 << 20},
+}
+
+func TestManager_CreateShard_StorageQuotaExceeded(t *testing.T) {
+	manager, _ := newTierTestManager(t, "free", 1)
+	req := &models.CreateShardRequest{
+		Name:            "orders-1",
+		ClientAppID:     "app1",
+		PrimaryEndpoint: "postgres://localhost/orders",
+	}
+
+	// Exactly at the quota is not over it, as CheckStorageQuotas reports too.
+	// The shard still fails to connect to its database, which is checked after the quota.
+	manager.SetShardStatsSource(fakeStatsSource{"shard0": {Size: 1 << 30}})
+	if _, err := manager.CreateShard(context.Background(), req); errors.Is(err, ErrStorageQuotaExceeded) {
+		t.Fatalf("expected storage at the quota to be allowed, got %v", err)
+	}
+	if statuses := manager.CheckStorageQuotas(); statuses[0].OverQuota {
+		t.Errorf("expected storage at the quota not to be flagged, got %+v", statuses[0])
+	}
+
+	manager.SetShardStatsSource(fakeStatsSource{"shard0": {Size: 1<<30 + 1}})
+	if _, err := manager.CreateShard(context.Background(), req); !errors.Is(err, ErrStorageQuotaExceeded) {
+		t.Fatalf("expected ErrStorageQuotaExceeded, got %v", err)
+	}
+	if statuses := manager.CheckStorageQuotas(); !statuses[0].OverQuota {
+		t.Errorf("expected storage over the quota to be flagged, got %+v", statuses[0])
+	}
+}
+
+func TestManager_StorageQuotaReported(t *testing.T) {
+	manager, _ := newTierTestManager(t, "free", 2)
+	manager.SetShardStatsSource(overQuotaStats)
+
+	report, err := manager.ClientAppUsage("app1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if report.StorageBytes.Used != 3<<29 || report.StorageBytes.Limit != 1<<30 {
+		t.Errorf("expected 1.5GiB of a 1GiB quota, got %+v", report.StorageBytes)
+	}
+	if !report.StorageBytes.OverLimit || report.StorageBytes.PercentUsed != 150 {
+		t.Errorf("expected storage to be 150%% and over the limit, got %+v", report.StorageBytes)
+	}
+	if report.Shards.OverLimit {
+		t.Errorf("expected 2 of 2 shards to be within the limit, got %+v", report.Shards)
+	}
+
+	statuses := manager.CheckStorageQuotas()
+	if len(statuses) != 1 || !statuses[0].OverQuota || statuses[0].UsedBytes != 3<<29 {
+		t.Fatalf("expected app1 to be flagged over quota, got %+v", statuses)
+	}
+
+	// An upgrade lifts the quota, and a downgrade back is refused while over it
+	if _, err := manager.SetClientAppTier("app1", "pro", false); err != nil {
+		t.Fatalf("failed to upgrade: %v", err)
+	}
+	if statuses := manager.CheckStorageQuotas(); statuses[0].OverQuota {
+		t.Errorf("expected the pro tier's quota to fit 1.5GiB, got %+v", statuses[0])
+	}
+	if _, err := manager.SetClientAppTier("app1", "free", false); !errors.Is(err, ErrTierLimitExceeded) {
+		t.Errorf("expected a downgrade over the storage quota to be refused, got %v", err)
+	}
+}
+
+func TestManager_WatchStorageQuotas(t *testing.T) {
+	manager, _ := newTierTestManager(t, "free", 2)
+	manager.SetShardStatsSource(fakeStatsSource{
+		"shard0": {Size: 100 << 20},
+		"shard1": {Size: 100 << 20},
+	})
+
+	observer := &recordingQuotaObserver{storage: make(map[string][2]int64)}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		manager.WatchStorageQuotas(ctx, time.Millisecond, observer)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	if got, _ := observer.reported("app1"); got != [2]int64{200 << 20, 1 << 30} {
+		t.Errorf("expected 200MiB of a 1GiB quota to be reported, got %v", got)
+	}
+
+	// A deleted app's storage is no longer reported
+	if err := manager.clientAppMgr.DeleteClientApp("app1"); err != nil {
+		t.Fatalf("failed to delete the app: %v", err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if got, ok := observer.reported("app1"); ok {
+		t.Errorf("expected the deleted app's storage to be removed, got %v", got)
+	}
+}
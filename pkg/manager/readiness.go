@@ -0,0 +1,72 @@
+package manager
+
+import (
+	"context"
+	"time"
+
+	"github.com/sharding-system/pkg/models"
+	"go.uber.org/zap"
+)
+
+// WhenTargetsReady calls ready with each target shard of a resharding job
+// once the job has cut over to it and it is active, checking every interval.
+// Targets are only filled while the job runs and may not be reachable before
+// cutover, so anything connecting to them should wait for this. A target that
+// ready returns an error for, e.g. because it cannot be reached yet, is tried
+// again on the next check. It returns once every target was handed to ready
+// or left the catalog, the job failed, or ctx is done.
+func (m *Manager) WhenTargetsReady(ctx context.Context, jobID string, interval time.Duration, ready func(shard *models.Shard) error) error {
+	job, err := m.GetReshardJob(jobID)
+	if err != nil {
+		return err
+	}
+	m.mu.RLock()
+	pending := append([]string(nil), job.TargetShards...)
+	m.mu.RUnlock()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		waiting := pending[:0]
+		for _, shardID := range pending {
+			shard, err := m.catalog.GetShardByID(shardID)
+			if err != nil {
+				m.logger.Debug("target shard left the catalog before becoming ready",
+					zap.String("job_id", jobID),
+					zap.String("shard_id", shardID))
+				continue
+			}
+			if shard.Status != models.ShardStatusActive {
+				waiting = append(waiting, shardID)
+				continue
+			}
+			if err := ready(shard); err != nil {
+				m.logger.Debug("target shard not ready yet",
+					zap.String("job_id", jobID),
+					zap.String("shard_id", shardID),
+					zap.Error(err))
+				waiting = append(waiting, shardID)
+			}
+		}
+		pending = waiting
+		if len(pending) == 0 {
+			return nil
+		}
+
+		m.mu.RLock()
+		failed := job.Status == "failed"
+		m.mu.RUnlock()
+		if failed {
+			m.logger.Info("resharding job failed before its targets became ready",
+				zap.String("job_id", jobID),
+				zap.Strings("shard_ids", pending))
+			return nil
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
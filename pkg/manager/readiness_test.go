@@ -0,0 +1,151 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sharding-system/pkg/models"
+)
+
+// readyRecorder records the shards WhenTargetsReady hands over
+type readyRecorder struct {
+	mu    sync.Mutex
+	ready []string
+	err   error // Returned for every shard while set
+}
+
+func (r *readyRecorder) register(shard *models.Shard) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return r.err
+	}
+	r.ready = append(r.ready, shard.ID)
+	return nil
+}
+
+func (r *readyRecorder) registered() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.ready...)
+}
+
+func (r *readyRecorder) setErr(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.err = err
+}
+
+// newReadinessTestManager returns a manager with a split job whose two
+// targets are still being filled
+func newReadinessTestManager(t *testing.T) (*Manager, *MockCatalog, *models.ReshardJob) {
+	t.Helper()
+	manager, catalog := newTierTestManager(t, "pro", 1)
+	for _, id := range []string{"target0", "target1"} {
+		catalog.shards[id] = &models.Shard{ID: id, ClientAppID: "app1", Status: models.ShardStatusMigrating}
+	}
+	job := &models.ReshardJob{ID: "job1", Type: "split", SourceShards: []string{"shard0"}, TargetShards: []string{"target0", "target1"}, Status: "copying"}
+	manager.jobs[job.ID] = job
+	return manager, catalog, job
+}
+
+// cutOver makes a target active, as the resharder does at cutover
+func cutOver(catalog *MockCatalog, shardID string) {
+	catalog.UpdateShard(&models.Shard{ID: shardID, ClientAppID: "app1", Status: models.ShardStatusActive})
+}
+
+// waitFor polls cond until it holds, failing the test after a second
+func waitFor(t *testing.T, cond func() bool, what string) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestManager_WhenTargetsReady_WaitsForCutover(t *testing.T) {
+	manager, catalog, job := newReadinessTestManager(t)
+	recorder := &readyRecorder{}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- manager.WhenTargetsReady(context.Background(), job.ID, time.Millisecond, recorder.register)
+	}()
+
+	// Targets still being filled are not handed over
+	time.Sleep(20 * time.Millisecond)
+	if got := recorder.registered(); len(got) != 0 {
+		t.Fatalf("expected no target to register before cutover, got %v", got)
+	}
+
+	cutOver(catalog, "target1")
+	waitFor(t, func() bool { return len(recorder.registered()) == 1 }, "target1 to register")
+	if got := recorder.registered(); got[0] != "target1" {
+		t.Errorf("expected only target1 to register, got %v", got)
+	}
+
+	cutOver(catalog, "target0")
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected to return once every target registered")
+	}
+	if got := recorder.registered(); len(got) != 2 || got[1] != "target0" {
+		t.Errorf("expected target1 and then target0 to register, got %v", got)
+	}
+}
+
+func TestManager_WhenTargetsReady_RetriesUnreachableTargets(t *testing.T) {
+	manager, catalog, job := newReadinessTestManager(t)
+	cutOver(catalog, "target0")
+	catalog.DeleteShard("target1")
+	recorder := &readyRecorder{err: errors.New("connection refused")}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- manager.WhenTargetsReady(context.Background(), job.ID, time.Millisecond, recorder.register)
+	}()
+
+	// An active target that cannot be registered yet is tried again
+	time.Sleep(20 * time.Millisecond)
+	recorder.setErr(nil)
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected to return once the target registered")
+	}
+	if got := recorder.registered(); len(got) != 1 || got[0] != "target0" {
+		t.Errorf("expected target0 to register and the deleted target1 to be dropped, got %v", got)
+	}
+}
+
+func TestManager_WhenTargetsReady_StopsWhenJobFails(t *testing.T) {
+	manager, _, job := newReadinessTestManager(t)
+	manager.mu.Lock()
+	job.Status = "failed"
+	manager.mu.Unlock()
+	recorder := &readyRecorder{}
+
+	if err := manager.WhenTargetsReady(context.Background(), job.ID, time.Millisecond, recorder.register); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := recorder.registered(); len(got) != 0 {
+		t.Errorf("expected the targets of a failed job not to register, got %v", got)
+	}
+
+	if err := manager.WhenTargetsReady(context.Background(), "missing", time.Millisecond, recorder.register); err == nil {
+		t.Error("expected an unknown job to be refused")
+	}
+}
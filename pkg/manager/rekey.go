@@ -0,0 +1,157 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sharding-system/pkg/catalog"
+	"github.com/sharding-system/pkg/models"
+	"go.uber.org/zap"
+)
+
+// Rekeyer moves a client application's rows onto shards placed by a new
+// shard key column. The resharder implements it.
+type Rekeyer interface {
+	Rekey(ctx context.Context, job *models.ReshardJob) error
+}
+
+// RekeyClientApp starts changing a client application's shard key. Every
+// active shard of the app is a source; the requested target shards form the
+// new key space, placed by hash of the new shard key column. Rows are copied
+// onto the targets, verified, and at cutover the targets take the app's
+// traffic while the sources are taken out of routing with their data kept.
+// A rekey that fails before cutover deletes its targets.
+func (m *Manager) RekeyClientApp(ctx context.Context, req *models.RekeyRequest) (*models.ReshardJob, error) {
+	if _, ok := m.resharder.(Rekeyer); !ok {
+		return nil, fmt.Errorf("rekeying is not supported: the resharder cannot rekey")
+	}
+	if req.ClientAppID == "" || req.ShardKey == "" {
+		return nil, fmt.Errorf("client_app_id and shard_key are required")
+	}
+	if len(req.TargetShards) == 0 {
+		return nil, fmt.Errorf("at least one target shard is required")
+	}
+
+	shards, err := m.ListShardsForClient(req.ClientAppID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list shards for client application: %w", err)
+	}
+	sourceIDs := make([]string, 0, len(shards))
+	for _, shard := range shards {
+		switch shard.Status {
+		case models.ShardStatusActive:
+			if shard.ShardKey == req.ShardKey {
+				return nil, fmt.Errorf("shard %s is already keyed by %s", shard.ID, req.ShardKey)
+			}
+			sourceIDs = append(sourceIDs, shard.ID)
+		case models.ShardStatusInactive:
+			// Out of routing, e.g. the sources of an earlier rekey
+		default:
+			return nil, fmt.Errorf("shard %s is %s; rekeying needs every shard of the app active", shard.ID, shard.Status)
+		}
+	}
+	if len(sourceIDs) == 0 {
+		return nil, fmt.Errorf("client application %s has no active shards", req.ClientAppID)
+	}
+
+	// Create the new key space, taking no traffic until the resharder cuts over to it
+	targetIDs := make([]string, 0, len(req.TargetShards))
+	for _, targetReq := range req.TargetShards {
+		targetReq.ClientAppID = req.ClientAppID
+		targetReq.ShardKey = req.ShardKey
+		targetReq.Strategy = "hash"
+		targetReq.KeyRangeStart, targetReq.KeyRangeEnd = "", ""
+		targetReq.Status = models.ShardStatusMigrating
+		shard, err := m.CreateShard(ctx, &targetReq)
+		if err != nil {
+			m.deleteRekeyTargets(targetIDs)
+			return nil, fmt.Errorf("failed to create target shard: %w", err)
+		}
+		targetIDs = append(targetIDs, shard.ID)
+	}
+
+	job := &models.ReshardJob{
+		ID:           uuid.New().String(),
+		Type:         "rekey",
+		SourceShards: sourceIDs,
+		TargetShards: targetIDs,
+		Status:       "pending",
+		Progress:     0.0,
+		StartedAt:    time.Now(),
+		ShardKey:     req.ShardKey,
+	}
+
+	m.mu.Lock()
+	m.jobs[job.ID] = job
+	m.mu.Unlock()
+
+	for _, shardID := range sourceIDs {
+		m.RecordShardEvent(shardID, catalog.ShardEventRekeyStarted, fmt.Sprintf("rekey by %s onto %d shards started", req.ShardKey, len(targetIDs)), map[string]string{
+			"job_id":    job.ID,
+			"shard_key": req.ShardKey,
+			"targets":   strings.Join(targetIDs, ","),
+		})
+	}
+
+	// The rekey outlives the request that started it
+	go m.executeReshard(context.Background(), job)
+
+	m.logger.Info("started rekey operation",
+		zap.String("job_id", job.ID),
+		zap.String("client_app_id", req.ClientAppID),
+		zap.String("shard_key", req.ShardKey))
+	return job, nil
+}
+
+// finishRekey settles the shards of a rekey the resharder is done with, given
+// its error. Once every target is cut over the targets serve the app, so the
+// sources are retired, even when the checks after cutover failed. While every
+// target is still migrating the app is on its sources and the targets are
+// deleted. A partial cutover is left for an operator to resolve.
+func (m *Manager) finishRekey(job *models.ReshardJob, err error) error {
+	migrating, active := 0, 0
+	for _, targetID := range job.TargetShards {
+		target, getErr := m.catalog.GetShardByID(targetID)
+		if getErr != nil {
+			continue
+		}
+		switch target.Status {
+		case models.ShardStatusMigrating:
+			migrating++
+		case models.ShardStatusActive:
+			active++
+		}
+	}
+
+	switch {
+	case active == len(job.TargetShards):
+		for _, sourceID := range job.SourceShards {
+			if retireErr := m.UpdateShardStatus(sourceID, models.ShardStatusInactive); retireErr != nil && err == nil {
+				err = fmt.Errorf("failed to retire source shard %s: %w", sourceID, retireErr)
+			}
+		}
+	case err != nil && migrating == len(job.TargetShards):
+		m.deleteRekeyTargets(job.TargetShards)
+	case err != nil:
+		m.logger.Error("rekey failed during cutover, leaving its shards for an operator",
+			zap.String("job_id", job.ID),
+			zap.Int("targets_active", active),
+			zap.Int("targets", len(job.TargetShards)))
+	}
+	return err
+}
+
+// deleteRekeyTargets deletes the target shards of a rekey that was abandoned
+// before cutover
+func (m *Manager) deleteRekeyTargets(targetIDs []string) {
+	for _, targetID := range targetIDs {
+		if err := m.DeleteShard(targetID); err != nil {
+			m.logger.Error("failed to delete target shard of abandoned rekey",
+				zap.String("shard_id", targetID),
+				zap.Error(err))
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/sharding-system/pkg/config"
+	"github.com/sharding-system/pkg/models"
+	"go.uber.org/zap/zaptest"
+)
+
+// rekeyResharder rekeys by cutting the targets over, or fails before cutover
+type rekeyResharder struct {
+	MockResharder
+	catalog *MockCatalog
+	err     error
+}
+
+func (r *rekeyResharder) Rekey(ctx context.Context, job *models.ReshardJob) error {
+	if r.err != nil {
+		return r.err
+	}
+	for _, shardID := range job.SourceShards {
+		source, _ := r.catalog.GetShardByID(shardID)
+		source.Status = models.ShardStatusReadOnly
+	}
+	for _, shardID := range job.TargetShards {
+		target, _ := r.catalog.GetShardByID(shardID)
+		target.Status = models.ShardStatusActive
+	}
+	return nil
+}
+
+func newRekeyTestManager(t *testing.T, rekeyErr error) (*Manager, *MockCatalog, *models.ReshardJob) {
+	catalog := NewMockCatalog()
+	manager := NewManager(catalog, zaptest.NewLogger(t), &rekeyResharder{catalog: catalog, err: rekeyErr}, config.PricingConfig{Tier: "pro"})
+
+	catalog.CreateShard(&models.Shard{ID: "source", ClientAppID: "app", Status: models.ShardStatusActive, ShardKey: "id"})
+	catalog.CreateShard(&models.Shard{ID: "target-1", ClientAppID: "app", Status: models.ShardStatusMigrating, ShardKey: "tenant_id"})
+	catalog.CreateShard(&models.Shard{ID: "target-2", ClientAppID: "app", Status: models.ShardStatusMigrating, ShardKey: "tenant_id"})
+	job := &models.ReshardJob{ID: "rekey-job", Type: "rekey", ShardKey: "tenant_id",
+		SourceShards: []string{"source"}, TargetShards: []string{"target-1", "target-2"}}
+	return manager, catalog, job
+}
+
+func TestManager_RekeyRetiresSourcesAfterCutover(t *testing.T) {
+	manager, catalog, job := newRekeyTestManager(t, nil)
+
+	manager.executeReshard(context.Background(), job)
+	if job.Status != "completed" {
+		t.Fatalf("expected the rekey to complete, got %s: %s", job.Status, job.ErrorMessage)
+	}
+	if source, _ := catalog.GetShardByID("source"); source.Status != models.ShardStatusInactive {
+		t.Errorf("expected the source to be retired, got %s", source.Status)
+	}
+}
+
+func TestManager_FailedRekeyDeletesTargets(t *testing.T) {
+	manager, catalog, job := newRekeyTestManager(t, errors.New("verification failed"))
+
+	manager.executeReshard(context.Background(), job)
+	if job.Status != "failed" {
+		t.Fatalf("expected the rekey to fail, got %s", job.Status)
+	}
+	for _, targetID := range job.TargetShards {
+		if _, err := catalog.GetShardByID(targetID); err == nil {
+			t.Errorf("expected target %s of the failed rekey to be deleted", targetID)
+		}
+	}
+	if source, _ := catalog.GetShardByID("source"); source.Status != models.ShardStatusActive {
+		t.Errorf("expected the source to keep serving, got %s", source.Status)
+	}
+}
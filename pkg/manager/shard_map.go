@@ -0,0 +1,100 @@
+package manager
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/sharding-system/pkg/models"
+)
+
+// MaxShardMapWait caps how long a shard-map request may long-poll. It stays
+// below the default server write timeout so the response can still be sent.
+const MaxShardMapWait = 25 * time.Second
+
+// defaultShardMapPollInterval is how often a long-poll re-checks the catalog
+const defaultShardMapPollInterval = 500 * time.Millisecond
+
+// ShardMap is the routing view of a client application's shards that smart
+// clients cache locally
+type ShardMap struct {
+	ClientAppID string         `json:"client_app_id"`
+	Version     string         `json:"version"` // Opaque token; changes whenever the map changes
+	Shards      []models.Shard `json:"shards"`
+}
+
+// GetShardMap returns the shard map for a client application. If knownVersion
+// matches the current map and wait is positive, it blocks until the map changes,
+// wait elapses or ctx is cancelled. The returned bool reports whether the map
+// differs from knownVersion.
+func (m *Manager) GetShardMap(ctx context.Context, clientAppID, knownVersion string, wait time.Duration) (*ShardMap, bool, error) {
+	shardMap, err := m.buildShardMap(clientAppID)
+	if err != nil {
+		return nil, false, err
+	}
+	if knownVersion == "" || shardMap.Version != knownVersion || wait <= 0 {
+		return shardMap, shardMap.Version != knownVersion, nil
+	}
+
+	if wait > MaxShardMapWait {
+		wait = MaxShardMapWait
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	interval := m.shardMapPoll
+	if interval <= 0 {
+		interval = defaultShardMapPollInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return shardMap, false, ctx.Err()
+		case <-timer.C:
+			return shardMap, false, nil
+		case <-ticker.C:
+			current, err := m.buildShardMap(clientAppID)
+			if err != nil {
+				return nil, false, err
+			}
+			if current.Version != knownVersion {
+				return current, true, nil
+			}
+			shardMap = current
+		}
+	}
+}
+
+// buildShardMap snapshots the client's shards in a stable order and derives
+// the version token from their contents. Clients route with the map but
+// connect with their own credentials, so the shards' are left out.
+func (m *Manager) buildShardMap(clientAppID string) (*ShardMap, error) {
+	shards, err := m.catalog.ListShards(clientAppID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list shards: %w", err)
+	}
+	for i := range shards {
+		shards[i] = shards[i].WithoutCredentials()
+	}
+
+	sort.Slice(shards, func(i, j int) bool { return shards[i].ID < shards[j].ID })
+
+	data, err := json.Marshal(shards)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode shard map: %w", err)
+	}
+	sum := sha256.Sum256(data)
+
+	return &ShardMap{
+		ClientAppID: clientAppID,
+		Version:     hex.EncodeToString(sum[:8]),
+		Shards:      shards,
+	}, nil
+}
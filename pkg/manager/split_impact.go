@@ -0,0 +1,184 @@
+package manager
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/sharding-system/pkg/models"
+)
+
+// AnalyzeSplit reports what splitting a shard would do without creating any
+// shard or job: the rows and bytes to copy, each target's key range and
+// expected share of them, and how long the copy would take. Range targets are
+// assumed to receive half the rows each; hash targets receive rows in
+// proportion to their vnodes. Rows and bytes come from the source's latest
+// collected stats.
+func (m *Manager) AnalyzeSplit(req *models.SplitImpactRequest) (*models.SplitImpact, error) {
+	source, err := m.catalog.GetShardByID(req.SourceShardID)
+	if err != nil {
+		return nil, fmt.Errorf("source shard not found: %w", err)
+	}
+	if source.Status != "active" {
+		return nil, fmt.Errorf("source shard is not active: %s", source.Status)
+	}
+	if len(req.TargetShards) == 0 {
+		return nil, fmt.Errorf("at least one target shard is required")
+	}
+
+	// Work on a copy, splitKeyRange fills in the targets' ranges
+	split := req.SplitRequest
+	split.TargetShards = append([]models.CreateShardRequest(nil), req.TargetShards...)
+	if source.Strategy == "range" {
+		if err := splitKeyRange(source, &split); err != nil {
+			return nil, err
+		}
+	}
+
+	impact := &models.SplitImpact{
+		SourceShardID: source.ID,
+		Strategy:      source.Strategy,
+		SplitKey:      split.SplitKey,
+		Targets:       make([]models.SplitTargetImpact, 0, len(split.TargetShards)),
+	}
+	if impact.Strategy == "" {
+		impact.Strategy = "hash"
+	}
+
+	m.mu.RLock()
+	statsSource := m.stats
+	m.mu.RUnlock()
+	if statsSource != nil {
+		if stats, err := statsSource.GetStats(source.ID); err == nil {
+			impact.RowsToMove = stats.Tables.TotalRows
+			impact.BytesToMove = stats.Size
+		}
+	}
+	if impact.RowsToMove == 0 && impact.BytesToMove == 0 {
+		impact.Warnings = append(impact.Warnings, fmt.Sprintf("no stats collected for shard %s: data to move is unknown", source.ID))
+	}
+
+	shares := splitShares(source, split.TargetShards)
+	for i, target := range split.TargetShards {
+		impact.Targets = append(impact.Targets, models.SplitTargetImpact{
+			Name:           target.Name,
+			KeyRangeStart:  target.KeyRangeStart,
+			KeyRangeEnd:    target.KeyRangeEnd,
+			Share:          shares[i],
+			EstimatedRows:  int64(float64(impact.RowsToMove) * shares[i]),
+			EstimatedBytes: int64(float64(impact.BytesToMove) * shares[i]),
+		})
+	}
+
+	impact.CopyRowsPerSecond, impact.ThroughputSource = req.CopyRowsPerSecond, "request"
+	if impact.CopyRowsPerSecond <= 0 {
+		impact.CopyRowsPerSecond, impact.ThroughputSource = m.measuredCopyThroughput()
+	}
+
+	start := time.Now()
+	if req.StartAt != nil {
+		start = *req.StartAt
+	}
+	var completion time.Time
+	if impact.CopyRowsPerSecond > 0 {
+		impact.EstimatedCopySeconds = float64(impact.RowsToMove) / impact.CopyRowsPerSecond
+		completion = start.Add(time.Duration(impact.EstimatedCopySeconds * float64(time.Second)))
+		impact.EstimatedCompletion = &completion
+	} else {
+		impact.Warnings = append(impact.Warnings, "no copy throughput measured yet: pass copy_rows_per_second to estimate the copy time")
+	}
+
+	if req.MaintenanceWindow != nil {
+		warnings, err := maintenanceWindowWarnings(*req.MaintenanceWindow, start, completion)
+		if err != nil {
+			return nil, err
+		}
+		impact.Warnings = append(impact.Warnings, warnings...)
+	}
+	return impact, nil
+}
+
+// splitShares returns the fraction of the source's rows each target would receive
+func splitShares(source *models.Shard, targets []models.CreateShardRequest) []float64 {
+	shares := make([]float64, len(targets))
+	if source.Strategy == "range" {
+		for i := range shares {
+			shares[i] = 1 / float64(len(targets))
+		}
+		return shares
+	}
+
+	// Hash keys land on the ring in proportion to each shard's vnodes
+	total := 0
+	for _, target := range targets {
+		total += vnodesOf(target)
+	}
+	for i, target := range targets {
+		shares[i] = float64(vnodesOf(target)) / float64(total)
+	}
+	return shares
+}
+
+// vnodesOf returns the number of vnodes CreateShard would give a shard
+func vnodesOf(req models.CreateShardRequest) int {
+	if req.VNodeCount == 0 {
+		return 256
+	}
+	return req.VNodeCount
+}
+
+// measuredCopyThroughput returns the rows per second copied by the most
+// recently completed resharding job, or zero if there is none
+func (m *Manager) measuredCopyThroughput() (float64, string) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var latest *models.ReshardJob
+	for _, job := range m.jobs {
+		if job.Status != "completed" || job.CompletedAt == nil || job.KeysMigrated == 0 || !job.CompletedAt.After(job.StartedAt) {
+			continue
+		}
+		if latest == nil || job.CompletedAt.After(*latest.CompletedAt) {
+			latest = job
+		}
+	}
+	if latest == nil {
+		return 0, ""
+	}
+	return float64(latest.KeysMigrated) / latest.CompletedAt.Sub(latest.StartedAt).Seconds(), "measured"
+}
+
+// maintenanceWindowWarnings warns if a split starting at start would begin
+// outside the window, or, when its completion is known, run past its end
+func maintenanceWindowWarnings(window models.MaintenanceWindow, start, completion time.Time) ([]string, error) {
+	opens, err := time.Parse("15:04", window.Start)
+	if err != nil {
+		return nil, fmt.Errorf("invalid maintenance window start %q: must be HH:MM", window.Start)
+	}
+	closes, err := time.Parse("15:04", window.End)
+	if err != nil {
+		return nil, fmt.Errorf("invalid maintenance window end %q: must be HH:MM", window.End)
+	}
+
+	length := closes.Sub(opens)
+	if length <= 0 {
+		length += 24 * time.Hour
+	}
+	offset := time.Duration(opens.Hour())*time.Hour + time.Duration(opens.Minute())*time.Minute
+
+	// The window containing start opened today or, if it wraps past midnight, yesterday
+	start = start.UTC()
+	day := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.UTC)
+	for _, opened := range []time.Time{day.Add(offset), day.Add(offset - 24*time.Hour)} {
+		end := opened.Add(length)
+		if start.Before(opened) || !start.Before(end) {
+			continue
+		}
+		if !completion.IsZero() && completion.After(end) {
+			return []string{fmt.Sprintf("estimated copy would finish at %s, after the maintenance window closes at %s UTC",
+				completion.UTC().Format(time.RFC3339), end.Format(time.RFC3339))}, nil
+		}
+		return nil, nil
+	}
+	return []string{fmt.Sprintf("split would start at %s, outside the maintenance window %s-%s UTC",
+		start.Format(time.RFC3339), window.Start, window.End)}, nil
+}
@@ -0,0 +1,142 @@
+package manager
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sharding-system/pkg/models"
+	"github.com/sharding-system/pkg/monitoring"
+)
+
+func TestManager_AnalyzeSplit_EstimatesFromThroughput(t *testing.T) {
+	manager, catalog := newTierTestManager(t, "pro", 1)
+	manager.SetShardStatsSource(fakeStatsSource{
+		"shard0": {Size: 8 << 20, Tables: monitoring.TableStats{TotalRows: 120000}},
+	})
+
+	start := time.Date(2024, 3, 1, 2, 0, 0, 0, time.UTC)
+	impact, err := manager.AnalyzeSplit(&models.SplitImpactRequest{
+		SplitRequest: models.SplitRequest{
+			SourceShardID: "shard0",
+			TargetShards:  []models.CreateShardRequest{{Name: "a", VNodeCount: 96}, {Name: "b", VNodeCount: 32}},
+		},
+		CopyRowsPerSecond: 400,
+		StartAt:           &start,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if impact.RowsToMove != 120000 || impact.BytesToMove != 8<<20 {
+		t.Errorf("expected 120000 rows and 8MiB to move, got %d rows and %d bytes", impact.RowsToMove, impact.BytesToMove)
+	}
+	if impact.EstimatedCopySeconds != 300 || impact.ThroughputSource != "request" {
+		t.Errorf("expected 300s from the requested throughput, got %vs from %q", impact.EstimatedCopySeconds, impact.ThroughputSource)
+	}
+	if want := start.Add(5 * time.Minute); impact.EstimatedCompletion == nil || !impact.EstimatedCompletion.Equal(want) {
+		t.Errorf("expected completion at %s, got %v", want, impact.EstimatedCompletion)
+	}
+	if impact.Targets[0].EstimatedRows != 90000 || impact.Targets[1].EstimatedRows != 30000 {
+		t.Errorf("expected rows split 3:1 by vnodes, got %+v", impact.Targets)
+	}
+	if len(impact.Warnings) != 0 {
+		t.Errorf("expected no warnings, got %v", impact.Warnings)
+	}
+
+	// Nothing is created by the analysis
+	if len(catalog.shards) != 1 || len(manager.jobs) != 0 {
+		t.Errorf("expected no shards or jobs to be created, got %d shards and %d jobs", len(catalog.shards), len(manager.jobs))
+	}
+}
+
+func TestManager_AnalyzeSplit_MeasuredThroughput(t *testing.T) {
+	manager, _ := newTierTestManager(t, "pro", 1)
+	manager.SetShardStatsSource(fakeStatsSource{"shard0": {Tables: monitoring.TableStats{TotalRows: 50000}}})
+
+	started := time.Now().Add(-time.Hour)
+	completed := started.Add(100 * time.Second)
+	manager.jobs["job1"] = &models.ReshardJob{ID: "job1", Status: "completed", StartedAt: started, CompletedAt: &completed, KeysMigrated: 25000}
+
+	impact, err := manager.AnalyzeSplit(&models.SplitImpactRequest{
+		SplitRequest: models.SplitRequest{SourceShardID: "shard0", TargetShards: []models.CreateShardRequest{{Name: "a"}, {Name: "b"}}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if impact.CopyRowsPerSecond != 250 || impact.ThroughputSource != "measured" || impact.EstimatedCopySeconds != 200 {
+		t.Errorf("expected 200s at the measured 250 rows/s, got %vs at %v rows/s (%q)",
+			impact.EstimatedCopySeconds, impact.CopyRowsPerSecond, impact.ThroughputSource)
+	}
+	if impact.Targets[0].Share != 0.5 || impact.Targets[1].Share != 0.5 {
+		t.Errorf("expected equal vnode counts to split evenly, got %+v", impact.Targets)
+	}
+}
+
+func TestManager_AnalyzeSplit_RangeTargets(t *testing.T) {
+	manager, catalog := newTierTestManager(t, "pro", 1)
+	catalog.shards["shard0"].Strategy = "range"
+	catalog.shards["shard0"].KeyRangeStart = "a"
+	catalog.shards["shard0"].KeyRangeEnd = "z"
+
+	req := &models.SplitImpactRequest{
+		SplitRequest: models.SplitRequest{
+			SourceShardID: "shard0",
+			SplitKey:      "m",
+			TargetShards:  []models.CreateShardRequest{{Name: "low"}, {Name: "high"}},
+		},
+	}
+	impact, err := manager.AnalyzeSplit(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if impact.SplitKey != "m" || impact.Targets[0].KeyRangeEnd != "m" || impact.Targets[1].KeyRangeStart != "m" {
+		t.Errorf("expected the targets to meet at the split key, got %+v", impact.Targets)
+	}
+	if req.TargetShards[0].KeyRangeEnd != "" {
+		t.Error("expected the request to be left unchanged")
+	}
+	// Without stats or throughput the estimate is reported as unknown
+	if impact.EstimatedCompletion != nil || len(impact.Warnings) != 2 {
+		t.Errorf("expected no estimate and two warnings, got %v", impact.Warnings)
+	}
+}
+
+func TestManager_AnalyzeSplit_MaintenanceWindow(t *testing.T) {
+	manager, _ := newTierTestManager(t, "pro", 1)
+	manager.SetShardStatsSource(fakeStatsSource{"shard0": {Tables: monitoring.TableStats{TotalRows: 36000}}})
+
+	analyze := func(start time.Time, rowsPerSecond float64) []string {
+		t.Helper()
+		impact, err := manager.AnalyzeSplit(&models.SplitImpactRequest{
+			SplitRequest:      models.SplitRequest{SourceShardID: "shard0", TargetShards: []models.CreateShardRequest{{Name: "a"}, {Name: "b"}}},
+			CopyRowsPerSecond: rowsPerSecond,
+			StartAt:           &start,
+			MaintenanceWindow: &models.MaintenanceWindow{Start: "23:00", End: "03:00"},
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return impact.Warnings
+	}
+
+	// 36000 rows at 20 rows/s take half an hour
+	if warnings := analyze(time.Date(2024, 3, 1, 1, 0, 0, 0, time.UTC), 20); len(warnings) != 0 {
+		t.Errorf("expected a split inside the window to pass, got %v", warnings)
+	}
+	if warnings := analyze(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC), 20); len(warnings) != 1 || !strings.Contains(warnings[0], "outside the maintenance window") {
+		t.Errorf("expected a split at noon to be outside the window, got %v", warnings)
+	}
+	// At 2 rows/s the copy takes five hours, past the window's end
+	if warnings := analyze(time.Date(2024, 3, 1, 23, 30, 0, 0, time.UTC), 2); len(warnings) != 1 || !strings.Contains(warnings[0], "after the maintenance window closes") {
+		t.Errorf("expected a copy overrunning the window to be flagged, got %v", warnings)
+	}
+
+	_, err := manager.AnalyzeSplit(&models.SplitImpactRequest{
+		SplitRequest:      models.SplitRequest{SourceShardID: "shard0", TargetShards: []models.CreateShardRequest{{Name: "a"}}},
+		MaintenanceWindow: &models.MaintenanceWindow{Start: "2am", End: "03:00"},
+	})
+	if err == nil {
+		t.Error("expected an invalid maintenance window to be rejected")
+	}
+}
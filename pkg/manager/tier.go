@@ -0,0 +1,107 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/sharding-system/pkg/pricing"
+	"go.uber.org/zap"
+)
+
+// ErrTierLimitExceeded is returned when a client app uses more than a new tier allows
+var ErrTierLimitExceeded = errors.New("current usage exceeds the tier limits")
+
+// TierChange describes the result of changing a client app's pricing tier
+type TierChange struct {
+	ClientAppID string         `json:"client_app_id"`
+	From        string         `json:"from"`
+	To          string         `json:"to"`
+	Limits      pricing.Limits `json:"limits"`
+	ShardCount  int            `json:"shard_count"`
+	// StorageBytes is the database size across the app's shards, as far as
+	// stats have been collected
+	StorageBytes int64 `json:"storage_bytes"`
+	// OverLimit is set when a forced downgrade leaves the app with more shards
+	// or storage than the tier allows. Nothing is removed; new shards are
+	// refused until the app is back within the limits.
+	OverLimit bool `json:"over_limit"`
+}
+
+// tierFor returns the pricing tier of a client app, falling back to the
+// configured tier for apps that have none of their own
+func (m *Manager) tierFor(clientAppID string) string {
+	if app, err := m.clientAppMgr.GetClientApp(clientAppID); err == nil && app.Tier != "" {
+		return app.Tier
+	}
+	return m.pricingConfig.Tier
+}
+
+// GetClientAppLimits returns the pricing limits that apply to a client app
+func (m *Manager) GetClientAppLimits(clientAppID string) pricing.Limits {
+	return pricing.GetLimits(m.tierFor(clientAppID))
+}
+
+// SetClientAppTier moves a client app to another pricing tier. A downgrade that
+// leaves the app with more shards or storage than the new tier allows is
+// refused with ErrTierLimitExceeded unless force is set.
+func (m *Manager) SetClientAppTier(clientAppID string, tierName string, force bool) (*TierChange, error) {
+	tier, err := pricing.ParseTier(tierName)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := m.clientAppMgr.GetClientApp(clientAppID); err != nil {
+		return nil, err
+	}
+
+	shards, err := m.ListShardsForClient(clientAppID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list shards for client application: %w", err)
+	}
+
+	_, storage, _ := m.shardUsage(shards)
+
+	limits := pricing.GetLimits(string(tier))
+	change := &TierChange{
+		ClientAppID:  clientAppID,
+		From:         m.tierFor(clientAppID),
+		To:           string(tier),
+		Limits:       limits,
+		ShardCount:   len(shards),
+		StorageBytes: storage,
+	}
+	if limits.MaxShards != -1 && len(shards) > limits.MaxShards {
+		change.OverLimit = true
+		if !force {
+			return nil, fmt.Errorf("%w: client application %s has %d shards, %s tier allows %d",
+				ErrTierLimitExceeded, clientAppID, len(shards), limits.Name, limits.MaxShards)
+		}
+	}
+	if limits.MaxStorageBytes != -1 && storage > limits.MaxStorageBytes {
+		change.OverLimit = true
+		if !force {
+			return nil, fmt.Errorf("%w: client application %s stores %d bytes, %s tier allows %d",
+				ErrTierLimitExceeded, clientAppID, storage, limits.Name, limits.MaxStorageBytes)
+		}
+	}
+
+	if err := m.clientAppMgr.SetTier(clientAppID, string(tier)); err != nil {
+		return nil, err
+	}
+
+	if change.OverLimit {
+		m.logger.Warn("forced client app onto a tier it exceeds; new shards are refused until it is back within the limit",
+			zap.String("client_app_id", clientAppID),
+			zap.String("tier", change.To),
+			zap.Int("shard_count", change.ShardCount),
+			zap.Int("max_shards", limits.MaxShards),
+			zap.Int64("storage_bytes", change.StorageBytes),
+			zap.Int64("max_storage_bytes", limits.MaxStorageBytes))
+	}
+	m.logger.Info("changed client app tier",
+		zap.String("client_app_id", clientAppID),
+		zap.String("from", change.From),
+		zap.String("to", change.To),
+		zap.Int("shard_count", change.ShardCount))
+
+	return change, nil
+}
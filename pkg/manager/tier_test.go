@@ -0,0 +1,98 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/sharding-system/pkg/config"
+	"github.com/sharding-system/pkg/models"
+	"go.uber.org/zap/zaptest"
+)
+
+// newTierTestManager creates a manager on the given tier with one client app
+// that owns shardCount shards
+func newTierTestManager(t *testing.T, tier string, shardCount int) (*Manager, *MockCatalog) {
+	t.Helper()
+	catalog := NewMockCatalog()
+	manager := NewManager(catalog, zaptest.NewLogger(t), &MockResharder{}, config.PricingConfig{Tier: tier})
+	manager.clientAppMgr.clientApps["app1"] = &ClientAppInfo{ID: "app1", Name: "orders"}
+	for i := 0; i < shardCount; i++ {
+		id := fmt.Sprintf("shard%d", i)
+		catalog.shards[id] = &models.Shard{ID: id, ClientAppID: "app1", Status: "active"}
+	}
+	return manager, catalog
+}
+
+func TestManager_SetClientAppTier_Upgrade(t *testing.T) {
+	manager, _ := newTierTestManager(t, "free", 2)
+
+	change, err := manager.SetClientAppTier("app1", "Pro", false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if change.From != "free" || change.To != "pro" {
+		t.Errorf("expected a change from free to pro, got %s to %s", change.From, change.To)
+	}
+	if change.ShardCount != 2 || change.OverLimit {
+		t.Errorf("expected 2 shards within the limit, got %d (over limit: %v)", change.ShardCount, change.OverLimit)
+	}
+	if got := manager.GetClientAppLimits("app1").MaxShards; got != 10 {
+		t.Errorf("expected the app to get the pro shard limit of 10, got %d", got)
+	}
+
+	// The upgrade makes room for a shard the free tier would have refused. The
+	// shard still fails to connect to its database, which is checked after the limit.
+	_, err = manager.CreateShard(context.Background(), &models.CreateShardRequest{
+		Name:            "orders-2",
+		ClientAppID:     "app1",
+		PrimaryEndpoint: "postgres://localhost/orders",
+		VNodeCount:      4,
+	})
+	if err != nil && strings.Contains(err.Error(), "shard limit reached") {
+		t.Errorf("expected the upgraded app to be allowed another shard, got %v", err)
+	}
+}
+
+func TestManager_SetClientAppTier_DowngradeOverLimit(t *testing.T) {
+	manager, _ := newTierTestManager(t, "pro", 3)
+
+	_, err := manager.SetClientAppTier("app1", "free", false)
+	if !errors.Is(err, ErrTierLimitExceeded) {
+		t.Fatalf("expected ErrTierLimitExceeded, got %v", err)
+	}
+	app, _ := manager.clientAppMgr.GetClientApp("app1")
+	if app.Tier != "" {
+		t.Errorf("expected the refused downgrade to leave the tier unchanged, got %q", app.Tier)
+	}
+
+	change, err := manager.SetClientAppTier("app1", "free", true)
+	if err != nil {
+		t.Fatalf("expected a forced downgrade to succeed, got %v", err)
+	}
+	if !change.OverLimit || change.ShardCount != 3 {
+		t.Errorf("expected the forced downgrade to report 3 shards over the limit, got %+v", change)
+	}
+
+	_, err = manager.CreateShard(context.Background(), &models.CreateShardRequest{
+		Name:            "orders-3",
+		ClientAppID:     "app1",
+		PrimaryEndpoint: "postgres://localhost/orders",
+	})
+	if err == nil || !strings.Contains(err.Error(), "shard limit reached") {
+		t.Errorf("expected new shards to be refused over the limit, got %v", err)
+	}
+}
+
+func TestManager_SetClientAppTier_Invalid(t *testing.T) {
+	manager, _ := newTierTestManager(t, "free", 0)
+
+	if _, err := manager.SetClientAppTier("app1", "platinum", false); err == nil {
+		t.Error("expected an unknown tier to be rejected")
+	}
+	if _, err := manager.SetClientAppTier("missing", "pro", false); err == nil {
+		t.Error("expected an unknown client app to be rejected")
+	}
+}
@@ -0,0 +1,75 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sharding-system/pkg/catalog"
+	"go.uber.org/zap"
+)
+
+// ErrShardNotFound is returned for the timeline of a shard that neither exists
+// nor ever recorded an event
+var ErrShardNotFound = errors.New("shard not found")
+
+// SetShardTimeline sets where shard histories are kept. Without one they are
+// kept in memory and lost on restart.
+func (m *Manager) SetShardTimeline(timeline catalog.ShardTimeline) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.timeline = timeline
+}
+
+// RecordShardEvent adds an event to a shard's timeline. The operation it
+// describes has already happened, so a failure to record it is logged rather
+// than returned.
+func (m *Manager) RecordShardEvent(shardID string, eventType catalog.ShardEventType, message string, details map[string]string) {
+	m.mu.RLock()
+	timeline := m.timeline
+	m.mu.RUnlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	event := &catalog.ShardEvent{
+		ShardID:   shardID,
+		Type:      eventType,
+		Message:   message,
+		Details:   details,
+		Timestamp: time.Now(),
+	}
+	if err := timeline.Record(ctx, event); err != nil {
+		m.logger.Error("failed to record shard event",
+			zap.String("shard_id", shardID),
+			zap.String("type", string(eventType)),
+			zap.Error(err))
+	}
+}
+
+// ShardEvents returns up to limit events of a shard after sequence number
+// since, oldest first. Deleted shards keep their timeline; shards that never
+// existed are not found.
+func (m *Manager) ShardEvents(ctx context.Context, shardID string, since int64, limit int) ([]catalog.ShardEvent, error) {
+	m.mu.RLock()
+	timeline := m.timeline
+	m.mu.RUnlock()
+
+	events, err := timeline.Events(ctx, shardID, since, limit)
+	if err != nil {
+		return nil, err
+	}
+	if len(events) == 0 && since == 0 {
+		if _, err := m.catalog.GetShardByID(shardID); err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrShardNotFound, shardID)
+		}
+	}
+	return events, nil
+}
+
+// newMemoryTimeline creates the timeline managers start with. NewManager's
+// catalog parameter shadows the package there.
+func newMemoryTimeline() catalog.ShardTimeline {
+	return catalog.NewMemoryShardTimeline()
+}
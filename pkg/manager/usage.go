@@ -0,0 +1,103 @@
+package manager
+
+import (
+	"fmt"
+
+	"github.com/sharding-system/pkg/models"
+	"github.com/sharding-system/pkg/monitoring"
+	"github.com/sharding-system/pkg/pricing"
+)
+
+// ShardStatsSource provides the latest database stats collected for shards
+type ShardStatsSource interface {
+	GetStats(databaseID string) (*monitoring.PostgresStats, error)
+}
+
+// ResourceUsage is how much of one resource a client app uses against its limit
+type ResourceUsage struct {
+	Used        int64   `json:"used"`
+	Limit       int64   `json:"limit"`        // -1 when the tier sets no limit
+	PercentUsed float64 `json:"percent_used"` // 0 when the tier sets no limit
+	OverLimit   bool    `json:"over_limit"`
+}
+
+// newResourceUsage computes the share of limit that used takes up
+func newResourceUsage(used, limit int64) ResourceUsage {
+	usage := ResourceUsage{Used: used, Limit: limit}
+	if limit > 0 {
+		usage.PercentUsed = float64(used) / float64(limit) * 100
+	}
+	usage.OverLimit = limit != -1 && used > limit
+	return usage
+}
+
+// UsageReport compares a client app's current usage with its tier's limits
+type UsageReport struct {
+	ClientAppID  string         `json:"client_app_id"`
+	Tier         string         `json:"tier"`
+	Limits       pricing.Limits `json:"limits"`
+	Shards       ResourceUsage  `json:"shards"`
+	Connections  ResourceUsage  `json:"connections"`
+	StorageBytes ResourceUsage  `json:"storage_bytes"`
+	// ShardsWithoutStats counts shards with no collected stats yet. Their
+	// connections and storage are missing from the report.
+	ShardsWithoutStats int `json:"shards_without_stats"`
+}
+
+// SetShardStatsSource sets where shard connection and storage usage is read from
+func (m *Manager) SetShardStatsSource(stats ShardStatsSource) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.stats = stats
+}
+
+// ClientAppUsage reports a client app's shards, connections and storage
+// against the limits of its tier. Shard counts come from the catalog;
+// connections and storage are summed from the latest stats of each shard.
+func (m *Manager) ClientAppUsage(clientAppID string) (*UsageReport, error) {
+	if _, err := m.clientAppMgr.GetClientApp(clientAppID); err != nil {
+		return nil, err
+	}
+
+	shards, err := m.ListShardsForClient(clientAppID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list shards for client application: %w", err)
+	}
+
+	connections, storage, withoutStats := m.shardUsage(shards)
+
+	tier := m.tierFor(clientAppID)
+	limits := pricing.GetLimits(tier)
+	return &UsageReport{
+		ClientAppID:        clientAppID,
+		Tier:               tier,
+		Limits:             limits,
+		Shards:             newResourceUsage(int64(len(shards)), int64(limits.MaxShards)),
+		Connections:        newResourceUsage(connections, int64(limits.MaxConnections)),
+		StorageBytes:       newResourceUsage(storage, limits.MaxStorageBytes),
+		ShardsWithoutStats: withoutStats,
+	}, nil
+}
+
+// shardUsage sums the client connections and database size of shards from
+// their latest stats, counting the shards that have none
+func (m *Manager) shardUsage(shards []models.Shard) (connections, storage int64, withoutStats int) {
+	m.mu.RLock()
+	source := m.stats
+	m.mu.RUnlock()
+
+	for _, shard := range shards {
+		if source == nil {
+			withoutStats++
+			continue
+		}
+		stats, err := source.GetStats(shard.ID)
+		if err != nil {
+			withoutStats++
+			continue
+		}
+		connections += int64(stats.Connections.Total)
+		storage += stats.Size
+	}
+	return connections, storage, withoutStats
+}
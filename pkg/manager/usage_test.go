@@ -0,0 +1,70 @@
+package manager
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/sharding-system/pkg/monitoring"
+)
+
+// fakeStatsSource serves fixed stats per shard
+type fakeStatsSource map[string]*monitoring.PostgresStats
+
+func (f fakeStatsSource) GetStats(databaseID string) (*monitoring.PostgresStats, error) {
+	stats, ok := f[databaseID]
+	if !ok {
+		return nil, fmt.Errorf("database not registered: %s", databaseID)
+	}
+	return stats, nil
+}
+
+func TestManager_ClientAppUsage(t *testing.T) {
+	manager, _ := newTierTestManager(t, "pro", 4)
+	manager.SetShardStatsSource(fakeStatsSource{
+		"shard0": {Size: 3 << 30, Connections: monitoring.ConnectionStats{Total: 30}},
+		"shard1": {Size: 1 << 30, Connections: monitoring.ConnectionStats{Total: 20}},
+		"shard2": {Size: 1 << 30, Connections: monitoring.ConnectionStats{Total: 0}},
+	})
+
+	report, err := manager.ClientAppUsage("app1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if report.Tier != "pro" {
+		t.Errorf("expected the pro tier, got %s", report.Tier)
+	}
+	if report.Shards.Used != 4 || report.Shards.Limit != 10 || report.Shards.PercentUsed != 40 {
+		t.Errorf("expected 4 of 10 shards (40%%), got %+v", report.Shards)
+	}
+	if report.Connections.Used != 50 || report.Connections.Limit != 200 || report.Connections.PercentUsed != 25 {
+		t.Errorf("expected 50 of 200 connections (25%%), got %+v", report.Connections)
+	}
+	if report.StorageBytes.Used != 5<<30 {
+		t.Errorf("expected 5GiB of storage, got %d", report.StorageBytes.Used)
+	}
+	if report.ShardsWithoutStats != 1 {
+		t.Errorf("expected 1 shard without stats, got %d", report.ShardsWithoutStats)
+	}
+}
+
+func TestManager_ClientAppUsage_Unlimited(t *testing.T) {
+	manager, _ := newTierTestManager(t, "free", 2)
+	if _, err := manager.SetClientAppTier("app1", "enterprise", false); err != nil {
+		t.Fatalf("failed to upgrade: %v", err)
+	}
+
+	report, err := manager.ClientAppUsage("app1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if report.Shards.Limit != -1 || report.Shards.PercentUsed != 0 {
+		t.Errorf("expected no shard limit on enterprise, got %+v", report.Shards)
+	}
+	if report.ShardsWithoutStats != 2 {
+		t.Errorf("expected both shards without stats when no collector is set, got %d", report.ShardsWithoutStats)
+	}
+
+	if _, err := manager.ClientAppUsage("missing"); err == nil {
+		t.Error("expected an error for an unknown client app")
+	}
+}
@@ -0,0 +1,356 @@
+package models
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// defaultConnParams are set on DSNs built from a shard's connection details
+// unless the shard's ConnParams override them
+var defaultConnParams = map[string]string{
+	"sslmode":         "prefer",
+	"connect_timeout": "10",
+}
+
+// defaultMySQLParams are the MySQL counterpart of defaultConnParams
+var defaultMySQLParams = map[string]string{
+	"timeout": "10s",
+}
+
+// ConnectionString returns the DSN to connect to the shard's primary with the
+// driver of its engine. For PostgreSQL, a postgres:// or postgresql://
+// PrimaryEndpoint is used with the extra parameters added to its query;
+// otherwise the DSN is built from Host, Port, Database and the credentials,
+// and is empty if the host or database is missing. ConnParams take precedence
+// over the defaults and over applicationName, which may be empty.
+func (s *Shard) ConnectionString(applicationName string) string {
+	if NormalizeEngine(s.Engine) == EngineMySQL {
+		return s.mysqlConnectionString(applicationName)
+	}
+
+	if strings.HasPrefix(s.PrimaryEndpoint, "postgres://") || strings.HasPrefix(s.PrimaryEndpoint, "postgresql://") {
+		return withURLParams(s.PrimaryEndpoint, applicationName, s.ConnParams)
+	}
+
+	if s.Host == "" || s.Database == "" {
+		return ""
+	}
+
+	port := s.Port
+	if port == 0 {
+		port = 5432 // Default PostgreSQL port
+	}
+
+	params := make(map[string]string, len(defaultConnParams)+len(s.ConnParams)+1)
+	if applicationName != "" {
+		params["application_name"] = applicationName
+	}
+	for k, v := range defaultConnParams {
+		params[k] = v
+	}
+	for k, v := range s.ConnParams {
+		params[k] = v
+	}
+
+	dsn := fmt.Sprintf("host=%s port=%d dbname=%s", s.Host, port, quoteDSNValue(s.Database))
+	if s.Username != "" {
+		dsn += " user=" + quoteDSNValue(s.Username)
+	}
+	if s.Password != "" {
+		dsn += " password=" + quoteDSNValue(s.Password)
+	}
+	for _, k := range sortedKeys(params) {
+		switch k {
+		case "host", "port", "dbname", "user", "password":
+			continue // Taken from the shard's own fields
+		}
+		dsn += " " + k + "=" + quoteDSNValue(params[k])
+	}
+	return dsn
+}
+
+// CollectorConnectionString returns the DSN the monitoring collectors connect
+// to the shard with. It is the ConnectionString, moved to DirectEndpoint when
+// the shard sits behind a pooler whose server is reachable directly.
+func (s *Shard) CollectorConnectionString(applicationName string) string {
+	if !s.Pooler || s.DirectEndpoint == "" {
+		return s.ConnectionString(applicationName)
+	}
+	host, port, err := net.SplitHostPort(s.DirectEndpoint)
+	if err != nil {
+		return s.ConnectionString(applicationName)
+	}
+
+	direct := *s
+	if strings.HasPrefix(s.PrimaryEndpoint, "postgres://") || strings.HasPrefix(s.PrimaryEndpoint, "postgresql://") {
+		u, err := url.Parse(s.PrimaryEndpoint)
+		if err != nil {
+			return ""
+		}
+		u.Host = s.DirectEndpoint
+		direct.PrimaryEndpoint = u.String()
+	} else {
+		direct.Host = host
+		direct.Port, _ = strconv.Atoi(port)
+	}
+	return direct.ConnectionString(applicationName)
+}
+
+// CollectsThroughPooler reports whether the collectors reach the shard
+// through its connection pooler, for want of a direct endpoint
+func (s *Shard) CollectsThroughPooler() bool {
+	return s.Pooler && s.DirectEndpoint == ""
+}
+
+// ValidatePooler checks a shard's pooler settings: a direct endpoint needs
+// the pooler flag and a host:port, and only PostgreSQL shards have poolers
+func ValidatePooler(engine string, pooler bool, directEndpoint string) error {
+	if !pooler {
+		if directEndpoint != "" {
+			return fmt.Errorf("direct_endpoint is only used for shards behind a pooler")
+		}
+		return nil
+	}
+	if NormalizeEngine(engine) != EnginePostgres {
+		return fmt.Errorf("pooler is only supported for %s shards", EnginePostgres)
+	}
+	if directEndpoint == "" {
+		return nil
+	}
+	host, port, err := net.SplitHostPort(directEndpoint)
+	if err != nil {
+		return fmt.Errorf("invalid direct_endpoint %q: %w", directEndpoint, err)
+	}
+	if p, err := strconv.Atoi(port); host == "" || err != nil || p < 1 || p > 65535 {
+		return fmt.Errorf("invalid direct_endpoint %q: want host:port", directEndpoint)
+	}
+	return nil
+}
+
+// mysqlConnectionString builds a go-sql-driver/mysql DSN from a mysql://
+// PrimaryEndpoint or the shard's connection details. The application name is
+// sent as the program_name connection attribute.
+func (s *Shard) mysqlConnectionString(applicationName string) string {
+	host, port, database := s.Host, s.Port, s.Database
+	user, password := s.Username, s.Password
+	params := make(map[string]string, len(defaultMySQLParams)+len(s.ConnParams)+1)
+	for k, v := range defaultMySQLParams {
+		params[k] = v
+	}
+	if applicationName != "" {
+		params["connectionAttributes"] = "program_name:" + applicationName
+	}
+
+	if strings.HasPrefix(s.PrimaryEndpoint, "mysql://") {
+		u, err := url.Parse(s.PrimaryEndpoint)
+		if err != nil {
+			return ""
+		}
+		host, database = u.Hostname(), strings.TrimPrefix(u.Path, "/")
+		port = 0
+		if p, err := strconv.Atoi(u.Port()); err == nil {
+			port = p
+		}
+		user = u.User.Username()
+		password, _ = u.User.Password()
+		for k, v := range u.Query() {
+			params[k] = v[0]
+		}
+	}
+	for k, v := range s.ConnParams {
+		params[k] = v
+	}
+
+	if host == "" || database == "" {
+		return ""
+	}
+	if port == 0 {
+		port = 3306 // Default MySQL port
+	}
+
+	credentials := ""
+	if user != "" {
+		credentials = user
+		if password != "" {
+			credentials += ":" + password
+		}
+		credentials += "@"
+	}
+	query := make([]string, 0, len(params))
+	for _, k := range sortedKeys(params) {
+		query = append(query, k+"="+url.QueryEscape(params[k]))
+	}
+	return fmt.Sprintf("%stcp(%s:%d)/%s?%s", credentials, host, port, database, strings.Join(query, "&"))
+}
+
+// withURLParams adds params to a connection URL's query, overriding any the
+// URL already has. applicationName is only added if the URL has none.
+func withURLParams(endpoint, applicationName string, params map[string]string) string {
+	if applicationName == "" && len(params) == 0 {
+		return endpoint
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return endpoint
+	}
+	query := u.Query()
+	if applicationName != "" && query.Get("application_name") == "" {
+		query.Set("application_name", applicationName)
+	}
+	for k, v := range params {
+		query.Set(k, v)
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// quoteDSNValue quotes a key/value DSN value if it is empty or contains
+// spaces, quotes or backslashes
+func quoteDSNValue(v string) string {
+	if v != "" && !strings.ContainsAny(v, " '\\\t\n") {
+		return v
+	}
+	v = strings.ReplaceAll(v, `\`, `\\`)
+	v = strings.ReplaceAll(v, `'`, `\'`)
+	return "'" + v + "'"
+}
+
+// sortedKeys returns the keys of m in order, so DSNs are stable
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// EndpointLabel identifies an endpoint by host and port, without credentials,
+// for logs, metric labels and events
+func EndpointLabel(endpoint string) string {
+	if strings.Contains(endpoint, "://") {
+		if u, err := url.Parse(endpoint); err == nil {
+			return u.Host
+		}
+		return ""
+	}
+
+	// user:password@tcp(host:port)/database
+	if at := strings.LastIndex(endpoint, "@"); at >= 0 && strings.Contains(endpoint[at:], "(") && !strings.Contains(endpoint[:at], "=") {
+		rest := endpoint[at+1:]
+		if open, end := strings.Index(rest, "("), strings.Index(rest, ")"); end > open {
+			return rest[open+1 : end]
+		}
+	}
+
+	// key=value connection strings
+	var host, port string
+	for _, field := range strings.Fields(endpoint) {
+		switch {
+		case strings.HasPrefix(field, "host="):
+			host = strings.TrimPrefix(field, "host=")
+		case strings.HasPrefix(field, "port="):
+			port = strings.TrimPrefix(field, "port=")
+		}
+	}
+	if port != "" {
+		return host + ":" + port
+	}
+	return host
+}
+
+// WithoutCredentials returns a copy of the shard without its username and
+// password, in its own fields, its connection parameters or its endpoints,
+// for handing out the shard's routing without access to its data
+func (s Shard) WithoutCredentials() Shard {
+	s.Username, s.Password = "", ""
+	s.PrimaryEndpoint = RedactEndpoint(s.PrimaryEndpoint)
+	if s.Replicas != nil {
+		replicas := make([]string, len(s.Replicas))
+		for i, replica := range s.Replicas {
+			replicas[i] = RedactEndpoint(replica)
+		}
+		s.Replicas = replicas
+	}
+	if s.ConnParams != nil {
+		params := make(map[string]string, len(s.ConnParams))
+		for k, v := range s.ConnParams {
+			if !credentialParams[k] {
+				params[k] = v
+			}
+		}
+		s.ConnParams = params
+	}
+	return s
+}
+
+// credentialParams are the connection parameters that carry credentials
+var credentialParams = map[string]bool{"user": true, "password": true}
+
+// RedactEndpoint removes the username and password from a connection
+// string: the user info of URLs, the user and password of key=value
+// connection strings and the credentials before the address of MySQL DSNs
+func RedactEndpoint(endpoint string) string {
+	if strings.Contains(endpoint, "://") {
+		u, err := url.Parse(endpoint)
+		if err != nil {
+			return ""
+		}
+		u.User = nil
+		query := u.Query()
+		for k := range credentialParams {
+			query.Del(k)
+		}
+		u.RawQuery = query.Encode()
+		return u.String()
+	}
+
+	// user:password@tcp(host:port)/database
+	if at := strings.LastIndex(endpoint, "@"); at >= 0 && strings.Contains(endpoint[at:], "(") && !strings.Contains(endpoint[:at], "=") {
+		return endpoint[at+1:]
+	}
+
+	// key=value connection strings
+	fields := dsnFields(endpoint)
+	kept := make([]string, 0, len(fields))
+	for _, field := range fields {
+		key, _, _ := strings.Cut(field, "=")
+		if !credentialParams[strings.TrimSpace(key)] {
+			kept = append(kept, field)
+		}
+	}
+	return strings.Join(kept, " ")
+}
+
+// dsnFields splits a key=value connection string into its key=value fields,
+// keeping quoted values with spaces in one piece
+func dsnFields(dsn string) []string {
+	var fields []string
+	var field strings.Builder
+	quoted, escaped := false, false
+	for _, r := range dsn {
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '\'':
+			quoted = !quoted
+		case !quoted && (r == ' ' || r == '\t' || r == '\n'):
+			if field.Len() > 0 {
+				fields = append(fields, field.String())
+				field.Reset()
+			}
+			continue
+		}
+		field.WriteRune(r)
+	}
+	if field.Len() > 0 {
+		fields = append(fields, field.String())
+	}
+	return fields
+}
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Database engines a shard can run on
+const (
+	EnginePostgres = "postgres"
+	EngineMySQL    = "mysql"
+)
+
+// NormalizeEngine returns the canonical name of an engine. An empty engine is
+// PostgreSQL, which shards ran on before the engine was recorded.
+func NormalizeEngine(engine string) string {
+	switch e := strings.ToLower(engine); e {
+	case "", "postgresql", EnginePostgres:
+		return EnginePostgres
+	default:
+		return e
+	}
+}
+
+// ValidEngine reports whether engine is a supported shard engine
+func ValidEngine(engine string) bool {
+	_, err := DriverName(engine)
+	return err == nil
+}
+
+// DriverName returns the database/sql driver that connects to an engine
+func DriverName(engine string) (string, error) {
+	switch NormalizeEngine(engine) {
+	case EnginePostgres:
+		return "postgres", nil
+	case EngineMySQL:
+		return "mysql", nil
+	default:
+		return "", fmt.Errorf("unsupported database engine %q: must be postgres or mysql", engine)
+	}
+}
@@ -0,0 +1,53 @@
+package models
+
+import "testing"
+
+func TestDriverName(t *testing.T) {
+	cases := map[string]string{
+		"":           "postgres",
+		"postgres":   "postgres",
+		"PostgreSQL": "postgres",
+		"mysql":      "mysql",
+		"MySQL":      "mysql",
+	}
+	for engine, want := range cases {
+		got, err := DriverName(engine)
+		if err != nil {
+			t.Errorf("DriverName(%q): unexpected error: %v", engine, err)
+		} else if got != want {
+			t.Errorf("DriverName(%q): expected %s, got %s", engine, want, got)
+		}
+	}
+
+	if _, err := DriverName("oracle"); err == nil {
+		t.Error("expected an unsupported engine to be rejected")
+	}
+	if ValidEngine("oracle") || !ValidEngine("mysql") {
+		t.Error("expected only postgres and mysql to be valid engines")
+	}
+}
+
+func TestShard_ConnectionString_MySQL(t *testing.T) {
+	shard := &Shard{Engine: EngineMySQL, Host: "db1", Database: "orders", Username: "app", Password: "pw"}
+
+	want := "app:pw@tcp(db1:3306)/orders?connectionAttributes=program_name%3Acollector&timeout=10s"
+	if got := shard.ConnectionString("collector"); got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+	if got := (&Shard{Engine: EngineMySQL, Host: "db1"}).ConnectionString("collector"); got != "" {
+		t.Errorf("expected no DSN without a database, got %q", got)
+	}
+}
+
+func TestShard_ConnectionString_MySQLEndpoint(t *testing.T) {
+	shard := &Shard{
+		Engine:          EngineMySQL,
+		PrimaryEndpoint: "mysql://app:pw@db1:3307/orders?tls=true",
+		ConnParams:      map[string]string{"timeout": "3s"},
+	}
+
+	want := "app:pw@tcp(db1:3307)/orders?timeout=3s&tls=true"
+	if got := shard.ConnectionString(""); got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
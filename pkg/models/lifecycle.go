@@ -0,0 +1,89 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// Shard lifecycle states. A shard is provisioned, serves traffic while
+// active, is drained of traffic and then deleted. Along the way it may be
+// made read-only, for example while a move copies its last writes, taken
+// out of routing as inactive, or marked failed.
+const (
+	ShardStatusProvisioning = "provisioning" // Created, database not yet serving
+	ShardStatusActive       = "active"       // Serving reads and writes
+	ShardStatusMigrating    = "migrating"    // Target of a split, merge or rekey, filled until cutover
+	ShardStatusReadOnly     = "readonly"     // Serving reads only
+	ShardStatusDraining     = "draining"     // Being emptied ahead of removal
+	ShardStatusInactive     = "inactive"     // Out of routing, data kept
+	ShardStatusDeleting     = "deleting"     // Being removed
+	ShardStatusFailed       = "failed"       // Unusable until repaired
+)
+
+// ErrInvalidShardTransition is returned when a shard cannot move from its
+// current status to the requested one
+var ErrInvalidShardTransition = errors.New("invalid shard status transition")
+
+// IsInvalidShardTransition reports whether err was caused by a disallowed
+// shard status change
+func IsInvalidShardTransition(err error) bool {
+	return errors.Is(err, ErrInvalidShardTransition)
+}
+
+// shardTransitions lists the statuses each status may move to
+var shardTransitions = map[string][]string{
+	ShardStatusProvisioning: {ShardStatusActive, ShardStatusFailed, ShardStatusDeleting},
+	ShardStatusActive:       {ShardStatusReadOnly, ShardStatusDraining, ShardStatusInactive, ShardStatusFailed},
+	ShardStatusMigrating:    {ShardStatusActive, ShardStatusReadOnly, ShardStatusFailed, ShardStatusDeleting},
+	ShardStatusReadOnly:     {ShardStatusActive, ShardStatusDraining, ShardStatusInactive, ShardStatusFailed, ShardStatusDeleting},
+	ShardStatusDraining:     {ShardStatusActive, ShardStatusInactive, ShardStatusFailed, ShardStatusDeleting},
+	ShardStatusInactive:     {ShardStatusActive, ShardStatusDraining, ShardStatusFailed, ShardStatusDeleting},
+	ShardStatusDeleting:     {ShardStatusFailed},
+	ShardStatusFailed:       {ShardStatusProvisioning, ShardStatusActive, ShardStatusInactive, ShardStatusDeleting},
+}
+
+// IsShardStatus reports whether status is a shard lifecycle state
+func IsShardStatus(status string) bool {
+	_, ok := shardTransitions[status]
+	return ok
+}
+
+// IsInitialShardStatus reports whether a shard may be created in status
+func IsInitialShardStatus(status string) bool {
+	switch status {
+	case ShardStatusProvisioning, ShardStatusActive, ShardStatusMigrating, ShardStatusInactive:
+		return true
+	}
+	return false
+}
+
+// ShardTransitions returns the statuses a shard in status may move to, in order
+func ShardTransitions(status string) []string {
+	next := append([]string{}, shardTransitions[status]...)
+	sort.Strings(next)
+	return next
+}
+
+// ValidateShardTransition checks that a shard may move from one status to
+// another. Staying in the same status is allowed. Shards recorded before the
+// lifecycle was enforced may hold a status outside it; they may move to any
+// lifecycle state.
+func ValidateShardTransition(from, to string) error {
+	if !IsShardStatus(to) {
+		return fmt.Errorf("%w: unknown status %q", ErrInvalidShardTransition, to)
+	}
+	if from == to {
+		return nil
+	}
+	allowed, known := shardTransitions[from]
+	if !known {
+		return nil
+	}
+	for _, next := range allowed {
+		if next == to {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s shard cannot become %s, only %v", ErrInvalidShardTransition, from, to, ShardTransitions(from))
+}
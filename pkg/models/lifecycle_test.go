@@ -0,0 +1,66 @@
+package models
+
+import "testing"
+
+func TestValidateShardTransition_Allowed(t *testing.T) {
+	tests := [][2]string{
+		{ShardStatusProvisioning, ShardStatusActive},
+		{ShardStatusProvisioning, ShardStatusFailed},
+		{ShardStatusActive, ShardStatusDraining},
+		{ShardStatusActive, ShardStatusReadOnly},
+		{ShardStatusReadOnly, ShardStatusActive},
+		{ShardStatusMigrating, ShardStatusActive},
+		{ShardStatusDraining, ShardStatusDeleting},
+		{ShardStatusDraining, ShardStatusActive},
+		{ShardStatusInactive, ShardStatusDeleting},
+		{ShardStatusFailed, ShardStatusActive},
+		{ShardStatusActive, ShardStatusActive},
+		// Statuses from before the lifecycle was enforced
+		{"", ShardStatusActive},
+		{"suspended", ShardStatusDraining},
+	}
+	for _, tt := range tests {
+		if err := ValidateShardTransition(tt[0], tt[1]); err != nil {
+			t.Errorf("expected %q -> %q to be allowed, got %v", tt[0], tt[1], err)
+		}
+	}
+}
+
+func TestValidateShardTransition_Rejected(t *testing.T) {
+	tests := [][2]string{
+		{ShardStatusActive, ShardStatusDeleting},
+		{ShardStatusActive, ShardStatusProvisioning},
+		{ShardStatusActive, ShardStatusMigrating},
+		{ShardStatusProvisioning, ShardStatusDraining},
+		{ShardStatusDeleting, ShardStatusActive},
+		{ShardStatusDeleting, ShardStatusInactive},
+		{ShardStatusDraining, ShardStatusReadOnly},
+		{ShardStatusMigrating, ShardStatusInactive},
+		{ShardStatusFailed, ShardStatusDraining},
+		{ShardStatusActive, "read-only"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		err := ValidateShardTransition(tt[0], tt[1])
+		if err == nil {
+			t.Errorf("expected %q -> %q to be rejected", tt[0], tt[1])
+			continue
+		}
+		if !IsInvalidShardTransition(err) {
+			t.Errorf("expected %q -> %q to fail with ErrInvalidShardTransition, got %v", tt[0], tt[1], err)
+		}
+	}
+}
+
+func TestShardTransitions_EveryTargetIsAState(t *testing.T) {
+	for from := range shardTransitions {
+		for _, to := range ShardTransitions(from) {
+			if !IsShardStatus(to) {
+				t.Errorf("%s may move to %q, which is not a shard status", from, to)
+			}
+			if to == from {
+				t.Errorf("%s lists itself as a transition", from)
+			}
+		}
+	}
+}
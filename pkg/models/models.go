@@ -13,19 +13,56 @@ type Shard struct {
 	HashRangeEnd    uint64    `json:"hash_range_end"`
 	PrimaryEndpoint string    `json:"primary_endpoint"`
 	Replicas        []string  `json:"replicas"`
-	Status          string    `json:"status"` // "active", "migrating", "readonly", "inactive"
+	Status          string    `json:"status"` // Lifecycle state, one of the ShardStatus constants
 	Version         int64     `json:"version"`
 	CreatedAt       time.Time `json:"created_at"`
 	UpdatedAt       time.Time `json:"updated_at"`
 	VNodes          []VNode   `json:"vnodes,omitempty"`
 
 	// Database connection details
+	Engine   string `json:"engine,omitempty"` // "postgres" (default) or "mysql"
 	Host     string `json:"host,omitempty"`
 	Port     int    `json:"port,omitempty"`
 	Database string `json:"database,omitempty"`
 	Username string `json:"username,omitempty"`
 	Password string `json:"password,omitempty"` // In production, use secrets management
 	Weight   int    `json:"weight,omitempty"`   // Load balancing weight
+
+	// ConnParams are extra driver parameters added to the shard's DSN, e.g.
+	// application_name, options or statement_timeout. They take precedence
+	// over the engine's defaults (sslmode=prefer and connect_timeout=10 for
+	// PostgreSQL, timeout=10s for MySQL).
+	ConnParams map[string]string `json:"conn_params,omitempty"`
+
+	// Pooler marks the shard's endpoint as a connection pooler such as
+	// PgBouncer in front of PostgreSQL. Queries keep going through the pooler.
+	// The collectors connect to DirectEndpoint, the host:port of the server
+	// behind it, when set; otherwise they go through the pooler too and skip
+	// the statistics a pooler distorts.
+	Pooler         bool   `json:"pooler,omitempty"`
+	DirectEndpoint string `json:"direct_endpoint,omitempty"`
+
+	// Sharding scheme, inherited from the client application's defaults unless set per shard
+	Strategy     string `json:"strategy,omitempty"`      // "hash" or "range"
+	ShardKey     string `json:"shard_key,omitempty"`     // Key used to route rows to this shard
+	HashFunction string `json:"hash_function,omitempty"` // "murmur3" (default), "xxhash" or "crc32"
+
+	// Key range of a range-strategy shard: keys from KeyRangeStart (inclusive)
+	// to KeyRangeEnd (exclusive), ordered by Collation. An empty start or end
+	// leaves that side unbounded.
+	KeyRangeStart string `json:"key_range_start,omitempty"`
+	KeyRangeEnd   string `json:"key_range_end,omitempty"`
+	Collation     string `json:"collation,omitempty"` // "binary" (default) or "unicode"
+
+	// Failover detection. FailureThreshold is how many failed health checks in
+	// a row make the failover controller promote a replica, and
+	// HealthCheckIntervalSeconds how often it checks the shard; zero uses the
+	// controller's defaults, so critical shards can be failed over sooner.
+	FailureThreshold           int `json:"failure_threshold,omitempty"`
+	HealthCheckIntervalSeconds int `json:"health_check_interval_seconds,omitempty"`
+
+	// SchemaVersion is the catalog record format version this shard was written with
+	SchemaVersion int `json:"schema_version"`
 }
 
 // VNode represents a virtual node in consistent hashing
@@ -45,7 +82,7 @@ type ShardCatalog struct {
 // ReshardJob represents a resharding operation
 type ReshardJob struct {
 	ID           string     `json:"id"`
-	Type         string     `json:"type"` // "split" or "merge"
+	Type         string     `json:"type"` // "split", "merge", "move" or "rekey"
 	SourceShards []string   `json:"source_shards"`
 	TargetShards []string   `json:"target_shards"`
 	Status       string     `json:"status"`   // "pending", "precopy", "deltasync", "cutover", "completed", "failed"
@@ -55,6 +92,7 @@ type ReshardJob struct {
 	ErrorMessage string     `json:"error_message,omitempty"`
 	KeysMigrated int64      `json:"keys_migrated"`
 	TotalKeys    int64      `json:"total_keys"`
+	ShardKey     string     `json:"shard_key,omitempty"` // Column rows are placed by on the targets of a rekey
 }
 
 // ShardHealth represents health status of a shard
@@ -66,6 +104,9 @@ type ShardHealth struct {
 	PrimaryUp      bool          `json:"primary_up"`
 	ReplicasUp     []string      `json:"replicas_up"`
 	ReplicasDown   []string      `json:"replicas_down"`
+	// ReplicasExcluded are replicas kept out of read routing after failing
+	// several checks in a row, until they pass several in a row
+	ReplicasExcluded []string `json:"replicas_excluded,omitempty"`
 }
 
 // QueryRequest represents a query request
@@ -75,6 +116,16 @@ type QueryRequest struct {
 	Params      []interface{}          `json:"params"`
 	Consistency string                 `json:"consistency"` // "strong" or "eventual"
 	Options     map[string]interface{} `json:"options,omitempty"`
+	// MaxStaleness bounds how far behind the primary a replica serving an
+	// eventual read may be, e.g. "500ms". Reads fall back to the primary when
+	// no replica is known to be within the budget.
+	MaxStaleness string `json:"max_staleness,omitempty"`
+	// SessionToken is the session_token of the client's last write. Eventual
+	// reads are then only served by a replica that has applied that write.
+	SessionToken string `json:"session_token,omitempty"`
+	// ReadPreference overrides the router's replica policy for the query:
+	// "primary", "prefer-replica", "replica-only" or "nearest"
+	ReadPreference string `json:"read_preference,omitempty"`
 }
 
 // QueryResponse represents a query response
@@ -83,6 +134,10 @@ type QueryResponse struct {
 	Rows      []interface{} `json:"rows"`
 	RowCount  int           `json:"row_count"`
 	LatencyMs float64       `json:"latency_ms"`
+	Warnings  []string      `json:"warnings,omitempty"`
+	// SessionToken encodes the WAL position a write reached, for reads that
+	// must see it
+	SessionToken string `json:"session_token,omitempty"`
 }
 
 // CreateShardRequest represents a request to create a shard
@@ -94,6 +149,7 @@ type CreateShardRequest struct {
 	VNodeCount      int      `json:"vnode_count"`
 
 	// Database connection details
+	Engine   string `json:"engine,omitempty"`
 	Host     string `json:"host,omitempty"`
 	Port     int    `json:"port,omitempty"`
 	Database string `json:"database,omitempty"`
@@ -101,6 +157,27 @@ type CreateShardRequest struct {
 	Password string `json:"password,omitempty"`
 	Weight   int    `json:"weight,omitempty"`
 	Status   string `json:"status,omitempty"`
+
+	// Extra driver parameters added to the shard's DSN; see Shard
+	ConnParams map[string]string `json:"conn_params,omitempty"`
+
+	// Connection pooler in front of the shard; see Shard
+	Pooler         bool   `json:"pooler,omitempty"`
+	DirectEndpoint string `json:"direct_endpoint,omitempty"`
+
+	// Sharding scheme; defaults to the client application's sharding defaults
+	Strategy     string `json:"strategy,omitempty"`
+	ShardKey     string `json:"shard_key,omitempty"`
+	HashFunction string `json:"hash_function,omitempty"`
+
+	// Key range of a range-strategy shard; see Shard
+	KeyRangeStart string `json:"key_range_start,omitempty"`
+	KeyRangeEnd   string `json:"key_range_end,omitempty"`
+	Collation     string `json:"collation,omitempty"`
+
+	// Failover detection; see Shard
+	FailureThreshold           int `json:"failure_threshold,omitempty"`
+	HealthCheckIntervalSeconds int `json:"health_check_interval_seconds,omitempty"`
 }
 
 // SplitRequest represents a request to split a shard
@@ -108,6 +185,59 @@ type SplitRequest struct {
 	SourceShardID string               `json:"source_shard_id"`
 	TargetShards  []CreateShardRequest `json:"target_shards"`
 	SplitPoint    uint64               `json:"split_point,omitempty"` // Optional explicit split point
+	SplitKey      string               `json:"split_key,omitempty"`   // Optional explicit split key for range-strategy shards
+}
+
+// SplitImpactRequest asks what a split would do without running it
+type SplitImpactRequest struct {
+	SplitRequest
+	// CopyRowsPerSecond is the copy throughput to estimate with. When zero,
+	// the throughput of the last completed resharding job is used.
+	CopyRowsPerSecond float64            `json:"copy_rows_per_second,omitempty"`
+	StartAt           *time.Time         `json:"start_at,omitempty"` // When the split would start; defaults to now
+	MaintenanceWindow *MaintenanceWindow `json:"maintenance_window,omitempty"`
+}
+
+// MaintenanceWindow is a daily window, in UTC, for disruptive operations. An
+// End before Start wraps past midnight.
+type MaintenanceWindow struct {
+	Start string `json:"start"` // "HH:MM"
+	End   string `json:"end"`   // "HH:MM"
+}
+
+// SplitImpact is the estimated impact of a split
+type SplitImpact struct {
+	SourceShardID string              `json:"source_shard_id"`
+	Strategy      string              `json:"strategy"`
+	SplitKey      string              `json:"split_key,omitempty"` // Key a range shard would split at
+	RowsToMove    int64               `json:"rows_to_move"`
+	BytesToMove   int64               `json:"bytes_to_move"`
+	Targets       []SplitTargetImpact `json:"targets"`
+	// CopyRowsPerSecond is the throughput the estimate is based on, taken from
+	// the request ("request") or the last completed resharding job ("measured")
+	CopyRowsPerSecond    float64    `json:"copy_rows_per_second"`
+	ThroughputSource     string     `json:"throughput_source,omitempty"`
+	EstimatedCopySeconds float64    `json:"estimated_copy_seconds"`
+	EstimatedCompletion  *time.Time `json:"estimated_completion,omitempty"`
+	Warnings             []string   `json:"warnings,omitempty"`
+}
+
+// SplitTargetImpact is the share of a split's source a target shard would receive
+type SplitTargetImpact struct {
+	Name           string  `json:"name"`
+	KeyRangeStart  string  `json:"key_range_start,omitempty"`
+	KeyRangeEnd    string  `json:"key_range_end,omitempty"`
+	Share          float64 `json:"share"` // Fraction of the source's rows, 0.0 to 1.0
+	EstimatedRows  int64   `json:"estimated_rows"`
+	EstimatedBytes int64   `json:"estimated_bytes"`
+}
+
+// MoveShardRequest represents a request to move a shard to another host
+// without changing its key range. Either TargetEndpoint or TargetHost is required.
+type MoveShardRequest struct {
+	TargetEndpoint string `json:"target_endpoint,omitempty"` // Full DSN of the target database
+	TargetHost     string `json:"target_host,omitempty"`     // Target host; reuses the shard's database and credentials
+	TargetPort     int    `json:"target_port,omitempty"`     // Defaults to the shard's current port
 }
 
 // MergeRequest represents a request to merge shards
@@ -116,6 +246,14 @@ type MergeRequest struct {
 	TargetShard    CreateShardRequest `json:"target_shard"`
 }
 
+// RekeyRequest moves every row of a client application onto new shards
+// placed by a different shard key column
+type RekeyRequest struct {
+	ClientAppID  string               `json:"client_app_id"`
+	ShardKey     string               `json:"shard_key"` // New shard key column, e.g. tenant_id
+	TargetShards []CreateShardRequest `json:"target_shards"`
+}
+
 // Tenant represents a client application/tenant in a multi-tenant setup
 type Tenant struct {
 	ID          string    `json:"id"`
@@ -0,0 +1,109 @@
+package monitoring
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Connection is a client session on a database server, as listed in pg_stat_activity
+type Connection struct {
+	PID              int        `json:"pid"`
+	Database         string     `json:"database"`
+	User             string     `json:"user"`
+	Application      string     `json:"application"`
+	ClientAddr       string     `json:"client_addr"` // Empty for Unix socket connections
+	State            string     `json:"state"`       // e.g. active, idle, idle in transaction
+	BackendStart     time.Time  `json:"backend_start"`
+	TransactionStart *time.Time `json:"transaction_start,omitempty"`
+	QueryStart       *time.Time `json:"query_start,omitempty"`
+	WaitEventType    string     `json:"wait_event_type,omitempty"`
+	WaitEvent        string     `json:"wait_event,omitempty"`
+	BlockedBy        []int      `json:"blocked_by,omitempty"` // PIDs holding the locks the session waits for
+	Query            string     `json:"query"`                // Current or last query; literals are masked
+}
+
+// GetConnections lists the client sessions currently open on a registered
+// database's server, oldest first
+func (psc *PostgresStatsCollector) GetConnections(ctx context.Context, databaseID string) ([]Connection, error) {
+	db, err := psc.database(databaseID)
+	if err != nil {
+		return nil, err
+	}
+	return listConnections(ctx, db)
+}
+
+// listConnections returns the client sessions in pg_stat_activity other than
+// the collector's own
+func listConnections(ctx context.Context, db *sql.DB) ([]Connection, error) {
+	query := `
+		SELECT
+			pid,
+			COALESCE(datname, ''),
+			COALESCE(usename, ''),
+			COALESCE(application_name, ''),
+			COALESCE(host(client_addr), ''),
+			COALESCE(state, ''),
+			backend_start,
+			xact_start,
+			query_start,
+			COALESCE(wait_event_type, ''),
+			COALESCE(wait_event, ''),
+			pg_blocking_pids(pid)::text,
+			COALESCE(query, '')
+		FROM pg_stat_activity
+		WHERE backend_type = 'client backend'
+			AND pid <> pg_backend_pid()
+		ORDER BY backend_start
+	`
+
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query pg_stat_activity: %w", err)
+	}
+	defer rows.Close()
+
+	connections := make([]Connection, 0)
+	for rows.Next() {
+		var c Connection
+		var xactStart, queryStart sql.NullTime
+		var blockedBy string
+		if err := rows.Scan(&c.PID, &c.Database, &c.User, &c.Application, &c.ClientAddr, &c.State,
+			&c.BackendStart, &xactStart, &queryStart, &c.WaitEventType, &c.WaitEvent, &blockedBy, &c.Query); err != nil {
+			return nil, fmt.Errorf("failed to scan connection: %w", err)
+		}
+		if xactStart.Valid {
+			c.TransactionStart = &xactStart.Time
+		}
+		if queryStart.Valid {
+			c.QueryStart = &queryStart.Time
+		}
+		if c.BlockedBy, err = parsePIDArray(blockedBy); err != nil {
+			return nil, fmt.Errorf("failed to parse blocking pids of %d: %w", c.PID, err)
+		}
+		c.Query = MaskQuery(c.Query)
+		connections = append(connections, c)
+	}
+	return connections, rows.Err()
+}
+
+// parsePIDArray parses a PostgreSQL integer array such as "{101,102}"
+func parsePIDArray(s string) ([]int, error) {
+	s = strings.TrimSuffix(strings.TrimPrefix(s, "{"), "}")
+	if s == "" {
+		return nil, nil
+	}
+	fields := strings.Split(s, ",")
+	pids := make([]int, 0, len(fields))
+	for _, field := range fields {
+		pid, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil {
+			return nil, err
+		}
+		pids = append(pids, pid)
+	}
+	return pids, nil
+}
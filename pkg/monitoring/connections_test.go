@@ -0,0 +1,93 @@
+package monitoring
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zaptest"
+)
+
+var connectionColumns = []string{
+	"pid", "datname", "usename", "application_name", "client_addr", "state",
+	"backend_start", "xact_start", "query_start", "wait_event_type", "wait_event",
+	"blocking_pids", "query",
+}
+
+func TestPostgresStatsCollector_GetConnections(t *testing.T) {
+	started := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	xact := started.Add(time.Minute)
+	db := testStatsDriver.open(t, fakeResult{
+		match:   "FROM pg_stat_activity",
+		columns: connectionColumns,
+		rows: [][]driver.Value{
+			// Idle in transaction, holding a lock
+			{int64(101), "orders", "app", "billing", "10.0.0.7", "idle in transaction", started, xact, xact, "Client", "ClientRead", "{}", "UPDATE orders SET total = 99.5 WHERE id = 7"},
+			// Waiting for the lock held by 101
+			{int64(102), "orders", "app", "billing", "10.0.0.8", "active", started, xact, xact, "Lock", "transactionid", "{101}", "DELETE FROM orders WHERE id = 7"},
+			// Idle session over a Unix socket
+			{int64(103), "orders", "postgres", "psql", "", "idle", started, nil, nil, "Client", "ClientRead", "{}", "SELECT 1"},
+		},
+	})
+
+	psc := NewPostgresStatsCollector(zaptest.NewLogger(t), time.Minute)
+	psc.databases["shard1"] = &DBConnection{DatabaseID: "shard1", DB: db}
+
+	connections, err := psc.GetConnections(context.Background(), "shard1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(connections) != 3 {
+		t.Fatalf("expected 3 connections, got %d", len(connections))
+	}
+
+	idle := connections[0]
+	if idle.PID != 101 || idle.State != "idle in transaction" || idle.ClientAddr != "10.0.0.7" || idle.WaitEvent != "ClientRead" {
+		t.Errorf("unexpected connection %+v", idle)
+	}
+	if idle.TransactionStart == nil || !idle.TransactionStart.Equal(xact) || !idle.BackendStart.Equal(started) {
+		t.Errorf("expected the transaction to have started at %s, got %+v", xact, idle)
+	}
+	if want := "UPDATE orders SET total = ? WHERE id = ?"; idle.Query != want {
+		t.Errorf("expected masked query %q, got %q", want, idle.Query)
+	}
+	if len(idle.BlockedBy) != 0 {
+		t.Errorf("expected 101 not to be blocked, got %v", idle.BlockedBy)
+	}
+
+	if blocked := connections[1]; !reflect.DeepEqual(blocked.BlockedBy, []int{101}) || blocked.WaitEventType != "Lock" {
+		t.Errorf("expected 102 to wait on a lock held by 101, got %+v", blocked)
+	}
+
+	socket := connections[2]
+	if socket.ClientAddr != "" || socket.TransactionStart != nil || socket.QueryStart != nil {
+		t.Errorf("expected a socket session without a transaction, got %+v", socket)
+	}
+
+	if _, err := psc.GetConnections(context.Background(), "missing"); !errors.Is(err, ErrDatabaseNotRegistered) {
+		t.Errorf("expected ErrDatabaseNotRegistered, got %v", err)
+	}
+}
+
+func TestParsePIDArray(t *testing.T) {
+	tests := map[string][]int{
+		"{}":          nil,
+		"{42}":        {42},
+		"{101,102,7}": {101, 102, 7},
+	}
+	for input, want := range tests {
+		got, err := parsePIDArray(input)
+		if err != nil {
+			t.Fatalf("parsePIDArray(%q): unexpected error: %v", input, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("parsePIDArray(%q) = %v, want %v", input, got, want)
+		}
+	}
+	if _, err := parsePIDArray("{x}"); err == nil {
+		t.Error("expected an invalid array to be rejected")
+	}
+}
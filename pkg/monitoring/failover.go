@@ -0,0 +1,172 @@
+package monitoring
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/sharding-system/pkg/catalog"
+	"github.com/sharding-system/pkg/models"
+	"go.uber.org/zap"
+)
+
+// DefaultReconnectAfter is how many consecutive failed connection checks make
+// a collector look up its shard's primary again
+const DefaultReconnectAfter = 3
+
+// EndpointResolver looks up the DSN a shard's primary is currently reached at
+type EndpointResolver interface {
+	ResolveDSN(shardID string) (string, error)
+}
+
+// catalogResolver resolves shard DSNs from the shard catalog
+type catalogResolver struct {
+	catalog catalog.Catalog
+}
+
+// NewCatalogResolver creates an EndpointResolver that reads a shard's current
+// connection details from the catalog, so collectors follow failovers
+func NewCatalogResolver(cat catalog.Catalog) EndpointResolver {
+	return &catalogResolver{catalog: cat}
+}
+
+func (r *catalogResolver) ResolveDSN(shardID string) (string, error) {
+	shard, err := r.catalog.GetShardByID(shardID)
+	if err != nil {
+		return "", err
+	}
+	dsn := shard.CollectorConnectionString(CollectorApplicationName)
+	if dsn == "" {
+		return "", fmt.Errorf("shard %s has no connection details", shardID)
+	}
+	return dsn, nil
+}
+
+// pingCollectorDB checks a collector's connection, bounded like registration
+func pingCollectorDB(ctx context.Context, db *sql.DB) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	return db.PingContext(ctx)
+}
+
+// reconnectToPrimary resolves a shard's DSN and, if it moved away from
+// current, connects to it with a pool of size. It returns a nil DB when the
+// DSN is unchanged. driverName overrides the engine's driver when set.
+func reconnectToPrimary(ctx context.Context, resolver EndpointResolver, shardID, engine, driverName, current string, size PoolSize) (string, *sql.DB, error) {
+	dsn, err := resolver.ResolveDSN(shardID)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to resolve shard's primary: %w", err)
+	}
+	if dsn == current {
+		return "", nil, nil
+	}
+
+	if driverName == "" {
+		if driverName, err = models.DriverName(engine); err != nil {
+			return "", nil, err
+		}
+	}
+	db, err := sql.Open(driverName, dsn)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to connect to shard's new primary: %w", err)
+	}
+	size.apply(db)
+	if err := pingCollectorDB(ctx, db); err != nil {
+		db.Close()
+		return "", nil, fmt.Errorf("failed to ping shard's new primary: %w", err)
+	}
+	return dsn, db, nil
+}
+
+// SetEndpointResolver makes collectors look up a shard's DSN again after
+// failures consecutive failed connection checks, and reconnect if its primary
+// moved. Non-positive failures use DefaultReconnectAfter.
+func (pc *PrometheusCollector) SetEndpointResolver(resolver EndpointResolver, failures int) {
+	if failures <= 0 {
+		failures = DefaultReconnectAfter
+	}
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	pc.resolver = resolver
+	pc.reconnectAfter = failures
+}
+
+// checkConnection pings a shard before collection. Once the shard has failed
+// enough checks in a row, its DSN is resolved again and the collector moves to
+// the new primary.
+func (pc *PrometheusCollector) checkConnection(ctx context.Context, sc *ShardCollector) {
+	if sc.db != nil && pingCollectorDB(ctx, sc.db) == nil {
+		sc.failures = 0
+		return
+	}
+	sc.failures++
+
+	pc.mu.RLock()
+	resolver, reconnectAfter, driverName := pc.resolver, pc.reconnectAfter, pc.driver
+	size := pc.pools.forShard(sc.shardID)
+	pc.mu.RUnlock()
+	if resolver == nil || sc.failures < reconnectAfter {
+		return
+	}
+
+	dsn, db, err := reconnectToPrimary(ctx, resolver, sc.shardID, sc.engine, driverName, sc.currentDSN(), size)
+	if err != nil {
+		sc.logger.Warn("failed to reconnect to shard", zap.Int("failures", sc.failures), zap.Error(err))
+		return
+	}
+	if db == nil {
+		return
+	}
+
+	sc.replace(db, dsn)
+	sc.logger.Info("reconnected to shard's new primary")
+}
+
+// SetEndpointResolver makes the collector look up a database's DSN again
+// after failures consecutive failed connection checks, and reconnect if its
+// primary moved. Non-positive failures use DefaultReconnectAfter.
+func (psc *PostgresStatsCollector) SetEndpointResolver(resolver EndpointResolver, failures int) {
+	if failures <= 0 {
+		failures = DefaultReconnectAfter
+	}
+	psc.mu.Lock()
+	defer psc.mu.Unlock()
+	psc.resolver = resolver
+	psc.reconnectAfter = failures
+}
+
+// checkConnection pings a database before collection. Once it has failed
+// enough checks in a row, its DSN is resolved again and the connection moves
+// to the new primary.
+func (psc *PostgresStatsCollector) checkConnection(ctx context.Context, dbConn *DBConnection) {
+	psc.mu.RLock()
+	db, current := dbConn.DB, dbConn.DSN
+	resolver, reconnectAfter, driverName := psc.resolver, psc.reconnectAfter, psc.driver
+	size := psc.pools.forShard(dbConn.DatabaseID)
+	psc.mu.RUnlock()
+
+	if db != nil && pingCollectorDB(ctx, db) == nil {
+		dbConn.failures = 0
+		return
+	}
+	dbConn.failures++
+	if resolver == nil || dbConn.failures < reconnectAfter {
+		return
+	}
+
+	dsn, newDB, err := reconnectToPrimary(ctx, resolver, dbConn.DatabaseID, dbConn.Engine, driverName, current, size)
+	if err != nil {
+		psc.logger.Warn("failed to reconnect to database",
+			zap.String("database_id", dbConn.DatabaseID),
+			zap.Int("failures", dbConn.failures),
+			zap.Error(err))
+		return
+	}
+	if newDB == nil {
+		return
+	}
+
+	psc.replace(dbConn, newDB, dsn)
+	psc.logger.Info("reconnected to database's new primary", zap.String("database_id", dbConn.DatabaseID))
+}
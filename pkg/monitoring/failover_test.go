@@ -0,0 +1,158 @@
+package monitoring
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sharding-system/pkg/catalog"
+	"github.com/sharding-system/pkg/models"
+	"go.uber.org/zap/zaptest"
+)
+
+// failoverCatalog serves shards by ID; the rest of the catalog is unused
+type failoverCatalog struct {
+	catalog.Catalog
+	mu      sync.Mutex
+	shards  map[string]*models.Shard
+	lookups int
+}
+
+func (c *failoverCatalog) GetShardByID(shardID string) (*models.Shard, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lookups++
+	shard, ok := c.shards[shardID]
+	if !ok {
+		return nil, fmt.Errorf("shard not found: %s", shardID)
+	}
+	copied := *shard
+	return &copied, nil
+}
+
+func (c *failoverCatalog) setPrimary(shardID, endpoint string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.shards[shardID].PrimaryEndpoint = endpoint
+}
+
+// failoverFixture is a shard whose primary fails over from one host to another
+type failoverFixture struct {
+	catalog                *failoverCatalog
+	oldDSN, newDSN         string
+	oldPrimary, newPrimary string
+}
+
+func newFailoverFixture(t *testing.T, results ...fakeResult) *failoverFixture {
+	t.Helper()
+	f := &failoverFixture{
+		catalog:    &failoverCatalog{shards: make(map[string]*models.Shard)},
+		oldPrimary: "postgres://app@" + t.Name() + "-old/orders",
+		newPrimary: "postgres://app@" + t.Name() + "-new/orders",
+	}
+	f.catalog.shards["shard1"] = &models.Shard{ID: "shard1", PrimaryEndpoint: f.oldPrimary}
+	f.oldDSN = f.catalog.shards["shard1"].ConnectionString(CollectorApplicationName)
+	f.newDSN = (&models.Shard{PrimaryEndpoint: f.newPrimary}).ConnectionString(CollectorApplicationName)
+
+	testStatsDriver.mu.Lock()
+	testStatsDriver.results[f.newDSN] = results
+	testStatsDriver.queried[f.newDSN] = nil
+	testStatsDriver.mu.Unlock()
+	return f
+}
+
+// openOldPrimary returns a connection that fails like one to a primary that
+// went away
+func (f *failoverFixture) openOldPrimary(t *testing.T) *sql.DB {
+	t.Helper()
+	db := testStatsDriver.open(t)
+	db.SetMaxIdleConns(0)
+	testStatsDriver.setDown(t.Name(), true)
+	t.Cleanup(func() { testStatsDriver.setDown(t.Name(), false) })
+	return db
+}
+
+func TestPrometheusCollector_ReconnectsAfterFailover(t *testing.T) {
+	f := newFailoverFixture(t, fakeResult{
+		match:   "FROM pg_stat_activity",
+		columns: []string{"active", "idle", "waiting", "max_conn"},
+		rows:    [][]driver.Value{{int64(3), int64(1), int64(0), int64(100)}},
+	})
+
+	pc := NewPrometheusCollector(zaptest.NewLogger(t), time.Minute)
+	pc.driver = "monitoringtest"
+	pc.SetEndpointResolver(NewCatalogResolver(f.catalog), 2)
+	collector := &ShardCollector{shardID: "shard1", dsn: f.oldDSN, db: f.openOldPrimary(t), logger: zaptest.NewLogger(t)}
+
+	// The primary fails over; one failed check is not enough to re-resolve
+	f.catalog.setPrimary("shard1", f.newPrimary)
+	pc.checkConnection(context.Background(), collector)
+	if collector.dsn != f.oldDSN || collector.failures != 1 {
+		t.Fatalf("expected the collector to wait for a second failure, got %s after %d failures", collector.dsn, collector.failures)
+	}
+
+	pc.checkConnection(context.Background(), collector)
+	if collector.dsn != f.newDSN || collector.failures != 0 {
+		t.Fatalf("expected the collector to reconnect to %s, got %s after %d failures", f.newDSN, collector.dsn, collector.failures)
+	}
+
+	metrics, err := collector.Collect(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if metrics.ActiveConnections != 3 {
+		t.Errorf("expected metrics from the new primary, got %d active connections", metrics.ActiveConnections)
+	}
+	if len(testStatsDriver.queries(f.newDSN)) == 0 {
+		t.Error("expected the new primary to be queried")
+	}
+}
+
+func TestPostgresStatsCollector_ReconnectsAfterFailover(t *testing.T) {
+	f := newFailoverFixture(t)
+
+	psc := NewPostgresStatsCollector(zaptest.NewLogger(t), time.Minute)
+	psc.driver = "monitoringtest"
+	psc.SetEndpointResolver(NewCatalogResolver(f.catalog), 0)
+
+	db := f.openOldPrimary(t)
+	dbConn := &DBConnection{DatabaseID: "shard1", DSN: f.oldDSN, DB: db}
+	psc.databases["shard1"] = dbConn
+
+	f.catalog.setPrimary("shard1", f.newPrimary)
+	for i := 1; i < DefaultReconnectAfter; i++ {
+		psc.checkConnection(context.Background(), dbConn)
+		if dbConn.DSN != f.oldDSN {
+			t.Fatalf("expected no reconnect after %d failures", i)
+		}
+	}
+	psc.checkConnection(context.Background(), dbConn)
+	if dbConn.DSN != f.newDSN || dbConn.DB == db {
+		t.Fatalf("expected the database to reconnect to %s, got %s", f.newDSN, dbConn.DSN)
+	}
+	if err := dbConn.DB.Ping(); err != nil {
+		t.Errorf("expected the new connection to work: %v", err)
+	}
+}
+
+func TestPrometheusCollector_KeepsConnectionWhilePrimaryUnchanged(t *testing.T) {
+	f := newFailoverFixture(t)
+
+	pc := NewPrometheusCollector(zaptest.NewLogger(t), time.Minute)
+	pc.driver = "monitoringtest"
+	pc.SetEndpointResolver(NewCatalogResolver(f.catalog), 1)
+
+	db := f.openOldPrimary(t)
+	collector := &ShardCollector{shardID: "shard1", dsn: f.oldDSN, db: db, logger: zaptest.NewLogger(t)}
+
+	// The catalog still points at the unreachable primary
+	pc.checkConnection(context.Background(), collector)
+	pc.checkConnection(context.Background(), collector)
+	if collector.db != db || collector.failures != 2 {
+		t.Errorf("expected the collector to keep its connection and count failures, got %d failures", collector.failures)
+	}
+}
@@ -0,0 +1,300 @@
+package monitoring
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// Index advisor defaults
+const (
+	DefaultIndexAdvisorMinRows         = 10000
+	DefaultIndexAdvisorMinSeqScanRatio = 0.5
+	maxIndexCandidatesPerTable         = 3
+	indexAdvisorStatementLimit         = 500
+)
+
+// IndexAdvisorOptions controls which tables the index advisor considers
+type IndexAdvisorOptions struct {
+	MinRows         int64   `json:"min_rows"`           // Smaller tables are cheap to scan and skipped
+	MinSeqScanRatio float64 `json:"min_seq_scan_ratio"` // Share of scans that are sequential, 0.0 to 1.0
+}
+
+// withDefaults fills unset options with the advisor defaults
+func (o IndexAdvisorOptions) withDefaults() IndexAdvisorOptions {
+	if o.MinRows <= 0 {
+		o.MinRows = DefaultIndexAdvisorMinRows
+	}
+	if o.MinSeqScanRatio <= 0 {
+		o.MinSeqScanRatio = DefaultIndexAdvisorMinSeqScanRatio
+	}
+	return o
+}
+
+// TableScanStats is the scan activity and layout of one table, as used by the index advisor
+type TableScanStats struct {
+	Schema         string   `json:"schema"`
+	Table          string   `json:"table"`
+	Rows           int64    `json:"rows"`
+	SeqScans       int64    `json:"seq_scans"`
+	IdxScans       int64    `json:"idx_scans"`
+	Columns        []string `json:"columns"`
+	IndexedColumns []string `json:"indexed_columns"` // Columns leading an existing index
+}
+
+// SeqScanRatio returns the share of scans on the table that were sequential
+func (t TableScanStats) SeqScanRatio() float64 {
+	total := t.SeqScans + t.IdxScans
+	if total == 0 {
+		return 0
+	}
+	return float64(t.SeqScans) / float64(total)
+}
+
+// StatementStats is a normalized statement and how often it ran, from pg_stat_statements
+type StatementStats struct {
+	Query string `json:"query"`
+	Calls int64  `json:"calls"`
+}
+
+// IndexRecommendation is a candidate index for a table that is mostly read
+// with sequential scans. It is advisory only; nothing is created.
+type IndexRecommendation struct {
+	Schema       string   `json:"schema"`
+	Table        string   `json:"table"`
+	Columns      []string `json:"columns,omitempty"` // Empty when no filter columns are known
+	Rows         int64    `json:"rows"`
+	SeqScans     int64    `json:"seq_scans"`
+	IdxScans     int64    `json:"idx_scans"`
+	SeqScanRatio float64  `json:"seq_scan_ratio"`
+	FilterCalls  int64    `json:"filter_calls,omitempty"` // Statement calls filtering on Columns
+	Reason       string   `json:"reason"`
+	Statement    string   `json:"statement,omitempty"` // Suggested CREATE INDEX statement
+}
+
+var (
+	tableReference = regexp.MustCompile(`(?i)\b(?:from|join|update)\s+(?:only\s+)?((?:"?\w+"?\.)?"?\w+"?)`)
+	whereClause    = regexp.MustCompile(`(?is)\bwhere\b(.*?)(?:\bgroup\s+by\b|\border\s+by\b|\blimit\b|\breturning\b|\bfor\s+update\b|$)`)
+	filterColumn   = regexp.MustCompile(`(?i)(?:\b\w+\.)?"?(\w+)"?\s*(?:=|<>|!=|<=|>=|<|>|\bin\b|\blike\b|\bilike\b|\bbetween\b|\bis\b)`)
+)
+
+// RecommendIndexes suggests indexes for large tables read mostly by sequential
+// scans. Candidate columns are the unindexed columns the given statements
+// filter on most often; without statements a table is still reported so the
+// hotspot is visible.
+func RecommendIndexes(tables []TableScanStats, statements []StatementStats, opts IndexAdvisorOptions) []IndexRecommendation {
+	opts = opts.withDefaults()
+
+	recommendations := make([]IndexRecommendation, 0)
+	for _, table := range tables {
+		ratio := table.SeqScanRatio()
+		if table.Rows < opts.MinRows || ratio < opts.MinSeqScanRatio {
+			continue
+		}
+
+		base := IndexRecommendation{
+			Schema:       table.Schema,
+			Table:        table.Table,
+			Rows:         table.Rows,
+			SeqScans:     table.SeqScans,
+			IdxScans:     table.IdxScans,
+			SeqScanRatio: ratio,
+		}
+
+		candidates := filterColumnCalls(table, statements)
+		if len(candidates) == 0 {
+			base.Reason = fmt.Sprintf("%.0f%% of scans are sequential over %d rows; no frequent filter columns found (pg_stat_statements may be unavailable)", ratio*100, table.Rows)
+			recommendations = append(recommendations, base)
+			continue
+		}
+
+		for i, candidate := range candidates {
+			if i == maxIndexCandidatesPerTable {
+				break
+			}
+			rec := base
+			rec.Columns = []string{candidate.column}
+			rec.FilterCalls = candidate.calls
+			rec.Reason = fmt.Sprintf("%.0f%% of scans are sequential over %d rows; %s is filtered on by %d statement calls without an index", ratio*100, table.Rows, candidate.column, candidate.calls)
+			rec.Statement = createIndexStatement(table.Schema, table.Table, candidate.column)
+			recommendations = append(recommendations, rec)
+		}
+	}
+
+	sort.SliceStable(recommendations, func(i, j int) bool {
+		return recommendations[i].SeqScans > recommendations[j].SeqScans
+	})
+	return recommendations
+}
+
+type columnCalls struct {
+	column string
+	calls  int64
+}
+
+// filterColumnCalls totals, per unindexed column of table, the calls of the
+// statements that reference the table and filter on that column
+func filterColumnCalls(table TableScanStats, statements []StatementStats) []columnCalls {
+	columns := make(map[string]bool, len(table.Columns))
+	for _, column := range table.Columns {
+		columns[strings.ToLower(column)] = true
+	}
+	for _, column := range table.IndexedColumns {
+		delete(columns, strings.ToLower(column))
+	}
+
+	totals := make(map[string]int64)
+	for _, stmt := range statements {
+		if !referencesTable(stmt.Query, table.Schema, table.Table) {
+			continue
+		}
+		seen := make(map[string]bool)
+		for _, clause := range whereClause.FindAllStringSubmatch(stmt.Query, -1) {
+			for _, match := range filterColumn.FindAllStringSubmatch(clause[1], -1) {
+				column := strings.ToLower(match[1])
+				if columns[column] && !seen[column] {
+					seen[column] = true
+					totals[column] += stmt.Calls
+				}
+			}
+		}
+	}
+
+	candidates := make([]columnCalls, 0, len(totals))
+	for column, calls := range totals {
+		candidates = append(candidates, columnCalls{column: column, calls: calls})
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].calls != candidates[j].calls {
+			return candidates[i].calls > candidates[j].calls
+		}
+		return candidates[i].column < candidates[j].column
+	})
+	return candidates
+}
+
+// referencesTable reports whether query reads or updates schema.table
+func referencesTable(query, schema, table string) bool {
+	for _, match := range tableReference.FindAllStringSubmatch(query, -1) {
+		name := strings.ToLower(strings.ReplaceAll(match[1], `"`, ""))
+		if name == strings.ToLower(table) || name == strings.ToLower(schema+"."+table) {
+			return true
+		}
+	}
+	return false
+}
+
+func createIndexStatement(schema, table, column string) string {
+	name := fmt.Sprintf("idx_%s_%s", table, column)
+	return fmt.Sprintf("CREATE INDEX CONCURRENTLY %s ON %s.%s (%s)",
+		pq.QuoteIdentifier(name), pq.QuoteIdentifier(schema), pq.QuoteIdentifier(table), pq.QuoteIdentifier(column))
+}
+
+// loadTableScanStats reads scan activity, columns and leading index columns
+// for the user tables with at least minRows rows
+func loadTableScanStats(ctx context.Context, db *sql.DB, minRows int64) ([]TableScanStats, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT schemaname, relname, n_live_tup, seq_scan, COALESCE(idx_scan, 0)
+		FROM pg_stat_user_tables
+		WHERE n_live_tup >= $1
+		ORDER BY schemaname, relname
+	`, minRows)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query table scan stats: %w", err)
+	}
+	defer rows.Close()
+
+	tables := make([]TableScanStats, 0)
+	index := make(map[string]int)
+	for rows.Next() {
+		var t TableScanStats
+		if err := rows.Scan(&t.Schema, &t.Table, &t.Rows, &t.SeqScans, &t.IdxScans); err != nil {
+			return nil, fmt.Errorf("failed to scan table scan stats: %w", err)
+		}
+		index[t.Schema+"."+t.Table] = len(tables)
+		tables = append(tables, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(tables) == 0 {
+		return tables, nil
+	}
+
+	columnRows, err := db.QueryContext(ctx, `
+		SELECT table_schema, table_name, column_name
+		FROM information_schema.columns
+		WHERE table_schema NOT IN ('pg_catalog', 'information_schema')
+		ORDER BY table_schema, table_name, ordinal_position
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query table columns: %w", err)
+	}
+	defer columnRows.Close()
+	for columnRows.Next() {
+		var schema, table, column string
+		if err := columnRows.Scan(&schema, &table, &column); err != nil {
+			return nil, fmt.Errorf("failed to scan table column: %w", err)
+		}
+		if i, ok := index[schema+"."+table]; ok {
+			tables[i].Columns = append(tables[i].Columns, column)
+		}
+	}
+	if err := columnRows.Err(); err != nil {
+		return nil, err
+	}
+
+	indexRows, err := db.QueryContext(ctx, `
+		SELECT n.nspname, c.relname, a.attname
+		FROM pg_index i
+		JOIN pg_class c ON c.oid = i.indrelid
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		JOIN pg_attribute a ON a.attrelid = i.indrelid AND a.attnum = i.indkey[0]
+		WHERE n.nspname NOT IN ('pg_catalog', 'information_schema')
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query indexed columns: %w", err)
+	}
+	defer indexRows.Close()
+	for indexRows.Next() {
+		var schema, table, column string
+		if err := indexRows.Scan(&schema, &table, &column); err != nil {
+			return nil, fmt.Errorf("failed to scan indexed column: %w", err)
+		}
+		if i, ok := index[schema+"."+table]; ok {
+			tables[i].IndexedColumns = append(tables[i].IndexedColumns, column)
+		}
+	}
+	return tables, indexRows.Err()
+}
+
+// loadFrequentStatements returns the most frequently called statements of the
+// current database. It fails when pg_stat_statements is not installed.
+func loadFrequentStatements(ctx context.Context, db *sql.DB) ([]StatementStats, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT query, calls
+		FROM pg_stat_statements
+		WHERE dbid = (SELECT oid FROM pg_database WHERE datname = current_database())
+		ORDER BY calls DESC
+		LIMIT $1
+	`, indexAdvisorStatementLimit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	statements := make([]StatementStats, 0)
+	for rows.Next() {
+		var stmt StatementStats
+		if err := rows.Scan(&stmt.Query, &stmt.Calls); err != nil {
+			return nil, err
+		}
+		statements = append(statements, stmt)
+	}
+	return statements, rows.Err()
+}
@@ -0,0 +1,115 @@
+package monitoring
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zaptest"
+)
+
+func hotTable() TableScanStats {
+	return TableScanStats{
+		Schema:         "public",
+		Table:          "orders",
+		Rows:           2000000,
+		SeqScans:       9500,
+		IdxScans:       500,
+		Columns:        []string{"id", "customer_id", "status", "created_at"},
+		IndexedColumns: []string{"id"},
+	}
+}
+
+func TestRecommendIndexes_HighSeqScanTable(t *testing.T) {
+	statements := []StatementStats{
+		{Query: "SELECT * FROM orders WHERE customer_id = $1 AND status = $2", Calls: 8000},
+		{Query: "SELECT count(*) FROM public.orders o WHERE o.status IN ($1, $2)", Calls: 1500},
+		{Query: "SELECT * FROM orders WHERE id = $1", Calls: 90000}, // already indexed
+		{Query: "SELECT * FROM customers WHERE created_at > $1", Calls: 70000},
+	}
+
+	recs := RecommendIndexes([]TableScanStats{hotTable()}, statements, IndexAdvisorOptions{})
+	if len(recs) != 2 {
+		t.Fatalf("expected 2 recommendations, got %+v", recs)
+	}
+
+	status, customer := recs[0], recs[1]
+	if !reflect.DeepEqual(status.Columns, []string{"status"}) || status.FilterCalls != 9500 {
+		t.Errorf("expected status to be recommended first with 9500 calls, got %+v", status)
+	}
+	if !reflect.DeepEqual(customer.Columns, []string{"customer_id"}) || customer.FilterCalls != 8000 {
+		t.Errorf("expected customer_id with 8000 calls, got %+v", customer)
+	}
+	if want := `CREATE INDEX CONCURRENTLY "idx_orders_status" ON "public"."orders" ("status")`; status.Statement != want {
+		t.Errorf("expected statement %q, got %q", want, status.Statement)
+	}
+	if status.SeqScanRatio != 0.95 {
+		t.Errorf("expected seq scan ratio 0.95, got %v", status.SeqScanRatio)
+	}
+}
+
+func TestRecommendIndexes_SkipsSmallAndIndexedTables(t *testing.T) {
+	small := hotTable()
+	small.Rows = 100
+
+	indexed := hotTable()
+	indexed.SeqScans, indexed.IdxScans = 10, 10000
+
+	if recs := RecommendIndexes([]TableScanStats{small, indexed}, nil, IndexAdvisorOptions{}); len(recs) != 0 {
+		t.Errorf("expected no recommendations, got %+v", recs)
+	}
+}
+
+func TestRecommendIndexes_WithoutStatementStats(t *testing.T) {
+	recs := RecommendIndexes([]TableScanStats{hotTable()}, nil, IndexAdvisorOptions{})
+	if len(recs) != 1 {
+		t.Fatalf("expected the hotspot to be reported, got %+v", recs)
+	}
+	if len(recs[0].Columns) != 0 || recs[0].Statement != "" || recs[0].Reason == "" {
+		t.Errorf("expected a column-less recommendation with a reason, got %+v", recs[0])
+	}
+}
+
+func TestPostgresStatsCollector_GetIndexRecommendations(t *testing.T) {
+	db := testStatsDriver.open(t,
+		fakeResult{
+			match:   "FROM pg_stat_user_tables",
+			columns: []string{"schemaname", "relname", "n_live_tup", "seq_scan", "idx_scan"},
+			rows:    [][]driver.Value{{"public", "orders", int64(2000000), int64(9500), int64(500)}},
+		},
+		fakeResult{
+			match:   "FROM information_schema.columns",
+			columns: []string{"table_schema", "table_name", "column_name"},
+			rows: [][]driver.Value{
+				{"public", "orders", "id"},
+				{"public", "orders", "customer_id"},
+			},
+		},
+		fakeResult{
+			match:   "FROM pg_index",
+			columns: []string{"nspname", "relname", "attname"},
+			rows:    [][]driver.Value{{"public", "orders", "id"}},
+		},
+		fakeResult{match: "FROM pg_stat_statements", err: errors.New(`relation "pg_stat_statements" does not exist`)},
+	)
+
+	psc := NewPostgresStatsCollector(zaptest.NewLogger(t), time.Minute)
+	psc.databases["shard1"] = &DBConnection{DatabaseID: "shard1", DB: db}
+
+	recs, err := psc.GetIndexRecommendations(context.Background(), "shard1", IndexAdvisorOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(recs) != 1 || recs[0].Table != "orders" || recs[0].Rows != 2000000 {
+		t.Fatalf("expected a recommendation for orders, got %+v", recs)
+	}
+
+	// The row threshold is applied in the query
+	args := testStatsDriver.lastArgs(t.Name(), "FROM pg_stat_user_tables")
+	if len(args) != 1 || args[0].Value != int64(DefaultIndexAdvisorMinRows) {
+		t.Errorf("expected min rows %d, got %+v", DefaultIndexAdvisorMinRows, args)
+	}
+}
@@ -0,0 +1,51 @@
+package monitoring
+
+import "time"
+
+// CollectionInterval returns how often registered shards are collected from
+func (pc *PrometheusCollector) CollectionInterval() time.Duration {
+	pc.mu.RLock()
+	defer pc.mu.RUnlock()
+	return pc.collectionInterval
+}
+
+// SetCollectionInterval changes how often registered shards are collected
+// from. A running collector switches to the new interval straight away.
+// Non-positive values are ignored.
+func (pc *PrometheusCollector) SetCollectionInterval(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	pc.mu.Lock()
+	pc.collectionInterval = interval
+	pc.mu.Unlock()
+
+	select {
+	case pc.intervalChanged <- struct{}{}:
+	default:
+	}
+}
+
+// CollectionInterval returns how often registered databases are collected from
+func (psc *PostgresStatsCollector) CollectionInterval() time.Duration {
+	psc.mu.RLock()
+	defer psc.mu.RUnlock()
+	return psc.interval
+}
+
+// SetCollectionInterval changes how often registered databases are collected
+// from. A running collector switches to the new interval straight away.
+// Non-positive values are ignored.
+func (psc *PostgresStatsCollector) SetCollectionInterval(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	psc.mu.Lock()
+	psc.interval = interval
+	psc.mu.Unlock()
+
+	select {
+	case psc.intervalChanged <- struct{}{}:
+	default:
+	}
+}
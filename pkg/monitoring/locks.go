@@ -0,0 +1,187 @@
+package monitoring
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// LockWait is an edge of the lock-wait graph: a session waiting for a lock
+// that another session holds or is queued ahead of it for
+type LockWait struct {
+	BlockedPID    int        `json:"blocked_pid"`
+	BlockedUser   string     `json:"blocked_user"`
+	BlockedQuery  string     `json:"blocked_query"` // Literals are masked
+	WaitingSince  *time.Time `json:"waiting_since,omitempty"`
+	LockType      string     `json:"lock_type"`          // e.g. relation, transactionid, tuple
+	LockMode      string     `json:"lock_mode"`          // Mode the blocked session requested
+	Relation      string     `json:"relation,omitempty"` // Locked table, for relation and tuple locks
+	BlockingPID   int        `json:"blocking_pid"`
+	BlockingUser  string     `json:"blocking_user"`
+	BlockingState string     `json:"blocking_state"` // "idle in transaction" blockers are usually forgotten transactions
+	BlockingQuery string     `json:"blocking_query"` // Current or last query; literals are masked
+}
+
+// LockGraph is the lock-wait graph of a database server
+type LockGraph struct {
+	Waits []LockWait `json:"waits"`
+	// Blocked is the number of sessions waiting for a lock
+	Blocked int `json:"blocked"`
+	// RootBlockers are blocking sessions that are not waiting themselves;
+	// ending them releases every chain they head
+	RootBlockers []int `json:"root_blockers"`
+	// Cycles are sessions waiting on each other in a loop: deadlocks
+	// PostgreSQL has not broken yet
+	Cycles [][]int `json:"cycles,omitempty"`
+	// Deadlocks is how many deadlocks PostgreSQL has detected in the database
+	Deadlocks int64 `json:"deadlocks"`
+}
+
+// GetLockGraph returns which sessions block which on a registered database's
+// server, with the root blockers and any wait cycles
+func (psc *PostgresStatsCollector) GetLockGraph(ctx context.Context, databaseID string) (*LockGraph, error) {
+	db, err := psc.database(databaseID)
+	if err != nil {
+		return nil, err
+	}
+
+	waits, err := listLockWaits(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	graph := newLockGraph(waits)
+
+	deadlockQuery := `SELECT deadlocks FROM pg_stat_database WHERE datname = current_database()`
+	db.QueryRowContext(ctx, deadlockQuery).Scan(&graph.Deadlocks)
+	return graph, nil
+}
+
+// listLockWaits returns the lock-wait edges of a database server, from
+// pg_blocking_pids joined with the sessions in pg_stat_activity and the lock
+// the blocked session is waiting for in pg_locks
+func listLockWaits(ctx context.Context, db *sql.DB) ([]LockWait, error) {
+	query := `
+		SELECT
+			blocked.pid,
+			COALESCE(blocked.usename, ''),
+			COALESCE(blocked.query, ''),
+			blocked.query_start,
+			COALESCE(wait.locktype, ''),
+			COALESCE(wait.mode, ''),
+			COALESCE(wait.relation::regclass::text, ''),
+			blocking.pid,
+			COALESCE(blocking.usename, ''),
+			COALESCE(blocking.state, ''),
+			COALESCE(blocking.query, '')
+		FROM pg_stat_activity blocked
+		CROSS JOIN LATERAL unnest(pg_blocking_pids(blocked.pid)) AS blocker(pid)
+		JOIN pg_stat_activity blocking ON blocking.pid = blocker.pid
+		LEFT JOIN pg_locks wait ON wait.pid = blocked.pid AND NOT wait.granted
+		ORDER BY blocked.pid, blocking.pid
+	`
+
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query pg_locks: %w", err)
+	}
+	defer rows.Close()
+
+	waits := make([]LockWait, 0)
+	for rows.Next() {
+		var w LockWait
+		var waitingSince sql.NullTime
+		if err := rows.Scan(&w.BlockedPID, &w.BlockedUser, &w.BlockedQuery, &waitingSince,
+			&w.LockType, &w.LockMode, &w.Relation,
+			&w.BlockingPID, &w.BlockingUser, &w.BlockingState, &w.BlockingQuery); err != nil {
+			return nil, fmt.Errorf("failed to scan lock wait: %w", err)
+		}
+		if waitingSince.Valid {
+			w.WaitingSince = &waitingSince.Time
+		}
+		w.BlockedQuery = MaskQuery(w.BlockedQuery)
+		w.BlockingQuery = MaskQuery(w.BlockingQuery)
+		waits = append(waits, w)
+	}
+	return waits, rows.Err()
+}
+
+// newLockGraph finds the blocked sessions, root blockers and cycles of a set
+// of lock-wait edges
+func newLockGraph(waits []LockWait) *LockGraph {
+	blockedBy := make(map[int][]int)
+	for _, w := range waits {
+		blockedBy[w.BlockedPID] = append(blockedBy[w.BlockedPID], w.BlockingPID)
+	}
+
+	graph := &LockGraph{Waits: waits, Blocked: len(blockedBy), RootBlockers: make([]int, 0)}
+	roots := make(map[int]bool)
+	for _, w := range waits {
+		if _, waiting := blockedBy[w.BlockingPID]; !waiting && !roots[w.BlockingPID] {
+			roots[w.BlockingPID] = true
+			graph.RootBlockers = append(graph.RootBlockers, w.BlockingPID)
+		}
+	}
+	sort.Ints(graph.RootBlockers)
+	graph.Cycles = findWaitCycles(blockedBy)
+	return graph
+}
+
+// findWaitCycles returns each cycle of the wait-for graph once, starting at
+// its lowest PID
+func findWaitCycles(blockedBy map[int][]int) [][]int {
+	pids := make([]int, 0, len(blockedBy))
+	for pid := range blockedBy {
+		pids = append(pids, pid)
+	}
+	sort.Ints(pids)
+
+	var cycles [][]int
+	seen := make(map[string]bool)
+	var path []int
+	onPath := make(map[int]int) // PID -> index in path
+	done := make(map[int]bool)
+
+	var visit func(pid int)
+	visit = func(pid int) {
+		onPath[pid] = len(path)
+		path = append(path, pid)
+		for _, next := range blockedBy[pid] {
+			if start, ok := onPath[next]; ok {
+				cycle := normalizeCycle(path[start:])
+				if key := fmt.Sprint(cycle); !seen[key] {
+					seen[key] = true
+					cycles = append(cycles, cycle)
+				}
+				continue
+			}
+			if !done[next] {
+				visit(next)
+			}
+		}
+		path = path[:len(path)-1]
+		delete(onPath, pid)
+		done[pid] = true
+	}
+
+	for _, pid := range pids {
+		if !done[pid] {
+			visit(pid)
+		}
+	}
+	return cycles
+}
+
+// normalizeCycle rotates a cycle to start at its lowest PID
+func normalizeCycle(cycle []int) []int {
+	lowest := 0
+	for i, pid := range cycle {
+		if pid < cycle[lowest] {
+			lowest = i
+		}
+	}
+	normalized := make([]int, 0, len(cycle))
+	normalized = append(normalized, cycle[lowest:]...)
+	return append(normalized, cycle[:lowest]...)
+}
@@ -0,0 +1,106 @@
+package monitoring
+
+import (
+	"context"
+	"database/sql/driver"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.uber.org/zap/zaptest"
+)
+
+// blockingChain simulates 201 holding a lock in an idle transaction, 202
+// waiting for it and 203 queued behind 202, plus 301 and 302 deadlocked
+func blockingChain() fakeResult {
+	since := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	return fakeResult{
+		match: "FROM pg_stat_activity blocked",
+		columns: []string{
+			"blocked_pid", "blocked_user", "blocked_query", "waiting_since", "locktype", "mode", "relation",
+			"blocking_pid", "blocking_user", "blocking_state", "blocking_query",
+		},
+		rows: [][]driver.Value{
+			{int64(202), "app", "UPDATE orders SET total = 10 WHERE id = 7", since, "transactionid", "ShareLock", "", int64(201), "app", "idle in transaction", "UPDATE orders SET total = 5 WHERE id = 7"},
+			{int64(203), "app", "LOCK TABLE orders", since, "relation", "AccessExclusiveLock", "orders", int64(202), "app", "active", "UPDATE orders SET total = 10 WHERE id = 7"},
+			{int64(301), "app", "UPDATE a SET x = 1", since, "transactionid", "ShareLock", "", int64(302), "app", "active", "UPDATE b SET y = 2"},
+			{int64(302), "app", "UPDATE b SET y = 2", nil, "transactionid", "ShareLock", "", int64(301), "app", "active", "UPDATE a SET x = 1"},
+		},
+	}
+}
+
+func TestPostgresStatsCollector_GetLockGraph(t *testing.T) {
+	db := testStatsDriver.open(t,
+		blockingChain(),
+		fakeResult{match: "FROM pg_stat_database", columns: []string{"deadlocks"}, rows: [][]driver.Value{{int64(4)}}},
+	)
+
+	psc := NewPostgresStatsCollector(zaptest.NewLogger(t), time.Minute)
+	psc.databases["shard1"] = &DBConnection{DatabaseID: "shard1", DB: db}
+
+	graph, err := psc.GetLockGraph(context.Background(), "shard1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(graph.Waits) != 4 || graph.Blocked != 4 {
+		t.Fatalf("expected 4 waits by 4 blocked sessions, got %d waits by %d", len(graph.Waits), graph.Blocked)
+	}
+	if !reflect.DeepEqual(graph.RootBlockers, []int{201}) {
+		t.Errorf("expected 201 to head the chain, got %v", graph.RootBlockers)
+	}
+	if !reflect.DeepEqual(graph.Cycles, [][]int{{301, 302}}) {
+		t.Errorf("expected 301 and 302 to be deadlocked, got %v", graph.Cycles)
+	}
+	if graph.Deadlocks != 4 {
+		t.Errorf("expected 4 deadlocks, got %d", graph.Deadlocks)
+	}
+
+	wait := graph.Waits[0]
+	if wait.BlockedPID != 202 || wait.BlockingPID != 201 || wait.BlockingState != "idle in transaction" || wait.LockMode != "ShareLock" {
+		t.Errorf("unexpected wait %+v", wait)
+	}
+	if want := "UPDATE orders SET total = ? WHERE id = ?"; wait.BlockedQuery != want || wait.BlockingQuery != want {
+		t.Errorf("expected masked queries %q, got %q and %q", want, wait.BlockedQuery, wait.BlockingQuery)
+	}
+	if graph.Waits[1].Relation != "orders" || graph.Waits[3].WaitingSince != nil {
+		t.Errorf("unexpected waits %+v", graph.Waits[1:])
+	}
+}
+
+func TestFindWaitCycles(t *testing.T) {
+	tests := []struct {
+		name      string
+		blockedBy map[int][]int
+		want      [][]int
+	}{
+		{"chain", map[int][]int{2: {1}, 3: {2}}, nil},
+		{"pair", map[int][]int{7: {5}, 5: {7}}, [][]int{{5, 7}}},
+		{"triangle with a tail", map[int][]int{4: {2}, 2: {3}, 3: {9}, 9: {2}}, [][]int{{2, 3, 9}}},
+		{"waiting on several", map[int][]int{1: {2, 3}, 3: {1}}, [][]int{{1, 3}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := findWaitCycles(tt.blockedBy); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestPrometheusCollector_BlockedQueriesGauge(t *testing.T) {
+	db := testStatsDriver.open(t, blockingChain())
+
+	pc := NewPrometheusCollector(zaptest.NewLogger(t), time.Minute)
+	sc := &ShardCollector{shardID: "shard1", db: db, logger: zaptest.NewLogger(t)}
+
+	metrics := &ShardDetailedMetrics{}
+	if err := sc.collectLockWaitStats(context.Background(), metrics); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	pc.updateMetrics("shard1", "default", metrics)
+
+	if got := testutil.ToFloat64(pc.shardBlockedQueries.WithLabelValues("shard1", "default")); got != 4 {
+		t.Errorf("expected 4 blocked queries, got %v", got)
+	}
+}
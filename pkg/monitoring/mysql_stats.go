@@ -0,0 +1,156 @@
+package monitoring
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/sharding-system/pkg/models"
+	"go.uber.org/zap"
+)
+
+// ErrUnsupportedEngine is returned for operations the database's engine does not support
+var ErrUnsupportedEngine = errors.New("operation not supported for this database engine")
+
+// collectMySQLStats collects the statistics MySQL exposes through
+// information_schema and performance_schema. Replication, lock and background
+// writer stats are PostgreSQL-specific and left empty.
+func (psc *PostgresStatsCollector) collectMySQLStats(ctx context.Context, db *sql.DB, stats *PostgresStats) {
+	query := `SELECT DATABASE(), COALESCE(SUM(data_length + index_length), 0) FROM information_schema.tables WHERE table_schema = DATABASE()`
+	if err := db.QueryRowContext(ctx, query).Scan(&stats.DatabaseName, &stats.Size); err != nil {
+		psc.logger.Warn("failed to collect database info", zap.Error(err))
+	}
+	if err := collectMySQLConnectionStats(ctx, db, &stats.Connections); err != nil {
+		psc.logger.Warn("failed to collect connection stats", zap.Error(err))
+	}
+	if err := psc.collectMySQLQueryStats(ctx, db, stats); err != nil {
+		psc.logger.Warn("failed to collect query stats", zap.Error(err))
+	}
+
+	tableQuery := `SELECT count(*), COALESCE(SUM(table_rows), 0), COALESCE(SUM(index_length), 0) FROM information_schema.tables WHERE table_schema = DATABASE() AND table_type = 'BASE TABLE'`
+	if err := db.QueryRowContext(ctx, tableQuery).Scan(&stats.Tables.TotalTables, &stats.Tables.TotalRows, &stats.Indexes.IndexSize); err != nil {
+		psc.logger.Warn("failed to collect table stats", zap.Error(err))
+	}
+	stats.Tables.LiveTuples = stats.Tables.TotalRows
+
+	indexQuery := `SELECT count(DISTINCT table_name, index_name) FROM information_schema.statistics WHERE table_schema = DATABASE()`
+	if err := db.QueryRowContext(ctx, indexQuery).Scan(&stats.Indexes.TotalIndexes); err != nil {
+		psc.logger.Warn("failed to collect index stats", zap.Error(err))
+	}
+}
+
+// collectMySQLConnectionStats counts client connections by command. Sleeping
+// connections are idle; every other command is treated as active.
+func collectMySQLConnectionStats(ctx context.Context, db *sql.DB, connections *ConnectionStats) error {
+	query := `SELECT command, count(*) FROM information_schema.processlist WHERE command NOT IN ('Daemon', 'Binlog Dump') GROUP BY command`
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	connections.ByState = make(map[string]int)
+	for rows.Next() {
+		var command string
+		var count int
+		if err := rows.Scan(&command, &count); err != nil {
+			continue
+		}
+		state := strings.ToLower(command)
+		connections.ByState[state] = count
+		connections.Total += count
+		if state == "sleep" {
+			connections.Idle += count
+		} else {
+			connections.Active += count
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if err := db.QueryRowContext(ctx, `SELECT @@max_connections`).Scan(&connections.MaxConnections); err == nil {
+		if connections.MaxConnections > 0 {
+			connections.PercentUsed = float64(connections.Total) / float64(connections.MaxConnections) * 100
+		}
+	}
+	return nil
+}
+
+// collectMySQLQueryStats reads the server's query and buffer pool counters and
+// counts statements running longer than the slow query threshold
+func (psc *PostgresStatsCollector) collectMySQLQueryStats(ctx context.Context, db *sql.DB, stats *PostgresStats) error {
+	status, err := mysqlGlobalStatus(ctx, db, "Questions", "Innodb_buffer_pool_read_requests", "Innodb_buffer_pool_reads")
+	if err != nil {
+		return err
+	}
+	stats.Queries.TotalQueries = status["Questions"]
+	if requests := status["Innodb_buffer_pool_read_requests"]; requests > 0 {
+		stats.Queries.CacheHitRatio = float64(requests-status["Innodb_buffer_pool_reads"]) / float64(requests) * 100
+	}
+
+	threshold := psc.SlowQueryThreshold()
+	query := `SELECT count(*) FROM information_schema.processlist WHERE command = 'Query' AND time >= ?`
+	return db.QueryRowContext(ctx, query, int64(threshold.Seconds())).Scan(&stats.Queries.SlowQueries)
+}
+
+// mysqlGlobalStatus reads numeric server status variables
+func mysqlGlobalStatus(ctx context.Context, db *sql.DB, names ...string) (map[string]int64, error) {
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(names)), ", ")
+	args := make([]interface{}, len(names))
+	for i, name := range names {
+		args[i] = name
+	}
+
+	query := fmt.Sprintf(`SELECT variable_name, variable_value FROM performance_schema.global_status WHERE variable_name IN (%s)`, placeholders)
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	status := make(map[string]int64, len(names))
+	for rows.Next() {
+		var name, value string
+		if err := rows.Scan(&name, &value); err != nil {
+			return nil, err
+		}
+		if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+			status[name] = n
+		}
+	}
+	return status, rows.Err()
+}
+
+// collectMySQL collects the shard metrics MySQL can provide
+func (sc *ShardCollector) collectMySQL(ctx context.Context, metrics *ShardDetailedMetrics) {
+	var connections ConnectionStats
+	if err := collectMySQLConnectionStats(ctx, sc.db, &connections); err != nil {
+		sc.logger.Warn("failed to collect connection stats", zap.Error(err))
+	}
+	metrics.ActiveConnections = int64(connections.Active)
+	metrics.IdleConnections = int64(connections.Idle)
+	metrics.MaxConnections = int64(connections.MaxConnections)
+
+	status, err := mysqlGlobalStatus(ctx, sc.db, "Com_commit", "Com_rollback", "Innodb_deadlocks")
+	if err != nil {
+		sc.logger.Warn("failed to collect database stats", zap.Error(err))
+	}
+	metrics.TransactionsCommit = status["Com_commit"]
+	metrics.TransactionsRollback = status["Com_rollback"]
+	metrics.Deadlocks = status["Innodb_deadlocks"]
+
+	query := `SELECT count(*), COALESCE(SUM(table_rows), 0) FROM information_schema.tables WHERE table_schema = DATABASE() AND table_type = 'BASE TABLE'`
+	if err := sc.db.QueryRowContext(ctx, query).Scan(&metrics.TableCount, &metrics.TotalRows); err != nil {
+		sc.logger.Warn("failed to collect table stats", zap.Error(err))
+	}
+}
+
+// isMySQL reports whether engine names MySQL
+func isMySQL(engine string) bool {
+	return models.NormalizeEngine(engine) == models.EngineMySQL
+}
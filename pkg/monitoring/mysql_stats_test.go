@@ -0,0 +1,130 @@
+package monitoring
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+	"go.uber.org/zap/zaptest"
+)
+
+// mysqlConnections answers the processlist and max_connections queries
+var mysqlConnections = []fakeResult{
+	{match: "GROUP BY command", columns: []string{"command", "count"}, rows: [][]driver.Value{{"Sleep", int64(6)}, {"Query", int64(2)}}},
+	{match: "@@max_connections", columns: []string{"max"}, rows: [][]driver.Value{{int64(16)}}},
+}
+
+// assertNoPostgresQueries fails if any query run against the test's DSN uses
+// PostgreSQL's catalogs
+func assertNoPostgresQueries(t *testing.T) {
+	t.Helper()
+	queries := testStatsDriver.queries(t.Name())
+	if len(queries) == 0 {
+		t.Fatal("expected queries to be run")
+	}
+	for _, query := range queries {
+		if strings.Contains(query, "pg_") {
+			t.Errorf("expected only MySQL queries, got %s", query)
+		}
+	}
+}
+
+func TestPostgresStatsCollector_CollectsMySQLStats(t *testing.T) {
+	db := testStatsDriver.open(t, append([]fakeResult{
+		{match: "SUM(data_length + index_length)", columns: []string{"db", "size"}, rows: [][]driver.Value{{"orders", int64(4096)}}},
+		{match: "performance_schema.global_status", columns: []string{"variable_name", "variable_value"}, rows: [][]driver.Value{
+			{"Questions", "1200"},
+			{"Innodb_buffer_pool_read_requests", "1000"},
+			{"Innodb_buffer_pool_reads", "50"},
+		}},
+		{match: "command = 'Query' AND time >=", columns: []string{"count"}, rows: [][]driver.Value{{int64(1)}}},
+		{match: "table_type = 'BASE TABLE'", columns: []string{"tables", "rows", "index_size"}, rows: [][]driver.Value{{int64(3), int64(500), int64(1024)}}},
+		{match: "information_schema.statistics", columns: []string{"indexes"}, rows: [][]driver.Value{{int64(5)}}},
+	}, mysqlConnections...)...)
+
+	psc := NewPostgresStatsCollector(zaptest.NewLogger(t), time.Minute)
+	dbConn := &DBConnection{DatabaseID: "shard1", Engine: "mysql", DB: db}
+	psc.databases["shard1"] = dbConn
+
+	stats, err := psc.CollectStats(context.Background(), dbConn)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if stats.DatabaseName != "orders" || stats.Size != 4096 {
+		t.Errorf("expected orders at 4096 bytes, got %s at %d", stats.DatabaseName, stats.Size)
+	}
+	if stats.Connections.Total != 8 || stats.Connections.Idle != 6 || stats.Connections.Active != 2 || stats.Connections.PercentUsed != 50 {
+		t.Errorf("expected 8 connections (6 idle, 2 active, 50%% used), got %+v", stats.Connections)
+	}
+	if stats.Queries.TotalQueries != 1200 || stats.Queries.CacheHitRatio != 95 || stats.Queries.SlowQueries != 1 {
+		t.Errorf("expected 1200 queries, a 95%% hit ratio and 1 slow query, got %+v", stats.Queries)
+	}
+	if stats.Tables.TotalTables != 3 || stats.Tables.TotalRows != 500 || stats.Indexes.TotalIndexes != 5 || stats.Indexes.IndexSize != 1024 {
+		t.Errorf("expected 3 tables, 500 rows and 5 indexes of 1024 bytes, got %+v %+v", stats.Tables, stats.Indexes)
+	}
+	assertNoPostgresQueries(t)
+
+	// Operations built on PostgreSQL functions are refused
+	if err := psc.ResetStats(context.Background(), "shard1"); !errors.Is(err, ErrUnsupportedEngine) {
+		t.Errorf("expected ErrUnsupportedEngine, got %v", err)
+	}
+}
+
+func TestShardCollector_CollectsMySQLMetrics(t *testing.T) {
+	db := testStatsDriver.open(t, append([]fakeResult{
+		{match: "performance_schema.global_status", columns: []string{"variable_name", "variable_value"}, rows: [][]driver.Value{
+			{"Com_commit", "40"},
+			{"Com_rollback", "2"},
+			{"Innodb_deadlocks", "1"},
+		}},
+		{match: "table_type = 'BASE TABLE'", columns: []string{"tables", "rows"}, rows: [][]driver.Value{{int64(3), int64(500)}}},
+	}, mysqlConnections...)...)
+
+	sc := &ShardCollector{shardID: "shard1", engine: "mysql", db: db, logger: zaptest.NewLogger(t)}
+	metrics, err := sc.Collect(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if metrics.ActiveConnections != 2 || metrics.IdleConnections != 6 || metrics.MaxConnections != 16 {
+		t.Errorf("expected 2 active and 6 idle of 16 connections, got %+v", metrics)
+	}
+	if metrics.TransactionsCommit != 40 || metrics.TransactionsRollback != 2 || metrics.Deadlocks != 1 {
+		t.Errorf("expected 40 commits, 2 rollbacks and 1 deadlock, got %+v", metrics)
+	}
+	if metrics.TableCount != 3 || metrics.TotalRows != 500 {
+		t.Errorf("expected 3 tables with 500 rows, got %+v", metrics)
+	}
+	assertNoPostgresQueries(t)
+}
+
+func TestPrometheusCollector_RegisterShardWithEngine(t *testing.T) {
+	pc := NewPrometheusCollector(zaptest.NewLogger(t), time.Minute)
+
+	if err := pc.RegisterShardWithEngine("orders", "mysql", "app:pw@tcp(127.0.0.1:3306)/orders"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := pc.RegisterShardWithEngine("billing", "", "host=127.0.0.1 dbname=billing"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer pc.UnregisterShard("orders")
+	defer pc.UnregisterShard("billing")
+
+	if _, ok := pc.collectors["orders"].db.Driver().(*mysql.MySQLDriver); !ok {
+		t.Errorf("expected the MySQL shard to use the mysql driver, got %T", pc.collectors["orders"].db.Driver())
+	}
+	if _, ok := pc.collectors["billing"].db.Driver().(*pq.Driver); !ok {
+		t.Errorf("expected a shard without an engine to use the postgres driver, got %T", pc.collectors["billing"].db.Driver())
+	}
+
+	if err := pc.RegisterShardWithEngine("legacy", "oracle", "dsn"); err == nil {
+		t.Error("expected an unsupported engine to be rejected")
+	}
+	if err := NewPostgresStatsCollector(zaptest.NewLogger(t), time.Minute).RegisterDatabaseWithEngine("legacy", "oracle", "dsn"); err == nil {
+		t.Error("expected an unsupported engine to be rejected by the stats collector")
+	}
+}
@@ -0,0 +1,49 @@
+package monitoring
+
+import "database/sql"
+
+// DefaultPoolSize is the connection pool collectors keep to each database
+// unless configured otherwise. Collection runs its queries one after another,
+// so a couple of connections is enough for most shards.
+var DefaultPoolSize = PoolSize{MaxOpenConns: 2, MaxIdleConns: 1}
+
+// NoIdleConns as MaxIdleConns keeps no idle connections, closing each one as
+// soon as it is released
+const NoIdleConns = -1
+
+// PoolSize bounds the connections a collector keeps to one database. Zero
+// fields are unset.
+type PoolSize struct {
+	MaxOpenConns int
+	MaxIdleConns int
+}
+
+// apply sets the pool size on a connection
+func (s PoolSize) apply(db *sql.DB) {
+	db.SetMaxOpenConns(s.MaxOpenConns)
+	db.SetMaxIdleConns(s.MaxIdleConns)
+}
+
+// poolSizes holds a collector's pool size and its per-shard overrides
+type poolSizes struct {
+	size   PoolSize
+	shards map[string]PoolSize
+}
+
+// forShard returns the pool size of a shard's connection. Zero fields fall
+// back to the collector's size, then to DefaultPoolSize.
+func (p poolSizes) forShard(shardID string) PoolSize {
+	size := DefaultPoolSize
+	for _, override := range []PoolSize{p.size, p.shards[shardID]} {
+		if override.MaxOpenConns > 0 {
+			size.MaxOpenConns = override.MaxOpenConns
+		}
+		if override.MaxIdleConns != 0 {
+			size.MaxIdleConns = override.MaxIdleConns
+		}
+	}
+	if size.MaxIdleConns > size.MaxOpenConns {
+		size.MaxIdleConns = size.MaxOpenConns
+	}
+	return size
+}
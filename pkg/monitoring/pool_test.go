@@ -0,0 +1,96 @@
+package monitoring
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zaptest"
+)
+
+// assertPoolSize checks the limits of a connection pool. The idle limit is not
+// exposed, so connections are opened and returned to see how many stay idle.
+func assertPoolSize(t *testing.T, db *sql.DB, want PoolSize) {
+	t.Helper()
+	if got := db.Stats().MaxOpenConnections; got != want.MaxOpenConns {
+		t.Errorf("expected at most %d open connections, got %d", want.MaxOpenConns, got)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	conns := make([]*sql.Conn, 0, want.MaxOpenConns)
+	for i := 0; i < want.MaxOpenConns; i++ {
+		conn, err := db.Conn(ctx)
+		if err != nil {
+			t.Fatalf("failed to open connection %d: %v", i+1, err)
+		}
+		conns = append(conns, conn)
+	}
+	for _, conn := range conns {
+		conn.Close()
+	}
+	if got := db.Stats().Idle; got != want.MaxIdleConns {
+		t.Errorf("expected %d idle connections, got %d", want.MaxIdleConns, got)
+	}
+}
+
+func TestPoolSizes_ForShard(t *testing.T) {
+	pools := poolSizes{
+		size: PoolSize{MaxOpenConns: 6},
+		shards: map[string]PoolSize{
+			"big":   {MaxOpenConns: 12, MaxIdleConns: 4},
+			"small": {MaxOpenConns: 1},
+			"none":  {MaxIdleConns: NoIdleConns},
+		},
+	}
+
+	for shardID, want := range map[string]PoolSize{
+		"other": {MaxOpenConns: 6, MaxIdleConns: 1},
+		"big":   {MaxOpenConns: 12, MaxIdleConns: 4},
+		"small": {MaxOpenConns: 1, MaxIdleConns: 1},
+		"none":  {MaxOpenConns: 6, MaxIdleConns: NoIdleConns},
+	} {
+		if got := pools.forShard(shardID); got != want {
+			t.Errorf("%s: expected %+v, got %+v", shardID, want, got)
+		}
+	}
+	if got := (poolSizes{}).forShard("any"); got != DefaultPoolSize {
+		t.Errorf("expected the default pool size, got %+v", got)
+	}
+}
+
+func TestPrometheusCollector_AppliesPoolSize(t *testing.T) {
+	pc := NewPrometheusCollector(zaptest.NewLogger(t), time.Minute)
+	pc.driver = "monitoringtest"
+	pc.SetPoolSize(PoolSize{MaxOpenConns: 4, MaxIdleConns: 2}, map[string]PoolSize{"big": {MaxOpenConns: 8, MaxIdleConns: 3}})
+	defer pc.UnregisterShard("shard1")
+	defer pc.UnregisterShard("big")
+
+	if err := pc.RegisterShard("shard1", t.Name()+"-shard1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := pc.RegisterShard("big", t.Name()+"-big"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertPoolSize(t, pc.collectors["shard1"].db, PoolSize{MaxOpenConns: 4, MaxIdleConns: 2})
+	assertPoolSize(t, pc.collectors["big"].db, PoolSize{MaxOpenConns: 8, MaxIdleConns: 3})
+
+	// Registered shards are resized in place
+	pc.SetPoolSize(PoolSize{MaxOpenConns: 3, MaxIdleConns: 1}, nil)
+	assertPoolSize(t, pc.collectors["big"].db, PoolSize{MaxOpenConns: 3, MaxIdleConns: 1})
+}
+
+func TestPostgresStatsCollector_AppliesPoolSize(t *testing.T) {
+	psc := NewPostgresStatsCollector(zaptest.NewLogger(t), time.Minute)
+	psc.driver = "monitoringtest"
+	defer psc.UnregisterDatabase("db1")
+
+	if err := psc.RegisterDatabase("db1", t.Name()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertPoolSize(t, psc.databases["db1"].DB, DefaultPoolSize)
+
+	psc.SetPoolSize(PoolSize{}, map[string]PoolSize{"db1": {MaxOpenConns: 5, MaxIdleConns: 2}})
+	assertPoolSize(t, psc.databases["db1"].DB, PoolSize{MaxOpenConns: 5, MaxIdleConns: 2})
+}
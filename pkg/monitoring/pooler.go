@@ -0,0 +1,40 @@
+package monitoring
+
+// PoolerResolver is implemented by EndpointResolvers that know which shards
+// the collectors reach through a connection pooler such as PgBouncer.
+// pg_stat_activity then lists the pooler's server connections rather than
+// its clients, so the collectors skip connection statistics for those shards.
+// Server-wide statistics such as table, query and replication stats are
+// collected as usual.
+type PoolerResolver interface {
+	ThroughPooler(shardID string) bool
+}
+
+func (r *catalogResolver) ThroughPooler(shardID string) bool {
+	shard, err := r.catalog.GetShardByID(shardID)
+	if err != nil {
+		return false
+	}
+	return shard.CollectsThroughPooler()
+}
+
+// throughPooler reports whether a shard is collected from through a pooler.
+// It is looked up when the shard is registered rather than on every
+// collection, as a shard's pooler is fixed when it is created.
+func (pc *PrometheusCollector) throughPooler(shardID string) bool {
+	pc.mu.RLock()
+	resolver := pc.resolver
+	pc.mu.RUnlock()
+	poolers, ok := resolver.(PoolerResolver)
+	return ok && poolers.ThroughPooler(shardID)
+}
+
+// throughPooler reports whether a database is collected from through a
+// pooler. Like the shard collectors, it is looked up at registration.
+func (psc *PostgresStatsCollector) throughPooler(databaseID string) bool {
+	psc.mu.RLock()
+	resolver := psc.resolver
+	psc.mu.RUnlock()
+	poolers, ok := resolver.(PoolerResolver)
+	return ok && poolers.ThroughPooler(databaseID)
+}
@@ -0,0 +1,134 @@
+package monitoring
+
+import (
+	"context"
+	"database/sql/driver"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sharding-system/pkg/models"
+	"go.uber.org/zap/zaptest"
+)
+
+// poolerCatalog has a shard collected through a pooler, one whose pooler's
+// server is reached directly and one without a pooler
+func poolerCatalog() *failoverCatalog {
+	return &failoverCatalog{shards: map[string]*models.Shard{
+		"pooled": {ID: "pooled", Host: "pgbouncer", Port: 6432, Database: "orders", Pooler: true},
+		"direct": {ID: "direct", Host: "pgbouncer", Port: 6432, Database: "orders", Pooler: true, DirectEndpoint: "db1:5432"},
+		"plain":  {ID: "plain", Host: "db2", Database: "orders"},
+	}}
+}
+
+// connectionQueries counts the queries run against a DSN that contain match
+func connectionQueries(dsn, match string) int {
+	count := 0
+	for _, query := range testStatsDriver.queries(dsn) {
+		if strings.Contains(query, match) {
+			count++
+		}
+	}
+	return count
+}
+
+func TestCatalogResolver_ThroughPooler(t *testing.T) {
+	resolver := NewCatalogResolver(poolerCatalog()).(PoolerResolver)
+
+	for shardID, want := range map[string]bool{"pooled": true, "direct": false, "plain": false, "missing": false} {
+		if got := resolver.ThroughPooler(shardID); got != want {
+			t.Errorf("%s: expected ThroughPooler %v, got %v", shardID, want, got)
+		}
+	}
+}
+
+func TestPrometheusCollector_SkipsConnectionMetricsThroughPooler(t *testing.T) {
+	testStatsDriver.open(t, fakeResult{
+		match:   "as max_conn",
+		columns: []string{"active", "idle", "waiting", "max_conn"},
+		rows:    [][]driver.Value{{int64(3), int64(1), int64(0), int64(100)}},
+	})
+	catalog := poolerCatalog()
+	pc := NewPrometheusCollector(zaptest.NewLogger(t), time.Minute)
+	pc.driver = "monitoringtest"
+	pc.SetEndpointResolver(NewCatalogResolver(catalog), 0)
+	if err := pc.RegisterShard("pooled", t.Name()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer pc.UnregisterShard("pooled")
+
+	pc.collectAll(context.Background())
+	if got := connectionQueries(t.Name(), "as max_conn"); got != 0 {
+		t.Errorf("expected no connection queries through the pooler, got %d", got)
+	}
+	if got := testutil.CollectAndCount(pc.shardConnections); got != 0 {
+		t.Errorf("expected no connection series for a shard collected through a pooler, got %d", got)
+	}
+	if metrics, ok := pc.GetShardMetrics("pooled"); !ok || !metrics.ThroughPooler {
+		t.Errorf("expected the metrics to be marked as collected through the pooler, got %+v", metrics)
+	}
+
+	// Shards collected from directly keep their connection metrics
+	if err := pc.RegisterShard("plain", t.Name()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer pc.UnregisterShard("plain")
+	pc.collectAll(context.Background())
+	if got := connectionQueries(t.Name(), "as max_conn"); got != 1 {
+		t.Errorf("expected one connection query, for the shard without a pooler, got %d", got)
+	}
+	if got := testutil.ToFloat64(pc.shardConnections.WithLabelValues("plain", "default", "active")); got != 3 {
+		t.Errorf("expected 3 active connections, got %v", got)
+	}
+	if got := testutil.CollectAndCount(pc.shardConnections); got != 3 {
+		t.Errorf("expected only the connection series of the shard without a pooler, got %d", got)
+	}
+
+	// Whether a shard is behind a pooler is looked up once, at registration
+	if catalog.lookups != 2 {
+		t.Errorf("expected a catalog lookup per registration and none per collection, got %d", catalog.lookups)
+	}
+}
+
+func TestPostgresStatsCollector_SkipsConnectionStatsThroughPooler(t *testing.T) {
+	testStatsDriver.open(t,
+		fakeResult{match: "GROUP BY state", columns: []string{"state", "count"}, rows: [][]driver.Value{{"active", int64(7)}}},
+		fakeResult{match: "max_connections", columns: []string{"setting"}, rows: [][]driver.Value{{int64(100)}}},
+		fakeResult{match: "host(client_addr)", columns: []string{"application_name", "client_addr", "count"}},
+	)
+	catalog := poolerCatalog()
+	psc := NewPostgresStatsCollector(zaptest.NewLogger(t), time.Minute)
+	psc.driver = "monitoringtest"
+	psc.SetEndpointResolver(NewCatalogResolver(catalog), 0)
+	for _, databaseID := range []string{"pooled", "direct", "plain"} {
+		if err := psc.RegisterDatabase(databaseID, t.Name()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		defer psc.UnregisterDatabase(databaseID)
+	}
+
+	stats, err := psc.CollectStats(context.Background(), psc.databases["pooled"])
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !stats.ThroughPooler || stats.Connections.Total != 0 {
+		t.Errorf("expected connection stats to be skipped through the pooler, got %+v", stats.Connections)
+	}
+	if got := connectionQueries(t.Name(), "GROUP BY state"); got != 0 {
+		t.Errorf("expected no connection queries through the pooler, got %d", got)
+	}
+
+	for _, databaseID := range []string{"direct", "plain"} {
+		stats, err := psc.CollectStats(context.Background(), psc.databases[databaseID])
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if stats.ThroughPooler || stats.Connections.Total != 7 {
+			t.Errorf("%s: expected connection stats when collected from directly, got %+v", databaseID, stats.Connections)
+		}
+	}
+	if catalog.lookups != 3 {
+		t.Errorf("expected a catalog lookup per registration and none per collection, got %d", catalog.lookups)
+	}
+}
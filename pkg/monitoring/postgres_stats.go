@@ -3,14 +3,24 @@ package monitoring
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"sort"
 	"sync"
 	"time"
 
 	_ "github.com/lib/pq"
+	"github.com/sharding-system/pkg/models"
 	"go.uber.org/zap"
 )
 
+// CollectorApplicationName identifies the collectors' connections in
+// pg_stat_activity
+const CollectorApplicationName = "sharding-manager-collector"
+
+// ErrDatabaseNotRegistered is returned for databases the collector does not monitor
+var ErrDatabaseNotRegistered = errors.New("database not registered")
+
 // PostgresStatsCollector collects detailed PostgreSQL statistics
 type PostgresStatsCollector struct {
 	logger    *zap.Logger
@@ -18,6 +28,21 @@ type PostgresStatsCollector struct {
 	mu        sync.RWMutex
 	interval  time.Duration
 	stopCh    chan struct{}
+
+	intervalChanged chan struct{} // Wakes Start to pick up a new collection interval
+	started         chan struct{} // Closed once Start runs
+	startOnce       sync.Once
+
+	slowQueryThreshold time.Duration
+
+	// Failover handling: after reconnectAfter failed connection checks the
+	// database's DSN is resolved again
+	resolver       EndpointResolver
+	reconnectAfter int
+	driver         string // database/sql driver connections are opened with; empty uses the engine's
+	pools          poolSizes
+
+	sizeRetention time.Duration // How long size samples are kept for growth trending
 }
 
 // DBConnection represents a database connection for stats collection
@@ -25,24 +50,40 @@ type DBConnection struct {
 	DSN         string
 	DB          *sql.DB
 	DatabaseID  string
+	Engine      string // "postgres" or "mysql"
 	LastStats   *PostgresStats
 	LastError   error
 	LastCollect time.Time
+
+	// resetAt is when the database's statistics were last reset. Collections
+	// started before it cannot serve as a baseline for deltas.
+	resetAt time.Time
+
+	// throughPooler is set when the database is reached through a connection
+	// pooler; see PoolerResolver
+	throughPooler bool
+
+	// failures counts consecutive failed connection checks
+	failures int
+
+	// sizes holds the database's recent size samples
+	sizes *sizeHistory
 }
 
 // PostgresStats contains comprehensive PostgreSQL statistics
 type PostgresStats struct {
-	DatabaseID   string    `json:"database_id"`
-	DatabaseName string    `json:"database_name"`
-	Size         int64     `json:"size_bytes"`
-	CollectedAt  time.Time `json:"collected_at"`
-	Connections  ConnectionStats  `json:"connections"`
-	Queries      QueryStats       `json:"queries"`
-	Replication  ReplicationStats `json:"replication"`
-	Tables       TableStats       `json:"tables"`
-	Indexes      IndexStats       `json:"indexes"`
-	Locks        LockStats        `json:"locks"`
-	BGWriter     BGWriterStats    `json:"bg_writer"`
+	DatabaseID    string           `json:"database_id"`
+	DatabaseName  string           `json:"database_name"`
+	Size          int64            `json:"size_bytes"`
+	CollectedAt   time.Time        `json:"collected_at"`
+	ThroughPooler bool             `json:"through_pooler,omitempty"` // Collected through a connection pooler, so Connections is left empty
+	Connections   ConnectionStats  `json:"connections"`
+	Queries       QueryStats       `json:"queries"`
+	Replication   ReplicationStats `json:"replication"`
+	Tables        TableStats       `json:"tables"`
+	Indexes       IndexStats       `json:"indexes"`
+	Locks         LockStats        `json:"locks"`
+	BGWriter      BGWriterStats    `json:"bg_writer"`
 }
 
 // ConnectionStats represents connection statistics
@@ -56,15 +97,28 @@ type ConnectionStats struct {
 	PercentUsed    float64            `json:"percent_used"`
 	ByState        map[string]int     `json:"by_state"`
 	ByApplication  map[string]int     `json:"by_application"`
+	ByClientAddr   map[string]int     `json:"by_client_addr"`
+	TopSources     []ConnectionSource `json:"top_sources,omitempty"` // Busiest application and address pairs, most connections first
+}
+
+// ConnectionSource counts the connections opened by an application from one address
+type ConnectionSource struct {
+	Application string `json:"application"`
+	ClientAddr  string `json:"client_addr"`
+	Count       int    `json:"count"`
 }
 
+// maxConnectionSources caps the connection sources reported per database
+const maxConnectionSources = 10
+
 // QueryStats represents query performance statistics
 type QueryStats struct {
 	TotalQueries     int64      `json:"total_queries"`
 	QueriesPerSecond float64    `json:"queries_per_second"`
 	AvgQueryTime     float64    `json:"avg_query_time_ms"`
 	MaxQueryTime     float64    `json:"max_query_time_ms"`
-	SlowQueries      int64      `json:"slow_queries"`
+	SlowQueries      int64      `json:"slow_queries"`    // Queries currently running longer than the slow query threshold
+	SlowStatements   int64      `json:"slow_statements"` // pg_stat_statements entries whose mean time exceeds the threshold
 	CacheHitRatio    float64    `json:"cache_hit_ratio"`
 	TopQueries       []TopQuery `json:"top_queries,omitempty"`
 }
@@ -160,21 +214,74 @@ func NewPostgresStatsCollector(logger *zap.Logger, interval time.Duration) *Post
 		databases: make(map[string]*DBConnection),
 		interval:  interval,
 		stopCh:    make(chan struct{}),
+
+		intervalChanged: make(chan struct{}, 1),
+		started:         make(chan struct{}),
+
+		slowQueryThreshold: DefaultSlowQueryThreshold,
+		reconnectAfter:     DefaultReconnectAfter,
+		sizeRetention:      DefaultSizeHistoryRetention,
 	}
 }
 
-// RegisterDatabase registers a database for stats collection
-func (psc *PostgresStatsCollector) RegisterDatabase(databaseID, dsn string) error {
+// SetSlowQueryThreshold sets how long a query must run before it counts as
+// slow. Non-positive values restore the default.
+func (psc *PostgresStatsCollector) SetSlowQueryThreshold(threshold time.Duration) {
+	if threshold <= 0 {
+		threshold = DefaultSlowQueryThreshold
+	}
 	psc.mu.Lock()
 	defer psc.mu.Unlock()
+	psc.slowQueryThreshold = threshold
+}
+
+// SlowQueryThreshold returns how long a query must run before it counts as slow
+func (psc *PostgresStatsCollector) SlowQueryThreshold() time.Duration {
+	psc.mu.RLock()
+	defer psc.mu.RUnlock()
+	return psc.slowQueryThreshold
+}
 
-	db, err := sql.Open("postgres", dsn)
+// RegisterDatabase registers a PostgreSQL database for stats collection
+func (psc *PostgresStatsCollector) RegisterDatabase(databaseID, dsn string) error {
+	return psc.RegisterDatabaseWithEngine(databaseID, models.EnginePostgres, dsn)
+}
+
+// RegisterDatabaseWithEngine registers a database running on engine for stats
+// collection. The engine decides the driver the DSN is opened with and the
+// queries stats are collected with.
+func (psc *PostgresStatsCollector) RegisterDatabaseWithEngine(databaseID, engine, dsn string) error {
+	driverName, err := models.DriverName(engine)
+	if err != nil {
+		return err
+	}
+
+	engine = models.NormalizeEngine(engine)
+
+	psc.mu.Lock()
+	if psc.driver != "" {
+		driverName = psc.driver
+	}
+	pool := psc.pools.forShard(databaseID)
+
+	// Registering a database again keeps its connection, unless the database
+	// is now reached elsewhere
+	if psc.connectedTo(databaseID, engine, dsn) {
+		psc.mu.Unlock()
+		psc.logger.Debug("database already registered for stats collection", zap.String("database_id", databaseID))
+		return nil
+	}
+	psc.mu.Unlock()
+	throughPooler := psc.throughPooler(databaseID)
+
+	// Connecting can take up to the ping timeout, so it happens outside the
+	// lock to let databases register concurrently
+	db, err := sql.Open(driverName, dsn)
 	if err != nil {
 		return fmt.Errorf("failed to connect to database: %w", err)
 	}
 
-	db.SetMaxOpenConns(2)
-	db.SetMaxIdleConns(1)
+	pool.apply(db)
 	db.SetConnMaxLifetime(5 * time.Minute)
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -184,16 +291,61 @@ func (psc *PostgresStatsCollector) RegisterDatabase(databaseID, dsn string) erro
 		return fmt.Errorf("failed to ping database: %w", err)
 	}
 
-	psc.databases[databaseID] = &DBConnection{
+	psc.mu.Lock()
+	defer psc.mu.Unlock()
+
+	// Another registration of the same database may have connected first
+	if psc.connectedTo(databaseID, engine, dsn) {
+		db.Close()
+		return nil
+	}
+
+	previous, registered := psc.databases[databaseID]
+	if registered && previous.DB != nil {
+		previous.DB.Close()
+	}
+	dbConn := &DBConnection{
 		DSN:        dsn,
 		DB:         db,
 		DatabaseID: databaseID,
+		Engine:     engine,
+
+		throughPooler: throughPooler,
+	}
+	if registered {
+		// The database's size history carries over to its new connection
+		dbConn.sizes = previous.sizes
 	}
+	psc.databases[databaseID] = dbConn
 
-	psc.logger.Info("registered database for stats collection", zap.String("database_id", databaseID))
+	psc.logger.Info("registered database for stats collection",
+		zap.String("database_id", databaseID),
+		zap.String("engine", engine))
 	return nil
 }
 
+// connectedTo reports whether databaseID is registered with a connection to
+// dsn on engine. The caller must hold psc.mu.
+func (psc *PostgresStatsCollector) connectedTo(databaseID, engine, dsn string) bool {
+	conn, ok := psc.databases[databaseID]
+	return ok && conn.DB != nil && conn.Engine == engine && conn.DSN == dsn
+}
+
+// SetPoolSize sets the connection pool kept to each database, with overrides
+// for individual databases by ID. Zero fields use DefaultPoolSize. Databases
+// already registered are resized straight away.
+func (psc *PostgresStatsCollector) SetPoolSize(size PoolSize, databases map[string]PoolSize) {
+	psc.mu.Lock()
+	defer psc.mu.Unlock()
+
+	psc.pools = poolSizes{size: size, shards: databases}
+	for databaseID, conn := range psc.databases {
+		if conn.DB != nil {
+			psc.pools.forShard(databaseID).apply(conn.DB)
+		}
+	}
+}
+
 // UnregisterDatabase removes a database from stats collection
 func (psc *PostgresStatsCollector) UnregisterDatabase(databaseID string) {
 	psc.mu.Lock()
@@ -208,12 +360,33 @@ func (psc *PostgresStatsCollector) UnregisterDatabase(databaseID string) {
 	}
 }
 
+// RegisteredDatabases returns the IDs of the databases registered for stats
+// collection, sorted
+func (psc *PostgresStatsCollector) RegisteredDatabases() []string {
+	psc.mu.RLock()
+	defer psc.mu.RUnlock()
+
+	databaseIDs := make([]string, 0, len(psc.databases))
+	for databaseID := range psc.databases {
+		databaseIDs = append(databaseIDs, databaseID)
+	}
+	sort.Strings(databaseIDs)
+	return databaseIDs
+}
+
+// Started is closed once the collection loop is running
+func (psc *PostgresStatsCollector) Started() <-chan struct{} {
+	return psc.started
+}
+
 // Start starts the stats collection loop
 func (psc *PostgresStatsCollector) Start(ctx context.Context) {
-	ticker := time.NewTicker(psc.interval)
+	interval := psc.CollectionInterval()
+	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
-	psc.logger.Info("PostgreSQL stats collector started", zap.Duration("interval", psc.interval))
+	psc.logger.Info("PostgreSQL stats collector started", zap.Duration("interval", interval))
+	psc.startOnce.Do(func() { close(psc.started) })
 
 	for {
 		select {
@@ -225,6 +398,8 @@ func (psc *PostgresStatsCollector) Start(ctx context.Context) {
 			return
 		case <-ticker.C:
 			psc.collectAll(ctx)
+		case <-psc.intervalChanged:
+			ticker.Reset(psc.CollectionInterval())
 		}
 	}
 }
@@ -253,21 +428,56 @@ func (psc *PostgresStatsCollector) collectAll(ctx context.Context) {
 	psc.mu.RUnlock()
 
 	for _, dbConn := range databases {
+		psc.checkConnection(ctx, dbConn)
+
 		stats, err := psc.CollectStats(ctx, dbConn)
 		if err != nil {
 			psc.logger.Warn("failed to collect stats",
 				zap.String("database_id", dbConn.DatabaseID),
 				zap.Error(err))
+			psc.mu.Lock()
 			dbConn.LastError = err
+			psc.mu.Unlock()
 			continue
 		}
 
-		dbConn.LastStats = stats
-		dbConn.LastCollect = time.Now()
-		dbConn.LastError = nil
+		psc.record(dbConn, stats)
 	}
 }
 
+// record stores a successful collection, deriving rates from the growth of the
+// cumulative counters since the previous one
+func (psc *PostgresStatsCollector) record(dbConn *DBConnection, stats *PostgresStats) {
+	psc.mu.Lock()
+	defer psc.mu.Unlock()
+
+	last := dbConn.LastStats
+	switch {
+	case last == nil:
+	case last.CollectedAt.Before(dbConn.resetAt):
+		// The previous collection counted from before the reset, so this one
+		// only becomes the new baseline
+	case stats.Queries.TotalQueries < last.Queries.TotalQueries:
+		psc.logger.Info("query counters went backwards, re-baselining stats",
+			zap.String("database_id", dbConn.DatabaseID),
+			zap.Int64("previous_total", last.Queries.TotalQueries),
+			zap.Int64("total", stats.Queries.TotalQueries))
+	default:
+		if elapsed := stats.CollectedAt.Sub(last.CollectedAt).Seconds(); elapsed > 0 {
+			stats.Queries.QueriesPerSecond = float64(stats.Queries.TotalQueries-last.Queries.TotalQueries) / elapsed
+		}
+	}
+
+	if dbConn.sizes == nil {
+		dbConn.sizes = newSizeHistory()
+	}
+	dbConn.sizes.add(stats, psc.sizeRetention)
+
+	dbConn.LastStats = stats
+	dbConn.LastCollect = time.Now()
+	dbConn.LastError = nil
+}
+
 // CollectStats collects comprehensive statistics from a database
 func (psc *PostgresStatsCollector) CollectStats(ctx context.Context, dbConn *DBConnection) (*PostgresStats, error) {
 	if dbConn.DB == nil {
@@ -275,15 +485,24 @@ func (psc *PostgresStatsCollector) CollectStats(ctx context.Context, dbConn *DBC
 	}
 
 	stats := &PostgresStats{
-		DatabaseID:  dbConn.DatabaseID,
-		CollectedAt: time.Now(),
+		DatabaseID:    dbConn.DatabaseID,
+		CollectedAt:   time.Now(),
+		ThroughPooler: dbConn.throughPooler,
+	}
+
+	if isMySQL(dbConn.Engine) {
+		psc.collectMySQLStats(ctx, dbConn.DB, stats)
+		return stats, nil
 	}
 
 	if err := psc.collectDatabaseInfo(ctx, dbConn.DB, stats); err != nil {
 		psc.logger.Warn("failed to collect database info", zap.Error(err))
 	}
-	if err := psc.collectConnectionStats(ctx, dbConn.DB, stats); err != nil {
-		psc.logger.Warn("failed to collect connection stats", zap.Error(err))
+	// Through a pooler, pg_stat_activity lists the pooler's connections rather than its clients
+	if !stats.ThroughPooler {
+		if err := psc.collectConnectionStats(ctx, dbConn.DB, stats); err != nil {
+			psc.logger.Warn("failed to collect connection stats", zap.Error(err))
+		}
 	}
 	if err := psc.collectQueryStats(ctx, dbConn.DB, stats); err != nil {
 		psc.logger.Warn("failed to collect query stats", zap.Error(err))
@@ -351,6 +570,59 @@ func (psc *PostgresStatsCollector) collectConnectionStats(ctx context.Context, d
 		}
 	}
 
+	return collectConnectionSources(ctx, db, &stats.Connections)
+}
+
+// collectConnectionSources groups client connections by application name and
+// client address. Connections over a Unix socket have no address and are
+// reported as local.
+func collectConnectionSources(ctx context.Context, db *sql.DB, connections *ConnectionStats) error {
+	query := `
+		SELECT COALESCE(application_name, ''), COALESCE(host(client_addr), ''), count(*)
+		FROM pg_stat_activity
+		WHERE backend_type = 'client backend'
+		GROUP BY 1, 2`
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return fmt.Errorf("failed to query connection sources: %w", err)
+	}
+	defer rows.Close()
+
+	connections.ByApplication = make(map[string]int)
+	connections.ByClientAddr = make(map[string]int)
+	var sources []ConnectionSource
+	for rows.Next() {
+		var source ConnectionSource
+		if err := rows.Scan(&source.Application, &source.ClientAddr, &source.Count); err != nil {
+			return fmt.Errorf("failed to scan connection source: %w", err)
+		}
+		if source.Application == "" {
+			source.Application = "unknown"
+		}
+		if source.ClientAddr == "" {
+			source.ClientAddr = "local"
+		}
+		connections.ByApplication[source.Application] += source.Count
+		connections.ByClientAddr[source.ClientAddr] += source.Count
+		sources = append(sources, source)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	sort.Slice(sources, func(i, j int) bool {
+		if sources[i].Count != sources[j].Count {
+			return sources[i].Count > sources[j].Count
+		}
+		if sources[i].Application != sources[j].Application {
+			return sources[i].Application < sources[j].Application
+		}
+		return sources[i].ClientAddr < sources[j].ClientAddr
+	})
+	if len(sources) > maxConnectionSources {
+		sources = sources[:maxConnectionSources]
+	}
+	connections.TopSources = sources
 	return nil
 }
 
@@ -363,6 +635,19 @@ func (psc *PostgresStatsCollector) collectQueryStats(ctx context.Context, db *sq
 	if cacheHitRatio.Valid {
 		stats.Queries.CacheHitRatio = cacheHitRatio.Float64 * 100
 	}
+
+	threshold := psc.SlowQueryThreshold()
+	// A failed pg_stat_activity read leaves the other query stats intact
+	if slowQueries, err := listSlowQueries(ctx, db, threshold); err != nil {
+		psc.logger.Warn("failed to list slow queries", zap.Error(err))
+	} else {
+		stats.Queries.SlowQueries = int64(len(slowQueries))
+	}
+
+	// pg_stat_statements is optional
+	if count, err := countSlowStatements(ctx, db, threshold); err == nil {
+		stats.Queries.SlowStatements = count
+	}
 	return nil
 }
 
@@ -406,7 +691,21 @@ func (psc *PostgresStatsCollector) collectTableStats(ctx context.Context, db *sq
 	if stats.Tables.SeqScans+stats.Tables.IndexScans > 0 {
 		stats.Tables.SeqScanRatio = float64(stats.Tables.SeqScans) / float64(stats.Tables.SeqScans+stats.Tables.IndexScans) * 100
 	}
-	return nil
+
+	largestQuery := `SELECT schemaname, relname, n_live_tup, pg_total_relation_size(relid), seq_scan, COALESCE(idx_scan, 0) FROM pg_stat_user_tables ORDER BY pg_total_relation_size(relid) DESC, schemaname, relname LIMIT $1`
+	rows, err := db.QueryContext(ctx, largestQuery, maxLargestTables)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var table TableInfo
+		if err := rows.Scan(&table.Schema, &table.TableName, &table.Rows, &table.Size, &table.SeqScans, &table.IdxScans); err != nil {
+			return err
+		}
+		stats.Tables.LargestTables = append(stats.Tables.LargestTables, table)
+	}
+	return rows.Err()
 }
 
 func (psc *PostgresStatsCollector) collectIndexStats(ctx context.Context, db *sql.DB, stats *PostgresStats) error {
@@ -454,6 +753,90 @@ func (psc *PostgresStatsCollector) GetStats(databaseID string) (*PostgresStats,
 	return dbConn.LastStats, nil
 }
 
+// database returns the connection of a registered PostgreSQL database. The
+// operations that use it rely on PostgreSQL's catalogs and functions.
+func (psc *PostgresStatsCollector) database(databaseID string) (*sql.DB, error) {
+	psc.mu.RLock()
+	dbConn, ok := psc.databases[databaseID]
+	psc.mu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrDatabaseNotRegistered, databaseID)
+	}
+	if isMySQL(dbConn.Engine) {
+		return nil, fmt.Errorf("%w: %s runs on %s", ErrUnsupportedEngine, databaseID, dbConn.Engine)
+	}
+	if dbConn.DB == nil {
+		return nil, fmt.Errorf("database connection not available")
+	}
+	return dbConn.DB, nil
+}
+
+// ResetStats resets the statistics counters of a registered database with
+// pg_stat_reset() and re-baselines delta tracking, so the drop in the counters
+// is not reported as activity
+func (psc *PostgresStatsCollector) ResetStats(ctx context.Context, databaseID string) error {
+	db, err := psc.database(databaseID)
+	if err != nil {
+		return err
+	}
+	if _, err := db.ExecContext(ctx, "SELECT pg_stat_reset()"); err != nil {
+		return fmt.Errorf("failed to reset statistics: %w", err)
+	}
+	psc.Rebaseline(databaseID)
+
+	psc.logger.Info("reset database statistics", zap.String("database_id", databaseID))
+	return nil
+}
+
+// Rebaseline signals that a database's statistics were reset, so the next
+// collection becomes a new baseline instead of being compared with the last
+func (psc *PostgresStatsCollector) Rebaseline(databaseID string) {
+	psc.mu.Lock()
+	defer psc.mu.Unlock()
+	if dbConn, ok := psc.databases[databaseID]; ok {
+		dbConn.resetAt = time.Now()
+	}
+}
+
+// GetSlowQueries lists the queries currently running longer than threshold on
+// a registered database. A zero threshold uses the collector's threshold.
+func (psc *PostgresStatsCollector) GetSlowQueries(ctx context.Context, databaseID string, threshold time.Duration) ([]SlowQuery, error) {
+	if threshold == 0 {
+		threshold = psc.SlowQueryThreshold()
+	}
+	db, err := psc.database(databaseID)
+	if err != nil {
+		return nil, err
+	}
+	return listSlowQueries(ctx, db, threshold)
+}
+
+// GetIndexRecommendations suggests indexes for the seq-scan hotspots of a
+// registered database. Frequent filter columns come from pg_stat_statements
+// when it is installed.
+func (psc *PostgresStatsCollector) GetIndexRecommendations(ctx context.Context, databaseID string, opts IndexAdvisorOptions) ([]IndexRecommendation, error) {
+	db, err := psc.database(databaseID)
+	if err != nil {
+		return nil, err
+	}
+
+	opts = opts.withDefaults()
+	tables, err := loadTableScanStats(ctx, db, opts.MinRows)
+	if err != nil {
+		return nil, err
+	}
+
+	statements, err := loadFrequentStatements(ctx, db)
+	if err != nil {
+		psc.logger.Debug("pg_stat_statements unavailable, recommending without filter columns",
+			zap.String("database_id", databaseID),
+			zap.Error(err))
+	}
+
+	return RecommendIndexes(tables, statements, opts), nil
+}
+
 // GetAllStats returns stats for all registered databases
 func (psc *PostgresStatsCollector) GetAllStats() map[string]*PostgresStats {
 	psc.mu.RLock()
@@ -468,3 +851,75 @@ func (psc *PostgresStatsCollector) GetAllStats() map[string]*PostgresStats {
 	return result
 }
 
+// ReplicaLag returns the replay lag last measured on the replica registered
+// with the given DSN. It reports false when the DSN isn't a replica or its
+// stats are missing, failed or older than three collection intervals, since
+// the lag can't be trusted then.
+func (psc *PostgresStatsCollector) ReplicaLag(dsn string) (time.Duration, bool) {
+	psc.mu.RLock()
+	defer psc.mu.RUnlock()
+
+	for _, dbConn := range psc.databases {
+		if dbConn.DSN != dsn {
+			continue
+		}
+		if dbConn.LastStats == nil || dbConn.LastError != nil || !dbConn.LastStats.Replication.IsReplica {
+			return 0, false
+		}
+		if time.Since(dbConn.LastCollect) > 3*psc.interval {
+			return 0, false
+		}
+		return time.Duration(dbConn.LastStats.Replication.ReplicationLag * float64(time.Second)), true
+	}
+	return 0, false
+}
+
+// StatsSnapshot is a point-in-time export of the latest stats for every
+// registered database, meant for offline analysis such as capacity reviews
+type StatsSnapshot struct {
+	GeneratedAt time.Time          `json:"generated_at"`
+	Databases   []DatabaseSnapshot `json:"databases"`
+}
+
+// DatabaseSnapshot holds the latest stats for one database. Stats is nil when
+// nothing has been collected successfully yet; Error then explains why.
+type DatabaseSnapshot struct {
+	DatabaseID  string         `json:"database_id"`
+	CollectedAt *time.Time     `json:"collected_at,omitempty"`
+	Stats       *PostgresStats `json:"stats,omitempty"`
+	Error       string         `json:"error,omitempty"`
+}
+
+// Snapshot returns the latest stats of every registered database, ordered by
+// database ID. Databases whose last collection failed are included with the
+// error alongside any older stats.
+func (psc *PostgresStatsCollector) Snapshot() *StatsSnapshot {
+	psc.mu.RLock()
+	defer psc.mu.RUnlock()
+
+	snapshot := &StatsSnapshot{
+		GeneratedAt: time.Now(),
+		Databases:   make([]DatabaseSnapshot, 0, len(psc.databases)),
+	}
+	for id, dbConn := range psc.databases {
+		entry := DatabaseSnapshot{
+			DatabaseID: id,
+			Stats:      dbConn.LastStats,
+		}
+		if !dbConn.LastCollect.IsZero() {
+			collectedAt := dbConn.LastCollect
+			entry.CollectedAt = &collectedAt
+		}
+		switch {
+		case dbConn.LastError != nil:
+			entry.Error = dbConn.LastError.Error()
+		case dbConn.LastStats == nil:
+			entry.Error = "no stats collected yet"
+		}
+		snapshot.Databases = append(snapshot.Databases, entry)
+	}
+	sort.Slice(snapshot.Databases, func(i, j int) bool {
+		return snapshot.Databases[i].DatabaseID < snapshot.Databases[j].DatabaseID
+	})
+	return snapshot
+}
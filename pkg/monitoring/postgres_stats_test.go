@@ -0,0 +1,336 @@
+package monitoring
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zaptest"
+)
+
+func TestPostgresStatsCollector_SnapshotIncludesEveryDatabase(t *testing.T) {
+	psc := NewPostgresStatsCollector(zaptest.NewLogger(t), time.Minute)
+	collected := time.Now().Add(-time.Minute)
+
+	psc.databases["shard-b"] = &DBConnection{
+		DatabaseID:  "shard-b",
+		LastStats:   &PostgresStats{DatabaseID: "shard-b", DatabaseName: "orders", CollectedAt: collected},
+		LastCollect: collected,
+	}
+	psc.databases["shard-a"] = &DBConnection{
+		DatabaseID: "shard-a",
+		LastError:  errors.New("connection refused"),
+	}
+	psc.databases["shard-c"] = &DBConnection{DatabaseID: "shard-c"}
+
+	snapshot := psc.Snapshot()
+	if len(snapshot.Databases) != 3 {
+		t.Fatalf("expected 3 databases in snapshot, got %d", len(snapshot.Databases))
+	}
+
+	a, b, c := snapshot.Databases[0], snapshot.Databases[1], snapshot.Databases[2]
+	if a.DatabaseID != "shard-a" || b.DatabaseID != "shard-b" || c.DatabaseID != "shard-c" {
+		t.Fatalf("expected databases ordered by ID, got %s, %s, %s", a.DatabaseID, b.DatabaseID, c.DatabaseID)
+	}
+
+	if a.Stats != nil || a.Error != "connection refused" || a.CollectedAt != nil {
+		t.Errorf("expected shard-a to carry only its error, got %+v", a)
+	}
+	if b.Stats == nil || b.Stats.DatabaseName != "orders" || b.Error != "" {
+		t.Errorf("expected shard-b stats, got %+v", b)
+	}
+	if b.CollectedAt == nil || !b.CollectedAt.Equal(collected) {
+		t.Errorf("expected shard-b collection timestamp %v, got %v", collected, b.CollectedAt)
+	}
+	if c.Stats != nil || c.Error == "" {
+		t.Errorf("expected shard-c to report that nothing was collected, got %+v", c)
+	}
+}
+
+func TestPostgresStatsCollector_SnapshotKeepsStaleStatsWithError(t *testing.T) {
+	psc := NewPostgresStatsCollector(zaptest.NewLogger(t), time.Minute)
+	psc.databases["shard-a"] = &DBConnection{
+		DatabaseID:  "shard-a",
+		LastStats:   &PostgresStats{DatabaseID: "shard-a"},
+		LastCollect: time.Now(),
+		LastError:   errors.New("timeout"),
+	}
+
+	entry := psc.Snapshot().Databases[0]
+	if entry.Stats == nil || entry.Error != "timeout" {
+		t.Errorf("expected last good stats alongside the latest error, got %+v", entry)
+	}
+}
+
+func TestPostgresStatsCollector_ReplicaLag(t *testing.T) {
+	psc := NewPostgresStatsCollector(zaptest.NewLogger(t), time.Minute)
+	psc.databases["replica-a"] = &DBConnection{
+		DSN:         "postgres://replica-a",
+		LastStats:   &PostgresStats{Replication: ReplicationStats{IsReplica: true, ReplicationLag: 1.5}},
+		LastCollect: time.Now(),
+	}
+	psc.databases["primary"] = &DBConnection{
+		DSN:         "postgres://primary",
+		LastStats:   &PostgresStats{},
+		LastCollect: time.Now(),
+	}
+	psc.databases["replica-stale"] = &DBConnection{
+		DSN:         "postgres://replica-stale",
+		LastStats:   &PostgresStats{Replication: ReplicationStats{IsReplica: true}},
+		LastCollect: time.Now().Add(-time.Hour),
+	}
+
+	if lag, ok := psc.ReplicaLag("postgres://replica-a"); !ok || lag != 1500*time.Millisecond {
+		t.Errorf("expected 1.5s lag, got %v (%v)", lag, ok)
+	}
+	for _, dsn := range []string{"postgres://primary", "postgres://replica-stale", "postgres://unknown"} {
+		if _, ok := psc.ReplicaLag(dsn); ok {
+			t.Errorf("expected no usable lag for %s", dsn)
+		}
+	}
+}
+
+// queriesAt builds a collection of total queries at a point in time
+func queriesAt(total int64, at time.Time) *PostgresStats {
+	return &PostgresStats{DatabaseID: "shard1", CollectedAt: at, Queries: QueryStats{TotalQueries: total}}
+}
+
+func TestPostgresStatsCollector_RecordDerivesQueriesPerSecond(t *testing.T) {
+	psc := NewPostgresStatsCollector(zaptest.NewLogger(t), time.Minute)
+	dbConn := &DBConnection{DatabaseID: "shard1"}
+	psc.databases["shard1"] = dbConn
+	start := time.Now()
+
+	first := queriesAt(1000, start)
+	psc.record(dbConn, first)
+	if first.Queries.QueriesPerSecond != 0 {
+		t.Errorf("expected the first collection to be a baseline, got %v qps", first.Queries.QueriesPerSecond)
+	}
+
+	second := queriesAt(1600, start.Add(10*time.Second))
+	psc.record(dbConn, second)
+	if second.Queries.QueriesPerSecond != 60 {
+		t.Errorf("expected 60 qps, got %v", second.Queries.QueriesPerSecond)
+	}
+
+	// Counters reset outside the collector are detected when they go backwards
+	third := queriesAt(50, start.Add(20*time.Second))
+	psc.record(dbConn, third)
+	if third.Queries.QueriesPerSecond != 0 {
+		t.Errorf("expected a backwards counter to re-baseline, got %v qps", third.Queries.QueriesPerSecond)
+	}
+	fourth := queriesAt(250, start.Add(30*time.Second))
+	psc.record(dbConn, fourth)
+	if fourth.Queries.QueriesPerSecond != 20 {
+		t.Errorf("expected 20 qps after re-baselining, got %v", fourth.Queries.QueriesPerSecond)
+	}
+}
+
+func TestPostgresStatsCollector_ResetStatsRebaselines(t *testing.T) {
+	db := testStatsDriver.open(t)
+	psc := NewPostgresStatsCollector(zaptest.NewLogger(t), time.Minute)
+	dbConn := &DBConnection{DatabaseID: "shard1", DB: db}
+	psc.databases["shard1"] = dbConn
+
+	before := time.Now().Add(-time.Minute)
+	psc.record(dbConn, queriesAt(100, before))
+
+	if err := psc.ResetStats(context.Background(), "shard1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	executed := testStatsDriver.statements(t.Name())
+	if len(executed) != 1 || executed[0] != "SELECT pg_stat_reset()" {
+		t.Errorf("expected pg_stat_reset() to be called, got %v", executed)
+	}
+
+	// The counters restarted from zero and have since grown past the old
+	// total, which would otherwise read as a small positive delta
+	after := time.Now()
+	next := queriesAt(5000, after)
+	psc.record(dbConn, next)
+	if next.Queries.QueriesPerSecond != 0 {
+		t.Errorf("expected the first collection after a reset to be a baseline, got %v qps", next.Queries.QueriesPerSecond)
+	}
+
+	following := queriesAt(5500, after.Add(5*time.Second))
+	psc.record(dbConn, following)
+	if following.Queries.QueriesPerSecond != 100 {
+		t.Errorf("expected 100 qps once re-baselined, got %v", following.Queries.QueriesPerSecond)
+	}
+}
+
+func TestPostgresStatsCollector_RebaselineSkipsCollectionsStartedBeforeReset(t *testing.T) {
+	psc := NewPostgresStatsCollector(zaptest.NewLogger(t), time.Minute)
+	dbConn := &DBConnection{DatabaseID: "shard1"}
+	psc.databases["shard1"] = dbConn
+
+	start := time.Now().Add(-time.Minute)
+	psc.record(dbConn, queriesAt(100, start))
+	psc.Rebaseline("shard1")
+
+	// A collection in flight during the reset read the old counters
+	inFlight := queriesAt(200, start.Add(10*time.Second))
+	psc.record(dbConn, inFlight)
+	next := queriesAt(30000, time.Now())
+	psc.record(dbConn, next)
+	if inFlight.Queries.QueriesPerSecond != 0 || next.Queries.QueriesPerSecond != 0 {
+		t.Errorf("expected no rate across the reset, got %v and %v qps",
+			inFlight.Queries.QueriesPerSecond, next.Queries.QueriesPerSecond)
+	}
+}
+
+func TestPostgresStatsCollector_ResetStatsUnknownDatabase(t *testing.T) {
+	psc := NewPostgresStatsCollector(zaptest.NewLogger(t), time.Minute)
+	if err := psc.ResetStats(context.Background(), "missing"); !errors.Is(err, ErrDatabaseNotRegistered) {
+		t.Errorf("expected ErrDatabaseNotRegistered, got %v", err)
+	}
+}
+
+func TestPostgresStatsCollector_CollectConnectionStatsGroupsSources(t *testing.T) {
+	db := testStatsDriver.open(t,
+		fakeResult{match: "GROUP BY state", columns: []string{"state", "count"}, rows: [][]driver.Value{
+			{"active", int64(7)}, {"idle", int64(13)},
+		}},
+		fakeResult{match: "max_connections", columns: []string{"setting"}, rows: [][]driver.Value{{int64(100)}}},
+		fakeResult{match: "host(client_addr)", columns: []string{"application_name", "client_addr", "count"}, rows: [][]driver.Value{
+			{"billing", "10.0.0.5", int64(3)},
+			{"orders-api", "10.0.0.7", int64(9)},
+			{"orders-api", "10.0.0.8", int64(5)},
+			{"", "10.0.0.5", int64(1)},
+			{"psql", "", int64(2)},
+		}},
+	)
+	psc := NewPostgresStatsCollector(zaptest.NewLogger(t), time.Minute)
+
+	stats := &PostgresStats{}
+	if err := psc.collectConnectionStats(context.Background(), db, stats); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	connections := stats.Connections
+
+	if connections.Total != 20 || connections.PercentUsed != 20 {
+		t.Errorf("expected 20 connections, 20%% used, got %d, %v%%", connections.Total, connections.PercentUsed)
+	}
+
+	wantApps := map[string]int{"orders-api": 14, "billing": 3, "psql": 2, "unknown": 1}
+	if len(connections.ByApplication) != len(wantApps) {
+		t.Errorf("expected %v by application, got %v", wantApps, connections.ByApplication)
+	}
+	for app, count := range wantApps {
+		if connections.ByApplication[app] != count {
+			t.Errorf("expected %d connections from %s, got %d", count, app, connections.ByApplication[app])
+		}
+	}
+	if connections.ByClientAddr["10.0.0.5"] != 4 || connections.ByClientAddr["local"] != 2 {
+		t.Errorf("unexpected connections by client address: %v", connections.ByClientAddr)
+	}
+
+	if len(connections.TopSources) != 5 {
+		t.Fatalf("expected 5 connection sources, got %d", len(connections.TopSources))
+	}
+	top := connections.TopSources[0]
+	if top.Application != "orders-api" || top.ClientAddr != "10.0.0.7" || top.Count != 9 {
+		t.Errorf("expected orders-api from 10.0.0.7 to be the top source, got %+v", top)
+	}
+	for i := 1; i < len(connections.TopSources); i++ {
+		if connections.TopSources[i].Count > connections.TopSources[i-1].Count {
+			t.Errorf("expected sources ordered by connection count, got %+v", connections.TopSources)
+		}
+	}
+}
+
+func TestCollectConnectionSources_CapsTopSources(t *testing.T) {
+	rows := make([][]driver.Value, 0, maxConnectionSources+5)
+	for i := 0; i < maxConnectionSources+5; i++ {
+		rows = append(rows, []driver.Value{"worker", fmt.Sprintf("10.0.1.%d", i), int64(i + 1)})
+	}
+	db := testStatsDriver.open(t, fakeResult{match: "host(client_addr)", columns: []string{"application_name", "client_addr", "count"}, rows: rows})
+
+	var connections ConnectionStats
+	if err := collectConnectionSources(context.Background(), db, &connections); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(connections.TopSources) != maxConnectionSources {
+		t.Fatalf("expected %d sources, got %d", maxConnectionSources, len(connections.TopSources))
+	}
+	if connections.TopSources[0].Count != maxConnectionSources+5 {
+		t.Errorf("expected the busiest source first, got %+v", connections.TopSources[0])
+	}
+	if len(connections.ByClientAddr) != maxConnectionSources+5 || connections.ByApplication["worker"] != 120 {
+		t.Errorf("expected every source in the groupings, got %d addresses and %d worker connections",
+			len(connections.ByClientAddr), connections.ByApplication["worker"])
+	}
+}
+
+func TestPostgresStatsCollector_RegisterDatabaseDoesNotWaitForSlowDatabases(t *testing.T) {
+	psc := NewPostgresStatsCollector(zaptest.NewLogger(t), time.Minute)
+	psc.driver = "monitoringtest"
+	defer psc.UnregisterDatabase("slow")
+	defer psc.UnregisterDatabase("fast")
+
+	release := testStatsDriver.stall(t.Name() + "-slow")
+	slow := make(chan error, 1)
+	go func() { slow <- psc.RegisterDatabase("slow", t.Name()+"-slow") }()
+
+	fast := make(chan error, 1)
+	go func() { fast <- psc.RegisterDatabase("fast", t.Name()+"-fast") }()
+	select {
+	case err := <-fast:
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	case <-time.After(time.Second):
+		release()
+		t.Fatal("expected a database to register while another is still connecting")
+	}
+
+	release()
+	if err := <-slow; err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(psc.databases) != 2 {
+		t.Errorf("expected both databases registered, got %d", len(psc.databases))
+	}
+}
+
+func TestPostgresStatsCollector_RegisterDatabaseAgain(t *testing.T) {
+	psc := NewPostgresStatsCollector(zaptest.NewLogger(t), time.Minute)
+	psc.driver = "monitoringtest"
+	defer psc.UnregisterDatabase("db1")
+
+	if err := psc.RegisterDatabase("db1", t.Name()+"-old"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	first := psc.databases["db1"].DB
+
+	if err := psc.RegisterDatabase("db1", t.Name()+"-old"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if psc.databases["db1"].DB != first {
+		t.Fatal("expected registering the same DSN again to keep the connection")
+	}
+	if err := first.Ping(); err != nil {
+		t.Fatalf("expected the connection to stay open, got %v", err)
+	}
+
+	// A new DSN that cannot be reached leaves the old connection in place
+	testStatsDriver.setDown(t.Name()+"-down", true)
+	defer testStatsDriver.setDown(t.Name()+"-down", false)
+	if err := psc.RegisterDatabase("db1", t.Name()+"-down"); err == nil {
+		t.Fatal("expected registering an unreachable DSN to fail")
+	}
+	if psc.databases["db1"].DB != first {
+		t.Fatal("expected a failed registration to keep the old connection")
+	}
+
+	if err := psc.RegisterDatabase("db1", t.Name()+"-new"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(psc.databases) != 1 || psc.databases["db1"].DSN != t.Name()+"-new" {
+		t.Fatalf("expected one database on the new DSN, got %d", len(psc.databases))
+	}
+	assertClosed(t, first, "the connection to the old DSN")
+}
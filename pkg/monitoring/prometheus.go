@@ -4,12 +4,15 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"math"
 	"net/http"
+	"sort"
 	"sync"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/sharding-system/pkg/models"
 	"go.uber.org/zap"
 )
 
@@ -20,6 +23,18 @@ type PrometheusCollector struct {
 	collectors         map[string]*ShardCollector
 	mu                 sync.RWMutex
 	collectionInterval time.Duration
+	intervalChanged    chan struct{} // Wakes Start to pick up a new collection interval
+	started            chan struct{} // Closed once Start runs
+	startOnce          sync.Once
+	slowQueryThreshold time.Duration
+	buckets            HistogramBuckets
+
+	// Failover handling: after reconnectAfter failed connection checks a
+	// shard's DSN is resolved again
+	resolver       EndpointResolver
+	reconnectAfter int
+	driver         string // database/sql driver connections are opened with; empty uses the engine's
+	pools          poolSizes
 
 	// Metrics
 	shardQueryTotal     *prometheus.CounterVec
@@ -30,12 +45,19 @@ type PrometheusCollector struct {
 	shardMemoryUsage    *prometheus.GaugeVec
 	shardDiskUsage      *prometheus.GaugeVec
 	shardErrorRate      *prometheus.GaugeVec
+	shardSlowQueries    *prometheus.GaugeVec
+	shardBlockedQueries *prometheus.GaugeVec
 	clusterHealth       *prometheus.GaugeVec
 	routerLatency       *prometheus.HistogramVec
 	routerThroughput    *prometheus.CounterVec
 	catalogUpdates      prometheus.Counter
 	failoverEvents      *prometheus.CounterVec
 	reshardingProgress  *prometheus.GaugeVec
+
+	// Client app storage quota metrics
+	clientAppStorage      *prometheus.GaugeVec
+	clientAppStorageQuota *prometheus.GaugeVec
+	clientAppOverQuota    *prometheus.GaugeVec
 	
 	// PostgreSQL statistics metrics
 	postgresDatabaseSize      *prometheus.GaugeVec
@@ -47,16 +69,28 @@ type PrometheusCollector struct {
 	postgresCacheHitRatio     *prometheus.GaugeVec
 	postgresDeadTuples        *prometheus.GaugeVec
 	postgresDatabaseUptime     *prometheus.GaugeVec
+
+	// Tables with their own postgres_table_rows series, per database label
+	// set, capped at maxTableSeries
+	maxTableSeries int
+	tableSeries    map[string]map[string]bool
 }
 
 // ShardCollector collects metrics for a specific shard
 type ShardCollector struct {
 	shardID     string
 	dsn         string
+	engine      string
 	logger      *zap.Logger
 	db          *sql.DB
 	lastMetrics *ShardDetailedMetrics
 	mu          sync.RWMutex
+
+	slowQueryThreshold time.Duration
+	throughPooler      bool // The shard is reached through a connection pooler; see PoolerResolver
+
+	// failures counts consecutive failed connection checks
+	failures int
 }
 
 // ShardDetailedMetrics contains detailed metrics for a shard
@@ -72,6 +106,8 @@ type ShardDetailedMetrics struct {
 	QueriesPerSecond float64
 	AvgQueryTime     float64
 	SlowQueries      int64
+	SlowStatements   int64
+	BlockedQueries   int64
 
 	// Replication metrics
 	ReplicationLag   float64
@@ -97,11 +133,72 @@ type ShardDetailedMetrics struct {
 	TransactionsRollback int64
 	Deadlocks            int64
 
+	// ThroughPooler is set when the shard was collected through a connection
+	// pooler, which leaves the connection metrics out
+	ThroughPooler bool
+
 	CollectedAt time.Time
 }
 
+// Default histogram buckets of the latency metrics, in seconds
+var (
+	DefaultQueryDurationBuckets = []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1.0, 2.5, 5.0, 10.0}
+	DefaultRouterLatencyBuckets = []float64{0.0001, 0.0005, 0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1.0}
+)
+
+// HistogramBuckets sets the upper bounds, in seconds, of the latency
+// histograms' buckets. Empty buckets keep the defaults.
+type HistogramBuckets struct {
+	QueryDuration []float64
+	RouterLatency []float64
+}
+
+// Validate checks that every configured set of buckets is usable
+func (b HistogramBuckets) Validate() error {
+	if err := ValidateBuckets(b.QueryDuration); err != nil {
+		return fmt.Errorf("invalid query duration buckets: %w", err)
+	}
+	if err := ValidateBuckets(b.RouterLatency); err != nil {
+		return fmt.Errorf("invalid router latency buckets: %w", err)
+	}
+	return nil
+}
+
+// ValidateBuckets checks that latency buckets are positive and strictly
+// increasing
+func ValidateBuckets(buckets []float64) error {
+	for i, bound := range buckets {
+		if math.IsNaN(bound) || bound <= 0 {
+			return fmt.Errorf("bucket %v must be a positive number of seconds", bound)
+		}
+		if i > 0 && bound <= buckets[i-1] {
+			return fmt.Errorf("buckets must be strictly increasing, %v follows %v", bound, buckets[i-1])
+		}
+	}
+	return nil
+}
+
+// orDefault returns a copy of buckets, or of defaults when buckets is empty
+func orDefault(buckets, defaults []float64) []float64 {
+	if len(buckets) == 0 {
+		buckets = defaults
+	}
+	return append([]float64(nil), buckets...)
+}
+
 // NewPrometheusCollector creates a new Prometheus collector
 func NewPrometheusCollector(logger *zap.Logger, collectionInterval time.Duration) *PrometheusCollector {
+	pc, _ := NewPrometheusCollectorWithBuckets(logger, collectionInterval, HistogramBuckets{})
+	return pc
+}
+
+// NewPrometheusCollectorWithBuckets creates a new Prometheus collector whose
+// latency histograms use the given buckets
+func NewPrometheusCollectorWithBuckets(logger *zap.Logger, collectionInterval time.Duration, buckets HistogramBuckets) (*PrometheusCollector, error) {
+	if err := buckets.Validate(); err != nil {
+		return nil, err
+	}
+
 	registry := prometheus.NewRegistry()
 	registry.MustRegister(prometheus.NewGoCollector())
 	registry.MustRegister(prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}))
@@ -111,12 +208,22 @@ func NewPrometheusCollector(logger *zap.Logger, collectionInterval time.Duration
 		registry:           registry,
 		collectors:         make(map[string]*ShardCollector),
 		collectionInterval: collectionInterval,
+		intervalChanged:    make(chan struct{}, 1),
+		started:            make(chan struct{}),
+		slowQueryThreshold: DefaultSlowQueryThreshold,
+		reconnectAfter:     DefaultReconnectAfter,
+		maxTableSeries:     DefaultMaxTableSeries,
+		tableSeries:        make(map[string]map[string]bool),
+		buckets: HistogramBuckets{
+			QueryDuration: orDefault(buckets.QueryDuration, DefaultQueryDurationBuckets),
+			RouterLatency: orDefault(buckets.RouterLatency, DefaultRouterLatencyBuckets),
+		},
 	}
 
 	// Initialize metrics
 	pc.initMetrics()
 
-	return pc
+	return pc, nil
 }
 
 // initMetrics initializes all Prometheus metrics
@@ -133,7 +240,7 @@ func (pc *PrometheusCollector) initMetrics() {
 		prometheus.HistogramOpts{
 			Name:    "sharding_shard_query_duration_seconds",
 			Help:    "Duration of queries in seconds",
-			Buckets: []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1.0, 2.5, 5.0, 10.0},
+			Buckets: pc.buckets.QueryDuration,
 		},
 		[]string{"shard_id", "database", "operation"},
 	)
@@ -186,6 +293,22 @@ func (pc *PrometheusCollector) initMetrics() {
 		[]string{"shard_id", "database"},
 	)
 
+	pc.shardSlowQueries = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "sharding_shard_slow_queries",
+			Help: "Slow queries per shard: currently running (source=activity) or slow on average (source=statements)",
+		},
+		[]string{"shard_id", "database", "source"},
+	)
+
+	pc.shardBlockedQueries = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "sharding_shard_blocked_queries",
+			Help: "Sessions per shard waiting for a lock held by another session",
+		},
+		[]string{"shard_id", "database"},
+	)
+
 	pc.clusterHealth = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "sharding_cluster_health",
@@ -198,7 +321,7 @@ func (pc *PrometheusCollector) initMetrics() {
 		prometheus.HistogramOpts{
 			Name:    "sharding_router_latency_seconds",
 			Help:    "Router request latency in seconds",
-			Buckets: []float64{0.0001, 0.0005, 0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1.0},
+			Buckets: pc.buckets.RouterLatency,
 		},
 		[]string{"method", "path", "status"},
 	)
@@ -234,6 +357,30 @@ func (pc *PrometheusCollector) initMetrics() {
 		[]string{"job_id", "source_shard", "target_shard"},
 	)
 
+	pc.clientAppStorage = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "sharding_client_app_storage_bytes",
+			Help: "Database size across a client app's shards",
+		},
+		[]string{"client_app_id"},
+	)
+
+	pc.clientAppStorageQuota = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "sharding_client_app_storage_quota_bytes",
+			Help: "Storage quota of a client app's tier; absent when the tier sets no quota",
+		},
+		[]string{"client_app_id"},
+	)
+
+	pc.clientAppOverQuota = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "sharding_client_app_over_storage_quota",
+			Help: "Whether a client app stores more than its tier's quota (1 = over quota)",
+		},
+		[]string{"client_app_id"},
+	)
+
 	// PostgreSQL statistics metrics
 	pc.postgresDatabaseSize = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
@@ -317,12 +464,17 @@ func (pc *PrometheusCollector) initMetrics() {
 		pc.shardMemoryUsage,
 		pc.shardDiskUsage,
 		pc.shardErrorRate,
+		pc.shardSlowQueries,
+		pc.shardBlockedQueries,
 		pc.clusterHealth,
 		pc.routerLatency,
 		pc.routerThroughput,
 		pc.catalogUpdates,
 		pc.failoverEvents,
 		pc.reshardingProgress,
+		pc.clientAppStorage,
+		pc.clientAppStorageQuota,
+		pc.clientAppOverQuota,
 		pc.postgresDatabaseSize,
 		pc.postgresTableCount,
 		pc.postgresTableRows,
@@ -335,52 +487,165 @@ func (pc *PrometheusCollector) initMetrics() {
 	)
 }
 
-// RegisterShard registers a shard for metrics collection
+// RegisterShard registers a PostgreSQL shard for metrics collection
 func (pc *PrometheusCollector) RegisterShard(shardID, dsn string) error {
+	return pc.RegisterShardWithEngine(shardID, models.EnginePostgres, dsn)
+}
+
+// RegisterShardWithEngine registers a shard running on engine for metrics
+// collection. The engine decides the driver the DSN is opened with and the
+// queries metrics are collected with.
+func (pc *PrometheusCollector) RegisterShardWithEngine(shardID, engine, dsn string) error {
+	driverName, err := models.DriverName(engine)
+	if err != nil {
+		return err
+	}
+
+	engine = models.NormalizeEngine(engine)
+
 	pc.mu.Lock()
-	defer pc.mu.Unlock()
+	if pc.driver != "" {
+		driverName = pc.driver
+	}
+	pool := pc.pools.forShard(shardID)
+
+	// Startup and shard creation can both register a shard. Registering it
+	// again keeps its connection, unless the shard is now reached elsewhere.
+	if previous, registered := pc.collectors[shardID]; registered && previous.connectedTo(engine, dsn) {
+		pc.mu.Unlock()
+		pc.logger.Debug("shard already registered for metrics collection", zap.String("shard_id", shardID))
+		return nil
+	}
+	pc.mu.Unlock()
 
 	collector := &ShardCollector{
-		shardID: shardID,
-		dsn:     dsn,
-		logger:  pc.logger.With(zap.String("shard_id", shardID)),
+		shardID:       shardID,
+		dsn:           dsn,
+		engine:        engine,
+		logger:        pc.logger.With(zap.String("shard_id", shardID)),
+		throughPooler: pc.throughPooler(shardID),
 	}
 
-	// Try to establish database connection
-	db, err := sql.Open("postgres", dsn)
+	// Try to establish database connection, outside the lock so shards
+	// register concurrently
+	db, err := sql.Open(driverName, dsn)
 	if err != nil {
 		pc.logger.Warn("failed to connect to shard for metrics", zap.String("shard_id", shardID), zap.Error(err))
 	} else {
 		collector.db = db
-		db.SetMaxOpenConns(2)
-		db.SetMaxIdleConns(1)
+		pool.apply(db)
 	}
 
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+
+	// Another registration of the same shard may have connected first
+	previous, registered := pc.collectors[shardID]
+	if registered && previous.connectedTo(engine, dsn) {
+		collector.close()
+		return nil
+	}
+	collector.slowQueryThreshold = pc.slowQueryThreshold
+
+	if registered {
+		previous.close()
+	}
 	pc.collectors[shardID] = collector
 	pc.logger.Info("registered shard for metrics collection", zap.String("shard_id", shardID))
 
 	return nil
 }
 
+// SetSlowQueryThreshold sets how long a query must run before it counts as
+// slow. Non-positive values restore the default.
+func (pc *PrometheusCollector) SetSlowQueryThreshold(threshold time.Duration) {
+	if threshold <= 0 {
+		threshold = DefaultSlowQueryThreshold
+	}
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+
+	pc.slowQueryThreshold = threshold
+	for _, collector := range pc.collectors {
+		collector.mu.Lock()
+		collector.slowQueryThreshold = threshold
+		collector.mu.Unlock()
+	}
+}
+
+// SetPoolSize sets the connection pool kept to each shard, with overrides for
+// individual shards. Zero fields use DefaultPoolSize. Shards already
+// registered are resized straight away.
+func (pc *PrometheusCollector) SetPoolSize(size PoolSize, shards map[string]PoolSize) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+
+	pc.pools = poolSizes{size: size, shards: shards}
+	for shardID, collector := range pc.collectors {
+		collector.mu.RLock()
+		if collector.db != nil {
+			pc.pools.forShard(shardID).apply(collector.db)
+		}
+		collector.mu.RUnlock()
+	}
+}
+
 // UnregisterShard removes a shard from metrics collection
 func (pc *PrometheusCollector) UnregisterShard(shardID string) {
 	pc.mu.Lock()
 	defer pc.mu.Unlock()
 
 	if collector, ok := pc.collectors[shardID]; ok {
-		if collector.db != nil {
-			collector.db.Close()
-		}
+		collector.close()
 		delete(pc.collectors, shardID)
 	}
 }
 
+// RegisteredShards returns the IDs of the shards registered for metrics
+// collection, sorted
+func (pc *PrometheusCollector) RegisteredShards() []string {
+	pc.mu.RLock()
+	defer pc.mu.RUnlock()
+
+	shardIDs := make([]string, 0, len(pc.collectors))
+	for shardID := range pc.collectors {
+		shardIDs = append(shardIDs, shardID)
+	}
+	sort.Strings(shardIDs)
+	return shardIDs
+}
+
+// connectedTo reports whether the collector has a connection to dsn on engine
+func (sc *ShardCollector) connectedTo(engine, dsn string) bool {
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
+	return sc.db != nil && sc.engine == engine && sc.dsn == dsn
+}
+
+// close closes the collector's connection, if it has one. A collection still
+// running on it fails rather than reading a nil connection.
+func (sc *ShardCollector) close() {
+	sc.mu.RLock()
+	db := sc.db
+	sc.mu.RUnlock()
+	if db != nil {
+		db.Close()
+	}
+}
+
+// Started is closed once the collection loop is running
+func (pc *PrometheusCollector) Started() <-chan struct{} {
+	return pc.started
+}
+
 // Start starts the metrics collection loop
 func (pc *PrometheusCollector) Start(ctx context.Context) {
-	ticker := time.NewTicker(pc.collectionInterval)
+	interval := pc.CollectionInterval()
+	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
-	pc.logger.Info("Prometheus collector started", zap.Duration("interval", pc.collectionInterval))
+	pc.logger.Info("Prometheus collector started", zap.Duration("interval", interval))
+	pc.startOnce.Do(func() { close(pc.started) })
 
 	// Initial collection
 	pc.collectAll(ctx)
@@ -392,6 +657,8 @@ func (pc *PrometheusCollector) Start(ctx context.Context) {
 			return
 		case <-ticker.C:
 			pc.collectAll(ctx)
+		case <-pc.intervalChanged:
+			ticker.Reset(pc.CollectionInterval())
 		}
 	}
 }
@@ -406,6 +673,8 @@ func (pc *PrometheusCollector) collectAll(ctx context.Context) {
 	pc.mu.RUnlock()
 
 	for _, collector := range collectors {
+		pc.checkConnection(ctx, collector)
+
 		metrics, err := collector.Collect(ctx)
 		if err != nil {
 			pc.logger.Warn("failed to collect metrics", zap.String("shard_id", collector.shardID), zap.Error(err))
@@ -418,15 +687,26 @@ func (pc *PrometheusCollector) collectAll(ctx context.Context) {
 
 // updateMetrics updates Prometheus metrics with collected data
 func (pc *PrometheusCollector) updateMetrics(shardID, database string, metrics *ShardDetailedMetrics) {
-	pc.shardConnections.WithLabelValues(shardID, database, "active").Set(float64(metrics.ActiveConnections))
-	pc.shardConnections.WithLabelValues(shardID, database, "idle").Set(float64(metrics.IdleConnections))
-	pc.shardConnections.WithLabelValues(shardID, database, "waiting").Set(float64(metrics.WaitingConnections))
+	if metrics.ThroughPooler {
+		// No connection counts were collected, so none are exported rather than zeros
+		for _, state := range []string{"active", "idle", "waiting"} {
+			pc.shardConnections.DeleteLabelValues(shardID, database, state)
+		}
+	} else {
+		pc.shardConnections.WithLabelValues(shardID, database, "active").Set(float64(metrics.ActiveConnections))
+		pc.shardConnections.WithLabelValues(shardID, database, "idle").Set(float64(metrics.IdleConnections))
+		pc.shardConnections.WithLabelValues(shardID, database, "waiting").Set(float64(metrics.WaitingConnections))
+	}
 
 	pc.shardReplicationLag.WithLabelValues(shardID, database, "primary").Set(metrics.ReplicationLag)
 
 	pc.shardCPUUsage.WithLabelValues(shardID, database).Set(metrics.CPUUsage)
 	pc.shardMemoryUsage.WithLabelValues(shardID, database).Set(metrics.MemoryUsage)
 	pc.shardDiskUsage.WithLabelValues(shardID, database).Set(metrics.DiskUsage)
+
+	pc.shardSlowQueries.WithLabelValues(shardID, database, "activity").Set(float64(metrics.SlowQueries))
+	pc.shardSlowQueries.WithLabelValues(shardID, database, "statements").Set(float64(metrics.SlowStatements))
+	pc.shardBlockedQueries.WithLabelValues(shardID, database).Set(float64(metrics.BlockedQueries))
 }
 
 // Collect collects metrics from a shard
@@ -435,13 +715,33 @@ func (sc *ShardCollector) Collect(ctx context.Context) (*ShardDetailedMetrics, e
 		return &ShardDetailedMetrics{CollectedAt: time.Now()}, nil
 	}
 
+	sc.mu.RLock()
 	metrics := &ShardDetailedMetrics{
-		CollectedAt: time.Now(),
+		ThroughPooler: sc.throughPooler,
+		CollectedAt:   time.Now(),
+	}
+	sc.mu.RUnlock()
+
+	if isMySQL(sc.engine) {
+		sc.collectMySQL(ctx, metrics)
+	} else {
+		sc.collectPostgres(ctx, metrics)
 	}
 
-	// Collect connection stats
-	if err := sc.collectConnectionStats(ctx, metrics); err != nil {
-		sc.logger.Warn("failed to collect connection stats", zap.Error(err))
+	sc.mu.Lock()
+	sc.lastMetrics = metrics
+	sc.mu.Unlock()
+
+	return metrics, nil
+}
+
+// collectPostgres collects the shard metrics from PostgreSQL's statistics views
+func (sc *ShardCollector) collectPostgres(ctx context.Context, metrics *ShardDetailedMetrics) {
+	// Collect connection stats, unless pg_stat_activity only shows a pooler's connections
+	if !metrics.ThroughPooler {
+		if err := sc.collectConnectionStats(ctx, metrics); err != nil {
+			sc.logger.Warn("failed to collect connection stats", zap.Error(err))
+		}
 	}
 
 	// Collect replication stats
@@ -459,11 +759,15 @@ func (sc *ShardCollector) Collect(ctx context.Context) (*ShardDetailedMetrics, e
 		sc.logger.Warn("failed to collect table stats", zap.Error(err))
 	}
 
-	sc.mu.Lock()
-	sc.lastMetrics = metrics
-	sc.mu.Unlock()
+	// Collect slow query stats
+	if err := sc.collectSlowQueryStats(ctx, metrics); err != nil {
+		sc.logger.Warn("failed to collect slow query stats", zap.Error(err))
+	}
 
-	return metrics, nil
+	// Collect lock waits
+	if err := sc.collectLockWaitStats(ctx, metrics); err != nil {
+		sc.logger.Warn("failed to collect lock wait stats", zap.Error(err))
+	}
 }
 
 // collectConnectionStats collects connection statistics
@@ -570,6 +874,35 @@ func (sc *ShardCollector) collectTableStats(ctx context.Context, metrics *ShardD
 	return nil
 }
 
+// collectSlowQueryStats counts running slow queries and, when pg_stat_statements
+// is installed, statements that are slow on average
+func (sc *ShardCollector) collectSlowQueryStats(ctx context.Context, metrics *ShardDetailedMetrics) error {
+	sc.mu.RLock()
+	threshold := sc.slowQueryThreshold
+	sc.mu.RUnlock()
+
+	slowQueries, err := listSlowQueries(ctx, sc.db, threshold)
+	if err != nil {
+		return err
+	}
+	metrics.SlowQueries = int64(len(slowQueries))
+
+	if count, err := countSlowStatements(ctx, sc.db, threshold); err == nil {
+		metrics.SlowStatements = count
+	}
+	return nil
+}
+
+// collectLockWaitStats counts the sessions waiting for a lock another session holds
+func (sc *ShardCollector) collectLockWaitStats(ctx context.Context, metrics *ShardDetailedMetrics) error {
+	waits, err := listLockWaits(ctx, sc.db)
+	if err != nil {
+		return err
+	}
+	metrics.BlockedQueries = int64(newLockGraph(waits).Blocked)
+	return nil
+}
+
 // Handler returns the HTTP handler for Prometheus metrics
 func (pc *PrometheusCollector) Handler() http.Handler {
 	return promhttp.HandlerFor(pc.registry, promhttp.HandlerOpts{
@@ -577,6 +910,11 @@ func (pc *PrometheusCollector) Handler() http.Handler {
 	})
 }
 
+// MustRegister adds collectors of other components to the metrics served by Handler
+func (pc *PrometheusCollector) MustRegister(collectors ...prometheus.Collector) {
+	pc.registry.MustRegister(collectors...)
+}
+
 // RecordQuery records a query execution
 func (pc *PrometheusCollector) RecordQuery(shardID, database, operation, status string, duration time.Duration) {
 	pc.shardQueryTotal.WithLabelValues(shardID, database, status).Inc()
@@ -617,6 +955,30 @@ func (pc *PrometheusCollector) SetReshardingProgress(jobID, sourceShard, targetS
 	pc.reshardingProgress.WithLabelValues(jobID, sourceShard, targetShard).Set(progress)
 }
 
+// SetClientAppStorage records a client app's storage against its quota. A
+// quota of -1 means the tier sets none.
+func (pc *PrometheusCollector) SetClientAppStorage(clientAppID string, usedBytes, quotaBytes int64) {
+	pc.clientAppStorage.WithLabelValues(clientAppID).Set(float64(usedBytes))
+	if quotaBytes == -1 {
+		pc.clientAppStorageQuota.DeleteLabelValues(clientAppID)
+		pc.clientAppOverQuota.WithLabelValues(clientAppID).Set(0)
+		return
+	}
+	pc.clientAppStorageQuota.WithLabelValues(clientAppID).Set(float64(quotaBytes))
+	over := 0.0
+	if usedBytes > quotaBytes {
+		over = 1.0
+	}
+	pc.clientAppOverQuota.WithLabelValues(clientAppID).Set(over)
+}
+
+// DeleteClientAppStorage removes the storage series of a deleted client app
+func (pc *PrometheusCollector) DeleteClientAppStorage(clientAppID string) {
+	pc.clientAppStorage.DeleteLabelValues(clientAppID)
+	pc.clientAppStorageQuota.DeleteLabelValues(clientAppID)
+	pc.clientAppOverQuota.DeleteLabelValues(clientAppID)
+}
+
 // RecordPostgresStats records PostgreSQL statistics from scanned databases
 func (pc *PrometheusCollector) RecordPostgresStats(clusterID, clusterName, namespace, databaseName, databaseHost string, stats *ShardDetailedMetrics) {
 	labels := []string{clusterID, clusterName, namespace, databaseName, databaseHost}
@@ -652,12 +1014,6 @@ func (pc *PrometheusCollector) RecordPostgresStats(clusterID, clusterName, names
 	}
 }
 
-// RecordPostgresTableStats records table-level PostgreSQL statistics
-func (pc *PrometheusCollector) RecordPostgresTableStats(clusterID, clusterName, namespace, databaseName, databaseHost, tableName string, rowCount int64) {
-	labels := []string{clusterID, clusterName, namespace, databaseName, databaseHost, tableName}
-	pc.postgresTableRows.WithLabelValues(labels...).Set(float64(rowCount))
-}
-
 // GetShardMetrics returns the latest metrics for a shard
 func (pc *PrometheusCollector) GetShardMetrics(shardID string) (*ShardDetailedMetrics, bool) {
 	pc.mu.RLock()
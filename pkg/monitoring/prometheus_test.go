@@ -0,0 +1,180 @@
+package monitoring
+
+import (
+	"database/sql"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"go.uber.org/zap/zaptest"
+)
+
+// histogram gathers the histogram of a metric family from the collector's registry
+func histogram(t *testing.T, pc *PrometheusCollector, name string) *dto.Histogram {
+	t.Helper()
+	families, err := pc.registry.Gather()
+	if err != nil {
+		t.Fatalf("failed to gather metrics: %v", err)
+	}
+	for _, family := range families {
+		if family.GetName() == name && len(family.GetMetric()) > 0 {
+			return family.GetMetric()[0].GetHistogram()
+		}
+	}
+	t.Fatalf("metric %s not found", name)
+	return nil
+}
+
+// cumulativeCounts maps each bucket's upper bound to its cumulative count
+func cumulativeCounts(h *dto.Histogram) map[float64]uint64 {
+	counts := make(map[float64]uint64)
+	for _, bucket := range h.GetBucket() {
+		counts[bucket.GetUpperBound()] = bucket.GetCumulativeCount()
+	}
+	return counts
+}
+
+func TestPrometheusCollector_CustomHistogramBuckets(t *testing.T) {
+	pc, err := NewPrometheusCollectorWithBuckets(zaptest.NewLogger(t), time.Minute, HistogramBuckets{
+		QueryDuration: []float64{0.05, 0.2, 1},
+		RouterLatency: []float64{0.002, 0.004},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	pc.RecordQuery("shard1", "orders", "select", "success", 30*time.Millisecond)
+	pc.RecordQuery("shard1", "orders", "select", "success", 150*time.Millisecond)
+	pc.RecordQuery("shard1", "orders", "select", "success", 3*time.Second)
+	pc.RecordRouterRequest("GET", "/route", "200", 3*time.Millisecond)
+
+	query := histogram(t, pc, "sharding_shard_query_duration_seconds")
+	if len(query.GetBucket()) != 3 {
+		t.Fatalf("expected 3 query duration buckets, got %d", len(query.GetBucket()))
+	}
+	want := map[float64]uint64{0.05: 1, 0.2: 2, 1: 2}
+	for bound, count := range cumulativeCounts(query) {
+		if want[bound] != count {
+			t.Errorf("expected %d observations up to %vs, got %d", want[bound], bound, count)
+		}
+	}
+	if query.GetSampleCount() != 3 {
+		t.Errorf("expected 3 observations in total, got %d", query.GetSampleCount())
+	}
+
+	router := cumulativeCounts(histogram(t, pc, "sharding_router_latency_seconds"))
+	if len(router) != 2 || router[0.002] != 0 || router[0.004] != 1 {
+		t.Errorf("expected the router request in the 4ms bucket, got %v", router)
+	}
+}
+
+func TestPrometheusCollector_DefaultHistogramBuckets(t *testing.T) {
+	pc, err := NewPrometheusCollectorWithBuckets(zaptest.NewLogger(t), time.Minute, HistogramBuckets{
+		RouterLatency: []float64{0.5},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	pc.RecordQuery("shard1", "orders", "select", "success", time.Millisecond)
+	pc.RecordRouterRequest("GET", "/route", "200", time.Millisecond)
+
+	if got := len(histogram(t, pc, "sharding_shard_query_duration_seconds").GetBucket()); got != len(DefaultQueryDurationBuckets) {
+		t.Errorf("expected unset query duration buckets to keep the %d defaults, got %d", len(DefaultQueryDurationBuckets), got)
+	}
+	if got := len(histogram(t, pc, "sharding_router_latency_seconds").GetBucket()); got != 1 {
+		t.Errorf("expected 1 router latency bucket, got %d", got)
+	}
+}
+
+func TestPrometheusCollector_InvalidHistogramBuckets(t *testing.T) {
+	invalid := [][]float64{
+		{0.1, 0.05},
+		{0.1, 0.1},
+		{0, 0.1},
+		{-1, 0.1},
+		{math.NaN()},
+	}
+	for _, buckets := range invalid {
+		if _, err := NewPrometheusCollectorWithBuckets(zaptest.NewLogger(t), time.Minute, HistogramBuckets{QueryDuration: buckets}); err == nil {
+			t.Errorf("expected query duration buckets %v to be rejected", buckets)
+		}
+		if _, err := NewPrometheusCollectorWithBuckets(zaptest.NewLogger(t), time.Minute, HistogramBuckets{RouterLatency: buckets}); err == nil {
+			t.Errorf("expected router latency buckets %v to be rejected", buckets)
+		}
+	}
+}
+
+func TestPrometheusCollector_ClientAppStorage(t *testing.T) {
+	pc := NewPrometheusCollector(zaptest.NewLogger(t), time.Minute)
+
+	pc.SetClientAppStorage("app1", 3<<29, 1<<30)
+	if got := testutil.ToFloat64(pc.clientAppOverQuota.WithLabelValues("app1")); got != 1 {
+		t.Errorf("expected app1 to be flagged over quota, got %v", got)
+	}
+	if got := testutil.ToFloat64(pc.clientAppStorageQuota.WithLabelValues("app1")); got != 1<<30 {
+		t.Errorf("expected a 1GiB quota, got %v", got)
+	}
+
+	// Moving to a tier without a quota clears the quota series
+	pc.SetClientAppStorage("app1", 3<<29, -1)
+	if got := testutil.ToFloat64(pc.clientAppOverQuota.WithLabelValues("app1")); got != 0 {
+		t.Errorf("expected app1 not to be over an unlimited quota, got %v", got)
+	}
+	if got := testutil.CollectAndCount(pc.clientAppStorageQuota); got != 0 {
+		t.Errorf("expected no quota series, got %d", got)
+	}
+	if got := testutil.ToFloat64(pc.clientAppStorage.WithLabelValues("app1")); got != 3<<29 {
+		t.Errorf("expected 1.5GiB of storage, got %v", got)
+	}
+
+	// Deleting the app removes all of its series
+	pc.DeleteClientAppStorage("app1")
+	for _, gauge := range []*prometheus.GaugeVec{pc.clientAppStorage, pc.clientAppStorageQuota, pc.clientAppOverQuota} {
+		if got := testutil.CollectAndCount(gauge); got != 0 {
+			t.Errorf("expected no series after the app was deleted, got %d", got)
+		}
+	}
+}
+
+// assertClosed fails the test if db is still open
+func assertClosed(t *testing.T, db *sql.DB, what string) {
+	t.Helper()
+	if err := db.Ping(); err == nil || err.Error() != "sql: database is closed" {
+		t.Errorf("expected %s to be closed, ping returned %v", what, err)
+	}
+}
+
+func TestPrometheusCollector_RegisterShardAgain(t *testing.T) {
+	pc := NewPrometheusCollector(zaptest.NewLogger(t), time.Minute)
+	pc.driver = "monitoringtest"
+	defer pc.UnregisterShard("shard1")
+
+	if err := pc.RegisterShard("shard1", t.Name()+"-old"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	first := pc.collectors["shard1"].db
+
+	// Startup and shard creation both register the shard
+	for i := 0; i < 3; i++ {
+		if err := pc.RegisterShard("shard1", t.Name()+"-old"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if pc.collectors["shard1"].db != first {
+		t.Fatal("expected registering the same DSN again to keep the connection")
+	}
+	if err := first.Ping(); err != nil {
+		t.Fatalf("expected the connection to stay open, got %v", err)
+	}
+
+	if err := pc.RegisterShard("shard1", t.Name()+"-new"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(pc.collectors) != 1 || pc.collectors["shard1"].dsn != t.Name()+"-new" {
+		t.Fatalf("expected one collector on the new DSN, got %d", len(pc.collectors))
+	}
+	assertClosed(t, first, "the connection to the old DSN")
+}
@@ -0,0 +1,141 @@
+package monitoring
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/sharding-system/pkg/catalog"
+	"go.uber.org/zap"
+)
+
+// DSNReconciler reconnects to shards whose connection details changed, e.g.
+// after a password rotation
+type DSNReconciler interface {
+	// ReconcileDSNs resolves every shard's DSN again and moves the connections
+	// of those that changed, returning how many moved
+	ReconcileDSNs(ctx context.Context) int
+}
+
+// WatchCatalogDSNs reconciles DSNs each time the catalog changes, so rotated
+// credentials are picked up before the old ones start failing new
+// connections. It returns when ctx is done.
+func WatchCatalogDSNs(ctx context.Context, cat catalog.Catalog, logger *zap.Logger, reconcilers ...DSNReconciler) {
+	updates, err := cat.Watch(ctx)
+	if err != nil {
+		logger.Error("failed to watch catalog for connection changes", zap.Error(err))
+		return
+	}
+
+	for range updates {
+		for _, reconciler := range reconcilers {
+			reconciler.ReconcileDSNs(ctx)
+		}
+	}
+}
+
+// ReconcileDSNs moves every shard whose DSN changed in the resolver to a new
+// connection. The old connection is only closed once the new one answers a
+// ping, so a bad update leaves the shard on its working connection, and
+// closing it waits for queries already running on it.
+func (pc *PrometheusCollector) ReconcileDSNs(ctx context.Context) int {
+	pc.mu.RLock()
+	resolver, driverName := pc.resolver, pc.driver
+	collectors := make([]*ShardCollector, 0, len(pc.collectors))
+	for _, collector := range pc.collectors {
+		collectors = append(collectors, collector)
+	}
+	pc.mu.RUnlock()
+	if resolver == nil {
+		return 0
+	}
+
+	moved := 0
+	for _, sc := range collectors {
+		pc.mu.RLock()
+		size := pc.pools.forShard(sc.shardID)
+		pc.mu.RUnlock()
+
+		dsn, db, err := reconnectToPrimary(ctx, resolver, sc.shardID, sc.engine, driverName, sc.currentDSN(), size)
+		if err != nil {
+			sc.logger.Warn("failed to reconnect to shard with its new connection details", zap.Error(err))
+			continue
+		}
+		if db == nil {
+			continue
+		}
+		sc.replace(db, dsn)
+		moved++
+		sc.logger.Info("reconnected to shard with its new connection details")
+	}
+	return moved
+}
+
+// currentDSN returns the DSN the collector is connected with
+func (sc *ShardCollector) currentDSN() string {
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
+	return sc.dsn
+}
+
+// replace moves the collector to a new connection and closes the old one
+func (sc *ShardCollector) replace(db *sql.DB, dsn string) {
+	sc.mu.Lock()
+	old := sc.db
+	sc.db, sc.dsn, sc.failures = db, dsn, 0
+	sc.mu.Unlock()
+	if old != nil {
+		old.Close()
+	}
+}
+
+// ReconcileDSNs moves every database whose DSN changed in the resolver to a
+// new connection, keeping its stats baseline. The old connection is only
+// closed once the new one answers a ping.
+func (psc *PostgresStatsCollector) ReconcileDSNs(ctx context.Context) int {
+	psc.mu.RLock()
+	resolver, driverName := psc.resolver, psc.driver
+	databases := make([]*DBConnection, 0, len(psc.databases))
+	for _, dbConn := range psc.databases {
+		databases = append(databases, dbConn)
+	}
+	psc.mu.RUnlock()
+	if resolver == nil {
+		return 0
+	}
+
+	moved := 0
+	for _, dbConn := range databases {
+		psc.mu.RLock()
+		current, size := dbConn.DSN, psc.pools.forShard(dbConn.DatabaseID)
+		psc.mu.RUnlock()
+
+		dsn, db, err := reconnectToPrimary(ctx, resolver, dbConn.DatabaseID, dbConn.Engine, driverName, current, size)
+		if err != nil {
+			psc.logger.Warn("failed to reconnect to database with its new connection details",
+				zap.String("database_id", dbConn.DatabaseID),
+				zap.Error(err))
+			continue
+		}
+		if db == nil {
+			continue
+		}
+		psc.replace(dbConn, db, dsn)
+		moved++
+		psc.logger.Info("reconnected to database with its new connection details", zap.String("database_id", dbConn.DatabaseID))
+	}
+	return moved
+}
+
+// replace moves a database to a new connection and closes the old one
+func (psc *PostgresStatsCollector) replace(dbConn *DBConnection, db *sql.DB, dsn string) {
+	db.SetConnMaxLifetime(5 * time.Minute)
+
+	psc.mu.Lock()
+	old := dbConn.DB
+	dbConn.DB, dbConn.DSN, dbConn.failures = db, dsn, 0
+	psc.mu.Unlock()
+	if old != nil {
+		old.Close()
+	}
+}
@@ -0,0 +1,178 @@
+package monitoring
+
+import (
+	"context"
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	"github.com/sharding-system/pkg/models"
+	"go.uber.org/zap/zaptest"
+)
+
+// watchedCatalog is a failoverCatalog whose changes are announced on a channel
+type watchedCatalog struct {
+	*failoverCatalog
+	updates chan *models.ShardCatalog
+}
+
+func (c *watchedCatalog) Watch(ctx context.Context) (<-chan *models.ShardCatalog, error) {
+	return c.updates, nil
+}
+
+func (c *watchedCatalog) setPassword(shardID, password string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.shards[shardID].Password = password
+}
+
+// rotationFixture is a shard whose password is rotated in the catalog. The
+// old password stops working for new connections once it is rotated.
+type rotationFixture struct {
+	catalog        *watchedCatalog
+	oldDSN, newDSN string
+}
+
+func newRotationFixture(t *testing.T, results ...fakeResult) *rotationFixture {
+	t.Helper()
+	shard := &models.Shard{ID: "shard1", Host: t.Name(), Database: "orders", Username: "app", Password: "old-secret"}
+	f := &rotationFixture{
+		catalog: &watchedCatalog{
+			failoverCatalog: &failoverCatalog{shards: map[string]*models.Shard{"shard1": shard}},
+			updates:         make(chan *models.ShardCatalog),
+		},
+		oldDSN: shard.ConnectionString(CollectorApplicationName),
+	}
+	rotated := *shard
+	rotated.Password = "new-secret"
+	f.newDSN = rotated.ConnectionString(CollectorApplicationName)
+
+	testStatsDriver.mu.Lock()
+	testStatsDriver.results[f.newDSN] = results
+	testStatsDriver.queried[f.newDSN] = nil
+	testStatsDriver.mu.Unlock()
+	t.Cleanup(func() {
+		testStatsDriver.setDown(f.oldDSN, false)
+		testStatsDriver.setDown(f.newDSN, false)
+	})
+	return f
+}
+
+// rotate changes the shard's password in the catalog and revokes the old one
+func (f *rotationFixture) rotate() {
+	f.catalog.setPassword("shard1", "new-secret")
+	testStatsDriver.setDown(f.oldDSN, true)
+}
+
+func TestPrometheusCollector_ReconnectsWithRotatedPassword(t *testing.T) {
+	f := newRotationFixture(t, fakeResult{
+		match:   "FROM pg_stat_activity",
+		columns: []string{"active", "idle", "waiting", "max_conn"},
+		rows:    [][]driver.Value{{int64(4), int64(1), int64(0), int64(100)}},
+	})
+
+	pc := NewPrometheusCollector(zaptest.NewLogger(t), time.Minute)
+	pc.driver = "monitoringtest"
+	pc.SetEndpointResolver(NewCatalogResolver(f.catalog), 0)
+	if err := pc.RegisterShardWithEngine("shard1", models.EnginePostgres, f.oldDSN); err != nil {
+		t.Fatalf("failed to register shard: %v", err)
+	}
+	collector := pc.collectors["shard1"]
+	oldDB := collector.db
+
+	// Nothing changed yet
+	if moved := pc.ReconcileDSNs(context.Background()); moved != 0 {
+		t.Fatalf("expected no reconnects before the rotation, got %d", moved)
+	}
+
+	f.rotate()
+	if moved := pc.ReconcileDSNs(context.Background()); moved != 1 {
+		t.Fatalf("expected the shard to reconnect, got %d reconnects", moved)
+	}
+	if collector.currentDSN() != f.newDSN || collector.db == oldDB {
+		t.Fatalf("expected the collector to use the rotated password, got %s", collector.currentDSN())
+	}
+	assertClosed(t, oldDB, "the connection with the old password")
+
+	metrics, err := collector.Collect(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if metrics.ActiveConnections != 4 {
+		t.Errorf("expected metrics over the new connection, got %d active connections", metrics.ActiveConnections)
+	}
+}
+
+func TestPostgresStatsCollector_ReconnectsWithRotatedPassword(t *testing.T) {
+	f := newRotationFixture(t)
+
+	psc := NewPostgresStatsCollector(zaptest.NewLogger(t), time.Minute)
+	psc.driver = "monitoringtest"
+	psc.SetEndpointResolver(NewCatalogResolver(f.catalog), 0)
+	if err := psc.RegisterDatabaseWithEngine("shard1", models.EnginePostgres, f.oldDSN); err != nil {
+		t.Fatalf("failed to register database: %v", err)
+	}
+	dbConn := psc.databases["shard1"]
+	baseline := &PostgresStats{DatabaseName: "orders"}
+	dbConn.LastStats = baseline
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		WatchCatalogDSNs(ctx, f.catalog, zaptest.NewLogger(t), psc)
+		close(done)
+	}()
+
+	f.rotate()
+	f.catalog.updates <- &models.ShardCatalog{}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		psc.mu.RLock()
+		dsn := dbConn.DSN
+		psc.mu.RUnlock()
+		if dsn == f.newDSN {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected the catalog change to reconnect the database")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	cancel()
+	close(f.catalog.updates)
+	<-done
+
+	if psc.databases["shard1"] != dbConn || dbConn.LastStats != baseline {
+		t.Error("expected the database to keep its stats across the reconnect")
+	}
+	if err := dbConn.DB.Ping(); err != nil {
+		t.Errorf("expected the new connection to work: %v", err)
+	}
+}
+
+func TestPrometheusCollector_KeepsConnectionWhenRotatedPasswordFails(t *testing.T) {
+	f := newRotationFixture(t)
+
+	pc := NewPrometheusCollector(zaptest.NewLogger(t), time.Minute)
+	pc.driver = "monitoringtest"
+	pc.SetEndpointResolver(NewCatalogResolver(f.catalog), 0)
+	if err := pc.RegisterShardWithEngine("shard1", models.EnginePostgres, f.oldDSN); err != nil {
+		t.Fatalf("failed to register shard: %v", err)
+	}
+	collector := pc.collectors["shard1"]
+	oldDB := collector.db
+
+	// The catalog was updated before the database accepts the new password
+	f.catalog.setPassword("shard1", "new-secret")
+	testStatsDriver.setDown(f.newDSN, true)
+	if moved := pc.ReconcileDSNs(context.Background()); moved != 0 {
+		t.Fatalf("expected no reconnect, got %d", moved)
+	}
+	if collector.currentDSN() != f.oldDSN || collector.db != oldDB {
+		t.Fatal("expected the collector to keep its working connection")
+	}
+	if err := oldDB.Ping(); err != nil {
+		t.Errorf("expected the old connection to stay open: %v", err)
+	}
+}
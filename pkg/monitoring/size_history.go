@@ -0,0 +1,217 @@
+package monitoring
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// DefaultSizeHistoryRetention is how long size samples are kept by default
+const DefaultSizeHistoryRetention = 7 * 24 * time.Hour
+
+// sizeHistorySamples is how many samples a series keeps across the retention.
+// Collections closer together than retention/sizeHistorySamples are not
+// sampled, so memory stays bounded however often stats are collected.
+const sizeHistorySamples = 256
+
+// maxLargestTables caps the tables collected with their sizes per database
+const maxLargestTables = 10
+
+// SizeSample is the size of a database or table at a point in time
+type SizeSample struct {
+	At    time.Time `json:"at"`
+	Bytes int64     `json:"bytes"`
+}
+
+// CapacityReport is a database's size history with the growth rates derived
+// from it, for capacity planning. Growth is 0 until two samples are kept.
+type CapacityReport struct {
+	DatabaseID        string          `json:"database_id"`
+	SizeBytes         int64           `json:"size_bytes"`
+	GrowthBytesPerDay float64         `json:"growth_bytes_per_day"`
+	Retention         string          `json:"retention"`
+	History           []SizeSample    `json:"history"`
+	Tables            []TableCapacity `json:"tables,omitempty"` // Fastest growing first
+}
+
+// TableCapacity is the size and growth rate of one of a database's largest tables
+type TableCapacity struct {
+	Schema            string  `json:"schema"`
+	TableName         string  `json:"table_name"`
+	SizeBytes         int64   `json:"size_bytes"`
+	GrowthBytesPerDay float64 `json:"growth_bytes_per_day"`
+	Samples           int     `json:"samples"`
+}
+
+// GrowthRate returns how fast a series of sizes grows in bytes per day, as
+// the least-squares slope of size over time. Fewer than two samples, or
+// samples all taken at once, give 0.
+func GrowthRate(samples []SizeSample) float64 {
+	if len(samples) < 2 {
+		return 0
+	}
+	origin := samples[0].At
+	var sumX, sumY float64
+	for _, sample := range samples {
+		sumX += sample.At.Sub(origin).Seconds()
+		sumY += float64(sample.Bytes)
+	}
+	n := float64(len(samples))
+	meanX, meanY := sumX/n, sumY/n
+
+	var covariance, variance float64
+	for _, sample := range samples {
+		dx := sample.At.Sub(origin).Seconds() - meanX
+		covariance += dx * (float64(sample.Bytes) - meanY)
+		variance += dx * dx
+	}
+	if variance == 0 {
+		return 0
+	}
+	return covariance / variance * (24 * time.Hour).Seconds()
+}
+
+// sizeSeries is a ring buffer of size samples
+type sizeSeries struct {
+	schema, table string // Empty for the database itself
+	samples       []SizeSample
+	start, count  int
+}
+
+func newSizeSeries(schema, table string) *sizeSeries {
+	return &sizeSeries{schema: schema, table: table, samples: make([]SizeSample, sizeHistorySamples)}
+}
+
+// at returns the i-th oldest sample
+func (s *sizeSeries) at(i int) SizeSample {
+	return s.samples[(s.start+i)%len(s.samples)]
+}
+
+// add appends a sample unless the latest is less than spacing older,
+// overwriting the oldest sample once the buffer is full
+func (s *sizeSeries) add(sample SizeSample, spacing time.Duration) {
+	if s.count > 0 && sample.At.Sub(s.at(s.count-1).At) < spacing {
+		return
+	}
+	if s.count == len(s.samples) {
+		s.samples[s.start] = sample
+		s.start = (s.start + 1) % len(s.samples)
+		return
+	}
+	s.samples[(s.start+s.count)%len(s.samples)] = sample
+	s.count++
+}
+
+// trim drops the samples taken before cutoff
+func (s *sizeSeries) trim(cutoff time.Time) {
+	for s.count > 0 && s.at(0).At.Before(cutoff) {
+		s.start = (s.start + 1) % len(s.samples)
+		s.count--
+	}
+}
+
+// list returns the samples, oldest first
+func (s *sizeSeries) list() []SizeSample {
+	samples := make([]SizeSample, s.count)
+	for i := range samples {
+		samples[i] = s.at(i)
+	}
+	return samples
+}
+
+// sizeHistory holds the size samples of a database and its largest tables
+type sizeHistory struct {
+	database *sizeSeries
+	tables   map[string]*sizeSeries // By schema-qualified table name
+}
+
+func newSizeHistory() *sizeHistory {
+	return &sizeHistory{database: newSizeSeries("", ""), tables: make(map[string]*sizeSeries)}
+}
+
+// add samples the sizes of a collection and drops the samples, and the table
+// series, that fell out of the retention
+func (h *sizeHistory) add(stats *PostgresStats, retention time.Duration) {
+	spacing := retention / sizeHistorySamples
+	// An unknown size, from a failed query, is not a drop to zero
+	if stats.Size > 0 {
+		h.database.add(SizeSample{At: stats.CollectedAt, Bytes: stats.Size}, spacing)
+	}
+	for _, table := range stats.Tables.LargestTables {
+		key := table.Schema + "." + table.TableName
+		series, ok := h.tables[key]
+		if !ok {
+			series = newSizeSeries(table.Schema, table.TableName)
+			h.tables[key] = series
+		}
+		series.add(SizeSample{At: stats.CollectedAt, Bytes: table.Size}, spacing)
+	}
+
+	cutoff := stats.CollectedAt.Add(-retention)
+	h.database.trim(cutoff)
+	for key, series := range h.tables {
+		if series.trim(cutoff); series.count == 0 {
+			delete(h.tables, key)
+		}
+	}
+}
+
+// report builds the capacity report of the history
+func (h *sizeHistory) report(databaseID string, retention time.Duration) *CapacityReport {
+	report := &CapacityReport{
+		DatabaseID: databaseID,
+		Retention:  retention.String(),
+		History:    h.database.list(),
+		Tables:     make([]TableCapacity, 0, len(h.tables)),
+	}
+	if len(report.History) > 0 {
+		report.SizeBytes = report.History[len(report.History)-1].Bytes
+	}
+	report.GrowthBytesPerDay = GrowthRate(report.History)
+
+	for _, series := range h.tables {
+		samples := series.list()
+		report.Tables = append(report.Tables, TableCapacity{
+			Schema:            series.schema,
+			TableName:         series.table,
+			SizeBytes:         samples[len(samples)-1].Bytes,
+			GrowthBytesPerDay: GrowthRate(samples),
+			Samples:           len(samples),
+		})
+	}
+	sort.Slice(report.Tables, func(i, j int) bool {
+		a, b := report.Tables[i], report.Tables[j]
+		if a.GrowthBytesPerDay != b.GrowthBytesPerDay {
+			return a.GrowthBytesPerDay > b.GrowthBytesPerDay
+		}
+		return a.Schema+"."+a.TableName < b.Schema+"."+b.TableName
+	})
+	return report
+}
+
+// SetSizeHistoryRetention sets how long size samples are kept for growth
+// trending. Non-positive values restore the default. Samples older than a
+// shorter retention are dropped at the next collection.
+func (psc *PostgresStatsCollector) SetSizeHistoryRetention(retention time.Duration) {
+	if retention <= 0 {
+		retention = DefaultSizeHistoryRetention
+	}
+	psc.mu.Lock()
+	defer psc.mu.Unlock()
+	psc.sizeRetention = retention
+}
+
+// GetCapacity returns the size history and growth rates of a registered database
+func (psc *PostgresStatsCollector) GetCapacity(databaseID string) (*CapacityReport, error) {
+	psc.mu.RLock()
+	defer psc.mu.RUnlock()
+
+	dbConn, ok := psc.databases[databaseID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrDatabaseNotRegistered, databaseID)
+	}
+	if dbConn.sizes == nil {
+		return newSizeHistory().report(databaseID, psc.sizeRetention), nil
+	}
+	return dbConn.sizes.report(databaseID, psc.sizeRetention), nil
+}
@@ -0,0 +1,193 @@
+package monitoring
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zaptest"
+)
+
+// sizesAt builds a collection of a database size and its orders table size
+func sizesAt(size, orders int64, at time.Time) *PostgresStats {
+	return &PostgresStats{
+		DatabaseID:  "shard1",
+		Size:        size,
+		CollectedAt: at,
+		Tables: TableStats{LargestTables: []TableInfo{
+			{Schema: "public", TableName: "orders", Size: orders},
+			{Schema: "public", TableName: "customers", Size: 1 << 20},
+		}},
+	}
+}
+
+func TestGrowthRate(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		samples []SizeSample
+		want    float64
+	}{
+		{name: "no samples", want: 0},
+		{name: "one sample", samples: []SizeSample{{At: start, Bytes: 100}}, want: 0},
+		{name: "same instant", samples: []SizeSample{{At: start, Bytes: 100}, {At: start, Bytes: 200}}, want: 0},
+		{name: "steady growth", samples: []SizeSample{
+			{At: start, Bytes: 1000},
+			{At: start.Add(6 * time.Hour), Bytes: 1250},
+			{At: start.Add(12 * time.Hour), Bytes: 1500},
+			{At: start.Add(24 * time.Hour), Bytes: 2000},
+		}, want: 1000},
+		{name: "noisy growth", samples: []SizeSample{
+			{At: start, Bytes: 1000},
+			{At: start.Add(24 * time.Hour), Bytes: 3100},
+			{At: start.Add(48 * time.Hour), Bytes: 4900},
+		}, want: 1950},
+		{name: "shrinking", samples: []SizeSample{
+			{At: start, Bytes: 5000},
+			{At: start.Add(48 * time.Hour), Bytes: 4000},
+		}, want: -500},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := GrowthRate(tt.samples); math.Abs(got-tt.want) > 1e-6 {
+				t.Errorf("expected %v bytes/day, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestPostgresStatsCollector_GetCapacity(t *testing.T) {
+	psc := NewPostgresStatsCollector(zaptest.NewLogger(t), time.Minute)
+	dbConn := &DBConnection{DatabaseID: "shard1"}
+	psc.databases["shard1"] = dbConn
+	start := time.Now().Add(-72 * time.Hour)
+
+	// The database grows 10 MiB a day and its orders table 4 MiB a day
+	for day := int64(0); day <= 3; day++ {
+		psc.record(dbConn, sizesAt(100<<20+day*10<<20, 50<<20+day*4<<20, start.Add(time.Duration(day)*24*time.Hour)))
+	}
+
+	report, err := psc.GetCapacity("shard1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(report.History) != 4 || report.SizeBytes != 130<<20 {
+		t.Errorf("expected 4 samples up to 130 MiB, got %d up to %d", len(report.History), report.SizeBytes)
+	}
+	if math.Abs(report.GrowthBytesPerDay-10<<20) > 1 {
+		t.Errorf("expected 10 MiB/day, got %v", report.GrowthBytesPerDay)
+	}
+	if len(report.Tables) != 2 {
+		t.Fatalf("expected 2 tables, got %+v", report.Tables)
+	}
+	orders, customers := report.Tables[0], report.Tables[1]
+	if orders.TableName != "orders" || math.Abs(orders.GrowthBytesPerDay-4<<20) > 1 || orders.SizeBytes != 62<<20 || orders.Samples != 4 {
+		t.Errorf("expected orders to grow fastest at 4 MiB/day, got %+v", orders)
+	}
+	if customers.TableName != "customers" || customers.GrowthBytesPerDay != 0 {
+		t.Errorf("expected customers not to grow, got %+v", customers)
+	}
+
+	if _, err := psc.GetCapacity("unknown"); !errors.Is(err, ErrDatabaseNotRegistered) {
+		t.Errorf("expected ErrDatabaseNotRegistered, got %v", err)
+	}
+}
+
+func TestPostgresStatsCollector_SizeHistoryRetention(t *testing.T) {
+	psc := NewPostgresStatsCollector(zaptest.NewLogger(t), time.Minute)
+	psc.SetSizeHistoryRetention(time.Hour)
+	dbConn := &DBConnection{DatabaseID: "shard1"}
+	psc.databases["shard1"] = dbConn
+	start := time.Now()
+
+	// Collections closer together than retention/sizeHistorySamples are not sampled
+	spacing := time.Hour / sizeHistorySamples
+	for i := 0; i < 3*sizeHistorySamples; i++ {
+		at := start.Add(time.Duration(i) * spacing / 2)
+		psc.record(dbConn, sizesAt(int64(1000+i), int64(i), at))
+	}
+
+	report, _ := psc.GetCapacity("shard1")
+	if len(report.History) > sizeHistorySamples+1 {
+		t.Errorf("expected at most %d samples, got %d", sizeHistorySamples+1, len(report.History))
+	}
+	oldest := report.History[0].At
+	latest := report.History[len(report.History)-1].At
+	if latest.Sub(oldest) > time.Hour {
+		t.Errorf("expected samples within the hour of retention, got %v to %v", oldest, latest)
+	}
+	if report.Retention != "1h0m0s" {
+		t.Errorf("expected the retention to be reported, got %s", report.Retention)
+	}
+
+	// Tables that stop being collected age out with their samples
+	psc.record(dbConn, &PostgresStats{DatabaseID: "shard1", Size: 5000, CollectedAt: latest.Add(2 * time.Hour)})
+	report, _ = psc.GetCapacity("shard1")
+	if len(report.History) != 1 || len(report.Tables) != 0 {
+		t.Errorf("expected only the latest database sample, got %d samples and %+v", len(report.History), report.Tables)
+	}
+}
+
+func TestPostgresStatsCollector_SizeHistorySkipsUnknownSizes(t *testing.T) {
+	psc := NewPostgresStatsCollector(zaptest.NewLogger(t), time.Minute)
+	dbConn := &DBConnection{DatabaseID: "shard1"}
+	psc.databases["shard1"] = dbConn
+	start := time.Now()
+
+	psc.record(dbConn, sizesAt(1000, 10, start))
+	psc.record(dbConn, &PostgresStats{DatabaseID: "shard1", CollectedAt: start.Add(time.Hour)})
+
+	report, _ := psc.GetCapacity("shard1")
+	if len(report.History) != 1 || report.SizeBytes != 1000 {
+		t.Errorf("expected a failed size query not to be sampled as 0 bytes, got %+v", report.History)
+	}
+}
+
+func TestSizeSeries_RingBuffer(t *testing.T) {
+	series := newSizeSeries("", "")
+	start := time.Now()
+	for i := 0; i < sizeHistorySamples+10; i++ {
+		series.add(SizeSample{At: start.Add(time.Duration(i) * time.Second), Bytes: int64(i)}, 0)
+	}
+
+	samples := series.list()
+	if len(samples) != sizeHistorySamples {
+		t.Fatalf("expected a full buffer of %d samples, got %d", sizeHistorySamples, len(samples))
+	}
+	if samples[0].Bytes != 10 || samples[len(samples)-1].Bytes != sizeHistorySamples+9 {
+		t.Errorf("expected the oldest samples to be overwritten, got %d to %d", samples[0].Bytes, samples[len(samples)-1].Bytes)
+	}
+
+	series.trim(start.Add(time.Duration(sizeHistorySamples) * time.Second))
+	if got := series.list(); len(got) != 10 || got[0].Bytes != sizeHistorySamples {
+		t.Errorf("expected the samples before the cutoff to be dropped, got %d from %d", len(got), got[0].Bytes)
+	}
+}
+
+func TestPostgresStatsCollector_CollectTableStatsLargestTables(t *testing.T) {
+	db := testStatsDriver.open(t,
+		fakeResult{match: "sum(n_live_tup)", columns: []string{"count", "live", "dead", "seq", "idx"}, rows: [][]driver.Value{
+			{int64(2), int64(300), int64(5), int64(10), int64(30)},
+		}},
+		fakeResult{match: "pg_total_relation_size", columns: []string{"schemaname", "relname", "n_live_tup", "size", "seq_scan", "idx_scan"}, rows: [][]driver.Value{
+			{"public", "orders", int64(200), int64(8 << 20), int64(4), int64(20)},
+			{"billing", "invoices", int64(100), int64(2 << 20), int64(6), int64(10)},
+		}},
+	)
+	psc := NewPostgresStatsCollector(zaptest.NewLogger(t), time.Minute)
+
+	stats := &PostgresStats{}
+	if err := psc.collectTableStats(context.Background(), db, stats); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tables := stats.Tables.LargestTables
+	if len(tables) != 2 || tables[0].TableName != "orders" || tables[0].Size != 8<<20 || tables[1].Schema != "billing" {
+		t.Errorf("expected orders and billing.invoices, got %+v", tables)
+	}
+	if args := testStatsDriver.lastArgs(t.Name(), "pg_total_relation_size"); len(args) != 1 || args[0].Value != int64(maxLargestTables) {
+		t.Errorf("expected the tables to be capped at %d, got %v", maxLargestTables, args)
+	}
+}
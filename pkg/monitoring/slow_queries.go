@@ -0,0 +1,127 @@
+package monitoring
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// DefaultSlowQueryThreshold is how long a query must run before it counts as slow
+const DefaultSlowQueryThreshold = time.Second
+
+// SlowQuery is a query that has been running longer than the slow query threshold
+type SlowQuery struct {
+	PID         int       `json:"pid"`
+	User        string    `json:"user"`
+	Application string    `json:"application"`
+	State       string    `json:"state"`
+	StartedAt   time.Time `json:"started_at"`
+	DurationMs  float64   `json:"duration_ms"`
+	Query       string    `json:"query"` // Literals are masked
+}
+
+// ErrBackendNotFound is returned when signalling a PID that is not a backend of the database
+var ErrBackendNotFound = errors.New("backend not found")
+
+var (
+	stringLiteral  = regexp.MustCompile(`'(?:[^']|'')*'`)
+	numericLiteral = regexp.MustCompile(`\$\d+|\b\d+(?:\.\d+)?\b`)
+)
+
+// MaskQuery replaces string and numeric literals in a query with ? so query
+// text can be shown without leaking the values it was run with. Bind
+// placeholders such as $1 are kept.
+func MaskQuery(query string) string {
+	masked := stringLiteral.ReplaceAllString(query, "?")
+	return numericLiteral.ReplaceAllStringFunc(masked, func(match string) string {
+		if match[0] == '$' {
+			return match
+		}
+		return "?"
+	})
+}
+
+// listSlowQueries returns the client queries currently running longer than
+// threshold, longest running first
+func listSlowQueries(ctx context.Context, db *sql.DB, threshold time.Duration) ([]SlowQuery, error) {
+	query := `
+		SELECT
+			pid,
+			COALESCE(usename, ''),
+			COALESCE(application_name, ''),
+			COALESCE(state, ''),
+			query_start,
+			EXTRACT(EPOCH FROM (now() - query_start)) * 1000,
+			query
+		FROM pg_stat_activity
+		WHERE backend_type = 'client backend'
+			AND state = 'active'
+			AND pid <> pg_backend_pid()
+			AND now() - query_start > $1 * interval '1 millisecond'
+		ORDER BY query_start
+	`
+
+	rows, err := db.QueryContext(ctx, query, float64(threshold.Milliseconds()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query pg_stat_activity: %w", err)
+	}
+	defer rows.Close()
+
+	slowQueries := make([]SlowQuery, 0)
+	for rows.Next() {
+		var sq SlowQuery
+		if err := rows.Scan(&sq.PID, &sq.User, &sq.Application, &sq.State, &sq.StartedAt, &sq.DurationMs, &sq.Query); err != nil {
+			return nil, fmt.Errorf("failed to scan slow query: %w", err)
+		}
+		sq.Query = MaskQuery(sq.Query)
+		slowQueries = append(slowQueries, sq)
+	}
+	return slowQueries, rows.Err()
+}
+
+// countSlowStatements counts the statements in pg_stat_statements whose mean
+// execution time exceeds threshold. It fails when the extension is not installed.
+func countSlowStatements(ctx context.Context, db *sql.DB, threshold time.Duration) (int64, error) {
+	query := `SELECT count(*) FROM pg_stat_statements WHERE mean_exec_time > $1`
+	var count int64
+	if err := db.QueryRowContext(ctx, query, float64(threshold.Milliseconds())).Scan(&count); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// CancelBackend cancels the query a backend of a registered database is
+// running, leaving its session open
+func (psc *PostgresStatsCollector) CancelBackend(ctx context.Context, databaseID string, pid int) error {
+	return psc.signalBackend(ctx, databaseID, "pg_cancel_backend", pid)
+}
+
+// TerminateBackend ends the session of a backend of a registered database,
+// rolling back its open transaction
+func (psc *PostgresStatsCollector) TerminateBackend(ctx context.Context, databaseID string, pid int) error {
+	return psc.signalBackend(ctx, databaseID, "pg_terminate_backend", pid)
+}
+
+// signalBackend calls function, pg_cancel_backend or pg_terminate_backend,
+// for pid. Both report false rather than failing when pid is not a backend.
+func (psc *PostgresStatsCollector) signalBackend(ctx context.Context, databaseID, function string, pid int) error {
+	if pid <= 0 {
+		return fmt.Errorf("%w: invalid pid %d", ErrBackendNotFound, pid)
+	}
+	db, err := psc.database(databaseID)
+	if err != nil {
+		return err
+	}
+
+	var signalled bool
+	if err := db.QueryRowContext(ctx, "SELECT "+function+"($1)", pid).Scan(&signalled); err != nil {
+		return fmt.Errorf("failed to call %s: %w", function, err)
+	}
+	if !signalled {
+		return fmt.Errorf("%w: pid %d", ErrBackendNotFound, pid)
+	}
+	return nil
+}
@@ -0,0 +1,352 @@
+package monitoring
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.uber.org/zap/zaptest"
+)
+
+// fakeResult is the canned answer for queries containing match
+type fakeResult struct {
+	match   string
+	columns []string
+	rows    [][]driver.Value
+	err     error
+}
+
+// fakeStatsDriver answers queries with canned results registered per DSN
+type fakeStatsDriver struct {
+	mu       sync.Mutex
+	results  map[string][]fakeResult
+	args     map[string][]driver.NamedValue
+	executed map[string][]string
+	queried  map[string][]string
+	down     map[string]bool
+	stalled  map[string]chan struct{}
+}
+
+var testStatsDriver = &fakeStatsDriver{
+	results:  make(map[string][]fakeResult),
+	args:     make(map[string][]driver.NamedValue),
+	executed: make(map[string][]string),
+	queried:  make(map[string][]string),
+	down:     make(map[string]bool),
+	stalled:  make(map[string]chan struct{}),
+}
+
+func init() {
+	sql.Register("monitoringtest", testStatsDriver)
+}
+
+// open registers canned results under a DSN unique to the test and opens it
+func (d *fakeStatsDriver) open(t *testing.T, results ...fakeResult) *sql.DB {
+	t.Helper()
+	dsn := t.Name()
+	d.mu.Lock()
+	d.results[dsn] = results
+	d.executed[dsn] = nil
+	d.queried[dsn] = nil
+	d.mu.Unlock()
+
+	db, err := sql.Open("monitoringtest", dsn)
+	if err != nil {
+		t.Fatalf("failed to open fake database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// lastArgs returns the arguments of the last query containing match
+func (d *fakeStatsDriver) lastArgs(dsn, match string) []driver.NamedValue {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.args[dsn+"\x00"+match]
+}
+
+// statements returns the statements executed against a DSN
+func (d *fakeStatsDriver) statements(dsn string) []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]string(nil), d.executed[dsn]...)
+}
+
+// queries returns the queries run against a DSN
+func (d *fakeStatsDriver) queries(dsn string) []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]string(nil), d.queried[dsn]...)
+}
+
+// setDown makes new connections to a DSN fail, or succeed again
+func (d *fakeStatsDriver) setDown(dsn string, down bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.down[dsn] = down
+}
+
+// stall holds new connections to a DSN until the returned release is called
+func (d *fakeStatsDriver) stall(dsn string) (release func()) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	stalled := make(chan struct{})
+	d.stalled[dsn] = stalled
+	return func() {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		delete(d.stalled, dsn)
+		close(stalled)
+	}
+}
+
+func (d *fakeStatsDriver) Open(dsn string) (driver.Conn, error) {
+	d.mu.Lock()
+	down := d.down[dsn]
+	stalled := d.stalled[dsn]
+	d.mu.Unlock()
+	if stalled != nil {
+		<-stalled
+	}
+	if down {
+		return nil, fmt.Errorf("connection refused: %s", dsn)
+	}
+	return &fakeStatsConn{driver: d, dsn: dsn}, nil
+}
+
+type fakeStatsConn struct {
+	driver *fakeStatsDriver
+	dsn    string
+}
+
+func (c *fakeStatsConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("prepare not supported")
+}
+
+func (c *fakeStatsConn) Close() error { return nil }
+
+func (c *fakeStatsConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("transactions not supported")
+}
+
+func (c *fakeStatsConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.driver.mu.Lock()
+	defer c.driver.mu.Unlock()
+	c.driver.executed[c.dsn] = append(c.driver.executed[c.dsn], query)
+	return driver.RowsAffected(0), nil
+}
+
+func (c *fakeStatsConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	c.driver.mu.Lock()
+	defer c.driver.mu.Unlock()
+	c.driver.queried[c.dsn] = append(c.driver.queried[c.dsn], query)
+
+	for _, result := range c.driver.results[c.dsn] {
+		if !strings.Contains(query, result.match) {
+			continue
+		}
+		c.driver.args[c.dsn+"\x00"+result.match] = args
+		if result.err != nil {
+			return nil, result.err
+		}
+		return &fakeStatsRows{columns: result.columns, rows: result.rows}, nil
+	}
+	return nil, fmt.Errorf("unexpected query: %s", query)
+}
+
+type fakeStatsRows struct {
+	columns []string
+	rows    [][]driver.Value
+	next    int
+}
+
+func (r *fakeStatsRows) Columns() []string { return r.columns }
+
+func (r *fakeStatsRows) Close() error { return nil }
+
+func (r *fakeStatsRows) Next(dest []driver.Value) error {
+	if r.next >= len(r.rows) {
+		return io.EOF
+	}
+	copy(dest, r.rows[r.next])
+	r.next++
+	return nil
+}
+
+var activityColumns = []string{"pid", "usename", "application_name", "state", "query_start", "duration_ms", "query"}
+
+func longRunningQuery(started time.Time) fakeResult {
+	return fakeResult{
+		match:   "FROM pg_stat_activity",
+		columns: activityColumns,
+		rows: [][]driver.Value{
+			{int64(4242), "app", "billing", "active", started, float64(93000), "SELECT * FROM invoices WHERE email = 'a@b.com' AND total > 100 AND id = $1"},
+		},
+	}
+}
+
+func TestMaskQuery(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"SELECT * FROM users WHERE email = 'bob@example.com'", "SELECT * FROM users WHERE email = ?"},
+		{"SELECT * FROM t1 WHERE id = 42 AND score > 1.5", "SELECT * FROM t1 WHERE id = ? AND score > ?"},
+		{"UPDATE users SET name = 'O''Brien' WHERE id = $1", "UPDATE users SET name = ? WHERE id = $1"},
+	}
+	for _, tt := range tests {
+		if got := MaskQuery(tt.query); got != tt.want {
+			t.Errorf("MaskQuery(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestPostgresStatsCollector_GetSlowQueries(t *testing.T) {
+	started := time.Now().Add(-93 * time.Second)
+	db := testStatsDriver.open(t, longRunningQuery(started))
+
+	psc := NewPostgresStatsCollector(zaptest.NewLogger(t), time.Minute)
+	psc.SetSlowQueryThreshold(5 * time.Second)
+	psc.databases["shard1"] = &DBConnection{DatabaseID: "shard1", DB: db}
+
+	slowQueries, err := psc.GetSlowQueries(context.Background(), "shard1", 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(slowQueries) != 1 {
+		t.Fatalf("expected 1 slow query, got %d", len(slowQueries))
+	}
+
+	sq := slowQueries[0]
+	if sq.PID != 4242 || sq.Application != "billing" || sq.DurationMs != 93000 || !sq.StartedAt.Equal(started) {
+		t.Errorf("unexpected slow query %+v", sq)
+	}
+	if want := "SELECT * FROM invoices WHERE email = ? AND total > ? AND id = $1"; sq.Query != want {
+		t.Errorf("expected masked query %q, got %q", want, sq.Query)
+	}
+
+	// The configured threshold is passed to PostgreSQL in milliseconds
+	args := testStatsDriver.lastArgs(t.Name(), "FROM pg_stat_activity")
+	if len(args) != 1 || args[0].Value != float64(5000) {
+		t.Errorf("expected a 5000ms threshold, got %+v", args)
+	}
+
+	if _, err := psc.GetSlowQueries(context.Background(), "missing", 0); err == nil {
+		t.Error("expected an error for an unregistered database")
+	}
+}
+
+func TestPostgresStatsCollector_CollectQueryStatsCountsSlowQueries(t *testing.T) {
+	db := testStatsDriver.open(t,
+		fakeResult{match: "FROM pg_stat_database", columns: []string{"total_queries", "cache_hit_ratio"}, rows: [][]driver.Value{{int64(1000), float64(0.99)}}},
+		longRunningQuery(time.Now().Add(-time.Minute)),
+		fakeResult{match: "FROM pg_stat_statements", err: errors.New(`relation "pg_stat_statements" does not exist`)},
+	)
+
+	psc := NewPostgresStatsCollector(zaptest.NewLogger(t), time.Minute)
+	stats := &PostgresStats{}
+	if err := psc.collectQueryStats(context.Background(), db, stats); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if stats.Queries.SlowQueries != 1 {
+		t.Errorf("expected 1 slow query, got %d", stats.Queries.SlowQueries)
+	}
+	if stats.Queries.SlowStatements != 0 {
+		t.Errorf("expected no slow statements without pg_stat_statements, got %d", stats.Queries.SlowStatements)
+	}
+}
+
+func TestPostgresStatsCollector_CollectQueryStatsKeepsStatsWhenSlowQueriesFail(t *testing.T) {
+	db := testStatsDriver.open(t,
+		fakeResult{match: "FROM pg_stat_database", columns: []string{"total_queries", "cache_hit_ratio"}, rows: [][]driver.Value{{int64(1000), float64(0.99)}}},
+		fakeResult{match: "FROM pg_stat_activity", err: errors.New("permission denied for view pg_stat_activity")},
+		fakeResult{match: "FROM pg_stat_statements", columns: []string{"count"}, rows: [][]driver.Value{{int64(2)}}},
+	)
+
+	psc := NewPostgresStatsCollector(zaptest.NewLogger(t), time.Minute)
+	stats := &PostgresStats{}
+	if err := psc.collectQueryStats(context.Background(), db, stats); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if stats.Queries.TotalQueries != 1000 {
+		t.Errorf("expected 1000 total queries, got %d", stats.Queries.TotalQueries)
+	}
+	if stats.Queries.SlowStatements != 2 {
+		t.Errorf("expected 2 slow statements, got %d", stats.Queries.SlowStatements)
+	}
+}
+
+func TestPrometheusCollector_SlowQueriesGauge(t *testing.T) {
+	db := testStatsDriver.open(t,
+		longRunningQuery(time.Now().Add(-time.Minute)),
+		fakeResult{match: "FROM pg_stat_statements", columns: []string{"count"}, rows: [][]driver.Value{{int64(3)}}},
+	)
+
+	pc := NewPrometheusCollector(zaptest.NewLogger(t), time.Minute)
+	sc := &ShardCollector{shardID: "shard1", db: db, logger: zaptest.NewLogger(t), slowQueryThreshold: time.Second}
+
+	metrics := &ShardDetailedMetrics{}
+	if err := sc.collectSlowQueryStats(context.Background(), metrics); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	pc.updateMetrics("shard1", "default", metrics)
+
+	if got := testutil.ToFloat64(pc.shardSlowQueries.WithLabelValues("shard1", "default", "activity")); got != 1 {
+		t.Errorf("expected 1 running slow query, got %v", got)
+	}
+	if got := testutil.ToFloat64(pc.shardSlowQueries.WithLabelValues("shard1", "default", "statements")); got != 3 {
+		t.Errorf("expected 3 slow statements, got %v", got)
+	}
+}
+
+func TestPostgresStatsCollector_SignalBackend(t *testing.T) {
+	db := testStatsDriver.open(t,
+		fakeResult{match: "pg_cancel_backend", columns: []string{"pg_cancel_backend"}, rows: [][]driver.Value{{true}}},
+		fakeResult{match: "pg_terminate_backend", columns: []string{"pg_terminate_backend"}, rows: [][]driver.Value{{true}}},
+	)
+	psc := NewPostgresStatsCollector(zaptest.NewLogger(t), time.Minute)
+	psc.databases["shard1"] = &DBConnection{DatabaseID: "shard1", DB: db}
+
+	if err := psc.CancelBackend(context.Background(), "shard1", 4321); err != nil {
+		t.Fatalf("unexpected cancel error: %v", err)
+	}
+	if args := testStatsDriver.lastArgs(t.Name(), "pg_cancel_backend"); len(args) != 1 || args[0].Value != int64(4321) {
+		t.Errorf("expected pg_cancel_backend to be called with pid 4321, got %v", args)
+	}
+	if args := testStatsDriver.lastArgs(t.Name(), "pg_terminate_backend"); args != nil {
+		t.Errorf("expected cancelling not to terminate, got %v", args)
+	}
+
+	if err := psc.TerminateBackend(context.Background(), "shard1", 8765); err != nil {
+		t.Fatalf("unexpected terminate error: %v", err)
+	}
+	if args := testStatsDriver.lastArgs(t.Name(), "pg_terminate_backend"); len(args) != 1 || args[0].Value != int64(8765) {
+		t.Errorf("expected pg_terminate_backend to be called with pid 8765, got %v", args)
+	}
+}
+
+func TestPostgresStatsCollector_SignalBackendNotFound(t *testing.T) {
+	db := testStatsDriver.open(t,
+		fakeResult{match: "pg_terminate_backend", columns: []string{"pg_terminate_backend"}, rows: [][]driver.Value{{false}}},
+	)
+	psc := NewPostgresStatsCollector(zaptest.NewLogger(t), time.Minute)
+	psc.databases["shard1"] = &DBConnection{DatabaseID: "shard1", DB: db}
+
+	if err := psc.TerminateBackend(context.Background(), "shard1", 99); !errors.Is(err, ErrBackendNotFound) {
+		t.Errorf("expected ErrBackendNotFound for a pid that is not a backend, got %v", err)
+	}
+	if err := psc.TerminateBackend(context.Background(), "shard1", 0); !errors.Is(err, ErrBackendNotFound) {
+		t.Errorf("expected ErrBackendNotFound for an invalid pid, got %v", err)
+	}
+	if err := psc.CancelBackend(context.Background(), "missing", 99); !errors.Is(err, ErrDatabaseNotRegistered) {
+		t.Errorf("expected ErrDatabaseNotRegistered, got %v", err)
+	}
+}
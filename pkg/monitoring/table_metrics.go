@@ -0,0 +1,78 @@
+package monitoring
+
+import (
+	"sort"
+	"strings"
+)
+
+// DefaultMaxTableSeries is how many tables of a database get their own
+// postgres_table_rows series by default
+const DefaultMaxTableSeries = 50
+
+// othersTableSeries is the table_name of the series summing the rows of the
+// tables without their own
+const othersTableSeries = "others"
+
+// SetMaxTableSeries caps how many tables of each database get their own
+// postgres_table_rows series. Non-positive values restore the default.
+func (pc *PrometheusCollector) SetMaxTableSeries(max int) {
+	if max <= 0 {
+		max = DefaultMaxTableSeries
+	}
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	pc.maxTableSeries = max
+}
+
+// RecordPostgresTables records the row counts of all of a database's tables.
+// The largest tables, up to the cap, get their own series and the rest are
+// summed into table_name="others". Series of tables that were dropped or fell
+// out of the largest are deleted.
+func (pc *PrometheusCollector) RecordPostgresTables(clusterID, clusterName, namespace, databaseName, databaseHost string, tableRows map[string]int64) {
+	labels := []string{clusterID, clusterName, namespace, databaseName, databaseHost}
+
+	tables := make([]string, 0, len(tableRows))
+	for table := range tableRows {
+		tables = append(tables, table)
+	}
+	sort.Slice(tables, func(i, j int) bool {
+		if tableRows[tables[i]] != tableRows[tables[j]] {
+			return tableRows[tables[i]] > tableRows[tables[j]]
+		}
+		return tables[i] < tables[j]
+	})
+
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+
+	kept := tables
+	if len(kept) > pc.maxTableSeries {
+		kept = tables[:pc.maxTableSeries]
+	}
+	exported := make(map[string]bool, len(kept)+1)
+	for _, table := range kept {
+		pc.postgresTableRows.WithLabelValues(append(labels, table)...).Set(float64(tableRows[table]))
+		exported[table] = true
+	}
+	if rest := tables[len(kept):]; len(rest) > 0 {
+		var others int64
+		for _, table := range rest {
+			others += tableRows[table]
+		}
+		pc.postgresTableRows.WithLabelValues(append(labels, othersTableSeries)...).Set(float64(others))
+		exported[othersTableSeries] = true
+	}
+
+	key := tableSeriesKey(labels)
+	for table := range pc.tableSeries[key] {
+		if !exported[table] {
+			pc.postgresTableRows.DeleteLabelValues(append(labels, table)...)
+		}
+	}
+	pc.tableSeries[key] = exported
+}
+
+// tableSeriesKey identifies a database by its metric labels
+func tableSeriesKey(labels []string) string {
+	return strings.Join(labels, "\x00")
+}
@@ -0,0 +1,59 @@
+package monitoring
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestPrometheusCollector_RecordPostgresTables_CapsSeries(t *testing.T) {
+	pc := NewPrometheusCollector(zaptest.NewLogger(t), time.Minute)
+	pc.SetMaxTableSeries(10)
+
+	tables := make(map[string]int64, 2000)
+	var smallRows int64
+	for i := 0; i < 2000; i++ {
+		rows := int64(i)
+		tables[fmt.Sprintf("table_%04d", i)] = rows
+		if i < 1990 {
+			smallRows += rows
+		}
+	}
+	pc.RecordPostgresTables("c1", "cluster", "default", "orders", "db1", tables)
+
+	if got := testutil.CollectAndCount(pc.postgresTableRows); got != 11 {
+		t.Fatalf("expected 10 table series and an others series, got %d", got)
+	}
+	if got := testutil.ToFloat64(pc.postgresTableRows.WithLabelValues("c1", "cluster", "default", "orders", "db1", "table_1999")); got != 1999 {
+		t.Errorf("expected the largest table to keep its series, got %v rows", got)
+	}
+	if got := testutil.ToFloat64(pc.postgresTableRows.WithLabelValues("c1", "cluster", "default", "orders", "db1", "others")); got != float64(smallRows) {
+		t.Errorf("expected others to sum the remaining %d rows, got %v", smallRows, got)
+	}
+
+	// Another database gets its own budget
+	pc.RecordPostgresTables("c1", "cluster", "default", "billing", "db2", map[string]int64{"invoices": 5})
+	if got := testutil.CollectAndCount(pc.postgresTableRows); got != 12 {
+		t.Errorf("expected 12 series across both databases, got %d", got)
+	}
+}
+
+func TestPrometheusCollector_RecordPostgresTables_DropsStaleSeries(t *testing.T) {
+	pc := NewPrometheusCollector(zaptest.NewLogger(t), time.Minute)
+	pc.SetMaxTableSeries(2)
+
+	pc.RecordPostgresTables("c1", "cluster", "default", "orders", "db1", map[string]int64{"a": 30, "b": 20, "c": 10})
+	pc.RecordPostgresTables("c1", "cluster", "default", "orders", "db1", map[string]int64{"b": 20, "d": 40})
+
+	if got := testutil.CollectAndCount(pc.postgresTableRows); got != 2 {
+		t.Fatalf("expected only the series of b and d to remain, got %d", got)
+	}
+	for _, table := range []string{"a", "c", "others"} {
+		if pc.postgresTableRows.DeleteLabelValues("c1", "cluster", "default", "orders", "db1", table) {
+			t.Errorf("expected the series of %s to have been deleted", table)
+		}
+	}
+}
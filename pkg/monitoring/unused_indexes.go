@@ -0,0 +1,164 @@
+package monitoring
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrIndexNotFound is returned when dropping an index that does not exist or has been used
+	ErrIndexNotFound = errors.New("unused index not found")
+	// ErrIndexNotDroppable is returned when dropping an index that backs a primary key or constraint
+	ErrIndexNotDroppable = errors.New("index backs a primary key or constraint")
+)
+
+// UnusedIndex is an index that has not been scanned since statistics were last reset
+type UnusedIndex struct {
+	Schema     string     `json:"schema"`
+	Table      string     `json:"table"`
+	Name       string     `json:"name"`
+	SizeBytes  int64      `json:"size_bytes"`
+	IndexScans int64      `json:"index_scans"`
+	LastScan   *time.Time `json:"last_scan,omitempty"` // Reported by PostgreSQL 16 and later
+	IsPrimary  bool       `json:"is_primary"`
+	IsUnique   bool       `json:"is_unique"`
+	Constraint string     `json:"constraint,omitempty"` // Constraint the index enforces, if any
+	Definition string     `json:"definition"`
+	Droppable  bool       `json:"droppable"`
+	Reason     string     `json:"reason,omitempty"` // Why the index may not be dropped
+}
+
+// UnusedIndexReport lists the unused indexes of a database, largest first
+type UnusedIndexReport struct {
+	DatabaseID     string        `json:"database_id"`
+	Count          int           `json:"count"`
+	TotalSizeBytes int64         `json:"total_size_bytes"`
+	Indexes        []UnusedIndex `json:"indexes"`
+}
+
+// listUnusedIndexes returns the indexes with zero scans, largest first. Indexes
+// behind primary keys, unique indexes and constraints are reported but marked
+// as not droppable, since they enforce data integrity rather than serve reads.
+func listUnusedIndexes(ctx context.Context, db *sql.DB) ([]UnusedIndex, error) {
+	// last_idx_scan only exists from PostgreSQL 16, so read it through to_jsonb
+	query := `
+		SELECT
+			s.schemaname,
+			s.relname,
+			s.indexrelname,
+			pg_relation_size(s.indexrelid),
+			s.idx_scan,
+			(to_jsonb(s) ->> 'last_idx_scan')::timestamptz,
+			i.indisprimary,
+			i.indisunique,
+			COALESCE((SELECT c.conname FROM pg_constraint c WHERE c.conindid = s.indexrelid LIMIT 1), ''),
+			pg_get_indexdef(s.indexrelid)
+		FROM pg_stat_user_indexes s
+		JOIN pg_index i ON i.indexrelid = s.indexrelid
+		WHERE s.idx_scan = 0
+		ORDER BY pg_relation_size(s.indexrelid) DESC, s.schemaname, s.indexrelname
+	`
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query unused indexes: %w", err)
+	}
+	defer rows.Close()
+
+	indexes := make([]UnusedIndex, 0)
+	for rows.Next() {
+		var idx UnusedIndex
+		var lastScan sql.NullTime
+		if err := rows.Scan(&idx.Schema, &idx.Table, &idx.Name, &idx.SizeBytes, &idx.IndexScans, &lastScan,
+			&idx.IsPrimary, &idx.IsUnique, &idx.Constraint, &idx.Definition); err != nil {
+			return nil, fmt.Errorf("failed to scan unused index: %w", err)
+		}
+		if lastScan.Valid {
+			idx.LastScan = &lastScan.Time
+		}
+
+		switch {
+		case idx.IsPrimary:
+			idx.Reason = "backs the primary key"
+		case idx.Constraint != "":
+			idx.Reason = fmt.Sprintf("backs constraint %s", idx.Constraint)
+		case idx.IsUnique:
+			idx.Reason = "enforces uniqueness"
+		default:
+			idx.Droppable = true
+		}
+		indexes = append(indexes, idx)
+	}
+	return indexes, rows.Err()
+}
+
+// GetUnusedIndexes reports the indexes of a registered database that have
+// never been scanned
+func (psc *PostgresStatsCollector) GetUnusedIndexes(ctx context.Context, databaseID string) (*UnusedIndexReport, error) {
+	db, err := psc.database(databaseID)
+	if err != nil {
+		return nil, err
+	}
+
+	indexes, err := listUnusedIndexes(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &UnusedIndexReport{
+		DatabaseID: databaseID,
+		Count:      len(indexes),
+		Indexes:    indexes,
+	}
+	for _, idx := range indexes {
+		report.TotalSizeBytes += idx.SizeBytes
+	}
+	return report, nil
+}
+
+// DropUnusedIndex drops an index after checking that it is still unused and
+// does not back a primary key, unique index or constraint. The index is
+// dropped concurrently so writes to the table are not blocked.
+func (psc *PostgresStatsCollector) DropUnusedIndex(ctx context.Context, databaseID, schema, name string) (*UnusedIndex, error) {
+	db, err := psc.database(databaseID)
+	if err != nil {
+		return nil, err
+	}
+
+	indexes, err := listUnusedIndexes(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	var target *UnusedIndex
+	for i := range indexes {
+		if indexes[i].Schema == schema && indexes[i].Name == name {
+			target = &indexes[i]
+			break
+		}
+	}
+	if target == nil {
+		return nil, fmt.Errorf("%w: %s.%s", ErrIndexNotFound, schema, name)
+	}
+	if !target.Droppable {
+		return nil, fmt.Errorf("%w: %s.%s %s", ErrIndexNotDroppable, schema, name, target.Reason)
+	}
+
+	stmt := fmt.Sprintf("DROP INDEX CONCURRENTLY IF EXISTS %s.%s", pq.QuoteIdentifier(schema), pq.QuoteIdentifier(name))
+	if _, err := db.ExecContext(ctx, stmt); err != nil {
+		return nil, fmt.Errorf("failed to drop index %s.%s: %w", schema, name, err)
+	}
+
+	psc.logger.Info("dropped unused index",
+		zap.String("database_id", databaseID),
+		zap.String("schema", schema),
+		zap.String("index", name),
+		zap.String("table", target.Table),
+		zap.Int64("size_bytes", target.SizeBytes))
+	return target, nil
+}
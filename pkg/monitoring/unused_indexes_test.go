@@ -0,0 +1,93 @@
+package monitoring
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zaptest"
+)
+
+func unusedIndexes() fakeResult {
+	lastScan := time.Now().Add(-90 * 24 * time.Hour)
+	return fakeResult{
+		match: "FROM pg_stat_user_indexes",
+		columns: []string{"schemaname", "relname", "indexrelname", "size", "idx_scan", "last_idx_scan",
+			"indisprimary", "indisunique", "conname", "indexdef"},
+		rows: [][]driver.Value{
+			{"public", "orders", "idx_orders_note", int64(81920), int64(0), lastScan, false, false, "", "CREATE INDEX idx_orders_note ON public.orders USING btree (note)"},
+			{"public", "orders", "orders_pkey", int64(40960), int64(0), nil, true, true, "orders_pkey", "CREATE UNIQUE INDEX orders_pkey ON public.orders USING btree (id)"},
+			{"public", "orders", "orders_ref_key", int64(16384), int64(0), nil, false, true, "orders_ref_key", "CREATE UNIQUE INDEX orders_ref_key ON public.orders USING btree (ref)"},
+		},
+	}
+}
+
+func newUnusedIndexCollector(t *testing.T) *PostgresStatsCollector {
+	t.Helper()
+	db := testStatsDriver.open(t, unusedIndexes())
+	psc := NewPostgresStatsCollector(zaptest.NewLogger(t), time.Minute)
+	psc.databases["shard1"] = &DBConnection{DatabaseID: "shard1", DB: db}
+	return psc
+}
+
+func TestPostgresStatsCollector_GetUnusedIndexes(t *testing.T) {
+	psc := newUnusedIndexCollector(t)
+
+	report, err := psc.GetUnusedIndexes(context.Background(), "shard1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if report.Count != 3 || report.TotalSizeBytes != 81920+40960+16384 {
+		t.Fatalf("unexpected report totals: count %d, size %d", report.Count, report.TotalSizeBytes)
+	}
+
+	note, pkey, unique := report.Indexes[0], report.Indexes[1], report.Indexes[2]
+	if note.Name != "idx_orders_note" || !note.Droppable || note.LastScan == nil {
+		t.Errorf("expected idx_orders_note to be droppable with a last scan, got %+v", note)
+	}
+	if pkey.Droppable || pkey.Reason == "" || pkey.LastScan != nil {
+		t.Errorf("expected the primary key index to be kept, got %+v", pkey)
+	}
+	if unique.Droppable || unique.Constraint != "orders_ref_key" {
+		t.Errorf("expected the unique constraint index to be kept, got %+v", unique)
+	}
+}
+
+func TestPostgresStatsCollector_DropUnusedIndex(t *testing.T) {
+	psc := newUnusedIndexCollector(t)
+
+	dropped, err := psc.DropUnusedIndex(context.Background(), "shard1", "public", "idx_orders_note")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if dropped.Table != "orders" {
+		t.Errorf("expected the dropped index to be reported, got %+v", dropped)
+	}
+
+	executed := testStatsDriver.statements(t.Name())
+	want := `DROP INDEX CONCURRENTLY IF EXISTS "public"."idx_orders_note"`
+	if len(executed) != 1 || executed[0] != want {
+		t.Errorf("expected %q, got %v", want, executed)
+	}
+}
+
+func TestPostgresStatsCollector_DropUnusedIndexRefusesConstraintIndexes(t *testing.T) {
+	psc := newUnusedIndexCollector(t)
+
+	for _, name := range []string{"orders_pkey", "orders_ref_key"} {
+		_, err := psc.DropUnusedIndex(context.Background(), "shard1", "public", name)
+		if !errors.Is(err, ErrIndexNotDroppable) {
+			t.Errorf("expected %s to be refused, got %v", name, err)
+		}
+	}
+
+	if _, err := psc.DropUnusedIndex(context.Background(), "shard1", "public", "idx_orders_busy"); !errors.Is(err, ErrIndexNotFound) {
+		t.Errorf("expected an index that is not unused to be refused, got %v", err)
+	}
+
+	if executed := testStatsDriver.statements(t.Name()); len(executed) != 0 {
+		t.Errorf("expected nothing to be dropped, got %v", executed)
+	}
+}
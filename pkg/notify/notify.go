@@ -0,0 +1,190 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Event types emitted by the services
+const (
+	EventBackup       = "backup"
+	EventBranchCreate = "branch.create"
+	EventBranchDelete = "branch.delete"
+	EventAutoSplit    = "autoscale.split"
+	EventAutoExpand   = "autoscale.expand"
+)
+
+// Event statuses
+const (
+	StatusStarted   = "started"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+// DefaultPagerDutyURL is the PagerDuty Events API v2 endpoint
+const DefaultPagerDutyURL = "https://events.pagerduty.com/v2/enqueue"
+
+// Event is a significant event of a service, e.g. a backup that completed or
+// an automatic split that could not start
+type Event struct {
+	Type     string    `json:"type"`     // One of the Event constants
+	Resource string    `json:"resource"` // ID of the backup, branch or shard the event is about
+	Status   string    `json:"status"`   // One of the Status constants
+	Detail   string    `json:"detail,omitempty"`
+	Time     time.Time `json:"time"`
+}
+
+// Summary describes the event in one line
+func (e Event) Summary() string {
+	summary := fmt.Sprintf("%s %s %s", e.Type, e.Resource, e.Status)
+	if e.Detail != "" {
+		summary += ": " + e.Detail
+	}
+	return summary
+}
+
+// Notifier dispatches service events to an external system
+type Notifier interface {
+	Notify(ctx context.Context, event Event) error
+}
+
+// SendTimeout bounds how long Send waits for a notifier
+const SendTimeout = 30 * time.Second
+
+// Send dispatches an event through n in the background, stamping its time
+// when unset. A nil n drops the event. Failures are logged rather than
+// returned, since a notification must not fail or hold up the operation it
+// reports on.
+func Send(ctx context.Context, n Notifier, event Event, logger *zap.Logger) {
+	if n == nil {
+		return
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	// The operation's context may be cancelled as soon as it returns
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), SendTimeout)
+	go func() {
+		defer cancel()
+		if err := n.Notify(ctx, event); err != nil {
+			logger.Warn("failed to send notification",
+				zap.String("type", event.Type),
+				zap.String("resource", event.Resource),
+				zap.String("status", event.Status),
+				zap.Error(err))
+		}
+	}()
+}
+
+// Multi sends each event to every notifier, returning their errors joined
+type Multi []Notifier
+
+func (m Multi) Notify(ctx context.Context, event Event) error {
+	var errs []error
+	for _, n := range m {
+		if err := n.Notify(ctx, event); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// WebhookNotifier posts each event as JSON to a URL
+type WebhookNotifier struct {
+	url    string
+	client *http.Client
+}
+
+// NewWebhookNotifier creates a notifier posting events to url
+func NewWebhookNotifier(url string) *WebhookNotifier {
+	return &WebhookNotifier{url: url, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (n *WebhookNotifier) Notify(ctx context.Context, event Event) error {
+	return postJSON(ctx, n.client, n.url, event)
+}
+
+// SlackNotifier posts each event's summary to a Slack incoming webhook
+type SlackNotifier struct {
+	webhookURL string
+	client     *http.Client
+}
+
+// NewSlackNotifier creates a notifier posting to a Slack incoming webhook
+func NewSlackNotifier(webhookURL string) *SlackNotifier {
+	return &SlackNotifier{webhookURL: webhookURL, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (n *SlackNotifier) Notify(ctx context.Context, event Event) error {
+	text := event.Summary()
+	if event.Status == StatusFailed {
+		text = ":rotating_light: " + text
+	}
+	return postJSON(ctx, n.client, n.webhookURL, map[string]string{"text": text})
+}
+
+// PagerDutyNotifier triggers a PagerDuty incident for each failed event.
+// Other events are not paged.
+type PagerDutyNotifier struct {
+	routingKey string
+	url        string
+	client     *http.Client
+}
+
+// NewPagerDutyNotifier creates a notifier triggering incidents with the
+// routing key of a PagerDuty Events API v2 integration
+func NewPagerDutyNotifier(routingKey string) *PagerDutyNotifier {
+	return &PagerDutyNotifier{routingKey: routingKey, url: DefaultPagerDutyURL, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (n *PagerDutyNotifier) Notify(ctx context.Context, event Event) error {
+	if event.Status != StatusFailed {
+		return nil
+	}
+	return postJSON(ctx, n.client, n.url, map[string]interface{}{
+		"routing_key":  n.routingKey,
+		"event_action": "trigger",
+		"dedup_key":    event.Type + "/" + event.Resource,
+		"payload": map[string]interface{}{
+			"summary":        event.Summary(),
+			"source":         "sharding-system",
+			"severity":       "error",
+			"component":      event.Type,
+			"timestamp":      event.Time.UTC().Format(time.RFC3339),
+			"custom_details": event,
+		},
+	})
+}
+
+// postJSON posts body as JSON to url and fails on non-2xx responses
+func postJSON(ctx context.Context, client *http.Client, url string, body interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("failed to encode notification: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to create notification request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send notification: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("notification rejected with status %d: %s", resp.StatusCode, bytes.TrimSpace(message))
+	}
+	return nil
+}
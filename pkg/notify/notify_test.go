@@ -0,0 +1,113 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// receiver records the JSON bodies posted to it
+type receiver struct {
+	*httptest.Server
+	bodies []map[string]interface{}
+}
+
+func newReceiver(t *testing.T, status int) *receiver {
+	r := &receiver{}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var body map[string]interface{}
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+			t.Errorf("failed to decode notification: %v", err)
+		}
+		r.bodies = append(r.bodies, body)
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(r.Close)
+	return r
+}
+
+var failedBackup = Event{
+	Type:     EventBackup,
+	Resource: "backup-1",
+	Status:   StatusFailed,
+	Detail:   "upload failed",
+	Time:     time.Date(2026, 10, 16, 2, 0, 0, 0, time.UTC),
+}
+
+func TestNotifiers_Payloads(t *testing.T) {
+	webhook, slack, pagerDuty := newReceiver(t, http.StatusOK), newReceiver(t, http.StatusOK), newReceiver(t, http.StatusAccepted)
+	pd := NewPagerDutyNotifier("routing-key")
+	pd.url = pagerDuty.URL
+	n := Multi{NewWebhookNotifier(webhook.URL), NewSlackNotifier(slack.URL), pd}
+
+	succeeded := failedBackup
+	succeeded.Status, succeeded.Detail = StatusSucceeded, ""
+	for _, event := range []Event{failedBackup, succeeded} {
+		if err := n.Notify(context.Background(), event); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	if len(webhook.bodies) != 2 || webhook.bodies[0]["resource"] != "backup-1" || webhook.bodies[0]["status"] != StatusFailed {
+		t.Errorf("expected the webhook to receive both events, got %v", webhook.bodies)
+	}
+	if len(slack.bodies) != 2 || slack.bodies[1]["text"] != "backup backup-1 succeeded" {
+		t.Errorf("expected Slack to receive both summaries, got %v", slack.bodies)
+	}
+	if text, _ := slack.bodies[0]["text"].(string); !strings.HasSuffix(text, "backup backup-1 failed: upload failed") {
+		t.Errorf("unexpected Slack text %q", text)
+	}
+	if len(pagerDuty.bodies) != 1 {
+		t.Fatalf("expected only the failure to be paged, got %v", pagerDuty.bodies)
+	}
+	if page := pagerDuty.bodies[0]; page["routing_key"] != "routing-key" || page["event_action"] != "trigger" || page["dedup_key"] != "backup/backup-1" {
+		t.Errorf("unexpected PagerDuty event %v", page)
+	}
+}
+
+func TestNotifiers_ReportRejections(t *testing.T) {
+	ok, rejected := newReceiver(t, http.StatusOK), newReceiver(t, http.StatusForbidden)
+	n := Multi{NewWebhookNotifier(rejected.URL), NewWebhookNotifier(ok.URL)}
+
+	err := n.Notify(context.Background(), failedBackup)
+	if err == nil || !strings.Contains(err.Error(), "403") {
+		t.Errorf("expected the rejection to be reported, got %v", err)
+	}
+	if len(ok.bodies) != 1 {
+		t.Error("expected a rejection not to stop the other notifiers")
+	}
+}
+
+// blockingNotifier holds each notification until its context ends
+type blockingNotifier chan error
+
+func (n blockingNotifier) Notify(ctx context.Context, event Event) error {
+	<-ctx.Done()
+	n <- ctx.Err()
+	return ctx.Err()
+}
+
+func TestSend_DoesNotWaitForNotifiers(t *testing.T) {
+	notifier := make(blockingNotifier, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	start := time.Now()
+	Send(ctx, notifier, failedBackup, zap.NewNop())
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("expected Send to return without waiting for the notifier, took %s", elapsed)
+	}
+
+	// Cancelling the operation's context leaves the notification running
+	cancel()
+	select {
+	case err := <-notifier:
+		t.Fatalf("expected the notification to outlive the operation's context, got %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
@@ -41,6 +41,22 @@ var (
 		[]string{"shard_id", "replica"},
 	)
 
+	ReplicaExcluded = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "shard_replica_excluded",
+			Help: "1 while health checks keep a replica out of read routing, 0 otherwise",
+		},
+		[]string{"shard_id", "replica"},
+	)
+
+	ShadowReads = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "router_shadow_reads_total",
+			Help: "Reads mirrored to split targets, by result: match, mismatch, error or dropped",
+		},
+		[]string{"source_shard", "target_shard", "result"},
+	)
+
 	// Resharding metrics
 	ReshardProgress = promauto.NewGaugeVec(
 		prometheus.GaugeOpts{
@@ -58,6 +74,72 @@ var (
 		[]string{"job_id"},
 	)
 
+	ReshardRowsCopied = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "reshard_rows_copied_total",
+			Help: "Rows copied to target shards during resharding",
+		},
+		[]string{"job_id", "source_shard", "target_shard"},
+	)
+
+	ReshardBytesCopied = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "reshard_bytes_copied_total",
+			Help: "Bytes of row data copied to target shards during resharding",
+		},
+		[]string{"job_id", "source_shard", "target_shard"},
+	)
+
+	ReshardCopyErrors = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "reshard_copy_errors_total",
+			Help: "Rows or batches that failed to copy to target shards during resharding",
+		},
+		[]string{"job_id", "source_shard", "target_shard"},
+	)
+
+	ReshardCopyRate = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "reshard_copy_rows_per_second",
+			Help: "Rows copied per second by the current copy from a source shard",
+		},
+		[]string{"job_id", "source_shard"},
+	)
+
+	ReshardPhaseDuration = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "reshard_phase_duration_seconds",
+			Help: "Duration of each completed resharding phase in seconds",
+		},
+		[]string{"job_id", "phase", "source_shard"},
+	)
+
+	// Object storage metrics
+	ObjectStorageOperations = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "object_storage_operations_total",
+			Help: "Object storage operations by backend, operation and status: success or error",
+		},
+		[]string{"backend", "operation", "status"},
+	)
+
+	ObjectStorageDuration = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "object_storage_operation_duration_seconds",
+			Help:    "Duration of object storage operations in seconds",
+			Buckets: prometheus.ExponentialBuckets(0.005, 2, 14),
+		},
+		[]string{"backend", "operation"},
+	)
+
+	ObjectStorageBytes = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "object_storage_bytes_total",
+			Help: "Bytes transferred to and from object storage, by direction: upload or download",
+		},
+		[]string{"backend", "direction"},
+	)
+
 	// Catalog metrics
 	CatalogVersion = promauto.NewGauge(
 		prometheus.GaugeOpts{
@@ -0,0 +1,51 @@
+package operator
+
+import (
+	"context"
+	"fmt"
+
+	policyv1 "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// ShardGroupLabel is set on the pods of a shard's primary and replicas to the
+// shard's name, so they can be selected together
+const ShardGroupLabel = "shard-group"
+
+// podDisruptionBudgetName returns the name of a shard's PodDisruptionBudget
+func podDisruptionBudgetName(shardName string) string {
+	return fmt.Sprintf("%s-pdb", shardName)
+}
+
+// createPodDisruptionBudget limits voluntary disruptions of a shard's pods,
+// such as evictions by node drains, to one pod at a time, so the shard keeps
+// its primary or a replica to promote. The budget caps unavailable pods rather
+// than requiring a count to stay available, so it covers replicas added to the
+// shard later and never blocks draining the node of a shard's only pod.
+func (o *Operator) createPodDisruptionBudget(ctx context.Context, db *ShardedDatabase, shardName string) error {
+	maxUnavailable := intstr.FromInt(1)
+	pdb := &policyv1.PodDisruptionBudget{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      podDisruptionBudgetName(shardName),
+			Namespace: o.namespace,
+			Labels: map[string]string{
+				"app":      "sharding-system",
+				"database": db.Spec.Name,
+				"shard":    shardName,
+			},
+		},
+		Spec: policyv1.PodDisruptionBudgetSpec{
+			MaxUnavailable: &maxUnavailable,
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					"app":           "sharding-system",
+					ShardGroupLabel: shardName,
+				},
+			},
+		},
+	}
+
+	_, err := o.client.PolicyV1().PodDisruptionBudgets(o.namespace).Create(ctx, pdb, metav1.CreateOptions{})
+	return err
+}
@@ -0,0 +1,84 @@
+package operator
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestCreatePodDisruptionBudget(t *testing.T) {
+	o := newZoneTestOperator()
+	db := replicaTestDatabase(ReplicaBootstrapStream)
+
+	// The shard may lose one pod at a time, however many replicas it has
+	if err := o.createPodDisruptionBudget(context.Background(), db, "orders-shard-0"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	pdb, err := o.client.PolicyV1().PodDisruptionBudgets("default").Get(context.Background(), "orders-shard-0-pdb", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("expected a PodDisruptionBudget: %v", err)
+	}
+	if pdb.Spec.MaxUnavailable == nil || pdb.Spec.MaxUnavailable.IntValue() != 1 {
+		t.Errorf("expected maxUnavailable 1, got %v", pdb.Spec.MaxUnavailable)
+	}
+	if pdb.Spec.MinAvailable != nil {
+		t.Errorf("expected no minAvailable, which would stop covering replicas added later, got %v", pdb.Spec.MinAvailable)
+	}
+	if got := pdb.Spec.Selector.MatchLabels[ShardGroupLabel]; got != "orders-shard-0" {
+		t.Errorf("expected the budget to select the shard's pods, got %v", pdb.Spec.Selector.MatchLabels)
+	}
+
+	if err := o.deleteShard(context.Background(), "orders-shard-0"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := o.client.PolicyV1().PodDisruptionBudgets("default").Get(context.Background(), "orders-shard-0-pdb", metav1.GetOptions{}); err == nil {
+		t.Error("expected the PodDisruptionBudget to be deleted with the shard")
+	}
+}
+
+func TestShardGroupLabel_SelectsPrimaryAndReplicas(t *testing.T) {
+	o := newZoneTestOperator()
+	db := replicaTestDatabase(ReplicaBootstrapStream)
+
+	if err := o.createStatefulSet(context.Background(), db, "orders-shard-0", 0, ""); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := o.createReplica(context.Background(), db, "orders-shard-0", 0, 0, ""); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, name := range []string{"orders-shard-0", "orders-shard-0-replica-0"} {
+		sts, err := o.client.AppsV1().StatefulSets("default").Get(context.Background(), name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("expected StatefulSet %s: %v", name, err)
+		}
+		if got := sts.Spec.Template.Labels[ShardGroupLabel]; got != "orders-shard-0" {
+			t.Errorf("expected the pods of %s in the shard's group, got %q", name, got)
+		}
+	}
+}
+
+func TestZoneResolver_Draining(t *testing.T) {
+	cordoned := zonedNode("node-a", "zone-a")
+	cordoned.Spec.Unschedulable = true
+	pods := []*corev1.Pod{
+		{ObjectMeta: metav1.ObjectMeta{Name: "orders-shard-0-0", Namespace: "default"}, Spec: corev1.PodSpec{NodeName: "node-a"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "orders-shard-1-0", Namespace: "default"}, Spec: corev1.PodSpec{NodeName: "node-b"}},
+	}
+	resolver := NewZoneResolver(fake.NewSimpleClientset(cordoned, zonedNode("node-b", "zone-b"), pods[0], pods[1]), "default")
+
+	for endpoint, want := range map[string]bool{
+		"postgres://admin@orders-shard-0.default.svc.cluster.local:5432/orders": true,
+		"postgres://admin@orders-shard-1.default.svc.cluster.local:5432/orders": false,
+	} {
+		draining, err := resolver.Draining(context.Background(), endpoint)
+		if err != nil {
+			t.Fatalf("Draining(%s): %v", endpoint, err)
+		}
+		if draining != want {
+			t.Errorf("Draining(%s): expected %v, got %v", endpoint, want, draining)
+		}
+	}
+}
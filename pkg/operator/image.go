@@ -0,0 +1,77 @@
+package operator
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// DefaultPostgresRepository is the image shards run when the spec names none
+	DefaultPostgresRepository = "postgres"
+	// DefaultPostgresTag is the tag of DefaultPostgresRepository shards run
+	// when the spec names no image
+	DefaultPostgresTag = "15-alpine"
+
+	// MinPostgresVersion and MaxPostgresVersion bound the major versions the
+	// stats collectors can query: pg_stat_statements.mean_exec_time arrived in
+	// 13, and 17 moved the checkpoint columns out of pg_stat_bgwriter
+	MinPostgresVersion = 13
+	MaxPostgresVersion = 16
+)
+
+// postgresImage returns the image reference shards of the spec run
+func postgresImage(spec ShardedDatabaseSpec) string {
+	if spec.Image.Repository == "" {
+		return DefaultPostgresRepository + ":" + DefaultPostgresTag
+	}
+	tag := spec.Image.Tag
+	if tag == "" {
+		tag = DefaultPostgresTag
+	}
+	return spec.Image.Repository + ":" + tag
+}
+
+// imagePullSecrets returns the pull secrets of the spec's image, if it has one
+func imagePullSecrets(spec ShardedDatabaseSpec) []corev1.LocalObjectReference {
+	if spec.Image.PullSecret == "" {
+		return nil
+	}
+	return []corev1.LocalObjectReference{{Name: spec.Image.PullSecret}}
+}
+
+// ValidateImage checks that an image runs a PostgreSQL version the stats
+// collectors support. The version is the image's Version, or else the
+// major version its tag starts with, e.g. 16 for "16.2-bookworm".
+func ValidateImage(image PostgresImage) error {
+	if image.Repository == "" && (image.Tag != "" || image.Version != 0) {
+		return fmt.Errorf("image tag and version need an image repository")
+	}
+	if strings.ContainsAny(image.Repository, " @") || strings.ContainsAny(image.Tag, " :@/") {
+		return fmt.Errorf("invalid image %q", image.Repository+":"+image.Tag)
+	}
+
+	version := image.Version
+	if version == 0 {
+		tag := image.Tag
+		if tag == "" {
+			tag = DefaultPostgresTag
+		}
+		major := tag
+		if i := strings.IndexAny(tag, ".-"); i >= 0 {
+			major = tag[:i]
+		}
+		v, err := strconv.Atoi(major)
+		if err != nil {
+			return fmt.Errorf("cannot tell the PostgreSQL version of image tag %q; set the image version", tag)
+		}
+		version = v
+	}
+	if version < MinPostgresVersion || version > MaxPostgresVersion {
+		return fmt.Errorf("PostgreSQL %d is not supported, shards run versions %d to %d",
+			version, MinPostgresVersion, MaxPostgresVersion)
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package operator
+
+import (
+	"context"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestCreateStatefulSet_UsesConfiguredImage(t *testing.T) {
+	o := newZoneTestOperator()
+	db := &ShardedDatabase{Spec: ShardedDatabaseSpec{
+		Name:      "orders",
+		Resources: ShardResources{CPU: "250m", Memory: "256Mi"},
+		Storage:   StorageConfig{Size: "10Gi"},
+		Image:     PostgresImage{Repository: "registry.example.com/hardened/postgres", Tag: "16.2", PullSecret: "registry-creds"},
+	}}
+
+	if err := o.createStatefulSet(context.Background(), db, "orders-shard-0", 0, ""); err != nil {
+		t.Fatalf("createStatefulSet: %v", err)
+	}
+	sts, err := o.client.AppsV1().StatefulSets("default").Get(context.Background(), "orders-shard-0", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("get StatefulSet: %v", err)
+	}
+	pod := sts.Spec.Template.Spec
+	if image := pod.Containers[0].Image; image != "registry.example.com/hardened/postgres:16.2" {
+		t.Errorf("expected the configured image, got %s", image)
+	}
+	if len(pod.ImagePullSecrets) != 1 || pod.ImagePullSecrets[0].Name != "registry-creds" {
+		t.Errorf("expected the registry-creds pull secret, got %v", pod.ImagePullSecrets)
+	}
+
+	// Replicas bootstrap with the primary's pg_basebackup and run the same image
+	if _, err := o.createReplica(context.Background(), db, "orders-shard-0", 0, 0, ""); err != nil {
+		t.Fatalf("createReplica: %v", err)
+	}
+	replica, err := o.client.AppsV1().StatefulSets("default").Get(context.Background(), "orders-shard-0-replica-0", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("get replica StatefulSet: %v", err)
+	}
+	pod = replica.Spec.Template.Spec
+	if pod.Containers[0].Image != "registry.example.com/hardened/postgres:16.2" || pod.InitContainers[0].Image != "registry.example.com/hardened/postgres:16.2" {
+		t.Errorf("expected the replica and its bootstrap to run the configured image, got %s and %s", pod.Containers[0].Image, pod.InitContainers[0].Image)
+	}
+	if len(pod.ImagePullSecrets) != 1 || pod.ImagePullSecrets[0].Name != "registry-creds" {
+		t.Errorf("expected the replica to use the pull secret, got %v", pod.ImagePullSecrets)
+	}
+}
+
+func TestCreateStatefulSet_DefaultImage(t *testing.T) {
+	o := newZoneTestOperator()
+	db := &ShardedDatabase{Spec: ShardedDatabaseSpec{Name: "orders", Resources: ShardResources{CPU: "250m", Memory: "256Mi"}}}
+
+	if err := o.createStatefulSet(context.Background(), db, "orders-shard-0", 0, ""); err != nil {
+		t.Fatalf("createStatefulSet: %v", err)
+	}
+	sts, _ := o.client.AppsV1().StatefulSets("default").Get(context.Background(), "orders-shard-0", metav1.GetOptions{})
+	if pod := sts.Spec.Template.Spec; pod.Containers[0].Image != "postgres:15-alpine" || pod.ImagePullSecrets != nil {
+		t.Errorf("expected postgres:15-alpine without pull secrets, got %s and %v", pod.Containers[0].Image, pod.ImagePullSecrets)
+	}
+}
+
+func TestValidateImage(t *testing.T) {
+	valid := []PostgresImage{
+		{},
+		{Repository: "postgres", Tag: "13"},
+		{Repository: "postgres", Tag: "16.2-bookworm"},
+		{Repository: "registry.example.com:5000/postgres"},
+		{Repository: "registry.example.com/hardened-pg", Tag: "2024.06", Version: 14},
+	}
+	for _, image := range valid {
+		if err := ValidateImage(image); err != nil {
+			t.Errorf("expected %+v to be valid, got %v", image, err)
+		}
+	}
+
+	invalid := []PostgresImage{
+		{Repository: "postgres", Tag: "12-alpine"},
+		{Repository: "postgres", Tag: "17"},
+		{Repository: "registry.example.com/hardened-pg", Tag: "latest"},
+		{Repository: "postgres", Tag: "16", Version: 17},
+		{Tag: "16"},
+		{Repository: "postgres", Tag: "16:alpine"},
+	}
+	for _, image := range invalid {
+		if err := ValidateImage(image); err == nil {
+			t.Errorf("expected %+v to be rejected", image)
+		}
+	}
+}
@@ -0,0 +1,362 @@
+package operator
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// DefaultLocalImage is the PostgreSQL image local shards run
+	DefaultLocalImage = "postgres:16-alpine"
+	// LocalPassword is the superuser password of local shards. They are
+	// ephemeral and only listen on the loopback interface.
+	LocalPassword = "postgres"
+)
+
+// ContainerRuntime runs the PostgreSQL instances backing local shards
+type ContainerRuntime interface {
+	// Start runs a PostgreSQL instance with the given database and returns
+	// the address it listens on once it accepts connections
+	Start(ctx context.Context, name, database string) (host string, port int, err error)
+	// Stop stops and removes the instance
+	Stop(ctx context.Context, name string) error
+}
+
+// LocalOperator provisions shards as ephemeral PostgreSQL containers on the
+// local machine, so databases can be created, become ready and be routed to
+// without a Kubernetes cluster
+type LocalOperator struct {
+	runtime   ContainerRuntime
+	logger    *zap.Logger
+	databases map[string]*ShardedDatabase
+	mu        sync.RWMutex
+
+	// Callbacks
+	onShardReady func(dbName string, shard ShardInfo)
+}
+
+// NewLocalOperator creates an operator that runs shards with the given runtime
+func NewLocalOperator(runtime ContainerRuntime, logger *zap.Logger) *LocalOperator {
+	return &LocalOperator{
+		runtime:   runtime,
+		logger:    logger,
+		databases: make(map[string]*ShardedDatabase),
+	}
+}
+
+// SetOnShardReady sets callback for when a shard becomes ready
+func (l *LocalOperator) SetOnShardReady(callback func(dbName string, shard ShardInfo)) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.onShardReady = callback
+}
+
+// CreateShardedDatabase starts a local PostgreSQL instance for each shard
+func (l *LocalOperator) CreateShardedDatabase(ctx context.Context, spec ShardedDatabaseSpec) (*ShardedDatabase, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, exists := l.databases[spec.Name]; exists {
+		return nil, fmt.Errorf("database %s already exists", spec.Name)
+	}
+
+	db := &ShardedDatabase{
+		ObjectMeta: metav1.ObjectMeta{Name: spec.Name},
+		Spec:       spec,
+		Status: ShardedDatabaseStatus{
+			Phase:     "Creating",
+			Shards:    make([]ShardInfo, 0, spec.ShardCount),
+			CreatedAt: time.Now(),
+		},
+	}
+	l.databases[spec.Name] = db
+
+	// Provisioning outlives the request that started it
+	go l.provisionShards(context.WithoutCancel(ctx), db, 0, spec.ShardCount)
+
+	l.logger.Info("started creating local sharded database",
+		zap.String("name", spec.Name),
+		zap.Int("shardCount", spec.ShardCount))
+
+	return l.snapshot(db), nil
+}
+
+// provisionShards starts shards [from, to) and marks the database ready or failed
+func (l *LocalOperator) provisionShards(ctx context.Context, db *ShardedDatabase, from, to int) {
+	var failed []string
+	for i := from; i < to; i++ {
+		if err := l.createShard(ctx, db, i); err != nil {
+			l.logger.Error("failed to create local shard",
+				zap.String("database", db.Spec.Name),
+				zap.Int("index", i),
+				zap.Error(err))
+			failed = append(failed, err.Error())
+		}
+	}
+
+	l.mu.Lock()
+	if len(failed) > 0 {
+		db.Status.Phase = "Failed"
+		db.Status.Message = fmt.Sprintf("failed to create %d shards: %s", len(failed), strings.Join(failed, "; "))
+		started := append([]ShardInfo(nil), db.Status.Shards[from:]...)
+		db.Status.Shards = db.Status.Shards[:from]
+		l.mu.Unlock()
+
+		// Don't leave the shards that did start running. Stopping a container
+		// can take a while, so other callers aren't held up behind the lock.
+		for _, shard := range started {
+			if err := l.runtime.Stop(ctx, shard.PodName); err != nil {
+				l.logger.Warn("failed to stop local shard", zap.String("shard", shard.Name), zap.Error(err))
+			}
+		}
+		return
+	}
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	db.Status.Phase = "Ready"
+	db.Status.ReadyAt = &now
+	db.Status.Message = "All shards ready"
+	if len(db.Status.Shards) > 0 {
+		db.Status.ConnectionString = LocalDSN(db.Status.Shards[0])
+	}
+
+	l.logger.Info("local sharded database ready",
+		zap.String("name", db.Spec.Name),
+		zap.Int("shardCount", len(db.Status.Shards)))
+}
+
+// createShard starts a single local shard
+func (l *LocalOperator) createShard(ctx context.Context, db *ShardedDatabase, index int) error {
+	shardName := fmt.Sprintf("%s-shard-%d", db.Spec.Name, index)
+	// Container names are global on the host, so keep them unique per run
+	containerName := fmt.Sprintf("sharding-%s-%s", shardName, uuid.New().String()[:8])
+
+	host, port, err := l.runtime.Start(ctx, containerName, db.Spec.Name)
+	if err != nil {
+		return fmt.Errorf("failed to start %s: %w", shardName, err)
+	}
+
+	shardInfo := ShardInfo{
+		ID:        uuid.New().String(),
+		Name:      shardName,
+		Host:      host,
+		Port:      port,
+		Database:  db.Spec.Name,
+		Status:    "ready",
+		PodName:   containerName,
+		CreatedAt: time.Now(),
+	}
+
+	l.mu.Lock()
+	db.Status.Shards = append(db.Status.Shards, shardInfo)
+	callback := l.onShardReady
+	l.mu.Unlock()
+
+	if callback != nil {
+		callback(db.Spec.Name, shardInfo)
+	}
+
+	l.logger.Info("local shard created",
+		zap.String("name", shardName),
+		zap.String("container", containerName),
+		zap.String("address", net.JoinHostPort(host, strconv.Itoa(port))))
+	return nil
+}
+
+// GetDatabase retrieves a sharded database by name
+func (l *LocalOperator) GetDatabase(name string) (*ShardedDatabase, bool) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	db, exists := l.databases[name]
+	if !exists {
+		return nil, false
+	}
+	return l.snapshot(db), true
+}
+
+// ListDatabases returns all sharded databases
+func (l *LocalOperator) ListDatabases() []*ShardedDatabase {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	result := make([]*ShardedDatabase, 0, len(l.databases))
+	for _, db := range l.databases {
+		result = append(result, l.snapshot(db))
+	}
+	return result
+}
+
+// snapshot copies a database so callers can read it while shards are added. The caller must hold l.mu.
+func (l *LocalOperator) snapshot(db *ShardedDatabase) *ShardedDatabase {
+	copied := *db
+	copied.Status.Shards = append([]ShardInfo(nil), db.Status.Shards...)
+	return &copied
+}
+
+// DeleteDatabase stops and removes every shard of a database
+func (l *LocalOperator) DeleteDatabase(ctx context.Context, name string) error {
+	l.mu.Lock()
+	db, exists := l.databases[name]
+	if !exists {
+		l.mu.Unlock()
+		return fmt.Errorf("database %s not found", name)
+	}
+	delete(l.databases, name)
+	shards := append([]ShardInfo(nil), db.Status.Shards...)
+	l.mu.Unlock()
+
+	for _, shard := range shards {
+		if err := l.runtime.Stop(ctx, shard.PodName); err != nil {
+			l.logger.Warn("failed to stop local shard", zap.String("shard", shard.Name), zap.Error(err))
+		}
+	}
+
+	l.logger.Info("deleted local sharded database", zap.String("name", name))
+	return nil
+}
+
+// ScaleShards adds shards to a database. Scaling down is not supported, as
+// with the Kubernetes operator it requires migrating data first.
+func (l *LocalOperator) ScaleShards(ctx context.Context, name string, newCount int) error {
+	l.mu.Lock()
+	db, exists := l.databases[name]
+	if !exists {
+		l.mu.Unlock()
+		return fmt.Errorf("database %s not found", name)
+	}
+	currentCount := len(db.Status.Shards)
+	l.mu.Unlock()
+
+	if newCount <= currentCount {
+		if newCount < currentCount {
+			l.logger.Warn("scaling down shards - data migration required",
+				zap.String("database", name),
+				zap.Int("from", currentCount),
+				zap.Int("to", newCount))
+		}
+		return nil
+	}
+
+	for i := currentCount; i < newCount; i++ {
+		if err := l.createShard(ctx, db, i); err != nil {
+			return fmt.Errorf("failed to create shard %d: %w", i, err)
+		}
+	}
+
+	l.mu.Lock()
+	db.Spec.ShardCount = newCount
+	l.mu.Unlock()
+	return nil
+}
+
+// LocalDSN returns the connection string of a local shard
+func LocalDSN(shard ShardInfo) string {
+	return fmt.Sprintf("postgres://postgres:%s@%s/%s?sslmode=disable",
+		LocalPassword, net.JoinHostPort(shard.Host, strconv.Itoa(shard.Port)), shard.Database)
+}
+
+// DockerRuntime runs local shards as docker containers published on a random
+// loopback port. Containers are started with --rm so stopping removes them.
+type DockerRuntime struct {
+	image  string
+	logger *zap.Logger
+}
+
+// NewDockerRuntime creates a docker runtime for the given image, DefaultLocalImage if empty
+func NewDockerRuntime(image string, logger *zap.Logger) *DockerRuntime {
+	if image == "" {
+		image = DefaultLocalImage
+	}
+	return &DockerRuntime{image: image, logger: logger}
+}
+
+// Start implements ContainerRuntime
+func (d *DockerRuntime) Start(ctx context.Context, name, database string) (string, int, error) {
+	if _, err := docker(ctx, "run", "-d", "--rm", "--name", name,
+		"-e", "POSTGRES_PASSWORD="+LocalPassword,
+		"-e", "POSTGRES_DB="+database,
+		"-p", "127.0.0.1::5432",
+		d.image); err != nil {
+		return "", 0, err
+	}
+
+	out, err := docker(ctx, "port", name, "5432/tcp")
+	if err != nil {
+		d.Stop(ctx, name)
+		return "", 0, err
+	}
+	host, port, err := parsePublishedPort(out)
+	if err != nil {
+		d.Stop(ctx, name)
+		return "", 0, err
+	}
+
+	if err := d.waitReady(ctx, name, database); err != nil {
+		d.Stop(ctx, name)
+		return "", 0, err
+	}
+	return host, port, nil
+}
+
+// waitReady waits for the instance to accept connections
+func (d *DockerRuntime) waitReady(ctx context.Context, name, database string) error {
+	timeout := time.After(time.Minute)
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		if _, err := docker(ctx, "exec", name, "pg_isready", "-U", "postgres", "-d", database); err == nil {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timeout:
+			return fmt.Errorf("timeout waiting for container %s to accept connections", name)
+		case <-ticker.C:
+		}
+	}
+}
+
+// Stop implements ContainerRuntime
+func (d *DockerRuntime) Stop(ctx context.Context, name string) error {
+	_, err := docker(ctx, "rm", "-f", name)
+	return err
+}
+
+// docker runs a docker CLI command and returns its output
+func docker(ctx context.Context, args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "docker", args...)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("docker %s failed: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
+
+// parsePublishedPort parses `docker port` output such as "127.0.0.1:49153"
+func parsePublishedPort(out string) (string, int, error) {
+	line := strings.TrimSpace(strings.SplitN(strings.TrimSpace(out), "\n", 2)[0])
+	host, portStr, err := net.SplitHostPort(line)
+	if err != nil {
+		return "", 0, fmt.Errorf("unexpected docker port output %q: %w", out, err)
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return "", 0, fmt.Errorf("unexpected docker port output %q: %w", out, err)
+	}
+	return host, port, nil
+}
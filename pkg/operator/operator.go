@@ -10,6 +10,7 @@ import (
 	"go.uber.org/zap"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
@@ -18,6 +19,18 @@ import (
 	"k8s.io/client-go/tools/clientcmd"
 )
 
+// Provisioner creates and manages the PostgreSQL instances backing sharded
+// databases. Operator provisions them in Kubernetes; LocalOperator runs them
+// as local containers for development and CI.
+type Provisioner interface {
+	SetOnShardReady(callback func(dbName string, shard ShardInfo))
+	CreateShardedDatabase(ctx context.Context, spec ShardedDatabaseSpec) (*ShardedDatabase, error)
+	GetDatabase(name string) (*ShardedDatabase, bool)
+	ListDatabases() []*ShardedDatabase
+	DeleteDatabase(ctx context.Context, name string) error
+	ScaleShards(ctx context.Context, name string, newCount int) error
+}
+
 // Operator manages automatic PostgreSQL shard provisioning
 type Operator struct {
 	client    kubernetes.Interface
@@ -26,6 +39,12 @@ type Operator struct {
 	databases map[string]*ShardedDatabase
 	mu        sync.RWMutex
 
+	// requireExpansion refuses StorageClasses that do not allow volume expansion
+	requireExpansion bool
+
+	// rolloutPoll is how often rollouts are checked; 0 means every 5 seconds
+	rolloutPoll time.Duration
+
 	// Callbacks
 	onShardReady func(dbName string, shard ShardInfo)
 }
@@ -72,6 +91,26 @@ func (o *Operator) SetOnShardReady(callback func(dbName string, shard ShardInfo)
 
 // CreateShardedDatabase creates a new sharded database with automatic provisioning
 func (o *Operator) CreateShardedDatabase(ctx context.Context, spec ShardedDatabaseSpec) (*ShardedDatabase, error) {
+	if err := validateReplication(spec); err != nil {
+		return nil, err
+	}
+	if err := ValidateImage(spec.Image); err != nil {
+		return nil, err
+	}
+	if err := validateSchemaBootstrap(spec); err != nil {
+		return nil, err
+	}
+	expandable, err := o.checkStorageClass(ctx, spec.Storage, storageVolumes(spec))
+	if err != nil {
+		return nil, err
+	}
+
+	// Refuse placements that break the zone policy before creating anything
+	zones, err := o.checkZoneSpread(ctx, spec)
+	if err != nil {
+		return nil, err
+	}
+
 	o.mu.Lock()
 	defer o.mu.Unlock()
 
@@ -91,13 +130,15 @@ func (o *Operator) CreateShardedDatabase(ctx context.Context, spec ShardedDataba
 			Shards:        make([]ShardInfo, 0, spec.ShardCount),
 			CreatedAt:     time.Now(),
 			SchemaVersion: 0,
+			// Shards of a StorageClass that cannot grow are split instead
+			ExpansionDisabled: !expandable,
 		},
 	}
 
 	o.databases[spec.Name] = db
 
 	// Create shards asynchronously
-	go o.provisionShards(ctx, db)
+	go o.provisionShards(ctx, db, zones)
 
 	o.logger.Info("started creating sharded database",
 		zap.String("name", spec.Name),
@@ -107,7 +148,7 @@ func (o *Operator) CreateShardedDatabase(ctx context.Context, spec ShardedDataba
 }
 
 // provisionShards creates all PostgreSQL shards for a database
-func (o *Operator) provisionShards(ctx context.Context, db *ShardedDatabase) {
+func (o *Operator) provisionShards(ctx context.Context, db *ShardedDatabase, zones []string) {
 	var wg sync.WaitGroup
 	errors := make(chan error, db.Spec.ShardCount)
 
@@ -115,7 +156,7 @@ func (o *Operator) provisionShards(ctx context.Context, db *ShardedDatabase) {
 		wg.Add(1)
 		go func(shardIndex int) {
 			defer wg.Done()
-			if err := o.createShard(ctx, db, shardIndex); err != nil {
+			if err := o.createShard(ctx, db, shardIndex, zones); err != nil {
 				errors <- err
 			}
 		}(i)
@@ -156,12 +197,27 @@ func (o *Operator) provisionShards(ctx context.Context, db *ShardedDatabase) {
 }
 
 // createShard creates a single PostgreSQL shard
-func (o *Operator) createShard(ctx context.Context, db *ShardedDatabase, index int) error {
+func (o *Operator) createShard(ctx context.Context, db *ShardedDatabase, index int, zones []string) error {
 	shardName := fmt.Sprintf("%s-shard-%d", db.Spec.Name, index)
 	shardID := uuid.New().String()
 
 	o.logger.Info("creating shard", zap.String("name", shardName), zap.Int("index", index))
 
+	// Pick failure domains for the primary and its replicas
+	replicaCount := 0
+	if db.Spec.Replication.Enabled {
+		replicaCount = db.Spec.Replication.Replicas
+	}
+	zone, replicaZones, err := planShardZones(zones, index, replicaCount, db.Spec.Replication.ZonePolicy)
+	if err != nil {
+		return fmt.Errorf("failed to plan zones: %w", err)
+	}
+	if replicaCount > 0 && len(zones) < 2 {
+		o.logger.Warn("replicas share a failure domain with the primary",
+			zap.String("name", shardName),
+			zap.Strings("zones", zones))
+	}
+
 	// Create PVC for persistent storage
 	if err := o.createPVC(ctx, db, shardName); err != nil {
 		return fmt.Errorf("failed to create PVC: %w", err)
@@ -173,8 +229,15 @@ func (o *Operator) createShard(ctx context.Context, db *ShardedDatabase, index i
 		return fmt.Errorf("failed to create secret: %w", err)
 	}
 
+	// Create ConfigMap for the initial schema PostgreSQL runs on first start
+	if bootstrapsSchemaOnInit(db.Spec) {
+		if err := o.createSchemaConfigMap(ctx, db, shardName); err != nil {
+			return fmt.Errorf("failed to create schema ConfigMap: %w", err)
+		}
+	}
+
 	// Create StatefulSet for PostgreSQL
-	if err := o.createStatefulSet(ctx, db, shardName, index); err != nil {
+	if err := o.createStatefulSet(ctx, db, shardName, index, zone); err != nil {
 		return fmt.Errorf("failed to create StatefulSet: %w", err)
 	}
 
@@ -188,13 +251,32 @@ func (o *Operator) createShard(ctx context.Context, db *ShardedDatabase, index i
 		return fmt.Errorf("pod failed to become ready: %w", err)
 	}
 
-	// Apply initial schema if provided
-	if db.Spec.Schema != "" {
+	// Apply initial schema if provided and not already applied on first start
+	if db.Spec.Schema != "" && !bootstrapsSchemaOnInit(db.Spec) {
 		if err := o.applySchema(ctx, db, shardName, db.Spec.Schema); err != nil {
 			o.logger.Warn("failed to apply initial schema", zap.Error(err))
 		}
 	}
 
+	// Add replicas once the primary they copy from is up
+	replicas := make([]ReplicaInfo, 0, len(replicaZones))
+	for i := 0; i < replicaCount; i++ {
+		replicaZone := ""
+		if i < len(replicaZones) {
+			replicaZone = replicaZones[i]
+		}
+		replica, err := o.createReplica(ctx, db, shardName, index, i, replicaZone)
+		if err != nil {
+			return fmt.Errorf("failed to create replica %d: %w", i, err)
+		}
+		replicas = append(replicas, replica)
+	}
+
+	// Keep node drains from evicting the primary and its replicas together
+	if err := o.createPodDisruptionBudget(ctx, db, shardName); err != nil {
+		return fmt.Errorf("failed to create PodDisruptionBudget: %w", err)
+	}
+
 	// Record shard info
 	shardInfo := ShardInfo{
 		ID:        shardID,
@@ -206,6 +288,10 @@ func (o *Operator) createShard(ctx context.Context, db *ShardedDatabase, index i
 		PodName:   fmt.Sprintf("%s-0", shardName),
 		PVCName:   fmt.Sprintf("data-%s-0", shardName),
 		CreatedAt: time.Now(),
+
+		Zone:         zone,
+		ReplicaZones: replicaZones,
+		Replicas:     replicas,
 	}
 
 	o.mu.Lock()
@@ -283,7 +369,18 @@ func (o *Operator) createSecret(ctx context.Context, db *ShardedDatabase, shardN
 }
 
 // createStatefulSet creates a StatefulSet for PostgreSQL
-func (o *Operator) createStatefulSet(ctx context.Context, db *ShardedDatabase, shardName string, index int) error {
+func (o *Operator) createStatefulSet(ctx context.Context, db *ShardedDatabase, shardName string, index int, zone string) error {
+	sts := o.postgresStatefulSet(db, shardName, fmt.Sprintf("%s-credentials", shardName), index, zone)
+	if bootstrapsSchemaOnInit(db.Spec) {
+		mountInitSchema(&sts.Spec.Template.Spec, shardName)
+	}
+	_, err := o.client.AppsV1().StatefulSets(o.namespace).Create(ctx, sts, metav1.CreateOptions{})
+	return err
+}
+
+// postgresStatefulSet builds a single-pod PostgreSQL StatefulSet named name,
+// with its data on the PVC data-<name>
+func (o *Operator) postgresStatefulSet(db *ShardedDatabase, name, credentialsSecret string, index int, zone string) *appsv1.StatefulSet {
 	replicas := int32(1)
 
 	cpuLimit, _ := resource.ParseQuantity(db.Spec.Resources.CPU)
@@ -291,40 +388,43 @@ func (o *Operator) createStatefulSet(ctx context.Context, db *ShardedDatabase, s
 
 	sts := &appsv1.StatefulSet{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      shardName,
+			Name:      name,
 			Namespace: o.namespace,
 			Labels: map[string]string{
 				"app":         "sharding-system",
 				"component":   "postgresql",
 				"database":    db.Spec.Name,
-				"shard":       shardName,
+				"shard":       name,
 				"shard-index": fmt.Sprintf("%d", index),
 			},
 		},
 		Spec: appsv1.StatefulSetSpec{
-			ServiceName: shardName,
+			ServiceName: name,
 			Replicas:    &replicas,
 			Selector: &metav1.LabelSelector{
 				MatchLabels: map[string]string{
 					"app":   "sharding-system",
-					"shard": shardName,
+					"shard": name,
 				},
 			},
 			Template: corev1.PodTemplateSpec{
 				ObjectMeta: metav1.ObjectMeta{
 					Labels: map[string]string{
-						"app":         "sharding-system",
-						"component":   "postgresql",
-						"database":    db.Spec.Name,
-						"shard":       shardName,
-						"shard-index": fmt.Sprintf("%d", index),
+						"app":           "sharding-system",
+						"component":     "postgresql",
+						"database":      db.Spec.Name,
+						"shard":         name,
+						"shard-index":   fmt.Sprintf("%d", index),
+						ShardGroupLabel: name,
 					},
 				},
 				Spec: corev1.PodSpec{
+					Affinity:         zoneAffinity(zone),
+					ImagePullSecrets: imagePullSecrets(db.Spec),
 					Containers: []corev1.Container{
 						{
 							Name:  "postgresql",
-							Image: "postgres:15-alpine",
+							Image: postgresImage(db.Spec),
 							Ports: []corev1.ContainerPort{
 								{
 									Name:          "postgresql",
@@ -335,7 +435,7 @@ func (o *Operator) createStatefulSet(ctx context.Context, db *ShardedDatabase, s
 								{
 									SecretRef: &corev1.SecretEnvSource{
 										LocalObjectReference: corev1.LocalObjectReference{
-											Name: fmt.Sprintf("%s-credentials", shardName),
+											Name: credentialsSecret,
 										},
 									},
 								},
@@ -389,7 +489,7 @@ func (o *Operator) createStatefulSet(ctx context.Context, db *ShardedDatabase, s
 							Name: "data",
 							VolumeSource: corev1.VolumeSource{
 								PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
-									ClaimName: fmt.Sprintf("data-%s", shardName),
+									ClaimName: fmt.Sprintf("data-%s", name),
 								},
 							},
 						},
@@ -399,8 +499,7 @@ func (o *Operator) createStatefulSet(ctx context.Context, db *ShardedDatabase, s
 		},
 	}
 
-	_, err := o.client.AppsV1().StatefulSets(o.namespace).Create(ctx, sts, metav1.CreateOptions{})
-	return err
+	return sts
 }
 
 // createService creates a headless Service for the shard
@@ -512,6 +611,9 @@ func (o *Operator) DeleteDatabase(ctx context.Context, name string) error {
 
 	// Delete all shards
 	for _, shard := range db.Status.Shards {
+		for _, replica := range shard.Replicas {
+			o.deleteReplica(ctx, replica.Name)
+		}
 		if err := o.deleteShard(ctx, shard.Name); err != nil {
 			o.logger.Warn("failed to delete shard", zap.String("shard", shard.Name), zap.Error(err))
 		}
@@ -539,6 +641,18 @@ func (o *Operator) deleteShard(ctx context.Context, shardName string) error {
 		o.logger.Warn("failed to delete Secret", zap.String("name", secretName), zap.Error(err))
 	}
 
+	// Delete schema ConfigMap, which only shards bootstrapped on first start have
+	cmName := schemaConfigMapName(shardName)
+	if err := o.client.CoreV1().ConfigMaps(o.namespace).Delete(ctx, cmName, metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+		o.logger.Warn("failed to delete ConfigMap", zap.String("name", cmName), zap.Error(err))
+	}
+
+	// Delete PodDisruptionBudget
+	pdbName := podDisruptionBudgetName(shardName)
+	if err := o.client.PolicyV1().PodDisruptionBudgets(o.namespace).Delete(ctx, pdbName, metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+		o.logger.Warn("failed to delete PodDisruptionBudget", zap.String("name", pdbName), zap.Error(err))
+	}
+
 	// Delete PVC
 	pvcName := fmt.Sprintf("data-%s", shardName)
 	if err := o.client.CoreV1().PersistentVolumeClaims(o.namespace).Delete(ctx, pvcName, metav1.DeleteOptions{}); err != nil {
@@ -548,6 +662,20 @@ func (o *Operator) deleteShard(ctx context.Context, shardName string) error {
 	return nil
 }
 
+// deleteReplica deletes a shard replica and its resources
+func (o *Operator) deleteReplica(ctx context.Context, replicaName string) {
+	if err := o.client.AppsV1().StatefulSets(o.namespace).Delete(ctx, replicaName, metav1.DeleteOptions{}); err != nil {
+		o.logger.Warn("failed to delete StatefulSet", zap.String("name", replicaName), zap.Error(err))
+	}
+	if err := o.client.CoreV1().Services(o.namespace).Delete(ctx, replicaName, metav1.DeleteOptions{}); err != nil {
+		o.logger.Warn("failed to delete Service", zap.String("name", replicaName), zap.Error(err))
+	}
+	pvcName := fmt.Sprintf("data-%s", replicaName)
+	if err := o.client.CoreV1().PersistentVolumeClaims(o.namespace).Delete(ctx, pvcName, metav1.DeleteOptions{}); err != nil {
+		o.logger.Warn("failed to delete PVC", zap.String("name", pvcName), zap.Error(err))
+	}
+}
+
 // ScaleShards adds or removes shards from a database
 func (o *Operator) ScaleShards(ctx context.Context, name string, newCount int) error {
 	o.mu.Lock()
@@ -565,9 +693,19 @@ func (o *Operator) ScaleShards(ctx context.Context, name string, newCount int) e
 	}
 
 	if newCount > currentCount {
+		added := db.Spec
+		added.ShardCount = newCount - currentCount
+		if _, err := o.checkStorageClass(ctx, db.Spec.Storage, storageVolumes(added)); err != nil {
+			return err
+		}
+		zones, err := o.checkZoneSpread(ctx, db.Spec)
+		if err != nil {
+			return err
+		}
+
 		// Scale up - add new shards
 		for i := currentCount; i < newCount; i++ {
-			if err := o.createShard(ctx, db, i); err != nil {
+			if err := o.createShard(ctx, db, i, zones); err != nil {
 				return fmt.Errorf("failed to create shard %d: %w", i, err)
 			}
 		}
@@ -0,0 +1,99 @@
+package operator
+
+import (
+	"context"
+	"fmt"
+
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ReplicaBootstrapStream copies a new replica's data from the primary with
+// pg_basebackup. It is the only bootstrap: backups are logical dumps, which
+// cannot seed a physical standby.
+const ReplicaBootstrapStream = "stream"
+
+// pgDataDir is where the PostgreSQL image keeps its data directory
+const pgDataDir = "/var/lib/postgresql/data"
+
+// validateReplication checks the replica bootstrap settings of a spec
+func validateReplication(spec ShardedDatabaseSpec) error {
+	switch spec.Replication.Bootstrap {
+	case "", ReplicaBootstrapStream:
+	case "backup":
+		return fmt.Errorf("replica bootstrap from backup is not supported: backups are logical dumps and cannot seed a streaming replica")
+	default:
+		return fmt.Errorf("invalid replica bootstrap %q", spec.Replication.Bootstrap)
+	}
+	return nil
+}
+
+// createReplica creates a read replica of a shard's primary, seeded with a
+// base backup streamed from the primary
+func (o *Operator) createReplica(ctx context.Context, db *ShardedDatabase, shardName string, index, replica int, zone string) (ReplicaInfo, error) {
+	replicaName := fmt.Sprintf("%s-replica-%d", shardName, replica)
+	primaryHost := fmt.Sprintf("%s.%s.svc.cluster.local", shardName, o.namespace)
+	info := ReplicaInfo{
+		Name:      replicaName,
+		Host:      fmt.Sprintf("%s.%s.svc.cluster.local", replicaName, o.namespace),
+		PodName:   fmt.Sprintf("%s-0", replicaName),
+		PVCName:   fmt.Sprintf("data-%s", replicaName),
+		Zone:      zone,
+		Bootstrap: ReplicaBootstrapStream,
+	}
+
+	if err := o.createPVC(ctx, db, replicaName); err != nil {
+		return info, fmt.Errorf("failed to create PVC: %w", err)
+	}
+
+	// Replicas log in to the primary with the shard's credentials
+	credentials := fmt.Sprintf("%s-credentials", shardName)
+	sts := o.postgresStatefulSet(db, replicaName, credentials, index, zone)
+	sts.Labels["role"] = "replica"
+	sts.Spec.Template.Labels["role"] = "replica"
+	sts.Spec.Template.Labels[ShardGroupLabel] = shardName
+	// -R writes standby.signal and primary_conninfo, so the replica follows the primary
+	sts.Spec.Template.Spec.InitContainers = []corev1.Container{
+		bootstrapContainer("base-backup", postgresImage(db.Spec), credentials, fmt.Sprintf(
+			`[ -s "$PGDATA/PG_VERSION" ] || PGPASSWORD="$POSTGRES_PASSWORD" pg_basebackup -h %s -U "$POSTGRES_USER" -D "$PGDATA" -X stream -R`,
+			primaryHost)),
+	}
+
+	if _, err := o.client.AppsV1().StatefulSets(o.namespace).Create(ctx, sts, metav1.CreateOptions{}); err != nil {
+		return info, fmt.Errorf("failed to create StatefulSet: %w", err)
+	}
+	if err := o.createService(ctx, db, replicaName); err != nil {
+		return info, fmt.Errorf("failed to create service: %w", err)
+	}
+
+	o.logger.Info("created shard replica",
+		zap.String("name", replicaName),
+		zap.String("bootstrap", info.Bootstrap))
+	return info, nil
+}
+
+// bootstrapContainer builds an init container that fills an empty replica
+// data directory. The script skips its work once the data is in place, so
+// pod restarts leave the replica alone.
+func bootstrapContainer(name, image, credentialsSecret, script string) corev1.Container {
+	return corev1.Container{
+		Name:    name,
+		Image:   image,
+		Command: []string{"sh", "-c", script},
+		Env:     []corev1.EnvVar{{Name: "PGDATA", Value: pgDataDir}},
+		EnvFrom: []corev1.EnvFromSource{
+			{
+				SecretRef: &corev1.SecretEnvSource{
+					LocalObjectReference: corev1.LocalObjectReference{Name: credentialsSecret},
+				},
+			},
+		},
+		VolumeMounts: []corev1.VolumeMount{
+			{
+				Name:      "data",
+				MountPath: pgDataDir,
+			},
+		},
+	}
+}
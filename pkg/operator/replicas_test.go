@@ -0,0 +1,69 @@
+package operator
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func replicaTestDatabase(bootstrap string) *ShardedDatabase {
+	return &ShardedDatabase{Spec: ShardedDatabaseSpec{
+		Name:        "orders",
+		Resources:   ShardResources{CPU: "500m", Memory: "1Gi"},
+		Storage:     StorageConfig{Size: "10Gi"},
+		Replication: ReplicationConfig{Enabled: true, Replicas: 1, Bootstrap: bootstrap},
+	}}
+}
+
+func TestCreateReplica_StreamsFromPrimary(t *testing.T) {
+	o := newZoneTestOperator()
+
+	replica, err := o.createReplica(context.Background(), replicaTestDatabase(""), "orders-shard-0", 0, 0, "zone-b")
+	if err != nil {
+		t.Fatalf("createReplica: %v", err)
+	}
+	if replica.Bootstrap != ReplicaBootstrapStream {
+		t.Errorf("expected bootstrap from the primary, got %+v", replica)
+	}
+
+	// The recorded PVC is the one the replica's pod mounts
+	if _, err := o.client.CoreV1().PersistentVolumeClaims("default").Get(context.Background(), replica.PVCName, metav1.GetOptions{}); err != nil {
+		t.Errorf("expected the recorded PVC %s to exist: %v", replica.PVCName, err)
+	}
+	sts, err := o.client.AppsV1().StatefulSets("default").Get(context.Background(), "orders-shard-0-replica-0", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("expected the replica's StatefulSet: %v", err)
+	}
+	podSpec := sts.Spec.Template.Spec
+	if claim := podSpec.Volumes[0].PersistentVolumeClaim; claim == nil || claim.ClaimName != replica.PVCName {
+		t.Errorf("expected the pod to mount %s, got %+v", replica.PVCName, podSpec.Volumes[0])
+	}
+
+	if len(podSpec.InitContainers) != 1 || podSpec.InitContainers[0].Name != "base-backup" {
+		t.Fatalf("expected a base backup init container, got %+v", podSpec.InitContainers)
+	}
+	script := podSpec.InitContainers[0].Command[2]
+	for _, want := range []string{"pg_basebackup -h orders-shard-0.default.svc.cluster.local", "-X stream -R"} {
+		if !strings.Contains(script, want) {
+			t.Errorf("expected %q in the bootstrap script %q", want, script)
+		}
+	}
+}
+
+func TestValidateReplication(t *testing.T) {
+	for _, bootstrap := range []string{"", ReplicaBootstrapStream} {
+		if err := validateReplication(ShardedDatabaseSpec{Replication: ReplicationConfig{Bootstrap: bootstrap}}); err != nil {
+			t.Errorf("expected bootstrap %q to be valid, got %v", bootstrap, err)
+		}
+	}
+
+	err := validateReplication(ShardedDatabaseSpec{Replication: ReplicationConfig{Bootstrap: "backup"}})
+	if err == nil || !strings.Contains(err.Error(), "not supported") {
+		t.Errorf("expected bootstrap from backup to be refused, got %v", err)
+	}
+	if err := validateReplication(ShardedDatabaseSpec{Replication: ReplicationConfig{Bootstrap: "snapshot"}}); err == nil {
+		t.Error("expected an unknown bootstrap to be rejected")
+	}
+}
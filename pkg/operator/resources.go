@@ -0,0 +1,186 @@
+package operator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// postgresContainer is the name of the PostgreSQL container of shard pods
+const postgresContainer = "postgresql"
+
+// rolloutTimeout bounds how long a StatefulSet's pods may take to roll
+const rolloutTimeout = 10 * time.Minute
+
+// ErrInvalidResources is returned for CPU or memory that is not a quantity
+// such as 500m or 2Gi, or when neither is given
+var ErrInvalidResources = errors.New("invalid shard resources")
+
+// ResourceUpdate describes a change of a shard's CPU and memory
+type ResourceUpdate struct {
+	ShardID      string         `json:"shardId"`
+	Database     string         `json:"database"`
+	Previous     ShardResources `json:"previous"`
+	Resources    ShardResources `json:"resources"`
+	StatefulSets []string       `json:"statefulSets"` // The StatefulSets of the replicas and primary that were updated
+}
+
+// UpdateShardResources changes the CPU and memory of a running shard's
+// primary and replicas in place. Requests and limits are both set to the new
+// values, as at creation; an empty CPU or memory keeps the current one. The
+// StatefulSets roll their pods to apply it one at a time, replicas before the
+// primary: each rollout has to finish with every pod ready before the next
+// StatefulSet is updated, so the primary restarts last and only once its
+// replicas are serving again.
+func (o *Operator) UpdateShardResources(ctx context.Context, shardID string, resources ShardResources) (*ResourceUpdate, error) {
+	if resources.CPU == "" && resources.Memory == "" {
+		return nil, fmt.Errorf("%w: cpu or memory is required", ErrInvalidResources)
+	}
+	limits := corev1.ResourceList{}
+	if resources.CPU != "" {
+		cpu, err := resource.ParseQuantity(resources.CPU)
+		if err != nil {
+			return nil, fmt.Errorf("%w: cpu %q", ErrInvalidResources, resources.CPU)
+		}
+		limits[corev1.ResourceCPU] = cpu
+	}
+	if resources.Memory != "" {
+		memory, err := resource.ParseQuantity(resources.Memory)
+		if err != nil {
+			return nil, fmt.Errorf("%w: memory %q", ErrInvalidResources, resources.Memory)
+		}
+		limits[corev1.ResourceMemory] = memory
+	}
+
+	db, shard, err := o.findShard(shardID)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(shard.Replicas)+1)
+	for _, replica := range shard.Replicas {
+		names = append(names, replica.Name)
+	}
+	names = append(names, shard.Name)
+
+	update := &ResourceUpdate{
+		ShardID:      shard.ID,
+		Database:     db.Spec.Name,
+		StatefulSets: make([]string, 0, len(names)),
+	}
+	statefulSets := o.client.AppsV1().StatefulSets(o.namespace)
+	for _, name := range names {
+		sts, err := statefulSets.Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return update, fmt.Errorf("failed to get StatefulSet %s: %w", name, err)
+		}
+		container := findContainer(sts.Spec.Template.Spec.Containers, postgresContainer)
+		if container == nil {
+			return update, fmt.Errorf("StatefulSet %s has no %s container", name, postgresContainer)
+		}
+		if name == shard.Name {
+			update.Previous = containerResources(container)
+		}
+		if container.Resources.Limits == nil {
+			container.Resources.Limits = corev1.ResourceList{}
+		}
+		if container.Resources.Requests == nil {
+			container.Resources.Requests = corev1.ResourceList{}
+		}
+		for resourceName, quantity := range limits {
+			container.Resources.Limits[resourceName] = quantity
+			container.Resources.Requests[resourceName] = quantity
+		}
+
+		// Changing the pod template rolls the StatefulSet's pods
+		updated, err := statefulSets.Update(ctx, sts, metav1.UpdateOptions{})
+		if err != nil {
+			return update, fmt.Errorf("failed to update StatefulSet %s: %w", name, err)
+		}
+		update.StatefulSets = append(update.StatefulSets, name)
+		if name == shard.Name {
+			update.Resources = containerResources(container)
+		}
+		if err := o.waitForRollout(ctx, updated); err != nil {
+			return update, err
+		}
+	}
+
+	o.logger.Info("updated shard resources",
+		zap.String("shard", shard.Name),
+		zap.String("cpu", update.Resources.CPU),
+		zap.String("memory", update.Resources.Memory),
+		zap.Strings("statefulsets", update.StatefulSets))
+	return update, nil
+}
+
+// waitForRollout waits until every pod of a StatefulSet runs its current
+// template and is ready
+func (o *Operator) waitForRollout(ctx context.Context, sts *appsv1.StatefulSet) error {
+	poll := o.rolloutPoll
+	if poll == 0 {
+		poll = 5 * time.Second
+	}
+	timeout := time.After(rolloutTimeout)
+	ticker := time.NewTicker(poll)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("stopped waiting for StatefulSet %s to roll out: %w", sts.Name, ctx.Err())
+		case <-timeout:
+			return fmt.Errorf("timeout waiting for StatefulSet %s to roll out", sts.Name)
+		case <-ticker.C:
+			current, err := o.client.AppsV1().StatefulSets(o.namespace).Get(ctx, sts.Name, metav1.GetOptions{})
+			if err != nil {
+				continue
+			}
+			if rolledOut(current, sts.Generation) {
+				return nil
+			}
+		}
+	}
+}
+
+// rolledOut reports whether a StatefulSet has applied the given generation of
+// its spec to all of its pods and they are ready
+func rolledOut(sts *appsv1.StatefulSet, generation int64) bool {
+	replicas := int32(1)
+	if sts.Spec.Replicas != nil {
+		replicas = *sts.Spec.Replicas
+	}
+	status := sts.Status
+	if status.ObservedGeneration < generation || status.UpdatedReplicas < replicas || status.ReadyReplicas < replicas {
+		return false
+	}
+	return status.UpdateRevision == "" || status.CurrentRevision == status.UpdateRevision
+}
+
+// findContainer returns the container with the given name, or nil
+func findContainer(containers []corev1.Container, name string) *corev1.Container {
+	for i := range containers {
+		if containers[i].Name == name {
+			return &containers[i]
+		}
+	}
+	return nil
+}
+
+// containerResources returns the CPU and memory limits of a container
+func containerResources(container *corev1.Container) ShardResources {
+	var resources ShardResources
+	if cpu, ok := container.Resources.Limits[corev1.ResourceCPU]; ok {
+		resources.CPU = cpu.String()
+	}
+	if memory, ok := container.Resources.Limits[corev1.ResourceMemory]; ok {
+		resources.Memory = memory.String()
+	}
+	return resources
+}
@@ -0,0 +1,129 @@
+package operator
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// newResourcesTestOperator returns an operator running orders-shard-0 with
+// one replica, each with 500m CPU and 1Gi memory. Updated StatefulSets roll
+// out at once, except the stuck ones, whose pods never get ready.
+func newResourcesTestOperator(t *testing.T, stuck ...string) *Operator {
+	t.Helper()
+	o := newZoneTestOperator()
+	o.rolloutPoll = time.Millisecond
+	o.client.(*fake.Clientset).PrependReactor("update", "statefulsets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		sts := action.(k8stesting.UpdateAction).GetObject().(*appsv1.StatefulSet)
+		for _, name := range stuck {
+			if sts.Name == name {
+				return false, nil, nil
+			}
+		}
+		sts.Status = appsv1.StatefulSetStatus{Replicas: 1, ReadyReplicas: 1, UpdatedReplicas: 1}
+		return false, nil, nil
+	})
+	db := &ShardedDatabase{
+		Spec: ShardedDatabaseSpec{Name: "orders", Resources: ShardResources{CPU: "500m", Memory: "1Gi"}},
+		Status: ShardedDatabaseStatus{Shards: []ShardInfo{{
+			ID:       "shard-id-0",
+			Name:     "orders-shard-0",
+			Replicas: []ReplicaInfo{{Name: "orders-shard-0-replica-0"}},
+		}}},
+	}
+	o.databases["orders"] = db
+	for _, name := range []string{"orders-shard-0", "orders-shard-0-replica-0"} {
+		sts := o.postgresStatefulSet(db, name, "orders-shard-0-credentials", 0, "")
+		if _, err := o.client.AppsV1().StatefulSets("default").Create(context.Background(), sts, metav1.CreateOptions{}); err != nil {
+			t.Fatalf("failed to create StatefulSet %s: %v", name, err)
+		}
+	}
+	return o
+}
+
+// postgresResources returns the resources of a StatefulSet's PostgreSQL container
+func postgresResources(t *testing.T, o *Operator, name string) corev1.ResourceRequirements {
+	t.Helper()
+	sts, err := o.client.AppsV1().StatefulSets("default").Get(context.Background(), name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("expected StatefulSet %s: %v", name, err)
+	}
+	return findContainer(sts.Spec.Template.Spec.Containers, postgresContainer).Resources
+}
+
+func TestUpdateShardResources_PatchesStatefulSets(t *testing.T) {
+	o := newResourcesTestOperator(t)
+
+	update, err := o.UpdateShardResources(context.Background(), "shard-id-0", ShardResources{CPU: "2", Memory: "4Gi"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if update.Previous != (ShardResources{CPU: "500m", Memory: "1Gi"}) || update.Resources != (ShardResources{CPU: "2", Memory: "4Gi"}) {
+		t.Errorf("expected the shard to go from 500m/1Gi to 2/4Gi, got %+v", update)
+	}
+	// Replicas restart before the primary
+	if len(update.StatefulSets) != 2 || update.StatefulSets[0] != "orders-shard-0-replica-0" || update.StatefulSets[1] != "orders-shard-0" {
+		t.Errorf("expected the replica and then the primary to be updated, got %v", update.StatefulSets)
+	}
+	for _, name := range update.StatefulSets {
+		resources := postgresResources(t, o, name)
+		for _, list := range []corev1.ResourceList{resources.Limits, resources.Requests} {
+			if cpu, memory := list[corev1.ResourceCPU], list[corev1.ResourceMemory]; cpu.String() != "2" || memory.String() != "4Gi" {
+				t.Errorf("expected %s to run with 2 CPUs and 4Gi, got %s and %s", name, cpu.String(), memory.String())
+			}
+		}
+	}
+}
+
+func TestUpdateShardResources_WaitsForReplicasBeforeThePrimary(t *testing.T) {
+	o := newResourcesTestOperator(t, "orders-shard-0-replica-0")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	update, err := o.UpdateShardResources(ctx, "shard-id-0", ShardResources{CPU: "2"})
+	if err == nil {
+		t.Fatal("expected the update to stop while the replica's pods are not ready")
+	}
+	if len(update.StatefulSets) != 1 || update.StatefulSets[0] != "orders-shard-0-replica-0" {
+		t.Errorf("expected only the replica to be updated, got %v", update.StatefulSets)
+	}
+	if cpu := postgresResources(t, o, "orders-shard-0").Limits[corev1.ResourceCPU]; cpu.String() != "500m" {
+		t.Errorf("expected the primary to be left alone until its replicas are ready, got %s", cpu.String())
+	}
+}
+
+func TestUpdateShardResources_KeepsUnsetResources(t *testing.T) {
+	o := newResourcesTestOperator(t)
+
+	if _, err := o.UpdateShardResources(context.Background(), "orders-shard-0", ShardResources{Memory: "2Gi"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resources := postgresResources(t, o, "orders-shard-0")
+	if cpu, memory := resources.Limits[corev1.ResourceCPU], resources.Limits[corev1.ResourceMemory]; cpu.String() != "500m" || memory.String() != "2Gi" {
+		t.Errorf("expected only the memory to change, got %s and %s", cpu.String(), memory.String())
+	}
+}
+
+func TestUpdateShardResources_Refused(t *testing.T) {
+	o := newResourcesTestOperator(t)
+
+	for _, resources := range []ShardResources{{}, {CPU: "lots"}, {CPU: "1", Memory: "4 GB"}} {
+		if _, err := o.UpdateShardResources(context.Background(), "shard-id-0", resources); !errors.Is(err, ErrInvalidResources) {
+			t.Errorf("expected %+v to be refused, got %v", resources, err)
+		}
+	}
+	if _, err := o.UpdateShardResources(context.Background(), "missing", ShardResources{CPU: "1"}); !errors.Is(err, ErrShardNotFound) {
+		t.Errorf("expected an unknown shard to be refused, got %v", err)
+	}
+	if cpu := postgresResources(t, o, "orders-shard-0").Limits[corev1.ResourceCPU]; cpu.String() != "500m" {
+		t.Errorf("expected refused updates to leave the shard alone, got %s", cpu.String())
+	}
+}
@@ -0,0 +1,84 @@
+package operator
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// SchemaBootstrapJob applies the initial schema once the shard's pod is ready
+	SchemaBootstrapJob = "job"
+	// SchemaBootstrapInitDB mounts the initial schema from a ConfigMap into
+	// the PostgreSQL image's init directory. The image runs it when it
+	// initializes an empty data directory, before the pod is marked ready.
+	SchemaBootstrapInitDB = "initdb"
+
+	// initDBDir is where the PostgreSQL image looks for scripts to run on first start
+	initDBDir = "/docker-entrypoint-initdb.d"
+	// initSchemaFile is the name the initial schema is mounted under
+	initSchemaFile = "schema.sql"
+)
+
+// validateSchemaBootstrap checks the schema bootstrap setting of a spec
+func validateSchemaBootstrap(spec ShardedDatabaseSpec) error {
+	switch spec.SchemaBootstrap {
+	case "", SchemaBootstrapJob, SchemaBootstrapInitDB:
+		return nil
+	default:
+		return fmt.Errorf("invalid schema bootstrap %q", spec.SchemaBootstrap)
+	}
+}
+
+// bootstrapsSchemaOnInit reports whether shards of the spec get their initial
+// schema from the init directory
+func bootstrapsSchemaOnInit(spec ShardedDatabaseSpec) bool {
+	return spec.Schema != "" && spec.SchemaBootstrap == SchemaBootstrapInitDB
+}
+
+// schemaConfigMapName is the name of the ConfigMap holding a shard's initial schema
+func schemaConfigMapName(shardName string) string {
+	return fmt.Sprintf("%s-schema", shardName)
+}
+
+// createSchemaConfigMap creates the ConfigMap holding a shard's initial schema
+func (o *Operator) createSchemaConfigMap(ctx context.Context, db *ShardedDatabase, shardName string) error {
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      schemaConfigMapName(shardName),
+			Namespace: o.namespace,
+			Labels: map[string]string{
+				"app":      "sharding-system",
+				"database": db.Spec.Name,
+				"shard":    shardName,
+			},
+		},
+		Data: map[string]string{
+			initSchemaFile: db.Spec.Schema,
+		},
+	}
+
+	_, err := o.client.CoreV1().ConfigMaps(o.namespace).Create(ctx, cm, metav1.CreateOptions{})
+	return err
+}
+
+// mountInitSchema mounts a shard's schema ConfigMap into the init directory
+// of its PostgreSQL container
+func mountInitSchema(podSpec *corev1.PodSpec, shardName string) {
+	podSpec.Volumes = append(podSpec.Volumes, corev1.Volume{
+		Name: "init-schema",
+		VolumeSource: corev1.VolumeSource{
+			ConfigMap: &corev1.ConfigMapVolumeSource{
+				LocalObjectReference: corev1.LocalObjectReference{Name: schemaConfigMapName(shardName)},
+			},
+		},
+	})
+	container := &podSpec.Containers[0]
+	container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+		Name:      "init-schema",
+		MountPath: initDBDir,
+		ReadOnly:  true,
+	})
+}
@@ -0,0 +1,94 @@
+package operator
+
+import (
+	"context"
+	"testing"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func schemaTestDatabase(bootstrap string) *ShardedDatabase {
+	return &ShardedDatabase{Spec: ShardedDatabaseSpec{
+		Name:            "orders",
+		Resources:       ShardResources{CPU: "250m", Memory: "256Mi"},
+		Schema:          "CREATE TABLE orders (id uuid PRIMARY KEY);",
+		SchemaBootstrap: bootstrap,
+	}}
+}
+
+func TestSchemaBootstrap_InitDBMountsSchemaConfigMap(t *testing.T) {
+	o := newZoneTestOperator()
+	db := schemaTestDatabase(SchemaBootstrapInitDB)
+	ctx := context.Background()
+
+	if err := o.createSchemaConfigMap(ctx, db, "orders-shard-0"); err != nil {
+		t.Fatalf("createSchemaConfigMap: %v", err)
+	}
+	cm, err := o.client.CoreV1().ConfigMaps("default").Get(ctx, "orders-shard-0-schema", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("expected the schema ConfigMap: %v", err)
+	}
+	if cm.Data["schema.sql"] != db.Spec.Schema {
+		t.Errorf("expected the ConfigMap to hold the schema, got %v", cm.Data)
+	}
+
+	if err := o.createStatefulSet(ctx, db, "orders-shard-0", 0, ""); err != nil {
+		t.Fatalf("createStatefulSet: %v", err)
+	}
+	sts, err := o.client.AppsV1().StatefulSets("default").Get(ctx, "orders-shard-0", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("get StatefulSet: %v", err)
+	}
+	pod := sts.Spec.Template.Spec
+	mounted := false
+	for _, mount := range pod.Containers[0].VolumeMounts {
+		if mount.Name == "init-schema" && mount.MountPath == "/docker-entrypoint-initdb.d" && mount.ReadOnly {
+			mounted = true
+		}
+	}
+	if !mounted {
+		t.Errorf("expected the schema to be mounted into the init directory, got %+v", pod.Containers[0].VolumeMounts)
+	}
+	fromConfigMap := false
+	for _, volume := range pod.Volumes {
+		if volume.Name == "init-schema" && volume.ConfigMap != nil && volume.ConfigMap.Name == "orders-shard-0-schema" {
+			fromConfigMap = true
+		}
+	}
+	if !fromConfigMap {
+		t.Errorf("expected an init-schema volume from the schema ConfigMap, got %+v", pod.Volumes)
+	}
+
+	// Deleting the shard removes its schema
+	if err := o.deleteShard(ctx, "orders-shard-0"); err != nil {
+		t.Fatalf("deleteShard: %v", err)
+	}
+	if _, err := o.client.CoreV1().ConfigMaps("default").Get(ctx, "orders-shard-0-schema", metav1.GetOptions{}); !apierrors.IsNotFound(err) {
+		t.Errorf("expected the schema ConfigMap to be deleted, got %v", err)
+	}
+}
+
+func TestSchemaBootstrap_JobMountsNothing(t *testing.T) {
+	o := newZoneTestOperator()
+	ctx := context.Background()
+
+	if err := o.createStatefulSet(ctx, schemaTestDatabase(""), "orders-shard-0", 0, ""); err != nil {
+		t.Fatalf("createStatefulSet: %v", err)
+	}
+	sts, _ := o.client.AppsV1().StatefulSets("default").Get(ctx, "orders-shard-0", metav1.GetOptions{})
+	if pod := sts.Spec.Template.Spec; len(pod.Volumes) != 1 || len(pod.Containers[0].VolumeMounts) != 1 {
+		t.Errorf("expected only the data volume, got %+v", pod.Volumes)
+	}
+}
+
+func TestValidateSchemaBootstrap(t *testing.T) {
+	for _, bootstrap := range []string{"", SchemaBootstrapJob, SchemaBootstrapInitDB} {
+		if err := validateSchemaBootstrap(ShardedDatabaseSpec{SchemaBootstrap: bootstrap}); err != nil {
+			t.Errorf("expected %q to be valid, got %v", bootstrap, err)
+		}
+	}
+	if err := validateSchemaBootstrap(ShardedDatabaseSpec{SchemaBootstrap: "sidecar"}); err == nil {
+		t.Error("expected an unknown schema bootstrap to be rejected")
+	}
+}
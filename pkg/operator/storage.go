@@ -0,0 +1,305 @@
+package operator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// defaultStorageClassAnnotation marks the StorageClass used by PVCs that name none
+const defaultStorageClassAnnotation = "storageclass.kubernetes.io/is-default-class"
+
+// noProvisioner is the provisioner of StorageClasses whose volumes are
+// created by hand rather than on demand, such as local volumes
+const noProvisioner = "kubernetes.io/no-provisioner"
+
+var (
+	// ErrShardNotFound is returned for a shard no database of the operator has
+	ErrShardNotFound = errors.New("shard not found")
+	// ErrInvalidStorageSize is returned for a size that is not a quantity such as 20Gi
+	ErrInvalidStorageSize = errors.New("invalid storage size")
+	// ErrVolumeExpansionNotAllowed is returned when the StorageClass of a
+	// shard's volume does not allow expanding it
+	ErrVolumeExpansionNotAllowed = errors.New("storage class does not allow volume expansion")
+	// ErrStorageShrink is returned for a requested size below the current one
+	ErrStorageShrink = errors.New("shard storage cannot shrink")
+	// ErrStorageAtLimit is returned when a shard cannot grow past the maximum size
+	ErrStorageAtLimit = errors.New("shard storage is at its maximum size")
+	// ErrStorageClassNotFound is returned when a database's StorageClass does
+	// not exist, or it names none and the cluster has no default
+	ErrStorageClassNotFound = errors.New("storage class not found")
+	// ErrNoPersistentVolumes is returned when a StorageClass without a
+	// provisioner has too few available volumes for a database's PVCs
+	ErrNoPersistentVolumes = errors.New("storage class has too few available volumes")
+)
+
+// StorageExpansion describes the growth of a shard's volumes
+type StorageExpansion struct {
+	ShardID      string   `json:"shardId"`
+	Database     string   `json:"database"`
+	PreviousSize string   `json:"previousSize"`
+	Size         string   `json:"size"`
+	PVCs         []string `json:"pvcs"` // The PVCs of the primary and its replicas that were patched
+}
+
+// findShard returns a shard of any database by ID or name
+func (o *Operator) findShard(shardID string) (*ShardedDatabase, ShardInfo, error) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	for _, db := range o.databases {
+		for _, shard := range db.Status.Shards {
+			if shard.ID == shardID || shard.Name == shardID {
+				return db, shard, nil
+			}
+		}
+	}
+	return nil, ShardInfo{}, fmt.Errorf("%w: %s", ErrShardNotFound, shardID)
+}
+
+// shardPVCNames returns the PVCs of a shard's primary and replicas
+func shardPVCNames(shard ShardInfo) []string {
+	names := []string{fmt.Sprintf("data-%s", shard.Name)}
+	for _, replica := range shard.Replicas {
+		names = append(names, fmt.Sprintf("data-%s", replica.Name))
+	}
+	return names
+}
+
+// ShardStorageSize returns the storage requested by a shard's primary volume
+func (o *Operator) ShardStorageSize(ctx context.Context, shardID string) (resource.Quantity, error) {
+	_, shard, err := o.findShard(shardID)
+	if err != nil {
+		return resource.Quantity{}, err
+	}
+	pvc, err := o.client.CoreV1().PersistentVolumeClaims(o.namespace).Get(ctx, shardPVCNames(shard)[0], metav1.GetOptions{})
+	if err != nil {
+		return resource.Quantity{}, fmt.Errorf("failed to get PVC: %w", err)
+	}
+	return pvc.Spec.Resources.Requests[corev1.ResourceStorage], nil
+}
+
+// ExpandShardStorage grows the volumes of a shard's primary and replicas to
+// size, e.g. "20Gi", so a shard nearing its storage limit can grow in place
+// instead of being split. The StorageClass of the volumes must allow
+// expansion; Kubernetes then resizes the volumes and their file systems.
+func (o *Operator) ExpandShardStorage(ctx context.Context, shardID, size string) (*StorageExpansion, error) {
+	target, err := resource.ParseQuantity(size)
+	if err != nil {
+		return nil, fmt.Errorf("%w %q", ErrInvalidStorageSize, size)
+	}
+	db, shard, err := o.findShard(shardID)
+	if err != nil {
+		return nil, err
+	}
+	o.mu.RLock()
+	disabled := db.Status.ExpansionDisabled
+	o.mu.RUnlock()
+	if disabled {
+		return nil, fmt.Errorf("%w: expansion is disabled for database %s", ErrVolumeExpansionNotAllowed, db.Spec.Name)
+	}
+
+	names := shardPVCNames(shard)
+	pvcs := o.client.CoreV1().PersistentVolumeClaims(o.namespace)
+	primary, err := pvcs.Get(ctx, names[0], metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get PVC: %w", err)
+	}
+	current := primary.Spec.Resources.Requests[corev1.ResourceStorage]
+	if target.Cmp(current) < 0 {
+		return nil, fmt.Errorf("%w: %s is below the current %s", ErrStorageShrink, target.String(), current.String())
+	}
+	if err := o.checkVolumeExpansion(ctx, primary.Spec.StorageClassName); err != nil {
+		return nil, err
+	}
+
+	expansion := &StorageExpansion{
+		ShardID:      shard.ID,
+		Database:     db.Spec.Name,
+		PreviousSize: current.String(),
+		Size:         target.String(),
+		PVCs:         make([]string, 0, len(names)),
+	}
+	patch := []byte(fmt.Sprintf(`{"spec":{"resources":{"requests":{"storage":%q}}}}`, target.String()))
+	for _, name := range names {
+		pvc, err := pvcs.Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return expansion, fmt.Errorf("failed to get PVC %s: %w", name, err)
+		}
+		if requested := pvc.Spec.Resources.Requests[corev1.ResourceStorage]; target.Cmp(requested) <= 0 {
+			continue
+		}
+		if _, err := pvcs.Patch(ctx, name, types.MergePatchType, patch, metav1.PatchOptions{}); err != nil {
+			return expansion, fmt.Errorf("failed to expand PVC %s: %w", name, err)
+		}
+		expansion.PVCs = append(expansion.PVCs, name)
+	}
+
+	o.logger.Info("expanded shard storage",
+		zap.String("shard", shard.Name),
+		zap.String("from", expansion.PreviousSize),
+		zap.String("to", expansion.Size),
+		zap.Strings("pvcs", expansion.PVCs))
+	return expansion, nil
+}
+
+// GrowShardStorage grows a shard's volumes by percent of their current size,
+// rounded up to a whole GiB and capped at maxSize unless that is empty
+func (o *Operator) GrowShardStorage(ctx context.Context, shardID string, percent int, maxSize string) (*StorageExpansion, error) {
+	if percent <= 0 {
+		return nil, fmt.Errorf("growth percent must be positive, got %d", percent)
+	}
+	current, err := o.ShardStorageSize(ctx, shardID)
+	if err != nil {
+		return nil, err
+	}
+
+	const gib = int64(1) << 30
+	bytes := current.Value() + current.Value()*int64(percent)/100
+	target := resource.NewQuantity((bytes+gib-1)/gib*gib, resource.BinarySI)
+	if maxSize != "" {
+		limit, err := resource.ParseQuantity(maxSize)
+		if err != nil {
+			return nil, fmt.Errorf("%w %q for the maximum", ErrInvalidStorageSize, maxSize)
+		}
+		if current.Cmp(limit) >= 0 {
+			return nil, fmt.Errorf("%w: %s", ErrStorageAtLimit, limit.String())
+		}
+		if target.Cmp(limit) > 0 {
+			target = &limit
+		}
+	}
+	return o.ExpandShardStorage(ctx, shardID, target.String())
+}
+
+// SetRequireVolumeExpansion makes new databases warn about StorageClasses that
+// do not allow volume expansion, for when shards grow their storage in place.
+// Expansion is then disabled for such a database and its shards are split instead.
+func (o *Operator) SetRequireVolumeExpansion(require bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.requireExpansion = require
+}
+
+// storageClass returns the named StorageClass, or the default one when name is empty
+func (o *Operator) storageClass(ctx context.Context, name string) (*storagev1.StorageClass, error) {
+	classes := o.client.StorageV1().StorageClasses()
+	if name == "" {
+		list, err := classes.List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list storage classes: %w", err)
+		}
+		for i := range list.Items {
+			if list.Items[i].Annotations[defaultStorageClassAnnotation] == "true" {
+				return &list.Items[i], nil
+			}
+		}
+		return nil, fmt.Errorf("%w: none is named and the cluster has no default", ErrStorageClassNotFound)
+	}
+
+	class, err := classes.Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, fmt.Errorf("%w: %s", ErrStorageClassNotFound, name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get storage class %s: %w", name, err)
+	}
+	return class, nil
+}
+
+// allowsExpansion reports whether the volumes of a StorageClass can grow
+func allowsExpansion(class *storagev1.StorageClass) bool {
+	return class.AllowVolumeExpansion != nil && *class.AllowVolumeExpansion
+}
+
+// storageVolumes returns how many PVCs the shards of a spec and their replicas need
+func storageVolumes(spec ShardedDatabaseSpec) int {
+	perShard := 1
+	if spec.Replication.Enabled {
+		perShard += spec.Replication.Replicas
+	}
+	return spec.ShardCount * perShard
+}
+
+// checkStorageClass fails fast, rather than leaving shards pending, when the
+// given number of new PVCs with storage could never bind. The StorageClass,
+// or the default one when storage names none, must exist. A class without a
+// provisioner must have enough available ReadWriteOnce volumes of the
+// requested size, as PVCs can only bind to volumes created ahead of them.
+// It reports whether the class allows the volumes to grow in place.
+func (o *Operator) checkStorageClass(ctx context.Context, storage StorageConfig, volumes int) (bool, error) {
+	class, err := o.storageClass(ctx, storage.StorageClass)
+	if err != nil {
+		return false, err
+	}
+	expandable := allowsExpansion(class)
+	o.mu.RLock()
+	requireExpansion := o.requireExpansion
+	o.mu.RUnlock()
+	if requireExpansion && !expandable {
+		o.logger.Warn("storage class does not allow volume expansion, shards will be split instead of grown",
+			zap.String("storageClass", class.Name))
+	}
+	if class.Provisioner != noProvisioner || volumes == 0 {
+		return expandable, nil
+	}
+
+	size, err := resource.ParseQuantity(storage.Size)
+	if err != nil {
+		return false, fmt.Errorf("%w %q", ErrInvalidStorageSize, storage.Size)
+	}
+	list, err := o.client.CoreV1().PersistentVolumes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return false, fmt.Errorf("failed to list persistent volumes: %w", err)
+	}
+	available := 0
+	for _, pv := range list.Items {
+		capacity := pv.Spec.Capacity[corev1.ResourceStorage]
+		if pv.Spec.StorageClassName == class.Name && pv.Status.Phase == corev1.VolumeAvailable && pv.Spec.ClaimRef == nil &&
+			hasAccessMode(pv.Spec.AccessModes, corev1.ReadWriteOnce) && capacity.Cmp(size) >= 0 {
+			available++
+		}
+	}
+	if available < volumes {
+		return false, fmt.Errorf("%w: %s has %d available ReadWriteOnce volumes of at least %s, the shards need %d",
+			ErrNoPersistentVolumes, class.Name, available, size.String(), volumes)
+	}
+	return expandable, nil
+}
+
+// hasAccessMode reports whether a volume offers an access mode
+func hasAccessMode(modes []corev1.PersistentVolumeAccessMode, mode corev1.PersistentVolumeAccessMode) bool {
+	for _, m := range modes {
+		if m == mode {
+			return true
+		}
+	}
+	return false
+}
+
+// checkVolumeExpansion returns ErrVolumeExpansionNotAllowed unless the named
+// StorageClass, or the default one when className is unset, allows expansion
+func (o *Operator) checkVolumeExpansion(ctx context.Context, className *string) error {
+	name := ""
+	if className != nil {
+		name = *className
+	}
+	class, err := o.storageClass(ctx, name)
+	if name == "" && errors.Is(err, ErrStorageClassNotFound) {
+		return fmt.Errorf("%w: no default storage class", ErrVolumeExpansionNotAllowed)
+	}
+	if err != nil {
+		return err
+	}
+	if !allowsExpansion(class) {
+		return fmt.Errorf("%w: %s", ErrVolumeExpansionNotAllowed, class.Name)
+	}
+	return nil
+}
@@ -0,0 +1,257 @@
+package operator
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func storageClass(name string, expandable, isDefault bool) *storagev1.StorageClass {
+	class := &storagev1.StorageClass{
+		ObjectMeta:           metav1.ObjectMeta{Name: name},
+		Provisioner:          "ebs.csi.aws.com",
+		AllowVolumeExpansion: &expandable,
+	}
+	if isDefault {
+		class.Annotations = map[string]string{defaultStorageClassAnnotation: "true"}
+	}
+	return class
+}
+
+func shardPVC(name, size, className string) *corev1.PersistentVolumeClaim {
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			Resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse(size)},
+			},
+		},
+	}
+	if className != "" {
+		pvc.Spec.StorageClassName = &className
+	}
+	return pvc
+}
+
+// newStorageTestOperator returns an operator knowing a shard orders-shard-0
+// with one replica, whose volumes are of the given StorageClass
+func newStorageTestOperator(className string, objects ...runtime.Object) *Operator {
+	objects = append(objects,
+		shardPVC("data-orders-shard-0", "10Gi", className),
+		shardPVC("data-orders-shard-0-replica-0", "10Gi", className))
+	o := newZoneTestOperator(objects...)
+	o.databases["orders"] = &ShardedDatabase{
+		Spec: ShardedDatabaseSpec{Name: "orders"},
+		Status: ShardedDatabaseStatus{Shards: []ShardInfo{{
+			ID:       "shard-id-0",
+			Name:     "orders-shard-0",
+			Replicas: []ReplicaInfo{{Name: "orders-shard-0-replica-0"}},
+		}}},
+	}
+	return o
+}
+
+func requestedStorage(t *testing.T, o *Operator, name string) string {
+	t.Helper()
+	pvc, err := o.client.CoreV1().PersistentVolumeClaims("default").Get(context.Background(), name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("expected PVC %s: %v", name, err)
+	}
+	size := pvc.Spec.Resources.Requests[corev1.ResourceStorage]
+	return size.String()
+}
+
+func TestExpandShardStorage_PatchesPrimaryAndReplicas(t *testing.T) {
+	o := newStorageTestOperator("fast", storageClass("fast", true, false))
+
+	expansion, err := o.ExpandShardStorage(context.Background(), "shard-id-0", "20Gi")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expansion.PreviousSize != "10Gi" || expansion.Size != "20Gi" || len(expansion.PVCs) != 2 {
+		t.Errorf("expected both volumes to grow from 10Gi to 20Gi, got %+v", expansion)
+	}
+	for _, name := range []string{"data-orders-shard-0", "data-orders-shard-0-replica-0"} {
+		if got := requestedStorage(t, o, name); got != "20Gi" {
+			t.Errorf("expected %s to request 20Gi, got %s", name, got)
+		}
+	}
+}
+
+func TestExpandShardStorage_Refused(t *testing.T) {
+	for _, c := range []struct {
+		name      string
+		className string
+		classes   []runtime.Object
+		size      string
+		want      error
+	}{
+		{"class disallows expansion", "standard", []runtime.Object{storageClass("standard", false, false)}, "20Gi", ErrVolumeExpansionNotAllowed},
+		{"default class disallows expansion", "", []runtime.Object{storageClass("fast", true, false), storageClass("standard", false, true)}, "20Gi", ErrVolumeExpansionNotAllowed},
+		{"no default class", "", []runtime.Object{storageClass("fast", true, false)}, "20Gi", ErrVolumeExpansionNotAllowed},
+		{"shrink", "fast", []runtime.Object{storageClass("fast", true, false)}, "5Gi", ErrStorageShrink},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			o := newStorageTestOperator(c.className, c.classes...)
+			if _, err := o.ExpandShardStorage(context.Background(), "orders-shard-0", c.size); !errors.Is(err, c.want) {
+				t.Errorf("expected %v, got %v", c.want, err)
+			}
+			if got := requestedStorage(t, o, "data-orders-shard-0"); got != "10Gi" {
+				t.Errorf("expected the volume to keep 10Gi, got %s", got)
+			}
+		})
+	}
+
+	o := newStorageTestOperator("fast", storageClass("fast", true, false))
+	if _, err := o.ExpandShardStorage(context.Background(), "missing", "20Gi"); !errors.Is(err, ErrShardNotFound) {
+		t.Errorf("expected an unknown shard to be refused, got %v", err)
+	}
+}
+
+func TestGrowShardStorage(t *testing.T) {
+	// The default StorageClass applies to volumes naming none
+	o := newStorageTestOperator("", storageClass("fast", true, true))
+
+	if _, err := o.GrowShardStorage(context.Background(), "shard-id-0", 50, "25Gi"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := requestedStorage(t, o, "data-orders-shard-0-replica-0"); got != "15Gi" {
+		t.Errorf("expected the volume to grow by half to 15Gi, got %s", got)
+	}
+
+	// Growth stops at the maximum size
+	if _, err := o.GrowShardStorage(context.Background(), "shard-id-0", 100, "25Gi"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := requestedStorage(t, o, "data-orders-shard-0"); got != "25Gi" {
+		t.Errorf("expected the volume to be capped at 25Gi, got %s", got)
+	}
+	if _, err := o.GrowShardStorage(context.Background(), "shard-id-0", 50, "25Gi"); !errors.Is(err, ErrStorageAtLimit) {
+		t.Errorf("expected a volume at the maximum size not to grow, got %v", err)
+	}
+}
+
+// localVolume is an available volume of a StorageClass without a provisioner
+func localVolume(name, size string, modes ...corev1.PersistentVolumeAccessMode) *corev1.PersistentVolume {
+	return &corev1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: corev1.PersistentVolumeSpec{
+			StorageClassName: "local",
+			AccessModes:      modes,
+			Capacity:         corev1.ResourceList{corev1.ResourceStorage: resource.MustParse(size)},
+		},
+		Status: corev1.PersistentVolumeStatus{Phase: corev1.VolumeAvailable},
+	}
+}
+
+func localStorageClass() *storagev1.StorageClass {
+	class := storageClass("local", false, false)
+	class.Provisioner = noProvisioner
+	return class
+}
+
+func TestCheckStorageClass(t *testing.T) {
+	claimed := localVolume("pv-claimed", "20Gi", corev1.ReadWriteOnce)
+	claimed.Spec.ClaimRef = &corev1.ObjectReference{Name: "data-other"}
+
+	for _, c := range []struct {
+		name             string
+		storage          StorageConfig
+		objects          []runtime.Object
+		requireExpansion bool
+		want             error
+	}{
+		{name: "present", storage: StorageConfig{Size: "10Gi", StorageClass: "fast"}, objects: []runtime.Object{storageClass("fast", false, false)}},
+		{name: "absent", storage: StorageConfig{Size: "10Gi", StorageClass: "fast"}, objects: []runtime.Object{storageClass("standard", true, true)}, want: ErrStorageClassNotFound},
+		{name: "default class", storage: StorageConfig{Size: "10Gi"}, objects: []runtime.Object{storageClass("standard", true, true)}},
+		{name: "no default class", storage: StorageConfig{Size: "10Gi"}, objects: []runtime.Object{storageClass("fast", true, false)}, want: ErrStorageClassNotFound},
+		{name: "expansion allowed", storage: StorageConfig{Size: "10Gi", StorageClass: "fast"}, objects: []runtime.Object{storageClass("fast", true, false)}, requireExpansion: true},
+		{name: "expansion not allowed", storage: StorageConfig{Size: "10Gi", StorageClass: "fast"}, objects: []runtime.Object{storageClass("fast", false, false)}, requireExpansion: true},
+		{name: "enough local volumes", storage: StorageConfig{Size: "10Gi", StorageClass: "local"}, objects: []runtime.Object{
+			localStorageClass(),
+			localVolume("pv-0", "10Gi", corev1.ReadWriteOnce),
+			localVolume("pv-1", "20Gi", corev1.ReadWriteOnce, corev1.ReadOnlyMany),
+		}},
+		{name: "too few local volumes", storage: StorageConfig{Size: "10Gi", StorageClass: "local"}, objects: []runtime.Object{
+			localStorageClass(),
+			localVolume("pv-0", "10Gi", corev1.ReadWriteOnce),
+			localVolume("pv-small", "5Gi", corev1.ReadWriteOnce),
+			localVolume("pv-shared", "10Gi", corev1.ReadWriteMany),
+			claimed,
+		}, want: ErrNoPersistentVolumes},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			o := newZoneTestOperator(c.objects...)
+			o.SetRequireVolumeExpansion(c.requireExpansion)
+			if _, err := o.checkStorageClass(context.Background(), c.storage, 2); !errors.Is(err, c.want) {
+				t.Errorf("expected %v, got %v", c.want, err)
+			}
+		})
+	}
+}
+
+func TestCreateShardedDatabase_RefusesMissingStorageClass(t *testing.T) {
+	o := newZoneTestOperator(storageClass("standard", true, true))
+	spec := ShardedDatabaseSpec{
+		Name:       "orders",
+		ShardCount: 2,
+		Storage:    StorageConfig{Size: "10Gi", StorageClass: "fast"},
+	}
+
+	_, err := o.CreateShardedDatabase(context.Background(), spec)
+	if !errors.Is(err, ErrStorageClassNotFound) {
+		t.Fatalf("expected a missing storage class to be refused, got %v", err)
+	}
+	if _, exists := o.GetDatabase("orders"); exists {
+		t.Error("expected no database to be created")
+	}
+	pvcs, _ := o.client.CoreV1().PersistentVolumeClaims("default").List(context.Background(), metav1.ListOptions{})
+	if len(pvcs.Items) != 0 {
+		t.Errorf("expected no PVCs to be created, got %d", len(pvcs.Items))
+	}
+}
+
+func TestExpandShardStorage_DisabledForDatabase(t *testing.T) {
+	o := newStorageTestOperator("fast", storageClass("fast", false, false))
+	o.SetRequireVolumeExpansion(true)
+
+	expandable, err := o.checkStorageClass(context.Background(), StorageConfig{Size: "10Gi", StorageClass: "fast"}, 2)
+	if err != nil {
+		t.Fatalf("expected a class without expansion to be accepted, got %v", err)
+	}
+	if expandable {
+		t.Fatal("expected the class to be reported as not expandable")
+	}
+
+	o.databases["orders"].Status.ExpansionDisabled = !expandable
+	if _, err := o.ExpandShardStorage(context.Background(), "shard-id-0", "20Gi"); !errors.Is(err, ErrVolumeExpansionNotAllowed) {
+		t.Errorf("expected expansion to be disabled for the database, got %v", err)
+	}
+	if size := requestedStorage(t, o, "data-orders-shard-0"); size != "10Gi" {
+		t.Errorf("expected the volume to keep 10Gi, got %s", size)
+	}
+}
+
+func TestScaleShards_RefusesTooFewLocalVolumes(t *testing.T) {
+	o := newZoneTestOperator(localStorageClass(), localVolume("pv-0", "10Gi", corev1.ReadWriteOnce))
+	o.databases["orders"] = &ShardedDatabase{
+		Spec: ShardedDatabaseSpec{
+			Name:        "orders",
+			ShardCount:  1,
+			Storage:     StorageConfig{Size: "10Gi", StorageClass: "local"},
+			Replication: ReplicationConfig{Enabled: true, Replicas: 1},
+		},
+		Status: ShardedDatabaseStatus{Shards: []ShardInfo{{Name: "orders-shard-0"}}},
+	}
+
+	// A new shard and its replica need two volumes
+	if err := o.ScaleShards(context.Background(), "orders", 2); !errors.Is(err, ErrNoPersistentVolumes) {
+		t.Errorf("expected too few volumes to be refused, got %v", err)
+	}
+}
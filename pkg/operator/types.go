@@ -41,6 +41,28 @@ type ShardedDatabaseSpec struct {
 
 	// Schema to apply on creation
 	Schema string `json:"schema,omitempty"`
+
+	// SchemaBootstrap is how the schema is applied: "job" (default) once the
+	// shard is ready, or "initdb" by PostgreSQL itself on first start, from a
+	// ConfigMap mounted into its init directory
+	SchemaBootstrap string `json:"schemaBootstrap,omitempty"`
+
+	// PostgreSQL image shards run, postgres:15-alpine when unset
+	Image PostgresImage `json:"image,omitempty"`
+}
+
+// PostgresImage is the PostgreSQL container image shards run, e.g. a pinned
+// version or a hardened image from a private registry
+type PostgresImage struct {
+	Repository string `json:"repository,omitempty"` // e.g. "registry.example.com/hardened/postgres"
+	Tag        string `json:"tag,omitempty"`        // e.g. "16.2-alpine"; defaults to "15-alpine"
+
+	// Version is the PostgreSQL major version the image runs, for tags that
+	// do not start with it
+	Version int `json:"version,omitempty"`
+
+	// PullSecret names the Secret holding the credentials of a private registry
+	PullSecret string `json:"pullSecret,omitempty"`
 }
 
 // ShardResources defines resource limits per shard
@@ -59,6 +81,14 @@ type StorageConfig struct {
 type ReplicationConfig struct {
 	Enabled  bool `json:"enabled"`
 	Replicas int  `json:"replicas"` // Number of read replicas per shard
+
+	// ZonePolicy controls what happens when replicas cannot be spread across
+	// zones: "preferred" (default) logs a warning, "required" refuses to provision
+	ZonePolicy string `json:"zonePolicy,omitempty"`
+
+	// Bootstrap is how new replicas get their initial copy of the data. Only
+	// "stream" (default), a base backup taken from the primary, is supported.
+	Bootstrap string `json:"bootstrap,omitempty"`
 }
 
 // ShardedDatabaseStatus defines the observed state
@@ -71,6 +101,10 @@ type ShardedDatabaseStatus struct {
 	ReadyAt         *time.Time   `json:"readyAt,omitempty"`
 	Message         string       `json:"message,omitempty"`
 	SchemaVersion   int          `json:"schemaVersion"`
+
+	// ExpansionDisabled is set when the StorageClass of the shards' volumes
+	// does not allow them to grow in place
+	ExpansionDisabled bool `json:"expansionDisabled,omitempty"`
 }
 
 // ShardInfo contains information about a single shard
@@ -84,6 +118,25 @@ type ShardInfo struct {
 	PodName   string    `json:"podName"`
 	PVCName   string    `json:"pvcName"`
 	CreatedAt time.Time `json:"createdAt"`
+
+	// Zone is the failure domain the primary is pinned to
+	Zone string `json:"zone,omitempty"`
+	// ReplicaZones are the zones planned for each replica, distinct from Zone where possible
+	ReplicaZones []string `json:"replicaZones,omitempty"`
+	// Replicas are the read replicas of the shard
+	Replicas []ReplicaInfo `json:"replicas,omitempty"`
+}
+
+// ReplicaInfo contains information about a read replica of a shard
+type ReplicaInfo struct {
+	Name    string `json:"name"`
+	Host    string `json:"host"`
+	PodName string `json:"podName"`
+	PVCName string `json:"pvcName"`
+	Zone    string `json:"zone,omitempty"`
+
+	// Bootstrap is how the replica got its initial data: "stream" from the primary
+	Bootstrap string `json:"bootstrap"`
 }
 
 // ShardedDatabaseList is a list of ShardedDatabase resources
@@ -101,6 +154,7 @@ type DatabaseTemplate struct {
 	Resources   ShardResources    `json:"resources"`
 	Storage     StorageConfig     `json:"storage"`
 	Replication ReplicationConfig `json:"replication"`
+	Image       PostgresImage     `json:"image,omitempty"`
 }
 
 // PredefinedTemplates provides ready-to-use configurations
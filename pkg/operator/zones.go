@@ -0,0 +1,261 @@
+package operator
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// ZoneLabel is the well-known node label holding the node's zone
+	ZoneLabel = "topology.kubernetes.io/zone"
+	// legacyZoneLabel is the pre-1.17 zone label, still set on some clusters
+	legacyZoneLabel = "failure-domain.beta.kubernetes.io/zone"
+
+	// ZonePolicyPreferred spreads replicas across zones when possible and warns otherwise
+	ZonePolicyPreferred = "preferred"
+	// ZonePolicyRequired refuses placements that co-locate all replicas with the primary
+	ZonePolicyRequired = "required"
+)
+
+// nodeZone returns the zone label of a node, or "" if it has none
+func nodeZone(node *corev1.Node) string {
+	if zone := node.Labels[ZoneLabel]; zone != "" {
+		return zone
+	}
+	return node.Labels[legacyZoneLabel]
+}
+
+// ListZones returns the distinct zones of schedulable nodes, sorted
+func (o *Operator) ListZones(ctx context.Context) ([]string, error) {
+	nodes, err := o.client.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list nodes: %w", err)
+	}
+
+	seen := make(map[string]bool)
+	zones := make([]string, 0)
+	for i := range nodes.Items {
+		node := &nodes.Items[i]
+		if node.Spec.Unschedulable {
+			continue
+		}
+		zone := nodeZone(node)
+		if zone == "" || seen[zone] {
+			continue
+		}
+		seen[zone] = true
+		zones = append(zones, zone)
+	}
+	sort.Strings(zones)
+	return zones, nil
+}
+
+// planShardZones picks a zone for a shard's primary and each of its replicas.
+// Primaries rotate through the zones by shard index so shards of one database
+// spread out, and replicas take the following zones so none shares the
+// primary's zone while another zone is available. It returns an error under
+// ZonePolicyRequired when every replica would land in the primary's zone.
+func planShardZones(zones []string, index, replicas int, policy string) (string, []string, error) {
+	if len(zones) == 0 {
+		// Nodes carry no zone labels; leave scheduling to Kubernetes
+		if replicas > 0 && policy == ZonePolicyRequired {
+			return "", nil, fmt.Errorf("zone policy %q requires zone-labelled nodes, found none", policy)
+		}
+		return "", nil, nil
+	}
+
+	primary := zones[index%len(zones)]
+	replicaZones := make([]string, 0, replicas)
+	for i := 1; i <= replicas; i++ {
+		zone := zones[(index+i)%len(zones)]
+		if zone == primary && len(zones) > 1 {
+			// Wrapped around to the primary's zone; skip it
+			zone = zones[(index+i+1)%len(zones)]
+		}
+		replicaZones = append(replicaZones, zone)
+	}
+
+	if replicas > 0 && len(zones) < 2 && policy == ZonePolicyRequired {
+		return "", nil, fmt.Errorf("zone policy %q: all %d replicas would share zone %s with the primary", policy, replicas, primary)
+	}
+	return primary, replicaZones, nil
+}
+
+// checkZoneSpread verifies the cluster can honour the spec's zone policy before
+// any resources are created
+func (o *Operator) checkZoneSpread(ctx context.Context, spec ShardedDatabaseSpec) ([]string, error) {
+	if !spec.Replication.Enabled || spec.Replication.Replicas == 0 {
+		return nil, nil
+	}
+
+	switch spec.Replication.ZonePolicy {
+	case "", ZonePolicyPreferred, ZonePolicyRequired:
+	default:
+		return nil, fmt.Errorf("invalid zone policy %q", spec.Replication.ZonePolicy)
+	}
+
+	zones, err := o.ListZones(ctx)
+	if err != nil {
+		if spec.Replication.ZonePolicy == ZonePolicyRequired {
+			return nil, err
+		}
+		// Spreading is only preferred, so leave scheduling to Kubernetes
+		o.logger.Warn("failed to list zones, replicas will not be spread across zones",
+			zap.String("database", spec.Name),
+			zap.Error(err))
+		return nil, nil
+	}
+
+	if _, _, err := planShardZones(zones, 0, spec.Replication.Replicas, spec.Replication.ZonePolicy); err != nil {
+		return nil, err
+	}
+	return zones, nil
+}
+
+// zoneAffinity pins pods to a zone
+func zoneAffinity(zone string) *corev1.Affinity {
+	if zone == "" {
+		return nil
+	}
+	return &corev1.Affinity{
+		NodeAffinity: &corev1.NodeAffinity{
+			RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
+				NodeSelectorTerms: []corev1.NodeSelectorTerm{
+					{
+						MatchExpressions: []corev1.NodeSelectorRequirement{
+							{
+								Key:      ZoneLabel,
+								Operator: corev1.NodeSelectorOpIn,
+								Values:   []string{zone},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// ZoneResolver maps database endpoints to the zone of the pod serving them
+type ZoneResolver struct {
+	client    kubernetes.Interface
+	namespace string
+}
+
+// NewZoneResolver creates a resolver for endpoints in a namespace
+func NewZoneResolver(client kubernetes.Interface, namespace string) *ZoneResolver {
+	return &ZoneResolver{
+		client:    client,
+		namespace: namespace,
+	}
+}
+
+// ZoneResolver returns a resolver for endpoints in the operator's namespace
+func (o *Operator) ZoneResolver() *ZoneResolver {
+	return NewZoneResolver(o.client, o.namespace)
+}
+
+// ZoneOf returns the zone of the node running the pod behind an endpoint.
+// Endpoints may be URLs or key=value DSNs whose host is a pod DNS name
+// (pod.service.namespace.svc...), a service name (resolved to its -0 pod) or a pod IP.
+func (r *ZoneResolver) ZoneOf(ctx context.Context, endpoint string) (string, error) {
+	host := endpointHost(endpoint)
+	if host == "" {
+		return "", fmt.Errorf("no host in endpoint")
+	}
+
+	pod, err := r.findPod(ctx, host)
+	if err != nil {
+		return "", err
+	}
+	if pod.Spec.NodeName == "" {
+		return "", fmt.Errorf("pod %s is not scheduled", pod.Name)
+	}
+
+	node, err := r.client.CoreV1().Nodes().Get(ctx, pod.Spec.NodeName, metav1.GetOptions{})
+	if err != nil {
+		return "", fmt.Errorf("failed to get node %s: %w", pod.Spec.NodeName, err)
+	}
+	zone := nodeZone(node)
+	if zone == "" {
+		return "", fmt.Errorf("node %s has no zone label", node.Name)
+	}
+	return zone, nil
+}
+
+// Draining reports whether the node running the pod behind an endpoint is
+// cordoned, as it is while being drained
+func (r *ZoneResolver) Draining(ctx context.Context, endpoint string) (bool, error) {
+	host := endpointHost(endpoint)
+	if host == "" {
+		return false, fmt.Errorf("no host in endpoint")
+	}
+
+	pod, err := r.findPod(ctx, host)
+	if err != nil {
+		return false, err
+	}
+	if pod.Spec.NodeName == "" {
+		return false, nil
+	}
+
+	node, err := r.client.CoreV1().Nodes().Get(ctx, pod.Spec.NodeName, metav1.GetOptions{})
+	if err != nil {
+		return false, fmt.Errorf("failed to get node %s: %w", pod.Spec.NodeName, err)
+	}
+	return node.Spec.Unschedulable, nil
+}
+
+// findPod locates the pod serving a host
+func (r *ZoneResolver) findPod(ctx context.Context, host string) (*corev1.Pod, error) {
+	pods := r.client.CoreV1().Pods(r.namespace)
+
+	if net.ParseIP(host) != nil {
+		list, err := pods.List(ctx, metav1.ListOptions{FieldSelector: "status.podIP=" + host})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list pods: %w", err)
+		}
+		for i := range list.Items {
+			if list.Items[i].Status.PodIP == host {
+				return &list.Items[i], nil
+			}
+		}
+		return nil, fmt.Errorf("no pod with IP %s", host)
+	}
+
+	name := strings.SplitN(host, ".", 2)[0]
+	if pod, err := pods.Get(ctx, name, metav1.GetOptions{}); err == nil {
+		return pod, nil
+	}
+	// Service name of a single-pod StatefulSet
+	pod, err := pods.Get(ctx, name+"-0", metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("no pod found for host %s", host)
+	}
+	return pod, nil
+}
+
+// endpointHost extracts the host from a URL or key=value DSN
+func endpointHost(endpoint string) string {
+	if strings.Contains(endpoint, "://") {
+		if u, err := url.Parse(endpoint); err == nil {
+			return u.Hostname()
+		}
+		return ""
+	}
+	for _, field := range strings.Fields(endpoint) {
+		if strings.HasPrefix(field, "host=") {
+			return strings.TrimPrefix(field, "host=")
+		}
+	}
+	return ""
+}
@@ -0,0 +1,210 @@
+package operator
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func zonedNode(name, zone string) *corev1.Node {
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			Labels: map[string]string{ZoneLabel: zone},
+		},
+	}
+}
+
+func newZoneTestOperator(objects ...runtime.Object) *Operator {
+	return NewOperatorWithClient(fake.NewSimpleClientset(objects...), zap.NewNop(), "default")
+}
+
+func TestListZones(t *testing.T) {
+	cordoned := zonedNode("node-d", "zone-d")
+	cordoned.Spec.Unschedulable = true
+	legacy := &corev1.Node{ObjectMeta: metav1.ObjectMeta{
+		Name:   "node-e",
+		Labels: map[string]string{legacyZoneLabel: "zone-e"},
+	}}
+	unlabelled := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-f"}}
+
+	o := newZoneTestOperator(
+		zonedNode("node-b", "zone-b"),
+		zonedNode("node-a1", "zone-a"),
+		zonedNode("node-a2", "zone-a"),
+		cordoned, legacy, unlabelled,
+	)
+
+	zones, err := o.ListZones(context.Background())
+	if err != nil {
+		t.Fatalf("ListZones: %v", err)
+	}
+	want := []string{"zone-a", "zone-b", "zone-e"}
+	if len(zones) != len(want) {
+		t.Fatalf("expected %v, got %v", want, zones)
+	}
+	for i := range want {
+		if zones[i] != want[i] {
+			t.Errorf("expected %v, got %v", want, zones)
+		}
+	}
+}
+
+func TestPlanShardZones_SpreadsReplicas(t *testing.T) {
+	zones := []string{"zone-a", "zone-b", "zone-c"}
+	primaries := make(map[string]int)
+
+	for index := 0; index < 3; index++ {
+		primary, replicas, err := planShardZones(zones, index, 2, ZonePolicyRequired)
+		if err != nil {
+			t.Fatalf("shard %d: %v", index, err)
+		}
+		primaries[primary]++
+
+		used := map[string]bool{primary: true}
+		for _, zone := range replicas {
+			if used[zone] {
+				t.Errorf("shard %d: zone %s used twice (primary %s, replicas %v)", index, zone, primary, replicas)
+			}
+			used[zone] = true
+		}
+	}
+
+	// Primaries of consecutive shards land in different zones
+	if len(primaries) != 3 {
+		t.Errorf("expected primaries spread over 3 zones, got %v", primaries)
+	}
+}
+
+func TestPlanShardZones_MoreReplicasThanZones(t *testing.T) {
+	primary, replicas, err := planShardZones([]string{"zone-a", "zone-b"}, 0, 3, ZonePolicyRequired)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	for _, zone := range replicas {
+		if zone == primary {
+			t.Errorf("replica placed in primary zone %s: %v", primary, replicas)
+		}
+	}
+}
+
+func TestPlanShardZones_SingleZonePolicy(t *testing.T) {
+	if _, _, err := planShardZones([]string{"zone-a"}, 0, 1, ZonePolicyRequired); err == nil {
+		t.Error("expected required policy to refuse co-located replicas")
+	}
+
+	primary, replicas, err := planShardZones([]string{"zone-a"}, 0, 1, ZonePolicyPreferred)
+	if err != nil {
+		t.Fatalf("expected preferred policy to allow co-location, got %v", err)
+	}
+	if primary != "zone-a" || len(replicas) != 1 || replicas[0] != "zone-a" {
+		t.Errorf("unexpected plan %s %v", primary, replicas)
+	}
+}
+
+func TestCheckZoneSpread(t *testing.T) {
+	spec := ShardedDatabaseSpec{
+		Name:        "orders",
+		Replication: ReplicationConfig{Enabled: true, Replicas: 1, ZonePolicy: ZonePolicyRequired},
+	}
+
+	single := newZoneTestOperator(zonedNode("node-a", "zone-a"), zonedNode("node-a2", "zone-a"))
+	if _, err := single.checkZoneSpread(context.Background(), spec); err == nil {
+		t.Error("expected error when all nodes are in one zone")
+	}
+
+	multi := newZoneTestOperator(zonedNode("node-a", "zone-a"), zonedNode("node-b", "zone-b"))
+	zones, err := multi.checkZoneSpread(context.Background(), spec)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(zones) != 2 {
+		t.Errorf("expected 2 zones, got %v", zones)
+	}
+
+	spec.Replication.ZonePolicy = "sometimes"
+	if _, err := multi.checkZoneSpread(context.Background(), spec); err == nil {
+		t.Error("expected error for unknown zone policy")
+	}
+}
+
+func TestCheckZoneSpread_NodesUnreadable(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	client.PrependReactor("list", "nodes", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.New("nodes is forbidden")
+	})
+	o := NewOperatorWithClient(client, zap.NewNop(), "default")
+	spec := ShardedDatabaseSpec{
+		Name:        "orders",
+		Replication: ReplicationConfig{Enabled: true, Replicas: 1, ZonePolicy: ZonePolicyPreferred},
+	}
+
+	zones, err := o.checkZoneSpread(context.Background(), spec)
+	if err != nil || zones != nil {
+		t.Errorf("expected the preferred policy to go ahead without zones, got %v, %v", zones, err)
+	}
+
+	spec.Replication.ZonePolicy = ZonePolicyRequired
+	if _, err := o.checkZoneSpread(context.Background(), spec); err == nil {
+		t.Error("expected the required policy to fail without zones")
+	}
+}
+
+func TestCreateStatefulSet_PinsZone(t *testing.T) {
+	o := newZoneTestOperator()
+	db := &ShardedDatabase{Spec: ShardedDatabaseSpec{
+		Name:      "orders",
+		Resources: ShardResources{CPU: "250m", Memory: "256Mi"},
+	}}
+
+	if err := o.createStatefulSet(context.Background(), db, "orders-shard-0", 0, "zone-b"); err != nil {
+		t.Fatalf("createStatefulSet: %v", err)
+	}
+
+	sts, err := o.client.AppsV1().StatefulSets("default").Get(context.Background(), "orders-shard-0", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("get StatefulSet: %v", err)
+	}
+	affinity := sts.Spec.Template.Spec.Affinity
+	if affinity == nil || affinity.NodeAffinity == nil {
+		t.Fatal("expected node affinity")
+	}
+	expr := affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms[0].MatchExpressions[0]
+	if expr.Key != ZoneLabel || len(expr.Values) != 1 || expr.Values[0] != "zone-b" {
+		t.Errorf("expected affinity to zone-b, got %+v", expr)
+	}
+}
+
+func TestZoneResolver_ZoneOf(t *testing.T) {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "orders-shard-0-0", Namespace: "default"},
+		Spec:       corev1.PodSpec{NodeName: "node-b"},
+	}
+	client := fake.NewSimpleClientset(zonedNode("node-a", "zone-a"), zonedNode("node-b", "zone-b"), pod)
+	resolver := NewZoneResolver(client, "default")
+
+	endpoints := []string{
+		"postgres://admin@orders-shard-0-0.orders-shard-0.default.svc.cluster.local:5432/orders",
+		"host=orders-shard-0.default.svc.cluster.local port=5432 dbname=orders",
+	}
+	for _, endpoint := range endpoints {
+		zone, err := resolver.ZoneOf(context.Background(), endpoint)
+		if err != nil {
+			t.Fatalf("ZoneOf(%s): %v", endpoint, err)
+		}
+		if zone != "zone-b" {
+			t.Errorf("ZoneOf(%s): expected zone-b, got %s", endpoint, zone)
+		}
+	}
+
+	if _, err := resolver.ZoneOf(context.Background(), "postgres://admin@unknown:5432/db"); err == nil {
+		t.Error("expected error for unknown host")
+	}
+}
@@ -1,6 +1,9 @@
 package pricing
 
-import "strings"
+import (
+	"fmt"
+	"strings"
+)
 
 // Tier represents a pricing tier
 type Tier string
@@ -11,12 +14,24 @@ const (
 	TierEnterprise Tier = "enterprise"
 )
 
+// QoSClass determines how a tenant's requests are scheduled under contention
+type QoSClass string
+
+const (
+	QoSPremium    QoSClass = "premium"     // Always admitted while capacity remains; queued first when full
+	QoSStandard   QoSClass = "standard"    // Admitted up to most of capacity; queued behind premium
+	QoSBestEffort QoSClass = "best_effort" // Shed first once the router is under pressure
+)
+
 // Limits defines the capabilities for a specific tier
 type Limits struct {
 	MaxShards              int
 	MaxRPS                 int
+	MaxConnections         int   // Client connections across all of an app's shards
+	MaxStorageBytes        int64 // Database size across all of an app's shards
 	AllowStrongConsistency bool
 	Name                   string
+	QoSClass               QoSClass
 }
 
 // GetLimits returns the limits for a given tier
@@ -27,22 +42,51 @@ func GetLimits(tierStr string) Limits {
 		return Limits{
 			MaxShards:              10,
 			MaxRPS:                 100,
+			MaxConnections:         200,
+			MaxStorageBytes:        100 << 30, // 100 GiB
 			AllowStrongConsistency: true,
 			Name:                   "Pro",
+			QoSClass:               QoSStandard,
 		}
 	case TierEnterprise:
 		return Limits{
 			MaxShards:              -1, // Unlimited
 			MaxRPS:                 -1, // Unlimited
+			MaxConnections:         -1, // Unlimited
+			MaxStorageBytes:        -1, // Unlimited
 			AllowStrongConsistency: true,
 			Name:                   "Enterprise",
+			QoSClass:               QoSPremium,
 		}
 	default: // Default to Free
 		return Limits{
 			MaxShards:              2,
 			MaxRPS:                 10,
+			MaxConnections:         20,
+			MaxStorageBytes:        1 << 30, // 1 GiB
 			AllowStrongConsistency: false,
 			Name:                   "Free",
+			QoSClass:               QoSBestEffort,
 		}
 	}
 }
+
+// ParseQoSClass validates a QoS class name
+func ParseQoSClass(s string) (QoSClass, error) {
+	switch class := QoSClass(strings.ToLower(s)); class {
+	case QoSPremium, QoSStandard, QoSBestEffort:
+		return class, nil
+	default:
+		return "", fmt.Errorf("unknown QoS class %q", s)
+	}
+}
+
+// ParseTier validates a pricing tier name
+func ParseTier(s string) (Tier, error) {
+	switch tier := Tier(strings.ToLower(s)); tier {
+	case TierFree, TierPro, TierEnterprise:
+		return tier, nil
+	default:
+		return "", fmt.Errorf("unknown pricing tier %q", s)
+	}
+}
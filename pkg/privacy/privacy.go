@@ -0,0 +1,517 @@
+package privacy
+
+import (
+	"archive/zip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/sharding-system/pkg/models"
+	"github.com/sharding-system/pkg/security"
+	"go.uber.org/zap"
+)
+
+// ErrNoShards is returned when a client application has no shards holding data
+var ErrNoShards = errors.New("client application has no shards with data")
+
+// Audit actions recorded for data subject requests
+const (
+	AuditActionExport = "tenant_data_export"
+	AuditActionErase  = "tenant_data_erase"
+)
+
+// tablesWithColumn lists the user tables that have the shard key column. Views
+// and foreign tables are left out, and so are partitions: a partitioned table
+// reads and deletes through its partitions, so each row is handled once.
+const tablesWithColumn = `SELECT c.table_schema, c.table_name
+FROM information_schema.columns c
+JOIN information_schema.tables t
+  ON t.table_schema = c.table_schema AND t.table_name = c.table_name
+JOIN pg_catalog.pg_namespace n ON n.nspname = c.table_schema
+JOIN pg_catalog.pg_class r ON r.relnamespace = n.oid AND r.relname = c.table_name
+WHERE c.column_name = $1
+  AND c.table_schema NOT IN ('pg_catalog', 'information_schema')
+  AND t.table_type = 'BASE TABLE'
+  AND NOT r.relispartition
+ORDER BY c.table_schema, c.table_name`
+
+// mysqlTablesWithColumn lists the tables of the shard's database that have
+// the shard key column, leaving out views; a MySQL schema is a database
+const mysqlTablesWithColumn = `SELECT c.table_schema, c.table_name
+FROM information_schema.columns c
+JOIN information_schema.tables t
+  ON t.table_schema = c.table_schema AND t.table_name = c.table_name
+WHERE c.column_name = ?
+  AND c.table_schema = DATABASE()
+  AND t.table_type = 'BASE TABLE'
+ORDER BY c.table_schema, c.table_name`
+
+// dialect is the engine-specific SQL of data subject requests
+type dialect struct {
+	tablesWithColumn string
+	placeholder      string
+	quote            func(string) string
+}
+
+var dialects = map[string]dialect{
+	models.EnginePostgres: {tablesWithColumn: tablesWithColumn, placeholder: "$1", quote: pq.QuoteIdentifier},
+	models.EngineMySQL:    {tablesWithColumn: mysqlTablesWithColumn, placeholder: "?", quote: quoteMySQLIdentifier},
+}
+
+// dialectFor returns the dialect of a shard's engine
+func dialectFor(shard *models.Shard) (dialect, error) {
+	d, ok := dialects[models.NormalizeEngine(shard.Engine)]
+	if !ok {
+		return dialect{}, fmt.Errorf("shard %s: data subject requests are not supported for engine %q", shard.ID, shard.Engine)
+	}
+	return d, nil
+}
+
+// ShardLister lists the shards of a client application
+type ShardLister interface {
+	ListShardsForClient(clientAppID string) ([]models.Shard, error)
+}
+
+// Request identifies the data of one data subject: the rows of a client
+// application whose shard key column holds Key, e.g. a user ID
+type Request struct {
+	ClientAppID string `json:"client_app_id"`
+	Key         string `json:"key"`
+	RequestedBy string `json:"requested_by,omitempty"` // Recorded in the audit log
+}
+
+// TableRows are the rows of one table keyed to the data subject
+type TableRows struct {
+	Table   string                   `json:"table"` // schema.table
+	Columns []string                 `json:"columns"`
+	Rows    []map[string]interface{} `json:"rows"`
+}
+
+// ShardExport is the data collected from one shard
+type ShardExport struct {
+	ShardID  string      `json:"shard_id"`
+	ShardKey string      `json:"shard_key"`
+	Tables   []TableRows `json:"tables"`
+}
+
+// Export is every row of a data subject across a client application's shards
+type Export struct {
+	ClientAppID string        `json:"client_app_id"`
+	Key         string        `json:"key"`
+	ExportedAt  time.Time     `json:"exported_at"`
+	TotalRows   int           `json:"total_rows"`
+	Shards      []ShardExport `json:"shards"`
+}
+
+// TableErasure is how many rows were deleted from a table
+type TableErasure struct {
+	Table string `json:"table"`
+	Rows  int64  `json:"rows"`
+}
+
+// ShardErasure is the outcome of erasing a data subject's rows on one shard.
+// A shard's deletes run in one transaction, so a shard with an error kept all
+// of its rows.
+type ShardErasure struct {
+	ShardID string         `json:"shard_id"`
+	Tables  []TableErasure `json:"tables,omitempty"`
+	Error   string         `json:"error,omitempty"`
+}
+
+// Erasure is the outcome of erasing a data subject's rows
+type Erasure struct {
+	ClientAppID string         `json:"client_app_id"`
+	Key         string         `json:"key"`
+	ErasedAt    time.Time      `json:"erased_at"`
+	TotalRows   int64          `json:"total_rows"`
+	Shards      []ShardErasure `json:"shards"`
+}
+
+// Service exports and erases a data subject's rows across the shards of a
+// client application, for access and right-to-erasure requests. Every shard
+// of the app that has a database is visited, since a split or move may have
+// left rows for a key on more than one shard, and every table with the
+// shard's shard key column is included. Both operations are recorded in the
+// audit log when one is set.
+type Service struct {
+	shards ShardLister
+	logger *zap.Logger
+	driver string // database/sql driver used to reach shards; empty uses the shard's engine
+	mu     sync.RWMutex
+	audit  *security.AuditLogger
+}
+
+// NewService creates a service reaching the shards listed by shards
+func NewService(shards ShardLister, logger *zap.Logger) *Service {
+	return &Service{shards: shards, logger: logger}
+}
+
+// SetAuditLogger records exports and erasures in the audit log
+func (s *Service) SetAuditLogger(audit *security.AuditLogger) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.audit = audit
+}
+
+// Export collects every row keyed to the request's key from the client
+// application's shards. It fails if any shard cannot be read, since a partial
+// export would not answer the request.
+func (s *Service) Export(ctx context.Context, req Request) (*Export, error) {
+	export, err := s.export(ctx, req)
+	detail := ""
+	if export != nil {
+		detail = fmt.Sprintf("%d rows from %d shards", export.TotalRows, len(export.Shards))
+	}
+	s.record(ctx, AuditActionExport, req, detail, err)
+	return export, err
+}
+
+func (s *Service) export(ctx context.Context, req Request) (*Export, error) {
+	shards, err := s.shardsFor(req)
+	if err != nil {
+		return nil, err
+	}
+
+	export := &Export{ClientAppID: req.ClientAppID, Key: req.Key, ExportedAt: time.Now(), Shards: make([]ShardExport, 0, len(shards))}
+	for i := range shards {
+		shardExport, err := s.exportShard(ctx, &shards[i], req.Key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to export from shard %s: %w", shards[i].ID, err)
+		}
+		for _, table := range shardExport.Tables {
+			export.TotalRows += len(table.Rows)
+		}
+		export.Shards = append(export.Shards, *shardExport)
+	}
+	return export, nil
+}
+
+// exportShard collects a shard's rows keyed to key
+func (s *Service) exportShard(ctx context.Context, shard *models.Shard, key string) (*ShardExport, error) {
+	d, err := dialectFor(shard)
+	if err != nil {
+		return nil, err
+	}
+	db, err := s.open(shard)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	tables, err := d.keyedTables(ctx, db, shard.ShardKey)
+	if err != nil {
+		return nil, err
+	}
+
+	shardExport := &ShardExport{ShardID: shard.ID, ShardKey: shard.ShardKey, Tables: make([]TableRows, 0, len(tables))}
+	for _, table := range tables {
+		rows, err := d.selectKeyed(ctx, db, table, shard.ShardKey, key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", table, err)
+		}
+		if len(rows.Rows) > 0 {
+			shardExport.Tables = append(shardExport.Tables, *rows)
+		}
+	}
+	return shardExport, nil
+}
+
+// Erase deletes every row keyed to the request's key from the client
+// application's shards. Each shard is erased in its own transaction; shards
+// that fail are reported in the result and the returned error, while the
+// others are still erased.
+func (s *Service) Erase(ctx context.Context, req Request) (*Erasure, error) {
+	erasure, err := s.erase(ctx, req)
+	detail := ""
+	if erasure != nil {
+		detail = fmt.Sprintf("%d rows from %d shards", erasure.TotalRows, len(erasure.Shards))
+	}
+	s.record(ctx, AuditActionErase, req, detail, err)
+	return erasure, err
+}
+
+func (s *Service) erase(ctx context.Context, req Request) (*Erasure, error) {
+	shards, err := s.shardsFor(req)
+	if err != nil {
+		return nil, err
+	}
+
+	erasure := &Erasure{ClientAppID: req.ClientAppID, Key: req.Key, ErasedAt: time.Now(), Shards: make([]ShardErasure, 0, len(shards))}
+	var failed []string
+	for i := range shards {
+		shardErasure := s.eraseShard(ctx, &shards[i], req.Key)
+		if shardErasure.Error != "" {
+			failed = append(failed, shardErasure.ShardID)
+			s.logger.Error("failed to erase tenant data from shard",
+				zap.String("client_app_id", req.ClientAppID),
+				zap.String("shard_id", shardErasure.ShardID),
+				zap.String("error", shardErasure.Error))
+		}
+		for _, table := range shardErasure.Tables {
+			erasure.TotalRows += table.Rows
+		}
+		erasure.Shards = append(erasure.Shards, shardErasure)
+	}
+	if len(failed) > 0 {
+		return erasure, fmt.Errorf("failed to erase data from shards %s", strings.Join(failed, ", "))
+	}
+	return erasure, nil
+}
+
+// eraseShard deletes a shard's rows keyed to key in one transaction
+func (s *Service) eraseShard(ctx context.Context, shard *models.Shard, key string) ShardErasure {
+	result := ShardErasure{ShardID: shard.ID}
+	fail := func(err error) ShardErasure {
+		result.Tables = nil
+		result.Error = err.Error()
+		return result
+	}
+
+	d, err := dialectFor(shard)
+	if err != nil {
+		return fail(err)
+	}
+	db, err := s.open(shard)
+	if err != nil {
+		return fail(err)
+	}
+	defer db.Close()
+
+	tables, err := d.keyedTables(ctx, db, shard.ShardKey)
+	if err != nil {
+		return fail(err)
+	}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fail(fmt.Errorf("failed to begin transaction: %w", err))
+	}
+	defer tx.Rollback()
+
+	for _, table := range tables {
+		res, err := tx.ExecContext(ctx, "DELETE FROM "+d.keyedRows(table, shard.ShardKey), key)
+		if err != nil {
+			return fail(fmt.Errorf("failed to delete from %s: %w", table, err))
+		}
+		n, _ := res.RowsAffected()
+		if n > 0 {
+			result.Tables = append(result.Tables, TableErasure{Table: table, Rows: n})
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fail(fmt.Errorf("failed to commit: %w", err))
+	}
+	return result
+}
+
+// shardsFor returns the shards of the request's client application that have
+// a database to read
+func (s *Service) shardsFor(req Request) ([]models.Shard, error) {
+	if req.ClientAppID == "" || req.Key == "" {
+		return nil, fmt.Errorf("client_app_id and key are required")
+	}
+	all, err := s.shards.ListShardsForClient(req.ClientAppID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list shards: %w", err)
+	}
+
+	shards := make([]models.Shard, 0, len(all))
+	for _, shard := range all {
+		if shard.ClientAppID != req.ClientAppID || shard.Status == models.ShardStatusProvisioning {
+			continue
+		}
+		if shard.ShardKey == "" {
+			return nil, fmt.Errorf("shard %s has no shard key", shard.ID)
+		}
+		shards = append(shards, shard)
+	}
+	if len(shards) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrNoShards, req.ClientAppID)
+	}
+	sort.Slice(shards, func(i, j int) bool { return shards[i].ID < shards[j].ID })
+	return shards, nil
+}
+
+// open connects to a shard's primary
+func (s *Service) open(shard *models.Shard) (*sql.DB, error) {
+	dsn := shard.ConnectionString("")
+	if dsn == "" {
+		return nil, fmt.Errorf("shard %s has no connection details", shard.ID)
+	}
+	driverName := s.driver
+	if driverName == "" {
+		var err error
+		if driverName, err = models.DriverName(shard.Engine); err != nil {
+			return nil, err
+		}
+	}
+	db, err := sql.Open(driverName, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to shard %s: %w", shard.ID, err)
+	}
+	return db, nil
+}
+
+// record writes a data subject request to the audit log
+func (s *Service) record(ctx context.Context, action string, req Request, detail string, err error) {
+	s.logger.Info("tenant data request",
+		zap.String("action", action),
+		zap.String("client_app_id", req.ClientAppID),
+		zap.String("requested_by", req.RequestedBy),
+		zap.String("result", detail),
+		zap.Bool("success", err == nil))
+
+	s.mu.RLock()
+	audit := s.audit
+	s.mu.RUnlock()
+	if audit == nil {
+		return
+	}
+	// The key identifies a person, so the audit log only names the app
+	event := security.AuditEvent{
+		User:       req.RequestedBy,
+		Action:     action,
+		Resource:   "client_apps",
+		ResourceID: req.ClientAppID,
+		Success:    err == nil,
+	}
+	if err != nil {
+		event.Error = err.Error()
+	}
+	audit.LogContext(ctx, event)
+}
+
+// keyedTables lists the schema-qualified tables that have the column
+func (d dialect) keyedTables(ctx context.Context, db *sql.DB, column string) ([]string, error) {
+	rows, err := db.QueryContext(ctx, d.tablesWithColumn, column)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tables with column %s: %w", column, err)
+	}
+	defer rows.Close()
+
+	var tables []string
+	for rows.Next() {
+		var schema, table string
+		if err := rows.Scan(&schema, &table); err != nil {
+			return nil, fmt.Errorf("failed to scan table: %w", err)
+		}
+		tables = append(tables, schema+"."+table)
+	}
+	return tables, rows.Err()
+}
+
+// selectKeyed reads a table's rows whose column equals key
+func (d dialect) selectKeyed(ctx context.Context, db *sql.DB, table, column, key string) (*TableRows, error) {
+	rows, err := db.QueryContext(ctx, "SELECT * FROM "+d.keyedRows(table, column), key)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	result := &TableRows{Table: table, Columns: columns, Rows: make([]map[string]interface{}, 0)}
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		pointers := make([]interface{}, len(columns))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return nil, err
+		}
+		row := make(map[string]interface{}, len(columns))
+		for i, col := range columns {
+			if b, ok := values[i].([]byte); ok {
+				row[col] = string(b)
+			} else {
+				row[col] = values[i]
+			}
+		}
+		result.Rows = append(result.Rows, row)
+	}
+	return result, rows.Err()
+}
+
+// keyedRows is the FROM ... WHERE clause matching a table's rows whose column
+// equals the statement's only argument
+func (d dialect) keyedRows(table, column string) string {
+	return fmt.Sprintf("%s WHERE %s = %s", d.quoteTable(table), d.quote(column), d.placeholder)
+}
+
+// quoteTable quotes a schema-qualified table name
+func (d dialect) quoteTable(table string) string {
+	schema, name, ok := strings.Cut(table, ".")
+	if !ok {
+		return d.quote(table)
+	}
+	return d.quote(schema) + "." + d.quote(name)
+}
+
+// quoteMySQLIdentifier quotes a MySQL identifier with backticks
+func quoteMySQLIdentifier(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+// WriteArchive writes the export as a zip archive: manifest.json summarizes
+// it, and shards/<shard>/<schema.table>.json holds each table's rows
+func (e *Export) WriteArchive(w io.Writer) error {
+	archive := zip.NewWriter(w)
+
+	type manifestTable struct {
+		Table string `json:"table"`
+		Rows  int    `json:"rows"`
+		File  string `json:"file"`
+	}
+	type manifestShard struct {
+		ShardID string          `json:"shard_id"`
+		Tables  []manifestTable `json:"tables"`
+	}
+	manifest := struct {
+		ClientAppID string          `json:"client_app_id"`
+		Key         string          `json:"key"`
+		ExportedAt  time.Time       `json:"exported_at"`
+		TotalRows   int             `json:"total_rows"`
+		Shards      []manifestShard `json:"shards"`
+	}{ClientAppID: e.ClientAppID, Key: e.Key, ExportedAt: e.ExportedAt, TotalRows: e.TotalRows, Shards: make([]manifestShard, 0, len(e.Shards))}
+
+	for _, shard := range e.Shards {
+		entry := manifestShard{ShardID: shard.ShardID, Tables: make([]manifestTable, 0, len(shard.Tables))}
+		for _, table := range shard.Tables {
+			file := path.Join("shards", shard.ShardID, table.Table+".json")
+			if err := writeJSON(archive, file, table.Rows); err != nil {
+				return err
+			}
+			entry.Tables = append(entry.Tables, manifestTable{Table: table.Table, Rows: len(table.Rows), File: file})
+		}
+		manifest.Shards = append(manifest.Shards, entry)
+	}
+	if err := writeJSON(archive, "manifest.json", manifest); err != nil {
+		return err
+	}
+	return archive.Close()
+}
+
+// writeJSON adds an indented JSON file to the archive
+func writeJSON(archive *zip.Writer, name string, v interface{}) error {
+	f, err := archive.Create(name)
+	if err != nil {
+		return fmt.Errorf("failed to add %s to archive: %w", name, err)
+	}
+	encoder := json.NewEncoder(f)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(v); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	return nil
+}
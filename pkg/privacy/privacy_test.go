@@ -0,0 +1,441 @@
+package privacy
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/sharding-system/pkg/models"
+	"github.com/sharding-system/pkg/security"
+	"go.uber.org/zap/zaptest"
+)
+
+// fakeTable is a table behind the fake driver
+type fakeTable struct {
+	columns []string
+	rows    [][]driver.Value
+	kind    string // "view" or "partition"; empty for a base table
+}
+
+// fakeDriver serves the service's catalog, SELECT and DELETE statements from
+// in-memory tables, keyed by DSN and then by schema.table
+type fakeDriver struct {
+	mu        sync.Mutex
+	databases map[string]map[string]*fakeTable
+	down      map[string]bool
+	queries   map[string][]string // Statements received, by DSN
+}
+
+var testDriver = &fakeDriver{databases: make(map[string]map[string]*fakeTable), down: make(map[string]bool), queries: make(map[string][]string)}
+
+func init() {
+	sql.Register("privacytest", testDriver)
+}
+
+var keyedStatement = regexp.MustCompile("^(SELECT \\*|DELETE) FROM [\"`](\\w+)[\"`]\\.[\"`](\\w+)[\"`] WHERE [\"`](\\w+)[\"`] = (\\$1|\\?)$")
+
+// mysqlStatement matches the statements the service sends to MySQL shards
+var mysqlStatement = regexp.MustCompile("^(SELECT \\*|DELETE) FROM `\\w+`\\.`\\w+` WHERE `\\w+` = \\?$")
+
+func (d *fakeDriver) Open(dsn string) (driver.Conn, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.down[dsn] {
+		return nil, fmt.Errorf("connection refused")
+	}
+	return &fakeConn{driver: d, dsn: dsn}, nil
+}
+
+// rows returns the rows of a table keyed to key, removing them if remove is set
+func (d *fakeDriver) rows(dsn, table, column, key string, remove bool) (*fakeTable, [][]driver.Value) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	t := d.databases[dsn][table]
+	if t == nil {
+		return nil, nil
+	}
+	col := -1
+	for i, name := range t.columns {
+		if name == column {
+			col = i
+		}
+	}
+	var matched, kept [][]driver.Value
+	for _, row := range t.rows {
+		if col >= 0 && fmt.Sprint(row[col]) == key {
+			matched = append(matched, row)
+		} else {
+			kept = append(kept, row)
+		}
+	}
+	if remove {
+		t.rows = kept
+	}
+	return t, matched
+}
+
+func (d *fakeDriver) record(dsn, query string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.queries[dsn] = append(d.queries[dsn], query)
+}
+
+type fakeConn struct {
+	driver *fakeDriver
+	dsn    string
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, fmt.Errorf("prepare not supported")
+}
+func (c *fakeConn) Close() error              { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error) { return fakeTx{}, nil }
+
+func (c *fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	c.driver.record(c.dsn, query)
+	if query == tablesWithColumn || query == mysqlTablesWithColumn {
+		c.driver.mu.Lock()
+		defer c.driver.mu.Unlock()
+		var names []string
+		for name, t := range c.driver.databases[c.dsn] {
+			if t.kind != "" {
+				continue // the catalog query keeps base tables that are not partitions
+			}
+			for _, col := range t.columns {
+				if col == args[0].Value {
+					names = append(names, name)
+				}
+			}
+		}
+		sort.Strings(names)
+		rows := &fakeRows{columns: []string{"table_schema", "table_name"}}
+		for _, name := range names {
+			schema, table, _ := strings.Cut(name, ".")
+			rows.rows = append(rows.rows, []driver.Value{schema, table})
+		}
+		return rows, nil
+	}
+	m := keyedStatement.FindStringSubmatch(query)
+	if m == nil || m[1] != "SELECT *" {
+		return nil, fmt.Errorf("unsupported query: %s", query)
+	}
+	t, matched := c.driver.rows(c.dsn, m[2]+"."+m[3], m[4], fmt.Sprint(args[0].Value), false)
+	if t == nil {
+		return nil, fmt.Errorf("relation %s.%s does not exist", m[2], m[3])
+	}
+	return &fakeRows{columns: t.columns, rows: matched}, nil
+}
+
+func (c *fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.driver.record(c.dsn, query)
+	m := keyedStatement.FindStringSubmatch(query)
+	if m == nil || m[1] != "DELETE" {
+		return nil, fmt.Errorf("unsupported statement: %s", query)
+	}
+	_, matched := c.driver.rows(c.dsn, m[2]+"."+m[3], m[4], fmt.Sprint(args[0].Value), true)
+	return driver.RowsAffected(len(matched)), nil
+}
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }
+
+type fakeRows struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+// staticShards lists a fixed set of shards, by client application
+type staticShards []models.Shard
+
+func (s staticShards) ListShardsForClient(clientAppID string) ([]models.Shard, error) {
+	var shards []models.Shard
+	for _, shard := range s {
+		if shard.ClientAppID == clientAppID {
+			shards = append(shards, shard)
+		}
+	}
+	return shards, nil
+}
+
+// fixture is two shards of the shop app and one of another app, each holding
+// rows of user-42 and of other users
+type fixture struct {
+	service *Service
+	shards  staticShards
+	audit   string
+}
+
+func newFixture(t *testing.T) *fixture {
+	t.Helper()
+	shard := func(id, app string) models.Shard {
+		return models.Shard{ID: id, ClientAppID: app, Status: models.ShardStatusActive, ShardKey: "user_id",
+			PrimaryEndpoint: fmt.Sprintf("postgres://%s-%s/app", t.Name(), id)}
+	}
+	f := &fixture{shards: staticShards{shard("shop-1", "shop"), shard("shop-2", "shop"), shard("crm-1", "crm")}}
+	// crm-1 is a MySQL shard
+	f.shards[2].Engine = models.EngineMySQL
+	f.shards[2].PrimaryEndpoint = ""
+	f.shards[2].Host = t.Name() + "-crm-1"
+	f.shards[2].Database = "app"
+
+	testDriver.mu.Lock()
+	for _, s := range f.shards {
+		testDriver.databases[s.ConnectionString("")] = map[string]*fakeTable{
+			"public.orders": {columns: []string{"id", "user_id", "total"}, rows: [][]driver.Value{
+				{s.ID + "-o1", "user-42", int64(10)},
+				{s.ID + "-o2", "user-7", int64(20)},
+			}},
+			"public.products": {columns: []string{"id", "name"}, rows: [][]driver.Value{{"p1", "user-42"}}},
+		}
+	}
+	// Rows for the key moved to shop-2 by a split also live in a second table there
+	testDriver.databases[f.shards[1].ConnectionString("")]["billing.invoices"] = &fakeTable{
+		columns: []string{"number", "user_id"},
+		rows:    [][]driver.Value{{"inv-1", "user-42"}, {"inv-2", "user-42"}},
+	}
+	testDriver.mu.Unlock()
+
+	f.audit = filepath.Join(t.TempDir(), "audit.log")
+	audit, err := security.NewAuditLogger(f.audit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { audit.Close() })
+
+	f.service = NewService(f.shards, zaptest.NewLogger(t))
+	f.service.driver = "privacytest"
+	f.service.SetAuditLogger(audit)
+	return f
+}
+
+func (f *fixture) auditEvents(t *testing.T) []security.AuditEvent {
+	t.Helper()
+	data, err := os.ReadFile(f.audit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var events []security.AuditEvent
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var event security.AuditEvent
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			t.Fatalf("invalid audit line %q: %v", line, err)
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+func TestService_ExportCollectsKeyedRowsFromAppShards(t *testing.T) {
+	f := newFixture(t)
+
+	export, err := f.service.Export(context.Background(), Request{ClientAppID: "shop", Key: "user-42", RequestedBy: "dpo"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	collected := make(map[string][]string) // shard -> table/id
+	for _, shard := range export.Shards {
+		for _, table := range shard.Tables {
+			for _, row := range table.Rows {
+				if row["user_id"] != "user-42" {
+					t.Errorf("expected only rows of user-42, got %v from %s", row, shard.ShardID)
+				}
+				id := row["id"]
+				if id == nil {
+					id = row["number"]
+				}
+				collected[shard.ShardID] = append(collected[shard.ShardID], fmt.Sprintf("%s/%v", table.Table, id))
+			}
+		}
+	}
+	if got := strings.Join(collected["shop-1"], ","); got != "public.orders/shop-1-o1" {
+		t.Errorf("unexpected rows from shop-1: %s", got)
+	}
+	if got := strings.Join(collected["shop-2"], ","); got != "billing.invoices/inv-1,billing.invoices/inv-2,public.orders/shop-2-o1" {
+		t.Errorf("unexpected rows from shop-2: %s", got)
+	}
+	if _, ok := collected["crm-1"]; ok || export.TotalRows != 4 {
+		t.Errorf("expected 4 rows from the shop shards only, got %d from %v", export.TotalRows, collected)
+	}
+
+	var archive bytes.Buffer
+	if err := export.WriteArchive(&archive); err != nil {
+		t.Fatalf("failed to write archive: %v", err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(archive.Bytes()), int64(archive.Len()))
+	if err != nil {
+		t.Fatalf("invalid archive: %v", err)
+	}
+	var files []string
+	for _, file := range zr.File {
+		files = append(files, file.Name)
+	}
+	if got := strings.Join(files, ","); got != "shards/shop-1/public.orders.json,shards/shop-2/billing.invoices.json,shards/shop-2/public.orders.json,manifest.json" {
+		t.Errorf("unexpected archive files %s", got)
+	}
+
+	events := f.auditEvents(t)
+	if len(events) != 1 || events[0].Action != AuditActionExport || events[0].ResourceID != "shop" || events[0].User != "dpo" || !events[0].Success {
+		t.Errorf("expected the export to be audited, got %+v", events)
+	}
+}
+
+func TestService_EraseDeletesKeyedRowsFromAppShards(t *testing.T) {
+	f := newFixture(t)
+	ctx := context.Background()
+
+	erasure, err := f.service.Erase(ctx, Request{ClientAppID: "shop", Key: "user-42", RequestedBy: "dpo"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if erasure.TotalRows != 4 || len(erasure.Shards) != 2 {
+		t.Errorf("expected 4 rows erased from the 2 shop shards, got %+v", erasure)
+	}
+
+	// Nothing is left for the key in the app, and everything else is untouched
+	if export, err := f.service.Export(ctx, Request{ClientAppID: "shop", Key: "user-42"}); err != nil || export.TotalRows != 0 {
+		t.Errorf("expected no rows left for user-42, got %+v (%v)", export, err)
+	}
+	if export, _ := f.service.Export(ctx, Request{ClientAppID: "shop", Key: "user-7"}); export.TotalRows != 2 {
+		t.Errorf("expected other users' rows to be kept, got %d", export.TotalRows)
+	}
+	if export, _ := f.service.Export(ctx, Request{ClientAppID: "crm", Key: "user-42"}); export.TotalRows != 1 {
+		t.Errorf("expected the other app's rows to be kept, got %d", export.TotalRows)
+	}
+
+	events := f.auditEvents(t)
+	if len(events) == 0 || events[0].Action != AuditActionErase || !events[0].Success {
+		t.Errorf("expected the erasure to be audited, got %+v", events)
+	}
+	for _, event := range events {
+		if strings.Contains(event.ResourceID+event.Error, "user-42") {
+			t.Errorf("expected the audit log not to name the data subject, got %+v", event)
+		}
+	}
+}
+
+func TestService_SkipsViewsAndPartitions(t *testing.T) {
+	f := newFixture(t)
+	ctx := context.Background()
+	dsn := f.shards[0].ConnectionString("")
+
+	// A view over the orders and a partition of them repeat rows the keyed
+	// base table already holds
+	testDriver.mu.Lock()
+	testDriver.databases[dsn]["public.big_orders"] = &fakeTable{
+		columns: []string{"id", "user_id"}, rows: [][]driver.Value{{"shop-1-o1", "user-42"}}, kind: "view",
+	}
+	testDriver.databases[dsn]["public.orders_2026"] = &fakeTable{
+		columns: []string{"id", "user_id"}, rows: [][]driver.Value{{"shop-1-o1", "user-42"}}, kind: "partition",
+	}
+	testDriver.mu.Unlock()
+
+	export, err := f.service.Export(ctx, Request{ClientAppID: "shop", Key: "user-42"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if export.TotalRows != 4 {
+		t.Errorf("expected each row exported once, got %d", export.TotalRows)
+	}
+	if _, err := f.service.Erase(ctx, Request{ClientAppID: "shop", Key: "user-42"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	testDriver.mu.Lock()
+	defer testDriver.mu.Unlock()
+	for _, query := range testDriver.queries[dsn] {
+		if strings.Contains(query, "big_orders") || strings.Contains(query, "orders_2026") {
+			t.Errorf("expected no statement against a view or partition, got %q", query)
+		}
+	}
+}
+
+func TestService_EraseUsesTheShardEngineDialect(t *testing.T) {
+	f := newFixture(t)
+
+	erasure, err := f.service.Erase(context.Background(), Request{ClientAppID: "crm", Key: "user-42"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if erasure.TotalRows != 1 {
+		t.Errorf("expected 1 row erased from the MySQL shard, got %+v", erasure)
+	}
+
+	testDriver.mu.Lock()
+	queries := testDriver.queries[f.shards[2].ConnectionString("")]
+	testDriver.mu.Unlock()
+	if len(queries) == 0 || queries[0] != mysqlTablesWithColumn {
+		t.Fatalf("expected the MySQL catalog query first, got %q", queries)
+	}
+	for _, query := range queries[1:] {
+		if !mysqlStatement.MatchString(query) {
+			t.Errorf("expected a MySQL statement, got %q", query)
+		}
+	}
+}
+
+func TestService_RejectsUnsupportedEngines(t *testing.T) {
+	f := newFixture(t)
+	f.shards[2].Engine = "oracle"
+	f.service = NewService(f.shards, zaptest.NewLogger(t))
+	f.service.driver = "privacytest"
+
+	erasure, err := f.service.Erase(context.Background(), Request{ClientAppID: "crm", Key: "user-42"})
+	if err == nil || erasure.TotalRows != 0 || !strings.Contains(erasure.Shards[0].Error, "not supported") {
+		t.Errorf("expected the shard to be refused, got %+v (%v)", erasure, err)
+	}
+}
+
+func TestService_EraseReportsUnreachableShards(t *testing.T) {
+	f := newFixture(t)
+	down := f.shards[0].ConnectionString("")
+	testDriver.mu.Lock()
+	testDriver.down[down] = true
+	testDriver.mu.Unlock()
+	t.Cleanup(func() {
+		testDriver.mu.Lock()
+		delete(testDriver.down, down)
+		testDriver.mu.Unlock()
+	})
+
+	erasure, err := f.service.Erase(context.Background(), Request{ClientAppID: "shop", Key: "user-42"})
+	if err == nil || !strings.Contains(err.Error(), "shop-1") {
+		t.Fatalf("expected the unreachable shard to be reported, got %v", err)
+	}
+	if erasure.Shards[0].Error == "" || erasure.Shards[1].Error != "" || erasure.TotalRows != 3 {
+		t.Errorf("expected the reachable shard to still be erased, got %+v", erasure)
+	}
+	if events := f.auditEvents(t); len(events) != 1 || events[0].Success {
+		t.Errorf("expected the partial erasure to be audited as failed, got %+v", events)
+	}
+
+	if _, err := f.service.Export(context.Background(), Request{ClientAppID: "shop", Key: "user-42"}); err == nil {
+		t.Error("expected an export missing a shard to fail")
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"net/http"
 
 	"github.com/gorilla/mux"
+	"github.com/sharding-system/pkg/listener"
 	"go.uber.org/zap"
 )
 
@@ -42,14 +43,22 @@ func (p *ShardingProxy) startAdminServer() error {
 	
 	// Stats
 	router.HandleFunc("/api/v1/stats", p.statsHandler).Methods("GET")
+
+	// Prometheus metrics for connection pooling
+	router.Handle("/metrics", p.metricsHandler()).Methods("GET")
 	
 	p.adminServer = &http.Server{
-		Addr:    p.config.AdminAddr,
-		Handler: router,
+		Addr:           p.config.AdminAddr,
+		Handler:        router,
+		MaxHeaderBytes: p.config.MaxHeaderBytes,
+	}
+	l, err := listener.TCP(p.config.AdminAddr, p.config.tcpOptions())
+	if err != nil {
+		return err
 	}
 	
 	go func() {
-		if err := p.adminServer.ListenAndServe(); err != http.ErrServerClosed {
+		if err := p.adminServer.Serve(l); err != http.ErrServerClosed {
 			p.logger.Error("admin server error", zap.Error(err))
 		}
 	}()
@@ -282,6 +291,7 @@ func (p *ShardingProxy) statsHandler(w http.ResponseWriter, r *http.Request) {
 		"shard_count":      len(shards),
 		"connection_pools": poolCount,
 		"databases":        len(p.config.ClientApps),
+		"statement_cache":  p.metrics.statementCacheStats(),
 	}
 	
 	w.Header().Set("Content-Type", "application/json")
@@ -0,0 +1,459 @@
+package proxy
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"math/big"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AggregateFunc is an aggregate function computed across shards
+type AggregateFunc string
+
+const (
+	AggregateCount AggregateFunc = "count"
+	AggregateSum   AggregateFunc = "sum"
+	AggregateAvg   AggregateFunc = "avg"
+	AggregateMin   AggregateFunc = "min"
+	AggregateMax   AggregateFunc = "max"
+)
+
+// Suffixes of the columns AVG's partial sum and count are returned in by each
+// shard
+const (
+	avgSumSuffix   = "__sum"
+	avgCountSuffix = "__count"
+)
+
+var columnNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Aggregate is one aggregate column of a cross-shard query, e.g. SUM(amount)
+// AS revenue
+type Aggregate struct {
+	Func AggregateFunc `json:"func"`
+	Expr string        `json:"expr"` // Column or expression aggregated; "*" for COUNT(*)
+	As   string        `json:"as"`   // Name of the result column
+}
+
+// AggregateQuery is an aggregation over a table sharded across shards. Each
+// shard computes partial aggregates of its rows, which are merged with the
+// semantics of running the query on a single node: counts and sums are
+// summed, AVG divides the summed sums by the summed counts rather than
+// averaging the shards' averages, and MIN and MAX take the extreme of the
+// shards' extremes. Groups are merged across shards before TOP-N ranking, so
+// a group split over several shards is ranked on its total.
+//
+// Table, Where, GroupBy and the aggregate expressions are copied into the SQL
+// sent to the shards and must not come from untrusted input, so queries are
+// built by application code rather than accepted over the admin API.
+type AggregateQuery struct {
+	Table      string      `json:"table"`
+	Where      string      `json:"where,omitempty"` // Condition without the WHERE keyword
+	GroupBy    []string    `json:"group_by,omitempty"`
+	Aggregates []Aggregate `json:"aggregates"`
+	OrderBy    string      `json:"order_by,omitempty"` // Group or aggregate column to rank the result by
+	Descending bool        `json:"descending,omitempty"`
+	Limit      int         `json:"limit,omitempty"` // Keep the top N rows; 0 keeps all
+}
+
+// Validate checks that the query can be computed across shards
+func (q AggregateQuery) Validate() error {
+	if q.Table == "" {
+		return fmt.Errorf("aggregate query needs a table")
+	}
+	if len(q.Aggregates) == 0 {
+		return fmt.Errorf("aggregate query needs at least one aggregate")
+	}
+	if q.Limit < 0 {
+		return fmt.Errorf("limit must not be negative, got %d", q.Limit)
+	}
+
+	columns := make(map[string]bool)
+	for _, column := range q.GroupBy {
+		if !columnNamePattern.MatchString(column) {
+			return fmt.Errorf("group by column %q must be a column name", column)
+		}
+		columns[column] = true
+	}
+	for _, agg := range q.Aggregates {
+		switch agg.Func {
+		case AggregateCount, AggregateSum, AggregateAvg, AggregateMin, AggregateMax:
+		default:
+			return fmt.Errorf("unsupported aggregate %q: must be count, sum, avg, min or max", agg.Func)
+		}
+		if agg.Expr == "" {
+			return fmt.Errorf("aggregate %s needs an expression", agg.As)
+		}
+		if agg.Expr == "*" && agg.Func != AggregateCount {
+			return fmt.Errorf("only count can aggregate *")
+		}
+		if !columnNamePattern.MatchString(agg.As) {
+			return fmt.Errorf("aggregate name %q must be a column name", agg.As)
+		}
+		if columns[agg.As] {
+			return fmt.Errorf("column %q appears twice", agg.As)
+		}
+		columns[agg.As] = true
+	}
+	if q.OrderBy != "" && !columns[q.OrderBy] {
+		return fmt.Errorf("order by %q must be a group by column or an aggregate", q.OrderBy)
+	}
+	return nil
+}
+
+// ShardSQL returns the query each shard runs to compute its partial
+// aggregates. TOP-N is applied after merging, as a shard's top groups need
+// not be the top groups overall.
+func (q AggregateQuery) ShardSQL() string {
+	selects := append([]string(nil), q.GroupBy...)
+	for _, agg := range q.Aggregates {
+		switch agg.Func {
+		case AggregateAvg:
+			selects = append(selects,
+				fmt.Sprintf("SUM(%s) AS %s", agg.Expr, agg.As+avgSumSuffix),
+				fmt.Sprintf("COUNT(%s) AS %s", agg.Expr, agg.As+avgCountSuffix))
+		default:
+			selects = append(selects, fmt.Sprintf("%s(%s) AS %s", strings.ToUpper(string(agg.Func)), agg.Expr, agg.As))
+		}
+	}
+
+	sql := fmt.Sprintf("SELECT %s FROM %s", strings.Join(selects, ", "), q.Table)
+	if q.Where != "" {
+		sql += " WHERE " + q.Where
+	}
+	if len(q.GroupBy) > 0 {
+		sql += " GROUP BY " + strings.Join(q.GroupBy, ", ")
+	}
+	return sql
+}
+
+// Columns returns the columns of the merged result
+func (q AggregateQuery) Columns() []string {
+	columns := append([]string(nil), q.GroupBy...)
+	for _, agg := range q.Aggregates {
+		columns = append(columns, agg.As)
+	}
+	return columns
+}
+
+// Merge combines the rows of partial aggregates returned by every shard's
+// ShardSQL into the query's result
+func (q AggregateQuery) Merge(rows []map[string]interface{}) ([]map[string]interface{}, error) {
+	type group struct {
+		values []interface{}
+		accs   []*aggregateAcc
+	}
+	newGroup := func(values []interface{}) *group {
+		g := &group{values: values, accs: make([]*aggregateAcc, len(q.Aggregates))}
+		for i := range g.accs {
+			g.accs[i] = &aggregateAcc{}
+		}
+		return g
+	}
+
+	groups := make(map[string]*group)
+	order := make([]string, 0)
+	for _, row := range rows {
+		values := make([]interface{}, len(q.GroupBy))
+		keys := make([]string, len(q.GroupBy))
+		for i, column := range q.GroupBy {
+			values[i] = normalizeValue(row[column])
+			keys[i] = fmt.Sprintf("%T:%v", values[i], values[i])
+		}
+		key := strings.Join(keys, "\x00")
+		g, ok := groups[key]
+		if !ok {
+			g = newGroup(values)
+			groups[key] = g
+			order = append(order, key)
+		}
+		for i, agg := range q.Aggregates {
+			if err := g.accs[i].add(agg, row); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// Without GROUP BY a single node returns one row even for no rows
+	if len(q.GroupBy) == 0 && len(order) == 0 {
+		groups[""] = newGroup(nil)
+		order = append(order, "")
+	}
+
+	merged := make([]map[string]interface{}, 0, len(order))
+	for _, key := range order {
+		g := groups[key]
+		row := make(map[string]interface{}, len(q.GroupBy)+len(q.Aggregates))
+		for i, column := range q.GroupBy {
+			row[column] = g.values[i]
+		}
+		for i, agg := range q.Aggregates {
+			row[agg.As] = g.accs[i].result(agg.Func)
+		}
+		merged = append(merged, row)
+	}
+
+	if q.OrderBy != "" {
+		sort.SliceStable(merged, func(i, j int) bool {
+			a, b := merged[i][q.OrderBy], merged[j][q.OrderBy]
+			// NULLs rank last either way
+			if a == nil || b == nil {
+				return a != nil && b == nil
+			}
+			if q.Descending {
+				return compareValues(a, b) > 0
+			}
+			return compareValues(a, b) < 0
+		})
+	}
+	if q.Limit > 0 && len(merged) > q.Limit {
+		merged = merged[:q.Limit]
+	}
+	return merged, nil
+}
+
+// aggregateAcc accumulates the partial aggregates of one group. Integer and
+// NUMERIC parts are summed exactly; only floating-point parts are summed as
+// floats.
+type aggregateAcc struct {
+	count    int64       // Rows counted, for COUNT and AVG
+	sum      big.Rat     // Exact sum of the integer and NUMERIC parts
+	floatSum float64     // Sum of the floating-point parts
+	kind     numberKind  // Widest kind of part summed
+	scale    int         // Most decimal places of a NUMERIC part
+	summed   bool        // Whether any non-NULL part was summed
+	extreme  interface{} // MIN or MAX so far
+}
+
+// add adds a shard's partial aggregate
+func (a *aggregateAcc) add(agg Aggregate, row map[string]interface{}) error {
+	switch agg.Func {
+	case AggregateCount:
+		n, err := partialCount(row, agg.As)
+		if err != nil {
+			return err
+		}
+		a.count += n
+	case AggregateSum:
+		return a.addSum(row[agg.As], agg.As)
+	case AggregateAvg:
+		n, err := partialCount(row, agg.As+avgCountSuffix)
+		if err != nil {
+			return err
+		}
+		a.count += n
+		return a.addSum(row[agg.As+avgSumSuffix], agg.As+avgSumSuffix)
+	case AggregateMin, AggregateMax:
+		value := normalizeValue(row[agg.As])
+		if value == nil {
+			return nil
+		}
+		if a.extreme == nil {
+			a.extreme = value
+			return nil
+		}
+		cmp := compareValues(value, a.extreme)
+		if (agg.Func == AggregateMin && cmp < 0) || (agg.Func == AggregateMax && cmp > 0) {
+			a.extreme = value
+		}
+	}
+	return nil
+}
+
+// addSum adds a partial sum, ignoring NULLs from shards without rows
+func (a *aggregateAcc) addSum(value interface{}, column string) error {
+	value = normalizeValue(value)
+	if value == nil {
+		return nil
+	}
+	n, ok := parseNumber(value)
+	if !ok {
+		return fmt.Errorf("partial sum %s is not a number: %v", column, value)
+	}
+	a.summed = true
+	a.kind = max(a.kind, n.kind)
+	a.scale = max(a.scale, n.scale)
+	if n.kind == numberFloat {
+		a.floatSum += n.float
+	} else {
+		a.sum.Add(&a.sum, n.rat)
+	}
+	return nil
+}
+
+// result returns the merged aggregate. Sums of integers are int64 while they
+// fit, and sums and averages of NUMERIC values are decimal strings, so no
+// precision is lost to float64. Averages of integers are decimal strings too,
+// as PostgreSQL's are NUMERIC.
+func (a *aggregateAcc) result(fn AggregateFunc) interface{} {
+	switch fn {
+	case AggregateCount:
+		return a.count
+	case AggregateSum:
+		if !a.summed {
+			return nil
+		}
+		switch a.kind {
+		case numberFloat:
+			return a.floatTotal()
+		case numberInt:
+			if a.sum.Num().IsInt64() {
+				return a.sum.Num().Int64()
+			}
+			return a.sum.Num().String()
+		}
+		return a.sum.FloatString(a.scale)
+	case AggregateAvg:
+		if a.count == 0 || !a.summed {
+			return nil
+		}
+		if a.kind == numberFloat {
+			return a.floatTotal() / float64(a.count)
+		}
+		avg := new(big.Rat).Quo(&a.sum, new(big.Rat).SetInt64(a.count))
+		return avg.FloatString(max(a.scale, avgScale))
+	}
+	return a.extreme
+}
+
+// floatTotal returns the sum of every part as a float, once a floating-point
+// part has made the sum inexact
+func (a *aggregateAcc) floatTotal() float64 {
+	exact, _ := a.sum.Float64()
+	return exact + a.floatSum
+}
+
+// avgScale is the fewest decimal places an exact average is given, as for
+// PostgreSQL's average of integers
+const avgScale = 16
+
+// numberKind is how exactly a number is represented, from most to least exact
+type numberKind int
+
+const (
+	numberInt numberKind = iota
+	numberDecimal
+	numberFloat
+)
+
+// number is a numeric driver value
+type number struct {
+	rat   *big.Rat // Exact value; nil for floats that have none, e.g. NaN
+	float float64  // Value as a float
+	kind  numberKind
+	scale int // Decimal places of a NUMERIC value
+}
+
+// compare orders two numbers, exactly unless either has no exact value
+func (n number) compare(o number) int {
+	if n.rat != nil && o.rat != nil {
+		return n.rat.Cmp(o.rat)
+	}
+	return cmp.Compare(n.float, o.float)
+}
+
+// partialCount returns a shard's partial count from a row
+func partialCount(row map[string]interface{}, column string) (int64, error) {
+	value := normalizeValue(row[column])
+	if value == nil {
+		return 0, nil
+	}
+	n, ok := parseNumber(value)
+	if !ok || n.kind != numberInt || !n.rat.Num().IsInt64() {
+		return 0, fmt.Errorf("partial count %s is not an integer: %v", column, value)
+	}
+	return n.rat.Num().Int64(), nil
+}
+
+// normalizeValue turns the byte slices drivers return for text and NUMERIC
+// columns into strings
+func normalizeValue(value interface{}) interface{} {
+	if b, ok := value.([]byte); ok {
+		return string(b)
+	}
+	return value
+}
+
+// parseNumber converts a driver value to a number. Text is parsed exactly
+// unless written with an exponent, as drivers return NUMERIC columns as text.
+func parseNumber(value interface{}) (number, bool) {
+	switch v := value.(type) {
+	case int64:
+		return number{rat: new(big.Rat).SetInt64(v), float: float64(v), kind: numberInt}, true
+	case int:
+		return parseNumber(int64(v))
+	case int32:
+		return parseNumber(int64(v))
+	case float64:
+		return number{rat: new(big.Rat).SetFloat64(v), float: v, kind: numberFloat}, true
+	case float32:
+		return parseNumber(float64(v))
+	case string:
+		if !strings.ContainsAny(v, "eE") {
+			if rat, ok := new(big.Rat).SetString(v); ok {
+				n := number{rat: rat, kind: numberInt}
+				n.float, _ = rat.Float64()
+				if dot := strings.IndexByte(v, '.'); dot >= 0 {
+					n.kind = numberDecimal
+					n.scale = len(v) - dot - 1
+				}
+				return n, true
+			}
+		}
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			return parseNumber(f)
+		}
+	}
+	return number{}, false
+}
+
+// compareValues orders two non-NULL values: numerically if both are numbers,
+// chronologically if both are times, and as text otherwise
+func compareValues(a, b interface{}) int {
+	if an, ok := parseNumber(a); ok {
+		if bn, ok := parseNumber(b); ok {
+			return an.compare(bn)
+		}
+	}
+	if at, ok := a.(time.Time); ok {
+		if bt, ok := b.(time.Time); ok {
+			return at.Compare(bt)
+		}
+	}
+	return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+}
+
+// ExecuteAggregate runs an aggregate query across every active shard of a
+// database and merges the shards' partial aggregates
+func (p *ShardingProxy) ExecuteAggregate(ctx context.Context, database string, q AggregateQuery) (*QueryResult, error) {
+	if err := q.Validate(); err != nil {
+		return nil, err
+	}
+	startTime := time.Now()
+
+	results, err := p.executeOnEveryShard(ctx, database, q.ShardSQL())
+	if err != nil {
+		return nil, fmt.Errorf("aggregate query failed: %w", err)
+	}
+	partials := make([]map[string]interface{}, 0, len(results))
+	for _, result := range results {
+		partials = append(partials, result.Rows...)
+	}
+
+	rows, err := q.Merge(partials)
+	if err != nil {
+		return nil, err
+	}
+	return &QueryResult{
+		Columns:   q.Columns(),
+		Rows:      rows,
+		RowCount:  len(rows),
+		RoutedTo:  "all_shards",
+		LatencyMs: float64(time.Since(startTime).Milliseconds()),
+	}, nil
+}
@@ -0,0 +1,286 @@
+package proxy
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+)
+
+// aggregateTestRows are orders spread unevenly over three shards, so merging
+// shortcuts such as averaging the shards' averages give wrong results
+var aggregateTestRows = [][]map[string]interface{}{
+	{
+		{"region": "eu", "customer": "ann", "amount": int64(10)},
+		{"region": "eu", "customer": "bob", "amount": int64(20)},
+		{"region": "us", "customer": "cat", "amount": int64(300)},
+	},
+	{
+		{"region": "eu", "customer": "ann", "amount": int64(40)},
+		{"region": "us", "customer": "dan", "amount": nil},
+	},
+	{
+		{"region": "apac", "customer": "eve", "amount": int64(5)},
+		{"region": "us", "customer": "cat", "amount": int64(1)},
+		{"region": "us", "customer": "fay", "amount": int64(2)},
+		{"region": "us", "customer": "fay", "amount": int64(3)},
+	},
+}
+
+// evaluateAggregate computes q over rows as a single node would. With
+// partial set it returns what a shard's ShardSQL returns instead.
+func evaluateAggregate(t *testing.T, q AggregateQuery, rows []map[string]interface{}, partial bool) []map[string]interface{} {
+	t.Helper()
+	groups := make(map[string][]map[string]interface{})
+	order := make([]string, 0)
+	for _, row := range rows {
+		key := ""
+		for _, column := range q.GroupBy {
+			key += row[column].(string) + "/"
+		}
+		if _, ok := groups[key]; !ok {
+			order = append(order, key)
+		}
+		groups[key] = append(groups[key], row)
+	}
+	if len(q.GroupBy) == 0 && len(order) == 0 {
+		order = append(order, "")
+	}
+
+	result := make([]map[string]interface{}, 0, len(order))
+	for _, key := range order {
+		members := groups[key]
+		out := make(map[string]interface{})
+		for _, column := range q.GroupBy {
+			out[column] = members[0][column]
+		}
+		for _, agg := range q.Aggregates {
+			var count, sum int64
+			var min, max interface{}
+			for _, row := range members {
+				value := row[agg.Expr]
+				if agg.Expr == "*" {
+					count++
+					continue
+				}
+				if value == nil {
+					continue
+				}
+				n := value.(int64)
+				count++
+				sum += n
+				if min == nil || n < min.(int64) {
+					min = n
+				}
+				if max == nil || n > max.(int64) {
+					max = n
+				}
+			}
+			var sumValue interface{}
+			if count > 0 {
+				sumValue = sum
+			}
+			switch agg.Func {
+			case AggregateCount:
+				out[agg.As] = count
+			case AggregateSum:
+				out[agg.As] = sumValue
+			case AggregateMin:
+				out[agg.As] = min
+			case AggregateMax:
+				out[agg.As] = max
+			case AggregateAvg:
+				if partial {
+					out[agg.As+avgSumSuffix] = sumValue
+					out[agg.As+avgCountSuffix] = count
+				} else if count > 0 {
+					out[agg.As] = new(big.Rat).SetFrac64(sum, count).FloatString(avgScale)
+				} else {
+					out[agg.As] = nil
+				}
+			}
+		}
+		result = append(result, out)
+	}
+	return result
+}
+
+// mergeAcrossShards merges the partial aggregates of every test shard
+func mergeAcrossShards(t *testing.T, q AggregateQuery) []map[string]interface{} {
+	t.Helper()
+	if err := q.Validate(); err != nil {
+		t.Fatalf("invalid query: %v", err)
+	}
+	partials := make([]map[string]interface{}, 0)
+	for _, shard := range aggregateTestRows {
+		partials = append(partials, evaluateAggregate(t, q, shard, true)...)
+	}
+	merged, err := q.Merge(partials)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return merged
+}
+
+// singleNode computes q over every test row at once
+func singleNode(t *testing.T, q AggregateQuery) []map[string]interface{} {
+	t.Helper()
+	all := make([]map[string]interface{}, 0)
+	for _, shard := range aggregateTestRows {
+		all = append(all, shard...)
+	}
+	return evaluateAggregate(t, q, all, false)
+}
+
+// byGroup indexes result rows by their region
+func byGroup(rows []map[string]interface{}) map[interface{}]map[string]interface{} {
+	indexed := make(map[interface{}]map[string]interface{}, len(rows))
+	for _, row := range rows {
+		indexed[row["region"]] = row
+	}
+	return indexed
+}
+
+func TestAggregateQuery_MatchesSingleNode(t *testing.T) {
+	for _, agg := range []Aggregate{
+		{Func: AggregateCount, Expr: "*", As: "orders"},
+		{Func: AggregateCount, Expr: "amount", As: "priced"},
+		{Func: AggregateSum, Expr: "amount", As: "revenue"},
+		{Func: AggregateAvg, Expr: "amount", As: "average"},
+		{Func: AggregateMin, Expr: "amount", As: "smallest"},
+		{Func: AggregateMax, Expr: "amount", As: "largest"},
+	} {
+		t.Run(string(agg.Func)+"_"+agg.As, func(t *testing.T) {
+			// Over the whole table
+			q := AggregateQuery{Table: "orders", Aggregates: []Aggregate{agg}}
+			if got, want := mergeAcrossShards(t, q), singleNode(t, q); !reflect.DeepEqual(got, want) {
+				t.Errorf("expected %v, got %v", want, got)
+			}
+
+			// Per group, where groups span shards
+			q.GroupBy = []string{"region"}
+			got, want := byGroup(mergeAcrossShards(t, q)), byGroup(singleNode(t, q))
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("expected %v, got %v", want, got)
+			}
+		})
+	}
+}
+
+func TestAggregateQuery_AvgIsNotAverageOfAverages(t *testing.T) {
+	q := AggregateQuery{Table: "orders", Aggregates: []Aggregate{{Func: AggregateAvg, Expr: "amount", As: "average"}}}
+
+	// 381 over 8 priced orders, where the shards' averages average to 50.9
+	if got := mergeAcrossShards(t, q)[0]["average"]; got != "47.6250000000000000" {
+		t.Errorf("expected an average of 47.625, got %v", got)
+	}
+}
+
+func TestAggregateQuery_TopN(t *testing.T) {
+	q := AggregateQuery{
+		Table:      "orders",
+		GroupBy:    []string{"customer"},
+		Aggregates: []Aggregate{{Func: AggregateSum, Expr: "amount", As: "revenue"}},
+		OrderBy:    "revenue",
+		Descending: true,
+		Limit:      2,
+	}
+
+	// ann's 50 is split over two shards; on the first her 10 ranks below bob's 20
+	got := mergeAcrossShards(t, q)
+	if len(got) != 2 || got[0]["customer"] != "cat" || got[1]["customer"] != "ann" {
+		t.Fatalf("expected cat and then ann, got %v", got)
+	}
+	if got[0]["revenue"] != int64(301) || got[1]["revenue"] != int64(50) {
+		t.Errorf("expected revenues of 301 and 50, got %v", got)
+	}
+}
+
+func TestAggregateQuery_MergesNumericText(t *testing.T) {
+	// PostgreSQL returns SUM of integers as NUMERIC, which drivers hand over as text
+	q := AggregateQuery{Table: "orders", Aggregates: []Aggregate{
+		{Func: AggregateSum, Expr: "amount", As: "revenue"},
+		{Func: AggregateAvg, Expr: "amount", As: "average"},
+	}}
+	merged, err := q.Merge([]map[string]interface{}{
+		{"revenue": []byte("10"), "average__sum": []byte("10"), "average__count": int64(4)},
+		{"revenue": []byte("2.5"), "average__sum": []byte("2.5"), "average__count": int64(1)},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if merged[0]["revenue"] != "12.5" || merged[0]["average"] != "2.5000000000000000" {
+		t.Errorf("expected a revenue of 12.5 averaging 2.5, got %v", merged[0])
+	}
+}
+
+func TestAggregateQuery_KeepsNumericPrecision(t *testing.T) {
+	q := AggregateQuery{Table: "orders", Aggregates: []Aggregate{
+		{Func: AggregateSum, Expr: "amount", As: "revenue"},
+		{Func: AggregateSum, Expr: "quantity", As: "units"},
+		{Func: AggregateMax, Expr: "amount", As: "largest"},
+	}}
+	// Every value here is beyond float64's 53 bits of precision
+	merged, err := q.Merge([]map[string]interface{}{
+		{"revenue": []byte("12345678901234567.89"), "units": []byte("9223372036854775807"), "largest": []byte("9007199254740993")},
+		{"revenue": []byte("0.01"), "units": []byte("9223372036854775807"), "largest": []byte("9007199254740992")},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := merged[0]["revenue"]; got != "12345678901234567.90" {
+		t.Errorf("expected the NUMERIC sum to be exact, got %v", got)
+	}
+	if got := merged[0]["units"]; got != "18446744073709551614" {
+		t.Errorf("expected a sum overflowing int64 to be exact, got %v", got)
+	}
+	if got := merged[0]["largest"]; got != "9007199254740993" {
+		t.Errorf("expected the largest NUMERIC value, got %v", got)
+	}
+}
+
+func TestAggregateQuery_SumsFloatsAsFloats(t *testing.T) {
+	q := AggregateQuery{Table: "readings", Aggregates: []Aggregate{{Func: AggregateSum, Expr: "value", As: "total"}}}
+	merged, err := q.Merge([]map[string]interface{}{{"total": 1.5}, {"total": int64(2)}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := merged[0]["total"]; got != 3.5 {
+		t.Errorf("expected a float sum of 3.5, got %v", got)
+	}
+}
+
+func TestAggregateQuery_ShardSQL(t *testing.T) {
+	q := AggregateQuery{
+		Table:   "orders",
+		Where:   "status = 'paid'",
+		GroupBy: []string{"region"},
+		Aggregates: []Aggregate{
+			{Func: AggregateCount, Expr: "*", As: "orders"},
+			{Func: AggregateAvg, Expr: "amount", As: "average"},
+		},
+		OrderBy: "orders",
+		Limit:   10,
+	}
+
+	want := "SELECT region, COUNT(*) AS orders, SUM(amount) AS average__sum, COUNT(amount) AS average__count FROM orders WHERE status = 'paid' GROUP BY region"
+	if got := q.ShardSQL(); got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestAggregateQuery_Validate(t *testing.T) {
+	valid := Aggregate{Func: AggregateSum, Expr: "amount", As: "revenue"}
+	for name, q := range map[string]AggregateQuery{
+		"no table":         {Aggregates: []Aggregate{valid}},
+		"no aggregates":    {Table: "orders"},
+		"unknown function": {Table: "orders", Aggregates: []Aggregate{{Func: "median", Expr: "amount", As: "m"}}},
+		"sum of *":         {Table: "orders", Aggregates: []Aggregate{{Func: AggregateSum, Expr: "*", As: "s"}}},
+		"bad alias":        {Table: "orders", Aggregates: []Aggregate{{Func: AggregateSum, Expr: "amount", As: "a; DROP"}}},
+		"unknown order":    {Table: "orders", Aggregates: []Aggregate{valid}, OrderBy: "amount"},
+		"negative limit":   {Table: "orders", Aggregates: []Aggregate{valid}, Limit: -1},
+	} {
+		if err := q.Validate(); err == nil {
+			t.Errorf("%s: expected the query to be refused", name)
+		}
+	}
+}
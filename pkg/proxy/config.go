@@ -1,17 +1,27 @@
 package proxy
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"net"
+	"net/url"
 	"os"
+	"regexp"
+	"sort"
+	"strconv"
 	"sync"
+	"time"
+
+	"github.com/sharding-system/pkg/config"
+	"github.com/sharding-system/pkg/listener"
 )
 
 // ShardingRule defines how a table should be sharded
 type ShardingRule struct {
 	Table       string `json:"table"`
-	ShardKey    string `json:"shard_key"`     // Column to shard by (e.g., "user_id")
-	Strategy    string `json:"strategy"`      // "hash", "range", "broadcast"
+	ShardKey    string `json:"shard_key"` // Column to shard by (e.g., "user_id")
+	Strategy    string `json:"strategy"`  // "hash", "range", "broadcast"
 	Description string `json:"description"`
 }
 
@@ -19,18 +29,58 @@ type ShardingRule struct {
 type ClientAppConfig struct {
 	ID            string         `json:"id"`
 	Name          string         `json:"name"`
-	Database      string         `json:"database"`       // Database name
-	ShardingRules []ShardingRule `json:"sharding_rules"` // Table-level sharding rules
-	DefaultShard  string         `json:"default_shard"`  // Default shard for unsharded tables
+	Database      string         `json:"database"`              // Database name
+	ShardingRules []ShardingRule `json:"sharding_rules"`        // Table-level sharding rules
+	DefaultShard  string         `json:"default_shard"`         // Default shard for unsharded tables
+	PoolMode      PoolMode       `json:"pool_mode,omitempty"`   // Overrides the proxy's pool mode for this app
+	MaxFanOut     int            `json:"max_fan_out,omitempty"` // Overrides the proxy's max_fan_out, up to its max_fan_out_ceiling; negative asks for no limit
 }
 
 // ProxyConfig holds the proxy server configuration
 type ProxyConfig struct {
-	ListenAddr    string                      `json:"listen_addr"`    // e.g., ":5432"
-	AdminAddr     string                      `json:"admin_addr"`     // e.g., ":8082"
-	ManagerURL    string                      `json:"manager_url"`    // Sharding manager URL
-	ClientApps    map[string]*ClientAppConfig `json:"client_apps"`    // App configs by database name
-	mu            sync.RWMutex
+	ListenAddr   string                      `json:"listen_addr"`   // e.g., ":5432"
+	ListenSocket string                      `json:"listen_socket"` // Unix socket path, e.g., "/var/run/sharding/.s.PGSQL.5432"
+	AdminAddr    string                      `json:"admin_addr"`    // e.g., ":8082"
+	ManagerURL   string                      `json:"manager_url"`   // Sharding manager URL
+	ClientApps   map[string]*ClientAppConfig `json:"client_apps"`   // App configs by database name
+	PoolMode     PoolMode                    `json:"pool_mode"`     // "session", "transaction" or "statement"; client apps may override it
+
+	// TCP tuning for the proxy and admin listeners
+	TCPKeepAliveSeconds int `json:"tcp_keepalive_seconds"` // Keepalive probe interval; 0 keeps the 15s default, negative disables keepalive
+	MaxHeaderBytes      int `json:"max_header_bytes"`      // Admin API request header limit; 0 keeps the 1MB default
+
+	// MaxPreparedStatements caps the statements kept prepared on each backend
+	// connection held in session pooling. Statement caching is off unless it
+	// is positive: every distinct query text is prepared, so it only pays off
+	// for clients that send parameterized queries.
+	MaxPreparedStatements int `json:"max_prepared_statements"`
+
+	// MaxFanOut caps the shards a query without a routable shard key may
+	// scatter to; 0 or negative leaves it unlimited.
+	// Client apps may set their own limit, up to MaxFanOutCeiling; 0 only lets
+	// them lower MaxFanOut, negative lets them set any.
+	MaxFanOut        int `json:"max_fan_out"`
+	MaxFanOutCeiling int `json:"max_fan_out_ceiling"`
+
+	mu sync.RWMutex
+}
+
+// tcpOptions returns the TCP tuning for the proxy's listeners
+func (c *ProxyConfig) tcpOptions() listener.TCPOptions {
+	return listener.TCPOptions{
+		KeepAlive: time.Duration(c.TCPKeepAliveSeconds) * time.Second,
+	}
+}
+
+// maxPreparedStatements returns how many statements may be kept prepared on
+// a backend connection, 0 if statements are not cached
+func (c *ProxyConfig) maxPreparedStatements() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.MaxPreparedStatements < 0 {
+		return 0
+	}
+	return c.MaxPreparedStatements
 }
 
 // NewProxyConfig creates a new proxy configuration
@@ -40,6 +90,7 @@ func NewProxyConfig() *ProxyConfig {
 		AdminAddr:  ":8082",
 		ManagerURL: "http://localhost:8081",
 		ClientApps: make(map[string]*ClientAppConfig),
+		PoolMode:   DefaultPoolMode,
 	}
 }
 
@@ -49,11 +100,82 @@ func (c *ProxyConfig) LoadFromFile(path string) error {
 	if err != nil {
 		return fmt.Errorf("failed to read config file: %w", err)
 	}
-	
+
 	if err := json.Unmarshal(data, c); err != nil {
 		return fmt.Errorf("failed to parse config: %w", err)
 	}
-	
+
+	return nil
+}
+
+// Validate checks the proxy's listener addresses and manager URL, returning
+// a *config.ValidationError listing every problem found
+func (c *ProxyConfig) Validate() error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var problems []string
+	report := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if c.ListenAddr == "" && c.ListenSocket == "" {
+		report("listen_addr or listen_socket is required")
+	}
+	if c.ListenAddr != "" {
+		if err := validateListenAddr(c.ListenAddr); err != nil {
+			report("listen_addr %q is invalid: %v", c.ListenAddr, err)
+		}
+	}
+	if c.AdminAddr == "" {
+		report("admin_addr is required")
+	} else if err := validateListenAddr(c.AdminAddr); err != nil {
+		report("admin_addr %q is invalid: %v", c.AdminAddr, err)
+	}
+	if u, err := url.Parse(c.ManagerURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		report("manager_url must be an http or https URL, got %q", c.ManagerURL)
+	}
+	if c.MaxHeaderBytes < 0 {
+		report("max_header_bytes must not be negative, got %d", c.MaxHeaderBytes)
+	}
+	if c.PoolMode != "" && !validPoolMode(c.PoolMode) {
+		report("pool_mode must be session, transaction or statement, got %q", c.PoolMode)
+	}
+	databases := make([]string, 0, len(c.ClientApps))
+	for database := range c.ClientApps {
+		databases = append(databases, database)
+	}
+	sort.Strings(databases)
+	limit := fanOutLimit(c.MaxFanOut)
+	ceiling := c.fanOutCeiling(limit)
+	for _, database := range databases {
+		app := c.ClientApps[database]
+		if app == nil {
+			continue
+		}
+		if app.PoolMode != "" && !validPoolMode(app.PoolMode) {
+			report("client_apps.%s.pool_mode must be session, transaction or statement, got %q", database, app.PoolMode)
+		}
+		if app.MaxFanOut != 0 && ceiling != 0 && (app.MaxFanOut < 0 || app.MaxFanOut > ceiling) {
+			report("client_apps.%s.max_fan_out must be between 1 and the ceiling of %d, got %d", database, ceiling, app.MaxFanOut)
+		}
+	}
+
+	if len(problems) > 0 {
+		return &config.ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// validateListenAddr checks a "host:port" listen address
+func validateListenAddr(addr string) error {
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 0 || n > 65535 {
+		return fmt.Errorf("port must be between 0 and 65535")
+	}
 	return nil
 }
 
@@ -61,12 +183,12 @@ func (c *ProxyConfig) LoadFromFile(path string) error {
 func (c *ProxyConfig) SaveToFile(path string) error {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
-	
+
 	data, err := json.MarshalIndent(c, "", "  ")
 	if err != nil {
 		return err
 	}
-	
+
 	return os.WriteFile(path, data, 0644)
 }
 
@@ -84,6 +206,20 @@ func (c *ProxyConfig) SetAppConfig(database string, config *ClientAppConfig) {
 	c.ClientApps[database] = config
 }
 
+// PoolModeFor returns the pool mode of a database's client app: its own, or
+// the proxy's if it sets none
+func (c *ProxyConfig) PoolModeFor(database string) PoolMode {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if app := c.ClientApps[database]; app != nil && app.PoolMode != "" {
+		return app.PoolMode
+	}
+	if c.PoolMode != "" {
+		return c.PoolMode
+	}
+	return DefaultPoolMode
+}
+
 // GetShardingRule returns the sharding rule for a table
 func (c *ClientAppConfig) GetShardingRule(table string) *ShardingRule {
 	for i := range c.ShardingRules {
@@ -116,3 +252,116 @@ func (c *ClientAppConfig) RemoveShardingRule(table string) {
 	c.ShardingRules = rules
 }
 
+// The SQL parser extracts unquoted names from queries, lowercasing table names
+// and comparing columns case-insensitively; rules naming anything else never
+// match a query
+var (
+	tableIdentifier  = regexp.MustCompile(`^[a-z_][a-z0-9_]*$`)
+	columnIdentifier = regexp.MustCompile(`(?i)^[a-z_][a-z0-9_]*$`)
+)
+
+// RuleProblem is a problem found while validating sharding rules
+type RuleProblem struct {
+	Database string `json:"database"`
+	Table    string `json:"table,omitempty"`
+	Message  string `json:"message"`
+}
+
+func (p RuleProblem) String() string {
+	if p.Table == "" {
+		return fmt.Sprintf("%s: %s", p.Database, p.Message)
+	}
+	return fmt.Sprintf("%s.%s: %s", p.Database, p.Table, p.Message)
+}
+
+// ValidateRuleFile parses a rule file strictly, rejecting unknown fields, and
+// checks its sharding rules without starting the proxy. A file that cannot be
+// parsed returns an error; semantic problems are returned as a list.
+func ValidateRuleFile(path string) (*ProxyConfig, []RuleProblem, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read rule file: %w", err)
+	}
+
+	config := NewProxyConfig()
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(config); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse rule file: %w", err)
+	}
+	return config, config.ValidateRules(), nil
+}
+
+// ValidateRules checks every client app's sharding rules: tables and shard
+// keys must be names the proxy can match in queries, strategies must be
+// known, and key-based strategies need a shard key
+func (c *ProxyConfig) ValidateRules() []RuleProblem {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var problems []RuleProblem
+	if len(c.ClientApps) == 0 {
+		problems = append(problems, RuleProblem{Database: "*", Message: "no client apps are defined"})
+	}
+
+	databases := make([]string, 0, len(c.ClientApps))
+	for database := range c.ClientApps {
+		databases = append(databases, database)
+	}
+	sort.Strings(databases)
+
+	ceiling := c.fanOutCeiling(fanOutLimit(c.MaxFanOut))
+	for _, database := range databases {
+		app := c.ClientApps[database]
+		report := func(table, format string, args ...interface{}) {
+			problems = append(problems, RuleProblem{Database: database, Table: table, Message: fmt.Sprintf(format, args...)})
+		}
+
+		if app == nil {
+			report("", "client app has no configuration")
+			continue
+		}
+		if app.Database != "" && app.Database != database {
+			report("", "database %q does not match the client app's key", app.Database)
+		}
+		if app.PoolMode != "" && !validPoolMode(app.PoolMode) {
+			report("", "unknown pool mode %q: must be session, transaction or statement", app.PoolMode)
+		}
+		if app.MaxFanOut != 0 && ceiling != 0 && (app.MaxFanOut < 0 || app.MaxFanOut > ceiling) {
+			report("", "max fan-out %d is outside the proxy's ceiling of %d", app.MaxFanOut, ceiling)
+		}
+
+		seen := make(map[string]bool, len(app.ShardingRules))
+		for i, rule := range app.ShardingRules {
+			table := rule.Table
+			switch {
+			case table == "":
+				report(fmt.Sprintf("rule[%d]", i), "table is required")
+				continue
+			case !tableIdentifier.MatchString(table):
+				report(table, "table must be an unquoted lowercase name to match queries")
+			case seen[table]:
+				report(table, "duplicate rule; only the first rule for a table is used")
+			}
+			seen[table] = true
+
+			switch rule.Strategy {
+			case "hash", "range":
+				if rule.ShardKey == "" {
+					report(table, "%s strategy requires a shard key", rule.Strategy)
+				} else if !columnIdentifier.MatchString(rule.ShardKey) {
+					report(table, "shard key %q must be an unquoted column name", rule.ShardKey)
+				}
+			case "broadcast":
+				if rule.ShardKey != "" {
+					report(table, "broadcast strategy ignores shard key %q", rule.ShardKey)
+				}
+			case "":
+				report(table, "strategy is required: hash, range or broadcast")
+			default:
+				report(table, "unknown strategy %q: must be hash, range or broadcast", rule.Strategy)
+			}
+		}
+	}
+	return problems
+}
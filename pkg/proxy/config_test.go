@@ -0,0 +1,146 @@
+package proxy
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeRuleFile writes contents to a rule file in a temporary directory
+func writeRuleFile(t *testing.T, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "rules.json")
+	if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatalf("failed to write rule file: %v", err)
+	}
+	return path
+}
+
+func TestValidateRuleFile_Valid(t *testing.T) {
+	for _, path := range []string{"../../configs/proxy.json", writeRuleFile(t, `{
+		"client_apps": {
+			"orders_db": {
+				"database": "orders_db",
+				"sharding_rules": [
+					{"table": "orders", "shard_key": "Customer_ID", "strategy": "hash"},
+					{"table": "events", "shard_key": "created_day", "strategy": "range"},
+					{"table": "countries", "strategy": "broadcast"}
+				]
+			}
+		}
+	}`)} {
+		config, problems, err := ValidateRuleFile(path)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", path, err)
+		}
+		if len(problems) != 0 {
+			t.Errorf("%s: expected no problems, got %v", path, problems)
+		}
+		if len(config.ClientApps) == 0 {
+			t.Errorf("%s: expected client apps to be loaded", path)
+		}
+	}
+}
+
+func TestValidateRuleFile_SemanticProblems(t *testing.T) {
+	path := writeRuleFile(t, `{
+		"client_apps": {
+			"orders_db": {
+				"database": "billing_db",
+				"sharding_rules": [
+					{"table": "orders", "shard_key": "customer_id", "strategy": "hsah"},
+					{"table": "orders", "shard_key": "customer_id", "strategy": "hash"},
+					{"table": "Users", "shard_key": "user_id", "strategy": "hash"},
+					{"table": "payments", "strategy": "range"},
+					{"table": "invoices", "shard_key": "customer-id", "strategy": "hash"},
+					{"table": "countries", "shard_key": "code", "strategy": "broadcast"},
+					{"table": "refunds", "shard_key": "customer_id"},
+					{"shard_key": "id", "strategy": "hash"}
+				]
+			}
+		}
+	}`)
+
+	_, problems, err := ValidateRuleFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []string{
+		`orders_db: database "billing_db" does not match`,
+		`orders_db.orders: unknown strategy "hsah"`,
+		`orders_db.orders: duplicate rule`,
+		`orders_db.Users: table must be an unquoted lowercase name`,
+		`orders_db.payments: range strategy requires a shard key`,
+		`orders_db.invoices: shard key "customer-id"`,
+		`orders_db.countries: broadcast strategy ignores shard key`,
+		`orders_db.refunds: strategy is required`,
+		`orders_db.rule[7]: table is required`,
+	}
+	if len(problems) != len(want) {
+		t.Fatalf("expected %d problems, got %d: %v", len(want), len(problems), problems)
+	}
+	for i, problem := range problems {
+		if !strings.HasPrefix(problem.String(), want[i]) {
+			t.Errorf("problem %d: expected %q, got %q", i, want[i], problem.String())
+		}
+	}
+}
+
+func TestValidateRuleFile_ParseErrors(t *testing.T) {
+	files := map[string]string{
+		"unknown field": `{"client_apps": {"orders_db": {"sharding_rule": []}}}`,
+		"malformed":     `{"client_apps": {`,
+		"wrong type":    `{"client_apps": {"orders_db": {"sharding_rules": {"table": "orders"}}}}`,
+	}
+	for name, contents := range files {
+		if _, _, err := ValidateRuleFile(writeRuleFile(t, contents)); err == nil {
+			t.Errorf("%s: expected a parse error", name)
+		}
+	}
+
+	if _, _, err := ValidateRuleFile(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
+
+func TestValidateRules_NoClientApps(t *testing.T) {
+	problems := NewProxyConfig().ValidateRules()
+	if len(problems) != 1 || !strings.Contains(problems[0].Message, "no client apps") {
+		t.Errorf("expected a problem for a config without client apps, got %v", problems)
+	}
+}
+
+func TestProxyConfig_Validate(t *testing.T) {
+	if err := NewProxyConfig().Validate(); err != nil {
+		t.Fatalf("expected the defaults to be valid, got %v", err)
+	}
+
+	config := NewProxyConfig()
+	config.ListenAddr = ""
+	config.AdminAddr = "8082"
+	config.ManagerURL = "localhost:8081"
+	config.MaxHeaderBytes = -1
+	err := config.Validate()
+	if err == nil {
+		t.Fatal("expected an invalid config to be rejected")
+	}
+	for _, want := range []string{
+		"listen_addr or listen_socket is required",
+		`admin_addr "8082" is invalid`,
+		`manager_url must be an http or https URL, got "localhost:8081"`,
+		"max_header_bytes must not be negative, got -1",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected %q in %q", want, err.Error())
+		}
+	}
+
+	config = NewProxyConfig()
+	config.ListenAddr = ""
+	config.ListenSocket = "/var/run/sharding/.s.PGSQL.5432"
+	if err := config.Validate(); err != nil {
+		t.Errorf("expected a socket alone to be enough, got %v", err)
+	}
+}
@@ -0,0 +1,318 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrInvalidCursor is returned for cursor tokens that were not issued for the
+// keyset query they are used with
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// KeysetQuery is an ordered scan of a table sharded across shards, paged
+// through with keyset pagination: each page starts after the key of the last
+// row of the previous one instead of skipping OFFSET rows, which every shard
+// would have to read and cannot be applied per shard anyway. Each shard
+// returns the first rows of its own ordered stream after the cursor and the
+// streams are merged, so pages follow the global order without duplicates or
+// gaps.
+//
+// The key columns must be NOT NULL and identify a row together, e.g. a
+// timestamp followed by the primary key. Keys are merged by the type of their
+// column: numbers, NUMERIC included, exactly by value, and text in byte order
+// even when it looks like a number, so text keys should be ordered with the
+// "C" collation on the shards. Table,
+// Columns, Where and Key are copied into the SQL sent to the shards and must
+// not come from untrusted input.
+type KeysetQuery struct {
+	Table      string   `json:"table"`
+	Columns    []string `json:"columns,omitempty"` // Columns returned; all when empty
+	Where      string   `json:"where,omitempty"`   // Condition without the WHERE keyword
+	Key        []string `json:"key"`               // Columns the scan is ordered by
+	Descending bool     `json:"descending,omitempty"`
+	PageSize   int      `json:"page_size"`
+
+	keyTypes map[string]string // Database type name of the columns, as the shards report them
+}
+
+// KeysetPage is one page of a keyset scan
+type KeysetPage struct {
+	Columns    []string                 `json:"columns,omitempty"`
+	Rows       []map[string]interface{} `json:"rows"`
+	NextCursor string                   `json:"next_cursor,omitempty"` // Empty on the last page
+}
+
+// keysetCursor is the content of a cursor token: the key of the last row of
+// a page, and the ordering it was taken in
+type keysetCursor struct {
+	Key        []string      `json:"k"`
+	Descending bool          `json:"d,omitempty"`
+	After      []interface{} `json:"a"`
+}
+
+// Validate checks that the query can be paged through
+func (q KeysetQuery) Validate() error {
+	if q.Table == "" {
+		return fmt.Errorf("keyset query needs a table")
+	}
+	if len(q.Key) == 0 {
+		return fmt.Errorf("keyset query needs key columns")
+	}
+	for _, column := range q.Key {
+		if !columnNamePattern.MatchString(column) {
+			return fmt.Errorf("key column %q must be a column name", column)
+		}
+		if len(q.Columns) > 0 && !containsColumn(q.Columns, column) {
+			return fmt.Errorf("key column %s must be one of the columns returned, as pages resume after it", column)
+		}
+	}
+	if q.PageSize < 1 {
+		return fmt.Errorf("page size must be at least 1, got %d", q.PageSize)
+	}
+	return nil
+}
+
+// ShardSQL returns the query each shard runs for a page starting after the
+// key after, nil for the first page. The shard returns one row more than a
+// page, telling whether there is another page.
+func (q KeysetQuery) ShardSQL(after []interface{}) (string, []interface{}) {
+	columns := "*"
+	if len(q.Columns) > 0 {
+		columns = strings.Join(q.Columns, ", ")
+	}
+	conditions := make([]string, 0, 2)
+	if q.Where != "" {
+		conditions = append(conditions, "("+q.Where+")")
+	}
+	if after != nil {
+		placeholders := make([]string, len(q.Key))
+		for i := range placeholders {
+			placeholders[i] = fmt.Sprintf("$%d", i+1)
+		}
+		op := ">"
+		if q.Descending {
+			op = "<"
+		}
+		conditions = append(conditions, fmt.Sprintf("(%s) %s (%s)",
+			strings.Join(q.Key, ", "), op, strings.Join(placeholders, ", ")))
+	}
+	order := make([]string, len(q.Key))
+	for i, column := range q.Key {
+		order[i] = column
+		if q.Descending {
+			order[i] += " DESC"
+		}
+	}
+
+	sql := fmt.Sprintf("SELECT %s FROM %s", columns, q.Table)
+	if len(conditions) > 0 {
+		sql += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	sql += fmt.Sprintf(" ORDER BY %s LIMIT %d", strings.Join(order, ", "), q.PageSize+1)
+	return sql, after
+}
+
+// encodeCursor returns the token of the cursor after a row
+func (q KeysetQuery) encodeCursor(row map[string]interface{}) (string, error) {
+	after := make([]interface{}, len(q.Key))
+	for i, column := range q.Key {
+		value := normalizeValue(row[column])
+		if value == nil {
+			return "", fmt.Errorf("key column %s is missing or NULL", column)
+		}
+		after[i] = value
+	}
+	data, err := json.Marshal(keysetCursor{Key: q.Key, Descending: q.Descending, After: after})
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// decodeCursor returns the key a cursor token resumes after, nil for an
+// empty token
+func (q KeysetQuery) decodeCursor(token string) ([]interface{}, error) {
+	if token == "" {
+		return nil, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var cursor keysetCursor
+	if err := decoder.Decode(&cursor); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if strings.Join(cursor.Key, ",") != strings.Join(q.Key, ",") || cursor.Descending != q.Descending || len(cursor.After) != len(q.Key) {
+		return nil, fmt.Errorf("%w: it belongs to a scan in another order", ErrInvalidCursor)
+	}
+	for i, value := range cursor.After {
+		if n, ok := value.(json.Number); ok {
+			if v, err := n.Int64(); err == nil {
+				cursor.After[i] = v
+			} else if v, err := n.Float64(); err == nil {
+				cursor.After[i] = v
+			}
+		}
+	}
+	return cursor.After, nil
+}
+
+// containsColumn reports whether columns holds column
+func containsColumn(columns []string, column string) bool {
+	for _, c := range columns {
+		if c == column {
+			return true
+		}
+	}
+	return false
+}
+
+// compareKeys orders two rows by the query's key in scan order
+func (q KeysetQuery) compareKeys(a, b map[string]interface{}) int {
+	for _, column := range q.Key {
+		cmp := compareKeyValues(q.keyTypes[column], normalizeValue(a[column]), normalizeValue(b[column]))
+		if cmp != 0 {
+			if q.Descending {
+				return -cmp
+			}
+			return cmp
+		}
+	}
+	return 0
+}
+
+// compareKeyValues orders the values of a key column of the given database
+// type. Values of numeric columns are compared exactly, as NUMERIC values come
+// as text and would lose precision as floats. Text is compared as text even
+// when it looks like a number, unlike in compareValues. RFC 3339 text, which
+// cursors carry times as, matches against the times shards return.
+func compareKeyValues(typeName string, a, b interface{}) int {
+	if isNumericType(typeName) {
+		if ar, ok := toRat(a); ok {
+			if br, ok := toRat(b); ok {
+				return ar.Cmp(br)
+			}
+		}
+	}
+	if at, ok := a.(time.Time); ok {
+		if s, ok := b.(string); ok {
+			if bt, err := time.Parse(time.RFC3339Nano, s); err == nil {
+				return at.Compare(bt)
+			}
+		}
+	}
+	if s, ok := a.(string); ok {
+		if bt, ok := b.(time.Time); ok {
+			if at, err := time.Parse(time.RFC3339Nano, s); err == nil {
+				return at.Compare(bt)
+			}
+		}
+	}
+	_, aText := a.(string)
+	_, bText := b.(string)
+	if aText || bText {
+		return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+	}
+	return compareValues(a, b)
+}
+
+// numericTypes are the database type names of numeric columns, as the
+// PostgreSQL and MySQL drivers report them
+var numericTypes = map[string]bool{
+	"INT2": true, "INT4": true, "INT8": true, "NUMERIC": true, "FLOAT4": true, "FLOAT8": true,
+	"TINYINT": true, "SMALLINT": true, "MEDIUMINT": true, "INT": true, "BIGINT": true,
+	"DECIMAL": true, "FLOAT": true, "DOUBLE": true,
+}
+
+// isNumericType reports whether a database type name is a numeric type
+func isNumericType(typeName string) bool {
+	return numericTypes[strings.TrimPrefix(strings.ToUpper(typeName), "UNSIGNED ")]
+}
+
+// toRat converts a numeric driver value, or the text NUMERIC values come as,
+// to an exact number
+func toRat(value interface{}) (*big.Rat, bool) {
+	switch v := value.(type) {
+	case int64:
+		return new(big.Rat).SetInt64(v), true
+	case int:
+		return new(big.Rat).SetInt64(int64(v)), true
+	case int32:
+		return new(big.Rat).SetInt64(int64(v)), true
+	case uint64:
+		return new(big.Rat).SetUint64(v), true
+	case float64:
+		if r := new(big.Rat); r.SetFloat64(v) != nil {
+			return r, true
+		}
+	case float32:
+		if r := new(big.Rat); r.SetFloat64(float64(v)) != nil {
+			return r, true
+		}
+	case string:
+		return new(big.Rat).SetString(v)
+	}
+	return nil, false
+}
+
+// mergePage merges the ordered rows every shard returned for a page into the
+// page and the cursor to the next one
+func (q KeysetQuery) mergePage(shardRows [][]map[string]interface{}) (*KeysetPage, error) {
+	rows := make([]map[string]interface{}, 0, q.PageSize+1)
+	for _, shard := range shardRows {
+		rows = append(rows, shard...)
+	}
+	// Each shard's rows are already in order, so this only interleaves them
+	sort.SliceStable(rows, func(i, j int) bool { return q.compareKeys(rows[i], rows[j]) < 0 })
+
+	page := &KeysetPage{Columns: q.Columns, Rows: rows}
+	if len(rows) > q.PageSize {
+		page.Rows = rows[:q.PageSize]
+		next, err := q.encodeCursor(page.Rows[q.PageSize-1])
+		if err != nil {
+			return nil, err
+		}
+		page.NextCursor = next
+	}
+	return page, nil
+}
+
+// ExecuteKeyset returns the page of a keyset scan across every active shard
+// of a database after cursor, the first page for an empty cursor
+func (p *ShardingProxy) ExecuteKeyset(ctx context.Context, database string, q KeysetQuery, cursor string) (*KeysetPage, error) {
+	if err := q.Validate(); err != nil {
+		return nil, err
+	}
+	after, err := q.decodeCursor(cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	sql, args := q.ShardSQL(after)
+	results, err := p.executeOnEveryShard(ctx, database, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("keyset scan failed: %w", err)
+	}
+	shardRows := make([][]map[string]interface{}, 0, len(results))
+	q.keyTypes = make(map[string]string, len(q.Key))
+	for _, result := range results {
+		shardRows = append(shardRows, result.Rows)
+		for i, column := range result.Columns {
+			if i < len(result.Types) && result.Types[i] != "" {
+				q.keyTypes[column] = result.Types[i]
+			}
+		}
+	}
+	return q.mergePage(shardRows)
+}
@@ -0,0 +1,183 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"testing"
+)
+
+// keysetTestShards splits events unevenly over three shards. Scores repeat
+// across shards, so pages must break ties on the ID.
+func keysetTestShards() [][]map[string]interface{} {
+	shards := make([][]map[string]interface{}, 3)
+	for id := int64(1); id <= 47; id++ {
+		shard := id % 3
+		if id%7 == 0 {
+			shard = 0
+		}
+		shards[shard] = append(shards[shard], map[string]interface{}{"score": (id * 37) % 11, "id": id})
+	}
+	return shards
+}
+
+// shardPage returns what a shard's ShardSQL returns: its rows after the key,
+// in order, one more than a page
+func shardPage(q KeysetQuery, rows []map[string]interface{}, after []interface{}) []map[string]interface{} {
+	sorted := append([]map[string]interface{}(nil), rows...)
+	sort.Slice(sorted, func(i, j int) bool { return q.compareKeys(sorted[i], sorted[j]) < 0 })
+
+	page := make([]map[string]interface{}, 0, q.PageSize+1)
+	for _, row := range sorted {
+		if after != nil && q.compareKeys(row, map[string]interface{}{"score": after[0], "id": after[1]}) <= 0 {
+			continue
+		}
+		if len(page) == q.PageSize+1 {
+			break
+		}
+		page = append(page, row)
+	}
+	return page
+}
+
+// pageThrough reads every page of a scan over the test shards
+func pageThrough(t *testing.T, q KeysetQuery) ([]map[string]interface{}, int) {
+	t.Helper()
+	shards := keysetTestShards()
+	all := make([]map[string]interface{}, 0)
+	cursor, pages := "", 0
+	for {
+		after, err := q.decodeCursor(cursor)
+		if err != nil {
+			t.Fatalf("page %d: bad cursor: %v", pages, err)
+		}
+		shardRows := make([][]map[string]interface{}, 0, len(shards))
+		for _, rows := range shards {
+			shardRows = append(shardRows, shardPage(q, rows, after))
+		}
+		page, err := q.mergePage(shardRows)
+		if err != nil {
+			t.Fatalf("page %d: unexpected error: %v", pages, err)
+		}
+		if len(page.Rows) > q.PageSize {
+			t.Fatalf("page %d: expected at most %d rows, got %d", pages, q.PageSize, len(page.Rows))
+		}
+		all = append(all, page.Rows...)
+		pages++
+		if page.NextCursor == "" {
+			return all, pages
+		}
+		cursor = page.NextCursor
+	}
+}
+
+func TestKeyset_PagesInGlobalOrderWithoutDuplicatesOrGaps(t *testing.T) {
+	for _, descending := range []bool{false, true} {
+		t.Run(fmt.Sprintf("descending=%v", descending), func(t *testing.T) {
+			q := KeysetQuery{Table: "events", Key: []string{"score", "id"}, Descending: descending, PageSize: 5}
+			rows, pages := pageThrough(t, q)
+
+			if pages != 10 {
+				t.Errorf("expected 47 rows in 10 pages, got %d", pages)
+			}
+			seen := make(map[int64]bool)
+			for i, row := range rows {
+				id := row["id"].(int64)
+				if seen[id] {
+					t.Errorf("row %d returned twice", id)
+				}
+				seen[id] = true
+				if i > 0 && q.compareKeys(rows[i-1], row) >= 0 {
+					t.Errorf("rows %v and %v are out of order", rows[i-1], row)
+				}
+			}
+			if len(seen) != 47 {
+				t.Errorf("expected all 47 rows, got %d", len(seen))
+			}
+		})
+	}
+}
+
+func TestKeyset_SinglePageHasNoCursor(t *testing.T) {
+	q := KeysetQuery{Table: "events", Key: []string{"score", "id"}, PageSize: 47}
+
+	// Every row fits on the first page, which is then the last
+	rows, pages := pageThrough(t, q)
+	if pages != 1 || len(rows) != 47 {
+		t.Errorf("expected one page of 47 rows, got %d pages of %d rows", pages, len(rows))
+	}
+}
+
+func TestKeyset_ShardSQL(t *testing.T) {
+	q := KeysetQuery{Table: "events", Columns: []string{"id", "score"}, Where: "kind = 'click'", Key: []string{"score", "id"}, Descending: true, PageSize: 20}
+
+	sql, args := q.ShardSQL(nil)
+	if want := "SELECT id, score FROM events WHERE (kind = 'click') ORDER BY score DESC, id DESC LIMIT 21"; sql != want || len(args) != 0 {
+		t.Errorf("expected %q without arguments, got %q %v", want, sql, args)
+	}
+	sql, args = q.ShardSQL([]interface{}{int64(7), int64(42)})
+	if want := "SELECT id, score FROM events WHERE (kind = 'click') AND (score, id) < ($1, $2) ORDER BY score DESC, id DESC LIMIT 21"; sql != want || len(args) != 2 {
+		t.Errorf("expected %q with the cursor's key, got %q %v", want, sql, args)
+	}
+}
+
+func TestKeyset_CursorRoundTrip(t *testing.T) {
+	q := KeysetQuery{Table: "events", Key: []string{"score", "id"}, PageSize: 5}
+
+	token, err := q.encodeCursor(map[string]interface{}{"score": int64(7), "id": int64(42), "name": "x"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	after, err := q.decodeCursor(token)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(after) != 2 || after[0] != int64(7) || after[1] != int64(42) {
+		t.Errorf("expected to resume after (7, 42), got %v", after)
+	}
+
+	// A cursor only resumes the scan it was issued for
+	other := KeysetQuery{Table: "events", Key: []string{"id"}, PageSize: 5}
+	if _, err := other.decodeCursor(token); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("expected a cursor of another scan to be refused, got %v", err)
+	}
+	if _, err := q.decodeCursor("not a cursor!"); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("expected a malformed cursor to be refused, got %v", err)
+	}
+}
+
+func TestKeyset_ComparesKeysByColumnType(t *testing.T) {
+	// Codes that look like numbers are still text, ordered byte by byte
+	q := KeysetQuery{Table: "products", Key: []string{"code"}, PageSize: 5, keyTypes: map[string]string{"code": "VARCHAR"}}
+	if cmp := q.compareKeys(map[string]interface{}{"code": "10"}, map[string]interface{}{"code": "9"}); cmp >= 0 {
+		t.Errorf("expected text key 10 before 9, got %d", cmp)
+	}
+
+	// NUMERIC values come as text and are compared exactly, beyond float64 precision
+	q = KeysetQuery{Table: "payments", Key: []string{"amount"}, PageSize: 5, keyTypes: map[string]string{"amount": "NUMERIC"}}
+	for _, tt := range []struct {
+		a, b string
+		want int
+	}{
+		{"9.5", "10.25", -1},
+		{"12345678901234567.2", "12345678901234567.1", 1},
+		{"1.50", "1.5", 0},
+	} {
+		a := map[string]interface{}{"amount": []byte(tt.a)}
+		b := map[string]interface{}{"amount": []byte(tt.b)}
+		if cmp := q.compareKeys(a, b); cmp != tt.want {
+			t.Errorf("%s vs %s: expected %d, got %d", tt.a, tt.b, tt.want, cmp)
+		}
+	}
+}
+
+func TestKeyset_ValidateRequiresKeyColumnsReturned(t *testing.T) {
+	q := KeysetQuery{Table: "events", Columns: []string{"id", "name"}, Key: []string{"score", "id"}, PageSize: 5}
+	if err := q.Validate(); err == nil {
+		t.Error("expected a key column missing from the columns to be refused")
+	}
+	q.Columns = append(q.Columns, "score")
+	if err := q.Validate(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,127 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/sharding-system/pkg/models"
+	"go.uber.org/zap"
+)
+
+// ErrFanOutExceeded is returned for queries that would run on more shards
+// than their client app's fan-out limit allows
+var ErrFanOutExceeded = errors.New("query exceeds the maximum shard fan-out")
+
+// IsFanOutExceeded reports whether err was caused by a query over the fan-out limit
+func IsFanOutExceeded(err error) bool {
+	return errors.Is(err, ErrFanOutExceeded)
+}
+
+// MaxFanOutFor returns how many shards a scatter-gather query on a database
+// may run on, 0 if it is unlimited. A client app's own max_fan_out replaces
+// the proxy's, but is capped at the proxy's max_fan_out_ceiling.
+func (c *ProxyConfig) MaxFanOutFor(database string) int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	limit := fanOutLimit(c.MaxFanOut)
+	app := c.ClientApps[database]
+	if app == nil || app.MaxFanOut == 0 {
+		return limit
+	}
+	return minFanOut(fanOutLimit(app.MaxFanOut), c.fanOutCeiling(limit))
+}
+
+// fanOutCeiling returns the highest limit a client app may set, 0 if apps may
+// set any. Without a configured ceiling, apps may only lower the proxy's limit.
+func (c *ProxyConfig) fanOutCeiling(limit int) int {
+	switch {
+	case c.MaxFanOutCeiling < 0:
+		return 0
+	case c.MaxFanOutCeiling == 0:
+		return limit
+	}
+	return c.MaxFanOutCeiling
+}
+
+// fanOutLimit turns a configured fan-out limit into one where 0 is
+// unlimited, as negative values are too
+func fanOutLimit(configured int) int {
+	if configured < 0 {
+		return 0
+	}
+	return configured
+}
+
+// minFanOut returns the lower of two fan-out limits, where 0 is unlimited
+func minFanOut(a, b int) int {
+	if a == 0 || (b != 0 && b < a) {
+		return b
+	}
+	return a
+}
+
+// checkFanOut rejects a scatter-gather query on a database that would run on
+// more of its shards than its fan-out limit. shardKey names the column that
+// would route the query to a single shard, if its table has one.
+func (p *ShardingProxy) checkFanOut(database, shardKey string) error {
+	limit := p.config.MaxFanOutFor(database)
+	if limit == 0 {
+		return nil
+	}
+	shards := len(p.databaseShards(database))
+	if shards <= limit {
+		return nil
+	}
+
+	p.logger.Warn("rejected query over the fan-out limit",
+		zap.String("database", database),
+		zap.Int("shards", shards),
+		zap.Int("max_fan_out", limit))
+	hint := "filter on the table's shard key to route it to a single shard"
+	if shardKey != "" {
+		hint = fmt.Sprintf("filter on %s in the WHERE clause to route it to a single shard", shardKey)
+	}
+	return fmt.Errorf("%w: it would run on %d shards and the limit is %d; %s", ErrFanOutExceeded, shards, limit, hint)
+}
+
+// scatter runs a query on every active shard once it is within the fan-out limit
+func (p *ShardingProxy) scatter(ctx context.Context, session *Session, database, shardKey, sql string) (*QueryResult, error) {
+	if err := p.checkFanOut(database, shardKey); err != nil {
+		return nil, err
+	}
+	return p.executeOnAllShards(ctx, session, sql)
+}
+
+// controlPattern matches transaction and session control statements, which
+// name no table but have to reach every shard the session uses
+var controlPattern = regexp.MustCompile(`(?i)^\s*(?:BEGIN|START\s+TRANSACTION|COMMIT|END|ROLLBACK|ABORT|SAVEPOINT|RELEASE|SET|RESET)\b`)
+
+// databaseShards returns the active shards a scatter-gather query on a
+// database runs on: those the manager placed for the database or its client
+// app, or every active shard if none is tagged with either
+func (p *ShardingProxy) databaseShards(database string) []models.Shard {
+	appID := ""
+	if app := p.config.GetAppConfig(database); app != nil {
+		appID = app.ID
+	}
+
+	p.shardsMu.RLock()
+	defer p.shardsMu.RUnlock()
+	var active, owned []models.Shard
+	for _, shard := range p.shards {
+		if shard.Status != "active" {
+			continue
+		}
+		active = append(active, shard)
+		if shard.Database == database || (appID != "" && shard.ClientAppID == appID) {
+			owned = append(owned, shard)
+		}
+	}
+	if len(owned) == 0 {
+		return active
+	}
+	return owned
+}
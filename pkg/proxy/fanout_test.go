@@ -0,0 +1,178 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/sharding-system/pkg/models"
+	"go.uber.org/zap/zaptest"
+)
+
+// newFanOutProxy returns a proxy in front of count active shards whose
+// orders_db client app shards orders by customer_id
+func newFanOutProxy(t *testing.T, count int, config *ProxyConfig) *ShardingProxy {
+	t.Helper()
+	if config.GetAppConfig("orders_db") == nil {
+		config.SetAppConfig("orders_db", &ClientAppConfig{Database: "orders_db"})
+	}
+	app := config.GetAppConfig("orders_db")
+	app.ShardingRules = []ShardingRule{
+		{Table: "orders", ShardKey: "customer_id", Strategy: "hash"},
+		{Table: "countries", Strategy: "broadcast"},
+	}
+
+	p := NewShardingProxy(config, zaptest.NewLogger(t))
+	p.driver = "proxybackend"
+	for i := 0; i < count; i++ {
+		p.shards = append(p.shards, models.Shard{
+			ID:              fmt.Sprintf("shard-%d", i),
+			Status:          "active",
+			PrimaryEndpoint: fmt.Sprintf("%s-%d", t.Name(), i),
+		})
+	}
+	t.Cleanup(func() { p.Stop() })
+	return p
+}
+
+func TestFanOut_RejectsScatterAboveLimit(t *testing.T) {
+	config := NewProxyConfig()
+	config.MaxFanOut = 2
+	p := newFanOutProxy(t, 3, config)
+	session := p.NewSession("orders_db")
+	defer session.Close()
+
+	_, err := session.Execute(context.Background(), "SELECT * FROM orders WHERE total > 100")
+	if !IsFanOutExceeded(err) {
+		t.Fatalf("expected the scatter to be rejected, got %v", err)
+	}
+	for _, want := range []string{"3 shards", "limit is 2", "filter on customer_id"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected %q in %v", want, err)
+		}
+	}
+
+	// Keyed queries, control statements and broadcast tables are not limited
+	for _, query := range []string{
+		"SELECT * FROM orders WHERE customer_id = 42",
+		"BEGIN",
+		"SET statement_timeout = 0",
+		"COMMIT",
+		"SELECT * FROM countries",
+	} {
+		if _, err := session.Execute(context.Background(), query); err != nil {
+			t.Errorf("%s: unexpected error: %v", query, err)
+		}
+	}
+
+	// Statements whose table the proxy can't tell still scatter
+	if _, err := session.Execute(context.Background(), "SELECT now()"); !IsFanOutExceeded(err) {
+		t.Errorf("expected a statement without a table to be limited, got %v", err)
+	}
+}
+
+func TestFanOut_CountsOnlyTheDatabasesShards(t *testing.T) {
+	config := NewProxyConfig()
+	config.MaxFanOut = 2
+	config.SetAppConfig("orders_db", &ClientAppConfig{ID: "orders", Database: "orders_db"})
+	p := newFanOutProxy(t, 4, config)
+	p.shards[0].ClientAppID = "orders"
+	p.shards[1].Database = "orders_db"
+	p.shards[2].ClientAppID = "billing"
+	session := p.NewSession("orders_db")
+	defer session.Close()
+
+	result, err := session.Execute(context.Background(), "SELECT * FROM orders WHERE total > 100")
+	if err != nil {
+		t.Fatalf("expected the scatter to be within the database's 2 shards, got %v", err)
+	}
+	if len(result.Rows) != 2 {
+		t.Errorf("expected a row from each of the database's shards, got %v", result.Rows)
+	}
+}
+
+func TestFanOut_AllowsScatterWithinLimit(t *testing.T) {
+	config := NewProxyConfig()
+	config.MaxFanOut = 3
+	p := newFanOutProxy(t, 3, config)
+	session := p.NewSession("orders_db")
+	defer session.Close()
+
+	result, err := session.Execute(context.Background(), "SELECT * FROM orders WHERE total > 100")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(result.Rows) != 3 {
+		t.Errorf("expected a row from every shard, got %v", result.Rows)
+	}
+}
+
+func TestFanOut_AppliesToAggregates(t *testing.T) {
+	config := NewProxyConfig()
+	config.MaxFanOut = 1
+	p := newFanOutProxy(t, 2, config)
+
+	_, err := p.executeOnEveryShard(context.Background(), "orders_db", "SELECT count(*) FROM orders")
+	if !IsFanOutExceeded(err) {
+		t.Errorf("expected the aggregate scatter to be rejected, got %v", err)
+	}
+}
+
+func TestProxyConfig_MaxFanOutFor(t *testing.T) {
+	tests := []struct {
+		name               string
+		maxFanOut, ceiling int
+		app                int
+		want               int
+	}{
+		{name: "default", want: 0},
+		{name: "proxy limit", maxFanOut: 8, want: 8},
+		{name: "proxy unlimited", maxFanOut: -1, want: 0},
+		{name: "app lowers limit", maxFanOut: 8, app: 4, want: 4},
+		{name: "app raise capped without ceiling", maxFanOut: 8, app: 16, want: 8},
+		{name: "app raises within ceiling", maxFanOut: 8, ceiling: 32, app: 16, want: 16},
+		{name: "app raise capped at ceiling", maxFanOut: 8, ceiling: 32, app: 64, want: 32},
+		{name: "app unlimited capped at ceiling", maxFanOut: 8, ceiling: 32, app: -1, want: 32},
+		{name: "app unlimited without ceiling", maxFanOut: 8, ceiling: -1, app: -1, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := NewProxyConfig()
+			config.MaxFanOut = tt.maxFanOut
+			config.MaxFanOutCeiling = tt.ceiling
+			config.SetAppConfig("orders_db", &ClientAppConfig{MaxFanOut: tt.app})
+
+			if got := config.MaxFanOutFor("orders_db"); got != tt.want {
+				t.Errorf("expected a fan-out limit of %d, got %d", tt.want, got)
+			}
+			if got, want := config.MaxFanOutFor("unknown_db"), fanOutLimit(tt.maxFanOut); got != want {
+				t.Errorf("expected apps without a config to get the proxy's limit of %d, got %d", want, got)
+			}
+		})
+	}
+}
+
+func TestProxyConfig_ValidateMaxFanOut(t *testing.T) {
+	config := NewProxyConfig()
+	config.MaxFanOut = 8
+	config.MaxFanOutCeiling = 32
+	config.SetAppConfig("orders_db", &ClientAppConfig{Database: "orders_db", MaxFanOut: 64})
+	config.SetAppConfig("reports_db", &ClientAppConfig{Database: "reports_db", MaxFanOut: 16})
+
+	err := config.Validate()
+	if err == nil {
+		t.Fatal("expected a fan-out above the ceiling to be rejected")
+	}
+	if want := "client_apps.orders_db.max_fan_out must be between 1 and the ceiling of 32, got 64"; !strings.Contains(err.Error(), want) {
+		t.Errorf("expected %q in %v", want, err)
+	}
+	if strings.Contains(err.Error(), "reports_db") {
+		t.Errorf("expected a fan-out within the ceiling to be accepted, got %v", err)
+	}
+
+	problems := config.ValidateRules()
+	if len(problems) != 1 || problems[0].Database != "orders_db" || !strings.Contains(problems[0].Message, "max fan-out 64") {
+		t.Errorf("expected the rule check to report the app's fan-out, got %v", problems)
+	}
+}
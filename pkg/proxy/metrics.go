@@ -0,0 +1,192 @@
+package proxy
+
+import (
+	"database/sql"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// poolMetrics exposes the proxy's client connections, backend connection
+// pools and prepared statement caches on the admin endpoint
+type poolMetrics struct {
+	registry *prometheus.Registry
+
+	clientConnections     prometheus.Gauge
+	backendConnections    *prometheus.GaugeVec
+	backendMaxConnections *prometheus.GaugeVec
+	poolWaits             *prometheus.CounterVec
+	poolWaitDuration      *prometheus.CounterVec
+	evictedConnections    *prometheus.CounterVec
+	preparedStatements    *prometheus.GaugeVec
+	statementCacheHits    *prometheus.CounterVec
+	statementCacheMisses  *prometheus.CounterVec
+	statementEvictions    *prometheus.CounterVec
+
+	// Totals across shards for the stats endpoint
+	statementHits      atomic.Int64
+	statementMisses    atomic.Int64
+	statementsEvicted  atomic.Int64
+	statementsPrepared atomic.Int64
+
+	// last holds the pool stats at the previous observation, since the pools
+	// report cumulative totals
+	mu   sync.Mutex
+	last map[string]sql.DBStats
+}
+
+// newPoolMetrics creates the pool metrics on a registry of their own
+func newPoolMetrics() *poolMetrics {
+	m := &poolMetrics{
+		registry: prometheus.NewRegistry(),
+		clientConnections: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "sharding_proxy_client_connections",
+			Help: "Number of client connections open to the proxy",
+		}),
+		backendConnections: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "sharding_proxy_backend_connections",
+			Help: "Number of backend connections in a shard's pool by state (active, idle)",
+		}, []string{"shard_id", "state"}),
+		backendMaxConnections: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "sharding_proxy_backend_max_connections",
+			Help: "Maximum number of open backend connections allowed in a shard's pool",
+		}, []string{"shard_id"}),
+		poolWaits: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "sharding_proxy_pool_waits_total",
+			Help: "Total number of times a query waited for a backend connection",
+		}, []string{"shard_id"}),
+		poolWaitDuration: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "sharding_proxy_pool_wait_seconds_total",
+			Help: "Total time spent waiting for a backend connection",
+		}, []string{"shard_id"}),
+		evictedConnections: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "sharding_proxy_pool_evicted_connections_total",
+			Help: "Total number of backend connections closed by the pool by reason (max_idle, max_idle_time, max_lifetime)",
+		}, []string{"shard_id", "reason"}),
+		preparedStatements: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "sharding_proxy_prepared_statements",
+			Help: "Number of statements kept prepared on a shard's backend connections",
+		}, []string{"shard_id"}),
+		statementCacheHits: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "sharding_proxy_statement_cache_hits_total",
+			Help: "Total number of queries run with a statement already prepared on their backend connection",
+		}, []string{"shard_id"}),
+		statementCacheMisses: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "sharding_proxy_statement_cache_misses_total",
+			Help: "Total number of queries that had to be prepared on their backend connection",
+		}, []string{"shard_id"}),
+		statementEvictions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "sharding_proxy_statement_cache_evictions_total",
+			Help: "Total number of prepared statements deallocated because their backend connection's cache was full",
+		}, []string{"shard_id"}),
+		last: make(map[string]sql.DBStats),
+	}
+
+	m.registry.MustRegister(
+		m.clientConnections,
+		m.backendConnections,
+		m.backendMaxConnections,
+		m.poolWaits,
+		m.poolWaitDuration,
+		m.evictedConnections,
+		m.preparedStatements,
+		m.statementCacheHits,
+		m.statementCacheMisses,
+		m.statementEvictions,
+	)
+	return m
+}
+
+// observe records the current state of each shard's pool
+func (m *poolMetrics) observe(pools map[string]sql.DBStats) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for shardID, stats := range pools {
+		m.backendConnections.WithLabelValues(shardID, "active").Set(float64(stats.InUse))
+		m.backendConnections.WithLabelValues(shardID, "idle").Set(float64(stats.Idle))
+		m.backendMaxConnections.WithLabelValues(shardID).Set(float64(stats.MaxOpenConnections))
+
+		last := m.last[shardID]
+		m.poolWaits.WithLabelValues(shardID).Add(growth(stats.WaitCount, last.WaitCount))
+		m.poolWaitDuration.WithLabelValues(shardID).Add(growth(int64(stats.WaitDuration), int64(last.WaitDuration)) / float64(time.Second))
+		m.evictedConnections.WithLabelValues(shardID, "max_idle").Add(growth(stats.MaxIdleClosed, last.MaxIdleClosed))
+		m.evictedConnections.WithLabelValues(shardID, "max_idle_time").Add(growth(stats.MaxIdleTimeClosed, last.MaxIdleTimeClosed))
+		m.evictedConnections.WithLabelValues(shardID, "max_lifetime").Add(growth(stats.MaxLifetimeClosed, last.MaxLifetimeClosed))
+		m.last[shardID] = stats
+	}
+}
+
+// statementHit records a query run with a cached prepared statement
+func (m *poolMetrics) statementHit(shardID string) {
+	m.statementCacheHits.WithLabelValues(shardID).Inc()
+	m.statementHits.Add(1)
+}
+
+// statementMiss records a query that had to be prepared
+func (m *poolMetrics) statementMiss(shardID string) {
+	m.statementCacheMisses.WithLabelValues(shardID).Inc()
+	m.statementMisses.Add(1)
+}
+
+// statementEvicted records a prepared statement deallocated to make room
+func (m *poolMetrics) statementEvicted(shardID string) {
+	m.statementEvictions.WithLabelValues(shardID).Inc()
+	m.statementsEvicted.Add(1)
+}
+
+// statementPrepared records delta statements prepared, or deallocated if negative
+func (m *poolMetrics) statementPrepared(shardID string, delta int) {
+	m.preparedStatements.WithLabelValues(shardID).Add(float64(delta))
+	m.statementsPrepared.Add(int64(delta))
+}
+
+// statementCacheStats summarizes the prepared statement caches of every shard
+func (m *poolMetrics) statementCacheStats() map[string]interface{} {
+	hits, misses := m.statementHits.Load(), m.statementMisses.Load()
+	hitRate := 0.0
+	if hits+misses > 0 {
+		hitRate = float64(hits) / float64(hits+misses)
+	}
+	return map[string]interface{}{
+		"prepared_statements": m.statementsPrepared.Load(),
+		"hits":                hits,
+		"misses":              misses,
+		"hit_rate":            hitRate,
+		"evictions":           m.statementsEvicted.Load(),
+	}
+}
+
+// growth is how much a cumulative total grew since it was last observed. A
+// total below the last one belongs to a new pool and grew from zero.
+func growth(current, last int64) float64 {
+	if current < last {
+		return float64(current)
+	}
+	return float64(current - last)
+}
+
+// metricsHandler serves the pool metrics, observing the pools on each scrape
+func (p *ShardingProxy) metricsHandler() http.Handler {
+	serve := promhttp.HandlerFor(p.metrics.registry, promhttp.HandlerOpts{})
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p.observePools()
+		serve.ServeHTTP(w, r)
+	})
+}
+
+// observePools records the stats of every shard's connection pool
+func (p *ShardingProxy) observePools() {
+	p.shardPoolsMu.RLock()
+	pools := make(map[string]sql.DBStats, len(p.shardPools))
+	for shardID, pool := range p.shardPools {
+		pools[shardID] = pool.Stats()
+	}
+	p.shardPoolsMu.RUnlock()
+
+	p.metrics.observe(pools)
+}
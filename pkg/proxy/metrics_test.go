@@ -0,0 +1,166 @@
+package proxy
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.uber.org/zap/zaptest"
+)
+
+// fakePoolDriver hands out connections that do nothing, so tests can drive a
+// pool without a database
+type fakePoolDriver struct{}
+
+func init() {
+	sql.Register("proxytest", fakePoolDriver{})
+}
+
+func (fakePoolDriver) Open(name string) (driver.Conn, error) { return fakePoolConn{}, nil }
+
+type fakePoolConn struct{}
+
+func (fakePoolConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("prepare not supported")
+}
+func (fakePoolConn) Close() error              { return nil }
+func (fakePoolConn) Begin() (driver.Tx, error) { return nil, errors.New("transactions not supported") }
+
+func newPoolTestProxy(t *testing.T) (*ShardingProxy, *sql.DB) {
+	t.Helper()
+	pool, err := sql.Open("proxytest", "shard1")
+	if err != nil {
+		t.Fatalf("failed to open fake pool: %v", err)
+	}
+	t.Cleanup(func() { pool.Close() })
+	pool.SetMaxOpenConns(2)
+	pool.SetMaxIdleConns(2)
+
+	p := NewShardingProxy(NewProxyConfig(), zaptest.NewLogger(t))
+	p.shardPools["shard1"] = pool
+	return p, pool
+}
+
+func TestPoolMetrics_ReflectPoolActivity(t *testing.T) {
+	p, pool := newPoolTestProxy(t)
+	ctx := context.Background()
+	active := p.metrics.backendConnections.WithLabelValues("shard1", "active")
+	idle := p.metrics.backendConnections.WithLabelValues("shard1", "idle")
+
+	first, err := pool.Conn(ctx)
+	if err != nil {
+		t.Fatalf("failed to get connection: %v", err)
+	}
+	second, err := pool.Conn(ctx)
+	if err != nil {
+		t.Fatalf("failed to get connection: %v", err)
+	}
+	p.observePools()
+	if got := testutil.ToFloat64(active); got != 2 {
+		t.Errorf("expected 2 active connections, got %v", got)
+	}
+	if got := testutil.ToFloat64(p.metrics.backendMaxConnections.WithLabelValues("shard1")); got != 2 {
+		t.Errorf("expected a pool size of 2, got %v", got)
+	}
+
+	// With the pool exhausted a third query waits until a connection is released
+	acquired := make(chan *sql.Conn)
+	go func() {
+		conn, err := pool.Conn(ctx)
+		if err != nil {
+			t.Errorf("failed to get connection: %v", err)
+		}
+		acquired <- conn
+	}()
+	deadline := time.Now().Add(5 * time.Second)
+	for pool.Stats().WaitCount == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+	first.Close()
+	third := <-acquired
+	third.Close()
+	second.Close()
+
+	p.observePools()
+	if got := testutil.ToFloat64(active); got != 0 {
+		t.Errorf("expected no active connections, got %v", got)
+	}
+	if got := testutil.ToFloat64(idle); got != 2 {
+		t.Errorf("expected 2 idle connections, got %v", got)
+	}
+	if got := testutil.ToFloat64(p.metrics.poolWaits.WithLabelValues("shard1")); got != 1 {
+		t.Errorf("expected 1 pool wait, got %v", got)
+	}
+	if got := testutil.ToFloat64(p.metrics.poolWaitDuration.WithLabelValues("shard1")); got <= 0 {
+		t.Errorf("expected time spent waiting, got %v", got)
+	}
+
+	// Shrinking the idle limit evicts the surplus idle connections
+	pool.SetMaxIdleConns(0)
+	p.observePools()
+	if got := testutil.ToFloat64(idle); got != 0 {
+		t.Errorf("expected no idle connections, got %v", got)
+	}
+	if got := testutil.ToFloat64(p.metrics.evictedConnections.WithLabelValues("shard1", "max_idle")); got != 2 {
+		t.Errorf("expected 2 evicted connections, got %v", got)
+	}
+
+	// Totals are not counted twice across observations
+	p.observePools()
+	if got := testutil.ToFloat64(p.metrics.poolWaits.WithLabelValues("shard1")); got != 1 {
+		t.Errorf("expected pool waits to stay at 1, got %v", got)
+	}
+}
+
+func TestPoolMetrics_ClientConnections(t *testing.T) {
+	p, _ := newPoolTestProxy(t)
+	client, server := net.Pipe()
+
+	p.wg.Add(1)
+	done := make(chan struct{})
+	go func() {
+		p.handleConnection(server)
+		close(done)
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for testutil.ToFloat64(p.metrics.clientConnections) != 1 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if got := testutil.ToFloat64(p.metrics.clientConnections); got != 1 {
+		t.Errorf("expected 1 client connection, got %v", got)
+	}
+
+	client.Close()
+	<-done
+	if got := testutil.ToFloat64(p.metrics.clientConnections); got != 0 {
+		t.Errorf("expected no client connections after disconnect, got %v", got)
+	}
+}
+
+func TestPoolMetrics_ServedOnAdminEndpoint(t *testing.T) {
+	p, _ := newPoolTestProxy(t)
+
+	recorder := httptest.NewRecorder()
+	p.metricsHandler().ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+
+	body := recorder.Body.String()
+	for _, want := range []string{
+		`sharding_proxy_backend_connections{shard_id="shard1",state="idle"} 0`,
+		`sharding_proxy_backend_max_connections{shard_id="shard1"} 2`,
+		`sharding_proxy_client_connections 0`,
+		`sharding_proxy_pool_evicted_connections_total{reason="max_lifetime",shard_id="shard1"} 0`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected metrics to contain %q, got:\n%s", want, body)
+		}
+	}
+}
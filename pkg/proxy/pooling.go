@@ -0,0 +1,260 @@
+package proxy
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"sync"
+
+	"github.com/sharding-system/pkg/models"
+)
+
+// PoolMode decides how long a client holds a backend connection, as in
+// PgBouncer
+type PoolMode string
+
+const (
+	// PoolModeSession gives each client its own backend connection per shard
+	// until it disconnects. Every PostgreSQL feature works, but backend
+	// connections are not shared between clients.
+	PoolModeSession PoolMode = "session"
+	// PoolModeTransaction returns the backend connection to the pool when a
+	// transaction ends, or after each statement outside one. Session state
+	// would leak to other clients, so session-level features are rejected.
+	PoolModeTransaction PoolMode = "transaction"
+	// PoolModeStatement returns the backend connection after every statement.
+	// Multi-statement transactions are rejected along with session-level
+	// features.
+	PoolModeStatement PoolMode = "statement"
+)
+
+// DefaultPoolMode is the pool mode of client apps that do not set one. Like
+// PgBouncer's default it supports every client.
+const DefaultPoolMode = PoolModeSession
+
+// validPoolMode reports whether mode is a known pool mode
+func validPoolMode(mode PoolMode) bool {
+	switch mode {
+	case PoolModeSession, PoolModeTransaction, PoolModeStatement:
+		return true
+	}
+	return false
+}
+
+// ErrPoolModeRestriction is returned for statements the client app's pool
+// mode cannot run
+var ErrPoolModeRestriction = errors.New("statement not allowed in pool mode")
+
+// IsPoolModeRestriction reports whether err was caused by a statement the
+// pool mode cannot run
+func IsPoolModeRestriction(err error) bool {
+	return errors.Is(err, ErrPoolModeRestriction)
+}
+
+var (
+	// Statements that change or depend on the state of a backend connection
+	// beyond the current transaction. SET LOCAL, SET TRANSACTION, SET
+	// CONSTRAINTS and set_config(..., true) only last until the transaction
+	// ends and are safe.
+	sessionFeatures = []struct {
+		name    string
+		pattern *regexp.Regexp
+	}{
+		{"SET", regexp.MustCompile(`(?i)^\s*SET\s+(?:SESSION\s+)?(?:[a-z_][\w.]*|TIME\s+ZONE|ROLE|SESSION\s+AUTHORIZATION)\b`)},
+		{"RESET", regexp.MustCompile(`(?i)^\s*RESET\b`)},
+		{"PREPARE", regexp.MustCompile(`(?i)^\s*(?:PREPARE|EXECUTE|DEALLOCATE)\b`)},
+		{"LISTEN", regexp.MustCompile(`(?i)^\s*(?:LISTEN|UNLISTEN)\b`)},
+		{"temporary tables", regexp.MustCompile(`(?i)^\s*CREATE\s+(?:GLOBAL\s+|LOCAL\s+)?(?:TEMP|TEMPORARY)\s+TABLE\b`)},
+		{"cursors WITH HOLD", regexp.MustCompile(`(?i)^\s*DECLARE\b.*\bWITH\s+HOLD\b`)},
+		{"session advisory locks", regexp.MustCompile(`(?i)\bpg_(?:try_)?advisory_lock(?:_shared)?\s*\(`)},
+		{"LOAD", regexp.MustCompile(`(?i)^\s*LOAD\b`)},
+		{"DISCARD", regexp.MustCompile(`(?i)^\s*DISCARD\b`)},
+	}
+	transactionSetPattern = regexp.MustCompile(`(?i)^\s*SET\s+(?:LOCAL|TRANSACTION|CONSTRAINTS)\b`)
+
+	beginPattern = regexp.MustCompile(`(?i)^\s*(?:BEGIN|START\s+TRANSACTION)\b`)
+	endPattern   = regexp.MustCompile(`(?i)^\s*(?:COMMIT|END|ROLLBACK|ABORT)\b`)
+	// ROLLBACK TO SAVEPOINT keeps the transaction open
+	rollbackToPattern = regexp.MustCompile(`(?i)^\s*ROLLBACK\s+(?:WORK\s+|TRANSACTION\s+)?TO\b`)
+)
+
+// sessionFeature returns the session-level feature a statement uses, if any
+func sessionFeature(query string) string {
+	if transactionSetPattern.MatchString(query) {
+		return ""
+	}
+	for _, feature := range sessionFeatures {
+		if feature.pattern.MatchString(query) {
+			return feature.name
+		}
+	}
+	return ""
+}
+
+// Session is a client connection's use of backend connections. Its pool mode
+// decides when the backend connections it takes go back to the shard pools.
+type Session struct {
+	proxy    *ShardingProxy
+	database string
+	mode     PoolMode
+
+	mu     sync.Mutex
+	held   map[string]*sql.Conn       // Backend connections held, by shard ID
+	stmts  map[string]*statementCache // Statements prepared on the held connections, by shard ID
+	inTx   bool
+	closed bool
+}
+
+// NewSession starts a client session on a database, pooled with the mode of
+// the database's client app. Close must be called when the client disconnects.
+func (p *ShardingProxy) NewSession(database string) *Session {
+	return &Session{
+		proxy:    p,
+		database: database,
+		mode:     p.config.PoolModeFor(database),
+		held:     make(map[string]*sql.Conn),
+		stmts:    make(map[string]*statementCache),
+	}
+}
+
+// Mode returns the session's pool mode
+func (s *Session) Mode() PoolMode {
+	return s.mode
+}
+
+// Execute routes a statement to its shards on the session's backend connections
+func (s *Session) Execute(ctx context.Context, query string) (*QueryResult, error) {
+	s.mu.Lock()
+	closed := s.closed
+	s.mu.Unlock()
+	if closed {
+		return nil, fmt.Errorf("session is closed")
+	}
+	if err := s.check(query); err != nil {
+		return nil, err
+	}
+
+	result, err := s.proxy.executeQuery(ctx, s, s.database, query)
+
+	s.mu.Lock()
+	switch {
+	case beginPattern.MatchString(query):
+		// A transaction that failed to start holds nothing
+		s.inTx = err == nil
+	case endPattern.MatchString(query) && !rollbackToPattern.MatchString(query):
+		s.inTx = false
+	}
+	s.mu.Unlock()
+
+	s.releaseIdle()
+	return result, err
+}
+
+// check rejects statements the session's pool mode cannot run
+func (s *Session) check(query string) error {
+	if s.mode == PoolModeSession {
+		return nil
+	}
+	if feature := sessionFeature(query); feature != "" {
+		return fmt.Errorf("%w: %s is a session-level feature and cannot be used with %s pooling on database %s; use session pooling for this client app",
+			ErrPoolModeRestriction, feature, s.mode, s.database)
+	}
+	if s.mode == PoolModeStatement && beginPattern.MatchString(query) {
+		return fmt.Errorf("%w: transactions cannot span statements with statement pooling on database %s; use transaction or session pooling for this client app",
+			ErrPoolModeRestriction, s.database)
+	}
+	return nil
+}
+
+// conn returns the backend connection a statement on shard runs on, taking
+// one from the shard's pool if the session holds none
+func (s *Session) conn(ctx context.Context, shard *models.Shard) (*sql.Conn, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil, fmt.Errorf("session is closed")
+	}
+	if conn, ok := s.held[shard.ID]; ok {
+		return conn, nil
+	}
+
+	pool := s.proxy.getOrCreatePool(shard)
+	if pool == nil {
+		return nil, fmt.Errorf("no connection pool for shard: %s", shard.ID)
+	}
+	conn, err := pool.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get connection to shard %s: %w", shard.ID, err)
+	}
+	s.held[shard.ID] = conn
+	return conn, nil
+}
+
+// query runs a query on shard on the session's backend connection. In
+// session pooling connections are held for as long as the client stays
+// connected, so the statements they run are kept prepared.
+func (s *Session) query(ctx context.Context, shard *models.Shard, query string, args ...interface{}) (*sql.Rows, error) {
+	conn, err := s.conn(ctx, shard)
+	if err != nil {
+		return nil, err
+	}
+	if s.mode != PoolModeSession {
+		return conn.QueryContext(ctx, query, args...)
+	}
+
+	s.mu.Lock()
+	cache, ok := s.stmts[shard.ID]
+	if !ok {
+		if max := s.proxy.config.maxPreparedStatements(); max > 0 {
+			cache = newStatementCache(conn, shard.ID, max, s.proxy.metrics)
+			s.stmts[shard.ID] = cache
+		}
+	}
+	s.mu.Unlock()
+	if cache == nil {
+		return conn.QueryContext(ctx, query, args...)
+	}
+	return cache.query(ctx, query, args...)
+}
+
+// releaseIdle returns the backend connections the pool mode no longer needs
+// held: after every statement outside a transaction in transaction and
+// statement pooling, never before Close in session pooling
+func (s *Session) releaseIdle() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.mode == PoolModeSession || s.inTx {
+		return
+	}
+	s.releaseLocked()
+}
+
+// Close returns every backend connection the session holds. A transaction
+// left open is rolled back when its connection goes back to the pool.
+func (s *Session) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	if s.inTx {
+		for _, conn := range s.held {
+			conn.ExecContext(context.Background(), "ROLLBACK")
+		}
+		s.inTx = false
+	}
+	s.releaseLocked()
+}
+
+// releaseLocked returns held connections to their pools. Must be called with
+// s.mu held.
+func (s *Session) releaseLocked() {
+	for shardID, cache := range s.stmts {
+		cache.close()
+		delete(s.stmts, shardID)
+	}
+	for shardID, conn := range s.held {
+		conn.Close()
+		delete(s.held, shardID)
+	}
+}
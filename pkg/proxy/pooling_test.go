@@ -0,0 +1,266 @@
+package proxy
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/sharding-system/pkg/models"
+	"go.uber.org/zap/zaptest"
+)
+
+// backendDriver numbers the connections it opens and answers every query with
+// the number of the backend connection that ran it
+type backendDriver struct {
+	mu      sync.Mutex
+	opened  int64
+	queries map[int64][]string
+}
+
+var testBackendDriver = &backendDriver{queries: make(map[int64][]string)}
+
+func init() {
+	sql.Register("proxybackend", testBackendDriver)
+}
+
+// received returns the queries a backend connection ran
+func (d *backendDriver) received(backend int64) []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]string(nil), d.queries[backend]...)
+}
+
+func (d *backendDriver) Open(name string) (driver.Conn, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.opened++
+	return &backendConn{driver: d, id: d.opened}, nil
+}
+
+type backendConn struct {
+	driver *backendDriver
+	id     int64
+}
+
+func (c *backendConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, driver.ErrSkip
+}
+
+func (c *backendConn) Close() error { return nil }
+
+func (c *backendConn) Begin() (driver.Tx, error) {
+	return nil, driver.ErrSkip
+}
+
+func (c *backendConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	c.driver.mu.Lock()
+	defer c.driver.mu.Unlock()
+	c.driver.queries[c.id] = append(c.driver.queries[c.id], query)
+	return &backendRows{id: c.id}, nil
+}
+
+func (c *backendConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.driver.mu.Lock()
+	defer c.driver.mu.Unlock()
+	c.driver.queries[c.id] = append(c.driver.queries[c.id], query)
+	return driver.RowsAffected(0), nil
+}
+
+type backendRows struct {
+	id   int64
+	done bool
+}
+
+func (r *backendRows) Columns() []string { return []string{"backend"} }
+
+func (r *backendRows) Close() error { return nil }
+
+func (r *backendRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	dest[0] = r.id
+	r.done = true
+	return nil
+}
+
+// newPoolingProxy returns a proxy in front of one shard whose orders_db
+// client app is pooled in mode
+func newPoolingProxy(t *testing.T, mode PoolMode) *ShardingProxy {
+	t.Helper()
+	config := NewProxyConfig()
+	config.SetAppConfig("orders_db", &ClientAppConfig{Database: "orders_db", PoolMode: mode})
+
+	p := NewShardingProxy(config, zaptest.NewLogger(t))
+	p.driver = "proxybackend"
+	p.shards = []models.Shard{{ID: "shard-1", Status: "active", PrimaryEndpoint: t.Name()}}
+	t.Cleanup(func() { p.Stop() })
+	return p
+}
+
+// backend runs a query on a session and returns the backend connection that ran it
+func backend(t *testing.T, session *Session, query string) int64 {
+	t.Helper()
+	result, err := session.Execute(context.Background(), query)
+	if err != nil {
+		t.Fatalf("%s: unexpected error: %v", query, err)
+	}
+	if len(result.Rows) != 1 {
+		t.Fatalf("%s: expected one row, got %v", query, result.Rows)
+	}
+	return result.Rows[0]["backend"].(int64)
+}
+
+// expectRestricted checks that the session refuses a query for its pool mode
+func expectRestricted(t *testing.T, session *Session, query, mentions string) {
+	t.Helper()
+	_, err := session.Execute(context.Background(), query)
+	if !IsPoolModeRestriction(err) {
+		t.Fatalf("%s: expected a pool mode restriction, got %v", query, err)
+	}
+	if !strings.Contains(err.Error(), mentions) || !strings.Contains(err.Error(), string(session.Mode())) {
+		t.Errorf("%s: expected the error to name %q and the pool mode, got %v", query, mentions, err)
+	}
+}
+
+func TestSession_SessionModeHoldsConnectionUntilClose(t *testing.T) {
+	p := newPoolingProxy(t, PoolModeSession)
+	first, second := p.NewSession("orders_db"), p.NewSession("orders_db")
+	defer second.Close()
+
+	held := backend(t, first, "SET search_path = orders")
+	if got := backend(t, first, "SELECT 1"); got != held {
+		t.Errorf("expected the session to keep backend %d, got %d", held, got)
+	}
+	if got := backend(t, second, "SELECT 1"); got == held {
+		t.Errorf("expected another session to get its own backend, got %d", got)
+	}
+
+	first.Close()
+	third := p.NewSession("orders_db")
+	defer third.Close()
+	if got := backend(t, third, "SELECT 1"); got != held {
+		t.Errorf("expected the closed session's backend %d to be reused, got %d", held, got)
+	}
+}
+
+func TestSession_TransactionModeReleasesConnectionAfterTransaction(t *testing.T) {
+	p := newPoolingProxy(t, PoolModeTransaction)
+	first, second := p.NewSession("orders_db"), p.NewSession("orders_db")
+	defer first.Close()
+	defer second.Close()
+
+	// Outside a transaction every statement returns its backend to the pool
+	shared := backend(t, first, "SELECT 1")
+	if got := backend(t, second, "SELECT 1"); got != shared {
+		t.Errorf("expected backend %d to be shared between sessions, got %d", shared, got)
+	}
+
+	// Inside one the session keeps its backend until the transaction ends
+	backend(t, first, "BEGIN")
+	held := backend(t, first, "SET LOCAL statement_timeout = '1s'")
+	backend(t, first, "SET TRANSACTION ISOLATION LEVEL SERIALIZABLE")
+	backend(t, first, "SET CONSTRAINTS ALL DEFERRED")
+	if got := backend(t, first, "SELECT 1"); got != held {
+		t.Errorf("expected the transaction to keep backend %d, got %d", held, got)
+	}
+	if got := backend(t, second, "SELECT 1"); got == held {
+		t.Errorf("expected backend %d to be unavailable during the transaction", held)
+	}
+	backend(t, first, "ROLLBACK TO SAVEPOINT before_update")
+	if got := backend(t, first, "COMMIT"); got != held {
+		t.Errorf("expected the transaction to keep backend %d after a savepoint rollback, got %d", held, got)
+	}
+	if got := backend(t, second, "SELECT 1"); got != held {
+		t.Errorf("expected backend %d back in the pool after COMMIT, got %d", held, got)
+	}
+
+	expectRestricted(t, first, "SET search_path = orders", "SET")
+	expectRestricted(t, first, "SET SESSION CHARACTERISTICS AS TRANSACTION READ ONLY", "SET")
+	expectRestricted(t, first, "LISTEN order_events", "LISTEN")
+	expectRestricted(t, first, "PREPARE find AS SELECT 1", "PREPARE")
+	expectRestricted(t, first, "CREATE TEMP TABLE scratch (id int)", "temporary tables")
+	expectRestricted(t, first, "SELECT pg_advisory_lock(42)", "session advisory locks")
+}
+
+func TestSession_StatementModeReleasesConnectionAfterEachStatement(t *testing.T) {
+	p := newPoolingProxy(t, PoolModeStatement)
+	first, second := p.NewSession("orders_db"), p.NewSession("orders_db")
+	defer first.Close()
+	defer second.Close()
+
+	shared := backend(t, first, "SELECT 1")
+	if got := backend(t, second, "SELECT 1"); got != shared {
+		t.Errorf("expected backend %d to be shared between sessions, got %d", shared, got)
+	}
+	if got := backend(t, first, "SELECT 2"); got != shared {
+		t.Errorf("expected backend %d to be shared between sessions, got %d", shared, got)
+	}
+
+	expectRestricted(t, first, "BEGIN", "transactions")
+	expectRestricted(t, first, "START TRANSACTION", "transactions")
+	expectRestricted(t, first, "RESET ALL", "RESET")
+}
+
+func TestSession_CloseRollsBackOpenTransaction(t *testing.T) {
+	p := newPoolingProxy(t, PoolModeTransaction)
+	session := p.NewSession("orders_db")
+	backend(t, session, "BEGIN")
+	held := backend(t, session, "UPDATE orders SET status = 'paid'")
+	session.Close()
+
+	got := testBackendDriver.received(held)
+	if len(got) == 0 || got[len(got)-1] != "ROLLBACK" {
+		t.Errorf("expected the open transaction to be rolled back, got %v", got)
+	}
+	if _, err := session.Execute(context.Background(), "SELECT 1"); err == nil {
+		t.Error("expected a closed session to refuse queries")
+	}
+}
+
+func TestProxyConfig_PoolModeFor(t *testing.T) {
+	config := NewProxyConfig()
+	config.PoolMode = PoolModeTransaction
+	config.SetAppConfig("orders_db", &ClientAppConfig{})
+	config.SetAppConfig("reports_db", &ClientAppConfig{PoolMode: PoolModeSession})
+
+	for database, want := range map[string]PoolMode{
+		"orders_db":  PoolModeTransaction,
+		"reports_db": PoolModeSession,
+		"unknown_db": PoolModeTransaction,
+	} {
+		if got := config.PoolModeFor(database); got != want {
+			t.Errorf("%s: expected pool mode %s, got %s", database, want, got)
+		}
+	}
+
+	config.PoolMode = ""
+	if got := config.PoolModeFor("orders_db"); got != DefaultPoolMode {
+		t.Errorf("expected the default pool mode, got %s", got)
+	}
+}
+
+func TestProxyConfig_ValidatePoolModes(t *testing.T) {
+	config := NewProxyConfig()
+	config.PoolMode = "per_query"
+	config.SetAppConfig("orders_db", &ClientAppConfig{PoolMode: "connection"})
+
+	err := config.Validate()
+	if err == nil {
+		t.Fatal("expected unknown pool modes to be rejected")
+	}
+	for _, want := range []string{`pool_mode must be session, transaction or statement, got "per_query"`, `client_apps.orders_db.pool_mode`} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected %q in %v", want, err)
+		}
+	}
+
+	problems := config.ValidateRules()
+	if len(problems) != 1 || !strings.Contains(problems[0].Message, `unknown pool mode "connection"`) {
+		t.Errorf("expected the rule check to report the app's pool mode, got %v", problems)
+	}
+}
@@ -12,6 +12,8 @@ import (
 	"time"
 
 	"github.com/sharding-system/pkg/hashing"
+	"github.com/sharding-system/pkg/keyrange"
+	"github.com/sharding-system/pkg/listener"
 	"github.com/sharding-system/pkg/models"
 	_ "github.com/lib/pq"
 	"go.uber.org/zap"
@@ -31,13 +33,15 @@ type ShardingProxy struct {
 	// Shard connections - pooled connections to each shard
 	shardPools   map[string]*sql.DB
 	shardPoolsMu sync.RWMutex
+	driver       string // database/sql driver shard pools are opened with
+	metrics      *poolMetrics
 	
 	// Shard metadata from manager
 	shards       []models.Shard
 	shardsMu     sync.RWMutex
 	
 	// Listeners
-	dbListener   net.Listener
+	dbListeners  []net.Listener
 	adminServer  *http.Server
 	
 	// Lifecycle
@@ -56,6 +60,8 @@ func NewShardingProxy(config *ProxyConfig, logger *zap.Logger) *ShardingProxy {
 		sqlParser:  NewSQLParser(),
 		hashFunc:   hashing.NewHashFunction("murmur3"),
 		shardPools: make(map[string]*sql.DB),
+		driver:     "postgres",
+		metrics:    newPoolMetrics(),
 		ctx:        ctx,
 		cancel:     cancel,
 	}
@@ -81,33 +87,66 @@ func (p *ShardingProxy) Start() error {
 		return fmt.Errorf("failed to start admin server: %w", err)
 	}
 	
-	// Start database proxy listener
-	listener, err := net.Listen("tcp", p.config.ListenAddr)
-	if err != nil {
-		return fmt.Errorf("failed to listen on %s: %w", p.config.ListenAddr, err)
+	// Start database proxy listeners
+	if err := p.listen(); err != nil {
+		return err
 	}
-	p.dbListener = listener
 	
 	p.logger.Info("sharding proxy started",
 		zap.String("db_addr", p.config.ListenAddr),
+		zap.String("db_socket", p.config.ListenSocket),
 		zap.String("admin_addr", p.config.AdminAddr))
 	
 	// Accept connections
-	p.wg.Add(1)
-	go p.acceptLoop()
+	for _, l := range p.dbListeners {
+		p.wg.Add(1)
+		go p.acceptLoop(l)
+	}
 	
 	return nil
 }
 
+// listen opens the TCP listener, the Unix socket listener or both, as
+// configured
+func (p *ShardingProxy) listen() error {
+	if p.config.ListenAddr == "" && p.config.ListenSocket == "" {
+		return fmt.Errorf("no listen address or socket configured")
+	}
+
+	if p.config.ListenAddr != "" {
+		l, err := listener.TCP(p.config.ListenAddr, p.config.tcpOptions())
+		if err != nil {
+			return err
+		}
+		p.dbListeners = append(p.dbListeners, l)
+	}
+	if p.config.ListenSocket != "" {
+		l, err := listener.Unix(p.config.ListenSocket)
+		if err != nil {
+			p.closeListeners()
+			return err
+		}
+		p.dbListeners = append(p.dbListeners, l)
+	}
+	return nil
+}
+
+// closeListeners stops accepting connections, removing the socket file of a
+// Unix socket listener
+func (p *ShardingProxy) closeListeners() {
+	for _, l := range p.dbListeners {
+		l.Close()
+	}
+	p.dbListeners = nil
+}
+
 // Stop stops the proxy server
 func (p *ShardingProxy) Stop() error {
 	p.logger.Info("stopping sharding proxy")
 	
 	p.cancel()
 	
-	if p.dbListener != nil {
-		p.dbListener.Close()
-	}
+	p.closeListeners()
 	
 	if p.adminServer != nil {
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -129,11 +168,11 @@ func (p *ShardingProxy) Stop() error {
 }
 
 // acceptLoop accepts incoming connections
-func (p *ShardingProxy) acceptLoop() {
+func (p *ShardingProxy) acceptLoop(l net.Listener) {
 	defer p.wg.Done()
 	
 	for {
-		conn, err := p.dbListener.Accept()
+		conn, err := l.Accept()
 		if err != nil {
 			select {
 			case <-p.ctx.Done():
@@ -153,6 +192,9 @@ func (p *ShardingProxy) acceptLoop() {
 func (p *ShardingProxy) handleConnection(conn net.Conn) {
 	defer p.wg.Done()
 	defer conn.Close()
+
+	p.metrics.clientConnections.Inc()
+	defer p.metrics.clientConnections.Dec()
 	
 	clientAddr := conn.RemoteAddr().String()
 	p.logger.Debug("new connection", zap.String("client", clientAddr))
@@ -174,8 +216,11 @@ func (p *ShardingProxy) handleConnection(conn net.Conn) {
 	query := string(buf[:n])
 	p.logger.Debug("received query", zap.String("query", query))
 	
-	// Execute the query
-	result, err := p.ExecuteQuery(context.Background(), "default_db", query)
+	// Execute the query on the connection's session, so backend connections
+	// are held as the client app's pool mode requires
+	session := p.NewSession("default_db")
+	defer session.Close()
+	result, err := session.Execute(context.Background(), query)
 	if err != nil {
 		conn.Write([]byte(fmt.Sprintf("ERROR: %s\n", err.Error())))
 		return
@@ -186,34 +231,49 @@ func (p *ShardingProxy) handleConnection(conn net.Conn) {
 	conn.Write(resultJSON)
 }
 
-// ExecuteQuery executes a query with automatic shard routing
+// ExecuteQuery executes a query with automatic shard routing, on backend
+// connections held only for the query
 func (p *ShardingProxy) ExecuteQuery(ctx context.Context, database string, sql string) (*QueryResult, error) {
+	session := p.NewSession(database)
+	defer session.Close()
+	return session.Execute(ctx, sql)
+}
+
+// executeQuery routes a query to its shards, running it on the session's
+// backend connections. Queries that scatter to every shard are held to the
+// fan-out limit, except transaction and session control statements, such as
+// BEGIN or SET, and tables with the broadcast strategy, which have to reach
+// every shard.
+func (p *ShardingProxy) executeQuery(ctx context.Context, session *Session, database string, sql string) (*QueryResult, error) {
 	startTime := time.Now()
 	
 	// Get app config
 	appConfig := p.config.GetAppConfig(database)
 	if appConfig == nil {
 		// No sharding rules, route to default
-		return p.executeOnAllShards(ctx, sql)
+		return p.scatter(ctx, session, database, "", sql)
 	}
 	
 	// Extract table from query
 	table := ExtractTableFromSQL(sql)
 	if table == "" {
 		// Can't determine table, broadcast to all shards
-		return p.executeOnAllShards(ctx, sql)
+		if controlPattern.MatchString(sql) {
+			return p.executeOnAllShards(ctx, session, sql)
+		}
+		return p.scatter(ctx, session, database, "", sql)
 	}
 	
 	// Get sharding rule for this table
 	rule := appConfig.GetShardingRule(table)
 	if rule == nil {
 		// No sharding rule for this table, broadcast
-		return p.executeOnAllShards(ctx, sql)
+		return p.scatter(ctx, session, database, "", sql)
 	}
 	
 	// Handle broadcast strategy
 	if rule.Strategy == "broadcast" {
-		return p.executeOnAllShards(ctx, sql)
+		return p.executeOnAllShards(ctx, session, sql)
 	}
 	
 	// Parse query to extract shard key
@@ -235,7 +295,7 @@ func (p *ShardingProxy) ExecuteQuery(ctx context.Context, database string, sql s
 			return nil, fmt.Errorf("no shard found for key: %s", parsed.ShardValue)
 		}
 		
-		result, err := p.executeOnShard(ctx, shard, sql)
+		result, err := p.executeOnShard(ctx, session, shard, sql)
 		if err != nil {
 			return nil, err
 		}
@@ -246,7 +306,7 @@ func (p *ShardingProxy) ExecuteQuery(ctx context.Context, database string, sql s
 	}
 	
 	// Cross-shard query - scatter-gather
-	return p.executeOnAllShards(ctx, sql)
+	return p.scatter(ctx, session, database, rule.ShardKey, sql)
 }
 
 // getShardForKey returns the shard that owns a given key
@@ -258,8 +318,8 @@ func (p *ShardingProxy) getShardForKey(key string) *models.Shard {
 		return nil
 	}
 	
-	// Hash the key
-	hash := p.hashFunc.Hash(key)
+	// Hash the key with each shard's hash function, once per function
+	hashes := make(map[string]uint64, 1)
 	
 	// Find the shard that owns this hash
 	for i := range p.shards {
@@ -268,6 +328,20 @@ func (p *ShardingProxy) getShardForKey(key string) *models.Shard {
 			continue
 		}
 		
+		// Range shards hold the keys in their key range, unhashed
+		if shard.Strategy == "range" {
+			if keyrange.Contains(shard.Collation, shard.KeyRangeStart, shard.KeyRangeEnd, key) {
+				return shard
+			}
+			continue
+		}
+		
+		hash, ok := hashes[shard.HashFunction]
+		if !ok {
+			hash = p.hashFor(shard.HashFunction).Hash(key)
+			hashes[shard.HashFunction] = hash
+		}
+		
 		// Check if hash falls in this shard's range
 		if hash >= shard.HashRangeStart && hash <= shard.HashRangeEnd {
 			return shard
@@ -284,14 +358,18 @@ func (p *ShardingProxy) getShardForKey(key string) *models.Shard {
 	return nil
 }
 
-// executeOnShard executes a query on a specific shard
-func (p *ShardingProxy) executeOnShard(ctx context.Context, shard *models.Shard, sql string) (*QueryResult, error) {
-	pool := p.getOrCreatePool(shard)
-	if pool == nil {
-		return nil, fmt.Errorf("no connection pool for shard: %s", shard.ID)
+// hashFor returns the hash function shards configured with name place keys
+// with; shards without one use the proxy's default
+func (p *ShardingProxy) hashFor(name string) hashing.HashFunction {
+	if name == "" {
+		return p.hashFunc
 	}
-	
-	rows, err := pool.QueryContext(ctx, sql)
+	return hashing.NewHashFunction(name)
+}
+
+// executeOnShard executes a query on a specific shard
+func (p *ShardingProxy) executeOnShard(ctx context.Context, session *Session, shard *models.Shard, sql string, args ...interface{}) (*QueryResult, error) {
+	rows, err := session.query(ctx, shard, sql, args...)
 	if err != nil {
 		return nil, fmt.Errorf("query failed on shard %s: %w", shard.ID, err)
 	}
@@ -300,12 +378,10 @@ func (p *ShardingProxy) executeOnShard(ctx context.Context, shard *models.Shard,
 	return p.scanResults(rows)
 }
 
-// executeOnAllShards executes a query on all shards (scatter-gather)
-func (p *ShardingProxy) executeOnAllShards(ctx context.Context, sql string) (*QueryResult, error) {
-	p.shardsMu.RLock()
-	shards := make([]models.Shard, len(p.shards))
-	copy(shards, p.shards)
-	p.shardsMu.RUnlock()
+// executeOnAllShards executes a query on all of the session database's
+// shards (scatter-gather)
+func (p *ShardingProxy) executeOnAllShards(ctx context.Context, session *Session, sql string) (*QueryResult, error) {
+	shards := p.databaseShards(session.database)
 	
 	if len(shards) == 0 {
 		return nil, fmt.Errorf("no shards available")
@@ -327,7 +403,7 @@ func (p *ShardingProxy) executeOnAllShards(ctx context.Context, sql string) (*Qu
 		}
 		
 		go func(s *models.Shard) {
-			result, err := p.executeOnShard(ctx, s, sql)
+			result, err := p.executeOnShard(ctx, session, s, sql)
 			results <- shardResult{shardID: s.ID, result: result, err: err}
 		}(shard)
 	}
@@ -364,6 +440,49 @@ func (p *ShardingProxy) executeOnAllShards(ctx context.Context, sql string) (*Qu
 	return combined, nil
 }
 
+// executeOnEveryShard runs a query on every active shard of a database and
+// returns each shard's result. Unlike executeOnAllShards it fails if any shard
+// fails, for results that would be wrong rather than partial without a shard.
+func (p *ShardingProxy) executeOnEveryShard(ctx context.Context, database string, sql string, args ...interface{}) ([]*QueryResult, error) {
+	if err := p.checkFanOut(database, ""); err != nil {
+		return nil, err
+	}
+
+	shards := p.databaseShards(database)
+	if len(shards) == 0 {
+		return nil, fmt.Errorf("no shards available")
+	}
+
+	session := p.NewSession(database)
+	defer session.Close()
+
+	type shardResult struct {
+		result *QueryResult
+		err    error
+	}
+	results := make(chan shardResult, len(shards))
+	for i := range shards {
+		go func(s *models.Shard) {
+			result, err := p.executeOnShard(ctx, session, s, sql, args...)
+			results <- shardResult{result: result, err: err}
+		}(&shards[i])
+	}
+
+	combined := make([]*QueryResult, 0, len(shards))
+	for range shards {
+		select {
+		case sr := <-results:
+			if sr.err != nil {
+				return nil, sr.err
+			}
+			combined = append(combined, sr.result)
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	return combined, nil
+}
+
 // scanResults scans query results into a QueryResult
 func (p *ShardingProxy) scanResults(rows *sql.Rows) (*QueryResult, error) {
 	columns, err := rows.Columns()
@@ -375,6 +494,12 @@ func (p *ShardingProxy) scanResults(rows *sql.Rows) (*QueryResult, error) {
 		Columns: columns,
 		Rows:    make([]map[string]interface{}, 0),
 	}
+	if types, err := rows.ColumnTypes(); err == nil {
+		result.Types = make([]string, len(types))
+		for i, columnType := range types {
+			result.Types[i] = columnType.DatabaseTypeName()
+		}
+	}
 	
 	for rows.Next() {
 		values := make([]interface{}, len(columns))
@@ -417,7 +542,7 @@ func (p *ShardingProxy) getOrCreatePool(shard *models.Shard) *sql.DB {
 	}
 	
 	// Create new pool
-	db, err := sql.Open("postgres", shard.PrimaryEndpoint)
+	db, err := sql.Open(p.driver, shard.PrimaryEndpoint)
 	if err != nil {
 		p.logger.Error("failed to create connection pool",
 			zap.String("shard", shard.ID),
@@ -486,6 +611,7 @@ func (p *ShardingProxy) shardRefreshLoop() {
 type QueryResult struct {
 	Columns   []string                 `json:"columns,omitempty"`
 	Rows      []map[string]interface{} `json:"rows"`
+	Types     []string                 `json:"-"` // Database type name of each column, where the driver reports it
 	RowCount  int                      `json:"row_count"`
 	RoutedTo  string                   `json:"routed_to"` // Shard ID or "all_shards"
 	LatencyMs float64                  `json:"latency_ms"`
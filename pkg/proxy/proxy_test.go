@@ -0,0 +1,123 @@
+package proxy
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zaptest"
+)
+
+// startSocketProxy starts a proxy with no shards listening on a Unix socket
+// and, when tcp is set, on a loopback TCP port
+func startSocketProxy(t *testing.T, tcp bool) (*ShardingProxy, string) {
+	t.Helper()
+	manager := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("[]"))
+	}))
+	t.Cleanup(manager.Close)
+
+	config := NewProxyConfig()
+	config.ListenAddr = ""
+	if tcp {
+		config.ListenAddr = "127.0.0.1:0"
+	}
+	config.ListenSocket = filepath.Join(t.TempDir(), "proxy.sock")
+	config.AdminAddr = "127.0.0.1:0"
+	config.ManagerURL = manager.URL
+
+	p := NewShardingProxy(config, zaptest.NewLogger(t))
+	if err := p.Start(); err != nil {
+		t.Fatalf("failed to start proxy: %v", err)
+	}
+	return p, config.ListenSocket
+}
+
+// query sends a query over a connection and returns the proxy's reply
+func query(t *testing.T, network, address, sql string) string {
+	t.Helper()
+	conn, err := net.Dial(network, address)
+	if err != nil {
+		t.Fatalf("failed to connect over %s: %v", network, err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte(sql)); err != nil {
+		t.Fatalf("failed to send query: %v", err)
+	}
+	reply, err := io.ReadAll(conn)
+	if err != nil {
+		t.Fatalf("failed to read reply: %v", err)
+	}
+	return string(reply)
+}
+
+func TestShardingProxy_ServesUnixSocket(t *testing.T) {
+	p, socket := startSocketProxy(t, false)
+
+	if len(p.dbListeners) != 1 {
+		t.Fatalf("expected only the socket listener, got %d listeners", len(p.dbListeners))
+	}
+	// With no shards the query is answered with an error, which is enough to
+	// show it went through the proxy
+	if reply := query(t, "unix", socket, "SELECT 1"); !strings.HasPrefix(reply, "ERROR: no shards available") {
+		t.Errorf("unexpected reply over the socket: %q", reply)
+	}
+
+	if err := p.Stop(); err != nil {
+		t.Fatalf("failed to stop proxy: %v", err)
+	}
+	if _, err := os.Lstat(socket); !os.IsNotExist(err) {
+		t.Errorf("expected the socket file to be removed on shutdown, got %v", err)
+	}
+}
+
+func TestShardingProxy_ServesUnixSocketAlongsideTCP(t *testing.T) {
+	p, socket := startSocketProxy(t, true)
+	defer p.Stop()
+
+	if len(p.dbListeners) != 2 {
+		t.Fatalf("expected TCP and socket listeners, got %d", len(p.dbListeners))
+	}
+	tcpAddr := p.dbListeners[0].Addr().String()
+	for network, address := range map[string]string{"tcp": tcpAddr, "unix": socket} {
+		if reply := query(t, network, address, "SELECT 1"); !strings.HasPrefix(reply, "ERROR:") {
+			t.Errorf("unexpected reply over %s: %q", network, reply)
+		}
+	}
+}
+
+func TestShardingProxy_RequiresAListener(t *testing.T) {
+	config := NewProxyConfig()
+	config.ListenAddr = ""
+	p := NewShardingProxy(config, zaptest.NewLogger(t))
+	if err := p.listen(); err == nil {
+		t.Error("expected an error with neither a listen address nor a socket")
+	}
+}
+
+func TestShardingProxy_AppliesTCPTuning(t *testing.T) {
+	config := NewProxyConfig()
+	config.AdminAddr = "127.0.0.1:0"
+	config.TCPKeepAliveSeconds = 30
+	config.MaxHeaderBytes = 32 << 10
+
+	opts := config.tcpOptions()
+	if opts.KeepAlive != 30*time.Second {
+		t.Errorf("expected keepalive 30s, got %+v", opts)
+	}
+
+	p := NewShardingProxy(config, zaptest.NewLogger(t))
+	if err := p.startAdminServer(); err != nil {
+		t.Fatalf("failed to start admin server: %v", err)
+	}
+	defer p.adminServer.Close()
+	if p.adminServer.MaxHeaderBytes != 32<<10 {
+		t.Errorf("expected admin max header bytes %d, got %d", 32<<10, p.adminServer.MaxHeaderBytes)
+	}
+}
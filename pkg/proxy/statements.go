@@ -0,0 +1,115 @@
+package proxy
+
+import (
+	"container/list"
+	"context"
+	"database/sql"
+	"strings"
+	"sync"
+)
+
+// stalePlanError is the message PostgreSQL fails a prepared statement with
+// once a schema change alters the columns its plan returns. Preparing the
+// query again picks up the new columns.
+const stalePlanError = "cached plan must not change result type"
+
+// statementCache keeps the statements run on one backend connection prepared,
+// so queries a client repeats are parsed and planned by the shard only once.
+// Server-side prepared statements live as long as the connection, so once the
+// cache is full the least recently used statement is deallocated to make room.
+type statementCache struct {
+	conn    *sql.Conn
+	shardID string
+	max     int
+	metrics *poolMetrics
+
+	mu    sync.Mutex
+	order *list.List               // Of *cachedStatement, most recently used first
+	stmts map[string]*list.Element // By query
+}
+
+// cachedStatement is a statement prepared on a backend connection
+type cachedStatement struct {
+	query string
+	stmt  *sql.Stmt
+}
+
+// newStatementCache creates a cache of at most max statements prepared on a
+// shard's backend connection
+func newStatementCache(conn *sql.Conn, shardID string, max int, metrics *poolMetrics) *statementCache {
+	return &statementCache{
+		conn:    conn,
+		shardID: shardID,
+		max:     max,
+		metrics: metrics,
+		order:   list.New(),
+		stmts:   make(map[string]*list.Element),
+	}
+}
+
+// query runs a query with its prepared statement, preparing it first if it
+// isn't cached. Queries that cannot be prepared, e.g. because they hold
+// several statements, run unprepared. A statement whose plan a schema change
+// invalidated is deallocated, so the query is prepared afresh next time.
+func (c *statementCache) query(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.stmts[query]; ok {
+		c.order.MoveToFront(el)
+		c.metrics.statementHit(c.shardID)
+		rows, err := el.Value.(*cachedStatement).stmt.QueryContext(ctx, args...)
+		if err != nil && strings.Contains(err.Error(), stalePlanError) {
+			c.evict(el)
+		}
+		return rows, err
+	}
+
+	c.metrics.statementMiss(c.shardID)
+	stmt, err := c.conn.PrepareContext(ctx, query)
+	if err != nil {
+		return c.conn.QueryContext(ctx, query, args...)
+	}
+	for c.order.Len() >= c.max {
+		c.evictOldest()
+	}
+	c.stmts[query] = c.order.PushFront(&cachedStatement{query: query, stmt: stmt})
+	c.metrics.statementPrepared(c.shardID, 1)
+	return stmt.QueryContext(ctx, args...)
+}
+
+// evictOldest deallocates the least recently used statement. Must be called
+// with c.mu held.
+func (c *statementCache) evictOldest() {
+	c.evict(c.order.Back())
+}
+
+// evict deallocates a statement. Must be called with c.mu held.
+func (c *statementCache) evict(el *list.Element) {
+	cached := el.Value.(*cachedStatement)
+	cached.stmt.Close()
+	c.order.Remove(el)
+	delete(c.stmts, cached.query)
+	c.metrics.statementPrepared(c.shardID, -1)
+	c.metrics.statementEvicted(c.shardID)
+}
+
+// len returns the number of statements prepared
+func (c *statementCache) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// close deallocates every prepared statement, before the backend connection
+// goes back to its pool
+func (c *statementCache) close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for el := c.order.Front(); el != nil; el = el.Next() {
+		el.Value.(*cachedStatement).stmt.Close()
+	}
+	c.metrics.statementPrepared(c.shardID, -c.order.Len())
+	c.order.Init()
+	c.stmts = make(map[string]*list.Element)
+}
@@ -0,0 +1,226 @@
+package proxy
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sharding-system/pkg/models"
+	"go.uber.org/zap/zaptest"
+)
+
+// stmtDriver opens connections that support prepared statements and records
+// which queries are prepared and deallocated
+type stmtDriver struct {
+	mu          sync.Mutex
+	prepared    []string
+	deallocated []string
+	stale       map[string]bool // Prepared queries whose plan a schema change invalidated
+}
+
+var testStmtDriver = &stmtDriver{}
+
+func init() {
+	sql.Register("proxystmt", testStmtDriver)
+}
+
+// reset forgets the statements recorded so far
+func (d *stmtDriver) reset() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.prepared, d.deallocated, d.stale = nil, nil, nil
+}
+
+// invalidate makes the statements prepared so far for query fail like
+// PostgreSQL does after a schema change alters its result columns
+func (d *stmtDriver) invalidate(query string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.stale == nil {
+		d.stale = make(map[string]bool)
+	}
+	d.stale[query] = true
+}
+
+// recorded returns the queries prepared and deallocated so far
+func (d *stmtDriver) recorded() (prepared, deallocated []string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]string(nil), d.prepared...), append([]string(nil), d.deallocated...)
+}
+
+func (d *stmtDriver) Open(name string) (driver.Conn, error) { return &stmtConn{driver: d}, nil }
+
+type stmtConn struct {
+	driver *stmtDriver
+}
+
+func (c *stmtConn) Prepare(query string) (driver.Stmt, error) {
+	c.driver.mu.Lock()
+	defer c.driver.mu.Unlock()
+	c.driver.prepared = append(c.driver.prepared, query)
+	delete(c.driver.stale, query)
+	return &stmtStmt{driver: c.driver, query: query}, nil
+}
+
+func (c *stmtConn) Close() error { return nil }
+
+func (c *stmtConn) Begin() (driver.Tx, error) { return nil, driver.ErrSkip }
+
+type stmtStmt struct {
+	driver *stmtDriver
+	query  string
+}
+
+func (s *stmtStmt) Close() error {
+	s.driver.mu.Lock()
+	defer s.driver.mu.Unlock()
+	s.driver.deallocated = append(s.driver.deallocated, s.query)
+	return nil
+}
+
+func (s *stmtStmt) NumInput() int { return 0 }
+
+func (s *stmtStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return driver.RowsAffected(0), nil
+}
+
+func (s *stmtStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.driver.mu.Lock()
+	defer s.driver.mu.Unlock()
+	if s.driver.stale[s.query] {
+		return nil, errors.New("pq: " + stalePlanError)
+	}
+	return &stmtRows{}, nil
+}
+
+type stmtRows struct{}
+
+func (r *stmtRows) Columns() []string              { return []string{"n"} }
+func (r *stmtRows) Close() error                   { return nil }
+func (r *stmtRows) Next(dest []driver.Value) error { return io.EOF }
+
+// newStatementProxy returns a proxy in front of one shard that keeps at most
+// max statements prepared per backend connection
+func newStatementProxy(t *testing.T, max int) *ShardingProxy {
+	t.Helper()
+	testStmtDriver.reset()
+	config := NewProxyConfig()
+	config.MaxPreparedStatements = max
+
+	p := NewShardingProxy(config, zaptest.NewLogger(t))
+	p.driver = "proxystmt"
+	p.shards = []models.Shard{{ID: "shard-1", Status: "active", PrimaryEndpoint: t.Name()}}
+	t.Cleanup(func() { p.Stop() })
+	return p
+}
+
+func TestStatementCache_EvictsLeastRecentlyUsedAtCap(t *testing.T) {
+	p := newStatementProxy(t, 2)
+	session := p.NewSession("orders_db")
+
+	for _, query := range []string{"SELECT a", "SELECT b", "SELECT a", "SELECT c", "SELECT b"} {
+		if _, err := session.Execute(context.Background(), query); err != nil {
+			t.Fatalf("%s: unexpected error: %v", query, err)
+		}
+	}
+
+	// SELECT c evicts SELECT b, the least recently used, and SELECT b evicts
+	// SELECT a in turn
+	prepared, deallocated := testStmtDriver.recorded()
+	if len(prepared) != 4 {
+		t.Errorf("expected 4 statements prepared, got %v", prepared)
+	}
+	if len(deallocated) != 2 || deallocated[0] != "SELECT b" || deallocated[1] != "SELECT a" {
+		t.Errorf("expected SELECT b and then SELECT a to be deallocated, got %v", deallocated)
+	}
+	if n := session.stmts["shard-1"].len(); n != 2 {
+		t.Errorf("expected the cache to stay at its cap of 2, got %d", n)
+	}
+	if got := testutil.ToFloat64(p.metrics.statementEvictions.WithLabelValues("shard-1")); got != 2 {
+		t.Errorf("expected 2 evictions, got %v", got)
+	}
+	if got := testutil.ToFloat64(p.metrics.preparedStatements.WithLabelValues("shard-1")); got != 2 {
+		t.Errorf("expected 2 prepared statements, got %v", got)
+	}
+
+	// Statements are deallocated before the connection goes back to the pool
+	session.Close()
+	if _, deallocated := testStmtDriver.recorded(); len(deallocated) != 4 {
+		t.Errorf("expected every statement deallocated on close, got %v", deallocated)
+	}
+	if got := testutil.ToFloat64(p.metrics.preparedStatements.WithLabelValues("shard-1")); got != 0 {
+		t.Errorf("expected no prepared statements after close, got %v", got)
+	}
+}
+
+func TestStatementCache_MetricsReflectHitsAndMisses(t *testing.T) {
+	p := newStatementProxy(t, 10)
+	session := p.NewSession("orders_db")
+	defer session.Close()
+
+	for _, query := range []string{"SELECT a", "SELECT a", "SELECT a", "SELECT b"} {
+		if _, err := session.Execute(context.Background(), query); err != nil {
+			t.Fatalf("%s: unexpected error: %v", query, err)
+		}
+	}
+
+	if got := testutil.ToFloat64(p.metrics.statementCacheHits.WithLabelValues("shard-1")); got != 2 {
+		t.Errorf("expected 2 hits, got %v", got)
+	}
+	if got := testutil.ToFloat64(p.metrics.statementCacheMisses.WithLabelValues("shard-1")); got != 2 {
+		t.Errorf("expected 2 misses, got %v", got)
+	}
+	stats := p.metrics.statementCacheStats()
+	if stats["hit_rate"] != 0.5 || stats["prepared_statements"] != int64(2) || stats["evictions"] != int64(0) {
+		t.Errorf("expected a hit rate of 0.5 with 2 statements prepared, got %v", stats)
+	}
+}
+
+func TestStatementCache_Disabled(t *testing.T) {
+	// Caching is opt-in, so it is off unless a positive limit is set
+	for _, max := range []int{0, -1} {
+		p := newStatementProxy(t, max)
+		session := p.NewSession("orders_db")
+
+		if _, err := session.Execute(context.Background(), "SELECT a"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(session.stmts) != 0 {
+			t.Errorf("max %d: expected no statement cache, got %v", max, session.stmts)
+		}
+		if got := testutil.ToFloat64(p.metrics.statementCacheMisses.WithLabelValues("shard-1")); got != 0 {
+			t.Errorf("max %d: expected the cache to be bypassed, got %v misses", max, got)
+		}
+		session.Close()
+	}
+}
+
+func TestStatementCache_EvictsStalePlans(t *testing.T) {
+	p := newStatementProxy(t, 10)
+	session := p.NewSession("orders_db")
+	defer session.Close()
+
+	if _, err := session.Execute(context.Background(), "SELECT * FROM orders"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// A column is added to orders: the prepared statement fails once, is
+	// deallocated and is prepared again for the next run
+	testStmtDriver.invalidate("SELECT * FROM orders")
+	session.Execute(context.Background(), "SELECT * FROM orders")
+	if n := session.stmts["shard-1"].len(); n != 0 {
+		t.Errorf("expected the stale statement to be evicted, got %d cached", n)
+	}
+	if _, err := session.Execute(context.Background(), "SELECT * FROM orders"); err != nil {
+		t.Fatalf("expected the query to be prepared again, got %v", err)
+	}
+	if prepared, deallocated := testStmtDriver.recorded(); len(prepared) != 2 || len(deallocated) != 1 {
+		t.Errorf("expected the query prepared twice and deallocated once, got %v and %v", prepared, deallocated)
+	}
+}
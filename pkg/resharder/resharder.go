@@ -4,11 +4,15 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"hash/fnv"
+	"strings"
 	"time"
 
 	"github.com/sharding-system/pkg/catalog"
 	"github.com/sharding-system/pkg/hashing"
+	"github.com/sharding-system/pkg/keyrange"
 	"github.com/sharding-system/pkg/models"
+	"github.com/sharding-system/pkg/observability"
 	_ "github.com/lib/pq"
 	"go.uber.org/zap"
 )
@@ -17,6 +21,7 @@ import (
 type Resharder struct {
 	catalog catalog.Catalog
 	logger  *zap.Logger
+	driver  string // database/sql driver used to reach shards
 }
 
 // NewResharder creates a new resharder instance
@@ -24,6 +29,7 @@ func NewResharder(catalog catalog.Catalog, logger *zap.Logger) *Resharder {
 	return &Resharder{
 		catalog: catalog,
 		logger:  logger,
+		driver:  "postgres",
 	}
 }
 
@@ -41,27 +47,44 @@ func (r *Resharder) Split(ctx context.Context, job *models.ReshardJob) error {
 
 	// Phase 1: Pre-copy (bulk copy)
 	r.logger.Info("starting pre-copy phase", zap.String("job_id", job.ID))
+	start := time.Now()
 	if err := r.preCopy(ctx, job, sourceShard); err != nil {
 		return fmt.Errorf("pre-copy failed: %w", err)
 	}
+	recordPhase(job, "precopy", sourceShardID, start)
 
 	// Phase 2: Delta sync (capture changes during copy)
 	r.logger.Info("starting delta sync phase", zap.String("job_id", job.ID))
+	start = time.Now()
 	if err := r.deltaSync(ctx, job, sourceShard); err != nil {
 		return fmt.Errorf("delta sync failed: %w", err)
 	}
+	recordPhase(job, "deltasync", sourceShardID, start)
+
+	// Phase 3: Verification (targets hold exactly the source's rows)
+	r.logger.Info("starting verification phase", zap.String("job_id", job.ID))
+	start = time.Now()
+	if err := r.verify(ctx, job, []*models.Shard{sourceShard}); err != nil {
+		r.restoreSources(job, []*models.Shard{sourceShard})
+		return fmt.Errorf("verification failed: %w", err)
+	}
+	recordPhase(job, "verification", sourceShardID, start)
 
-	// Phase 3: Cutover (switch routing)
+	// Phase 4: Cutover (switch routing)
 	r.logger.Info("starting cutover phase", zap.String("job_id", job.ID))
+	start = time.Now()
 	if err := r.cutover(ctx, job, sourceShard); err != nil {
 		return fmt.Errorf("cutover failed: %w", err)
 	}
+	recordPhase(job, "cutover", sourceShardID, start)
 
-	// Phase 4: Validation
+	// Phase 5: Validation
 	r.logger.Info("starting validation phase", zap.String("job_id", job.ID))
+	start = time.Now()
 	if err := r.validate(ctx, job, sourceShard); err != nil {
 		return fmt.Errorf("validation failed: %w", err)
 	}
+	recordPhase(job, "validation", sourceShardID, start)
 
 	return nil
 }
@@ -79,44 +102,117 @@ func (r *Resharder) Merge(ctx context.Context, job *models.ReshardJob) error {
 	}
 
 	// Copy data from all source shards to target
+	sources := make([]*models.Shard, 0, len(job.SourceShards))
 	for _, sourceShardID := range job.SourceShards {
 		sourceShard, err := r.catalog.GetShardByID(sourceShardID)
 		if err != nil {
 			return fmt.Errorf("failed to get source shard %s: %w", sourceShardID, err)
 		}
+		sources = append(sources, sourceShard)
 
 		// Pre-copy from this source
+		start := time.Now()
 		if err := r.preCopy(ctx, job, sourceShard); err != nil {
 			return fmt.Errorf("pre-copy from %s failed: %w", sourceShardID, err)
 		}
+		recordPhase(job, "precopy", sourceShardID, start)
 
 		// Delta sync
+		start = time.Now()
 		if err := r.deltaSync(ctx, job, sourceShard); err != nil {
 			return fmt.Errorf("delta sync from %s failed: %w", sourceShardID, err)
 		}
+		recordPhase(job, "deltasync", sourceShardID, start)
+	}
+
+	// Verification
+	start := time.Now()
+	if err := r.verify(ctx, job, sources); err != nil {
+		r.restoreSources(job, sources)
+		return fmt.Errorf("verification failed: %w", err)
 	}
+	recordPhase(job, "verification", "", start)
 
 	// Cutover
+	start = time.Now()
 	if err := r.cutover(ctx, job, nil); err != nil {
 		return fmt.Errorf("cutover failed: %w", err)
 	}
+	recordPhase(job, "cutover", "", start)
 
 	// Validation
+	start = time.Now()
 	if err := r.validate(ctx, job, nil); err != nil {
 		return fmt.Errorf("validation failed: %w", err)
 	}
+	recordPhase(job, "validation", "", start)
 
 	return nil
 }
 
-// preCopy performs bulk copy of data
-func (r *Resharder) preCopy(ctx context.Context, job *models.ReshardJob, sourceShard *models.Shard) error {
-	sourceDB, err := sql.Open("postgres", sourceShard.PrimaryEndpoint)
-	if err != nil {
-		return fmt.Errorf("failed to connect to source: %w", err)
+// Rekey moves every row of the source shards, a client application's current
+// layout, onto the target shards by the job's new shard key column. Like a
+// merge, each source is copied and delta synced, then the targets are verified
+// against all sources before cutover. At cutover the targets take over the
+// app's traffic; the sources, read-only since their delta sync, are left for
+// the caller to take out of routing.
+func (r *Resharder) Rekey(ctx context.Context, job *models.ReshardJob) error {
+	if len(job.SourceShards) == 0 || len(job.TargetShards) == 0 {
+		return fmt.Errorf("invalid rekey job: missing source or target shards")
 	}
-	defer sourceDB.Close()
+	if job.ShardKey == "" {
+		return fmt.Errorf("invalid rekey job: missing shard key")
+	}
+
+	sources := make([]*models.Shard, 0, len(job.SourceShards))
+	for _, sourceShardID := range job.SourceShards {
+		sourceShard, err := r.catalog.GetShardByID(sourceShardID)
+		if err != nil {
+			return fmt.Errorf("failed to get source shard %s: %w", sourceShardID, err)
+		}
+		sources = append(sources, sourceShard)
 
+		start := time.Now()
+		if err := r.preCopy(ctx, job, sourceShard); err != nil {
+			r.restoreSources(job, sources)
+			return fmt.Errorf("pre-copy from %s failed: %w", sourceShardID, err)
+		}
+		recordPhase(job, "precopy", sourceShardID, start)
+
+		start = time.Now()
+		if err := r.deltaSync(ctx, job, sourceShard); err != nil {
+			r.restoreSources(job, sources)
+			return fmt.Errorf("delta sync from %s failed: %w", sourceShardID, err)
+		}
+		recordPhase(job, "deltasync", sourceShardID, start)
+	}
+
+	// Verification, with the sources' rows bucketed by the new key
+	start := time.Now()
+	if err := r.verify(ctx, job, sources); err != nil {
+		r.restoreSources(job, sources)
+		return fmt.Errorf("verification failed: %w", err)
+	}
+	recordPhase(job, "verification", "", start)
+
+	// Cutover: the new layout takes the traffic
+	start = time.Now()
+	if err := r.cutover(ctx, job, nil); err != nil {
+		return fmt.Errorf("cutover failed: %w", err)
+	}
+	recordPhase(job, "cutover", "", start)
+
+	start = time.Now()
+	if err := r.validate(ctx, job, nil); err != nil {
+		return fmt.Errorf("validation failed: %w", err)
+	}
+	recordPhase(job, "validation", "", start)
+
+	return nil
+}
+
+// preCopy performs bulk copy of data
+func (r *Resharder) preCopy(ctx context.Context, job *models.ReshardJob, sourceShard *models.Shard) error {
 	// Get target shards
 	targetShards := make([]*models.Shard, 0, len(job.TargetShards))
 	for _, targetID := range job.TargetShards {
@@ -127,19 +223,116 @@ func (r *Resharder) preCopy(ctx context.Context, job *models.ReshardJob, sourceS
 		targetShards = append(targetShards, targetShard)
 	}
 
-	// For simplicity, we'll copy all rows
-	// In production, you'd filter by hash range
+	place, err := r.placementFor(job, sourceShard, targetShards)
+	if err != nil {
+		return err
+	}
+	metrics := copyMetrics{jobID: job.ID, sourceShard: sourceShard.ID}
+	if _, err := r.copyRows(ctx, sourceShard.PrimaryEndpoint, job.ShardKey, place, metrics, func(n int) {
+		job.KeysMigrated += int64(n)
+	}); err != nil {
+		return err
+	}
+
+	job.TotalKeys = job.KeysMigrated
+	job.Progress = 0.5 // Pre-copy is 50% of the work
+
+	return nil
+}
+
+// CopyShardData copies all rows of a shard to another database. Rows that
+// already exist on the target are skipped, so repeated calls only add rows
+// written since the previous copy.
+func (r *Resharder) CopyShardData(ctx context.Context, shard *models.Shard, targetEndpoint string) (int64, error) {
+	// A single target keeps the shard's ID so every row routes to it
+	target := &models.Shard{ID: shard.ID, PrimaryEndpoint: targetEndpoint, VNodes: shard.VNodes}
+	return r.copyRows(ctx, shard.PrimaryEndpoint, "", newPlacement([]*models.Shard{target}), copyMetrics{}, nil)
+}
+
+// CountShardRows counts the rows of the data table at endpoint. A database
+// without the table has none.
+func (r *Resharder) CountShardRows(ctx context.Context, endpoint string) (int64, error) {
+	db, err := sql.Open(r.driver, endpoint)
+	if err != nil {
+		return 0, fmt.Errorf("failed to connect: %w", err)
+	}
+	defer db.Close()
+
+	var count int64
+	if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM data").Scan(&count); err != nil {
+		if isMissingTable(err) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("failed to count rows: %w", err)
+	}
+	return count, nil
+}
+
+// DecommissionEndpoint drops shard data from a database that no longer
+// receives traffic after a move
+func (r *Resharder) DecommissionEndpoint(ctx context.Context, endpoint string) error {
+	db, err := sql.Open(r.driver, endpoint)
+	if err != nil {
+		return fmt.Errorf("failed to connect to source: %w", err)
+	}
+	defer db.Close()
+
+	if _, err := db.ExecContext(ctx, "DROP TABLE IF EXISTS data"); err != nil {
+		return fmt.Errorf("failed to drop source data: %w", err)
+	}
+
+	r.logger.Info("decommissioned source endpoint after move")
+	return nil
+}
+
+// copyRows copies the data table from sourceEndpoint to the target shards in
+// batches, placing rows by keyColumn, or by the default shard key column when
+// it is empty, and calling onBatch after each batch is written
+func (r *Resharder) copyRows(ctx context.Context, sourceEndpoint, keyColumn string, place *placement, metrics copyMetrics, onBatch func(n int)) (int64, error) {
+	sourceDB, err := sql.Open(r.driver, sourceEndpoint)
+	if err != nil {
+		return 0, fmt.Errorf("failed to connect to source: %w", err)
+	}
+	defer sourceDB.Close()
+
+	// Rows outside the source's key range are skipped as they are placed. A
+	// source without the table has nothing to copy, but any other failure to
+	// read it must stop the copy, or the targets would take over without data.
 	rows, err := sourceDB.QueryContext(ctx, "SELECT * FROM data")
 	if err != nil {
-		// Table might not exist yet, that's okay
+		if !isMissingTable(err) {
+			return 0, fmt.Errorf("failed to read source data: %w", err)
+		}
 		r.logger.Warn("no data table found, skipping pre-copy", zap.Error(err))
-		return nil
+		return 0, nil
 	}
 	defer rows.Close()
 
 	columns, _ := rows.Columns()
+	shardKeyIndex, found := routingColumn(columns, keyColumn)
+	if !found {
+		if keyColumn != "" {
+			return 0, fmt.Errorf("data table has no %s column", keyColumn)
+		}
+		r.logger.Warn("no shard_key column found, using first column for routing")
+	}
 	batchSize := 1000
 	batch := make([][]interface{}, 0, batchSize)
+	var copied int64
+	start := time.Now()
+
+	flush := func() error {
+		if err := r.copyBatch(ctx, batch, columns, shardKeyIndex, place, metrics); err != nil {
+			return err
+		}
+		copied += int64(len(batch))
+		metrics.rate(copied, time.Since(start))
+		if onBatch != nil {
+			onBatch(len(batch))
+		}
+		batch = batch[:0]
+		return nil
+	}
 
 	for rows.Next() {
 		values := make([]interface{}, len(columns))
@@ -149,68 +342,38 @@ func (r *Resharder) preCopy(ctx context.Context, job *models.ReshardJob, sourceS
 		}
 
 		if err := rows.Scan(valuePtrs...); err != nil {
-			return fmt.Errorf("failed to scan row: %w", err)
+			return copied, fmt.Errorf("failed to scan row: %w", err)
 		}
 
 		batch = append(batch, values)
 
 		if len(batch) >= batchSize {
-			if err := r.copyBatch(ctx, batch, columns, targetShards); err != nil {
-				return err
+			if err := flush(); err != nil {
+				return copied, err
 			}
-			job.KeysMigrated += int64(len(batch))
-			batch = batch[:0]
 		}
 	}
 
 	// Copy remaining batch
 	if len(batch) > 0 {
-		if err := r.copyBatch(ctx, batch, columns, targetShards); err != nil {
-			return err
+		if err := flush(); err != nil {
+			return copied, err
 		}
-		job.KeysMigrated += int64(len(batch))
+	}
+	if err := rows.Err(); err != nil {
+		return copied, fmt.Errorf("failed to read source data: %w", err)
 	}
 
-	job.TotalKeys = job.KeysMigrated
-	job.Progress = 0.5 // Pre-copy is 50% of the work
-
-	return nil
+	return copied, nil
 }
 
-// copyBatch copies a batch of rows to target shards using hash-based routing
-func (r *Resharder) copyBatch(ctx context.Context, batch [][]interface{}, columns []string, targetShards []*models.Shard) error {
-	// Use consistent hashing to route rows to correct target shards
-	hashFunc := hashing.NewHashFunction("murmur3")
-	consistentHash := hashing.NewConsistentHash(hashFunc)
-	
-	// Add target shards to hash ring
-	for _, shard := range targetShards {
-		vnodeCount := len(shard.VNodes)
-		if vnodeCount == 0 {
-			vnodeCount = 256 // default
-		}
-		consistentHash.AddShard(shard.ID, vnodeCount)
-	}
-
+// copyBatch copies a batch of rows to the target shards they are placed on
+func (r *Resharder) copyBatch(ctx context.Context, batch [][]interface{}, columns []string, shardKeyIndex int, place *placement, metrics copyMetrics) error {
 	// Group rows by target shard
 	shardRows := make(map[string][][]interface{})
-	shardKeyIndex := -1
-	
-	// Find shard_key column index (assuming first column or column named 'shard_key' or 'id')
-	for i, col := range columns {
-		if col == "shard_key" || col == "id" || col == "key" {
-			shardKeyIndex = i
-			break
-		}
-	}
-	
-	// If no shard_key column found, use first column as fallback
-	if shardKeyIndex == -1 {
-		shardKeyIndex = 0
-		r.logger.Warn("no shard_key column found, using first column for routing")
-	}
 
 	// Route each row to appropriate shard
+	skipped := 0
 	for _, row := range batch {
 		if shardKeyIndex >= len(row) {
 			r.logger.Warn("row missing shard key column, skipping")
@@ -218,30 +381,19 @@ func (r *Resharder) copyBatch(ctx context.Context, batch [][]interface{}, column
 		}
 
 		// Extract shard key (convert to string)
-		var shardKey string
-		switch v := row[shardKeyIndex].(type) {
-		case string:
-			shardKey = v
-		case []byte:
-			shardKey = string(v)
-		default:
-			shardKey = fmt.Sprintf("%v", v)
-		}
+		shardKey := keyString(row[shardKeyIndex])
 
-		// Determine target shard using consistent hashing
-		targetShardID := consistentHash.GetShard(shardKey)
+		targetShardID := place.target(shardKey)
 		if targetShardID == "" {
-			// Fallback: use first shard if hash ring is empty
-			if len(targetShards) > 0 {
-				targetShardID = targetShards[0].ID
-			} else {
-				r.logger.Warn("no target shards available, skipping row")
-				continue
-			}
+			skipped++
+			continue
 		}
 
 		shardRows[targetShardID] = append(shardRows[targetShardID], row)
 	}
+	if skipped > 0 {
+		r.logger.Debug("skipped rows outside the source's key range", zap.Int("rows", skipped))
+	}
 
 	// Build INSERT statement once
 	placeholders := ""
@@ -259,7 +411,7 @@ func (r *Resharder) copyBatch(ctx context.Context, batch [][]interface{}, column
 	for shardID, rows := range shardRows {
 		// Find the shard
 		var targetShard *models.Shard
-		for _, shard := range targetShards {
+		for _, shard := range place.targets {
 			if shard.ID == shardID {
 				targetShard = shard
 				break
@@ -270,8 +422,9 @@ func (r *Resharder) copyBatch(ctx context.Context, batch [][]interface{}, column
 			continue
 		}
 
-		targetDB, err := sql.Open("postgres", targetShard.PrimaryEndpoint)
+		targetDB, err := sql.Open(r.driver, targetShard.PrimaryEndpoint)
 		if err != nil {
+			metrics.copyError(shardID, len(rows))
 			return fmt.Errorf("failed to connect to target %s: %w", shardID, err)
 		}
 
@@ -281,6 +434,7 @@ func (r *Resharder) copyBatch(ctx context.Context, batch [][]interface{}, column
 
 			stmt, err := targetDB.PrepareContext(ctx, query)
 			if err != nil {
+				metrics.copyError(shardID, len(rows))
 				r.logger.Error("failed to prepare statement", zap.String("shard_id", shardID), zap.Error(err))
 				return
 			}
@@ -288,9 +442,12 @@ func (r *Resharder) copyBatch(ctx context.Context, batch [][]interface{}, column
 
 			for _, row := range rows {
 				if _, err := stmt.ExecContext(ctx, row...); err != nil {
+					metrics.copyError(shardID, 1)
 					r.logger.Warn("failed to insert row", zap.String("shard_id", shardID), zap.Error(err))
 					// Continue with other rows
+					continue
 				}
+				metrics.rowCopied(shardID, row)
 			}
 		}()
 	}
@@ -324,6 +481,145 @@ func (r *Resharder) deltaSync(ctx context.Context, job *models.ReshardJob, sourc
 	return nil
 }
 
+// verifySampleRate is how many rows share one checksummed row. Rows are
+// sampled by key hash, so the source and targets checksum the same rows.
+const verifySampleRate = 16
+
+// rangeDigest summarizes the rows of one target shard's key range
+type rangeDigest struct {
+	rows     int64
+	checksum uint64 // Order-independent sum of the sampled rows' hashes
+}
+
+func (d *rangeDigest) add(key string, row []interface{}) {
+	d.rows++
+	if hashing.KeyHash(key)%verifySampleRate != 0 {
+		return
+	}
+	h := fnv.New64a()
+	for _, value := range row {
+		fmt.Fprintf(h, "%v\x00", value)
+	}
+	d.checksum += h.Sum64()
+}
+
+// verify checks, before cutover, that every target shard holds as many rows
+// as the sources have in its key range and that a sample of them match
+func (r *Resharder) verify(ctx context.Context, job *models.ReshardJob, sources []*models.Shard) error {
+	targetShards := make([]*models.Shard, 0, len(job.TargetShards))
+	expected := make(map[string]*rangeDigest, len(job.TargetShards))
+	for _, targetID := range job.TargetShards {
+		targetShard, err := r.catalog.GetShardByID(targetID)
+		if err != nil {
+			return fmt.Errorf("failed to get target shard %s: %w", targetID, err)
+		}
+		targetShards = append(targetShards, targetShard)
+		expected[targetID] = &rangeDigest{}
+	}
+
+	// Bucket the sources' rows the way the copy placed them
+	for _, source := range sources {
+		place, err := r.placementFor(job, source, targetShards)
+		if err != nil {
+			return err
+		}
+		err = r.scanRows(ctx, source.PrimaryEndpoint, job.ShardKey, func(key string, row []interface{}) {
+			if targetID := place.target(key); targetID != "" {
+				expected[targetID].add(key, row)
+			}
+		})
+		if err != nil {
+			return fmt.Errorf("failed to read source shard %s: %w", source.ID, err)
+		}
+	}
+
+	for _, target := range targetShards {
+		actual := &rangeDigest{}
+		if err := r.scanRows(ctx, target.PrimaryEndpoint, job.ShardKey, actual.add); err != nil {
+			return fmt.Errorf("failed to read target shard %s: %w", target.ID, err)
+		}
+		want := expected[target.ID]
+		if actual.rows != want.rows {
+			return fmt.Errorf("target shard %s has %d rows, expected %d", target.ID, actual.rows, want.rows)
+		}
+		if actual.checksum != want.checksum {
+			return fmt.Errorf("target shard %s sampled checksum does not match its sources", target.ID)
+		}
+	}
+
+	job.Progress = 0.85 // Verification brings us to 85%
+
+	return nil
+}
+
+// scanRows calls fn with the keyColumn value, or the default shard key, and
+// values of every row of the data table at endpoint. A database without the
+// table has no rows, as for copies; any other failure to read it is returned
+// so verification never passes on a shard it could not read.
+func (r *Resharder) scanRows(ctx context.Context, endpoint, keyColumn string, fn func(key string, row []interface{})) error {
+	db, err := sql.Open(r.driver, endpoint)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	rows, err := db.QueryContext(ctx, "SELECT * FROM data")
+	if err != nil {
+		if !isMissingTable(err) {
+			return fmt.Errorf("failed to read data table: %w", err)
+		}
+		r.logger.Warn("no data table found, verifying as empty", zap.Error(err))
+		return nil
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	keyIndex, found := routingColumn(columns, keyColumn)
+	if !found && keyColumn != "" {
+		return fmt.Errorf("data table has no %s column", keyColumn)
+	}
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		valuePtrs := make([]interface{}, len(columns))
+		for i := range values {
+			valuePtrs[i] = &values[i]
+		}
+		if err := rows.Scan(valuePtrs...); err != nil {
+			return fmt.Errorf("failed to scan row: %w", err)
+		}
+		if keyIndex < len(values) {
+			fn(keyString(values[keyIndex]), values)
+		}
+	}
+	return rows.Err()
+}
+
+// isMissingTable reports whether a query failed because the data table does
+// not exist, as PostgreSQL and MySQL word it. A missing database or role is
+// not a missing table.
+func isMissingTable(err error) bool {
+	message := err.Error()
+	return strings.Contains(message, `relation "data" does not exist`) ||
+		(strings.Contains(message, "Table '") && strings.Contains(message, "doesn't exist"))
+}
+
+// restoreSources puts source shards made read-only for the delta sync back in
+// service after a job fails before cutover, so no writes or data are lost
+func (r *Resharder) restoreSources(job *models.ReshardJob, sources []*models.Shard) {
+	for _, source := range sources {
+		source.Status = "active"
+		if err := r.catalog.UpdateShard(source); err != nil {
+			r.logger.Error("failed to restore source shard after failed verification",
+				zap.String("job_id", job.ID),
+				zap.String("shard_id", source.ID),
+				zap.Error(err))
+		}
+	}
+}
+
 // cutover switches routing to new shards
 func (r *Resharder) cutover(ctx context.Context, job *models.ReshardJob, sourceShard *models.Shard) error {
 	// Update source shard status
@@ -364,7 +660,7 @@ func (r *Resharder) validate(ctx context.Context, job *models.ReshardJob, source
 
 		// Validate each shard connection and close immediately
 		func() {
-			targetDB, err := sql.Open("postgres", targetShard.PrimaryEndpoint)
+			targetDB, err := sql.Open(r.driver, targetShard.PrimaryEndpoint)
 			if err != nil {
 				r.logger.Error("failed to open target shard connection", zap.String("shard_id", targetID), zap.Error(err))
 				return
@@ -382,6 +678,196 @@ func (r *Resharder) validate(ctx context.Context, job *models.ReshardJob, source
 	return nil
 }
 
+// placement places the rows of one source on the target shards of a job the
+// way the catalog will route their keys once the targets take over
+type placement struct {
+	targets []*models.Shard
+	ring    *hashing.ConsistentHash // Targets' ring; nil when they are range shards
+	owns    func(key string) bool   // Keys the source holds; nil holds every key
+}
+
+// newPlacement places keys on range targets by their key ranges and on hash
+// targets by a ring on their hash function, which the manager keeps the same
+// for every shard of an app
+func newPlacement(targetShards []*models.Shard) *placement {
+	p := &placement{targets: targetShards}
+	for _, shard := range targetShards {
+		if shard.Strategy != "range" {
+			p.ring = appRing(targetShards)
+			break
+		}
+	}
+	return p
+}
+
+// target returns the ID of the target shard a key is placed on, or "" when
+// the source does not hold the key or no target's key range has it
+func (p *placement) target(key string) string {
+	if p.owns != nil && !p.owns(key) {
+		return ""
+	}
+	if p.ring != nil {
+		return p.ring.GetShard(key)
+	}
+	for _, shard := range p.targets {
+		if keyrange.Contains(shard.Collation, shard.KeyRangeStart, shard.KeyRangeEnd, key) {
+			return shard.ID
+		}
+	}
+	return ""
+}
+
+// placementFor returns how a source's rows are placed on a job's targets.
+// Only the keys in the source's key range are placed: rows a source still
+// holds for keys routed elsewhere, such as leftovers of an earlier move,
+// would otherwise be copied over the rows of the shard that owns them. A
+// rekey moves every row of the app by a new key, so it takes them all.
+func (r *Resharder) placementFor(job *models.ReshardJob, source *models.Shard, targetShards []*models.Shard) (*placement, error) {
+	p := newPlacement(targetShards)
+	if job.Type == "rekey" {
+		return p, nil
+	}
+	if source.Strategy == "range" {
+		p.owns = func(key string) bool {
+			return keyrange.Contains(source.Collation, source.KeyRangeStart, source.KeyRangeEnd, key)
+		}
+		return p, nil
+	}
+	if source.ClientAppID == "" {
+		return p, nil
+	}
+
+	// A hash source holds the keys the app's ring places on it, without the
+	// targets it is being resharded to
+	shards, err := r.catalog.ListShards(source.ClientAppID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list shards of %s: %w", source.ClientAppID, err)
+	}
+	targetIDs := make(map[string]bool, len(targetShards))
+	for _, target := range targetShards {
+		targetIDs[target.ID] = true
+	}
+	current := make([]*models.Shard, 0, len(shards))
+	for i := range shards {
+		if shards[i].Strategy != "range" && !targetIDs[shards[i].ID] {
+			current = append(current, &shards[i])
+		}
+	}
+	ring := appRing(current)
+	p.owns = func(key string) bool {
+		return ring.GetShard(key) == source.ID
+	}
+	return p, nil
+}
+
+// appRing builds the hash ring of some shards of one app, as the catalog does
+func appRing(shards []*models.Shard) *hashing.ConsistentHash {
+	hashFunction := ""
+	if len(shards) > 0 {
+		hashFunction = shards[0].HashFunction
+	}
+	consistentHash := hashing.NewConsistentHash(hashing.NewHashFunction(hashFunction))
+	for _, shard := range shards {
+		vnodeCount := len(shard.VNodes)
+		if vnodeCount == 0 {
+			vnodeCount = 256 // default
+		}
+		consistentHash.AddShard(shard.ID, vnodeCount)
+	}
+	return consistentHash
+}
+
+// routingColumn returns the index of keyColumn, or of the default shard key
+// column when keyColumn is empty
+func routingColumn(columns []string, keyColumn string) (int, bool) {
+	if keyColumn == "" {
+		return shardKeyColumn(columns)
+	}
+	for i, col := range columns {
+		if col == keyColumn {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// shardKeyColumn returns the index of the column rows are routed by: the
+// column named shard_key, id or key, or else the first column
+func shardKeyColumn(columns []string) (int, bool) {
+	for i, col := range columns {
+		if col == "shard_key" || col == "id" || col == "key" {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// keyString converts a shard key value to the string it is routed by
+func keyString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+// copyMetrics records the throughput of a resharding job's copy from one
+// source shard. The zero value, used outside of jobs, records nothing.
+type copyMetrics struct {
+	jobID       string
+	sourceShard string
+}
+
+// rowCopied counts a row written to a target shard
+func (m copyMetrics) rowCopied(targetShard string, row []interface{}) {
+	if m.jobID == "" {
+		return
+	}
+	observability.ReshardRowsCopied.WithLabelValues(m.jobID, m.sourceShard, targetShard).Inc()
+	observability.ReshardBytesCopied.WithLabelValues(m.jobID, m.sourceShard, targetShard).Add(float64(rowSize(row)))
+}
+
+// copyError counts rows that could not be written to a target shard
+func (m copyMetrics) copyError(targetShard string, rows int) {
+	if m.jobID == "" {
+		return
+	}
+	observability.ReshardCopyErrors.WithLabelValues(m.jobID, m.sourceShard, targetShard).Add(float64(rows))
+}
+
+// rate records the copy's throughput so far
+func (m copyMetrics) rate(copied int64, elapsed time.Duration) {
+	if m.jobID == "" || elapsed <= 0 {
+		return
+	}
+	observability.ReshardCopyRate.WithLabelValues(m.jobID, m.sourceShard).Set(float64(copied) / elapsed.Seconds())
+}
+
+// recordPhase records how long a completed phase of a job took
+func recordPhase(job *models.ReshardJob, phase string, sourceShard string, start time.Time) {
+	observability.ReshardPhaseDuration.WithLabelValues(job.ID, phase, sourceShard).Set(time.Since(start).Seconds())
+}
+
+// rowSize approximates the bytes a row carries, as its values' text length
+func rowSize(row []interface{}) int {
+	size := 0
+	for _, value := range row {
+		switch v := value.(type) {
+		case nil:
+		case string:
+			size += len(v)
+		case []byte:
+			size += len(v)
+		default:
+			size += len(fmt.Sprint(v))
+		}
+	}
+	return size
+}
+
 // joinColumns joins column names for SQL
 func joinColumns(columns []string) string {
 	result := ""
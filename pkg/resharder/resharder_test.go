@@ -0,0 +1,561 @@
+package resharder
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sharding-system/pkg/hashing"
+	"github.com/sharding-system/pkg/models"
+	"github.com/sharding-system/pkg/observability"
+	"go.uber.org/zap/zaptest"
+)
+
+// fakeTable is the data table of one database behind the fake driver
+type fakeTable struct {
+	mu          sync.Mutex
+	columns     []string // Defaults to id and value
+	rows        [][]driver.Value
+	failInserts int   // Number of inserts still to fail
+	lossy       bool  // Acknowledge but drop rows whose key ends in an odd digit
+	queryErr    error // Returned for every query when set
+}
+
+// fakeDriver serves "SELECT * FROM data" and INSERTs into data from
+// in-memory tables, keyed by DSN
+type fakeDriver struct {
+	mu     sync.Mutex
+	tables map[string]*fakeTable
+}
+
+var testDriver = &fakeDriver{tables: make(map[string]*fakeTable)}
+
+func init() {
+	sql.Register("reshardertest", testDriver)
+}
+
+func (d *fakeDriver) table(dsn string) *fakeTable {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	table, ok := d.tables[dsn]
+	if !ok {
+		table = &fakeTable{}
+		d.tables[dsn] = table
+	}
+	return table
+}
+
+func (d *fakeDriver) Open(dsn string) (driver.Conn, error) {
+	return &fakeConn{table: d.table(dsn)}, nil
+}
+
+type fakeConn struct {
+	table *fakeTable
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	if !strings.HasPrefix(query, "INSERT INTO data") {
+		return nil, fmt.Errorf("unsupported statement: %s", query)
+	}
+	return &fakeInsert{table: c.table}, nil
+}
+
+func (c *fakeConn) Close() error { return nil }
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("transactions not supported")
+}
+
+func (c *fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if query != "SELECT * FROM data" && query != "SELECT COUNT(*) FROM data" {
+		return nil, fmt.Errorf("unsupported query: %s", query)
+	}
+	c.table.mu.Lock()
+	defer c.table.mu.Unlock()
+	if c.table.queryErr != nil {
+		return nil, c.table.queryErr
+	}
+	if query == "SELECT COUNT(*) FROM data" {
+		return &fakeRows{columns: []string{"count"}, rows: [][]driver.Value{{int64(len(c.table.rows))}}}, nil
+	}
+	columns := c.table.columns
+	if columns == nil {
+		columns = []string{"id", "value"}
+	}
+	return &fakeRows{columns: columns, rows: append([][]driver.Value{}, c.table.rows...)}, nil
+}
+
+type fakeInsert struct {
+	table *fakeTable
+}
+
+func (s *fakeInsert) Close() error  { return nil }
+func (s *fakeInsert) NumInput() int { return -1 }
+
+func (s *fakeInsert) Exec(args []driver.Value) (driver.Result, error) {
+	s.table.mu.Lock()
+	defer s.table.mu.Unlock()
+	if s.table.failInserts > 0 {
+		s.table.failInserts--
+		return nil, errors.New("disk full")
+	}
+	if key := fmt.Sprint(args[0]); s.table.lossy && strings.ContainsAny(key[len(key)-1:], "13579") {
+		return driver.RowsAffected(1), nil
+	}
+	// Rows already copied are skipped, as with ON CONFLICT DO NOTHING
+	for _, row := range s.table.rows {
+		if row[0] == args[0] {
+			return driver.RowsAffected(0), nil
+		}
+	}
+	s.table.rows = append(s.table.rows, args)
+	return driver.RowsAffected(1), nil
+}
+
+func (s *fakeInsert) Query(args []driver.Value) (driver.Rows, error) {
+	return nil, errors.New("query not supported")
+}
+
+type fakeRows struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+// mockCatalog holds shards in memory
+type mockCatalog struct {
+	mu     sync.Mutex
+	shards map[string]*models.Shard
+}
+
+func newMockCatalog(shards ...*models.Shard) *mockCatalog {
+	c := &mockCatalog{shards: make(map[string]*models.Shard)}
+	for _, shard := range shards {
+		c.shards[shard.ID] = shard
+	}
+	return c
+}
+
+func (c *mockCatalog) GetShard(key string, clientAppID string) (*models.Shard, error) {
+	return nil, errors.New("not supported")
+}
+
+func (c *mockCatalog) GetShardByID(shardID string) (*models.Shard, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	shard, ok := c.shards[shardID]
+	if !ok {
+		return nil, fmt.Errorf("shard %s not found", shardID)
+	}
+	return shard, nil
+}
+
+func (c *mockCatalog) ListShards(clientAppID string) ([]models.Shard, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	shards := make([]models.Shard, 0, len(c.shards))
+	for _, shard := range c.shards {
+		shards = append(shards, *shard)
+	}
+	return shards, nil
+}
+
+func (c *mockCatalog) CreateShard(shard *models.Shard) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.shards[shard.ID] = shard
+	return nil
+}
+
+func (c *mockCatalog) UpdateShard(shard *models.Shard) error {
+	return c.CreateShard(shard)
+}
+
+func (c *mockCatalog) DeleteShard(shardID string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.shards, shardID)
+	return nil
+}
+
+func (c *mockCatalog) GetCatalogVersion() (int64, error) { return 1, nil }
+
+func (c *mockCatalog) Watch(ctx context.Context) (<-chan *models.ShardCatalog, error) {
+	return make(chan *models.ShardCatalog), nil
+}
+
+func (c *mockCatalog) Reload() error { return nil }
+
+func TestResharder_SplitRecordsCopyMetrics(t *testing.T) {
+	source := testDriver.table("metrics-source")
+	for i := 0; i < 50; i++ {
+		source.rows = append(source.rows, []driver.Value{int64(i), fmt.Sprintf("value-%02d", i)})
+	}
+	healthy := testDriver.table("metrics-healthy")
+	flaky := testDriver.table("metrics-flaky")
+	flaky.failInserts = 3
+
+	catalog := newMockCatalog(
+		&models.Shard{ID: "src", PrimaryEndpoint: "metrics-source", Status: "active"},
+		&models.Shard{ID: "healthy", PrimaryEndpoint: "metrics-healthy", Status: "migrating"},
+		&models.Shard{ID: "flaky", PrimaryEndpoint: "metrics-flaky", Status: "migrating"},
+	)
+	r := NewResharder(catalog, zaptest.NewLogger(t))
+	r.driver = "reshardertest"
+
+	job := &models.ReshardJob{ID: "job-metrics", Type: "split", SourceShards: []string{"src"}, TargetShards: []string{"healthy", "flaky"}}
+	if err := r.Split(context.Background(), job); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Pre-copy and delta sync each copy every row once; the delta sync
+	// retries the rows the flaky target failed
+	copied := testutil.ToFloat64(observability.ReshardRowsCopied.WithLabelValues("job-metrics", "src", "healthy"))
+	flakyCopied := testutil.ToFloat64(observability.ReshardRowsCopied.WithLabelValues("job-metrics", "src", "flaky"))
+	failed := testutil.ToFloat64(observability.ReshardCopyErrors.WithLabelValues("job-metrics", "src", "flaky"))
+	if copied == 0 || flakyCopied == 0 {
+		t.Fatalf("expected rows routed to both targets, got %v and %v", copied, flakyCopied)
+	}
+	if int(copied) != 2*len(healthy.rows) {
+		t.Errorf("expected %d rows copied, got %v", 2*len(healthy.rows), copied)
+	}
+	if failed != 3 {
+		t.Errorf("expected 3 copy errors, got %v", failed)
+	}
+	if copied+flakyCopied+failed != 100 {
+		t.Errorf("expected every row copied or failed twice, got %v copied and %v failed", copied+flakyCopied, failed)
+	}
+	if len(healthy.rows)+len(flaky.rows) != 50 {
+		t.Errorf("expected the targets to hold all 50 rows, got %d", len(healthy.rows)+len(flaky.rows))
+	}
+
+	// Each row is an int64 and an 8-byte string
+	bytes := testutil.ToFloat64(observability.ReshardBytesCopied.WithLabelValues("job-metrics", "src", "healthy"))
+	if bytes < copied*9 {
+		t.Errorf("expected at least %v bytes copied, got %v", copied*9, bytes)
+	}
+	if rate := testutil.ToFloat64(observability.ReshardCopyRate.WithLabelValues("job-metrics", "src")); rate <= 0 {
+		t.Errorf("expected a copy rate, got %v", rate)
+	}
+
+	// Delta sync waits for in-flight transactions, so it takes at least a second
+	for _, phase := range []string{"precopy", "deltasync", "verification", "cutover", "validation"} {
+		duration := testutil.ToFloat64(observability.ReshardPhaseDuration.WithLabelValues("job-metrics", phase, "src"))
+		if phase == "deltasync" && duration < 1 {
+			t.Errorf("expected delta sync to take at least a second, got %v", duration)
+		}
+		if duration <= 0 {
+			t.Errorf("expected a duration for %s, got %v", phase, duration)
+		}
+	}
+}
+
+func TestResharder_CopyShardDataRecordsNoJobMetrics(t *testing.T) {
+	source := testDriver.table("move-source")
+	source.rows = append(source.rows, []driver.Value{int64(1), "a"})
+
+	r := NewResharder(newMockCatalog(), zaptest.NewLogger(t))
+	r.driver = "reshardertest"
+
+	before := testutil.CollectAndCount(observability.ReshardRowsCopied)
+	copied, err := r.CopyShardData(context.Background(), &models.Shard{ID: "moved", PrimaryEndpoint: "move-source"}, "move-target")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if copied != 1 || len(testDriver.table("move-target").rows) != 1 {
+		t.Errorf("expected one row copied, got %d", copied)
+	}
+	if count, err := r.CountShardRows(context.Background(), "move-target"); err != nil || count != 1 {
+		t.Errorf("expected the copied row counted on the target, got %d, %v", count, err)
+	}
+	if after := testutil.CollectAndCount(observability.ReshardRowsCopied); after != before {
+		t.Errorf("expected moves outside of jobs to record no job metrics, got %d series, want %d", after, before)
+	}
+}
+
+func TestResharder_CopyShardDataFailsWhenSourceIsUnreadable(t *testing.T) {
+	testDriver.table("move-down").queryErr = errors.New("read tcp 10.0.0.7:5432: connection reset by peer")
+	testDriver.table("move-missing").queryErr = errors.New(`pq: relation "data" does not exist`)
+
+	r := NewResharder(newMockCatalog(), zaptest.NewLogger(t))
+	r.driver = "reshardertest"
+
+	if _, err := r.CopyShardData(context.Background(), &models.Shard{ID: "moved", PrimaryEndpoint: "move-down"}, "move-down-target"); err == nil {
+		t.Error("expected a source that cannot be read to fail the copy")
+	}
+	if _, err := r.CountShardRows(context.Background(), "move-down"); err == nil {
+		t.Error("expected a source that cannot be read to fail the count")
+	}
+
+	// A source without the data table has nothing to copy
+	copied, err := r.CopyShardData(context.Background(), &models.Shard{ID: "moved", PrimaryEndpoint: "move-missing"}, "move-missing-target")
+	if err != nil || copied != 0 {
+		t.Errorf("expected nothing copied from a source without data, got %d, %v", copied, err)
+	}
+	if count, err := r.CountShardRows(context.Background(), "move-missing"); err != nil || count != 0 {
+		t.Errorf("expected no rows counted without a data table, got %d, %v", count, err)
+	}
+}
+
+func TestResharder_SplitFailsVerificationOnIncompleteCopy(t *testing.T) {
+	source := testDriver.table("verify-source")
+	for i := 0; i < 40; i++ {
+		source.rows = append(source.rows, []driver.Value{int64(i), fmt.Sprintf("value-%02d", i)})
+	}
+	testDriver.table("verify-lossy").lossy = true
+
+	sourceShard := &models.Shard{ID: "verify-src", PrimaryEndpoint: "verify-source", Status: "active"}
+	catalog := newMockCatalog(
+		sourceShard,
+		&models.Shard{ID: "verify-ok", PrimaryEndpoint: "verify-ok", Status: "migrating"},
+		&models.Shard{ID: "verify-lossy", PrimaryEndpoint: "verify-lossy", Status: "migrating"},
+	)
+	r := NewResharder(catalog, zaptest.NewLogger(t))
+	r.driver = "reshardertest"
+
+	job := &models.ReshardJob{ID: "job-verify", Type: "split", SourceShards: []string{"verify-src"}, TargetShards: []string{"verify-ok", "verify-lossy"}}
+	err := r.Split(context.Background(), job)
+	if err == nil || !strings.Contains(err.Error(), "verification failed") || !strings.Contains(err.Error(), "verify-lossy") {
+		t.Fatalf("expected verification of verify-lossy to fail, got %v", err)
+	}
+
+	// The source keeps serving its data and the targets are never cut over
+	if sourceShard.Status != "active" {
+		t.Errorf("expected the source to be restored to active, got %s", sourceShard.Status)
+	}
+	if len(source.rows) != 40 {
+		t.Errorf("expected the source to keep its 40 rows, got %d", len(source.rows))
+	}
+	for _, id := range job.TargetShards {
+		if target, _ := catalog.GetShardByID(id); target.Status != "migrating" {
+			t.Errorf("expected %s to stay migrating, got %s", id, target.Status)
+		}
+	}
+	if job.Progress >= 0.9 {
+		t.Errorf("expected the job to stop before cutover, got progress %v", job.Progress)
+	}
+}
+
+func TestResharder_ScanRowsReportsUnreadableTables(t *testing.T) {
+	r := NewResharder(newMockCatalog(), zaptest.NewLogger(t))
+	r.driver = "reshardertest"
+	count := func(key string, row []interface{}) {}
+
+	testDriver.table("scan-missing").queryErr = errors.New(`pq: relation "data" does not exist`)
+	if err := r.scanRows(context.Background(), "scan-missing", "", count); err != nil {
+		t.Errorf("expected a database without the data table to read as empty, got %v", err)
+	}
+
+	testDriver.table("scan-down").queryErr = errors.New("read tcp 10.0.0.7:5432: connection reset by peer")
+	if err := r.scanRows(context.Background(), "scan-down", "", count); err == nil {
+		t.Error("expected a failed read to be reported rather than read as empty")
+	}
+}
+
+func TestResharder_MergeFailsVerificationOnIncompleteCopy(t *testing.T) {
+	for _, dsn := range []string{"merge-a", "merge-b"} {
+		table := testDriver.table(dsn)
+		for i := 0; i < 10; i++ {
+			table.rows = append(table.rows, []driver.Value{dsn + fmt.Sprint(i), "value"})
+		}
+	}
+	testDriver.table("merge-target").lossy = true
+
+	sources := []*models.Shard{
+		{ID: "merge-a", PrimaryEndpoint: "merge-a", Status: "active"},
+		{ID: "merge-b", PrimaryEndpoint: "merge-b", Status: "active"},
+	}
+	catalog := newMockCatalog(sources[0], sources[1], &models.Shard{ID: "merged", PrimaryEndpoint: "merge-target", Status: "migrating"})
+	r := NewResharder(catalog, zaptest.NewLogger(t))
+	r.driver = "reshardertest"
+
+	job := &models.ReshardJob{ID: "job-merge-verify", Type: "merge", SourceShards: []string{"merge-a", "merge-b"}, TargetShards: []string{"merged"}}
+	if err := r.Merge(context.Background(), job); err == nil || !strings.Contains(err.Error(), "verification failed") {
+		t.Fatalf("expected verification to fail, got %v", err)
+	}
+	for _, source := range sources {
+		if source.Status != "active" {
+			t.Errorf("expected %s to be restored to active, got %s", source.ID, source.Status)
+		}
+	}
+}
+
+func TestResharder_RekeyPlacesRowsByNewKey(t *testing.T) {
+	columns := []string{"id", "value", "tenant_id"}
+	sources := []*models.Shard{
+		{ID: "rekey-a", PrimaryEndpoint: "rekey-a", Status: "active", ShardKey: "id"},
+		{ID: "rekey-b", PrimaryEndpoint: "rekey-b", Status: "active", ShardKey: "id"},
+	}
+	for i, source := range sources {
+		table := testDriver.table(source.PrimaryEndpoint)
+		table.columns = columns
+		for j := 0; j < 15; j++ {
+			id := int64(i*100 + j)
+			table.rows = append(table.rows, []driver.Value{id, fmt.Sprintf("value-%d", id), fmt.Sprintf("tenant-%d", j%5)})
+		}
+	}
+	targets := []*models.Shard{
+		{ID: "rekey-t1", PrimaryEndpoint: "rekey-t1", Status: "migrating", ShardKey: "tenant_id"},
+		{ID: "rekey-t2", PrimaryEndpoint: "rekey-t2", Status: "migrating", ShardKey: "tenant_id"},
+		{ID: "rekey-t3", PrimaryEndpoint: "rekey-t3", Status: "migrating", ShardKey: "tenant_id"},
+	}
+	for _, target := range targets {
+		testDriver.table(target.PrimaryEndpoint).columns = columns
+	}
+	catalog := newMockCatalog(sources[0], sources[1], targets[0], targets[1], targets[2])
+	r := NewResharder(catalog, zaptest.NewLogger(t))
+	r.driver = "reshardertest"
+
+	job := &models.ReshardJob{ID: "job-rekey", Type: "rekey", ShardKey: "tenant_id",
+		SourceShards: []string{"rekey-a", "rekey-b"}, TargetShards: []string{"rekey-t1", "rekey-t2", "rekey-t3"}}
+	if err := r.Rekey(context.Background(), job); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Every row lands on the shard its tenant hashes to, so each tenant's
+	// rows end up together whichever source held them
+	place := newPlacement(targets)
+	tenantShard := make(map[string]string)
+	total := 0
+	for _, target := range targets {
+		for _, row := range testDriver.table(target.PrimaryEndpoint).rows {
+			tenant := fmt.Sprint(row[2])
+			if want := place.target(tenant); want != target.ID {
+				t.Errorf("row %v of %s is on %s, expected %s", row[0], tenant, target.ID, want)
+			}
+			if other, ok := tenantShard[tenant]; ok && other != target.ID {
+				t.Errorf("rows of %s are split between %s and %s", tenant, other, target.ID)
+			}
+			tenantShard[tenant] = target.ID
+			total++
+		}
+	}
+	if total != 30 || len(tenantShard) != 5 {
+		t.Errorf("expected the 30 rows of 5 tenants on the targets, got %d rows of %d tenants", total, len(tenantShard))
+	}
+
+	// The new layout takes over; the sources keep their data and stay
+	// read-only for the manager to retire
+	for _, target := range targets {
+		if target.Status != "active" {
+			t.Errorf("expected %s to be active after cutover, got %s", target.ID, target.Status)
+		}
+	}
+	for _, source := range sources {
+		if source.Status != models.ShardStatusReadOnly {
+			t.Errorf("expected %s to be left read-only, got %s", source.ID, source.Status)
+		}
+		if n := len(testDriver.table(source.PrimaryEndpoint).rows); n != 15 {
+			t.Errorf("expected %s to keep its 15 rows, got %d", source.ID, n)
+		}
+	}
+	if job.Progress != 1.0 || job.KeysMigrated == 0 {
+		t.Errorf("expected the job to track its progress to completion, got %v with %d keys", job.Progress, job.KeysMigrated)
+	}
+}
+
+func TestResharder_RekeyRequiresNewKeyColumn(t *testing.T) {
+	source := &models.Shard{ID: "rekey-nokey", PrimaryEndpoint: "rekey-nokey", Status: "active"}
+	testDriver.table("rekey-nokey").rows = [][]driver.Value{{int64(1), "a"}}
+	catalog := newMockCatalog(source, &models.Shard{ID: "rekey-nokey-t", PrimaryEndpoint: "rekey-nokey-t", Status: "migrating"})
+	r := NewResharder(catalog, zaptest.NewLogger(t))
+	r.driver = "reshardertest"
+
+	job := &models.ReshardJob{ID: "job-rekey-nokey", Type: "rekey", ShardKey: "tenant_id",
+		SourceShards: []string{"rekey-nokey"}, TargetShards: []string{"rekey-nokey-t"}}
+	if err := r.Rekey(context.Background(), job); err == nil || !strings.Contains(err.Error(), "tenant_id") {
+		t.Fatalf("expected rows without the new key column to fail the rekey, got %v", err)
+	}
+	if source.Status != "active" || len(testDriver.table("rekey-nokey-t").rows) != 0 {
+		t.Errorf("expected the source to keep serving and nothing copied, got %s and %d rows", source.Status, len(testDriver.table("rekey-nokey-t").rows))
+	}
+}
+
+func TestResharder_SplitPlacesRowsOnTheAppsHashFunction(t *testing.T) {
+	source := testDriver.table("hashfn-source")
+	for i := 0; i < 40; i++ {
+		source.rows = append(source.rows, []driver.Value{fmt.Sprintf("key-%02d", i), "value"})
+	}
+	targets := []*models.Shard{
+		{ID: "hashfn-t1", ClientAppID: "hashfn", PrimaryEndpoint: "hashfn-t1", Status: "migrating", HashFunction: "xxhash"},
+		{ID: "hashfn-t2", ClientAppID: "hashfn", PrimaryEndpoint: "hashfn-t2", Status: "migrating", HashFunction: "xxhash"},
+	}
+	catalog := newMockCatalog(&models.Shard{ID: "hashfn-src", ClientAppID: "hashfn", PrimaryEndpoint: "hashfn-source", Status: "active", HashFunction: "xxhash"}, targets[0], targets[1])
+	r := NewResharder(catalog, zaptest.NewLogger(t))
+	r.driver = "reshardertest"
+
+	job := &models.ReshardJob{ID: "job-hashfn", Type: "split", SourceShards: []string{"hashfn-src"}, TargetShards: []string{"hashfn-t1", "hashfn-t2"}}
+	if err := r.Split(context.Background(), job); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Rows land where the router's xxhash ring of the targets sends their keys
+	ring := hashing.NewConsistentHash(hashing.NewHashFunction("xxhash"))
+	ring.AddShard("hashfn-t1", 256)
+	ring.AddShard("hashfn-t2", 256)
+	total := 0
+	for _, target := range targets {
+		for _, row := range testDriver.table(target.PrimaryEndpoint).rows {
+			if want := ring.GetShard(fmt.Sprint(row[0])); want != target.ID {
+				t.Errorf("row %v is on %s, expected %s", row[0], target.ID, want)
+			}
+			total++
+		}
+	}
+	if total != 40 {
+		t.Errorf("expected the 40 rows on the targets, got %d", total)
+	}
+}
+
+func TestResharder_RangeSplitCopiesOnlyTheSourcesRange(t *testing.T) {
+	source := testDriver.table("range-source")
+	for _, key := range []string{"apple", "kiwi", "mango", "zebra"} {
+		source.rows = append(source.rows, []driver.Value{key, "value"})
+	}
+	// zebra is a leftover of a key the source no longer owns
+	sourceShard := &models.Shard{ID: "range-src", ClientAppID: "fruit", PrimaryEndpoint: "range-source", Status: "active",
+		Strategy: "range", KeyRangeStart: "a", KeyRangeEnd: "t"}
+	low := &models.Shard{ID: "range-low", ClientAppID: "fruit", PrimaryEndpoint: "range-low", Status: "migrating",
+		Strategy: "range", KeyRangeStart: "a", KeyRangeEnd: "m"}
+	high := &models.Shard{ID: "range-high", ClientAppID: "fruit", PrimaryEndpoint: "range-high", Status: "migrating",
+		Strategy: "range", KeyRangeStart: "m", KeyRangeEnd: "t"}
+	r := NewResharder(newMockCatalog(sourceShard, low, high), zaptest.NewLogger(t))
+	r.driver = "reshardertest"
+
+	job := &models.ReshardJob{ID: "job-range", Type: "split", SourceShards: []string{"range-src"}, TargetShards: []string{"range-low", "range-high"}}
+	if err := r.Split(context.Background(), job); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	keys := func(dsn string) string {
+		var keys []string
+		for _, row := range testDriver.table(dsn).rows {
+			keys = append(keys, fmt.Sprint(row[0]))
+		}
+		return strings.Join(keys, ",")
+	}
+	if got := keys("range-low"); got != "apple,kiwi" {
+		t.Errorf("expected apple and kiwi on the low half, got %s", got)
+	}
+	if got := keys("range-high"); got != "mango" {
+		t.Errorf("expected only mango on the high half, got %s", got)
+	}
+}
@@ -0,0 +1,195 @@
+package router
+
+import (
+	"database/sql/driver"
+	"errors"
+	"io"
+	"math"
+	"math/rand"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sharding-system/pkg/config"
+)
+
+// errorDecay is how much each query result moves a replica's error rate, so
+// the rate reflects roughly the last few dozen queries
+const errorDecay = 0.1
+
+// errorRateHalfLife is how long a replica's error rate takes to halve without
+// queries. Excluded replicas get no queries, so this is what lets them back
+// into rotation to be probed again.
+const errorRateHalfLife = 10 * time.Second
+
+// connectionErrors are the messages of driver errors that mean the replica
+// could not be reached, for drivers that don't wrap a net.Error
+var connectionErrors = []string{
+	"connection refused",
+	"connection reset",
+	"broken pipe",
+	"no route to host",
+	"no such host",
+	"i/o timeout",
+	"bad connection",
+}
+
+// minReplicaWeight keeps struggling replicas in rotation at a trickle, so they
+// are seen to recover
+const minReplicaWeight = 0.05
+
+// ReplicaLoad is the load signal of a replica at selection time
+type ReplicaLoad struct {
+	Saturation float64       // Share of the connection pool in use, 0 to 1
+	Lag        time.Duration // Last measured replication lag
+	LagKnown   bool
+}
+
+// ReplicaBalancer spreads reads across replicas in proportion to their
+// health. Replicas that error, saturate their pool or lag get a smaller share,
+// and those past the configured error rate or lag get none.
+type ReplicaBalancer struct {
+	cfg        config.ReplicaBalancingConfig
+	errorRates map[string]errorRate
+	mu         sync.Mutex
+	rand       *rand.Rand
+	now        func() time.Time
+}
+
+// errorRate is a replica's error rate as of its last query
+type errorRate struct {
+	rate float64
+	at   time.Time
+}
+
+// NewReplicaBalancer creates a replica balancer with the given weights
+func NewReplicaBalancer(cfg config.ReplicaBalancingConfig) *ReplicaBalancer {
+	return &ReplicaBalancer{
+		cfg:        cfg,
+		errorRates: make(map[string]errorRate),
+		rand:       rand.New(rand.NewSource(time.Now().UnixNano())),
+		now:        time.Now,
+	}
+}
+
+// RecordResult updates a replica's error rate with the outcome of a query.
+// Only connection errors count against the replica: a query that fails on a
+// syntax error or a constraint was still served.
+func (b *ReplicaBalancer) RecordResult(endpoint string, err error) {
+	sample := 0.0
+	if isConnectionError(err) {
+		sample = 1
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := b.now()
+	rate := b.decayedRate(endpoint, now)
+	b.errorRates[endpoint] = errorRate{rate: rate*(1-errorDecay) + sample*errorDecay, at: now}
+}
+
+// ErrorRate returns a replica's recent error rate
+func (b *ReplicaBalancer) ErrorRate(endpoint string) float64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.decayedRate(endpoint, b.now())
+}
+
+// decayedRate returns a replica's error rate, halved for every
+// errorRateHalfLife since its last query. Must be called with b.mu held.
+func (b *ReplicaBalancer) decayedRate(endpoint string, now time.Time) float64 {
+	last, ok := b.errorRates[endpoint]
+	if !ok {
+		return 0
+	}
+	idle := now.Sub(last.at)
+	if idle <= 0 {
+		return last.rate
+	}
+	return last.rate * math.Pow(0.5, float64(idle)/float64(errorRateHalfLife))
+}
+
+// isConnectionError reports whether a query failed because its replica could
+// not be reached, rather than because of the query
+func isConnectionError(err error) bool {
+	if err == nil {
+		return false
+	}
+	var netErr net.Error
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.As(err, &netErr) {
+		return true
+	}
+	msg := strings.ToLower(err.Error())
+	for _, connErr := range connectionErrors {
+		if strings.Contains(msg, connErr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Weight returns a replica's share of reads relative to a healthy replica's 1,
+// or 0 when the replica is excluded
+func (b *ReplicaBalancer) Weight(endpoint string, load ReplicaLoad) float64 {
+	errorRate := b.ErrorRate(endpoint)
+	if b.cfg.MaxErrorRate > 0 && errorRate > b.cfg.MaxErrorRate {
+		return 0
+	}
+	if load.LagKnown && b.cfg.MaxLag > 0 && load.Lag > b.cfg.MaxLag {
+		return 0
+	}
+
+	weight := penalty(b.cfg.ErrorWeight, errorRate) * penalty(b.cfg.SaturationWeight, load.Saturation)
+	if load.LagKnown && b.cfg.MaxLag > 0 {
+		weight *= penalty(b.cfg.LagWeight, float64(load.Lag)/float64(b.cfg.MaxLag))
+	}
+	if weight < minReplicaWeight {
+		weight = minReplicaWeight
+	}
+	return weight
+}
+
+// Pick chooses a replica at random in proportion to its weight. It returns
+// false when every replica is excluded.
+func (b *ReplicaBalancer) Pick(replicas []string, load func(endpoint string) ReplicaLoad) (string, bool) {
+	weights := make([]float64, len(replicas))
+	total := 0.0
+	for i, replica := range replicas {
+		weights[i] = b.Weight(replica, load(replica))
+		total += weights[i]
+	}
+	if total == 0 {
+		return "", false
+	}
+
+	b.mu.Lock()
+	target := b.rand.Float64() * total
+	b.mu.Unlock()
+
+	for i, replica := range replicas {
+		target -= weights[i]
+		if target < 0 && weights[i] > 0 {
+			return replica, true
+		}
+	}
+	// Rounding left the target at the very end of the range
+	for i := len(replicas) - 1; i >= 0; i-- {
+		if weights[i] > 0 {
+			return replicas[i], true
+		}
+	}
+	return "", false
+}
+
+// penalty scales a weight down by value, a signal between 0 and 1
+func penalty(weight, value float64) float64 {
+	if value > 1 {
+		value = 1
+	}
+	factor := 1 - weight*value
+	if factor < 0 {
+		return 0
+	}
+	return factor
+}
@@ -0,0 +1,160 @@
+package router
+
+import (
+	"database/sql/driver"
+	"errors"
+	"math/rand"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/sharding-system/pkg/config"
+	"github.com/sharding-system/pkg/models"
+	"go.uber.org/zap/zaptest"
+)
+
+func newTestBalancer() *ReplicaBalancer {
+	b := NewReplicaBalancer(config.ReplicaBalancingConfig{
+		ErrorWeight:      1,
+		SaturationWeight: 1,
+		LagWeight:        1,
+		MaxErrorRate:     0.5,
+		MaxLag:           10 * time.Second,
+	})
+	b.rand = rand.New(rand.NewSource(1))
+	return b
+}
+
+// pickCounts returns how often each replica is picked over n reads
+func pickCounts(b *ReplicaBalancer, replicas []string, load map[string]ReplicaLoad, n int) map[string]int {
+	counts := make(map[string]int)
+	for i := 0; i < n; i++ {
+		replica, ok := b.Pick(replicas, func(endpoint string) ReplicaLoad { return load[endpoint] })
+		if !ok {
+			counts[""]++
+			continue
+		}
+		counts[replica]++
+	}
+	return counts
+}
+
+func TestReplicaBalancer_ShiftsTrafficFromOverloadedReplica(t *testing.T) {
+	b := newTestBalancer()
+	replicas := []string{"replica-a", "replica-b"}
+
+	even := pickCounts(b, replicas, nil, 2000)
+	if even["replica-a"] < 800 || even["replica-b"] < 800 {
+		t.Fatalf("expected healthy replicas to share reads evenly, got %v", even)
+	}
+
+	load := map[string]ReplicaLoad{"replica-a": {Saturation: 0.9}}
+	shifted := pickCounts(b, replicas, load, 2000)
+	if shifted["replica-a"] > 300 {
+		t.Errorf("expected traffic to shift away from the saturated replica, got %v", shifted)
+	}
+	if shifted["replica-a"] == 0 {
+		t.Error("expected the saturated replica to keep a trickle of reads")
+	}
+}
+
+func TestReplicaBalancer_DownWeightsErrorsAndLag(t *testing.T) {
+	b := newTestBalancer()
+	for i := 0; i < 5; i++ {
+		b.RecordResult("replica-a", errors.New("connection reset"))
+		b.RecordResult("replica-b", nil)
+	}
+	if rate := b.ErrorRate("replica-a"); rate < 0.3 || rate > 0.5 {
+		t.Fatalf("expected a recent error rate around 0.4, got %v", rate)
+	}
+
+	healthy := b.Weight("replica-b", ReplicaLoad{})
+	erroring := b.Weight("replica-a", ReplicaLoad{})
+	lagging := b.Weight("replica-b", ReplicaLoad{Lag: 5 * time.Second, LagKnown: true})
+	if healthy != 1 || erroring >= healthy || lagging >= healthy {
+		t.Errorf("expected errors and lag to reduce the weight, got healthy=%v erroring=%v lagging=%v", healthy, erroring, lagging)
+	}
+
+	// Recovering replicas regain their share
+	for i := 0; i < 50; i++ {
+		b.RecordResult("replica-a", nil)
+	}
+	if weight := b.Weight("replica-a", ReplicaLoad{}); weight < 0.95 {
+		t.Errorf("expected the recovered replica to be weighted fully again, got %v", weight)
+	}
+}
+
+func TestReplicaBalancer_ExcludesUnhealthyReplicas(t *testing.T) {
+	b := newTestBalancer()
+	for i := 0; i < 10; i++ {
+		b.RecordResult("replica-a", errors.New("connection refused"))
+	}
+	load := map[string]ReplicaLoad{"replica-b": {Lag: time.Minute, LagKnown: true}}
+
+	counts := pickCounts(b, []string{"replica-a", "replica-b", "replica-c"}, load, 500)
+	if counts["replica-c"] != 500 {
+		t.Errorf("expected the erroring and lagging replicas to be excluded, got %v", counts)
+	}
+
+	if _, ok := b.Pick([]string{"replica-a", "replica-b"}, func(endpoint string) ReplicaLoad { return load[endpoint] }); ok {
+		t.Error("expected no pick when every replica is excluded")
+	}
+}
+
+func TestReplicaBalancer_ExcludedReplicasAreProbedAgain(t *testing.T) {
+	b := newTestBalancer()
+	now := time.Now()
+	b.now = func() time.Time { return now }
+	for i := 0; i < 20; i++ {
+		b.RecordResult("replica-a", errors.New("connection refused"))
+	}
+	if weight := b.Weight("replica-a", ReplicaLoad{}); weight != 0 {
+		t.Fatalf("expected the failing replica to be excluded, got a weight of %v", weight)
+	}
+
+	// Without queries its error rate decays until it gets reads again
+	now = now.Add(2 * errorRateHalfLife)
+	if weight := b.Weight("replica-a", ReplicaLoad{}); weight == 0 {
+		t.Errorf("expected the idle replica to be probed again, error rate %v", b.ErrorRate("replica-a"))
+	}
+}
+
+func TestReplicaBalancer_CountsOnlyConnectionErrors(t *testing.T) {
+	b := newTestBalancer()
+	for i := 0; i < 20; i++ {
+		b.RecordResult("replica-a", errors.New(`pq: syntax error at or near "SELEC"`))
+		b.RecordResult("replica-a", errors.New("pq: duplicate key value violates unique constraint"))
+	}
+	if rate := b.ErrorRate("replica-a"); rate != 0 {
+		t.Errorf("expected query errors not to count against the replica, got an error rate of %v", rate)
+	}
+
+	b.RecordResult("replica-b", &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("refused")})
+	b.RecordResult("replica-c", driver.ErrBadConn)
+	if b.ErrorRate("replica-b") == 0 || b.ErrorRate("replica-c") == 0 {
+		t.Error("expected connection errors to count against the replica")
+	}
+}
+
+func TestRouter_SelectEndpoint_BalancerFallsBackToPrimary(t *testing.T) {
+	r := NewRouter(NewMockCatalog(), zaptest.NewLogger(t), 10, time.Minute, "replica_ok", config.PricingConfig{})
+	b := newTestBalancer()
+	r.SetReplicaBalancer(b)
+	shard := &models.Shard{ID: "shard-1", PrimaryEndpoint: "primary", Replicas: []string{"replica-a", "replica-b"}}
+
+	for i := 0; i < 10; i++ {
+		b.RecordResult("replica-a", errors.New("dial tcp: i/o timeout"))
+	}
+	for i := 0; i < 20; i++ {
+		if got := r.selectEndpoint(shard, "eventual", 0); got != "replica-b" {
+			t.Fatalf("expected reads to avoid the failing replica, got %s", got)
+		}
+	}
+
+	for i := 0; i < 10; i++ {
+		b.RecordResult("replica-b", errors.New("dial tcp: i/o timeout"))
+	}
+	if got := r.selectEndpoint(shard, "eventual", 0); got != "primary" {
+		t.Errorf("expected reads to fall back to the primary, got %s", got)
+	}
+}
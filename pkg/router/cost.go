@@ -0,0 +1,190 @@
+package router
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sharding-system/pkg/config"
+	"github.com/sharding-system/pkg/logging"
+	"go.uber.org/zap"
+)
+
+// ErrQueryTooExpensive is returned when a query's estimated cost exceeds its budget
+var ErrQueryTooExpensive = errors.New("query exceeds cost budget")
+
+// IsTooExpensive reports whether err was caused by the query cost guardrail
+func IsTooExpensive(err error) bool {
+	return errors.Is(err, ErrQueryTooExpensive)
+}
+
+// planCacheTTL bounds how long a cost estimate is reused; table statistics drift
+const planCacheTTL = 5 * time.Minute
+
+// CostEstimator estimates the planner cost of a query on an endpoint
+type CostEstimator interface {
+	EstimateCost(ctx context.Context, endpoint string, query string, params []interface{}) (float64, error)
+}
+
+// explainEstimator estimates costs by running EXPLAIN over the router's connection pools
+type explainEstimator struct {
+	router *Router
+}
+
+// EstimateCost returns the total cost of the query's top-level plan node
+func (e *explainEstimator) EstimateCost(ctx context.Context, endpoint string, query string, params []interface{}) (float64, error) {
+	db, err := e.router.getConnection(ctx, endpoint)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get connection: %w", err)
+	}
+
+	var raw []byte
+	if err := db.QueryRowContext(ctx, "EXPLAIN (FORMAT JSON) "+query, params...).Scan(&raw); err != nil {
+		return 0, fmt.Errorf("failed to explain query: %w", err)
+	}
+	return parseExplainCost(raw)
+}
+
+// parseExplainCost extracts the total cost from EXPLAIN (FORMAT JSON) output
+func parseExplainCost(raw []byte) (float64, error) {
+	var plans []struct {
+		Plan struct {
+			TotalCost float64 `json:"Total Cost"`
+		} `json:"Plan"`
+	}
+	if err := json.Unmarshal(raw, &plans); err != nil {
+		return 0, fmt.Errorf("failed to parse plan: %w", err)
+	}
+	if len(plans) == 0 {
+		return 0, fmt.Errorf("empty plan")
+	}
+	return plans[0].Plan.TotalCost, nil
+}
+
+// explainable reports whether EXPLAIN accepts the statement
+func explainable(query string) bool {
+	fields := strings.Fields(query)
+	if len(fields) == 0 {
+		return false
+	}
+	switch strings.ToUpper(fields[0]) {
+	case "SELECT", "WITH", "INSERT", "UPDATE", "DELETE", "VALUES", "TABLE":
+		return true
+	}
+	return false
+}
+
+type cachedCost struct {
+	cost      float64
+	expiresAt time.Time
+}
+
+// QueryGuard checks queries against a cost budget before they are executed.
+// Estimates are cached per shard and query text so repeated queries are only
+// explained once. Estimation failures never block a query.
+type QueryGuard struct {
+	cfg       config.QueryGuardConfig
+	estimator CostEstimator
+	logger    *zap.Logger
+
+	mu    sync.Mutex
+	plans map[string]cachedCost
+}
+
+// NewQueryGuard creates a query cost guardrail
+func NewQueryGuard(cfg config.QueryGuardConfig, estimator CostEstimator, logger *zap.Logger) *QueryGuard {
+	if cfg.PlanCacheSize <= 0 {
+		cfg.PlanCacheSize = 1024
+	}
+	return &QueryGuard{
+		cfg:       cfg,
+		estimator: estimator,
+		logger:    logger,
+		plans:     make(map[string]cachedCost),
+	}
+}
+
+// budget returns the cost budget for a client application, 0 meaning unlimited
+func (g *QueryGuard) budget(clientAppID string) float64 {
+	if budget, ok := g.cfg.TenantMaxCost[clientAppID]; ok {
+		return budget
+	}
+	return g.cfg.MaxCost
+}
+
+// Check estimates the query's cost on the shard endpoint and compares it to
+// the client application's budget. Over-budget queries return
+// ErrQueryTooExpensive, or a warning when the guard only flags them.
+func (g *QueryGuard) Check(ctx context.Context, clientAppID, shardID, endpoint, query string, params []interface{}) (string, error) {
+	budget := g.budget(clientAppID)
+	if budget <= 0 || !explainable(query) {
+		return "", nil
+	}
+
+	cost, err := g.estimate(ctx, shardID, endpoint, query, params)
+	if err != nil {
+		logging.WithRequestID(ctx, g.logger).Warn("failed to estimate query cost, allowing query",
+			zap.String("shard_id", shardID),
+			zap.Error(err))
+		return "", nil
+	}
+	if cost <= budget {
+		return "", nil
+	}
+
+	if g.cfg.Action == "flag" {
+		logging.WithRequestID(ctx, g.logger).Warn("query exceeds cost budget",
+			zap.String("client_app_id", clientAppID),
+			zap.String("shard_id", shardID),
+			zap.Float64("estimated_cost", cost),
+			zap.Float64("budget", budget))
+		return fmt.Sprintf("estimated cost %.2f exceeds budget %.2f", cost, budget), nil
+	}
+	return "", fmt.Errorf("%w: estimated cost %.2f exceeds budget %.2f", ErrQueryTooExpensive, cost, budget)
+}
+
+// estimate returns the cached cost for the query, explaining it on a miss
+func (g *QueryGuard) estimate(ctx context.Context, shardID, endpoint, query string, params []interface{}) (float64, error) {
+	key := shardID + "\x00" + query
+	now := time.Now()
+
+	g.mu.Lock()
+	if cached, ok := g.plans[key]; ok && now.Before(cached.expiresAt) {
+		g.mu.Unlock()
+		return cached.cost, nil
+	}
+	g.mu.Unlock()
+
+	cost, err := g.estimator.EstimateCost(ctx, endpoint, query, params)
+	if err != nil {
+		return 0, err
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if len(g.plans) >= g.cfg.PlanCacheSize {
+		g.evictLocked(now)
+	}
+	g.plans[key] = cachedCost{cost: cost, expiresAt: now.Add(planCacheTTL)}
+	return cost, nil
+}
+
+// evictLocked drops expired estimates, or an arbitrary one if none have expired.
+// Must be called with g.mu held.
+func (g *QueryGuard) evictLocked(now time.Time) {
+	for key, cached := range g.plans {
+		if now.After(cached.expiresAt) {
+			delete(g.plans, key)
+		}
+	}
+	for key := range g.plans {
+		if len(g.plans) < g.cfg.PlanCacheSize {
+			return
+		}
+		delete(g.plans, key)
+	}
+}
@@ -0,0 +1,145 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sharding-system/pkg/config"
+	"github.com/sharding-system/pkg/models"
+	"go.uber.org/zap/zaptest"
+)
+
+// mockEstimator returns a fixed cost and counts EXPLAIN calls
+type mockEstimator struct {
+	mu    sync.Mutex
+	cost  float64
+	err   error
+	calls int
+}
+
+func (m *mockEstimator) EstimateCost(ctx context.Context, endpoint string, query string, params []interface{}) (float64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls++
+	return m.cost, m.err
+}
+
+func newGuardedRouter(t *testing.T, cfg config.QueryGuardConfig, estimator CostEstimator) *Router {
+	t.Helper()
+	catalog := NewMockCatalog()
+	catalog.CreateShard(&models.Shard{ID: "shard1", PrimaryEndpoint: "postgres://invalid:1/db", Status: "active"})
+	router := NewRouter(catalog, zaptest.NewLogger(t), 10, 5*time.Minute, "primary_only", config.PricingConfig{Tier: "enterprise"})
+	router.SetQueryGuard(cfg, estimator)
+	return router
+}
+
+func TestRouter_ExecuteQuery_BlocksExpensiveQuery(t *testing.T) {
+	estimator := &mockEstimator{cost: 1e6}
+	router := newGuardedRouter(t, config.QueryGuardConfig{MaxCost: 1000, Action: "reject"}, estimator)
+
+	req := &models.QueryRequest{ShardKey: "key", Query: "SELECT * FROM orders o JOIN items i ON true"}
+	_, err := router.ExecuteQuery(context.Background(), req, "app1")
+	if !IsTooExpensive(err) {
+		t.Fatalf("expected query to be blocked by cost guardrail, got %v", err)
+	}
+	if estimator.calls != 1 {
+		t.Errorf("expected 1 estimate, got %d", estimator.calls)
+	}
+}
+
+func TestRouter_ExecuteQuery_AllowsQueryUnderBudget(t *testing.T) {
+	estimator := &mockEstimator{cost: 10}
+	router := newGuardedRouter(t, config.QueryGuardConfig{MaxCost: 1000, Action: "reject"}, estimator)
+
+	req := &models.QueryRequest{ShardKey: "key", Query: "SELECT 1"}
+	_, err := router.ExecuteQuery(context.Background(), req, "app1")
+	// The query passes the guardrail and fails connecting to the unreachable shard
+	if err == nil || IsTooExpensive(err) {
+		t.Fatalf("expected query to pass the guardrail, got %v", err)
+	}
+}
+
+func TestQueryGuard_CachesPlans(t *testing.T) {
+	estimator := &mockEstimator{cost: 10}
+	guard := NewQueryGuard(config.QueryGuardConfig{MaxCost: 100}, estimator, zaptest.NewLogger(t))
+
+	for i := 0; i < 3; i++ {
+		if _, err := guard.Check(context.Background(), "app1", "shard1", "ep", "SELECT * FROM t", nil); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if estimator.calls != 1 {
+		t.Errorf("expected identical queries to be explained once, got %d", estimator.calls)
+	}
+
+	// The same query on another shard has its own plan
+	if _, err := guard.Check(context.Background(), "app1", "shard2", "ep2", "SELECT * FROM t", nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if estimator.calls != 2 {
+		t.Errorf("expected a new estimate for another shard, got %d calls", estimator.calls)
+	}
+}
+
+func TestQueryGuard_TenantBudgets(t *testing.T) {
+	estimator := &mockEstimator{cost: 500}
+	guard := NewQueryGuard(config.QueryGuardConfig{
+		MaxCost:       1000,
+		TenantMaxCost: map[string]float64{"small-app": 100, "batch-app": 0},
+	}, estimator, zaptest.NewLogger(t))
+
+	if _, err := guard.Check(context.Background(), "default-app", "shard1", "ep", "SELECT 1", nil); err != nil {
+		t.Errorf("expected default budget to allow query, got %v", err)
+	}
+	if _, err := guard.Check(context.Background(), "small-app", "shard1", "ep", "SELECT 1", nil); !IsTooExpensive(err) {
+		t.Errorf("expected tenant budget to block query, got %v", err)
+	}
+	if _, err := guard.Check(context.Background(), "batch-app", "shard1", "ep", "SELECT 1", nil); err != nil {
+		t.Errorf("expected zero tenant budget to disable the guardrail, got %v", err)
+	}
+}
+
+func TestQueryGuard_FlagMode(t *testing.T) {
+	guard := NewQueryGuard(config.QueryGuardConfig{MaxCost: 100, Action: "flag"}, &mockEstimator{cost: 1e6}, zaptest.NewLogger(t))
+
+	warning, err := guard.Check(context.Background(), "app1", "shard1", "ep", "SELECT 1", nil)
+	if err != nil {
+		t.Fatalf("expected flag mode to allow query, got %v", err)
+	}
+	if warning == "" {
+		t.Error("expected a warning for over-budget query")
+	}
+}
+
+func TestQueryGuard_EstimateFailureAllowsQuery(t *testing.T) {
+	estimator := &mockEstimator{err: errors.New("syntax error")}
+	guard := NewQueryGuard(config.QueryGuardConfig{MaxCost: 100}, estimator, zaptest.NewLogger(t))
+
+	if _, err := guard.Check(context.Background(), "app1", "shard1", "ep", "SELECT 1", nil); err != nil {
+		t.Errorf("expected estimation failure to allow query, got %v", err)
+	}
+	if _, err := guard.Check(context.Background(), "app1", "shard1", "ep", "CREATE TABLE t (id int)", nil); err != nil {
+		t.Errorf("expected DDL to skip the guardrail, got %v", err)
+	}
+	if estimator.calls != 1 {
+		t.Errorf("expected DDL not to be explained, got %d calls", estimator.calls)
+	}
+}
+
+func TestParseExplainCost(t *testing.T) {
+	raw := []byte(`[{"Plan": {"Node Type": "Seq Scan", "Startup Cost": 0.00, "Total Cost": 431.25}}]`)
+	cost, err := parseExplainCost(raw)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cost != 431.25 {
+		t.Errorf("expected cost 431.25, got %v", cost)
+	}
+
+	if _, err := parseExplainCost([]byte(`[]`)); err == nil {
+		t.Error("expected error for empty plan")
+	}
+}
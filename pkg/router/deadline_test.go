@@ -0,0 +1,165 @@
+package router
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sharding-system/pkg/config"
+	"github.com/sharding-system/pkg/models"
+	"go.uber.org/zap/zaptest"
+)
+
+// blockingDriver hands out connections whose queries run until their context
+// is done, reporting each cancellation on the shared channel
+type blockingDriver struct {
+	started   chan struct{}
+	cancelled chan error
+	down      bool // Pings fail, as if the shard went away
+}
+
+var testBlockingDriver = &blockingDriver{}
+
+func init() {
+	sql.Register("routertest", testBlockingDriver)
+}
+
+func (d *blockingDriver) reset() {
+	d.started = make(chan struct{}, 1)
+	d.cancelled = make(chan error, 1)
+	d.down = false
+}
+
+func (d *blockingDriver) Open(name string) (driver.Conn, error) {
+	return &blockingConn{driver: d}, nil
+}
+
+type blockingConn struct {
+	driver *blockingDriver
+}
+
+func (c *blockingConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("prepare not supported")
+}
+
+func (c *blockingConn) Close() error { return nil }
+
+func (c *blockingConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("transactions not supported")
+}
+
+func (c *blockingConn) Ping(ctx context.Context) error {
+	if c.driver.down {
+		return errors.New("connection refused")
+	}
+	return nil
+}
+
+func (c *blockingConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	c.driver.started <- struct{}{}
+	<-ctx.Done()
+	c.driver.cancelled <- ctx.Err()
+	return nil, ctx.Err()
+}
+
+func newBlockingRouter(t *testing.T) *Router {
+	t.Helper()
+	testBlockingDriver.reset()
+	catalog := NewMockCatalog()
+	catalog.CreateShard(&models.Shard{ID: "shard1", PrimaryEndpoint: "blocking", Status: "active"})
+	router := NewRouter(catalog, zaptest.NewLogger(t), 10, 5*time.Minute, "primary_only", config.PricingConfig{Tier: "enterprise"})
+	router.driver = "routertest"
+	t.Cleanup(func() { router.Close() })
+	return router
+}
+
+func TestRouter_ExecuteQuery_CancelledRequestCancelsQuery(t *testing.T) {
+	router := newBlockingRouter(t)
+	router.SetStatementTimeout(time.Minute)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := router.ExecuteQuery(ctx, &models.QueryRequest{ShardKey: "key", Query: "SELECT pg_sleep(60)"}, "app1")
+		errCh <- err
+	}()
+
+	select {
+	case <-testBlockingDriver.started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("query never reached the shard")
+	}
+
+	// The client gives up
+	cancel()
+
+	select {
+	case err := <-testBlockingDriver.cancelled:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("expected the shard query to be cancelled, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("shard query kept running after the request was cancelled")
+	}
+
+	if err := <-errCh; err == nil || IsTimeout(err) {
+		t.Errorf("expected a cancellation error, got %v", err)
+	}
+}
+
+func TestRouter_ExecuteQuery_StatementTimeoutBoundsQuery(t *testing.T) {
+	router := newBlockingRouter(t)
+	router.SetStatementTimeout(50 * time.Millisecond)
+
+	// No deadline on the request itself; the statement timeout still applies
+	_, err := router.ExecuteQuery(context.Background(), &models.QueryRequest{ShardKey: "key", Query: "SELECT pg_sleep(60)"}, "app1")
+	if !IsTimeout(err) {
+		t.Fatalf("expected statement timeout, got %v", err)
+	}
+
+	select {
+	case err := <-testBlockingDriver.cancelled:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected the shard query to hit its deadline, got %v", err)
+		}
+	default:
+		t.Fatal("shard query was not cancelled by the statement timeout")
+	}
+}
+
+func TestRouter_GetConnection_KeepsPoolForCancelledRequests(t *testing.T) {
+	router := newBlockingRouter(t)
+	db, err := router.getConnection(context.Background(), "blocking")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if again, err := router.getConnection(ctx, "blocking"); err != nil || again != db {
+		t.Errorf("expected a cancelled request to reuse the pool, got %v", err)
+	}
+}
+
+func TestRouter_GetConnection_ClosesDeadPool(t *testing.T) {
+	router := newBlockingRouter(t)
+	db, err := router.getConnection(context.Background(), "blocking")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	testBlockingDriver.down = true
+	if _, err := router.getConnection(context.Background(), "blocking"); err == nil {
+		t.Fatal("expected no connection to a shard that is down")
+	}
+	if err := db.Ping(); err == nil || !strings.Contains(err.Error(), "database is closed") {
+		t.Errorf("expected the dead pool to be closed, got %v", err)
+	}
+	if _, exists := router.connections["blocking"]; exists {
+		t.Error("expected the dead pool to be dropped")
+	}
+}
@@ -0,0 +1,81 @@
+package router
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sharding-system/pkg/config"
+	"github.com/sharding-system/pkg/models"
+	"go.uber.org/zap/zaptest"
+)
+
+// mockHealthSource reports the endpoints marked down
+type mockHealthSource struct {
+	mu   sync.Mutex
+	down map[string]bool
+}
+
+func (m *mockHealthSource) EndpointDown(endpoint string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.down[endpoint]
+}
+
+func (m *mockHealthSource) set(endpoint string, down bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.down[endpoint] = down
+}
+
+func TestRouter_SelectEndpoint_SkipsDownReplicas(t *testing.T) {
+	r := NewRouter(NewMockCatalog(), zaptest.NewLogger(t), 10, time.Minute, "replica_ok", config.PricingConfig{})
+	health := &mockHealthSource{down: map[string]bool{}}
+	r.SetHealthSource(health)
+	r.SetReplicaBalancer(newTestBalancer())
+	shard := &models.Shard{ID: "shard-1", PrimaryEndpoint: "primary", Replicas: []string{"replica-a", "replica-b"}}
+
+	reads := func() map[string]int {
+		counts := make(map[string]int)
+		for i := 0; i < 200; i++ {
+			counts[r.selectEndpoint(shard, "eventual", 0)]++
+		}
+		return counts
+	}
+
+	if counts := reads(); counts["replica-a"] == 0 || counts["replica-b"] == 0 {
+		t.Fatalf("expected reads spread over both replicas, got %v", counts)
+	}
+
+	// replica-a goes down: reads stop going to it instead of failing
+	health.set("replica-a", true)
+	if counts := reads(); counts["replica-a"] != 0 || counts["replica-b"] != 200 {
+		t.Fatalf("expected every read on replica-b while replica-a is down, got %v", counts)
+	}
+
+	// With both down, reads go to the primary
+	health.set("replica-b", true)
+	if counts := reads(); counts["primary"] != 200 {
+		t.Fatalf("expected every read on the primary while all replicas are down, got %v", counts)
+	}
+
+	// Recovery puts replica-a back in rotation
+	health.set("replica-a", false)
+	if counts := reads(); counts["replica-a"] != 200 {
+		t.Fatalf("expected reads to resume on the recovered replica, got %v", counts)
+	}
+}
+
+func TestRouter_SelectEndpoint_DownReplicaWithStalenessBudget(t *testing.T) {
+	r := NewRouter(NewMockCatalog(), zaptest.NewLogger(t), 10, time.Minute, "replica_ok", config.PricingConfig{})
+	r.SetLagSource(mockLagSource{"replica-fresh": 100 * time.Millisecond, "replica-ok": time.Second})
+	r.SetHealthSource(&mockHealthSource{down: map[string]bool{"replica-fresh": true}})
+	shard := &models.Shard{ID: "shard-1", PrimaryEndpoint: "primary", Replicas: []string{"replica-fresh", "replica-ok"}}
+
+	if got := r.selectEndpoint(shard, "eventual", 5*time.Second); got != "replica-ok" {
+		t.Errorf("expected the down replica to be skipped even when fresh, got %s", got)
+	}
+	if got := r.selectEndpoint(shard, "eventual", 500*time.Millisecond); got != "primary" {
+		t.Errorf("expected the primary when the only fresh replica is down, got %s", got)
+	}
+}
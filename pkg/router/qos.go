@@ -0,0 +1,148 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/sharding-system/pkg/pricing"
+)
+
+// ErrThrottled is returned when a request is shed by QoS admission control
+var ErrThrottled = errors.New("request throttled")
+
+// IsThrottled reports whether err was caused by QoS admission control
+func IsThrottled(err error) bool {
+	return errors.Is(err, ErrThrottled)
+}
+
+// QoSScheduler admits queries by QoS class against a shared concurrency
+// capacity. Each class may only use part of the capacity: best-effort
+// requests are shed as soon as half of it is in use or anyone is queued,
+// standard requests queue once 80% is in use, and premium requests may use
+// all of it. Freed slots go to queued premium requests first.
+type QoSScheduler struct {
+	mu       sync.Mutex
+	capacity int
+	inFlight int
+	waiters  map[pricing.QoSClass][]chan struct{}
+}
+
+// NewQoSScheduler creates a scheduler allowing capacity concurrent queries
+func NewQoSScheduler(capacity int) *QoSScheduler {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &QoSScheduler{
+		capacity: capacity,
+		waiters:  make(map[pricing.QoSClass][]chan struct{}),
+	}
+}
+
+// ceiling returns how many in-flight queries a class may be admitted under
+func (s *QoSScheduler) ceiling(class pricing.QoSClass) int {
+	var limit int
+	switch class {
+	case pricing.QoSPremium:
+		return s.capacity
+	case pricing.QoSStandard:
+		limit = s.capacity * 8 / 10
+	default:
+		limit = s.capacity / 2
+	}
+	if limit < 1 {
+		limit = 1
+	}
+	return limit
+}
+
+// queuedAhead reports whether requests of class or higher priority are waiting
+func (s *QoSScheduler) queuedAhead(class pricing.QoSClass) bool {
+	if class == pricing.QoSPremium {
+		return len(s.waiters[pricing.QoSPremium]) > 0
+	}
+	return len(s.waiters[pricing.QoSPremium]) > 0 || len(s.waiters[pricing.QoSStandard]) > 0
+}
+
+// Acquire admits a query of the given class, waiting for a slot if the class
+// is allowed to queue. The returned function must be called when the query
+// finishes.
+func (s *QoSScheduler) Acquire(ctx context.Context, class pricing.QoSClass) (func(), error) {
+	s.mu.Lock()
+	if !s.queuedAhead(class) && s.inFlight < s.ceiling(class) {
+		s.inFlight++
+		s.mu.Unlock()
+		return s.release, nil
+	}
+
+	if class != pricing.QoSPremium && class != pricing.QoSStandard {
+		inFlight := s.inFlight
+		s.mu.Unlock()
+		return nil, fmt.Errorf("%w: %s traffic shed under load (%d/%d in flight)", ErrThrottled, class, inFlight, s.capacity)
+	}
+
+	ready := make(chan struct{})
+	s.waiters[class] = append(s.waiters[class], ready)
+	s.mu.Unlock()
+
+	select {
+	case <-ready:
+		return s.release, nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.removeWaiter(class, ready) {
+			return nil, fmt.Errorf("%w: %s request timed out waiting for capacity: %v", ErrThrottled, class, ctx.Err())
+		}
+		// A slot was handed over just as we gave up; pass it on
+		s.inFlight--
+		s.dispatch()
+		return nil, fmt.Errorf("%w: %s request timed out waiting for capacity: %v", ErrThrottled, class, ctx.Err())
+	}
+}
+
+// release frees a slot and hands it to the highest-priority waiter
+func (s *QoSScheduler) release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.inFlight--
+	s.dispatch()
+}
+
+// dispatch admits queued requests while their class ceilings allow.
+// Must be called with s.mu held.
+func (s *QoSScheduler) dispatch() {
+	for _, class := range []pricing.QoSClass{pricing.QoSPremium, pricing.QoSStandard} {
+		for len(s.waiters[class]) > 0 && s.inFlight < s.ceiling(class) {
+			ready := s.waiters[class][0]
+			s.waiters[class] = s.waiters[class][1:]
+			s.inFlight++
+			close(ready)
+		}
+		if len(s.waiters[class]) > 0 {
+			// Lower classes stay queued behind this one
+			return
+		}
+	}
+}
+
+// removeWaiter drops a waiter from its queue, reporting whether it was still queued.
+// Must be called with s.mu held.
+func (s *QoSScheduler) removeWaiter(class pricing.QoSClass, ready chan struct{}) bool {
+	queue := s.waiters[class]
+	for i, ch := range queue {
+		if ch == ready {
+			s.waiters[class] = append(queue[:i], queue[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// InFlight returns the number of admitted queries that have not finished
+func (s *QoSScheduler) InFlight() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.inFlight
+}
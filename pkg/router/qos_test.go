@@ -0,0 +1,205 @@
+package router
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sharding-system/pkg/config"
+	"github.com/sharding-system/pkg/models"
+	"github.com/sharding-system/pkg/pricing"
+	"go.uber.org/zap/zaptest"
+)
+
+// holdSlots admits n premium queries and returns a func releasing them
+func holdSlots(t *testing.T, s *QoSScheduler, n int) func() {
+	t.Helper()
+	releases := make([]func(), 0, n)
+	for i := 0; i < n; i++ {
+		release, err := s.Acquire(context.Background(), pricing.QoSPremium)
+		if err != nil {
+			t.Fatalf("failed to hold slot %d: %v", i, err)
+		}
+		releases = append(releases, release)
+	}
+	return func() {
+		for _, release := range releases {
+			release()
+		}
+	}
+}
+
+func TestQoSScheduler_ShedsBestEffortUnderPressure(t *testing.T) {
+	s := NewQoSScheduler(10)
+	releaseHeld := holdSlots(t, s, 5)
+	defer releaseHeld()
+
+	// Half the capacity is busy: best-effort is shed, premium and standard are admitted
+	if _, err := s.Acquire(context.Background(), pricing.QoSBestEffort); !IsThrottled(err) {
+		t.Fatalf("expected best_effort to be throttled, got %v", err)
+	}
+
+	release, err := s.Acquire(context.Background(), pricing.QoSStandard)
+	if err != nil {
+		t.Fatalf("expected standard to be admitted, got %v", err)
+	}
+	release()
+
+	release, err = s.Acquire(context.Background(), pricing.QoSPremium)
+	if err != nil {
+		t.Fatalf("expected premium to be admitted, got %v", err)
+	}
+	release()
+}
+
+func TestQoSScheduler_PremiumUsesFullCapacity(t *testing.T) {
+	s := NewQoSScheduler(10)
+	releaseHeld := holdSlots(t, s, 8)
+	defer releaseHeld()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := s.Acquire(ctx, pricing.QoSStandard); !IsThrottled(err) {
+		t.Fatalf("expected standard to time out above its ceiling, got %v", err)
+	}
+
+	release, err := s.Acquire(context.Background(), pricing.QoSPremium)
+	if err != nil {
+		t.Fatalf("expected premium to be admitted up to capacity, got %v", err)
+	}
+	release()
+
+	if got := s.InFlight(); got != 8 {
+		t.Errorf("expected 8 in flight, got %d", got)
+	}
+}
+
+func TestQoSScheduler_PremiumServedBeforeStandard(t *testing.T) {
+	s := NewQoSScheduler(2)
+	releaseHeld := holdSlots(t, s, 2)
+
+	var mu sync.Mutex
+	var order []pricing.QoSClass
+	var wg sync.WaitGroup
+	start := func(class pricing.QoSClass) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			release, err := s.Acquire(context.Background(), class)
+			if err != nil {
+				t.Errorf("%s: unexpected error: %v", class, err)
+				return
+			}
+			mu.Lock()
+			order = append(order, class)
+			mu.Unlock()
+			release()
+		}()
+	}
+
+	// Queue standard first so premium has to overtake it
+	start(pricing.QoSStandard)
+	waitForWaiters(t, s, pricing.QoSStandard, 1)
+	start(pricing.QoSPremium)
+	waitForWaiters(t, s, pricing.QoSPremium, 1)
+
+	// Best-effort is shed while anyone is queued
+	if _, err := s.Acquire(context.Background(), pricing.QoSBestEffort); !IsThrottled(err) {
+		t.Fatalf("expected best_effort to be throttled while requests are queued, got %v", err)
+	}
+
+	releaseHeld()
+	wg.Wait()
+
+	if len(order) != 2 || order[0] != pricing.QoSPremium {
+		t.Fatalf("expected premium to be served first, got %v", order)
+	}
+	if got := s.InFlight(); got != 0 {
+		t.Errorf("expected no queries in flight, got %d", got)
+	}
+}
+
+func TestQoSScheduler_CancelledWaiterFreesQueue(t *testing.T) {
+	s := NewQoSScheduler(1)
+	releaseHeld := holdSlots(t, s, 1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := s.Acquire(ctx, pricing.QoSPremium)
+		errCh <- err
+	}()
+	waitForWaiters(t, s, pricing.QoSPremium, 1)
+	cancel()
+
+	if err := <-errCh; !IsThrottled(err) {
+		t.Fatalf("expected cancelled waiter to be throttled, got %v", err)
+	}
+	releaseHeld()
+
+	if got := s.InFlight(); got != 0 {
+		t.Errorf("expected no queries in flight, got %d", got)
+	}
+	release, err := s.Acquire(context.Background(), pricing.QoSBestEffort)
+	if err != nil {
+		t.Fatalf("expected best_effort to be admitted once idle, got %v", err)
+	}
+	release()
+}
+
+func TestRouter_ExecuteQuery_ThrottlesBestEffortTenant(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	router := NewRouter(NewMockCatalog(), logger, 4, 5*time.Minute, "primary_only", config.PricingConfig{Tier: "enterprise"})
+	router.SetClientQoS("free-app", pricing.QoSBestEffort)
+
+	releaseHeld := holdSlots(t, router.qos, 2)
+	defer releaseHeld()
+
+	req := &models.QueryRequest{ShardKey: "key", Query: "SELECT 1"}
+	if _, err := router.ExecuteQuery(context.Background(), req, "free-app"); !IsThrottled(err) {
+		t.Fatalf("expected best_effort tenant to be throttled, got %v", err)
+	}
+
+	// Premium tenants (enterprise tier default) get past admission and fail on the missing shard instead
+	_, err := router.ExecuteQuery(context.Background(), req, "enterprise-app")
+	if err == nil || IsThrottled(err) {
+		t.Fatalf("expected premium tenant to be admitted, got %v", err)
+	}
+	if got := router.qos.InFlight(); got != 2 {
+		t.Errorf("expected admitted query to release its slot, got %d in flight", got)
+	}
+}
+
+func TestRouter_QoSClassFollowsTier(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	tests := []struct {
+		tier string
+		want pricing.QoSClass
+	}{
+		{"free", pricing.QoSBestEffort},
+		{"pro", pricing.QoSStandard},
+		{"enterprise", pricing.QoSPremium},
+	}
+	for _, tt := range tests {
+		router := NewRouter(NewMockCatalog(), logger, 10, 5*time.Minute, "primary_only", config.PricingConfig{Tier: tt.tier})
+		if got := router.qosClass("app"); got != tt.want {
+			t.Errorf("tier %s: expected %s, got %s", tt.tier, tt.want, got)
+		}
+	}
+}
+
+func waitForWaiters(t *testing.T, s *QoSScheduler, class pricing.QoSClass, n int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		s.mu.Lock()
+		queued := len(s.waiters[class])
+		s.mu.Unlock()
+		if queued >= n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %d %s waiters", n, class)
+}
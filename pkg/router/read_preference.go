@@ -0,0 +1,104 @@
+package router
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sharding-system/pkg/models"
+)
+
+// Read preferences a query may carry, overriding the router's replica policy
+const (
+	// ReadPrimary reads from the primary
+	ReadPrimary = "primary"
+	// ReadPreferReplica reads from a replica, or the primary when none can serve the read
+	ReadPreferReplica = "prefer-replica"
+	// ReadReplicaOnly reads from a replica, failing when none can serve the read
+	ReadReplicaOnly = "replica-only"
+	// ReadNearest reads from whichever of the primary and the replicas has
+	// answered fastest lately
+	ReadNearest = "nearest"
+)
+
+// latencyDecay is how much each query moves an endpoint's latency average
+const latencyDecay = 0.2
+
+// ErrNoReplicaAvailable is returned for replica-only reads no replica of the
+// shard can serve
+var ErrNoReplicaAvailable = errors.New("no replica available")
+
+// IsNoReplicaAvailable reports whether err was caused by a replica-only read
+// finding no replica
+func IsNoReplicaAvailable(err error) bool {
+	return errors.Is(err, ErrNoReplicaAvailable)
+}
+
+// validReadPreference checks a query's read preference; empty leaves the
+// choice to the consistency and the replica policy
+func validReadPreference(preference string) error {
+	switch preference {
+	case "", ReadPrimary, ReadPreferReplica, ReadReplicaOnly, ReadNearest:
+		return nil
+	default:
+		return fmt.Errorf("invalid read_preference %q: must be %s, %s, %s or %s",
+			preference, ReadPrimary, ReadPreferReplica, ReadReplicaOnly, ReadNearest)
+	}
+}
+
+// selectPreferredEndpoint picks the endpoint a query with a read preference
+// runs on. Replicas are chosen as for eventual reads, so those that are down
+// or past the staleness budget are skipped. Writes always run on the primary,
+// and replica-only ones are refused.
+func (r *Router) selectPreferredEndpoint(shard *models.Shard, query, preference string, maxStaleness time.Duration) (string, error) {
+	if !isReadOnlyQuery(query) {
+		if preference == ReadReplicaOnly {
+			return "", fmt.Errorf("read preference %s cannot run writes, which need the primary", ReadReplicaOnly)
+		}
+		return shard.PrimaryEndpoint, nil
+	}
+
+	switch preference {
+	case ReadPreferReplica:
+		if replica, ok := r.pickReplica(shard, maxStaleness); ok {
+			return replica, nil
+		}
+		return shard.PrimaryEndpoint, nil
+	case ReadReplicaOnly:
+		if replica, ok := r.pickReplica(shard, maxStaleness); ok {
+			return replica, nil
+		}
+		return "", fmt.Errorf("%w for shard %s", ErrNoReplicaAvailable, shard.ID)
+	case ReadNearest:
+		return r.nearest(append([]string{shard.PrimaryEndpoint}, r.replicaCandidates(shard, maxStaleness)...)), nil
+	default:
+		return shard.PrimaryEndpoint, nil
+	}
+}
+
+// nearest returns the endpoint with the lowest average latency. Endpoints
+// without queries yet count as fastest, so each gets measured; ties go to the
+// earlier endpoint.
+func (r *Router) nearest(endpoints []string) string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	best := endpoints[0]
+	for _, endpoint := range endpoints[1:] {
+		if r.latencies[endpoint] < r.latencies[best] {
+			best = endpoint
+		}
+	}
+	return best
+}
+
+// recordLatency folds a query's latency into its endpoint's average
+func (r *Router) recordLatency(endpoint string, latency time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	previous, ok := r.latencies[endpoint]
+	if !ok {
+		r.latencies[endpoint] = latency
+		return
+	}
+	r.latencies[endpoint] = time.Duration(float64(previous)*(1-latencyDecay) + float64(latency)*latencyDecay)
+}
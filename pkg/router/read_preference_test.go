@@ -0,0 +1,128 @@
+package router
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sharding-system/pkg/config"
+	"github.com/sharding-system/pkg/models"
+	"go.uber.org/zap/zaptest"
+)
+
+// newPreferenceTestRouter returns a router whose replica policy keeps reads
+// on the primary, so every replica read comes from a preference
+func newPreferenceTestRouter(t *testing.T) (*Router, *mockHealthSource, *models.Shard) {
+	t.Helper()
+	r := NewRouter(NewMockCatalog(), zaptest.NewLogger(t), 10, time.Minute, "primary", config.PricingConfig{})
+	health := &mockHealthSource{down: map[string]bool{}}
+	r.SetHealthSource(health)
+	shard := &models.Shard{ID: "shard-1", PrimaryEndpoint: "primary", Replicas: []string{"replica-a", "replica-b"}}
+	return r, health, shard
+}
+
+func TestRouter_ReadPreference(t *testing.T) {
+	tests := []struct {
+		preference    string
+		down          []string
+		want          string
+		wantNoReplica bool
+	}{
+		{preference: ReadPrimary, want: "primary"},
+		{preference: ReadPreferReplica, want: "replica-a"},
+		{preference: ReadPreferReplica, down: []string{"replica-a"}, want: "replica-b"},
+		{preference: ReadPreferReplica, down: []string{"replica-a", "replica-b"}, want: "primary"},
+		{preference: ReadReplicaOnly, want: "replica-a"},
+		{preference: ReadReplicaOnly, down: []string{"replica-a", "replica-b"}, wantNoReplica: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.preference+"/down="+strings.Join(tt.down, ","), func(t *testing.T) {
+			r, health, shard := newPreferenceTestRouter(t)
+			for _, endpoint := range tt.down {
+				health.set(endpoint, true)
+			}
+
+			got, err := r.selectPreferredEndpoint(shard, "SELECT * FROM orders", tt.preference, 0)
+			if tt.wantNoReplica {
+				if !IsNoReplicaAvailable(err) {
+					t.Errorf("expected ErrNoReplicaAvailable, got %q and %v", got, err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("expected %s, got %q and %v", tt.want, got, err)
+			}
+		})
+	}
+}
+
+func TestRouter_ReadPreference_ReplicaOnlyWithoutReplicas(t *testing.T) {
+	r, _, _ := newPreferenceTestRouter(t)
+	shard := &models.Shard{ID: "shard-1", PrimaryEndpoint: "primary"}
+
+	if _, err := r.selectPreferredEndpoint(shard, "SELECT 1", ReadReplicaOnly, 0); !IsNoReplicaAvailable(err) {
+		t.Errorf("expected a shard without replicas to refuse replica-only reads, got %v", err)
+	}
+	if got, err := r.selectPreferredEndpoint(shard, "SELECT 1", ReadPreferReplica, 0); err != nil || got != "primary" {
+		t.Errorf("expected prefer-replica to fall back to the primary, got %q and %v", got, err)
+	}
+}
+
+func TestRouter_ReadPreference_StalenessBudget(t *testing.T) {
+	r, _, shard := newPreferenceTestRouter(t)
+	r.SetLagSource(mockLagSource{"replica-a": 10 * time.Second, "replica-b": 100 * time.Millisecond})
+
+	if got, _ := r.selectPreferredEndpoint(shard, "SELECT 1", ReadPreferReplica, time.Second); got != "replica-b" {
+		t.Errorf("expected the replica within the budget, got %s", got)
+	}
+	if _, err := r.selectPreferredEndpoint(shard, "SELECT 1", ReadReplicaOnly, 10*time.Millisecond); !IsNoReplicaAvailable(err) {
+		t.Errorf("expected no replica within the budget to fail a replica-only read, got %v", err)
+	}
+}
+
+func TestRouter_ReadPreference_Nearest(t *testing.T) {
+	r, health, shard := newPreferenceTestRouter(t)
+
+	// Endpoints not measured yet are tried first, the primary ahead of replicas
+	if got, _ := r.selectPreferredEndpoint(shard, "SELECT 1", ReadNearest, 0); got != "primary" {
+		t.Errorf("expected the unmeasured primary, got %s", got)
+	}
+	r.recordLatency("primary", 40*time.Millisecond)
+	r.recordLatency("replica-a", 30*time.Millisecond)
+	if got, _ := r.selectPreferredEndpoint(shard, "SELECT 1", ReadNearest, 0); got != "replica-b" {
+		t.Errorf("expected the unmeasured replica-b, got %s", got)
+	}
+	r.recordLatency("replica-b", 5*time.Millisecond)
+	if got, _ := r.selectPreferredEndpoint(shard, "SELECT 1", ReadNearest, 0); got != "replica-b" {
+		t.Errorf("expected the fastest endpoint, got %s", got)
+	}
+
+	// Replicas that are down are not candidates
+	health.set("replica-b", true)
+	if got, _ := r.selectPreferredEndpoint(shard, "SELECT 1", ReadNearest, 0); got != "replica-a" {
+		t.Errorf("expected the fastest endpoint that is up, got %s", got)
+	}
+}
+
+func TestRouter_ReadPreference_Writes(t *testing.T) {
+	r, _, shard := newPreferenceTestRouter(t)
+
+	for _, preference := range []string{ReadPrimary, ReadPreferReplica, ReadNearest} {
+		if got, err := r.selectPreferredEndpoint(shard, "UPDATE orders SET paid = true", preference, 0); err != nil || got != "primary" {
+			t.Errorf("%s: expected writes on the primary, got %q and %v", preference, got, err)
+		}
+	}
+	if _, err := r.selectPreferredEndpoint(shard, "UPDATE orders SET paid = true", ReadReplicaOnly, 0); err == nil || IsNoReplicaAvailable(err) {
+		t.Errorf("expected a replica-only write to be refused, got %v", err)
+	}
+}
+
+func TestRouter_ExecuteQuery_InvalidReadPreference(t *testing.T) {
+	r := NewRouter(NewMockCatalog(), zaptest.NewLogger(t), 10, time.Minute, "replica_ok", config.PricingConfig{Tier: "enterprise"})
+	req := &models.QueryRequest{ShardKey: "k", Query: "SELECT 1", ReadPreference: "secondary"}
+
+	if _, err := r.ExecuteQuery(context.Background(), req, "app"); err == nil || !strings.Contains(err.Error(), "read_preference") {
+		t.Errorf("expected an error for an unknown read_preference, got %v", err)
+	}
+}
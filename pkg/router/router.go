@@ -3,18 +3,28 @@ package router
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	_ "github.com/lib/pq"
 	"github.com/sharding-system/pkg/catalog"
 	"github.com/sharding-system/pkg/config"
+	"github.com/sharding-system/pkg/hashing"
+	"github.com/sharding-system/pkg/keyrange"
+	"github.com/sharding-system/pkg/logging"
 	"github.com/sharding-system/pkg/models"
 	"github.com/sharding-system/pkg/pricing"
 	"go.uber.org/zap"
 )
 
+// pingTimeout bounds the liveness check of a pooled connection
+const pingTimeout = 2 * time.Second
+
 // Router routes queries to appropriate shards
 type Router struct {
 	catalog       catalog.Catalog
@@ -27,6 +37,32 @@ type Router struct {
 	pricingConfig config.PricingConfig
 	rpsCounter    int
 	lastReset     time.Time
+	qos           *QoSScheduler
+	clientQoS     map[string]pricing.QoSClass
+	guard         *QueryGuard
+	stmtTimeout   time.Duration
+	driver        string // database/sql driver used to reach shards
+	lagSource     LagSource
+	balancer      *ReplicaBalancer
+	walPositions  WALPositionSource
+	throttle      *Throttle
+	health        HealthSource
+	shadow        *shadowReads
+	latencies     map[string]time.Duration // Average query latency by endpoint, for nearest reads
+
+	// mysqlSessionWarning warns once that MySQL shards get no session tokens
+	mysqlSessionWarning sync.Once
+}
+
+// LagSource reports the replay lag of replica endpoints
+type LagSource interface {
+	// ReplicaLag returns the replica's last measured lag, or false when it is unknown
+	ReplicaLag(endpoint string) (time.Duration, bool)
+}
+
+// HealthSource reports endpoints that health checks have found persistently down
+type HealthSource interface {
+	EndpointDown(endpoint string) bool
 }
 
 // NewRouter creates a new router instance
@@ -40,13 +76,105 @@ func NewRouter(catalog catalog.Catalog, logger *zap.Logger, maxConns int, connTT
 		replicaPolicy: replicaPolicy,
 		pricingConfig: pricingConfig,
 		lastReset:     time.Now(),
+		qos:           NewQoSScheduler(maxConns),
+		clientQoS:     make(map[string]pricing.QoSClass),
+		latencies:     make(map[string]time.Duration),
+		driver:        "postgres",
+		throttle:      NewThrottle(),
 	}
 }
 
+// Throttle returns the router's emergency rate limiter and kill switch
+func (r *Router) Throttle() *Throttle {
+	return r.throttle
+}
+
+// SetStatementTimeout sets the upper bound on how long a routed query may run.
+// Queries still stop earlier when the caller's context is cancelled or has a
+// shorter deadline.
+func (r *Router) SetStatementTimeout(timeout time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stmtTimeout = timeout
+}
+
+// SetLagSource sets where replica lag is read from when reads carry a
+// max_staleness budget. Without one no replica is known to be fresh enough
+// and such reads go to the primary.
+func (r *Router) SetLagSource(source LagSource) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lagSource = source
+}
+
+// SetHealthSource sets where endpoint health is read from. Replicas it
+// reports down get no reads until they recover.
+func (r *Router) SetHealthSource(source HealthSource) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.health = source
+}
+
+// SetReplicaBalancer spreads reads across replicas by health instead of
+// always using the first eligible replica
+func (r *Router) SetReplicaBalancer(balancer *ReplicaBalancer) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.balancer = balancer
+}
+
+// IsTimeout reports whether err was caused by the query's deadline expiring
+func IsTimeout(err error) bool {
+	return errors.Is(err, context.DeadlineExceeded)
+}
+
+// SetClientQoS overrides the QoS class of a client application. Apps without
+// an override use the class of the router's pricing tier.
+func (r *Router) SetClientQoS(clientAppID string, class pricing.QoSClass) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.clientQoS[clientAppID] = class
+}
+
+// SetQueryGuard enables the query cost guardrail. A nil estimator runs EXPLAIN
+// on the shard the query is routed to.
+func (r *Router) SetQueryGuard(cfg config.QueryGuardConfig, estimator CostEstimator) {
+	if estimator == nil {
+		estimator = &explainEstimator{router: r}
+	}
+	guard := NewQueryGuard(cfg, estimator, r.logger)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.guard = guard
+}
+
+// qosClass returns the QoS class a client application's queries are scheduled with
+func (r *Router) qosClass(clientAppID string) pricing.QoSClass {
+	r.mu.RLock()
+	class, ok := r.clientQoS[clientAppID]
+	r.mu.RUnlock()
+	if ok {
+		return class
+	}
+	return pricing.GetLimits(r.pricingConfig.Tier).QoSClass
+}
+
 // ExecuteQuery executes a query on the appropriate shard
 func (r *Router) ExecuteQuery(ctx context.Context, req *models.QueryRequest, clientAppID string) (*models.QueryResponse, error) {
 	limits := pricing.GetLimits(r.pricingConfig.Tier)
 
+	// Everything below runs under the caller's context, so a client that gives up
+	// cancels the shard query; the statement timeout caps clients that wait forever
+	r.mu.RLock()
+	stmtTimeout := r.stmtTimeout
+	r.mu.RUnlock()
+	if stmtTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, stmtTimeout)
+		defer cancel()
+	}
+
 	// Check Consistency Limit
 	if req.Consistency == "strong" && !limits.AllowStrongConsistency {
 		return nil, fmt.Errorf("strong consistency not allowed for tier %s", limits.Name)
@@ -69,6 +197,33 @@ func (r *Router) ExecuteQuery(ctx context.Context, req *models.QueryRequest, cli
 		}
 	}
 
+	// Premium tenants may use the full concurrency budget; best-effort tenants are shed first
+	release, err := r.qos.Acquire(ctx, r.qosClass(clientAppID))
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	var maxStaleness time.Duration
+	if req.MaxStaleness != "" {
+		maxStaleness, err = time.ParseDuration(req.MaxStaleness)
+		if err != nil || maxStaleness < 0 {
+			return nil, fmt.Errorf("invalid max_staleness %q", req.MaxStaleness)
+		}
+	}
+	if err := validReadPreference(req.ReadPreference); err != nil {
+		return nil, err
+	}
+
+	var session *SessionToken
+	if req.SessionToken != "" {
+		token, err := ParseSessionToken(req.SessionToken)
+		if err != nil {
+			return nil, err
+		}
+		session = &token
+	}
+
 	start := time.Now()
 
 	// Get shard for the key, scoped to client application
@@ -77,27 +232,95 @@ func (r *Router) ExecuteQuery(ctx context.Context, req *models.QueryRequest, cli
 		return nil, fmt.Errorf("failed to get shard: %w", err)
 	}
 
-	// Select endpoint based on consistency requirement
-	endpoint := shard.PrimaryEndpoint
-	if req.Consistency == "eventual" && r.replicaPolicy == "replica_ok" && len(shard.Replicas) > 0 {
-		// Use replica for read-only queries with eventual consistency
-		endpoint = shard.Replicas[0]
+	// Select endpoint based on the read preference, or else the consistency requirement
+	var endpoint string
+	if req.ReadPreference != "" {
+		eligible := shard
+		if session != nil {
+			eligible = r.caughtUp(ctx, shard, *session)
+		}
+		endpoint, err = r.selectPreferredEndpoint(eligible, req.Query, req.ReadPreference, maxStaleness)
+		if err != nil {
+			return nil, err
+		}
+	} else if session != nil {
+		endpoint = r.selectSessionEndpoint(ctx, shard, req.Consistency, maxStaleness, *session)
+	} else {
+		endpoint = r.selectEndpoint(shard, req.Consistency, maxStaleness)
+	}
+
+	// Reject queries whose estimated cost would destabilize the shard
+	var warnings []string
+	r.mu.RLock()
+	guard := r.guard
+	r.mu.RUnlock()
+	if guard != nil {
+		warning, err := guard.Check(ctx, clientAppID, shard.ID, endpoint, req.Query, req.Params)
+		if err != nil {
+			return nil, err
+		}
+		if warning != "" {
+			warnings = append(warnings, warning)
+		}
 	}
 
 	// Get or create connection pool
-	db, err := r.getConnection(endpoint)
+	db, err := r.getConnection(ctx, endpoint)
 	if err != nil {
+		r.recordResult(ctx, endpoint, err)
 		return nil, fmt.Errorf("failed to get connection: %w", err)
 	}
 
 	// Execute query
 	rows, err := db.QueryContext(ctx, req.Query, req.Params...)
+	r.recordResult(ctx, endpoint, err)
 	if err != nil {
 		return nil, fmt.Errorf("query execution failed: %w", err)
 	}
 	defer rows.Close()
 
 	// Convert rows to response
+	resultRows, err := scanRows(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	latency := time.Since(start)
+	r.recordLatency(endpoint, latency)
+
+	// Mirror a sample of reads to the targets of a split in progress. Replicas
+	// may lag the targets, so only reads from the primary are compared.
+	if endpoint == shard.PrimaryEndpoint {
+		r.shadowRead(ctx, shard, req, resultRows)
+	}
+
+	// Writes hand back the WAL position they reached so the client's next
+	// reads are only served by a replica that has it
+	var sessionToken string
+	if endpoint == shard.PrimaryEndpoint && !isReadOnlyQuery(req.Query) {
+		sessionToken = r.sessionTokenFor(ctx, shard)
+	}
+
+	logging.WithRequestID(ctx, r.logger).Info("query executed",
+		zap.String("shard_id", shard.ID),
+		zap.String("endpoint", endpoint),
+		zap.Duration("latency", latency),
+		zap.Int("row_count", len(resultRows)),
+	)
+
+	return &models.QueryResponse{
+		ShardID:      shard.ID,
+		Rows:         resultRows,
+		RowCount:     len(resultRows),
+		LatencyMs:    float64(latency.Nanoseconds()) / 1e6,
+		Warnings:     warnings,
+		SessionToken: sessionToken,
+	}, nil
+}
+
+// scanRows converts query results to the rows returned to clients, one map of
+// column to value per row
+func scanRows(rows *sql.Rows) ([]interface{}, error) {
 	resultRows := make([]interface{}, 0)
 	columns, _ := rows.Columns()
 	for rows.Next() {
@@ -122,22 +345,197 @@ func (r *Router) ExecuteQuery(ctx context.Context, req *models.QueryRequest, cli
 		}
 		resultRows = append(resultRows, rowMap)
 	}
+	return resultRows, nil
+}
 
-	latency := time.Since(start)
+// selectEndpoint picks the endpoint a query runs on. Eventual reads may use a
+// replica; with a staleness budget only one whose measured lag is within it.
+func (r *Router) selectEndpoint(shard *models.Shard, consistency string, maxStaleness time.Duration) string {
+	if consistency != "eventual" || r.replicaPolicy != "replica_ok" || len(shard.Replicas) == 0 {
+		return shard.PrimaryEndpoint
+	}
+	if replica, ok := r.pickReplica(shard, maxStaleness); ok {
+		return replica
+	}
+	return shard.PrimaryEndpoint
+}
 
-	r.logger.Info("query executed",
-		zap.String("shard_id", shard.ID),
-		zap.String("endpoint", endpoint),
-		zap.Duration("latency", latency),
-		zap.Int("row_count", len(resultRows)),
-	)
+// replicaCandidates returns the replicas of a shard a read may use: those
+// health checks have not found down and, with a staleness budget, whose
+// measured lag is within it
+func (r *Router) replicaCandidates(shard *models.Shard, maxStaleness time.Duration) []string {
+	r.mu.RLock()
+	lagSource := r.lagSource
+	health := r.health
+	r.mu.RUnlock()
 
-	return &models.QueryResponse{
-		ShardID:   shard.ID,
-		Rows:      resultRows,
-		RowCount:  len(resultRows),
-		LatencyMs: float64(latency.Nanoseconds()) / 1e6,
-	}, nil
+	candidates := shard.Replicas
+	if health != nil {
+		candidates = make([]string, 0, len(shard.Replicas))
+		for _, replica := range shard.Replicas {
+			if !health.EndpointDown(replica) {
+				candidates = append(candidates, replica)
+			}
+		}
+		if len(candidates) == 0 {
+			r.logger.Debug("all replicas down", zap.String("shard_id", shard.ID))
+			return nil
+		}
+	}
+	if maxStaleness > 0 {
+		healthy := candidates
+		candidates = nil
+		if lagSource != nil {
+			for _, replica := range healthy {
+				if lag, ok := lagSource.ReplicaLag(replica); ok && lag <= maxStaleness {
+					candidates = append(candidates, replica)
+				}
+			}
+		}
+		if len(candidates) == 0 {
+			r.logger.Debug("no replica within staleness budget",
+				zap.String("shard_id", shard.ID),
+				zap.Duration("max_staleness", maxStaleness))
+		}
+	}
+	return candidates
+}
+
+// pickReplica chooses the replica of a shard a read uses, spread by the
+// replica balancer if there is one. It returns false when no replica may
+// serve the read.
+func (r *Router) pickReplica(shard *models.Shard, maxStaleness time.Duration) (string, bool) {
+	candidates := r.replicaCandidates(shard, maxStaleness)
+	if len(candidates) == 0 {
+		return "", false
+	}
+
+	r.mu.RLock()
+	balancer := r.balancer
+	r.mu.RUnlock()
+	if balancer == nil {
+		return candidates[0], true
+	}
+	if replica, ok := balancer.Pick(candidates, r.replicaLoad); ok {
+		return replica, true
+	}
+	r.logger.Debug("all replicas unhealthy", zap.String("shard_id", shard.ID))
+	return "", false
+}
+
+// replicaLoad reports a replica's pool saturation and replication lag
+func (r *Router) replicaLoad(endpoint string) ReplicaLoad {
+	r.mu.RLock()
+	db, exists := r.connections[endpoint]
+	lagSource := r.lagSource
+	r.mu.RUnlock()
+
+	var load ReplicaLoad
+	if exists {
+		if stats := db.Stats(); stats.MaxOpenConnections > 0 {
+			load.Saturation = float64(stats.InUse) / float64(stats.MaxOpenConnections)
+		}
+	}
+	if lagSource != nil {
+		load.Lag, load.LagKnown = lagSource.ReplicaLag(endpoint)
+	}
+	return load
+}
+
+// recordResult feeds a query outcome to the replica balancer. Queries the
+// caller cancelled or that ran out of time say nothing about the replica.
+func (r *Router) recordResult(ctx context.Context, endpoint string, err error) {
+	r.mu.RLock()
+	balancer := r.balancer
+	r.mu.RUnlock()
+	if balancer == nil || ctx.Err() != nil {
+		return
+	}
+	balancer.RecordResult(endpoint, err)
+}
+
+// Route describes where a key is placed
+type Route struct {
+	ShardID      string `json:"shard_id"`
+	Endpoint     string `json:"endpoint"`                // Primary endpoint, without credentials
+	HashFunction string `json:"hash_function,omitempty"` // Hash function the client app's keys are placed with
+	KeyHash      string `json:"key_hash,omitempty"`      // The key's hash under HashFunction, in decimal
+
+	// KeyRange is set instead of the hash fields for range-strategy shards
+	KeyRange *KeyRange `json:"key_range,omitempty"`
+}
+
+// KeyRange is the range of keys a range-strategy shard holds, from Start
+// (inclusive) to End (exclusive). An empty bound is unbounded.
+type KeyRange struct {
+	Start     string `json:"start"`
+	End       string `json:"end"`
+	Collation string `json:"collation"` // Order keys are compared in: "binary" or "unicode"
+}
+
+// ResolveRoute returns the shard a key is placed on, scoped to client
+// application, so clients can check their own placement against the server's
+func (r *Router) ResolveRoute(key string, clientAppID string) (*Route, error) {
+	shard, err := r.catalog.GetShard(key, clientAppID)
+	if err != nil {
+		return nil, err
+	}
+	route := &Route{
+		ShardID:  shard.ID,
+		Endpoint: publicEndpoint(shard.PrimaryEndpoint),
+	}
+	if shard.Strategy == "range" {
+		route.KeyRange = &KeyRange{
+			Start:     shard.KeyRangeStart,
+			End:       shard.KeyRangeEnd,
+			Collation: keyrange.NormalizeCollation(shard.Collation),
+		}
+		return route, nil
+	}
+	route.HashFunction = hashing.NormalizeHashFunction(shard.HashFunction)
+	route.KeyHash = strconv.FormatUint(hashing.NewHashFunction(shard.HashFunction).Hash(key), 10)
+	return route, nil
+}
+
+// ResolveRoutes buckets keys by the shard they are placed on, scoped to client
+// application, using the same placement as ResolveRoute. Duplicate keys are
+// listed once.
+func (r *Router) ResolveRoutes(keys []string, clientAppID string) (map[string][]string, error) {
+	buckets := make(map[string][]string)
+	seen := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		route, err := r.ResolveRoute(key, clientAppID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to route key %q: %w", key, err)
+		}
+		buckets[route.ShardID] = append(buckets[route.ShardID], key)
+	}
+	return buckets, nil
+}
+
+// publicEndpoint strips the password from a connection string
+func publicEndpoint(dsn string) string {
+	if u, err := url.Parse(dsn); err == nil && u.Scheme != "" {
+		if _, hasPassword := u.User.Password(); hasPassword {
+			u.User = url.User(u.User.Username())
+		}
+		return u.String()
+	}
+
+	// key=value connection strings
+	fields := strings.Fields(dsn)
+	kept := fields[:0]
+	for _, field := range fields {
+		if !strings.HasPrefix(field, "password=") {
+			kept = append(kept, field)
+		}
+	}
+	return strings.Join(kept, " ")
 }
 
 // GetShardForKey returns the shard ID for a given key, scoped to client application
@@ -149,21 +547,99 @@ func (r *Router) GetShardForKey(key string, clientAppID string) (string, error)
 	return shard.ID, nil
 }
 
+// Refresh forces a catalog reload and closes connection pools for endpoints
+// that no longer belong to any shard. It returns the number of shards known
+// after the reload.
+func (r *Router) Refresh(ctx context.Context) (int, error) {
+	if err := r.catalog.Reload(); err != nil {
+		return 0, fmt.Errorf("failed to reload catalog: %w", err)
+	}
+
+	shards, err := r.catalog.ListShards("")
+	if err != nil {
+		return 0, fmt.Errorf("failed to list shards: %w", err)
+	}
+
+	r.closeStaleConnections(shards)
+
+	r.logger.Info("router refreshed from catalog", zap.Int("shard_count", len(shards)))
+	return len(shards), nil
+}
+
+// WatchCatalog follows catalog changes until ctx is done, closing the pools of
+// endpoints that left the catalog. A shard whose credentials are rotated gets
+// a new endpoint DSN, so its next query opens a pool with the new
+// credentials and the pool holding the old ones is retired.
+func (r *Router) WatchCatalog(ctx context.Context) {
+	updates, err := r.catalog.Watch(ctx)
+	if err != nil {
+		r.logger.Error("failed to watch catalog", zap.Error(err))
+		return
+	}
+	for update := range updates {
+		r.closeStaleConnections(update.Shards)
+	}
+}
+
+// closeStaleConnections closes the pools of endpoints that no longer belong to
+// any of shards. Pools are closed outside the lock, as closing waits for the
+// queries already running on them.
+func (r *Router) closeStaleConnections(shards []models.Shard) {
+	live := make(map[string]bool)
+	for _, shard := range shards {
+		live[shard.PrimaryEndpoint] = true
+		for _, replica := range shard.Replicas {
+			live[replica] = true
+		}
+	}
+
+	stale := make(map[string]*sql.DB)
+	r.mu.Lock()
+	for endpoint, db := range r.connections {
+		if live[endpoint] {
+			continue
+		}
+		stale[endpoint] = db
+		delete(r.connections, endpoint)
+	}
+	for endpoint := range r.latencies {
+		if !live[endpoint] {
+			delete(r.latencies, endpoint)
+		}
+	}
+	r.mu.Unlock()
+
+	for endpoint, db := range stale {
+		if err := db.Close(); err != nil {
+			r.logger.Warn("failed to close stale connection", zap.String("endpoint", models.EndpointLabel(endpoint)), zap.Error(err))
+		}
+	}
+}
+
 // getConnection gets or creates a database connection pool
-func (r *Router) getConnection(endpoint string) (*sql.DB, error) {
+func (r *Router) getConnection(ctx context.Context, endpoint string) (*sql.DB, error) {
 	r.mu.RLock()
 	db, exists := r.connections[endpoint]
 	r.mu.RUnlock()
 
 	if exists {
-		// Check if connection is still alive
-		if err := db.Ping(); err == nil {
+		// Check if connection is still alive. The check has its own timeout: a
+		// request that is cancelled or out of time says nothing about the pool.
+		pingCtx, cancel := context.WithTimeout(context.Background(), pingTimeout)
+		err := db.PingContext(pingCtx)
+		cancel()
+		if err == nil {
 			return db, nil
 		}
-		// Connection is dead, remove it
+
+		// Connection is dead, remove it unless another request already replaced it
 		r.mu.Lock()
-		delete(r.connections, endpoint)
+		if r.connections[endpoint] == db {
+			delete(r.connections, endpoint)
+		}
 		r.mu.Unlock()
+		r.logger.Warn("closing dead connection pool", zap.String("endpoint", models.EndpointLabel(endpoint)), zap.Error(err))
+		db.Close()
 	}
 
 	// Create new connection
@@ -175,7 +651,7 @@ func (r *Router) getConnection(endpoint string) (*sql.DB, error) {
 		return db, nil
 	}
 
-	db, err := sql.Open("postgres", endpoint)
+	db, err := sql.Open(r.driver, endpoint)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
@@ -185,7 +661,7 @@ func (r *Router) getConnection(endpoint string) (*sql.DB, error) {
 	db.SetConnMaxLifetime(r.connTTL)
 
 	// Test connection
-	if err := db.Ping(); err != nil {
+	if err := db.PingContext(ctx); err != nil {
 		db.Close()
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
@@ -0,0 +1,206 @@
+package router
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sharding-system/pkg/models"
+	"go.uber.org/zap"
+)
+
+// LSN is a PostgreSQL write-ahead log position
+type LSN uint64
+
+// ParseLSN parses a WAL position in PostgreSQL's "16/B374D848" notation
+func ParseLSN(s string) (LSN, error) {
+	hi, lo, ok := strings.Cut(s, "/")
+	if !ok {
+		return 0, fmt.Errorf("invalid WAL position %q", s)
+	}
+	h, err := strconv.ParseUint(hi, 16, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid WAL position %q", s)
+	}
+	l, err := strconv.ParseUint(lo, 16, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid WAL position %q", s)
+	}
+	return LSN(h<<32 | l), nil
+}
+
+// String formats the position the way PostgreSQL does
+func (l LSN) String() string {
+	return fmt.Sprintf("%X/%X", uint64(l)>>32, uint64(l)&0xFFFFFFFF)
+}
+
+// SessionToken records the WAL position a write reached on a shard's
+// primary. A read carrying it is only served by a replica of that shard that
+// has replayed up to the position, so a client always sees its own writes.
+type SessionToken struct {
+	ShardID string
+	LSN     LSN
+}
+
+// String encodes the token as "<shard_id>@<lsn>"
+func (t SessionToken) String() string {
+	return t.ShardID + "@" + t.LSN.String()
+}
+
+// ParseSessionToken decodes a token produced by SessionToken.String
+func ParseSessionToken(s string) (SessionToken, error) {
+	i := strings.LastIndex(s, "@")
+	if i <= 0 {
+		return SessionToken{}, fmt.Errorf("invalid session_token %q", s)
+	}
+	lsn, err := ParseLSN(s[i+1:])
+	if err != nil {
+		return SessionToken{}, fmt.Errorf("invalid session_token %q: %w", s, err)
+	}
+	return SessionToken{ShardID: s[:i], LSN: lsn}, nil
+}
+
+// WALPositionSource reads WAL positions from shard endpoints
+type WALPositionSource interface {
+	// WritePosition returns the primary's current WAL write position
+	WritePosition(ctx context.Context, endpoint string) (LSN, error)
+	// ReplayPosition returns the position a replica has replayed up to
+	ReplayPosition(ctx context.Context, endpoint string) (LSN, error)
+}
+
+// SetWALPositionSource replaces where WAL positions for session tokens are
+// read from. By default they are queried from the endpoints themselves.
+func (r *Router) SetWALPositionSource(source WALPositionSource) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.walPositions = source
+}
+
+// walPositionSource returns the configured WAL position source
+func (r *Router) walPositionSource() WALPositionSource {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.walPositions != nil {
+		return r.walPositions
+	}
+	return &pgWALPositions{router: r}
+}
+
+// selectSessionEndpoint is selectEndpoint for reads carrying a session token.
+// Replicas of the token's shard that have not replayed up to its position are
+// skipped; when none has, the read goes to the primary.
+func (r *Router) selectSessionEndpoint(ctx context.Context, shard *models.Shard, consistency string, maxStaleness time.Duration, token SessionToken) string {
+	if consistency != "eventual" {
+		return r.selectEndpoint(shard, consistency, maxStaleness)
+	}
+	return r.selectEndpoint(r.caughtUp(ctx, shard, token), consistency, maxStaleness)
+}
+
+// caughtUp returns the shard with only the replicas that have replayed up to
+// the session token's position, or the shard itself for a token of another
+// shard. The replicas' positions are read concurrently.
+func (r *Router) caughtUp(ctx context.Context, shard *models.Shard, token SessionToken) *models.Shard {
+	if token.ShardID != shard.ID || len(shard.Replicas) == 0 {
+		return shard
+	}
+
+	positions := r.walPositionSource()
+	replayed := make([]bool, len(shard.Replicas))
+	var wg sync.WaitGroup
+	for i, replica := range shard.Replicas {
+		wg.Add(1)
+		go func(i int, replica string) {
+			defer wg.Done()
+			position, err := positions.ReplayPosition(ctx, replica)
+			if err != nil {
+				r.logger.Debug("failed to read replica replay position",
+					zap.String("shard_id", shard.ID),
+					zap.String("endpoint", publicEndpoint(replica)),
+					zap.Error(err))
+				return
+			}
+			replayed[i] = position >= token.LSN
+		}(i, replica)
+	}
+	wg.Wait()
+
+	caughtUp := make([]string, 0, len(shard.Replicas))
+	for i, replica := range shard.Replicas {
+		if replayed[i] {
+			caughtUp = append(caughtUp, replica)
+		}
+	}
+	if len(caughtUp) == 0 {
+		r.logger.Debug("no replica has replayed the session's writes",
+			zap.String("shard_id", shard.ID),
+			zap.Stringer("lsn", token.LSN))
+	}
+
+	eligible := *shard
+	eligible.Replicas = caughtUp
+	return &eligible
+}
+
+// sessionTokenFor returns the token for a write that ran on a shard's
+// primary, or an empty string if the position cannot be read. MySQL shards
+// have no WAL position and never get one.
+func (r *Router) sessionTokenFor(ctx context.Context, shard *models.Shard) string {
+	if models.NormalizeEngine(shard.Engine) == models.EngineMySQL {
+		r.mysqlSessionWarning.Do(func() {
+			r.logger.Warn("session tokens are not supported on MySQL shards, reads after writes may be stale on their replicas",
+				zap.String("shard_id", shard.ID))
+		})
+		return ""
+	}
+	lsn, err := r.walPositionSource().WritePosition(ctx, shard.PrimaryEndpoint)
+	if err != nil {
+		r.logger.Warn("failed to read WAL position for session token",
+			zap.String("shard_id", shard.ID),
+			zap.Error(err))
+		return ""
+	}
+	return SessionToken{ShardID: shard.ID, LSN: lsn}.String()
+}
+
+// isReadOnlyQuery reports whether a statement only reads. Anything else,
+// including WITH queries that may modify data, is treated as a write.
+func isReadOnlyQuery(query string) bool {
+	fields := strings.Fields(query)
+	if len(fields) == 0 {
+		return true
+	}
+	switch strings.ToUpper(strings.TrimLeft(fields[0], "(")) {
+	case "SELECT", "SHOW", "EXPLAIN", "VALUES", "TABLE":
+		return true
+	}
+	return false
+}
+
+// pgWALPositions reads WAL positions with PostgreSQL's WAL functions
+type pgWALPositions struct {
+	router *Router
+}
+
+func (p *pgWALPositions) WritePosition(ctx context.Context, endpoint string) (LSN, error) {
+	return p.query(ctx, endpoint, "SELECT pg_current_wal_lsn()::text")
+}
+
+func (p *pgWALPositions) ReplayPosition(ctx context.Context, endpoint string) (LSN, error) {
+	// NULL, and so a scan error, on a server that is not in recovery
+	return p.query(ctx, endpoint, "SELECT pg_last_wal_replay_lsn()::text")
+}
+
+func (p *pgWALPositions) query(ctx context.Context, endpoint, query string) (LSN, error) {
+	db, err := p.router.getConnection(ctx, endpoint)
+	if err != nil {
+		return 0, err
+	}
+	var position string
+	if err := db.QueryRowContext(ctx, query).Scan(&position); err != nil {
+		return 0, err
+	}
+	return ParseLSN(position)
+}
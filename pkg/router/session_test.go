@@ -0,0 +1,154 @@
+package router
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/sharding-system/pkg/config"
+	"github.com/sharding-system/pkg/models"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// mockWALPositions reports fixed WAL positions; endpoints without an entry fail
+type mockWALPositions map[string]LSN
+
+func (m mockWALPositions) WritePosition(ctx context.Context, endpoint string) (LSN, error) {
+	return m.ReplayPosition(ctx, endpoint)
+}
+
+func (m mockWALPositions) ReplayPosition(ctx context.Context, endpoint string) (LSN, error) {
+	lsn, ok := m[endpoint]
+	if !ok {
+		return 0, fmt.Errorf("no position for %s", endpoint)
+	}
+	return lsn, nil
+}
+
+func TestSessionToken_RoundTrip(t *testing.T) {
+	lsn, err := ParseLSN("16/B374D848")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if lsn != 0x16B374D848 || lsn.String() != "16/B374D848" {
+		t.Errorf("expected 16/B374D848, got %s (%d)", lsn, lsn)
+	}
+
+	token, err := ParseSessionToken(SessionToken{ShardID: "orders@eu", LSN: lsn}.String())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if token.ShardID != "orders@eu" || token.LSN != lsn {
+		t.Errorf("expected the token to round trip, got %+v", token)
+	}
+
+	for _, invalid := range []string{"", "shard-1", "@0/1", "shard-1@16", "shard-1@x/1"} {
+		if _, err := ParseSessionToken(invalid); err == nil {
+			t.Errorf("expected %q to be rejected", invalid)
+		}
+	}
+}
+
+func TestRouter_SelectSessionEndpoint_SkipsLaggingReplicas(t *testing.T) {
+	r := NewRouter(NewMockCatalog(), zaptest.NewLogger(t), 10, time.Minute, "replica_ok", config.PricingConfig{})
+	r.SetWALPositionSource(mockWALPositions{
+		"replica-behind": 0x100,
+		"replica-ahead":  0x300,
+	})
+	shard := &models.Shard{
+		ID:              "shard-1",
+		PrimaryEndpoint: "primary",
+		Replicas:        []string{"replica-unknown", "replica-behind", "replica-ahead"},
+	}
+	ctx := context.Background()
+
+	tests := []struct {
+		name  string
+		token SessionToken
+		want  string
+	}{
+		{"replica past the write serves the read", SessionToken{ShardID: "shard-1", LSN: 0x200}, "replica-ahead"},
+		{"replica exactly at the write serves the read", SessionToken{ShardID: "shard-1", LSN: 0x100}, "replica-behind"},
+		{"no replica at the write falls back to primary", SessionToken{ShardID: "shard-1", LSN: 0x400}, "primary"},
+		{"token for another shard does not constrain", SessionToken{ShardID: "shard-2", LSN: 0x400}, "replica-unknown"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := r.selectSessionEndpoint(ctx, shard, "eventual", 0, tt.token); got != tt.want {
+				t.Errorf("expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+
+	// Replicas are filtered on a copy of the shard
+	if len(shard.Replicas) != 3 {
+		t.Errorf("expected the shard's replicas to be left unchanged, got %v", shard.Replicas)
+	}
+	if got := r.selectSessionEndpoint(ctx, shard, "strong", 0, SessionToken{ShardID: "shard-1"}); got != "primary" {
+		t.Errorf("expected strong reads to use the primary, got %s", got)
+	}
+}
+
+func TestRouter_SessionTokenFor(t *testing.T) {
+	r := NewRouter(NewMockCatalog(), zaptest.NewLogger(t), 10, time.Minute, "replica_ok", config.PricingConfig{})
+	r.SetWALPositionSource(mockWALPositions{"primary": 0x2A0000001})
+
+	shard := &models.Shard{ID: "shard-1", PrimaryEndpoint: "primary"}
+	if got := r.sessionTokenFor(context.Background(), shard); got != "shard-1@2/A0000001" {
+		t.Errorf("expected shard-1@2/A0000001, got %s", got)
+	}
+	shard.PrimaryEndpoint = "unreachable"
+	if got := r.sessionTokenFor(context.Background(), shard); got != "" {
+		t.Errorf("expected no token when the position cannot be read, got %s", got)
+	}
+}
+
+func TestRouter_SessionTokenFor_MySQLWarnsOnce(t *testing.T) {
+	core, logs := observer.New(zap.WarnLevel)
+	r := NewRouter(NewMockCatalog(), zap.New(core), 10, time.Minute, "replica_ok", config.PricingConfig{})
+	r.SetWALPositionSource(mockWALPositions{"primary": 0x2A0000001})
+
+	shard := &models.Shard{ID: "shard-1", Engine: models.EngineMySQL, PrimaryEndpoint: "primary"}
+	for i := 0; i < 3; i++ {
+		if got := r.sessionTokenFor(context.Background(), shard); got != "" {
+			t.Errorf("expected no token for a MySQL shard, got %s", got)
+		}
+	}
+	if got := logs.Len(); got != 1 {
+		t.Errorf("expected one warning for MySQL shards, got %d", got)
+	}
+}
+
+func TestRouter_ExecuteQuery_RejectsInvalidSessionToken(t *testing.T) {
+	r := NewRouter(NewMockCatalog(), zaptest.NewLogger(t), 10, time.Minute, "replica_ok", config.PricingConfig{})
+
+	_, err := r.ExecuteQuery(context.Background(), &models.QueryRequest{
+		ShardKey:     "key",
+		Query:        "SELECT 1",
+		Consistency:  "eventual",
+		SessionToken: "not-a-token",
+	}, "app1")
+	if err == nil {
+		t.Error("expected an invalid session token to be rejected")
+	}
+}
+
+func TestIsReadOnlyQuery(t *testing.T) {
+	cases := map[string]bool{
+		"SELECT * FROM users":                true,
+		"  select 1":                         true,
+		"(SELECT 1) UNION (SELECT 2)":        true,
+		"EXPLAIN SELECT 1":                   true,
+		"INSERT INTO users VALUES (1)":       false,
+		"update users SET name = 'x'":        false,
+		"WITH d AS (DELETE FROM t) SELECT 1": false,
+	}
+	for query, want := range cases {
+		if got := isReadOnlyQuery(query); got != want {
+			t.Errorf("isReadOnlyQuery(%q): expected %v, got %v", query, want, got)
+		}
+	}
+}
@@ -0,0 +1,307 @@
+package router
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sharding-system/pkg/hashing"
+	"github.com/sharding-system/pkg/keyrange"
+	"github.com/sharding-system/pkg/logging"
+	"github.com/sharding-system/pkg/models"
+	"github.com/sharding-system/pkg/observability"
+	"go.uber.org/zap"
+)
+
+const (
+	// maxShadowReadsInFlight bounds the shadow reads running at once. Reads
+	// sampled while the limit is reached are dropped, so a slow target never
+	// piles up work on the router.
+	maxShadowReadsInFlight = 32
+	// defaultShadowReadTimeout bounds a shadow read when no statement timeout is set
+	defaultShadowReadTimeout = 5 * time.Second
+	// maxShadowMismatches is how many recent mismatches are kept for inspection
+	maxShadowMismatches = 50
+)
+
+// ShadowMismatch is a read whose result on a split target differed from the
+// result served from the source shard
+type ShadowMismatch struct {
+	SourceShardID string    `json:"source_shard_id"`
+	TargetShardID string    `json:"target_shard_id"`
+	ShardKey      string    `json:"shard_key"`
+	Query         string    `json:"query"`
+	SourceRows    int       `json:"source_rows"`
+	TargetRows    int       `json:"target_rows"`
+	Error         string    `json:"error,omitempty"` // Set when the target failed the read
+	At            time.Time `json:"at"`
+}
+
+// ShadowReadStats summarizes shadow reads since the router started
+type ShadowReadStats struct {
+	Percent    float64          `json:"percent"`
+	Issued     int64            `json:"issued"`
+	Matched    int64            `json:"matched"`
+	Mismatched int64            `json:"mismatched"`
+	Failed     int64            `json:"failed"`
+	Dropped    int64            `json:"dropped"` // Sampled while too many shadow reads were running
+	Recent     []ShadowMismatch `json:"recent_mismatches"`
+}
+
+// shadowReads mirrors a sample of reads to the target shards of splits in
+// progress. Results served to clients always come from the source shard; the
+// copy on the target is only compared with it, so operators can validate the
+// targets before cutover.
+type shadowReads struct {
+	percent  float64
+	inFlight chan struct{}
+	rand     *rand.Rand
+
+	mu    sync.Mutex
+	stats ShadowReadStats
+}
+
+func newShadowReads(percent float64) *shadowReads {
+	return &shadowReads{
+		percent:  percent,
+		inFlight: make(chan struct{}, maxShadowReadsInFlight),
+		rand:     rand.New(rand.NewSource(time.Now().UnixNano())),
+		stats:    ShadowReadStats{Percent: percent},
+	}
+}
+
+// sample reports whether a read should be mirrored
+func (s *shadowReads) sample() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rand.Float64()*100 < s.percent
+}
+
+// record counts the outcome of a shadow read
+func (s *shadowReads) record(mismatch *ShadowMismatch, failed bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch {
+	case mismatch == nil:
+		s.stats.Matched++
+		return
+	case failed:
+		s.stats.Failed++
+	default:
+		s.stats.Mismatched++
+	}
+	s.stats.Recent = append(s.stats.Recent, *mismatch)
+	if len(s.stats.Recent) > maxShadowMismatches {
+		s.stats.Recent = s.stats.Recent[len(s.stats.Recent)-maxShadowMismatches:]
+	}
+}
+
+// SetShadowReads mirrors percent of the reads served by a shard being split
+// to the split's target shard for the key, comparing the results. Mismatches
+// are logged, counted in router_shadow_reads_total and kept for
+// ShadowReadStats. 0 turns shadow reads off.
+func (r *Router) SetShadowReads(percent float64) {
+	var shadow *shadowReads
+	if percent > 0 {
+		shadow = newShadowReads(percent)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.shadow = shadow
+}
+
+// ShadowReadStats returns the outcome of shadow reads since they were turned
+// on. Percent is 0 while they are off.
+func (r *Router) ShadowReadStats() ShadowReadStats {
+	r.mu.RLock()
+	shadow := r.shadow
+	r.mu.RUnlock()
+	if shadow == nil {
+		return ShadowReadStats{Recent: []ShadowMismatch{}}
+	}
+
+	shadow.mu.Lock()
+	defer shadow.mu.Unlock()
+	stats := shadow.stats
+	stats.Recent = append([]ShadowMismatch{}, shadow.stats.Recent...)
+	return stats
+}
+
+// shadowRead mirrors a read served from shard to the split target that will
+// own its key, if the read is sampled. It returns at once; the mirrored read
+// runs in the background so the client's response is never held up.
+func (r *Router) shadowRead(ctx context.Context, shard *models.Shard, req *models.QueryRequest, rows []interface{}) {
+	r.mu.RLock()
+	shadow, stmtTimeout := r.shadow, r.stmtTimeout
+	r.mu.RUnlock()
+	if shadow == nil || shard.Status != models.ShardStatusActive || !isReadOnlyQuery(req.Query) || !shadow.sample() {
+		return
+	}
+
+	target := r.shadowTarget(shard, req.ShardKey)
+	if target == nil {
+		return
+	}
+
+	select {
+	case shadow.inFlight <- struct{}{}:
+	default:
+		shadow.mu.Lock()
+		shadow.stats.Dropped++
+		shadow.mu.Unlock()
+		observability.ShadowReads.WithLabelValues(shard.ID, target.ID, "dropped").Inc()
+		return
+	}
+	shadow.mu.Lock()
+	shadow.stats.Issued++
+	shadow.mu.Unlock()
+
+	timeout := stmtTimeout
+	if timeout <= 0 {
+		timeout = defaultShadowReadTimeout
+	}
+	logger := logging.WithRequestID(ctx, r.logger)
+
+	go func() {
+		defer func() { <-shadow.inFlight }()
+
+		// Detached from the request, which ends as soon as the client has its rows
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+
+		mismatch, err := r.compareShadowRead(ctx, shard, target, req, rows)
+		result := "match"
+		switch {
+		case err != nil:
+			result = "error"
+			logger.Warn("shadow read failed on split target",
+				zap.String("source_shard", shard.ID),
+				zap.String("target_shard", target.ID),
+				zap.Error(err))
+		case mismatch != nil:
+			result = "mismatch"
+			logger.Warn("shadow read result differs on split target",
+				zap.String("source_shard", shard.ID),
+				zap.String("target_shard", target.ID),
+				zap.String("shard_key", req.ShardKey),
+				zap.Int("source_rows", mismatch.SourceRows),
+				zap.Int("target_rows", mismatch.TargetRows))
+		}
+		shadow.record(mismatch, err != nil)
+		observability.ShadowReads.WithLabelValues(shard.ID, target.ID, result).Inc()
+	}()
+}
+
+// compareShadowRead runs a read on a split target and compares its rows with
+// those the source shard returned. Rows are compared regardless of order,
+// since queries without ORDER BY may return them in any. It returns the
+// mismatch, if any; a target error is returned alongside a mismatch
+// recording it.
+func (r *Router) compareShadowRead(ctx context.Context, source, target *models.Shard, req *models.QueryRequest, sourceRows []interface{}) (*ShadowMismatch, error) {
+	mismatch := &ShadowMismatch{
+		SourceShardID: source.ID,
+		TargetShardID: target.ID,
+		ShardKey:      req.ShardKey,
+		Query:         req.Query,
+		SourceRows:    len(sourceRows),
+		At:            time.Now(),
+	}
+
+	targetRows, err := r.queryRows(ctx, target.PrimaryEndpoint, req.Query, req.Params)
+	if err != nil {
+		mismatch.Error = err.Error()
+		return mismatch, err
+	}
+	mismatch.TargetRows = len(targetRows)
+
+	if len(targetRows) != len(sourceRows) {
+		return mismatch, nil
+	}
+	want, got := canonicalRows(sourceRows), canonicalRows(targetRows)
+	for i := range want {
+		if want[i] != got[i] {
+			return mismatch, nil
+		}
+	}
+	return nil, nil
+}
+
+// queryRows runs a query on an endpoint and returns its rows as served to clients
+func (r *Router) queryRows(ctx context.Context, endpoint, query string, params []interface{}) ([]interface{}, error) {
+	db, err := r.getConnection(ctx, endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get connection: %w", err)
+	}
+	rows, err := db.QueryContext(ctx, query, params...)
+	if err != nil {
+		return nil, fmt.Errorf("query execution failed: %w", err)
+	}
+	defer rows.Close()
+	return scanRows(rows)
+}
+
+// canonicalRows renders rows as sorted strings, so result sets compare equal
+// whatever order their rows came in. fmt prints maps with sorted keys.
+func canonicalRows(rows []interface{}) []string {
+	rendered := make([]string, len(rows))
+	for i, row := range rows {
+		rendered[i] = fmt.Sprintf("%v", row)
+	}
+	sort.Strings(rendered)
+	return rendered
+}
+
+// shadowTarget returns the split target that will own key once a split of
+// shard cuts over, or nil when the shard is not being split. Split targets
+// are the migrating shards of the same client app: for range shards the one
+// whose key range holds the key, for hash shards the one the resharder copies
+// the key to.
+func (r *Router) shadowTarget(shard *models.Shard, key string) *models.Shard {
+	shards, err := r.catalog.ListShards(shard.ClientAppID)
+	if err != nil {
+		return nil
+	}
+
+	targets := make([]*models.Shard, 0)
+	for i := range shards {
+		candidate := &shards[i]
+		if candidate.ID == shard.ID || candidate.ClientAppID != shard.ClientAppID || candidate.Status != models.ShardStatusMigrating {
+			continue
+		}
+		if (candidate.Strategy == "range") != (shard.Strategy == "range") {
+			continue
+		}
+		if shard.Strategy == "range" {
+			if keyrange.Contains(candidate.Collation, candidate.KeyRangeStart, candidate.KeyRangeEnd, key) {
+				return candidate
+			}
+			continue
+		}
+		targets = append(targets, candidate)
+	}
+	if len(targets) == 0 {
+		return nil
+	}
+
+	// The same placement the resharder copies rows with, on the hash
+	// function the manager keeps for every shard of the app
+	ring := hashing.NewConsistentHash(hashing.NewHashFunction(shard.HashFunction))
+	for _, target := range targets {
+		vnodeCount := len(target.VNodes)
+		if vnodeCount == 0 {
+			vnodeCount = 256 // default
+		}
+		ring.AddShard(target.ID, vnodeCount)
+	}
+	targetID := ring.GetShard(key)
+	for _, target := range targets {
+		if target.ID == targetID {
+			return target
+		}
+	}
+	return nil
+}
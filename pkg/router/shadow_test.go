@@ -0,0 +1,261 @@
+package router
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sharding-system/pkg/config"
+	"github.com/sharding-system/pkg/hashing"
+	"github.com/sharding-system/pkg/models"
+	"go.uber.org/zap/zaptest"
+)
+
+// cannedDriver answers every query on an endpoint with the endpoint's rows
+// and records the queries it was sent
+type cannedDriver struct {
+	mu      sync.Mutex
+	rows    map[string][][]driver.Value
+	queries map[string][]string
+}
+
+var testCannedDriver = &cannedDriver{
+	rows:    make(map[string][][]driver.Value),
+	queries: make(map[string][]string),
+}
+
+func init() {
+	sql.Register("routercanned", testCannedDriver)
+}
+
+// serve sets the rows an endpoint answers with, each an id and a name
+func (d *cannedDriver) serve(endpoint string, rows ...[]driver.Value) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.rows[endpoint] = rows
+	d.queries[endpoint] = nil
+}
+
+// received returns the queries an endpoint was sent
+func (d *cannedDriver) received(endpoint string) []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]string(nil), d.queries[endpoint]...)
+}
+
+func (d *cannedDriver) Open(name string) (driver.Conn, error) {
+	return &cannedConn{driver: d, endpoint: name}, nil
+}
+
+type cannedConn struct {
+	driver   *cannedDriver
+	endpoint string
+}
+
+func (c *cannedConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("prepare not supported")
+}
+
+func (c *cannedConn) Close() error { return nil }
+
+func (c *cannedConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("transactions not supported")
+}
+
+func (c *cannedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	c.driver.mu.Lock()
+	defer c.driver.mu.Unlock()
+	c.driver.queries[c.endpoint] = append(c.driver.queries[c.endpoint], query)
+	rows, ok := c.driver.rows[c.endpoint]
+	if !ok {
+		return nil, errors.New("connection refused")
+	}
+	return &cannedRows{rows: rows}, nil
+}
+
+type cannedRows struct {
+	rows [][]driver.Value
+	next int
+}
+
+func (r *cannedRows) Columns() []string { return []string{"id", "name"} }
+
+func (r *cannedRows) Close() error { return nil }
+
+func (r *cannedRows) Next(dest []driver.Value) error {
+	if r.next >= len(r.rows) {
+		return io.EOF
+	}
+	copy(dest, r.rows[r.next])
+	r.next++
+	return nil
+}
+
+// splitCatalog routes every key to the source shard of a split in progress
+type splitCatalog struct {
+	*MockCatalog
+}
+
+func (c *splitCatalog) GetShard(key string, clientAppID string) (*models.Shard, error) {
+	return c.GetShardByID("orders")
+}
+
+// newSplittingRouter returns a router in front of a range shard being split
+// at "m" into two migrating targets
+func newSplittingRouter(t *testing.T) *Router {
+	t.Helper()
+	catalog := &splitCatalog{MockCatalog: NewMockCatalog()}
+	for _, shard := range []*models.Shard{
+		{ID: "orders", Status: models.ShardStatusActive},
+		{ID: "orders-a", Status: models.ShardStatusMigrating, KeyRangeEnd: "m"},
+		{ID: "orders-b", Status: models.ShardStatusMigrating, KeyRangeStart: "m"},
+	} {
+		shard.ClientAppID, shard.Strategy = "app1", "range"
+		shard.PrimaryEndpoint = t.Name() + "/" + shard.ID
+		catalog.CreateShard(shard)
+	}
+
+	router := NewRouter(catalog, zaptest.NewLogger(t), 10, 5*time.Minute, "primary_only", config.PricingConfig{Tier: "enterprise"})
+	router.driver = "routercanned"
+	t.Cleanup(func() { router.Close() })
+	return router
+}
+
+// waitForShadowReads waits until every issued shadow read has finished
+func waitForShadowReads(t *testing.T, router *Router, issued int64) ShadowReadStats {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		stats := router.ShadowReadStats()
+		if stats.Issued >= issued && stats.Matched+stats.Mismatched+stats.Failed == stats.Issued {
+			return stats
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("shadow reads did not finish: %+v", stats)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestRouter_ShadowReads_MirrorsToSplitTarget(t *testing.T) {
+	router := newSplittingRouter(t)
+	router.SetShadowReads(100)
+	source, target, other := t.Name()+"/orders", t.Name()+"/orders-b", t.Name()+"/orders-a"
+	testCannedDriver.serve(source, []driver.Value{int64(1), "pen"}, []driver.Value{int64(2), "ink"})
+	testCannedDriver.serve(target, []driver.Value{int64(2), "ink"}, []driver.Value{int64(1), "pen"})
+	testCannedDriver.serve(other)
+
+	resp, err := router.ExecuteQuery(context.Background(), &models.QueryRequest{ShardKey: "victor", Query: "SELECT id, name FROM orders WHERE customer = $1"}, "app1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.ShardID != "orders" || resp.RowCount != 2 {
+		t.Fatalf("expected the source shard's 2 rows, got %d from %s", resp.RowCount, resp.ShardID)
+	}
+
+	stats := waitForShadowReads(t, router, 1)
+	if stats.Matched != 1 || stats.Mismatched != 0 || len(stats.Recent) != 0 {
+		t.Errorf("expected rows in a different order to match, got %+v", stats)
+	}
+	if got := testCannedDriver.received(target); len(got) != 1 {
+		t.Errorf("expected the target owning the key to get the read, got %v", got)
+	}
+	if got := testCannedDriver.received(other); len(got) != 0 {
+		t.Errorf("expected the other target to get nothing, got %v", got)
+	}
+}
+
+func TestRouter_ShadowReads_RecordsMismatches(t *testing.T) {
+	router := newSplittingRouter(t)
+	router.SetShadowReads(100)
+	source, target := t.Name()+"/orders", t.Name()+"/orders-a"
+	testCannedDriver.serve(source, []driver.Value{int64(1), "pen"}, []driver.Value{int64(2), "ink"})
+	testCannedDriver.serve(target, []driver.Value{int64(1), "pencil"}, []driver.Value{int64(2), "ink"})
+
+	req := &models.QueryRequest{ShardKey: "alice", Query: "SELECT id, name FROM orders WHERE customer = $1"}
+	resp, err := router.ExecuteQuery(context.Background(), req, "app1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if row := resp.Rows[0].(map[string]interface{}); row["name"] != "pen" {
+		t.Errorf("expected the client to get the source shard's rows, got %v", row)
+	}
+
+	stats := waitForShadowReads(t, router, 1)
+	if stats.Mismatched != 1 || len(stats.Recent) != 1 {
+		t.Fatalf("expected one mismatch, got %+v", stats)
+	}
+	mismatch := stats.Recent[0]
+	if mismatch.SourceShardID != "orders" || mismatch.TargetShardID != "orders-a" || mismatch.ShardKey != "alice" || mismatch.TargetRows != 2 {
+		t.Errorf("unexpected mismatch %+v", mismatch)
+	}
+
+	// A target that cannot serve the read is recorded too, without failing the client
+	testCannedDriver.mu.Lock()
+	delete(testCannedDriver.rows, target)
+	testCannedDriver.mu.Unlock()
+	if _, err := router.ExecuteQuery(context.Background(), req, "app1"); err != nil {
+		t.Fatalf("expected the client read to succeed, got %v", err)
+	}
+	stats = waitForShadowReads(t, router, 2)
+	if stats.Failed != 1 || len(stats.Recent) != 2 || stats.Recent[1].Error == "" {
+		t.Errorf("expected the failed shadow read to be recorded, got %+v", stats)
+	}
+}
+
+func TestRouter_ShadowReads_OnlyMirrorsSampledReads(t *testing.T) {
+	router := newSplittingRouter(t)
+	source, target := t.Name()+"/orders", t.Name()+"/orders-a"
+	testCannedDriver.serve(source, []driver.Value{int64(1), "pen"})
+	testCannedDriver.serve(target, []driver.Value{int64(1), "pen"})
+
+	// Off by default
+	if _, err := router.ExecuteQuery(context.Background(), &models.QueryRequest{ShardKey: "alice", Query: "SELECT * FROM orders"}, "app1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Writes are never mirrored
+	router.SetShadowReads(100)
+	if _, err := router.ExecuteQuery(context.Background(), &models.QueryRequest{ShardKey: "alice", Query: "UPDATE orders SET name = 'pen'"}, "app1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	if got := testCannedDriver.received(target); len(got) != 0 {
+		t.Errorf("expected no shadow reads, got %v", got)
+	}
+	if stats := router.ShadowReadStats(); stats.Issued != 0 || stats.Percent != 100 {
+		t.Errorf("expected no shadow reads issued, got %+v", stats)
+	}
+}
+
+func TestRouter_ShadowTarget_HashSplit(t *testing.T) {
+	catalog := NewMockCatalog()
+	source := &models.Shard{ID: "orders", ClientAppID: "app1", Status: models.ShardStatusActive, HashFunction: "crc32"}
+	catalog.CreateShard(source)
+	catalog.CreateShard(&models.Shard{ID: "orders-a", ClientAppID: "app1", Status: models.ShardStatusMigrating, HashFunction: "crc32"})
+	catalog.CreateShard(&models.Shard{ID: "orders-b", ClientAppID: "app1", Status: models.ShardStatusMigrating, HashFunction: "crc32"})
+	catalog.CreateShard(&models.Shard{ID: "billing-a", ClientAppID: "app2", Status: models.ShardStatusMigrating})
+	router := NewRouter(catalog, zaptest.NewLogger(t), 10, time.Minute, "primary", config.PricingConfig{})
+
+	// Keys go where the resharder copies them, on the app's hash function
+	ring := hashing.NewConsistentHash(hashing.NewHashFunction("crc32"))
+	ring.AddShard("orders-a", 256)
+	ring.AddShard("orders-b", 256)
+	for _, key := range []string{"user-1", "user-2", "user-3", "order-42"} {
+		target := router.shadowTarget(source, key)
+		if target == nil || target.ID != ring.GetShard(key) {
+			t.Errorf("key %q: expected target %s, got %v", key, ring.GetShard(key), target)
+		}
+	}
+
+	// Shards of other apps are never targets
+	if target := router.shadowTarget(&models.Shard{ID: "billing", ClientAppID: "app3"}, "user-1"); target != nil {
+		t.Errorf("expected no target for a shard without a split, got %s", target.ID)
+	}
+}
@@ -0,0 +1,72 @@
+package router
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sharding-system/pkg/config"
+	"github.com/sharding-system/pkg/models"
+	"go.uber.org/zap/zaptest"
+)
+
+// mockLagSource reports fixed replica lags; replicas without an entry are unknown
+type mockLagSource map[string]time.Duration
+
+func (m mockLagSource) ReplicaLag(endpoint string) (time.Duration, bool) {
+	lag, ok := m[endpoint]
+	return lag, ok
+}
+
+func TestRouter_SelectEndpoint_StalenessBudget(t *testing.T) {
+	r := NewRouter(NewMockCatalog(), zaptest.NewLogger(t), 10, time.Minute, "replica_ok", config.PricingConfig{})
+	r.SetLagSource(mockLagSource{
+		"replica-far":    30 * time.Second,
+		"replica-near":   200 * time.Millisecond,
+		"replica-recent": 2 * time.Second,
+	})
+	shard := &models.Shard{
+		ID:              "shard-1",
+		PrimaryEndpoint: "primary",
+		Replicas:        []string{"replica-unknown", "replica-far", "replica-recent", "replica-near"},
+	}
+
+	tests := []struct {
+		name         string
+		consistency  string
+		maxStaleness time.Duration
+		want         string
+	}{
+		{"no budget uses first replica", "eventual", 0, "replica-unknown"},
+		{"loose budget skips unknown and lagging replicas", "eventual", 5 * time.Second, "replica-recent"},
+		{"tight budget picks the only fresh replica", "eventual", 500 * time.Millisecond, "replica-near"},
+		{"budget no replica meets falls back to primary", "eventual", 50 * time.Millisecond, "primary"},
+		{"strong reads ignore replicas", "strong", time.Minute, "primary"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := r.selectEndpoint(shard, tt.consistency, tt.maxStaleness); got != tt.want {
+				t.Errorf("expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestRouter_SelectEndpoint_NoLagSource(t *testing.T) {
+	r := NewRouter(NewMockCatalog(), zaptest.NewLogger(t), 10, time.Minute, "replica_ok", config.PricingConfig{})
+	shard := &models.Shard{ID: "shard-1", PrimaryEndpoint: "primary", Replicas: []string{"replica"}}
+
+	if got := r.selectEndpoint(shard, "eventual", time.Minute); got != "primary" {
+		t.Errorf("expected reads with a budget to use the primary without lag data, got %s", got)
+	}
+}
+
+func TestRouter_ExecuteQuery_InvalidMaxStaleness(t *testing.T) {
+	r := NewRouter(NewMockCatalog(), zaptest.NewLogger(t), 10, time.Minute, "replica_ok", config.PricingConfig{Tier: "enterprise"})
+	req := &models.QueryRequest{ShardKey: "k", Query: "SELECT 1", Consistency: "eventual", MaxStaleness: "soon"}
+
+	if _, err := r.ExecuteQuery(context.Background(), req, "app"); err == nil || !strings.Contains(err.Error(), "max_staleness") {
+		t.Errorf("expected an error for an unparseable max_staleness, got %v", err)
+	}
+}
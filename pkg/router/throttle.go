@@ -0,0 +1,192 @@
+package router
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrPaused is returned while the kill switch holds all non-admin traffic
+var ErrPaused = errors.New("traffic paused")
+
+// IsPaused reports whether err was caused by the kill switch
+func IsPaused(err error) bool {
+	return errors.Is(err, ErrPaused)
+}
+
+// ThrottleSettings are the emergency limits operators set on a router during
+// traffic spikes and incidents
+type ThrottleSettings struct {
+	// GlobalRPS caps requests per second across all client apps; 0 is unlimited
+	GlobalRPS float64 `json:"global_rps"`
+	// ClientRPS caps requests per second of individual client apps
+	ClientRPS map[string]float64 `json:"client_rps,omitempty"`
+	// Paused sheds all non-admin traffic
+	Paused bool `json:"paused"`
+	// Reason is passed on to shed clients, e.g. an incident link
+	Reason string `json:"reason,omitempty"`
+}
+
+// Validate checks that the limits are not negative
+func (s ThrottleSettings) Validate() error {
+	if s.GlobalRPS < 0 || math.IsNaN(s.GlobalRPS) || math.IsInf(s.GlobalRPS, 0) {
+		return fmt.Errorf("global_rps must be a non-negative number, got %g", s.GlobalRPS)
+	}
+	apps := make([]string, 0, len(s.ClientRPS))
+	for app := range s.ClientRPS {
+		apps = append(apps, app)
+	}
+	sort.Strings(apps)
+	for _, app := range apps {
+		if app == "" {
+			return fmt.Errorf("client_rps keys must be client app IDs")
+		}
+		if rps := s.ClientRPS[app]; rps < 0 || math.IsNaN(rps) || math.IsInf(rps, 0) {
+			return fmt.Errorf("client_rps[%s] must be a non-negative number, got %g", app, rps)
+		}
+	}
+	return nil
+}
+
+// Throttle is a request rate limiter with a kill switch. Requests are
+// admitted against a global token bucket and, for client apps with their own
+// limit, a per-app bucket. Buckets hold one second of requests, so a new limit
+// caps the very next second of traffic. Settings can be changed at any time
+// and apply to the next request.
+type Throttle struct {
+	mu       sync.Mutex
+	settings ThrottleSettings
+	global   *tokenBucket
+	clients  map[string]*tokenBucket
+	now      func() time.Time
+}
+
+// NewThrottle creates a throttle that admits everything
+func NewThrottle() *Throttle {
+	return &Throttle{
+		clients: make(map[string]*tokenBucket),
+		now:     time.Now,
+	}
+}
+
+// Settings returns the throttle's current limits
+func (t *Throttle) Settings() ThrottleSettings {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	settings := t.settings
+	if len(t.settings.ClientRPS) > 0 {
+		settings.ClientRPS = make(map[string]float64, len(t.settings.ClientRPS))
+		for app, rps := range t.settings.ClientRPS {
+			settings.ClientRPS[app] = rps
+		}
+	}
+	return settings
+}
+
+// Update replaces the throttle's limits. Requests already admitted are not
+// affected; the next request is checked against the new limits. A bucket
+// whose limit is tightened keeps no more tokens than the new limit allows.
+func (t *Throttle) Update(settings ThrottleSettings) error {
+	if err := settings.Validate(); err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := t.now()
+
+	t.global = resizeBucket(t.global, settings.GlobalRPS, now)
+	clients := make(map[string]*tokenBucket, len(settings.ClientRPS))
+	clientRPS := make(map[string]float64, len(settings.ClientRPS))
+	for app, rps := range settings.ClientRPS {
+		clientRPS[app] = rps
+		if bucket := resizeBucket(t.clients[app], rps, now); bucket != nil {
+			clients[app] = bucket
+		}
+	}
+	settings.ClientRPS = clientRPS
+	t.clients = clients
+	t.settings = settings
+	return nil
+}
+
+// Allow admits one request of a client app, or returns why it is shed:
+// ErrPaused while the kill switch is on, ErrThrottled when a rate limit is
+// reached
+func (t *Throttle) Allow(clientAppID string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.settings.Paused {
+		return fmt.Errorf("%w%s", ErrPaused, t.reason())
+	}
+
+	now := t.now()
+	client := t.clients[clientAppID]
+	if client != nil && !client.available(now) {
+		return fmt.Errorf("%w: client app %s is limited to %g requests/s%s", ErrThrottled, clientAppID, client.rate, t.reason())
+	}
+	if t.global != nil && !t.global.available(now) {
+		return fmt.Errorf("%w: router is limited to %g requests/s%s", ErrThrottled, t.global.rate, t.reason())
+	}
+
+	// Only take tokens once both buckets admit the request
+	if client != nil {
+		client.tokens--
+	}
+	if t.global != nil {
+		t.global.tokens--
+	}
+	return nil
+}
+
+// reason formats the operator's reason for an error message.
+// Must be called with t.mu held.
+func (t *Throttle) reason() string {
+	if t.settings.Reason == "" {
+		return ""
+	}
+	return " (" + t.settings.Reason + ")"
+}
+
+// tokenBucket refills at rate tokens per second up to one second's worth,
+// with room for at least one request
+type tokenBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// resizeBucket returns a bucket for a new rate, carrying over the tokens of
+// the old one so changing the limit does not grant a fresh burst. A rate of 0
+// means unlimited and returns nil.
+func resizeBucket(old *tokenBucket, rate float64, now time.Time) *tokenBucket {
+	if rate <= 0 {
+		return nil
+	}
+	bucket := &tokenBucket{rate: rate, burst: math.Max(rate, 1), last: now}
+	bucket.tokens = bucket.burst
+	if old != nil {
+		old.refill(now)
+		bucket.tokens = math.Min(old.tokens, bucket.burst)
+	}
+	return bucket
+}
+
+// refill adds the tokens earned since the last refill
+func (b *tokenBucket) refill(now time.Time) {
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(b.burst, b.tokens+elapsed*b.rate)
+	}
+	b.last = now
+}
+
+// available refills the bucket and reports whether it holds a token
+func (b *tokenBucket) available(now time.Time) bool {
+	b.refill(now)
+	return b.tokens >= 1
+}
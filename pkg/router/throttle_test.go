@@ -0,0 +1,125 @@
+package router
+
+import (
+	"testing"
+	"time"
+)
+
+// newTestThrottle creates a throttle with a clock the test moves by hand
+func newTestThrottle() (*Throttle, *time.Time) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	throttle := NewThrottle()
+	throttle.now = func() time.Time { return now }
+	return throttle, &now
+}
+
+// admitted counts how many of n requests a client app gets through
+func admitted(throttle *Throttle, clientAppID string, n int) int {
+	count := 0
+	for i := 0; i < n; i++ {
+		if throttle.Allow(clientAppID) == nil {
+			count++
+		}
+	}
+	return count
+}
+
+func TestThrottle_AdmitsEverythingByDefault(t *testing.T) {
+	throttle, _ := newTestThrottle()
+	if got := admitted(throttle, "app-a", 1000); got != 1000 {
+		t.Errorf("expected all requests admitted, got %d", got)
+	}
+}
+
+func TestThrottle_GlobalLimitTakesEffectImmediately(t *testing.T) {
+	throttle, now := newTestThrottle()
+	admitted(throttle, "app-a", 100)
+
+	if err := throttle.Update(ThrottleSettings{GlobalRPS: 5}); err != nil {
+		t.Fatal(err)
+	}
+	if got := admitted(throttle, "app-a", 20); got != 5 {
+		t.Fatalf("expected 5 requests admitted in the first second, got %d", got)
+	}
+	err := throttle.Allow("app-b")
+	if !IsThrottled(err) || IsPaused(err) {
+		t.Fatalf("expected a throttled error, got %v", err)
+	}
+
+	*now = now.Add(400 * time.Millisecond)
+	if got := admitted(throttle, "app-b", 20); got != 2 {
+		t.Errorf("expected 2 requests admitted after 400ms at 5/s, got %d", got)
+	}
+
+	// Tightening further does not hand out a fresh burst
+	*now = now.Add(time.Second)
+	throttle.Update(ThrottleSettings{GlobalRPS: 1})
+	if got := admitted(throttle, "app-a", 20); got != 1 {
+		t.Errorf("expected 1 request admitted after tightening to 1/s, got %d", got)
+	}
+
+	// Lifting the limit admits everything again
+	throttle.Update(ThrottleSettings{})
+	if got := admitted(throttle, "app-a", 100); got != 100 {
+		t.Errorf("expected all requests admitted after lifting the limit, got %d", got)
+	}
+}
+
+func TestThrottle_PerClientLimit(t *testing.T) {
+	throttle, _ := newTestThrottle()
+	throttle.Update(ThrottleSettings{ClientRPS: map[string]float64{"noisy": 3}})
+
+	if got := admitted(throttle, "noisy", 10); got != 3 {
+		t.Errorf("expected the limited app to get 3 requests through, got %d", got)
+	}
+	if got := admitted(throttle, "quiet", 10); got != 10 {
+		t.Errorf("expected other apps to be unaffected, got %d", got)
+	}
+
+	// A request shed by the global limit does not use the app's budget
+	throttle.Update(ThrottleSettings{GlobalRPS: 1, ClientRPS: map[string]float64{"noisy": 3}})
+	admitted(throttle, "quiet", 1)
+	if err := throttle.Allow("noisy"); !IsThrottled(err) {
+		t.Fatalf("expected the global limit to shed the request, got %v", err)
+	}
+}
+
+func TestThrottle_KillSwitchTakesEffectImmediately(t *testing.T) {
+	throttle, _ := newTestThrottle()
+	throttle.Update(ThrottleSettings{Paused: true, Reason: "INC-42"})
+
+	err := throttle.Allow("app-a")
+	if !IsPaused(err) {
+		t.Fatalf("expected the kill switch to shed the request, got %v", err)
+	}
+	if got := err.Error(); got != "traffic paused (INC-42)" {
+		t.Errorf("expected the reason in the error, got %q", got)
+	}
+
+	throttle.Update(ThrottleSettings{})
+	if err := throttle.Allow("app-a"); err != nil {
+		t.Errorf("expected traffic to resume, got %v", err)
+	}
+}
+
+func TestThrottleSettings_Validate(t *testing.T) {
+	invalid := []ThrottleSettings{
+		{GlobalRPS: -1},
+		{ClientRPS: map[string]float64{"app": -2}},
+		{ClientRPS: map[string]float64{"": 5}},
+	}
+	for _, settings := range invalid {
+		if err := settings.Validate(); err == nil {
+			t.Errorf("expected %+v to be rejected", settings)
+		}
+	}
+
+	throttle, _ := newTestThrottle()
+	throttle.Update(ThrottleSettings{GlobalRPS: 10})
+	if err := throttle.Update(ThrottleSettings{GlobalRPS: -1}); err == nil {
+		t.Fatal("expected an invalid update to be rejected")
+	}
+	if got := throttle.Settings().GlobalRPS; got != 10 {
+		t.Errorf("expected a rejected update to keep the current limits, got %g", got)
+	}
+}
@@ -106,6 +106,20 @@ func (cm *ClusterManager) GetCluster(clusterID string) (*ClusterConnection, erro
 	return conn, nil
 }
 
+// GetClusterByName gets a cluster connection by cluster name
+func (cm *ClusterManager) GetClusterByName(name string) (*ClusterConnection, error) {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	for _, conn := range cm.clusters {
+		if conn.Cluster.Name == name {
+			return conn, nil
+		}
+	}
+
+	return nil, fmt.Errorf("cluster not found: %s", name)
+}
+
 // ListClusters returns all registered clusters
 func (cm *ClusterManager) ListClusters() []*models.Cluster {
 	cm.mu.RLock()
@@ -0,0 +1,267 @@
+package scanner
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sharding-system/pkg/models"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// DefaultHistoryRetention is how long scan samples are kept by default
+const DefaultHistoryRetention = 90 * 24 * time.Hour
+
+// ScanSample is what one scan found about a database, kept to chart its growth
+type ScanSample struct {
+	DatabaseID      string    `json:"database_id"`
+	DatabaseName    string    `json:"database_name"`
+	ClusterID       string    `json:"cluster_id"`
+	Status          string    `json:"status"`
+	ScannedAt       time.Time `json:"scanned_at"`
+	Size            int64     `json:"size"` // bytes
+	TableCount      int       `json:"table_count"`
+	IndexCount      int       `json:"index_count"`
+	ConnectionCount int       `json:"connection_count"`
+}
+
+// HistoryStore persists scan samples
+type HistoryStore interface {
+	// Append stores samples
+	Append(ctx context.Context, samples []ScanSample) error
+	// Query returns a database's samples taken in [from, to], oldest first
+	Query(ctx context.Context, databaseID string, from, to time.Time) ([]ScanSample, error)
+	// Prune deletes the samples taken before a time
+	Prune(ctx context.Context, before time.Time) error
+}
+
+// ScanHistory records the results of every scan, so how databases grow can be
+// queried over time. Samples older than the retention are pruned as new ones
+// are recorded.
+type ScanHistory struct {
+	store     HistoryStore
+	retention time.Duration
+}
+
+// NewScanHistory creates a history persisting samples in store for
+// retention; non-positive retentions use DefaultHistoryRetention
+func NewScanHistory(store HistoryStore, retention time.Duration) *ScanHistory {
+	if retention <= 0 {
+		retention = DefaultHistoryRetention
+	}
+	return &ScanHistory{store: store, retention: retention}
+}
+
+// Record stores a sample of each scanned database, taken when it was last
+// scanned or else at now
+func (h *ScanHistory) Record(ctx context.Context, results []models.ScannedDatabase, now time.Time) error {
+	samples := make([]ScanSample, 0, len(results))
+	for _, db := range results {
+		sample := ScanSample{
+			DatabaseID:   db.ID,
+			DatabaseName: db.DatabaseName,
+			ClusterID:    db.ClusterID,
+			Status:       db.Status,
+			ScannedAt:    now,
+		}
+		if db.LastScannedAt != nil {
+			sample.ScannedAt = *db.LastScannedAt
+		}
+		if results := db.ScanResults; results != nil {
+			sample.Size = results.Size
+			sample.TableCount = results.TableCount
+			sample.IndexCount = results.IndexCount
+			sample.ConnectionCount = results.ConnectionCount
+		}
+		samples = append(samples, sample)
+	}
+	if err := h.store.Append(ctx, samples); err != nil {
+		return err
+	}
+	return h.store.Prune(ctx, now.Add(-h.retention))
+}
+
+// Query returns a database's samples taken in [from, to], oldest first
+func (h *ScanHistory) Query(ctx context.Context, databaseID string, from, to time.Time) ([]ScanSample, error) {
+	return h.store.Query(ctx, databaseID, from, to)
+}
+
+// MemoryHistoryStore keeps scan samples in memory. It is used in tests and
+// when the catalog cannot persist them.
+type MemoryHistoryStore struct {
+	mu      sync.RWMutex
+	samples map[string][]ScanSample
+}
+
+// NewMemoryHistoryStore creates an empty in-memory history store
+func NewMemoryHistoryStore() *MemoryHistoryStore {
+	return &MemoryHistoryStore{samples: make(map[string][]ScanSample)}
+}
+
+// Append stores samples
+func (s *MemoryHistoryStore) Append(ctx context.Context, samples []ScanSample) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, sample := range samples {
+		s.samples[sample.DatabaseID] = append(s.samples[sample.DatabaseID], sample)
+	}
+	return nil
+}
+
+// Query returns a database's samples taken in [from, to], oldest first
+func (s *MemoryHistoryStore) Query(ctx context.Context, databaseID string, from, to time.Time) ([]ScanSample, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	samples := make([]ScanSample, 0)
+	for _, sample := range s.samples[databaseID] {
+		if !sample.ScannedAt.Before(from) && !sample.ScannedAt.After(to) {
+			samples = append(samples, sample)
+		}
+	}
+	sort.Slice(samples, func(i, j int) bool { return samples[i].ScannedAt.Before(samples[j].ScannedAt) })
+	return samples, nil
+}
+
+// Prune deletes the samples taken before a time
+func (s *MemoryHistoryStore) Prune(ctx context.Context, before time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, samples := range s.samples {
+		kept := samples[:0]
+		for _, sample := range samples {
+			if !sample.ScannedAt.Before(before) {
+				kept = append(kept, sample)
+			}
+		}
+		if len(kept) == 0 {
+			delete(s.samples, id)
+		} else {
+			s.samples[id] = kept
+		}
+	}
+	return nil
+}
+
+const (
+	historyKeyPrefix   = "/catalog/scan-history/"
+	historyIndexPrefix = "/catalog/scan-history-index/"
+)
+
+// EtcdHistoryStore persists scan samples in the catalog's etcd under
+// /catalog/scan-history/<database>/<time>, so time ranges are key ranges.
+// Each sample is also indexed under /catalog/scan-history-index/<time>/<database>,
+// so pruning reads only the samples that have expired.
+type EtcdHistoryStore struct {
+	client *clientv3.Client
+}
+
+// NewEtcdHistoryStore creates a history store backed by etcd
+func NewEtcdHistoryStore(client *clientv3.Client) *EtcdHistoryStore {
+	return &EtcdHistoryStore{client: client}
+}
+
+// historyKey returns the key of a sample of a database taken at a time.
+// Times are zero-padded so keys sort in time order.
+func historyKey(databaseID string, at time.Time) string {
+	return historyDatabasePrefix(url.PathEscape(databaseID)) + historyTime(at)
+}
+
+// historyDatabasePrefix returns the prefix of an escaped database's sample keys
+func historyDatabasePrefix(escapedID string) string {
+	return historyKeyPrefix + escapedID + "/"
+}
+
+// historyIndexKey returns the index key of a sample of a database taken at a
+// time. Index keys sort by time across every database.
+func historyIndexKey(databaseID string, at time.Time) string {
+	return historyIndexPrefix + historyTime(at) + "/" + url.PathEscape(databaseID)
+}
+
+// historyIndexDatabase returns the escaped database of an index key
+func historyIndexDatabase(key string) (string, bool) {
+	key = strings.TrimPrefix(key, historyIndexPrefix)
+	i := strings.IndexByte(key, '/')
+	if i < 0 {
+		return "", false
+	}
+	return key[i+1:], true
+}
+
+// historyTime formats a time as a key segment, clamping times before the
+// Unix epoch to it
+func historyTime(at time.Time) string {
+	if at.Before(time.Unix(0, 0)) {
+		at = time.Unix(0, 0)
+	}
+	return fmt.Sprintf("%020d", at.UnixNano())
+}
+
+// Append stores samples
+func (s *EtcdHistoryStore) Append(ctx context.Context, samples []ScanSample) error {
+	for _, sample := range samples {
+		data, err := json.Marshal(sample)
+		if err != nil {
+			return fmt.Errorf("failed to marshal scan sample: %w", err)
+		}
+		_, err = s.client.Txn(ctx).Then(
+			clientv3.OpPut(historyKey(sample.DatabaseID, sample.ScannedAt), string(data)),
+			clientv3.OpPut(historyIndexKey(sample.DatabaseID, sample.ScannedAt), ""),
+		).Commit()
+		if err != nil {
+			return fmt.Errorf("failed to save scan sample: %w", err)
+		}
+	}
+	return nil
+}
+
+// Query returns a database's samples taken in [from, to], oldest first
+func (s *EtcdHistoryStore) Query(ctx context.Context, databaseID string, from, to time.Time) ([]ScanSample, error) {
+	resp, err := s.client.Get(ctx, historyKey(databaseID, from),
+		clientv3.WithRange(historyKey(databaseID, to.Add(time.Nanosecond))),
+		clientv3.WithSort(clientv3.SortByKey, clientv3.SortAscend))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query scan history: %w", err)
+	}
+
+	samples := make([]ScanSample, 0, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		var sample ScanSample
+		if err := json.Unmarshal(kv.Value, &sample); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal scan sample %s: %w", string(kv.Key), err)
+		}
+		samples = append(samples, sample)
+	}
+	return samples, nil
+}
+
+// Prune deletes the samples taken before a time
+func (s *EtcdHistoryStore) Prune(ctx context.Context, before time.Time) error {
+	// The index range before the cutoff holds only expired samples, so the
+	// samples still retained are never read
+	indexEnd := historyIndexPrefix + historyTime(before)
+	resp, err := s.client.Get(ctx, historyIndexPrefix, clientv3.WithRange(indexEnd), clientv3.WithKeysOnly())
+	if err != nil {
+		return fmt.Errorf("failed to list expired scan history: %w", err)
+	}
+	databases := make(map[string]bool)
+	for _, kv := range resp.Kvs {
+		if database, ok := historyIndexDatabase(string(kv.Key)); ok {
+			databases[database] = true
+		}
+	}
+	for database := range databases {
+		prefix := historyDatabasePrefix(database)
+		if _, err := s.client.Delete(ctx, prefix, clientv3.WithRange(prefix+historyTime(before))); err != nil {
+			return fmt.Errorf("failed to prune scan history: %w", err)
+		}
+	}
+	if _, err := s.client.Delete(ctx, historyIndexPrefix, clientv3.WithRange(indexEnd)); err != nil {
+		return fmt.Errorf("failed to prune scan history index: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package scanner
+
+import (
+	"context"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/sharding-system/pkg/models"
+)
+
+// scannedOrders returns a deep scan of the orders database at a time
+func scannedOrders(at time.Time, size int64, tables int) models.ScannedDatabase {
+	return models.ScannedDatabase{
+		ID:            scannedDatabaseID("cluster-1", "shop", "orders-api", "orders"),
+		ClusterID:     "cluster-1",
+		DatabaseName:  "orders",
+		Status:        "scanned",
+		LastScannedAt: &at,
+		ScanResults:   &models.DatabaseScanResults{Size: size, TableCount: tables},
+	}
+}
+
+func TestScanHistory_RetainsScansQueryableByTimeRange(t *testing.T) {
+	ctx := context.Background()
+	history := NewScanHistory(NewMemoryHistoryStore(), 0)
+	start := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	// One scan a day for a week, growing by 1 GiB a day
+	for day := 0; day < 7; day++ {
+		at := start.Add(time.Duration(day) * 24 * time.Hour)
+		if err := history.Record(ctx, []models.ScannedDatabase{scannedOrders(at, int64(day+1)<<30, 10+day)}, at); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	id := scannedDatabaseID("cluster-1", "shop", "orders-api", "orders")
+	samples, err := history.Query(ctx, id, start.Add(2*24*time.Hour), start.Add(4*24*time.Hour))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(samples) != 3 {
+		t.Fatalf("expected the scans of days 3 to 5, got %d", len(samples))
+	}
+	for i, sample := range samples {
+		if sample.Size != int64(i+3)<<30 || sample.TableCount != 12+i || !sample.ScannedAt.Equal(start.Add(time.Duration(i+2)*24*time.Hour)) {
+			t.Errorf("expected day %d's scan in order, got %+v", i+3, sample)
+		}
+	}
+
+	if other, _ := history.Query(ctx, "another-database", start, start.Add(7*24*time.Hour)); len(other) != 0 {
+		t.Errorf("expected no history for another database, got %v", other)
+	}
+}
+
+func TestScanHistory_PrunesPastRetention(t *testing.T) {
+	ctx := context.Background()
+	history := NewScanHistory(NewMemoryHistoryStore(), 48*time.Hour)
+	start := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	for day := 0; day < 4; day++ {
+		at := start.Add(time.Duration(day) * 24 * time.Hour)
+		if err := history.Record(ctx, []models.ScannedDatabase{scannedOrders(at, 1<<30, 10)}, at); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	samples, _ := history.Query(ctx, scannedDatabaseID("cluster-1", "shop", "orders-api", "orders"), start, start.Add(7*24*time.Hour))
+	if len(samples) != 3 || !samples[0].ScannedAt.Equal(start.Add(24*time.Hour)) {
+		t.Errorf("expected the scans of the last two days to be kept, got %+v", samples)
+	}
+}
+
+func TestScannedDatabaseID_StableAcrossScans(t *testing.T) {
+	id := scannedDatabaseID("cluster-1", "shop", "orders-api", "orders")
+	if id != scannedDatabaseID("cluster-1", "shop", "orders-api", "orders") {
+		t.Error("expected the same database to keep its ID")
+	}
+	if id == scannedDatabaseID("cluster-1", "shop", "billing-api", "orders") {
+		t.Error("expected databases of different apps to have different IDs")
+	}
+}
+
+func TestHistoryKey_SortsByTime(t *testing.T) {
+	early := historyKey("db/1", time.Unix(9, 0))
+	late := historyKey("db/1", time.Unix(10, 0))
+	if early >= late {
+		t.Errorf("expected keys in time order, got %s and %s", early, late)
+	}
+	if historyKey("db/1", time.Time{}) != historyKey("db/1", time.Unix(0, 0)) {
+		t.Error("expected times before the epoch to clamp to it")
+	}
+}
+
+func TestHistoryIndexKey_SortsByTimeAcrossDatabases(t *testing.T) {
+	early := historyIndexKey("zz", time.Unix(9, 0))
+	late := historyIndexKey("aa", time.Unix(10, 0))
+	if early >= late {
+		t.Errorf("expected index keys in time order, got %s and %s", early, late)
+	}
+	if early >= historyIndexPrefix+historyTime(time.Unix(10, 0)) {
+		t.Errorf("expected %s to fall before a later cutoff", early)
+	}
+
+	database, ok := historyIndexDatabase(historyIndexKey("db/1", time.Unix(9, 0)))
+	if !ok || database != url.PathEscape("db/1") {
+		t.Errorf("expected the escaped database from the index key, got %q", database)
+	}
+}
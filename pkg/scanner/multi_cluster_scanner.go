@@ -2,6 +2,8 @@ package scanner
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/base64"
 	"fmt"
 	"strings"
 	"sync"
@@ -176,10 +178,17 @@ func (mcs *MultiClusterScanner) getOrCreateDiscovery(clusterID string, conn *Clu
 	return disc, nil
 }
 
+// scannedDatabaseID returns a database's ID, the same on every scan of the
+// same database so its history can be followed
+func scannedDatabaseID(clusterID, namespace, appName, databaseName string) string {
+	sum := sha256.Sum256([]byte(clusterID + "/" + namespace + "/" + appName + "/" + databaseName))
+	return base64.URLEncoding.EncodeToString(sum[:16])
+}
+
 // convertToScannedDatabase converts a discovered app to a scanned database
 func (mcs *MultiClusterScanner) convertToScannedDatabase(clusterID, clusterName string, app *discovery.DiscoveredApp) *models.ScannedDatabase {
 	db := &models.ScannedDatabase{
-		ID:           generateClusterID(),
+		ID:           scannedDatabaseID(clusterID, app.Namespace, app.Name, app.DatabaseName),
 		ClusterID:    clusterID,
 		ClusterName:  clusterName,
 		Namespace:    app.Namespace,
@@ -276,6 +276,13 @@ func (ds *LegacyDatabaseScanner) scanPostgreSQL(ctx context.Context, db *sql.DB,
 		result.Schemas = schemas
 	}
 
+	tables, err := ds.scanPostgreSQLTables(ctx, db)
+	result.Tables = append(result.Tables, tables...)
+	return err
+}
+
+// scanPostgreSQLTables scans every user table and view in a PostgreSQL database
+func (ds *LegacyDatabaseScanner) scanPostgreSQLTables(ctx context.Context, db *sql.DB) ([]TableInfo, error) {
 	// Get all tables from all schemas (excluding system schemas)
 	query := `
 		SELECT schemaname, tablename, 'table' as tabletype
@@ -290,10 +297,11 @@ func (ds *LegacyDatabaseScanner) scanPostgreSQL(ctx context.Context, db *sql.DB,
 
 	rows, err := db.QueryContext(ctx, query)
 	if err != nil {
-		return fmt.Errorf("failed to query tables: %w", err)
+		return nil, fmt.Errorf("failed to query tables: %w", err)
 	}
 	defer rows.Close()
 
+	var tables []TableInfo
 	for rows.Next() {
 		var schema, tableName, tableType string
 		if err := rows.Scan(&schema, &tableName, &tableType); err != nil {
@@ -309,10 +317,10 @@ func (ds *LegacyDatabaseScanner) scanPostgreSQL(ctx context.Context, db *sql.DB,
 			continue
 		}
 
-		result.Tables = append(result.Tables, *tableInfo)
+		tables = append(tables, *tableInfo)
 	}
 
-	return nil
+	return tables, nil
 }
 
 // getPostgreSQLSchemas gets all schemas in the database
@@ -381,14 +389,15 @@ func (ds *LegacyDatabaseScanner) scanPostgreSQLTable(ctx context.Context, db *sq
 	columnMap := make(map[string]*ColumnInfo)
 	for rows.Next() {
 		var col ColumnInfo
-		var nullable, maxLength sql.NullString
+		var nullable, defaultValue, maxLength sql.NullString
 		var comment sql.NullString
 
-		if err := rows.Scan(&col.Name, &col.Type, &nullable, &col.DefaultValue, &maxLength, &comment); err != nil {
+		if err := rows.Scan(&col.Name, &col.Type, &nullable, &defaultValue, &maxLength, &comment); err != nil {
 			continue
 		}
 
 		col.Nullable = nullable.String == "YES"
+		col.DefaultValue = defaultValue.String
 		if maxLength.Valid {
 			// Parse max length if it's a number
 			fmt.Sscanf(maxLength.String, "%d", &col.MaxLength)
@@ -521,6 +530,28 @@ func (ds *LegacyDatabaseScanner) scanPostgreSQLTable(ctx context.Context, db *sq
 		}
 	}
 
+	// Get check, unique and exclusion constraints
+	constraintQuery := `
+		SELECT
+			conname,
+			CASE contype WHEN 'c' THEN 'CHECK' WHEN 'u' THEN 'UNIQUE' ELSE 'EXCLUDE' END,
+			pg_get_constraintdef(oid)
+		FROM pg_constraint
+		WHERE conrelid = $1::regclass AND contype IN ('c', 'u', 'x')
+		ORDER BY conname
+	`
+
+	conRows, err := db.QueryContext(ctx, constraintQuery, fullTableName)
+	if err == nil {
+		defer conRows.Close()
+		for conRows.Next() {
+			var constraint ConstraintInfo
+			if err := conRows.Scan(&constraint.Name, &constraint.Type, &constraint.Definition); err == nil {
+				table.Constraints = append(table.Constraints, constraint)
+			}
+		}
+	}
+
 	return table, nil
 }
 
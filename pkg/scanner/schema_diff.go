@@ -0,0 +1,244 @@
+package scanner
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// SchemaDiff describes how a target schema differs from a source schema.
+// "Added" objects exist only in the target, "removed" objects only in the source.
+type SchemaDiff struct {
+	Source        string      `json:"source"`
+	Target        string      `json:"target"`
+	AddedTables   []string    `json:"added_tables,omitempty"`
+	RemovedTables []string    `json:"removed_tables,omitempty"`
+	ChangedTables []TableDiff `json:"changed_tables,omitempty"`
+}
+
+// TableDiff describes the differences within a table present in both schemas
+type TableDiff struct {
+	Table              string             `json:"table"`
+	AddedColumns       []ColumnInfo       `json:"added_columns,omitempty"`
+	RemovedColumns     []ColumnInfo       `json:"removed_columns,omitempty"`
+	ChangedColumns     []ColumnChange     `json:"changed_columns,omitempty"`
+	AddedIndexes       []IndexInfo        `json:"added_indexes,omitempty"`
+	RemovedIndexes     []IndexInfo        `json:"removed_indexes,omitempty"`
+	ChangedIndexes     []IndexChange      `json:"changed_indexes,omitempty"`
+	AddedConstraints   []ConstraintInfo   `json:"added_constraints,omitempty"`
+	RemovedConstraints []ConstraintInfo   `json:"removed_constraints,omitempty"`
+	ChangedConstraints []ConstraintChange `json:"changed_constraints,omitempty"`
+}
+
+// ColumnChange describes a column whose definition differs
+type ColumnChange struct {
+	Name   string     `json:"name"`
+	Fields []string   `json:"fields"` // Attributes that differ, e.g. "type", "nullable"
+	Source ColumnInfo `json:"source"`
+	Target ColumnInfo `json:"target"`
+}
+
+// IndexChange describes an index whose definition differs
+type IndexChange struct {
+	Name   string    `json:"name"`
+	Source IndexInfo `json:"source"`
+	Target IndexInfo `json:"target"`
+}
+
+// ConstraintChange describes a constraint whose definition differs
+type ConstraintChange struct {
+	Name   string         `json:"name"`
+	Source ConstraintInfo `json:"source"`
+	Target ConstraintInfo `json:"target"`
+}
+
+// Empty reports whether the schemas are identical
+func (d *SchemaDiff) Empty() bool {
+	return len(d.AddedTables) == 0 && len(d.RemovedTables) == 0 && len(d.ChangedTables) == 0
+}
+
+func (d *TableDiff) empty() bool {
+	return len(d.AddedColumns) == 0 && len(d.RemovedColumns) == 0 && len(d.ChangedColumns) == 0 &&
+		len(d.AddedIndexes) == 0 && len(d.RemovedIndexes) == 0 && len(d.ChangedIndexes) == 0 &&
+		len(d.AddedConstraints) == 0 && len(d.RemovedConstraints) == 0 && len(d.ChangedConstraints) == 0
+}
+
+// DiffSchemas compares two sets of scanned tables. Tables are matched by
+// schema-qualified name; columns, indexes and constraints by name.
+func DiffSchemas(source, target []TableInfo) *SchemaDiff {
+	diff := &SchemaDiff{}
+
+	sourceTables := tablesByName(source)
+	targetTables := tablesByName(target)
+
+	for name := range targetTables {
+		if _, ok := sourceTables[name]; !ok {
+			diff.AddedTables = append(diff.AddedTables, name)
+		}
+	}
+	sort.Strings(diff.AddedTables)
+
+	names := make([]string, 0, len(sourceTables))
+	for name := range sourceTables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		targetTable, ok := targetTables[name]
+		if !ok {
+			diff.RemovedTables = append(diff.RemovedTables, name)
+			continue
+		}
+		if tableDiff := diffTable(name, sourceTables[name], targetTable); !tableDiff.empty() {
+			diff.ChangedTables = append(diff.ChangedTables, *tableDiff)
+		}
+	}
+
+	return diff
+}
+
+func diffTable(name string, source, target TableInfo) *TableDiff {
+	diff := &TableDiff{Table: name}
+
+	sourceColumns := make(map[string]ColumnInfo, len(source.Columns))
+	for _, col := range source.Columns {
+		sourceColumns[col.Name] = col
+	}
+	targetColumns := make(map[string]ColumnInfo, len(target.Columns))
+	for _, col := range target.Columns {
+		targetColumns[col.Name] = col
+		if _, ok := sourceColumns[col.Name]; !ok {
+			diff.AddedColumns = append(diff.AddedColumns, col)
+		}
+	}
+	for _, col := range source.Columns {
+		targetCol, ok := targetColumns[col.Name]
+		if !ok {
+			diff.RemovedColumns = append(diff.RemovedColumns, col)
+			continue
+		}
+		if fields := columnDifferences(col, targetCol); len(fields) > 0 {
+			diff.ChangedColumns = append(diff.ChangedColumns, ColumnChange{Name: col.Name, Fields: fields, Source: col, Target: targetCol})
+		}
+	}
+
+	sourceIndexes := make(map[string]IndexInfo, len(source.Indexes))
+	for _, idx := range source.Indexes {
+		sourceIndexes[idx.Name] = idx
+	}
+	targetIndexes := make(map[string]IndexInfo, len(target.Indexes))
+	for _, idx := range target.Indexes {
+		targetIndexes[idx.Name] = idx
+		if _, ok := sourceIndexes[idx.Name]; !ok {
+			diff.AddedIndexes = append(diff.AddedIndexes, idx)
+		}
+	}
+	for _, idx := range source.Indexes {
+		targetIdx, ok := targetIndexes[idx.Name]
+		if !ok {
+			diff.RemovedIndexes = append(diff.RemovedIndexes, idx)
+			continue
+		}
+		if !sameIndex(idx, targetIdx) {
+			diff.ChangedIndexes = append(diff.ChangedIndexes, IndexChange{Name: idx.Name, Source: idx, Target: targetIdx})
+		}
+	}
+
+	sourceConstraints := constraintsOf(source)
+	targetConstraints := constraintsOf(target)
+	sourceByName := make(map[string]ConstraintInfo, len(sourceConstraints))
+	for _, constraint := range sourceConstraints {
+		sourceByName[constraint.Name] = constraint
+	}
+	targetByName := make(map[string]ConstraintInfo, len(targetConstraints))
+	for _, constraint := range targetConstraints {
+		targetByName[constraint.Name] = constraint
+		if _, ok := sourceByName[constraint.Name]; !ok {
+			diff.AddedConstraints = append(diff.AddedConstraints, constraint)
+		}
+	}
+	for _, constraint := range sourceConstraints {
+		targetConstraint, ok := targetByName[constraint.Name]
+		if !ok {
+			diff.RemovedConstraints = append(diff.RemovedConstraints, constraint)
+			continue
+		}
+		if constraint.Type != targetConstraint.Type || constraint.Definition != targetConstraint.Definition {
+			diff.ChangedConstraints = append(diff.ChangedConstraints, ConstraintChange{Name: constraint.Name, Source: constraint, Target: targetConstraint})
+		}
+	}
+
+	return diff
+}
+
+// columnDifferences lists the attributes that differ between two columns
+func columnDifferences(source, target ColumnInfo) []string {
+	var fields []string
+	if source.Type != target.Type {
+		fields = append(fields, "type")
+	}
+	if source.MaxLength != target.MaxLength {
+		fields = append(fields, "max_length")
+	}
+	if source.Nullable != target.Nullable {
+		fields = append(fields, "nullable")
+	}
+	if source.DefaultValue != target.DefaultValue {
+		fields = append(fields, "default_value")
+	}
+	if source.IsPrimaryKey != target.IsPrimaryKey {
+		fields = append(fields, "is_primary_key")
+	}
+	return fields
+}
+
+func sameIndex(source, target IndexInfo) bool {
+	return source.IsUnique == target.IsUnique &&
+		source.IsPrimary == target.IsPrimary &&
+		source.Type == target.Type &&
+		strings.Join(source.Columns, ",") == strings.Join(target.Columns, ",")
+}
+
+// constraintsOf lists a table's check/unique constraints and foreign keys
+func constraintsOf(table TableInfo) []ConstraintInfo {
+	constraints := make([]ConstraintInfo, 0, len(table.Constraints)+len(table.ForeignKeys))
+	constraints = append(constraints, table.Constraints...)
+	for _, fk := range table.ForeignKeys {
+		constraints = append(constraints, ConstraintInfo{
+			Name: fk.Name,
+			Type: "FOREIGN KEY",
+			Definition: fmt.Sprintf("(%s) REFERENCES %s(%s) ON DELETE %s ON UPDATE %s",
+				strings.Join(fk.Columns, ", "), fk.ReferencedTable, strings.Join(fk.ReferencedColumns, ", "), fk.OnDelete, fk.OnUpdate),
+		})
+	}
+	return constraints
+}
+
+func tablesByName(tables []TableInfo) map[string]TableInfo {
+	byName := make(map[string]TableInfo, len(tables))
+	for _, table := range tables {
+		schema := table.Schema
+		if schema == "" {
+			schema = "public"
+		}
+		byName[schema+"."+table.Name] = table
+	}
+	return byName
+}
+
+// ScanSchema connects to a PostgreSQL endpoint and returns its table definitions
+func (ds *LegacyDatabaseScanner) ScanSchema(ctx context.Context, endpoint string) ([]TableInfo, error) {
+	db, err := sql.Open("postgres", endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect: %w", err)
+	}
+	defer db.Close()
+
+	if err := db.PingContext(ctx); err != nil {
+		return nil, fmt.Errorf("connection test failed: %w", err)
+	}
+
+	return ds.scanPostgreSQLTables(ctx, db)
+}
@@ -0,0 +1,132 @@
+package scanner
+
+import (
+	"reflect"
+	"testing"
+)
+
+func baseSchema() []TableInfo {
+	return []TableInfo{
+		{
+			Name:   "users",
+			Schema: "public",
+			Columns: []ColumnInfo{
+				{Name: "id", Type: "bigint", IsPrimaryKey: true},
+				{Name: "email", Type: "character varying", MaxLength: 255},
+				{Name: "nickname", Type: "text", Nullable: true},
+			},
+			Indexes: []IndexInfo{
+				{Name: "users_pkey", Columns: []string{"id"}, IsUnique: true, IsPrimary: true, Type: "btree"},
+				{Name: "idx_users_email", Columns: []string{"email"}, Type: "btree"},
+			},
+			Constraints: []ConstraintInfo{
+				{Name: "users_email_check", Type: "CHECK", Definition: "CHECK ((email <> ''::text))"},
+			},
+		},
+		{
+			Name:   "orders",
+			Schema: "public",
+			Columns: []ColumnInfo{
+				{Name: "id", Type: "bigint", IsPrimaryKey: true},
+				{Name: "user_id", Type: "bigint"},
+			},
+			ForeignKeys: []ForeignKeyInfo{
+				{Name: "orders_user_fk", Columns: []string{"user_id"}, ReferencedTable: "users", ReferencedColumns: []string{"id"}, OnDelete: "CASCADE", OnUpdate: "NO ACTION"},
+			},
+		},
+		{Name: "audit_log", Schema: "public", Columns: []ColumnInfo{{Name: "id", Type: "bigint"}}},
+	}
+}
+
+func TestDiffSchemas_IdenticalSchemas(t *testing.T) {
+	diff := DiffSchemas(baseSchema(), baseSchema())
+	if !diff.Empty() {
+		t.Errorf("expected no differences, got %+v", diff)
+	}
+}
+
+func TestDiffSchemas_KnownDifferences(t *testing.T) {
+	target := baseSchema()
+
+	// nickname is dropped, email changes type and status is added
+	users := &target[0]
+	users.Columns = []ColumnInfo{
+		{Name: "id", Type: "bigint", IsPrimaryKey: true},
+		{Name: "email", Type: "text"},
+		{Name: "status", Type: "text", DefaultValue: "'active'::text"},
+	}
+	users.Indexes = []IndexInfo{
+		{Name: "users_pkey", Columns: []string{"id"}, IsUnique: true, IsPrimary: true, Type: "btree"},
+		{Name: "idx_users_email", Columns: []string{"email"}, IsUnique: true, Type: "btree"}, // now unique
+		{Name: "idx_users_status", Columns: []string{"status"}, Type: "btree"},               // added
+	}
+	users.Constraints = []ConstraintInfo{
+		{Name: "users_status_check", Type: "CHECK", Definition: "CHECK ((status = ANY (ARRAY['active'::text, 'banned'::text])))"},
+	}
+
+	orders := &target[1]
+	orders.ForeignKeys[0].OnDelete = "RESTRICT"
+
+	target = append(target[:2], TableInfo{Name: "payments", Schema: "public"}) // audit_log removed, payments added
+
+	diff := DiffSchemas(baseSchema(), target)
+
+	if !reflect.DeepEqual(diff.AddedTables, []string{"public.payments"}) {
+		t.Errorf("expected payments to be added, got %v", diff.AddedTables)
+	}
+	if !reflect.DeepEqual(diff.RemovedTables, []string{"public.audit_log"}) {
+		t.Errorf("expected audit_log to be removed, got %v", diff.RemovedTables)
+	}
+	if len(diff.ChangedTables) != 2 {
+		t.Fatalf("expected 2 changed tables, got %+v", diff.ChangedTables)
+	}
+
+	// Changed tables are reported in name order
+	ordersDiff, usersDiff := diff.ChangedTables[0], diff.ChangedTables[1]
+	if ordersDiff.Table != "public.orders" || usersDiff.Table != "public.users" {
+		t.Fatalf("unexpected changed tables %s, %s", ordersDiff.Table, usersDiff.Table)
+	}
+
+	if len(ordersDiff.ChangedConstraints) != 1 || ordersDiff.ChangedConstraints[0].Name != "orders_user_fk" {
+		t.Errorf("expected foreign key change, got %+v", ordersDiff.ChangedConstraints)
+	}
+
+	if len(usersDiff.AddedColumns) != 1 || usersDiff.AddedColumns[0].Name != "status" {
+		t.Errorf("expected status column to be added, got %+v", usersDiff.AddedColumns)
+	}
+	if len(usersDiff.RemovedColumns) != 1 || usersDiff.RemovedColumns[0].Name != "nickname" {
+		t.Errorf("expected nickname column to be removed, got %+v", usersDiff.RemovedColumns)
+	}
+	if len(usersDiff.ChangedColumns) != 1 || usersDiff.ChangedColumns[0].Name != "email" {
+		t.Fatalf("expected email column to change, got %+v", usersDiff.ChangedColumns)
+	}
+	if fields := usersDiff.ChangedColumns[0].Fields; !reflect.DeepEqual(fields, []string{"type", "max_length"}) {
+		t.Errorf("expected type and max_length to differ, got %v", fields)
+	}
+
+	if len(usersDiff.AddedIndexes) != 1 || usersDiff.AddedIndexes[0].Name != "idx_users_status" {
+		t.Errorf("expected idx_users_status to be added, got %+v", usersDiff.AddedIndexes)
+	}
+	if len(usersDiff.ChangedIndexes) != 1 || usersDiff.ChangedIndexes[0].Name != "idx_users_email" {
+		t.Errorf("expected idx_users_email to change, got %+v", usersDiff.ChangedIndexes)
+	}
+	if len(usersDiff.RemovedIndexes) != 0 {
+		t.Errorf("expected no removed indexes, got %+v", usersDiff.RemovedIndexes)
+	}
+
+	if len(usersDiff.AddedConstraints) != 1 || usersDiff.AddedConstraints[0].Name != "users_status_check" {
+		t.Errorf("expected users_status_check to be added, got %+v", usersDiff.AddedConstraints)
+	}
+	if len(usersDiff.RemovedConstraints) != 1 || usersDiff.RemovedConstraints[0].Name != "users_email_check" {
+		t.Errorf("expected users_email_check to be removed, got %+v", usersDiff.RemovedConstraints)
+	}
+}
+
+func TestDiffSchemas_DefaultSchemaMatchesPublic(t *testing.T) {
+	source := []TableInfo{{Name: "users", Schema: "public"}}
+	target := []TableInfo{{Name: "users"}}
+
+	if diff := DiffSchemas(source, target); !diff.Empty() {
+		t.Errorf("expected tables without a schema to match public, got %+v", diff)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"encoding/hex"
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	_ "github.com/lib/pq"
@@ -21,6 +22,7 @@ type Migration struct {
 	Name        string    `json:"name"`
 	Description string    `json:"description,omitempty"`
 	SQL         string    `json:"sql"`
+	DownSQL     string    `json:"down_sql,omitempty"`
 	Checksum    string    `json:"checksum"`
 	AppliedAt   time.Time `json:"applied_at,omitempty"`
 	Duration    int64     `json:"duration_ms,omitempty"`
@@ -31,7 +33,7 @@ type MigrationStatus struct {
 	ShardID    string    `json:"shard_id"`
 	ShardName  string    `json:"shard_name"`
 	Version    int       `json:"version"`
-	Status     string    `json:"status"` // "pending", "applying", "applied", "failed"
+	Status     string    `json:"status"` // "pending", "applying", "applied", "rolled_back", "skipped", "failed"
 	Error      string    `json:"error,omitempty"`
 	AppliedAt  time.Time `json:"applied_at,omitempty"`
 	DurationMs int64     `json:"duration_ms,omitempty"`
@@ -48,23 +50,68 @@ type ShardConnection struct {
 	Password string
 }
 
+// DefaultMigrationParallelism is the number of shards migrated at once
+// unless configured otherwise
+const DefaultMigrationParallelism = 8
+
+// ApplyOptions controls how migrations are rolled out across shards
+type ApplyOptions struct {
+	Parallelism int  // Maximum shards migrated at once; defaults to the manager's parallelism
+	StopOnError bool // Skip shards not yet started once any shard fails
+	Online      bool // Apply table-rewriting ALTER TABLE statements without blocking writes
+}
+
 // Manager handles schema migrations across shards
 type Manager struct {
-	logger     *zap.Logger
-	migrations map[int]*Migration // version -> migration
-	mu         sync.RWMutex
+	logger      *zap.Logger
+	migrations  map[int]*Migration // version -> migration
+	mu          sync.RWMutex
+	driver      string   // database/sql driver used to reach shards
+	parallelism int      // default number of shards migrated at once
+	shardLocks  sync.Map // shard ID -> *sync.Mutex, serializes migrations per shard
+
+	onlineBatchSize int // rows copied per batch by online schema changes
 }
 
 // NewManager creates a new schema manager
 func NewManager(logger *zap.Logger) *Manager {
 	return &Manager{
-		logger:     logger,
-		migrations: make(map[int]*Migration),
+		logger:      logger,
+		migrations:  make(map[int]*Migration),
+		driver:      "postgres",
+		parallelism: DefaultMigrationParallelism,
+
+		onlineBatchSize: DefaultOnlineBatchSize,
+	}
+}
+
+// SetParallelism sets how many shards are migrated at once by default
+func (m *Manager) SetParallelism(n int) {
+	if n < 1 {
+		n = 1
 	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.parallelism = n
 }
 
-// RegisterMigration registers a new migration
+// lockShard serializes schema changes on a shard so concurrent rollouts
+// never apply the same version twice
+func (m *Manager) lockShard(shardID string) func() {
+	lock, _ := m.shardLocks.LoadOrStore(shardID, &sync.Mutex{})
+	mu := lock.(*sync.Mutex)
+	mu.Lock()
+	return mu.Unlock
+}
+
+// RegisterMigration registers a new migration without a down migration
 func (m *Manager) RegisterMigration(version int, name, description, sqlContent string) error {
+	return m.RegisterReversibleMigration(version, name, description, sqlContent, "")
+}
+
+// RegisterReversibleMigration registers a new migration along with the SQL
+// that reverts it
+func (m *Manager) RegisterReversibleMigration(version int, name, description, sqlContent, downSQL string) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
@@ -80,6 +127,7 @@ func (m *Manager) RegisterMigration(version int, name, description, sqlContent s
 		Name:        name,
 		Description: description,
 		SQL:         sqlContent,
+		DownSQL:     downSQL,
 		Checksum:    checksum,
 	}
 
@@ -92,37 +140,58 @@ func (m *Manager) RegisterMigration(version int, name, description, sqlContent s
 
 // ApplyMigrations applies pending migrations to all shards
 func (m *Manager) ApplyMigrations(ctx context.Context, shards []ShardConnection) ([]MigrationStatus, error) {
+	return m.ApplyMigrationsWithOptions(ctx, shards, ApplyOptions{})
+}
+
+// ApplyMigrationsWithOptions applies pending migrations to all shards, at
+// most opts.Parallelism at a time. Statuses are returned in shard order.
+func (m *Manager) ApplyMigrationsWithOptions(ctx context.Context, shards []ShardConnection, opts ApplyOptions) ([]MigrationStatus, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
-	var allStatus []MigrationStatus
-	var mu sync.Mutex
+	parallelism := opts.Parallelism
+	if parallelism <= 0 {
+		parallelism = m.parallelism
+	}
+	shards = uniqueShards(shards)
+
+	results := make([][]MigrationStatus, len(shards))
+	shardErrs := make([]error, len(shards))
+	sem := make(chan struct{}, parallelism)
+	var failed atomic.Bool
 	var wg sync.WaitGroup
-	errors := make(chan error, len(shards))
 
-	for _, shard := range shards {
+	for i, shard := range shards {
+		sem <- struct{}{}
+		if (opts.StopOnError && failed.Load()) || ctx.Err() != nil {
+			<-sem
+			results[i] = []MigrationStatus{m.skippedStatus(shard)}
+			continue
+		}
+
 		wg.Add(1)
-		go func(s ShardConnection) {
+		go func(i int, s ShardConnection) {
 			defer wg.Done()
+			defer func() { <-sem }()
 
-			status, err := m.applyMigrationsToShard(ctx, s)
+			status, err := m.applyMigrationsToShard(ctx, s, opts)
+			results[i] = status
 			if err != nil {
-				errors <- fmt.Errorf("shard %s: %w", s.Name, err)
+				shardErrs[i] = fmt.Errorf("shard %s: %w", s.Name, err)
+				failed.Store(true)
 			}
-
-			mu.Lock()
-			allStatus = append(allStatus, status...)
-			mu.Unlock()
-		}(shard)
+		}(i, shard)
 	}
 
 	wg.Wait()
-	close(errors)
 
-	// Collect errors
+	var allStatus []MigrationStatus
 	var errs []error
-	for err := range errors {
-		errs = append(errs, err)
+	for i := range shards {
+		allStatus = append(allStatus, results[i]...)
+		if shardErrs[i] != nil {
+			errs = append(errs, shardErrs[i])
+		}
 	}
 
 	if len(errs) > 0 {
@@ -132,12 +201,44 @@ func (m *Manager) ApplyMigrations(ctx context.Context, shards []ShardConnection)
 	return allStatus, nil
 }
 
+// uniqueShards drops repeated shards so a rollout touches each shard once
+func uniqueShards(shards []ShardConnection) []ShardConnection {
+	seen := make(map[string]bool, len(shards))
+	unique := make([]ShardConnection, 0, len(shards))
+	for _, shard := range shards {
+		if seen[shard.ID] {
+			continue
+		}
+		seen[shard.ID] = true
+		unique = append(unique, shard)
+	}
+	return unique
+}
+
+// skippedStatus reports a shard left untouched because the rollout stopped.
+// Must be called with m.mu held.
+func (m *Manager) skippedStatus(shard ShardConnection) MigrationStatus {
+	latest := 0
+	for version := range m.migrations {
+		if version > latest {
+			latest = version
+		}
+	}
+	return MigrationStatus{
+		ShardID:   shard.ID,
+		ShardName: shard.Name,
+		Version:   latest,
+		Status:    "skipped",
+		Error:     "rollout stopped before this shard was migrated",
+	}
+}
+
 // applyMigrationsToShard applies migrations to a single shard
-func (m *Manager) applyMigrationsToShard(ctx context.Context, shard ShardConnection) ([]MigrationStatus, error) {
-	dsn := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable",
-		shard.Host, shard.Port, shard.Username, shard.Password, shard.Database)
+func (m *Manager) applyMigrationsToShard(ctx context.Context, shard ShardConnection, opts ApplyOptions) ([]MigrationStatus, error) {
+	unlock := m.lockShard(shard.ID)
+	defer unlock()
 
-	db, err := sql.Open("postgres", dsn)
+	db, err := m.openShard(shard)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect: %w", err)
 	}
@@ -175,6 +276,36 @@ func (m *Manager) applyMigrationsToShard(ctx context.Context, shard ShardConnect
 
 		start := time.Now()
 
+		if opts.Online {
+			if alter, ok := parseOnlineAlter(migration.SQL); ok {
+				// The shadow table swap is transactional; only the history row is recorded afterwards
+				if err := m.applyOnline(ctx, db, alter); err != nil {
+					status.Status = "failed"
+					status.Error = err.Error()
+					statuses = append(statuses, status)
+					return statuses, fmt.Errorf("online migration %d failed: %w", version, err)
+				}
+				if err := m.recordMigration(ctx, db, migration, start); err != nil {
+					status.Status = "failed"
+					status.Error = err.Error()
+					statuses = append(statuses, status)
+					return statuses, err
+				}
+
+				status.Status = "applied"
+				status.AppliedAt = time.Now()
+				status.DurationMs = time.Since(start).Milliseconds()
+				statuses = append(statuses, status)
+
+				m.logger.Info("applied migration online",
+					zap.String("shard", shard.Name),
+					zap.Int("version", version),
+					zap.String("name", migration.Name),
+					zap.Int64("duration_ms", status.DurationMs))
+				continue
+			}
+		}
+
 		// Execute migration in transaction
 		tx, err := db.BeginTx(ctx, nil)
 		if err != nil {
@@ -193,10 +324,7 @@ func (m *Manager) applyMigrationsToShard(ctx context.Context, shard ShardConnect
 		}
 
 		// Record migration
-		if _, err := tx.ExecContext(ctx, `
-			INSERT INTO _schema_migrations (version, name, checksum, applied_at, duration_ms)
-			VALUES ($1, $2, $3, $4, $5)
-		`, version, migration.Name, migration.Checksum, time.Now(), time.Since(start).Milliseconds()); err != nil {
+		if err := m.recordMigration(ctx, tx, migration, start); err != nil {
 			tx.Rollback()
 			status.Status = "failed"
 			status.Error = err.Error()
@@ -226,9 +354,31 @@ func (m *Manager) applyMigrationsToShard(ctx context.Context, shard ShardConnect
 	return statuses, nil
 }
 
-// createMigrationsTable creates the migrations tracking table
+// execer is implemented by both *sql.DB and *sql.Tx
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// recordMigration adds an applied migration to the shard's history
+func (m *Manager) recordMigration(ctx context.Context, exec execer, migration *Migration, start time.Time) error {
+	_, err := exec.ExecContext(ctx, `
+		INSERT INTO _schema_migrations (version, name, checksum, applied_at, duration_ms, up_sql, down_sql)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`, migration.Version, migration.Name, migration.Checksum, time.Now(), time.Since(start).Milliseconds(), migration.SQL, migration.DownSQL)
+	return err
+}
+
+// openShard opens a connection pool to a shard
+func (m *Manager) openShard(shard ShardConnection) (*sql.DB, error) {
+	dsn := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable",
+		shard.Host, shard.Port, shard.Username, shard.Password, shard.Database)
+	return sql.Open(m.driver, dsn)
+}
+
+// createMigrationsTable creates the migrations tracking table, adding the
+// up/down SQL columns to tables created by older versions
 func (m *Manager) createMigrationsTable(ctx context.Context, db *sql.DB) error {
-	_, err := db.ExecContext(ctx, `
+	if _, err := db.ExecContext(ctx, `
 		CREATE TABLE IF NOT EXISTS _schema_migrations (
 			version INTEGER PRIMARY KEY,
 			name VARCHAR(255) NOT NULL,
@@ -236,6 +386,13 @@ func (m *Manager) createMigrationsTable(ctx context.Context, db *sql.DB) error {
 			applied_at TIMESTAMP WITH TIME ZONE NOT NULL,
 			duration_ms BIGINT NOT NULL
 		)
+	`); err != nil {
+		return err
+	}
+	_, err := db.ExecContext(ctx, `
+		ALTER TABLE _schema_migrations
+			ADD COLUMN IF NOT EXISTS up_sql TEXT NOT NULL DEFAULT '',
+			ADD COLUMN IF NOT EXISTS down_sql TEXT NOT NULL DEFAULT ''
 	`)
 	return err
 }
@@ -254,17 +411,18 @@ func (m *Manager) getCurrentVersion(ctx context.Context, db *sql.DB) (int, error
 
 // GetMigrationHistory returns migration history for a shard
 func (m *Manager) GetMigrationHistory(ctx context.Context, shard ShardConnection) ([]Migration, error) {
-	dsn := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable",
-		shard.Host, shard.Port, shard.Username, shard.Password, shard.Database)
-
-	db, err := sql.Open("postgres", dsn)
+	db, err := m.openShard(shard)
 	if err != nil {
 		return nil, err
 	}
 	defer db.Close()
 
+	if err := m.createMigrationsTable(ctx, db); err != nil {
+		return nil, err
+	}
+
 	rows, err := db.QueryContext(ctx, `
-		SELECT version, name, checksum, applied_at, duration_ms 
+		SELECT version, name, checksum, applied_at, duration_ms, up_sql, down_sql
 		FROM _schema_migrations 
 		ORDER BY version
 	`)
@@ -273,17 +431,182 @@ func (m *Manager) GetMigrationHistory(ctx context.Context, shard ShardConnection
 	}
 	defer rows.Close()
 
+	return scanMigrations(rows)
+}
+
+// scanMigrations reads _schema_migrations rows selected with all columns
+func scanMigrations(rows *sql.Rows) ([]Migration, error) {
 	var history []Migration
 	for rows.Next() {
 		var mig Migration
-		if err := rows.Scan(&mig.Version, &mig.Name, &mig.Checksum, &mig.AppliedAt, &mig.Duration); err != nil {
+		if err := rows.Scan(&mig.Version, &mig.Name, &mig.Checksum, &mig.AppliedAt, &mig.Duration, &mig.SQL, &mig.DownSQL); err != nil {
 			return nil, err
 		}
 		mig.ID = fmt.Sprintf("migration_%d", mig.Version)
 		history = append(history, mig)
 	}
+	return history, rows.Err()
+}
 
-	return history, nil
+// RollbackTo reverts all shards to the given schema version by applying the
+// down migrations of every later version, newest first. Each shard is rolled
+// back in a single transaction, so a failed down migration leaves that shard
+// at its current version. Once every shard is rolled back, the reverted
+// versions are unregistered so they are not applied again.
+func (m *Manager) RollbackTo(ctx context.Context, shards []ShardConnection, version int) ([]MigrationStatus, error) {
+	if version < 0 {
+		return nil, fmt.Errorf("invalid target version %d", version)
+	}
+
+	allStatus, err := m.rollbackShards(ctx, shards, version)
+	if err != nil {
+		return allStatus, err
+	}
+
+	m.mu.Lock()
+	for v := range m.migrations {
+		if v > version {
+			delete(m.migrations, v)
+		}
+	}
+	m.mu.Unlock()
+
+	m.logger.Info("rolled back schema",
+		zap.Int("version", version),
+		zap.Int("shards", len(shards)))
+
+	return allStatus, nil
+}
+
+// rollbackShards rolls back every shard concurrently and collects their statuses
+func (m *Manager) rollbackShards(ctx context.Context, shards []ShardConnection, version int) ([]MigrationStatus, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var allStatus []MigrationStatus
+	var errs []error
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, m.parallelism)
+
+	for _, shard := range uniqueShards(shards) {
+		wg.Add(1)
+		go func(s ShardConnection) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			status, err := m.rollbackShard(ctx, s, version)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs = append(errs, fmt.Errorf("shard %s: %w", s.Name, err))
+			}
+			allStatus = append(allStatus, status...)
+		}(shard)
+	}
+
+	wg.Wait()
+
+	if len(errs) > 0 {
+		return allStatus, fmt.Errorf("rollback errors: %v", errs)
+	}
+	return allStatus, nil
+}
+
+// rollbackShard applies down migrations on a single shard in one transaction
+func (m *Manager) rollbackShard(ctx context.Context, shard ShardConnection, version int) ([]MigrationStatus, error) {
+	unlock := m.lockShard(shard.ID)
+	defer unlock()
+
+	db, err := m.openShard(shard)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect: %w", err)
+	}
+	defer db.Close()
+
+	db.SetMaxOpenConns(1)
+
+	if err := m.createMigrationsTable(ctx, db); err != nil {
+		return nil, fmt.Errorf("failed to create migrations table: %w", err)
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT version, name, checksum, applied_at, duration_ms, up_sql, down_sql
+		FROM _schema_migrations
+		WHERE version > $1
+		ORDER BY version DESC
+	`, version)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migration history: %w", err)
+	}
+	applied, err := scanMigrations(rows)
+	rows.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migration history: %w", err)
+	}
+
+	start := time.Now()
+	for _, mig := range applied {
+		downSQL := mig.DownSQL
+		if downSQL == "" {
+			// Migrations applied before down SQL was recorded fall back to the registered one
+			if registered, ok := m.migrations[mig.Version]; ok {
+				downSQL = registered.DownSQL
+			}
+		}
+
+		failed := MigrationStatus{
+			ShardID:   shard.ID,
+			ShardName: shard.Name,
+			Version:   mig.Version,
+			Status:    "failed",
+		}
+		if downSQL == "" {
+			err := fmt.Errorf("migration %d has no down migration", mig.Version)
+			failed.Error = err.Error()
+			return []MigrationStatus{failed}, err
+		}
+
+		if _, err := tx.ExecContext(ctx, downSQL); err != nil {
+			failed.Error = err.Error()
+			return []MigrationStatus{failed}, fmt.Errorf("down migration %d failed: %w", mig.Version, err)
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM _schema_migrations WHERE version = $1`, mig.Version); err != nil {
+			failed.Error = err.Error()
+			return []MigrationStatus{failed}, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit rollback: %w", err)
+	}
+
+	now := time.Now()
+	statuses := make([]MigrationStatus, 0, len(applied))
+	for _, mig := range applied {
+		statuses = append(statuses, MigrationStatus{
+			ShardID:    shard.ID,
+			ShardName:  shard.Name,
+			Version:    mig.Version,
+			Status:     "rolled_back",
+			AppliedAt:  now,
+			DurationMs: time.Since(start).Milliseconds(),
+		})
+		m.logger.Info("rolled back migration",
+			zap.String("shard", shard.Name),
+			zap.Int("version", mig.Version),
+			zap.String("name", mig.Name))
+	}
+
+	return statuses, nil
 }
 
 // ValidateMigrations checks if all shards have consistent schema versions
@@ -297,10 +620,7 @@ func (m *Manager) ValidateMigrations(ctx context.Context, shards []ShardConnecti
 		go func(s ShardConnection) {
 			defer wg.Done()
 
-			dsn := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable",
-				s.Host, s.Port, s.Username, s.Password, s.Database)
-
-			db, err := sql.Open("postgres", dsn)
+			db, err := m.openShard(s)
 			if err != nil {
 				mu.Lock()
 				versions[s.Name] = -1 // Error indicator
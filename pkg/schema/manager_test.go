@@ -0,0 +1,633 @@
+package schema
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zaptest"
+)
+
+// fakeShardDB is the in-memory state of one shard behind the fake driver
+type fakeShardDB struct {
+	mu         sync.Mutex
+	migrations map[int]fakeMigrationRow
+	executed   []string // user migration SQL, in commit order
+	broken     bool     // fail all migration SQL
+
+	// Table served to online schema change introspection
+	columns     []string
+	pk          string
+	rowIDs      []int64
+	foreignKeys []foreignKey
+}
+
+type fakeMigrationRow struct {
+	version  int
+	name     string
+	checksum string
+	upSQL    string
+	downSQL  string
+}
+
+// fakeDriver emulates the statements the schema manager issues against
+// _schema_migrations. Any other statement is treated as migration SQL and
+// fails if it contains "FAIL".
+type fakeDriver struct {
+	mu     sync.Mutex
+	shards map[string]*fakeShardDB
+
+	// delay slows migration SQL so concurrent rollouts overlap
+	delay     atomic.Int64
+	active    atomic.Int32
+	maxActive atomic.Int32
+}
+
+var testDriver = &fakeDriver{shards: make(map[string]*fakeShardDB)}
+
+func init() {
+	sql.Register("schematest", testDriver)
+}
+
+func (d *fakeDriver) shard(dsn string) *fakeShardDB {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	db, ok := d.shards[dsn]
+	if !ok {
+		db = &fakeShardDB{migrations: make(map[int]fakeMigrationRow)}
+		d.shards[dsn] = db
+	}
+	return db
+}
+
+// track records how many shards are executing migration SQL at once
+func (d *fakeDriver) track() {
+	active := d.active.Add(1)
+	defer d.active.Add(-1)
+	for {
+		max := d.maxActive.Load()
+		if active <= max || d.maxActive.CompareAndSwap(max, active) {
+			break
+		}
+	}
+	time.Sleep(time.Duration(d.delay.Load()))
+}
+
+// reset replaces the state behind dsn with an empty database
+func (d *fakeDriver) reset(dsn string) *fakeShardDB {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	db := &fakeShardDB{migrations: make(map[int]fakeMigrationRow)}
+	d.shards[dsn] = db
+	return db
+}
+
+func (d *fakeDriver) Open(dsn string) (driver.Conn, error) {
+	return &fakeConn{db: d.shard(dsn)}, nil
+}
+
+// fakeConn buffers writes made inside a transaction until commit
+type fakeConn struct {
+	db      *fakeShardDB
+	pending []func()
+	inTx    bool
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("prepare not supported")
+}
+
+func (c *fakeConn) Close() error { return nil }
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	c.inTx = true
+	c.pending = nil
+	return c, nil
+}
+
+func (c *fakeConn) Commit() error {
+	c.db.mu.Lock()
+	for _, apply := range c.pending {
+		apply()
+	}
+	c.db.mu.Unlock()
+	c.inTx = false
+	c.pending = nil
+	return nil
+}
+
+func (c *fakeConn) Rollback() error {
+	c.inTx = false
+	c.pending = nil
+	return nil
+}
+
+func (c *fakeConn) write(apply func()) {
+	if c.inTx {
+		c.pending = append(c.pending, apply)
+		return
+	}
+	c.db.mu.Lock()
+	apply()
+	c.db.mu.Unlock()
+}
+
+func (c *fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	q := strings.TrimSpace(query)
+	switch {
+	case strings.HasPrefix(q, "CREATE TABLE IF NOT EXISTS _schema_migrations"),
+		strings.HasPrefix(q, "ALTER TABLE _schema_migrations"):
+	case strings.HasPrefix(q, "INSERT INTO _schema_migrations"):
+		row := fakeMigrationRow{
+			version:  int(args[0].Value.(int64)),
+			name:     args[1].Value.(string),
+			checksum: args[2].Value.(string),
+			upSQL:    args[5].Value.(string),
+			downSQL:  args[6].Value.(string),
+		}
+		c.write(func() { c.db.migrations[row.version] = row })
+	case strings.HasPrefix(q, "DELETE FROM _schema_migrations"):
+		version := int(args[0].Value.(int64))
+		c.write(func() { delete(c.db.migrations, version) })
+	default:
+		if strings.Contains(q, "FAIL") || c.db.broken {
+			return nil, fmt.Errorf("syntax error at %q", q)
+		}
+		testDriver.track()
+		c.write(func() { c.db.executed = append(c.db.executed, q) })
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (c *fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	q := strings.TrimSpace(query)
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+
+	switch {
+	case strings.HasPrefix(q, "SELECT COALESCE(MAX(version), 0)"):
+		max := 0
+		for v := range c.db.migrations {
+			if v > max {
+				max = v
+			}
+		}
+		return &fakeRows{columns: []string{"max"}, values: [][]driver.Value{{int64(max)}}}, nil
+	case strings.HasPrefix(q, "SELECT column_name, pg_get_serial_sequence"):
+		rows := &fakeRows{columns: []string{"column_name", "sequence"}}
+		for _, col := range c.db.columns {
+			var seq driver.Value
+			if col == c.db.pk {
+				seq = "public.users_id_seq"
+			}
+			rows.values = append(rows.values, []driver.Value{col, seq})
+		}
+		return rows, nil
+	case strings.HasPrefix(q, "SELECT a.attname"):
+		return &fakeRows{columns: []string{"attname"}, values: [][]driver.Value{{c.db.pk}}}, nil
+	case strings.HasPrefix(q, "SELECT conrelid::regclass::text"):
+		rows := &fakeRows{columns: []string{"conrelid", "conname", "definition", "referencing"}}
+		for _, fk := range c.db.foreignKeys {
+			rows.values = append(rows.values, []driver.Value{fk.table, fk.name, fk.definition, fk.referencing})
+		}
+		return rows, nil
+	case strings.Contains(q, "LIMIT 1 OFFSET $1"):
+		offset := int(args[0].Value.(int64))
+		var ids []int64
+		for _, id := range c.db.rowIDs {
+			if len(args) < 2 || id > args[1].Value.(int64) {
+				ids = append(ids, id)
+			}
+		}
+		rows := &fakeRows{columns: []string{c.db.pk}}
+		if offset < len(ids) {
+			rows.values = [][]driver.Value{{ids[offset]}}
+		}
+		return rows, nil
+	case strings.HasPrefix(q, "SELECT version, name, checksum"):
+		after := -1
+		if len(args) > 0 {
+			after = int(args[0].Value.(int64))
+		}
+		versions := make([]int, 0, len(c.db.migrations))
+		for v := range c.db.migrations {
+			if v > after {
+				versions = append(versions, v)
+			}
+		}
+		sort.Ints(versions)
+		if strings.Contains(q, "DESC") {
+			sort.Sort(sort.Reverse(sort.IntSlice(versions)))
+		}
+		rows := &fakeRows{columns: []string{"version", "name", "checksum", "applied_at", "duration_ms", "up_sql", "down_sql"}}
+		for _, v := range versions {
+			row := c.db.migrations[v]
+			rows.values = append(rows.values, []driver.Value{int64(row.version), row.name, row.checksum, time.Now(), int64(0), row.upSQL, row.downSQL})
+		}
+		return rows, nil
+	}
+	return nil, fmt.Errorf("unexpected query %q", q)
+}
+
+type fakeRows struct {
+	columns []string
+	values  [][]driver.Value
+	next    int
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.next >= len(r.values) {
+		return io.EOF
+	}
+	copy(dest, r.values[r.next])
+	r.next++
+	return nil
+}
+
+func newTestManager(t *testing.T) *Manager {
+	t.Helper()
+	m := NewManager(zaptest.NewLogger(t))
+	m.driver = "schematest"
+	return m
+}
+
+// testShards returns shard connections whose fake databases are unique to the test
+func testShards(t *testing.T, n int) ([]ShardConnection, []*fakeShardDB) {
+	t.Helper()
+	shards := make([]ShardConnection, 0, n)
+	dbs := make([]*fakeShardDB, 0, n)
+	for i := 0; i < n; i++ {
+		shard := ShardConnection{
+			ID:       fmt.Sprintf("shard-%d", i),
+			Name:     fmt.Sprintf("shard-%d", i),
+			Host:     fmt.Sprintf("%s-%d", t.Name(), i),
+			Port:     5432,
+			Database: "app",
+		}
+		shards = append(shards, shard)
+		dbs = append(dbs, testDriver.reset(fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable",
+			shard.Host, shard.Port, shard.Username, shard.Password, shard.Database)))
+	}
+	return shards, dbs
+}
+
+func registerTestMigrations(t *testing.T, m *Manager) {
+	t.Helper()
+	migrations := []struct{ up, down string }{
+		{"CREATE TABLE users (id int)", "DROP TABLE users"},
+		{"ALTER TABLE users ADD COLUMN email text", "ALTER TABLE users DROP COLUMN email"},
+		{"CREATE INDEX idx_users_email ON users(email)", "DROP INDEX idx_users_email"},
+	}
+	for i, mig := range migrations {
+		if err := m.RegisterReversibleMigration(i+1, fmt.Sprintf("m%d", i+1), "", mig.up, mig.down); err != nil {
+			t.Fatalf("failed to register migration: %v", err)
+		}
+	}
+}
+
+func TestManager_RollbackTo(t *testing.T) {
+	m := newTestManager(t)
+	registerTestMigrations(t, m)
+	shards, dbs := testShards(t, 3)
+	ctx := context.Background()
+
+	if _, err := m.ApplyMigrations(ctx, shards); err != nil {
+		t.Fatalf("failed to apply migrations: %v", err)
+	}
+
+	history, err := m.GetMigrationHistory(ctx, shards[0])
+	if err != nil {
+		t.Fatalf("failed to get history: %v", err)
+	}
+	if len(history) != 3 || history[2].DownSQL != "DROP INDEX idx_users_email" {
+		t.Fatalf("expected history with down SQL, got %+v", history)
+	}
+
+	statuses, err := m.RollbackTo(ctx, shards, 1)
+	if err != nil {
+		t.Fatalf("failed to roll back: %v", err)
+	}
+	if len(statuses) != 6 {
+		t.Errorf("expected 2 rolled back versions on 3 shards, got %d statuses", len(statuses))
+	}
+
+	for i, db := range dbs {
+		if len(db.migrations) != 1 {
+			t.Errorf("shard %d: expected only version 1 to remain, got %d", i, len(db.migrations))
+		}
+		want := []string{
+			"CREATE TABLE users (id int)",
+			"ALTER TABLE users ADD COLUMN email text",
+			"CREATE INDEX idx_users_email ON users(email)",
+			"DROP INDEX idx_users_email",
+			"ALTER TABLE users DROP COLUMN email",
+		}
+		if strings.Join(db.executed, ";") != strings.Join(want, ";") {
+			t.Errorf("shard %d: expected down migrations in reverse order, got %v", i, db.executed)
+		}
+	}
+
+	// Reverted versions are unregistered so they can be replaced
+	if len(m.ListMigrations()) != 1 {
+		t.Errorf("expected reverted migrations to be unregistered, got %d", len(m.ListMigrations()))
+	}
+	if err := m.RegisterReversibleMigration(2, "m2-fixed", "", "ALTER TABLE users ADD COLUMN mail text", "ALTER TABLE users DROP COLUMN mail"); err != nil {
+		t.Errorf("expected to register a replacement migration, got %v", err)
+	}
+}
+
+func TestManager_RollbackTo_FailureIsTransactional(t *testing.T) {
+	m := newTestManager(t)
+	if err := m.RegisterReversibleMigration(1, "m1", "", "CREATE TABLE a (id int)", "DROP TABLE a FAIL"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.RegisterReversibleMigration(2, "m2", "", "CREATE TABLE b (id int)", "DROP TABLE b"); err != nil {
+		t.Fatal(err)
+	}
+	shards, dbs := testShards(t, 1)
+	ctx := context.Background()
+
+	if _, err := m.ApplyMigrations(ctx, shards); err != nil {
+		t.Fatalf("failed to apply migrations: %v", err)
+	}
+
+	statuses, err := m.RollbackTo(ctx, shards, 0)
+	if err == nil {
+		t.Fatal("expected rollback to fail")
+	}
+	if len(statuses) != 1 || statuses[0].Status != "failed" || statuses[0].Version != 1 {
+		t.Errorf("expected failed status for version 1, got %+v", statuses)
+	}
+
+	// Version 2's down migration ran inside the aborted transaction and must not be visible
+	if len(dbs[0].migrations) != 2 {
+		t.Errorf("expected shard to stay at version 2, got %d migrations", len(dbs[0].migrations))
+	}
+	if len(dbs[0].executed) != 2 {
+		t.Errorf("expected no down migrations to be committed, got %v", dbs[0].executed)
+	}
+	if len(m.ListMigrations()) != 2 {
+		t.Errorf("expected migrations to stay registered after a failed rollback")
+	}
+}
+
+func TestManager_RollbackTo_RequiresDownMigration(t *testing.T) {
+	m := newTestManager(t)
+	if err := m.RegisterMigration(1, "m1", "", "CREATE TABLE a (id int)"); err != nil {
+		t.Fatal(err)
+	}
+	shards, _ := testShards(t, 1)
+	ctx := context.Background()
+
+	if _, err := m.ApplyMigrations(ctx, shards); err != nil {
+		t.Fatalf("failed to apply migrations: %v", err)
+	}
+	if _, err := m.RollbackTo(ctx, shards, 0); err == nil {
+		t.Error("expected rollback of irreversible migration to fail")
+	}
+}
+
+func TestManager_ApplyMigrations_BoundedParallelism(t *testing.T) {
+	m := newTestManager(t)
+	registerTestMigrations(t, m)
+	shards, dbs := testShards(t, 8)
+
+	testDriver.delay.Store(int64(20 * time.Millisecond))
+	testDriver.maxActive.Store(0)
+	defer testDriver.delay.Store(0)
+
+	statuses, err := m.ApplyMigrationsWithOptions(context.Background(), shards, ApplyOptions{Parallelism: 3})
+	if err != nil {
+		t.Fatalf("failed to apply migrations: %v", err)
+	}
+
+	if max := testDriver.maxActive.Load(); max < 2 || max > 3 {
+		t.Errorf("expected between 2 and 3 shards migrated at once, got %d", max)
+	}
+
+	// Three migrations per shard, reported in shard order
+	if len(statuses) != 24 {
+		t.Fatalf("expected 24 statuses, got %d", len(statuses))
+	}
+	for i, status := range statuses {
+		wantShard := shards[i/3].ID
+		if status.ShardID != wantShard || status.Version != i%3+1 || status.Status != "applied" {
+			t.Errorf("status %d: expected %s v%d applied, got %+v", i, wantShard, i%3+1, status)
+		}
+	}
+	for i, db := range dbs {
+		if len(db.migrations) != 3 {
+			t.Errorf("shard %d: expected 3 applied migrations, got %d", i, len(db.migrations))
+		}
+	}
+}
+
+func TestManager_ApplyMigrations_StopOnError(t *testing.T) {
+	m := newTestManager(t)
+	registerTestMigrations(t, m)
+	shards, dbs := testShards(t, 3)
+	dbs[0].broken = true
+
+	statuses, err := m.ApplyMigrationsWithOptions(context.Background(), shards, ApplyOptions{Parallelism: 1, StopOnError: true})
+	if err == nil {
+		t.Fatal("expected rollout to fail")
+	}
+
+	if len(statuses) != 3 {
+		t.Fatalf("expected one status per shard, got %+v", statuses)
+	}
+	if statuses[0].Status != "failed" {
+		t.Errorf("expected first shard to fail, got %+v", statuses[0])
+	}
+	for _, status := range statuses[1:] {
+		if status.Status != "skipped" {
+			t.Errorf("expected remaining shards to be skipped, got %+v", status)
+		}
+	}
+	for i, db := range dbs[1:] {
+		if len(db.migrations) != 0 {
+			t.Errorf("shard %d: expected no migrations after stop, got %d", i+1, len(db.migrations))
+		}
+	}
+}
+
+func TestManager_ApplyMigrations_NoDuplicateApplication(t *testing.T) {
+	m := newTestManager(t)
+	registerTestMigrations(t, m)
+	shards, dbs := testShards(t, 1)
+
+	// The same shard listed twice and two concurrent rollouts still apply each version once
+	doubled := []ShardConnection{shards[0], shards[0]}
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := m.ApplyMigrations(context.Background(), doubled); err != nil {
+				t.Errorf("failed to apply migrations: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(dbs[0].executed) != 3 {
+		t.Errorf("expected each migration to run once, got %v", dbs[0].executed)
+	}
+}
+
+func TestParseOnlineAlter(t *testing.T) {
+	tests := []struct {
+		statement string
+		online    bool
+		schema    string
+		table     string
+	}{
+		{"ALTER TABLE users ADD COLUMN status text NOT NULL DEFAULT 'active'", true, "", "users"},
+		{"alter table public.Users add status text default now();", true, "public", "users"},
+		{`ALTER TABLE "Events" ALTER COLUMN payload TYPE jsonb USING payload::jsonb`, true, "", "Events"},
+		{"ALTER TABLE users ADD COLUMN nickname text", false, "", ""},
+		{"ALTER TABLE users DROP COLUMN nickname", false, "", ""},
+		{"CREATE INDEX idx_users_email ON users(email)", false, "", ""},
+		{"ALTER TABLE users ADD COLUMN a int DEFAULT 1; DROP TABLE orders", false, "", ""},
+	}
+	for _, tt := range tests {
+		alter, online := parseOnlineAlter(tt.statement)
+		if online != tt.online {
+			t.Errorf("%q: expected online=%v, got %v", tt.statement, tt.online, online)
+			continue
+		}
+		if online && (alter.schema != tt.schema || alter.table != tt.table) {
+			t.Errorf("%q: expected table %s.%s, got %s.%s", tt.statement, tt.schema, tt.table, alter.schema, alter.table)
+		}
+	}
+}
+
+func TestManager_ApplyMigrations_OnlineAddColumnWithDefault(t *testing.T) {
+	m := newTestManager(t)
+	m.onlineBatchSize = 2
+	alter := "ALTER TABLE users ADD COLUMN status text NOT NULL DEFAULT 'active'"
+	if err := m.RegisterMigration(1, "add_status", "", alter); err != nil {
+		t.Fatal(err)
+	}
+	shards, dbs := testShards(t, 1)
+	dbs[0].columns = []string{"id", "email"}
+	dbs[0].pk = "id"
+	dbs[0].rowIDs = []int64{1, 2, 3, 4, 5}
+
+	statuses, err := m.ApplyMigrationsWithOptions(context.Background(), shards, ApplyOptions{Online: true})
+	if err != nil {
+		t.Fatalf("failed to apply online migration: %v", err)
+	}
+	if len(statuses) != 1 || statuses[0].Status != "applied" {
+		t.Fatalf("expected migration to be applied, got %+v", statuses)
+	}
+	if len(dbs[0].migrations) != 1 {
+		t.Errorf("expected migration to be recorded, got %d", len(dbs[0].migrations))
+	}
+
+	executed := dbs[0].executed
+	for _, statement := range executed {
+		if statement == alter {
+			t.Fatalf("expected the alteration not to run directly on the live table")
+		}
+	}
+
+	want := []string{
+		`CREATE TABLE "users__online" (LIKE "users" INCLUDING ALL)`,
+		`ALTER TABLE "users__online" ADD COLUMN status text NOT NULL DEFAULT 'active'`,
+		`CREATE TABLE "users__online_deleted" AS SELECT "id" FROM "users" WITH NO DATA`,
+		`ALTER TABLE "users__online_deleted" ADD PRIMARY KEY ("id")`,
+		`CREATE OR REPLACE FUNCTION "users__online_sync"()`,
+		`CREATE TRIGGER "users__online_sync" AFTER INSERT OR UPDATE OR DELETE ON "users"`,
+		`INSERT INTO "users__online" ("id", "email") SELECT "id", "email" FROM "users" WHERE "id" <= $1`,
+		`INSERT INTO "users__online" ("id", "email") SELECT "id", "email" FROM "users" WHERE "id" > $1 AND "id" <= $2`,
+		`INSERT INTO "users__online" ("id", "email") SELECT "id", "email" FROM "users" WHERE "id" > $1 ON CONFLICT`,
+		`LOCK TABLE "users" IN ACCESS EXCLUSIVE MODE`,
+		`DROP TRIGGER "users__online_sync" ON "users"`,
+		`DELETE FROM "users__online" WHERE "id" IN (SELECT "id" FROM "users__online_deleted")`,
+		`ALTER SEQUENCE public.users_id_seq OWNED BY "users__online"."id"`,
+		`ALTER TABLE "users" RENAME TO "users__old"`,
+		`ALTER TABLE "users__online" RENAME TO "users"`,
+		`DROP TABLE "users__old"`,
+		`DROP TABLE "users__online_deleted"`,
+		`DROP FUNCTION "users__online_sync"()`,
+	}
+	if len(executed) != len(want) {
+		t.Fatalf("expected %d statements, got %d: %v", len(want), len(executed), executed)
+	}
+	for i, prefix := range want {
+		if !strings.HasPrefix(executed[i], prefix) {
+			t.Errorf("statement %d: expected prefix %q, got %q", i, prefix, executed[i])
+		}
+	}
+}
+
+func TestManager_ApplyMigrations_OnlineKeepsForeignKeys(t *testing.T) {
+	m := newTestManager(t)
+	alter := "ALTER TABLE users ADD COLUMN status text NOT NULL DEFAULT 'active'"
+	if err := m.RegisterMigration(1, "add_status", "", alter); err != nil {
+		t.Fatal(err)
+	}
+	shards, dbs := testShards(t, 1)
+	dbs[0].columns = []string{"id", "team_id"}
+	dbs[0].pk = "id"
+	dbs[0].foreignKeys = []foreignKey{
+		{table: "orders", name: "orders_user_id_fkey", definition: "FOREIGN KEY (user_id) REFERENCES users(id)", referencing: true},
+		{table: "users", name: "users_team_id_fkey", definition: "FOREIGN KEY (team_id) REFERENCES teams(id)"},
+	}
+
+	if _, err := m.ApplyMigrationsWithOptions(context.Background(), shards, ApplyOptions{Online: true}); err != nil {
+		t.Fatalf("failed to apply online migration: %v", err)
+	}
+
+	// The referencing constraint is dropped before the old table is, and
+	// both are recreated on the new table before the swap commits
+	want := []string{
+		`ALTER TABLE orders DROP CONSTRAINT "orders_user_id_fkey"`,
+		`DROP TABLE "users__old"`,
+		`ALTER TABLE orders ADD CONSTRAINT "orders_user_id_fkey" FOREIGN KEY (user_id) REFERENCES users(id) NOT VALID`,
+		`ALTER TABLE users ADD CONSTRAINT "users_team_id_fkey" FOREIGN KEY (team_id) REFERENCES teams(id) NOT VALID`,
+		`ALTER TABLE orders VALIDATE CONSTRAINT "orders_user_id_fkey"`,
+		`ALTER TABLE users VALIDATE CONSTRAINT "users_team_id_fkey"`,
+	}
+	next := 0
+	for _, statement := range dbs[0].executed {
+		if next < len(want) && statement == want[next] {
+			next++
+		}
+		if strings.Contains(statement, `DROP CONSTRAINT "users_team_id_fkey"`) {
+			t.Errorf("expected the table's own constraint to go with the old table, got %q", statement)
+		}
+	}
+	if next != len(want) {
+		t.Errorf("expected %q in order, got %v", want[next], dbs[0].executed)
+	}
+}
+
+func TestManager_ApplyMigrations_DirectWithoutOnline(t *testing.T) {
+	m := newTestManager(t)
+	alter := "ALTER TABLE users ADD COLUMN status text NOT NULL DEFAULT 'active'"
+	if err := m.RegisterMigration(1, "add_status", "", alter); err != nil {
+		t.Fatal(err)
+	}
+	shards, dbs := testShards(t, 1)
+
+	if _, err := m.ApplyMigrations(context.Background(), shards); err != nil {
+		t.Fatalf("failed to apply migration: %v", err)
+	}
+	if len(dbs[0].executed) != 1 || dbs[0].executed[0] != alter {
+		t.Errorf("expected direct DDL, got %v", dbs[0].executed)
+	}
+}
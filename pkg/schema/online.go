@@ -0,0 +1,425 @@
+package schema
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+)
+
+// DefaultOnlineBatchSize is the number of rows copied per backfill batch
+const DefaultOnlineBatchSize = 1000
+
+var (
+	alterTablePattern = regexp.MustCompile(`(?is)^\s*ALTER\s+TABLE\s+(?:ONLY\s+)?((?:"[^"]+"|\w+)(?:\.(?:"[^"]+"|\w+))?)\s+(.+?)\s*;?\s*$`)
+
+	// Actions that rewrite or scan the whole table under an exclusive lock
+	addColumnDefaultPattern = regexp.MustCompile(`(?is)^ADD\s+(?:COLUMN\s+)?(?:IF\s+NOT\s+EXISTS\s+)?\S+\s+.*\bDEFAULT\b`)
+	alterColumnTypePattern  = regexp.MustCompile(`(?is)^ALTER\s+(?:COLUMN\s+)?\S+\s+(?:SET\s+DATA\s+)?TYPE\b`)
+)
+
+// onlineAlter is an ALTER TABLE statement that is applied through a shadow table
+type onlineAlter struct {
+	schema string // empty for the connection's current schema
+	table  string
+	action string
+}
+
+// parseOnlineAlter returns the alteration if the statement needs the online
+// path. Statements that are already cheap, or that we cannot rewrite, are
+// applied directly.
+func parseOnlineAlter(statement string) (*onlineAlter, bool) {
+	match := alterTablePattern.FindStringSubmatch(statement)
+	if match == nil {
+		return nil, false
+	}
+	action := match[2]
+	if strings.Contains(action, ";") {
+		return nil, false // more than one statement
+	}
+	if !addColumnDefaultPattern.MatchString(action) && !alterColumnTypePattern.MatchString(action) {
+		return nil, false
+	}
+
+	alter := &onlineAlter{action: action}
+	parts := strings.SplitN(match[1], ".", 2)
+	if len(parts) == 2 {
+		alter.schema = unquoteIdent(parts[0])
+		alter.table = unquoteIdent(parts[1])
+	} else {
+		alter.table = unquoteIdent(parts[0])
+	}
+	return alter, true
+}
+
+func unquoteIdent(ident string) string {
+	if strings.HasPrefix(ident, `"`) && strings.HasSuffix(ident, `"`) {
+		return strings.ReplaceAll(ident[1:len(ident)-1], `""`, `"`)
+	}
+	return strings.ToLower(ident)
+}
+
+// qualified quotes a table name in the alteration's schema
+func (a *onlineAlter) qualified(table string) string {
+	if a.schema == "" {
+		return pq.QuoteIdentifier(table)
+	}
+	return pq.QuoteIdentifier(a.schema) + "." + pq.QuoteIdentifier(table)
+}
+
+func (a *onlineAlter) shadowTable() string { return a.table + "__online" }
+func (a *onlineAlter) oldTable() string    { return a.table + "__old" }
+func (a *onlineAlter) deleteLog() string   { return a.table + "__online_deleted" }
+func (a *onlineAlter) syncFunction() string {
+	return a.qualified(a.table + "__online_sync")
+}
+func (a *onlineAlter) syncTrigger() string {
+	return pq.QuoteIdentifier(a.table + "__online_sync")
+}
+
+type tableColumn struct {
+	name     string
+	sequence sql.NullString // owned serial sequence, if any
+}
+
+// foreignKey is a foreign key constraint on, or referencing, the altered table
+type foreignKey struct {
+	table       string // constrained table, as a regclass name
+	name        string
+	definition  string
+	referencing bool // constrains another table
+}
+
+// applyOnline applies an alteration without holding a long exclusive lock:
+// the change is made on an empty shadow copy of the table, a trigger mirrors
+// concurrent writes into the shadow and logs deleted keys, existing rows are
+// backfilled in primary-key batches, and the tables are swapped in a short
+// transaction that first removes the logged keys the backfill copied back.
+// Foreign keys, which the shadow copy does not carry, are recreated at the
+// swap and validated after it.
+func (m *Manager) applyOnline(ctx context.Context, db *sql.DB, alter *onlineAlter) error {
+	columns, pk, err := m.describeTable(ctx, db, alter)
+	if err != nil {
+		return err
+	}
+	foreignKeys, err := m.describeForeignKeys(ctx, db, alter)
+	if err != nil {
+		return err
+	}
+
+	source := alter.qualified(alter.table)
+	shadow := alter.qualified(alter.shadowTable())
+
+	if err := m.createShadow(ctx, db, alter, columns, pk); err != nil {
+		m.cleanupOnline(db, alter)
+		return err
+	}
+
+	copied, err := m.backfill(ctx, db, source, shadow, columns, pk)
+	if err != nil {
+		m.cleanupOnline(db, alter)
+		return fmt.Errorf("backfill failed: %w", err)
+	}
+
+	if err := m.swapShadow(ctx, db, alter, columns, pk, foreignKeys); err != nil {
+		m.cleanupOnline(db, alter)
+		return fmt.Errorf("swap failed: %w", err)
+	}
+	m.validateForeignKeys(ctx, db, alter, foreignKeys)
+
+	m.logger.Info("applied online schema change",
+		zap.String("table", alter.table),
+		zap.Int64("rows_copied", copied))
+	return nil
+}
+
+// describeTable returns the table's columns and its single-column primary key
+func (m *Manager) describeTable(ctx context.Context, db *sql.DB, alter *onlineAlter) ([]tableColumn, string, error) {
+	regclass := alter.qualified(alter.table)
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT column_name, pg_get_serial_sequence($1, column_name)
+		FROM information_schema.columns
+		WHERE table_schema = COALESCE(NULLIF($2, ''), current_schema()) AND table_name = $3
+		ORDER BY ordinal_position
+	`, regclass, alter.schema, alter.table)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read columns: %w", err)
+	}
+	var columns []tableColumn
+	for rows.Next() {
+		var col tableColumn
+		if err := rows.Scan(&col.name, &col.sequence); err != nil {
+			rows.Close()
+			return nil, "", err
+		}
+		columns = append(columns, col)
+	}
+	rows.Close()
+	if len(columns) == 0 {
+		return nil, "", fmt.Errorf("table %s not found", alter.table)
+	}
+
+	rows, err = db.QueryContext(ctx, `
+		SELECT a.attname
+		FROM pg_index i
+		JOIN pg_attribute a ON a.attrelid = i.indrelid AND a.attnum = ANY(i.indkey)
+		WHERE i.indrelid = $1::regclass AND i.indisprimary
+	`, regclass)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read primary key: %w", err)
+	}
+	var pk []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return nil, "", err
+		}
+		pk = append(pk, name)
+	}
+	rows.Close()
+	if len(pk) != 1 {
+		return nil, "", fmt.Errorf("online schema change requires a single-column primary key on %s", alter.table)
+	}
+
+	return columns, pk[0], nil
+}
+
+// describeForeignKeys returns the foreign keys of the table and those of
+// other tables referencing it
+func (m *Manager) describeForeignKeys(ctx context.Context, db *sql.DB, alter *onlineAlter) ([]foreignKey, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT conrelid::regclass::text, conname, pg_get_constraintdef(oid), conrelid <> $1::regclass
+		FROM pg_constraint
+		WHERE contype = 'f' AND (conrelid = $1::regclass OR confrelid = $1::regclass)
+		ORDER BY conrelid::regclass::text, conname
+	`, alter.qualified(alter.table))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read foreign keys: %w", err)
+	}
+	defer rows.Close()
+
+	var foreignKeys []foreignKey
+	for rows.Next() {
+		var fk foreignKey
+		if err := rows.Scan(&fk.table, &fk.name, &fk.definition, &fk.referencing); err != nil {
+			return nil, err
+		}
+		foreignKeys = append(foreignKeys, fk)
+	}
+	return foreignKeys, rows.Err()
+}
+
+// createShadow creates the altered shadow table, the log of deleted keys and
+// the trigger keeping both in sync. A delete can commit while a backfill batch
+// still copies the deleted row, so the trigger logs the key for the swap to
+// remove again; a key written again leaves the log.
+func (m *Manager) createShadow(ctx context.Context, db *sql.DB, alter *onlineAlter, columns []tableColumn, pk string) error {
+	source := alter.qualified(alter.table)
+	shadow := alter.qualified(alter.shadowTable())
+	deleteLog := alter.qualified(alter.deleteLog())
+	key := pq.QuoteIdentifier(pk)
+
+	names := make([]string, len(columns))
+	values := make([]string, len(columns))
+	updates := make([]string, len(columns))
+	for i, col := range columns {
+		names[i] = pq.QuoteIdentifier(col.name)
+		values[i] = "NEW." + names[i]
+		updates[i] = names[i] + " = EXCLUDED." + names[i]
+	}
+
+	statements := []string{
+		fmt.Sprintf("CREATE TABLE %s (LIKE %s INCLUDING ALL)", shadow, source),
+		fmt.Sprintf("ALTER TABLE %s %s", shadow, alter.action),
+		fmt.Sprintf("CREATE TABLE %s AS SELECT %s FROM %s WITH NO DATA", deleteLog, key, source),
+		fmt.Sprintf("ALTER TABLE %s ADD PRIMARY KEY (%s)", deleteLog, key),
+		fmt.Sprintf(`CREATE OR REPLACE FUNCTION %s() RETURNS trigger LANGUAGE plpgsql AS $$
+BEGIN
+	IF TG_OP = 'DELETE' THEN
+		DELETE FROM %s WHERE %s = OLD.%s;
+		INSERT INTO %s (%s) VALUES (OLD.%s) ON CONFLICT DO NOTHING;
+		RETURN OLD;
+	END IF;
+	IF TG_OP = 'UPDATE' AND NEW.%s IS DISTINCT FROM OLD.%s THEN
+		DELETE FROM %s WHERE %s = OLD.%s;
+		INSERT INTO %s (%s) VALUES (OLD.%s) ON CONFLICT DO NOTHING;
+	END IF;
+	DELETE FROM %s WHERE %s = NEW.%s;
+	INSERT INTO %s (%s) VALUES (%s)
+	ON CONFLICT (%s) DO UPDATE SET %s;
+	RETURN NEW;
+END
+$$`, alter.syncFunction(),
+			shadow, key, key,
+			deleteLog, key, key,
+			key, key,
+			shadow, key, key,
+			deleteLog, key, key,
+			deleteLog, key, key,
+			shadow, strings.Join(names, ", "), strings.Join(values, ", "),
+			key, strings.Join(updates, ", ")),
+		fmt.Sprintf("CREATE TRIGGER %s AFTER INSERT OR UPDATE OR DELETE ON %s FOR EACH ROW EXECUTE FUNCTION %s()",
+			alter.syncTrigger(), source, alter.syncFunction()),
+	}
+
+	for _, statement := range statements {
+		if _, err := db.ExecContext(ctx, statement); err != nil {
+			return fmt.Errorf("failed to prepare shadow table: %w", err)
+		}
+	}
+	return nil
+}
+
+// backfill copies existing rows into the shadow table in primary-key order.
+// Rows the trigger already mirrored are left alone, since they are newer.
+func (m *Manager) backfill(ctx context.Context, db *sql.DB, source, shadow string, columns []tableColumn, pk string) (int64, error) {
+	key := pq.QuoteIdentifier(pk)
+	names := make([]string, len(columns))
+	for i, col := range columns {
+		names[i] = pq.QuoteIdentifier(col.name)
+	}
+	columnList := strings.Join(names, ", ")
+
+	var lower interface{}
+	var copied int64
+	for {
+		// Find the last key of the next batch
+		boundaryQuery := fmt.Sprintf("SELECT %s FROM %s ORDER BY %s LIMIT 1 OFFSET $1", key, source, key)
+		args := []interface{}{m.onlineBatchSize - 1}
+		if lower != nil {
+			boundaryQuery = fmt.Sprintf("SELECT %s FROM %s WHERE %s > $2 ORDER BY %s LIMIT 1 OFFSET $1", key, source, key, key)
+			args = append(args, lower)
+		}
+
+		var upper interface{}
+		err := db.QueryRowContext(ctx, boundaryQuery, args...).Scan(&upper)
+		if err != nil && err != sql.ErrNoRows {
+			return copied, err
+		}
+
+		var conditions []string
+		var insertArgs []interface{}
+		if lower != nil {
+			insertArgs = append(insertArgs, lower)
+			conditions = append(conditions, fmt.Sprintf("%s > $%d", key, len(insertArgs)))
+		}
+		if upper != nil {
+			insertArgs = append(insertArgs, upper)
+			conditions = append(conditions, fmt.Sprintf("%s <= $%d", key, len(insertArgs)))
+		}
+		where := ""
+		if len(conditions) > 0 {
+			where = " WHERE " + strings.Join(conditions, " AND ")
+		}
+
+		result, err := db.ExecContext(ctx, fmt.Sprintf(
+			"INSERT INTO %s (%s) SELECT %s FROM %s%s ON CONFLICT (%s) DO NOTHING",
+			shadow, columnList, columnList, source, where, key), insertArgs...)
+		if err != nil {
+			return copied, err
+		}
+		if n, err := result.RowsAffected(); err == nil {
+			copied += n
+		}
+
+		if upper == nil {
+			return copied, nil // last batch
+		}
+		lower = upper
+	}
+}
+
+// swapShadow replaces the table with its shadow under a brief exclusive lock.
+// With writes blocked, keys deleted during the backfill are removed from the
+// shadow, and the foreign keys are moved over to it unvalidated.
+func (m *Manager) swapShadow(ctx context.Context, db *sql.DB, alter *onlineAlter, columns []tableColumn, pk string, foreignKeys []foreignKey) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	source := alter.qualified(alter.table)
+	shadow := alter.qualified(alter.shadowTable())
+	deleteLog := alter.qualified(alter.deleteLog())
+	key := pq.QuoteIdentifier(pk)
+
+	statements := []string{
+		fmt.Sprintf("LOCK TABLE %s IN ACCESS EXCLUSIVE MODE", source),
+		fmt.Sprintf("DROP TRIGGER %s ON %s", alter.syncTrigger(), source),
+		fmt.Sprintf("DELETE FROM %s WHERE %s IN (SELECT %s FROM %s)", shadow, key, key, deleteLog),
+	}
+	// Constraints referencing the old table would keep it from being dropped
+	for _, fk := range foreignKeys {
+		if fk.referencing {
+			statements = append(statements, fmt.Sprintf("ALTER TABLE %s DROP CONSTRAINT %s", fk.table, pq.QuoteIdentifier(fk.name)))
+		}
+	}
+	// Serial sequences are owned by the old table and would be dropped with it
+	for _, col := range columns {
+		if col.sequence.Valid {
+			statements = append(statements, fmt.Sprintf("ALTER SEQUENCE %s OWNED BY %s.%s",
+				col.sequence.String, shadow, pq.QuoteIdentifier(col.name)))
+		}
+	}
+	statements = append(statements,
+		fmt.Sprintf("ALTER TABLE %s RENAME TO %s", source, pq.QuoteIdentifier(alter.oldTable())),
+		fmt.Sprintf("ALTER TABLE %s RENAME TO %s", shadow, pq.QuoteIdentifier(alter.table)),
+		fmt.Sprintf("DROP TABLE %s", alter.qualified(alter.oldTable())),
+		fmt.Sprintf("DROP TABLE %s", deleteLog),
+		fmt.Sprintf("DROP FUNCTION %s()", alter.syncFunction()),
+	)
+	// Constraint definitions name the table, which now resolves to the new one.
+	// NOT VALID checks new writes without scanning the table under the lock.
+	for _, fk := range foreignKeys {
+		statements = append(statements, fmt.Sprintf("ALTER TABLE %s ADD CONSTRAINT %s %s NOT VALID",
+			fk.table, pq.QuoteIdentifier(fk.name), fk.definition))
+	}
+
+	for _, statement := range statements {
+		if _, err := tx.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// validateForeignKeys validates the foreign keys recreated at the swap, which
+// scans the tables without blocking writes. A constraint that fails stays in
+// place, enforced for new writes, for an operator to resolve.
+func (m *Manager) validateForeignKeys(ctx context.Context, db *sql.DB, alter *onlineAlter, foreignKeys []foreignKey) {
+	for _, fk := range foreignKeys {
+		statement := fmt.Sprintf("ALTER TABLE %s VALIDATE CONSTRAINT %s", fk.table, pq.QuoteIdentifier(fk.name))
+		if _, err := db.ExecContext(ctx, statement); err != nil {
+			m.logger.Error("foreign key failed validation after online schema change",
+				zap.String("table", alter.table),
+				zap.String("constrained_table", fk.table),
+				zap.String("constraint", fk.name),
+				zap.Error(err))
+		}
+	}
+}
+
+// cleanupOnline removes the shadow table and sync trigger after a failure
+func (m *Manager) cleanupOnline(db *sql.DB, alter *onlineAlter) {
+	ctx := context.Background()
+	statements := []string{
+		fmt.Sprintf("DROP TRIGGER IF EXISTS %s ON %s", alter.syncTrigger(), alter.qualified(alter.table)),
+		fmt.Sprintf("DROP FUNCTION IF EXISTS %s()", alter.syncFunction()),
+		fmt.Sprintf("DROP TABLE IF EXISTS %s", alter.qualified(alter.shadowTable())),
+		fmt.Sprintf("DROP TABLE IF EXISTS %s", alter.qualified(alter.deleteLog())),
+	}
+	for _, statement := range statements {
+		if _, err := db.ExecContext(ctx, statement); err != nil {
+			m.logger.Warn("failed to clean up online schema change",
+				zap.String("table", alter.table),
+				zap.Error(err))
+		}
+	}
+}
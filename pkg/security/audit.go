@@ -1,11 +1,14 @@
 package security
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"os"
 	"sync"
 	"time"
+
+	"github.com/sharding-system/pkg/logging"
 )
 
 // AuditLogger logs audit events
@@ -25,6 +28,7 @@ type AuditEvent struct {
 	Success    bool      `json:"success"`
 	Error      string    `json:"error,omitempty"`
 	IP         string    `json:"ip,omitempty"`
+	RequestID  string    `json:"request_id,omitempty"`
 }
 
 // NewAuditLogger creates a new audit logger
@@ -52,6 +56,15 @@ func (a *AuditLogger) Log(event AuditEvent) {
 	}
 }
 
+// LogContext logs an audit event, tagging it with the ID of the request in ctx
+// so it can be correlated with that request's other log lines
+func (a *AuditLogger) LogContext(ctx context.Context, event AuditEvent) {
+	if event.RequestID == "" {
+		event.RequestID = logging.RequestIDFromContext(ctx)
+	}
+	a.Log(event)
+}
+
 // Close closes the audit logger
 func (a *AuditLogger) Close() error {
 	a.mu.Lock()
@@ -19,8 +19,13 @@ func NewRBAC() *RBAC {
 	rbac.AddPermission("admin", "*", []string{"*"}) // Admin can do everything
 	rbac.AddPermission("operator", "shards", []string{"read", "create", "update"})
 	rbac.AddPermission("operator", "reshard", []string{"read", "create"})
+	rbac.AddPermission("operator", "backends", []string{"cancel", "terminate"})
+	rbac.AddPermission("operator", "failover", []string{"switchover"})
+	rbac.AddPermission("operator", "router", []string{"read", "refresh", "throttle"})
+	rbac.AddPermission("operator", "client_apps", []string{"delete"})
 	rbac.AddPermission("viewer", "shards", []string{"read"})
 	rbac.AddPermission("viewer", "reshard", []string{"read"})
+	rbac.AddPermission("viewer", "router", []string{"read"})
 
 	return rbac
 }
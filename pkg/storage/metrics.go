@@ -0,0 +1,129 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/sharding-system/pkg/observability"
+)
+
+// InstrumentedStorage wraps an ObjectStorage backend and records the latency
+// and outcome of each operation, and the bytes uploaded and downloaded,
+// labeled by backend type
+type InstrumentedStorage struct {
+	backend string
+	store   ObjectStorage
+}
+
+// Instrument wraps store so its operations are recorded under backend, the
+// storage type such as "s3" or "local"
+func Instrument(backend string, store ObjectStorage) *InstrumentedStorage {
+	return &InstrumentedStorage{backend: backend, store: store}
+}
+
+// Unwrap returns the instrumented backend
+func (s *InstrumentedStorage) Unwrap() ObjectStorage {
+	return s.store
+}
+
+// observe records an operation started at start that ended with err
+func (s *InstrumentedStorage) observe(operation string, start time.Time, err error) {
+	status := "success"
+	if err != nil {
+		status = "error"
+	}
+	observability.ObjectStorageDuration.WithLabelValues(s.backend, operation).Observe(time.Since(start).Seconds())
+	observability.ObjectStorageOperations.WithLabelValues(s.backend, operation, status).Inc()
+}
+
+func (s *InstrumentedStorage) Upload(ctx context.Context, bucket, key string, data io.Reader, metadata map[string]string) error {
+	start := time.Now()
+	counted := &countingReader{r: data}
+	err := s.store.Upload(ctx, bucket, key, counted, metadata)
+	s.observe("upload", start, err)
+	if err == nil {
+		observability.ObjectStorageBytes.WithLabelValues(s.backend, "upload").Add(float64(counted.n))
+	}
+	return err
+}
+
+// Download records the time until the object starts streaming; the bytes are
+// counted as the caller reads them
+func (s *InstrumentedStorage) Download(ctx context.Context, bucket, key string) (io.ReadCloser, error) {
+	start := time.Now()
+	body, err := s.store.Download(ctx, bucket, key)
+	s.observe("download", start, err)
+	if err != nil {
+		return nil, err
+	}
+	return &downloadReader{ReadCloser: body, backend: s.backend}, nil
+}
+
+func (s *InstrumentedStorage) Delete(ctx context.Context, bucket, key string) error {
+	start := time.Now()
+	err := s.store.Delete(ctx, bucket, key)
+	s.observe("delete", start, err)
+	return err
+}
+
+func (s *InstrumentedStorage) List(ctx context.Context, bucket, prefix string) ([]ObjectInfo, error) {
+	start := time.Now()
+	objects, err := s.store.List(ctx, bucket, prefix)
+	s.observe("list", start, err)
+	return objects, err
+}
+
+func (s *InstrumentedStorage) Exists(ctx context.Context, bucket, key string) (bool, error) {
+	start := time.Now()
+	exists, err := s.store.Exists(ctx, bucket, key)
+	s.observe("exists", start, err)
+	return exists, err
+}
+
+func (s *InstrumentedStorage) GetSignedURL(ctx context.Context, bucket, key string, expiry time.Duration) (string, error) {
+	start := time.Now()
+	signed, err := s.store.GetSignedURL(ctx, bucket, key, expiry)
+	s.observe("signed_url", start, err)
+	return signed, err
+}
+
+func (s *InstrumentedStorage) CreateBucket(ctx context.Context, bucket string) error {
+	start := time.Now()
+	err := s.store.CreateBucket(ctx, bucket)
+	s.observe("create_bucket", start, err)
+	return err
+}
+
+func (s *InstrumentedStorage) DeleteBucket(ctx context.Context, bucket string) error {
+	start := time.Now()
+	err := s.store.DeleteBucket(ctx, bucket)
+	s.observe("delete_bucket", start, err)
+	return err
+}
+
+// countingReader counts the bytes a backend reads from an upload
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// downloadReader counts the bytes of a download as they are read
+type downloadReader struct {
+	io.ReadCloser
+	backend string
+}
+
+func (d *downloadReader) Read(p []byte) (int, error) {
+	n, err := d.ReadCloser.Read(p)
+	if n > 0 {
+		observability.ObjectStorageBytes.WithLabelValues(d.backend, "download").Add(float64(n))
+	}
+	return n, err
+}
@@ -0,0 +1,136 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sharding-system/pkg/observability"
+	"go.uber.org/zap/zaptest"
+)
+
+// fakeStorage keeps objects in memory and fails deletes of missing objects
+type fakeStorage struct {
+	objects map[string]string
+}
+
+func (f *fakeStorage) Upload(ctx context.Context, bucket, key string, data io.Reader, metadata map[string]string) error {
+	body, err := io.ReadAll(data)
+	if err != nil {
+		return err
+	}
+	f.objects[bucket+"/"+key] = string(body)
+	return nil
+}
+
+func (f *fakeStorage) Download(ctx context.Context, bucket, key string) (io.ReadCloser, error) {
+	body, ok := f.objects[bucket+"/"+key]
+	if !ok {
+		return nil, errors.New("object not found")
+	}
+	return io.NopCloser(strings.NewReader(body)), nil
+}
+
+func (f *fakeStorage) Delete(ctx context.Context, bucket, key string) error {
+	if _, ok := f.objects[bucket+"/"+key]; !ok {
+		return errors.New("object not found")
+	}
+	delete(f.objects, bucket+"/"+key)
+	return nil
+}
+
+func (f *fakeStorage) List(ctx context.Context, bucket, prefix string) ([]ObjectInfo, error) {
+	return nil, nil
+}
+func (f *fakeStorage) Exists(ctx context.Context, bucket, key string) (bool, error) {
+	_, ok := f.objects[bucket+"/"+key]
+	return ok, nil
+}
+func (f *fakeStorage) GetSignedURL(ctx context.Context, bucket, key string, expiry time.Duration) (string, error) {
+	return "", nil
+}
+func (f *fakeStorage) CreateBucket(ctx context.Context, bucket string) error { return nil }
+func (f *fakeStorage) DeleteBucket(ctx context.Context, bucket string) error { return nil }
+
+func operations(backend, operation, status string) float64 {
+	return testutil.ToFloat64(observability.ObjectStorageOperations.WithLabelValues(backend, operation, status))
+}
+
+func TestInstrumentedStorage_RecordsOperations(t *testing.T) {
+	ctx := context.Background()
+	store := Instrument("fake", &fakeStorage{objects: make(map[string]string)})
+
+	if err := store.Upload(ctx, "backups", "db1/full", strings.NewReader("0123456789"), nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	body, err := store.Download(ctx, "backups", "db1/full")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data, _ := io.ReadAll(body)
+	body.Close()
+	if string(data) != "0123456789" {
+		t.Errorf("expected the uploaded object, got %q", data)
+	}
+	if _, err := store.Download(ctx, "backups", "missing"); err == nil {
+		t.Error("expected downloading a missing object to fail")
+	}
+	if err := store.Delete(ctx, "backups", "db1/full"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := store.Delete(ctx, "backups", "db1/full"); err == nil {
+		t.Error("expected deleting a missing object to fail")
+	}
+
+	for _, c := range []struct {
+		operation, status string
+		want              float64
+	}{
+		{"upload", "success", 1},
+		{"upload", "error", 0},
+		{"download", "success", 1},
+		{"download", "error", 1},
+		{"delete", "success", 1},
+		{"delete", "error", 1},
+	} {
+		if got := operations("fake", c.operation, c.status); got != c.want {
+			t.Errorf("expected %v %s operations with status %s, got %v", c.want, c.operation, c.status, got)
+		}
+	}
+
+	if got := testutil.ToFloat64(observability.ObjectStorageBytes.WithLabelValues("fake", "upload")); got != 10 {
+		t.Errorf("expected 10 bytes uploaded, got %v", got)
+	}
+	if got := testutil.ToFloat64(observability.ObjectStorageBytes.WithLabelValues("fake", "download")); got != 10 {
+		t.Errorf("expected 10 bytes downloaded, got %v", got)
+	}
+	if got := testutil.CollectAndCount(observability.ObjectStorageDuration, "object_storage_operation_duration_seconds"); got < 3 {
+		t.Errorf("expected latencies for upload, download and delete, got %d series", got)
+	}
+}
+
+func TestNewObjectStorage_Instrumented(t *testing.T) {
+	store, err := NewObjectStorage(zaptest.NewLogger(t), StorageConfig{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	instrumented, ok := store.(*InstrumentedStorage)
+	if !ok {
+		t.Fatalf("expected an instrumented backend, got %T", store)
+	}
+	if _, ok := instrumented.Unwrap().(*LocalStorage); !ok {
+		t.Errorf("expected local storage by default, got %T", instrumented.Unwrap())
+	}
+
+	before := operations("local", "upload", "success")
+	if err := store.Upload(context.Background(), "backups", "key", strings.NewReader("data"), nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := operations("local", "upload", "success"); got != before+1 {
+		t.Errorf("expected the upload to be recorded under the local backend, got %v", got-before)
+	}
+}
@@ -55,20 +55,31 @@ type StorageConfig struct {
 	MaxRetries      int           `json:"max_retries"`
 }
 
-// NewObjectStorage creates a new object storage client based on configuration
+// NewObjectStorage creates a new object storage client based on configuration.
+// The client's operations are recorded in the object storage metrics.
 func NewObjectStorage(logger *zap.Logger, cfg StorageConfig) (ObjectStorage, error) {
+	var (
+		store ObjectStorage
+		err   error
+	)
+	backend := cfg.Type
 	switch cfg.Type {
 	case "s3":
-		return NewS3Storage(logger, cfg)
+		store, err = NewS3Storage(logger, cfg)
 	case "gcs":
-		return NewGCSStorage(logger, cfg)
+		store, err = NewGCSStorage(logger, cfg)
 	case "azure":
-		return NewAzureStorage(logger, cfg)
+		store, err = NewAzureStorage(logger, cfg)
 	case "local", "":
-		return NewLocalStorage(logger, cfg)
+		backend = "local"
+		store, err = NewLocalStorage(logger, cfg)
 	default:
 		return nil, fmt.Errorf("unsupported storage type: %s", cfg.Type)
 	}
+	if err != nil {
+		return nil, err
+	}
+	return Instrument(backend, store), nil
 }
 
 // S3Storage implements ObjectStorage for Amazon S3 compatible storage
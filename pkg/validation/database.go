@@ -7,6 +7,7 @@ import (
 	"strings"
 	"time"
 
+	_ "github.com/go-sql-driver/mysql"
 	_ "github.com/lib/pq"
 )
 
@@ -46,12 +47,18 @@ func ValidateDatabaseConnection(ctx context.Context, host, port, database, usern
 		dsn += " sslmode=prefer connect_timeout=10"
 	}
 
+	return ValidateConnection(ctx, "postgres", dsn)
+}
+
+// ValidateConnection checks that a database can be reached and queried with
+// the given database/sql driver and DSN
+func ValidateConnection(ctx context.Context, driverName, dsn string) error {
 	// Create context with timeout
 	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 
 	// Open database connection
-	db, err := sql.Open("postgres", dsn)
+	db, err := sql.Open(driverName, dsn)
 	if err != nil {
 		return fmt.Errorf("failed to open database connection: %w", err)
 	}
@@ -75,7 +82,7 @@ func ValidateDatabaseConnection(ctx context.Context, host, port, database, usern
 func HasDatabaseInfo(host, port, database, username, password, primaryEndpoint string) bool {
 	// If primaryEndpoint is provided and looks like a connection string, consider it valid
 	if primaryEndpoint != "" {
-		if strings.HasPrefix(primaryEndpoint, "postgres://") || strings.HasPrefix(primaryEndpoint, "postgresql://") {
+		if strings.HasPrefix(primaryEndpoint, "postgres://") || strings.HasPrefix(primaryEndpoint, "postgresql://") || strings.HasPrefix(primaryEndpoint, "mysql://") {
 			return true
 		}
 	}
@@ -6,9 +6,13 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/gorilla/mux"
 	"github.com/sharding-system/internal/api"
+	"github.com/sharding-system/internal/middleware"
 	"github.com/sharding-system/pkg/catalog"
 	"github.com/sharding-system/pkg/config"
 	"github.com/sharding-system/pkg/database"
@@ -121,6 +125,127 @@ func TestListTemplates(t *testing.T) {
 	}
 }
 
+func TestListTemplates_APIVersions(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	catalog := setupMockCatalog(t)
+	shardManager := manager.NewManager(catalog, logger, setupMockResharder(catalog), setupMockPricingConfig())
+	dbService := database.NewDatabaseService(shardManager, logger, "localhost", 8080)
+	dbService.SetTemplates(database.NewTemplateRegistry(database.NewMemoryTemplateStore()))
+	clusterManager := scanner.NewClusterManager(logger)
+	multiClusterScanner := scanner.NewMultiClusterScanner(clusterManager, scanner.NewDatabaseScanner(logger), logger)
+	dbHandler := api.NewDatabaseHandler(dbService, clusterManager, multiClusterScanner, logger)
+	handler := middleware.APIVersion("")(http.HandlerFunc(dbHandler.ListTemplates))
+
+	list := func(header, version string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/v1/databases/templates", nil)
+		if header != "" {
+			req.Header.Set(header, version)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	// v1 is served by default as a bare array
+	w := list("", "")
+	if got := w.Header().Get(middleware.APIVersionHeader); got != "v1" {
+		t.Errorf("Expected v1 to be served by default, got %q", got)
+	}
+	var templates []database.DatabaseTemplate
+	if err := json.Unmarshal(w.Body.Bytes(), &templates); err != nil {
+		t.Fatalf("Expected a v1 array of templates: %v", err)
+	}
+	if len(templates) != 3 {
+		t.Errorf("Expected the 3 built-in templates, got %d", len(templates))
+	}
+
+	// v2 wraps the templates and names the default
+	for _, header := range []string{middleware.APIVersionHeader, middleware.AcceptVersionHeader} {
+		w = list(header, "2")
+		if got := w.Header().Get(middleware.APIVersionHeader); got != "v2" {
+			t.Errorf("Expected v2 to be served for %s, got %q", header, got)
+		}
+		var listing struct {
+			Templates []database.DatabaseTemplate `json:"templates"`
+			Default   string                      `json:"default"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &listing); err != nil {
+			t.Fatalf("Expected a v2 object for %s: %v", header, err)
+		}
+		if len(listing.Templates) != 3 || listing.Default != "starter" {
+			t.Errorf("Expected 3 templates with starter as default, got %d with %q", len(listing.Templates), listing.Default)
+		}
+	}
+
+	// Unsupported versions are refused before reaching the handler
+	w = list(middleware.APIVersionHeader, "v9")
+	if w.Code != http.StatusNotAcceptable {
+		t.Errorf("Expected status 406 for an unsupported version, got %d", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), "UNSUPPORTED_API_VERSION") {
+		t.Errorf("Expected an unsupported version error, got %s", w.Body.String())
+	}
+}
+
+func TestGetSizeHistory(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	catalog := setupMockCatalog(t)
+	shardManager := manager.NewManager(catalog, logger, setupMockResharder(catalog), setupMockPricingConfig())
+	dbService := database.NewDatabaseService(shardManager, logger, "localhost", 8080)
+	clusterManager := scanner.NewClusterManager(logger)
+	multiClusterScanner := scanner.NewMultiClusterScanner(clusterManager, scanner.NewDatabaseScanner(logger), logger)
+	dbHandler := api.NewDatabaseHandler(dbService, clusterManager, multiClusterScanner, logger)
+	dbHandler.SetScanHistory(scanner.NewScanHistory(scanner.NewMemoryHistoryStore(), 0))
+	router := mux.NewRouter()
+	api.SetupDatabaseRoutes(router, dbHandler)
+
+	// Scans from a week ago and yesterday are kept
+	for _, age := range []time.Duration{7 * 24 * time.Hour, 24 * time.Hour} {
+		scannedAt := time.Now().Add(-age)
+		dbHandler.UpdateScanResults([]models.ScannedDatabase{{
+			ID:            "orders-db",
+			DatabaseName:  "orders",
+			Status:        "scanned",
+			LastScannedAt: &scannedAt,
+			ScanResults:   &models.DatabaseScanResults{Size: int64(age / time.Hour), TableCount: 4},
+		}})
+	}
+
+	get := func(query string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/databases/orders-db/size-history"+query, nil))
+		return w
+	}
+
+	w := get("")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var history struct {
+		DatabaseID string               `json:"database_id"`
+		Samples    []scanner.ScanSample `json:"samples"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &history); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if history.DatabaseID != "orders-db" || len(history.Samples) != 2 || history.Samples[0].Size != 168 || history.Samples[1].Size != 24 {
+		t.Errorf("Expected both scans oldest first, got %+v", history)
+	}
+
+	// Only the scans in the requested range are returned
+	from := time.Now().Add(-3 * 24 * time.Hour).UTC().Format(time.RFC3339)
+	if err := json.Unmarshal(get("?from="+from).Body.Bytes(), &history); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if len(history.Samples) != 1 || history.Samples[0].Size != 24 {
+		t.Errorf("Expected only yesterday's scan, got %+v", history.Samples)
+	}
+
+	if w := get("?from=yesterday"); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an invalid time, got %d", w.Code)
+	}
+}
+
 // Helper functions
 func setupMockCatalog(t *testing.T) *MockCatalog {
 	return &MockCatalog{}
@@ -162,3 +287,7 @@ func (m *MockCatalog) GetCatalogVersion() (int64, error) {
 func (m *MockCatalog) Watch(ctx context.Context) (<-chan *models.ShardCatalog, error) {
 	return nil, nil
 }
+
+func (m *MockCatalog) Reload() error {
+	return nil
+}